@@ -237,13 +237,15 @@ func toGoType(i int, t Argument, output []byte) (interface{}, error) {
 	switch t.Type.T {
 	case StringTy, BytesTy: // variable arrays are written at the end of the return bytes
 		// parse offset from which we should start reading
-		offset := int(binary.BigEndian.Uint64(output[index+24 : index+32]))
-		if offset+32 > len(output) {
+		// (bounds are checked on the raw 64 bit values, as a hostile offset or
+		// size may otherwise overflow into a negative int)
+		offset := binary.BigEndian.Uint64(output[index+24 : index+32])
+		if offset > uint64(len(output)) || offset+32 > uint64(len(output)) {
 			return nil, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), offset+32)
 		}
 		// parse the size up until we should be reading
-		size := int(binary.BigEndian.Uint64(output[offset+24 : offset+32]))
-		if offset+32+size > len(output) {
+		size := binary.BigEndian.Uint64(output[offset+24 : offset+32])
+		if size > uint64(len(output)) || offset+32+size > uint64(len(output)) {
 			return nil, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), offset+32+size)
 		}
 
@@ -280,9 +282,16 @@ var (
 	r_byte       = reflect.TypeOf(byte(0))
 )
 
-// Unpack output in v according to the abi specification
+// Unpack output in v according to the abi specification. If name refers to an
+// event instead of a method, output is the data of one of its logs, and only the
+// non-indexed arguments are unpacked.
 func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
-	var method = abi.Methods[name]
+	method, ok := abi.Methods[name]
+	if !ok {
+		if event, ok := abi.Events[name]; ok {
+			return event.unpack(v, output)
+		}
+	}
 
 	if len(output) == 0 {
 		return fmt.Errorf("abi: unmarshalling empty output")
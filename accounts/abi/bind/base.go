@@ -27,6 +27,7 @@ import (
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/event"
 )
 
 // SignerFn is a signer function callback when a contract requires a method to
@@ -55,6 +56,23 @@ type TransactOpts struct {
 	Context context.Context // Network context to support cancellation and timeouts (nil = no timeout)
 }
 
+// FilterOpts is the collection of options to fine tune filtering for events
+// within a bound contract.
+type FilterOpts struct {
+	Start uint64  // Start of the queried range
+	End   *uint64 // End of the range (nil = latest)
+
+	Context context.Context // Network context to support cancellation and timeouts (nil = no timeout)
+}
+
+// WatchOpts is the collection of options to fine tune subscribing for events
+// within a bound contract.
+type WatchOpts struct {
+	Start *uint64 // Start of the queried range (nil = latest)
+
+	Context context.Context // Network context to support cancellation and timeouts (nil = no timeout)
+}
+
 // BoundContract is the base wrapper object that reflects a contract on the
 // Ethereum network. It contains a collection of methods that are used by the
 // higher level contract bindings to operate.
@@ -63,16 +81,18 @@ type BoundContract struct {
 	abi        abi.ABI            // Reflect based ABI to access the correct Ethereum methods
 	caller     ContractCaller     // Read interface to interact with the blockchain
 	transactor ContractTransactor // Write interface to interact with the blockchain
+	filterer   ContractFilterer   // Event filtering to interact with the blockchain
 }
 
-// NewBoundContract creates a low level contract interface through which calls
-// and transactions may be made through.
-func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller, transactor ContractTransactor) *BoundContract {
+// NewBoundContract creates a low level contract interface through which calls,
+// transactions and event filtering may be made through.
+func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller, transactor ContractTransactor, filterer ContractFilterer) *BoundContract {
 	return &BoundContract{
 		address:    address,
 		abi:        abi,
 		caller:     caller,
 		transactor: transactor,
+		filterer:   filterer,
 	}
 }
 
@@ -80,7 +100,7 @@ func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller
 // deployment address with a Go wrapper.
 func DeployContract(opts *TransactOpts, abi abi.ABI, bytecode []byte, backend ContractBackend, params ...interface{}) (common.Address, *types.Transaction, *BoundContract, error) {
 	// Otherwise try to deploy the contract
-	c := NewBoundContract(common.Address{}, abi, backend, backend)
+	c := NewBoundContract(common.Address{}, abi, backend, backend, backend)
 
 	input, err := c.abi.Pack("", params...)
 	if err != nil {
@@ -225,6 +245,122 @@ func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, i
 	return signedTx, nil
 }
 
+// FilterLogs filters contract logs for past blocks, returning the logs of the
+// named event matching the rules of its indexed arguments, and a subscription
+// they are streamed through.
+func (c *BoundContract) FilterLogs(opts *FilterOpts, name string, query ...[]interface{}) (chan types.Log, event.Subscription, error) {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(FilterOpts)
+	}
+	if c.filterer == nil {
+		return nil, nil, ErrNoFilterer
+	}
+	config, err := c.filterQuery(name, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	config.FromBlock = new(big.Int).SetUint64(opts.Start)
+	if opts.End != nil {
+		config.ToBlock = new(big.Int).SetUint64(*opts.End)
+	}
+	// Retrieve the past logs and stream them through a subscription for the
+	// results to be consumed the same way as the live ones
+	buff, err := c.filterer.FilterLogs(ensureContext(opts.Context), config)
+	if err != nil {
+		return nil, nil, err
+	}
+	logs := make(chan types.Log, 128)
+	sub := event.NewSubscription(func(quit <-chan struct{}) error {
+		for _, log := range buff {
+			select {
+			case logs <- log:
+			case <-quit:
+				return nil
+			}
+		}
+		return nil
+	})
+	return logs, sub, nil
+}
+
+// WatchLogs subscribes to the logs of the named event emitted by the contract
+// from now on (or from the start block of the options), filtered by the rules of
+// its indexed arguments.
+func (c *BoundContract) WatchLogs(opts *WatchOpts, name string, query ...[]interface{}) (chan types.Log, event.Subscription, error) {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(WatchOpts)
+	}
+	if c.filterer == nil {
+		return nil, nil, ErrNoFilterer
+	}
+	config, err := c.filterQuery(name, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Start != nil {
+		config.FromBlock = new(big.Int).SetUint64(*opts.Start)
+	}
+	logs := make(chan types.Log, 128)
+
+	sub, err := c.filterer.SubscribeFilterLogs(ensureContext(opts.Context), config, logs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return logs, sub, nil
+}
+
+// filterQuery assembles the log filter matching the named event of the contract,
+// with the given rules for its indexed arguments.
+func (c *BoundContract) filterQuery(name string, query [][]interface{}) (ethereum.FilterQuery, error) {
+	event, ok := c.abi.Events[name]
+	if !ok {
+		return ethereum.FilterQuery{}, fmt.Errorf("event %q not found in the contract ABI", name)
+	}
+	// Anonymous events don't carry their signature in the first topic
+	if !event.Anonymous {
+		query = append([][]interface{}{{event.Id()}}, query...)
+	}
+	topics, err := makeTopics(query...)
+	if err != nil {
+		return ethereum.FilterQuery{}, err
+	}
+	return ethereum.FilterQuery{Addresses: []common.Address{c.address}, Topics: topics}, nil
+}
+
+// UnpackLog unpacks a retrieved log of the named event into the provided struct,
+// the non-indexed arguments from the log data and the indexed ones from its
+// topics.
+func (c *BoundContract) UnpackLog(out interface{}, name string, log types.Log) error {
+	event, ok := c.abi.Events[name]
+	if !ok {
+		return fmt.Errorf("event %q not found in the contract ABI", name)
+	}
+	if len(log.Data) > 0 {
+		if err := c.abi.Unpack(out, name, log.Data); err != nil {
+			return err
+		}
+	}
+	var indexed []abi.Argument
+	for i, arg := range event.Inputs {
+		if arg.Indexed {
+			if arg.Name == "" {
+				arg.Name = fmt.Sprintf("arg%d", i)
+			}
+			indexed = append(indexed, arg)
+		}
+	}
+	topics := log.Topics
+	if !event.Anonymous {
+		if len(topics) == 0 {
+			return errors.New("log without event signature")
+		}
+		topics = topics[1:]
+	}
+	return parseTopics(out, indexed, topics)
+}
+
 func ensureContext(ctx context.Context) context.Context {
 	if ctx == nil {
 		return context.TODO()
@@ -447,6 +447,99 @@ var bindTests = []struct {
 			}
 		`,
 	},
+	// Tests that events can be filtered from the past and watched live, with their
+	// indexed and non-indexed fields unpacked (bytecode assembled by hand to emit a
+	// single LOG3 on any call, matching the contract below)
+	{
+		`Eventer`,
+		`
+			contract Eventer {
+				event Emitted(address indexed sender, bytes32 indexed tag, uint256 value);
+
+				function emit(bytes32 tag, uint256 value) {
+					Emitted(msg.sender, tag, value);
+				}
+			}
+		`,
+		`603180600b6000396000f3602435600052600435337f25b91ba3f74dfb35e1df664a6e84b16f9b58df188e8072a068e1396b3750352660206000a300`,
+		`[{"constant":false,"inputs":[{"name":"tag","type":"bytes32"},{"name":"value","type":"uint256"}],"name":"emit","outputs":[],"type":"function"},{"anonymous":false,"inputs":[{"indexed":true,"name":"sender","type":"address"},{"indexed":true,"name":"tag","type":"bytes32"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Emitted","type":"event"}]`,
+		`
+			// Generate a new random account and a funded simulator
+			key, _ := crypto.GenerateKey()
+			auth := bind.NewKeyedTransactor(key)
+			sim := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(10000000000)}})
+
+			// Deploy an eventer contract to raise the events
+			_, _, eventer, err := DeployEventer(auth, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy eventer contract: %v", err)
+			}
+			sim.Commit()
+
+			// Watch for new events with one of the tags, before any get emitted
+			sink := make(chan *EventerEmitted, 4)
+			sub, err := eventer.WatchEmitted(nil, sink, nil, [][32]byte{{2}})
+			if err != nil {
+				t.Fatalf("Failed to watch events: %v", err)
+			}
+			defer sub.Unsubscribe()
+
+			// Emit a few events across two blocks
+			for i := 0; i < 3; i++ {
+				if _, err := eventer.Emit(auth, [32]byte{byte(1 + i%2)}, big.NewInt(int64(i))); err != nil {
+					t.Fatalf("Failed to emit event %d: %v", i, err)
+				}
+			}
+			sim.Commit()
+
+			if _, err := eventer.Emit(auth, [32]byte{2}, big.NewInt(3)); err != nil {
+				t.Fatalf("Failed to emit event 3: %v", err)
+			}
+			sim.Commit()
+
+			// Filter the past events by the other tag and check their contents
+			iter, err := eventer.FilterEmitted(nil, []common.Address{auth.From}, [][32]byte{{1}})
+			if err != nil {
+				t.Fatalf("Failed to filter events: %v", err)
+			}
+			var values []int64
+			for iter.Next() {
+				if iter.Event.Sender != auth.From {
+					t.Errorf("Sender mismatch: have %x, want %x", iter.Event.Sender, auth.From)
+				}
+				if iter.Event.Tag != [32]byte{1} {
+					t.Errorf("Tag mismatch: have %x, want %x", iter.Event.Tag, [32]byte{1})
+				}
+				values = append(values, iter.Event.Value.Int64())
+			}
+			if err := iter.Error(); err != nil {
+				t.Fatalf("Failed to iterate events: %v", err)
+			}
+			iter.Close()
+
+			if len(values) != 2 || values[0] != 0 || values[1] != 2 {
+				t.Fatalf("Filtered values mismatch: have %v, want [0 2]", values)
+			}
+			// Check that the watched events arrived (blocks are announced async)
+			watched := make(map[int64]bool)
+			for len(watched) < 2 {
+				select {
+				case event := <-sink:
+					if event.Tag != [32]byte{2} {
+						t.Fatalf("Watched tag mismatch: have %x, want %x", event.Tag, [32]byte{2})
+					}
+					watched[event.Value.Int64()] = true
+				case err := <-sub.Err():
+					t.Fatalf("Subscription failed: %v", err)
+				case <-time.After(time.Second):
+					t.Fatalf("Watched events timeout: have %v", watched)
+				}
+			}
+			if !watched[1] || !watched[3] {
+				t.Fatalf("Watched values mismatch: have %v, want 1 and 3", watched)
+			}
+		`,
+	},
 }
 
 // Tests that packages generated by the binder can be successfully compiled and
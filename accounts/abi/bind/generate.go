@@ -0,0 +1,117 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/expanse-org/go-expanse/common/compiler"
+)
+
+// GenerateConfig specifies the contracts to generate bindings for, either by an
+// ABI file (with optional bytecode and type name) or by a Solidity source.
+type GenerateConfig struct {
+	ABI  string // Path to the contract ABI json
+	Bin  string // Path to the contract bytecode (generates a deploy method)
+	Type string // Struct name for the binding (default = package name)
+
+	Sol     string   // Path to the Solidity source to build and bind
+	Solc    string   // Solidity compiler to build the source with
+	Exclude []string // Contract types to exclude from binding
+
+	Pkg  string // Package name to generate the binding into
+	Lang string // Destination language for the bindings (go, java, objc)
+}
+
+// Generate validates the configuration, gathers the contracts it specifies and
+// returns their bindings in the requested language.
+func Generate(config *GenerateConfig) (string, error) {
+	// Ensure all needed inputs are specified
+	if config.ABI == "" && config.Sol == "" {
+		return "", errors.New("no contract ABI or Solidity source specified")
+	} else if (config.ABI != "" || config.Bin != "" || config.Type != "") && config.Sol != "" {
+		return "", errors.New("contract ABI, bytecode and type are mutually exclusive with the Solidity source")
+	}
+	if config.Pkg == "" {
+		return "", errors.New("no destination package specified")
+	}
+	var lang Lang
+	switch config.Lang {
+	case "go":
+		lang = LangGo
+	case "java":
+		lang = LangJava
+	case "objc":
+		lang = LangObjC
+	default:
+		return "", fmt.Errorf("unsupported destination language %q", config.Lang)
+	}
+	// Gather the contracts to bind, either by building the Solidity source or
+	// by loading up the ABI, optional bytecode and type name
+	var abis, bins, types []string
+
+	if config.Sol != "" {
+		exclude := make(map[string]bool)
+		for _, kind := range config.Exclude {
+			exclude[strings.ToLower(kind)] = true
+		}
+		contracts, err := compiler.CompileSolidity(config.Solc, config.Sol)
+		if err != nil {
+			return "", fmt.Errorf("failed to build Solidity contract: %v", err)
+		}
+		for name, contract := range contracts {
+			if exclude[strings.ToLower(name)] {
+				continue
+			}
+			abi, _ := json.Marshal(contract.Info.AbiDefinition) // Flatten the compiler parse
+			abis = append(abis, string(abi))
+			bins = append(bins, contract.Code)
+
+			nameParts := strings.Split(name, ":")
+			types = append(types, nameParts[len(nameParts)-1])
+		}
+	} else {
+		abi, err := ioutil.ReadFile(config.ABI)
+		if err != nil {
+			return "", fmt.Errorf("failed to read input ABI: %v", err)
+		}
+		abis = append(abis, string(abi))
+
+		var bin []byte
+		if config.Bin != "" {
+			if bin, err = ioutil.ReadFile(config.Bin); err != nil {
+				return "", fmt.Errorf("failed to read input bytecode: %v", err)
+			}
+		}
+		bins = append(bins, string(bin))
+
+		kind := config.Type
+		if kind == "" {
+			kind = config.Pkg
+		}
+		types = append(types, kind)
+	}
+	code, err := Bind(types, abis, bins, config.Pkg, lang)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ABI binding: %v", err)
+	}
+	return code, nil
+}
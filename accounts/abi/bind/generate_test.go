@@ -0,0 +1,66 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests that binding generation rejects incomplete or conflicting inputs and
+// binds a contract loaded from an ABI file.
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bind-generate-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	abi := filepath.Join(dir, "empty.abi")
+	if err := ioutil.WriteFile(abi, []byte(`[]`), 0600); err != nil {
+		t.Fatalf("failed to write contract ABI: %v", err)
+	}
+	tests := []struct {
+		config *GenerateConfig
+		fail   bool
+	}{
+		{&GenerateConfig{Pkg: "empty", Lang: "go"}, true},
+		{&GenerateConfig{ABI: abi, Sol: "empty.sol", Pkg: "empty", Lang: "go"}, true},
+		{&GenerateConfig{ABI: abi, Lang: "go"}, true},
+		{&GenerateConfig{ABI: abi, Pkg: "empty", Lang: "cobol"}, true},
+		{&GenerateConfig{ABI: abi, Pkg: "empty", Lang: "go"}, false},
+	}
+	for i, tt := range tests {
+		code, err := Generate(tt.config)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: expected failure, got binding", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to generate binding: %v", i, err)
+			continue
+		}
+		if !strings.Contains(code, "package empty") || !strings.Contains(code, "func NewEmpty(") {
+			t.Errorf("test %d: unexpected binding:\n%s", i, code)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/expanse-org/go-expanse/accounts/abi"
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/math"
+	"github.com/expanse-org/go-expanse/crypto"
+)
+
+// makeTopics converts the filter rules of the indexed event arguments into the
+// topics of a log filter query. Each rule slice holds the alternatives for one
+// argument, an empty slice matching anything. Dynamic values (strings and byte
+// slices) are hashed, as that's what the EVM stores in the topics.
+func makeTopics(query ...[]interface{}) ([][]common.Hash, error) {
+	topics := make([][]common.Hash, len(query))
+	for i, filter := range query {
+		for _, rule := range filter {
+			var topic common.Hash
+
+			switch rule := rule.(type) {
+			case common.Hash:
+				topic = rule
+			case common.Address:
+				copy(topic[common.HashLength-common.AddressLength:], rule[:])
+			case *big.Int:
+				copy(topic[:], math.PaddedBigBytes(math.U256(new(big.Int).Set(rule)), common.HashLength))
+			case bool:
+				if rule {
+					topic[common.HashLength-1] = 1
+				}
+			case int8:
+				copy(topic[:], math.PaddedBigBytes(math.U256(big.NewInt(int64(rule))), common.HashLength))
+			case int16:
+				copy(topic[:], math.PaddedBigBytes(math.U256(big.NewInt(int64(rule))), common.HashLength))
+			case int32:
+				copy(topic[:], math.PaddedBigBytes(math.U256(big.NewInt(int64(rule))), common.HashLength))
+			case int64:
+				copy(topic[:], math.PaddedBigBytes(math.U256(big.NewInt(rule)), common.HashLength))
+			case uint8:
+				topic = common.BigToHash(new(big.Int).SetUint64(uint64(rule)))
+			case uint16:
+				topic = common.BigToHash(new(big.Int).SetUint64(uint64(rule)))
+			case uint32:
+				topic = common.BigToHash(new(big.Int).SetUint64(uint64(rule)))
+			case uint64:
+				topic = common.BigToHash(new(big.Int).SetUint64(rule))
+			case string:
+				topic = crypto.Keccak256Hash([]byte(rule))
+			case []byte:
+				topic = crypto.Keccak256Hash(rule)
+
+			default:
+				// Fixed size byte arrays are left aligned in the topic
+				val := reflect.ValueOf(rule)
+				if val.Kind() != reflect.Array || val.Type().Elem().Kind() != reflect.Uint8 || val.Len() > common.HashLength {
+					return nil, fmt.Errorf("unsupported indexed type: %T", rule)
+				}
+				reflect.Copy(reflect.ValueOf(topic[:val.Len()]), val)
+			}
+			topics[i] = append(topics[i], topic)
+		}
+	}
+	return topics, nil
+}
+
+// parseTopics sets the indexed event arguments, reconstructed from the topics of
+// a log (without the event signature), to the fields of the struct out points to.
+// Dynamic arguments can only be recovered as their hashes.
+func parseTopics(out interface{}, fields []abi.Argument, topics []common.Hash) error {
+	if len(fields) != len(topics) {
+		return errors.New("topic/field count mismatch")
+	}
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot parse topics into %T, struct pointer required", out)
+	}
+	value = value.Elem()
+
+	for i, arg := range fields {
+		if !arg.Indexed {
+			return errors.New("non-indexed field in topic reconstruction")
+		}
+		field := value.FieldByName(capitalise(arg.Name))
+		if !field.IsValid() {
+			return fmt.Errorf("field %s missing from %v", capitalise(arg.Name), value.Type())
+		}
+		topic := topics[i]
+
+		switch {
+		case arg.Type.T == abi.FixedBytesTy:
+			if field.Kind() != reflect.Array || field.Len() > common.HashLength {
+				return fmt.Errorf("unsupported field type for %s: %v", arg.Type, field.Type())
+			}
+			reflect.Copy(field, reflect.ValueOf(topic[:field.Len()]))
+
+		case arg.Type.IsSlice || arg.Type.IsArray || arg.Type.T == abi.StringTy || arg.Type.T == abi.HashTy:
+			// Dynamic types and arrays are only represented by their hash
+			field.Set(reflect.ValueOf(topic))
+
+		case arg.Type.T == abi.AddressTy:
+			field.Set(reflect.ValueOf(common.BytesToAddress(topic[common.HashLength-common.AddressLength:])))
+
+		case arg.Type.T == abi.BoolTy:
+			field.SetBool(topic[common.HashLength-1] != 0)
+
+		case arg.Type.T == abi.IntTy || arg.Type.T == abi.UintTy:
+			num := new(big.Int).SetBytes(topic[:])
+			if arg.Type.T == abi.IntTy {
+				num = math.S256(num)
+			}
+			switch field.Kind() {
+			case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				field.SetInt(num.Int64())
+			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				field.SetUint(num.Uint64())
+			default:
+				field.Set(reflect.ValueOf(num))
+			}
+
+		default:
+			return fmt.Errorf("unsupported indexed type: %v", arg.Type)
+		}
+	}
+	return nil
+}
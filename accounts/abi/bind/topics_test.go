@@ -0,0 +1,114 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/accounts/abi"
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/crypto"
+)
+
+// Tests that filter rules of various types are converted to the topics they are
+// stored as in the logs.
+func TestMakeTopics(t *testing.T) {
+	topics, err := makeTopics(
+		[]interface{}{common.Address{0x11}},
+		[]interface{}{big.NewInt(1), big.NewInt(-1)},
+		[]interface{}{uint32(2), true},
+		[]interface{}{"hello", [4]byte{0xde, 0xad}},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to make topics: %v", err)
+	}
+	want := [][]common.Hash{
+		{common.BytesToHash(common.Address{0x11}.Bytes())},
+		{common.BigToHash(big.NewInt(1)), common.BytesToHash(common.FromHex("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"))},
+		{common.BigToHash(big.NewInt(2)), common.BigToHash(big.NewInt(1))},
+		{crypto.Keccak256Hash([]byte("hello")), {0xde, 0xad}},
+		nil,
+	}
+	if !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics mismatch:\nhave %x\nwant %x", topics, want)
+	}
+	if _, err := makeTopics([]interface{}{struct{}{}}); err == nil {
+		t.Errorf("made topic from unsupported type")
+	}
+}
+
+// Tests that the indexed arguments of an event can be reconstructed from the
+// log topics, dynamic types being left as their hashes.
+func TestParseTopics(t *testing.T) {
+	definition := `[{ "type" : "event", "name" : "transfer", "inputs": [
+		{ "name" : "from", "type": "address", "indexed": true },
+		{ "name" : "value", "type": "int64", "indexed": true },
+		{ "name" : "amount", "type": "uint256", "indexed": true },
+		{ "name" : "tag", "type": "bytes4", "indexed": true },
+		{ "name" : "note", "type": "string", "indexed": true }
+	]}]`
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics, err := makeTopics(
+		[]interface{}{common.Address{0x11}},
+		[]interface{}{int64(-5)},
+		[]interface{}{big.NewInt(1000)},
+		[]interface{}{[4]byte{1, 2, 3, 4}},
+		[]interface{}{"hello"},
+	)
+	if err != nil {
+		t.Fatalf("failed to make topics: %v", err)
+	}
+	flat := make([]common.Hash, len(topics))
+	for i, topic := range topics {
+		flat[i] = topic[0]
+	}
+	var event struct {
+		From   common.Address
+		Value  int64
+		Amount *big.Int
+		Tag    [4]byte
+		Note   common.Hash
+	}
+	if err := parseTopics(&event, parsed.Events["transfer"].Inputs, flat); err != nil {
+		t.Fatalf("failed to parse topics: %v", err)
+	}
+	if event.From != (common.Address{0x11}) {
+		t.Errorf("address mismatch: have %x, want %x", event.From, common.Address{0x11})
+	}
+	if event.Value != -5 {
+		t.Errorf("int mismatch: have %d, want %d", event.Value, -5)
+	}
+	if event.Amount.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("big int mismatch: have %v, want %v", event.Amount, 1000)
+	}
+	if event.Tag != [4]byte{1, 2, 3, 4} {
+		t.Errorf("fixed bytes mismatch: have %x, want %x", event.Tag, [4]byte{1, 2, 3, 4})
+	}
+	if event.Note != crypto.Keccak256Hash([]byte("hello")) {
+		t.Errorf("dynamic hash mismatch: have %x, want %x", event.Note, crypto.Keccak256Hash([]byte("hello")))
+	}
+	if err := parseTopics(&event, parsed.Events["transfer"].Inputs, flat[1:]); err == nil {
+		t.Errorf("parsed topics with count mismatch")
+	}
+}
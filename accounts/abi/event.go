@@ -18,6 +18,7 @@ package abi
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/expanse-org/go-expanse/common"
@@ -33,6 +34,19 @@ type Event struct {
 	Inputs    []Argument
 }
 
+// String returns the Solidity declaration of the event, e.g. for documenting
+// the generated bindings.
+func (e Event) String() string {
+	inputs := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		inputs[i] = fmt.Sprintf("%v %v", input.Name, input.Type)
+		if input.Indexed {
+			inputs[i] = fmt.Sprintf("%v indexed %v", input.Name, input.Type)
+		}
+	}
+	return fmt.Sprintf("event %v(%v)", e.Name, strings.Join(inputs, ", "))
+}
+
 // Id returns the canonical representation of the event's signature used by the
 // abi definition to identify event names and types.
 func (e Event) Id() common.Hash {
@@ -44,3 +58,40 @@ func (e Event) Id() common.Hash {
 	}
 	return common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("%v(%v)", e.Name, strings.Join(types, ",")))))
 }
+
+// unpack sets the non-indexed arguments of the event, carried by the data of its
+// logs, to the fields of the struct v points to. Fields are matched by the
+// capitalised argument names, or ArgN for unnamed arguments (N being the index
+// among all inputs).
+func (e Event) unpack(v interface{}, output []byte) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("abi: cannot unmarshal event into %T, struct pointer required", v)
+	}
+	value = value.Elem()
+
+	slot := 0
+	for i, input := range e.Inputs {
+		if input.Indexed {
+			continue
+		}
+		marshalledValue, err := toGoType(slot, input, output)
+		if err != nil {
+			return err
+		}
+		slot++
+
+		name := fmt.Sprintf("Arg%d", i)
+		if input.Name != "" {
+			name = strings.ToUpper(input.Name[:1]) + input.Name[1:]
+		}
+		field := value.FieldByName(name)
+		if !field.IsValid() {
+			return fmt.Errorf("abi: field %s missing from %v", name, value.Type())
+		}
+		if err := set(field, reflect.ValueOf(marshalledValue), input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -17,6 +17,7 @@
 package abi
 
 import (
+	"math/big"
 	"strings"
 	"testing"
 
@@ -54,3 +55,56 @@ func TestEventId(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the non-indexed arguments of an event can be unpacked from the data
+// of its logs, skipping over the indexed ones.
+func TestEventUnpack(t *testing.T) {
+	definition := `[{ "type" : "event", "name" : "transfer", "inputs": [
+		{ "name" : "from", "type": "address", "indexed": true },
+		{ "name" : "value", "type": "uint256" },
+		{ "name" : "to", "type": "address", "indexed": true },
+		{ "name" : "", "type": "bool" }
+	]}]`
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(common.LeftPadBytes(big.NewInt(42).Bytes(), 32), common.LeftPadBytes([]byte{1}, 32)...)
+
+	var event struct {
+		From  common.Address
+		Value *big.Int
+		To    common.Address
+		Arg3  bool
+	}
+	if err := abi.Unpack(&event, "transfer", data); err != nil {
+		t.Fatalf("failed to unpack event: %v", err)
+	}
+	if event.Value.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("value mismatch: have %v, want %v", event.Value, 42)
+	}
+	if !event.Arg3 {
+		t.Errorf("unnamed argument mismatch: have %v, want %v", event.Arg3, true)
+	}
+	if (event.From != common.Address{}) || (event.To != common.Address{}) {
+		t.Errorf("indexed arguments unpacked from data: from %x, to %x", event.From, event.To)
+	}
+	// Unpacking into anything but a struct pointer should fail
+	if err := abi.Unpack(new(big.Int), "transfer", data); err == nil {
+		t.Errorf("unpacked event into non-struct")
+	}
+}
+
+func TestEventString(t *testing.T) {
+	definition := `[{ "type" : "event", "name" : "transfer", "inputs": [
+		{ "name" : "from", "type": "address", "indexed": true },
+		{ "name" : "value", "type": "uint256" }
+	]}]`
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := abi.Events["transfer"].String(), "event transfer(from indexed address, value uint256)"; have != want {
+		t.Errorf("event string mismatch: have %q, want %q", have, want)
+	}
+}
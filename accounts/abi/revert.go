@@ -0,0 +1,49 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/expanse-org/go-expanse/crypto"
+)
+
+// revertSelector is the 4 byte selector of the Error(string) pseudo-function
+// solidity uses to encode the reason passed to revert and require.
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// errInvalidRevert is returned if the revert data isn't an encoded reason string.
+var errInvalidRevert = errors.New("abi: invalid revert data")
+
+// UnpackRevert resolves the reason string from the data returned by a reverted
+// EVM execution, failing if the data wasn't produced by Error(string).
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], revertSelector) {
+		return "", errInvalidRevert
+	}
+	typ, err := NewType("string")
+	if err != nil {
+		return "", err
+	}
+	reason, err := toGoType(0, Argument{Type: typ}, data[4:])
+	if err != nil {
+		return "", fmt.Errorf("abi: invalid revert reason: %v", err)
+	}
+	return reason.(string), nil
+}
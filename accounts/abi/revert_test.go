@@ -0,0 +1,53 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+)
+
+func TestUnpackRevert(t *testing.T) {
+	tests := []struct {
+		input  string
+		reason string
+		fail   bool
+	}{
+		{"", "", true},
+		{"08c379a1", "", true},
+		{"08c379a0", "", true},
+		// Error("revert reason")
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000", "revert reason", false},
+		// Error with an offset pointing outside of the data
+		{"08c379a0ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff000000000000000000000000000000000000000000000000000000000000000d", "", true},
+		// Error with a length running over the end of the data
+		{"08c379a000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000040", "", true},
+	}
+	for i, tt := range tests {
+		reason, err := UnpackRevert(common.Hex2Bytes(tt.input))
+		if tt.fail && err == nil {
+			t.Errorf("test %d: expected failure, got reason %q", i, reason)
+		}
+		if !tt.fail && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if reason != tt.reason {
+			t.Errorf("test %d: reason mismatch: have %q, want %q", i, reason, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,529 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// This file contains a minimal protocol buffer codec for the subset of the
+// Trezor wire messages needed to derive accounts and sign transactions. The
+// message definitions can be found in the Trezor common GitHub repo:
+// https://github.com/trezor/trezor-common/blob/master/protob/messages.proto
+
+package usbwallet
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Trezor message type identifiers used in the transport header.
+const (
+	trezorMsgInitialize         uint16 = 0
+	trezorMsgPing               uint16 = 1
+	trezorMsgSuccess            uint16 = 2
+	trezorMsgFailure            uint16 = 3
+	trezorMsgFeatures           uint16 = 17
+	trezorMsgPinMatrixRequest   uint16 = 18
+	trezorMsgPinMatrixAck       uint16 = 19
+	trezorMsgButtonRequest      uint16 = 26
+	trezorMsgButtonAck          uint16 = 27
+	trezorMsgEthereumGetAddress uint16 = 56
+	trezorMsgEthereumAddress    uint16 = 57
+	trezorMsgEthereumSignTx     uint16 = 58
+	trezorMsgEthereumTxRequest  uint16 = 59
+	trezorMsgEthereumTxAck      uint16 = 60
+)
+
+// trezorMessageNames maps the message type identifiers to human readable names.
+var trezorMessageNames = map[uint16]string{
+	trezorMsgInitialize:         "Initialize",
+	trezorMsgPing:               "Ping",
+	trezorMsgSuccess:            "Success",
+	trezorMsgFailure:            "Failure",
+	trezorMsgFeatures:           "Features",
+	trezorMsgPinMatrixRequest:   "PinMatrixRequest",
+	trezorMsgPinMatrixAck:       "PinMatrixAck",
+	trezorMsgButtonRequest:      "ButtonRequest",
+	trezorMsgButtonAck:          "ButtonAck",
+	trezorMsgEthereumGetAddress: "EthereumGetAddress",
+	trezorMsgEthereumAddress:    "EthereumAddress",
+	trezorMsgEthereumSignTx:     "EthereumSignTx",
+	trezorMsgEthereumTxRequest:  "EthereumTxRequest",
+	trezorMsgEthereumTxAck:      "EthereumTxAck",
+}
+
+// trezorMessageName returns the human readable name of a Trezor message type.
+func trezorMessageName(kind uint16) string {
+	if name, ok := trezorMessageNames[kind]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", kind)
+}
+
+// Protocol buffer wire types used by the Trezor messages.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// errProtoTruncated is returned if a protocol buffer message ends abruptly.
+var errProtoTruncated = errors.New("truncated protobuf message")
+
+// trezorMessage is a protocol buffer message exchanged with a Trezor device.
+type trezorMessage interface {
+	kind() uint16             // Message type identifier for the transport header
+	marshal() []byte          // Protocol buffer encoding of the message
+	unmarshal(b []byte) error // Protocol buffer decoding into the message
+}
+
+// protoEncoder accumulates the protocol buffer encoding of a message.
+type protoEncoder []byte
+
+// varint appends a raw base 128 varint to the encoder.
+func (enc *protoEncoder) varint(v uint64) {
+	for v >= 0x80 {
+		*enc = append(*enc, byte(v)|0x80)
+		v >>= 7
+	}
+	*enc = append(*enc, byte(v))
+}
+
+// uint appends an unsigned integer field.
+func (enc *protoEncoder) uint(field int, v uint64) {
+	enc.varint(uint64(field)<<3 | protoVarint)
+	enc.varint(v)
+}
+
+// bool appends a boolean field.
+func (enc *protoEncoder) bool(field int, v bool) {
+	if v {
+		enc.uint(field, 1)
+	} else {
+		enc.uint(field, 0)
+	}
+}
+
+// bytes appends a length delimited field.
+func (enc *protoEncoder) bytes(field int, b []byte) {
+	enc.varint(uint64(field)<<3 | protoBytes)
+	enc.varint(uint64(len(b)))
+	*enc = append(*enc, b...)
+}
+
+// readVarint decodes a base 128 varint from the start of b, returning it along
+// with the number of bytes consumed.
+func readVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errProtoTruncated
+}
+
+// protoDecode iterates over the fields of an encoded protocol buffer message,
+// invoking fn with the value of every varint and length delimited field. Fixed
+// width fields are skipped as none of the supported messages use them.
+func protoDecode(b []byte, fn func(field int, value uint64, data []byte)) error {
+	for len(b) > 0 {
+		key, n, err := readVarint(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+
+		field := int(key >> 3)
+		switch key & 0x7 {
+		case protoVarint:
+			value, n, err := readVarint(b)
+			if err != nil {
+				return err
+			}
+			b = b[n:]
+			fn(field, value, nil)
+
+		case protoBytes:
+			size, n, err := readVarint(b)
+			if err != nil {
+				return err
+			}
+			b = b[n:]
+			if uint64(len(b)) < size {
+				return errProtoTruncated
+			}
+			fn(field, 0, b[:size])
+			b = b[size:]
+
+		case protoFixed64:
+			if len(b) < 8 {
+				return errProtoTruncated
+			}
+			b = b[8:]
+
+		case protoFixed32:
+			if len(b) < 4 {
+				return errProtoTruncated
+			}
+			b = b[4:]
+
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", key&0x7)
+		}
+	}
+	return nil
+}
+
+// protoSkip validates an encoded protocol buffer message without decoding any
+// of its fields, used by messages that carry no data of interest.
+func protoSkip(b []byte) error {
+	return protoDecode(b, func(int, uint64, []byte) {})
+}
+
+// trezorInitialize resets the device and requests its features.
+type trezorInitialize struct{}
+
+func (m *trezorInitialize) kind() uint16             { return trezorMsgInitialize }
+func (m *trezorInitialize) marshal() []byte          { return nil }
+func (m *trezorInitialize) unmarshal(b []byte) error { return protoSkip(b) }
+
+// trezorPing tests the device connection, optionally requesting PIN entry.
+type trezorPing struct {
+	PinProtection bool // Whether the device should ask for the PIN if locked
+}
+
+func (m *trezorPing) kind() uint16 { return trezorMsgPing }
+
+func (m *trezorPing) marshal() []byte {
+	var enc protoEncoder
+	if m.PinProtection {
+		enc.bool(3, true)
+	}
+	return enc
+}
+
+func (m *trezorPing) unmarshal(b []byte) error {
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		if field == 3 {
+			m.PinProtection = value != 0
+		}
+	})
+}
+
+// trezorSuccess is the device reply to a successfully executed request.
+type trezorSuccess struct {
+	Message string // Human readable description of the success
+}
+
+func (m *trezorSuccess) kind() uint16 { return trezorMsgSuccess }
+
+func (m *trezorSuccess) marshal() []byte {
+	var enc protoEncoder
+	if m.Message != "" {
+		enc.bytes(1, []byte(m.Message))
+	}
+	return enc
+}
+
+func (m *trezorSuccess) unmarshal(b []byte) error {
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		if field == 1 {
+			m.Message = string(data)
+		}
+	})
+}
+
+// trezorFailure is the device reply to a failed request.
+type trezorFailure struct {
+	Code    uint64 // Failure code as defined by the Trezor firmware
+	Message string // Human readable description of the failure
+}
+
+func (m *trezorFailure) kind() uint16 { return trezorMsgFailure }
+
+func (m *trezorFailure) marshal() []byte {
+	var enc protoEncoder
+	enc.uint(1, m.Code)
+	if m.Message != "" {
+		enc.bytes(2, []byte(m.Message))
+	}
+	return enc
+}
+
+func (m *trezorFailure) unmarshal(b []byte) error {
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		switch field {
+		case 1:
+			m.Code = value
+		case 2:
+			m.Message = string(data)
+		}
+	})
+}
+
+// trezorFeatures is the device reply to an initialization request.
+type trezorFeatures struct {
+	MajorVersion uint32 // Major version of the firmware
+	MinorVersion uint32 // Minor version of the firmware
+	PatchVersion uint32 // Patch version of the firmware
+	Label        string // User defined textual label of the device
+}
+
+func (m *trezorFeatures) kind() uint16 { return trezorMsgFeatures }
+
+func (m *trezorFeatures) marshal() []byte {
+	var enc protoEncoder
+	enc.uint(2, uint64(m.MajorVersion))
+	enc.uint(3, uint64(m.MinorVersion))
+	enc.uint(4, uint64(m.PatchVersion))
+	if m.Label != "" {
+		enc.bytes(10, []byte(m.Label))
+	}
+	return enc
+}
+
+func (m *trezorFeatures) unmarshal(b []byte) error {
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		switch field {
+		case 2:
+			m.MajorVersion = uint32(value)
+		case 3:
+			m.MinorVersion = uint32(value)
+		case 4:
+			m.PatchVersion = uint32(value)
+		case 10:
+			m.Label = string(data)
+		}
+	})
+}
+
+// trezorPinMatrixRequest is the device request for the user to enter the PIN.
+type trezorPinMatrixRequest struct{}
+
+func (m *trezorPinMatrixRequest) kind() uint16             { return trezorMsgPinMatrixRequest }
+func (m *trezorPinMatrixRequest) marshal() []byte          { return nil }
+func (m *trezorPinMatrixRequest) unmarshal(b []byte) error { return protoSkip(b) }
+
+// trezorPinMatrixAck carries the PIN entered by the user, encoded according to
+// the shuffled pinpad displayed on the device.
+type trezorPinMatrixAck struct {
+	Pin string // Pinpad encoded PIN of the device
+}
+
+func (m *trezorPinMatrixAck) kind() uint16 { return trezorMsgPinMatrixAck }
+
+func (m *trezorPinMatrixAck) marshal() []byte {
+	var enc protoEncoder
+	enc.bytes(1, []byte(m.Pin))
+	return enc
+}
+
+func (m *trezorPinMatrixAck) unmarshal(b []byte) error {
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		if field == 1 {
+			m.Pin = string(data)
+		}
+	})
+}
+
+// trezorButtonRequest is the device notification that user confirmation is
+// pending on the device itself.
+type trezorButtonRequest struct{}
+
+func (m *trezorButtonRequest) kind() uint16             { return trezorMsgButtonRequest }
+func (m *trezorButtonRequest) marshal() []byte          { return nil }
+func (m *trezorButtonRequest) unmarshal(b []byte) error { return protoSkip(b) }
+
+// trezorButtonAck acknowledges a button request, waiting for user confirmation.
+type trezorButtonAck struct{}
+
+func (m *trezorButtonAck) kind() uint16             { return trezorMsgButtonAck }
+func (m *trezorButtonAck) marshal() []byte          { return nil }
+func (m *trezorButtonAck) unmarshal(b []byte) error { return protoSkip(b) }
+
+// trezorEthereumGetAddress requests the address at a given derivation path.
+type trezorEthereumGetAddress struct {
+	AddressN []uint32 // BIP32 derivation path of the requested address
+}
+
+func (m *trezorEthereumGetAddress) kind() uint16 { return trezorMsgEthereumGetAddress }
+
+func (m *trezorEthereumGetAddress) marshal() []byte {
+	var enc protoEncoder
+	for _, n := range m.AddressN {
+		enc.uint(1, uint64(n))
+	}
+	return enc
+}
+
+func (m *trezorEthereumGetAddress) unmarshal(b []byte) error {
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		if field == 1 {
+			m.AddressN = append(m.AddressN, uint32(value))
+		}
+	})
+}
+
+// trezorEthereumAddress is the device reply to an address derivation request.
+type trezorEthereumAddress struct {
+	Address []byte // Derived Ethereum address
+}
+
+func (m *trezorEthereumAddress) kind() uint16 { return trezorMsgEthereumAddress }
+
+func (m *trezorEthereumAddress) marshal() []byte {
+	var enc protoEncoder
+	enc.bytes(1, m.Address)
+	return enc
+}
+
+func (m *trezorEthereumAddress) unmarshal(b []byte) error {
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		if field == 1 {
+			m.Address = append([]byte{}, data...)
+		}
+	})
+}
+
+// trezorEthereumSignTx requests the device to sign a transaction. Any data
+// beyond the initial chunk is streamed on request of the device.
+type trezorEthereumSignTx struct {
+	AddressN         []uint32 // BIP32 derivation path of the signing account
+	Nonce            []byte   // Big endian account nonce
+	GasPrice         []byte   // Big endian gas price
+	GasLimit         []byte   // Big endian gas limit
+	To               []byte   // Recipient address, empty for contract creations
+	Value            []byte   // Big endian value to transfer
+	DataInitialChunk []byte   // First chunk of the transaction data (max 1024 bytes)
+	DataLength       uint32   // Total length of the transaction data
+	ChainID          uint32   // EIP-155 chain identifier, zero for homestead signing
+}
+
+func (m *trezorEthereumSignTx) kind() uint16 { return trezorMsgEthereumSignTx }
+
+func (m *trezorEthereumSignTx) marshal() []byte {
+	var enc protoEncoder
+	for _, n := range m.AddressN {
+		enc.uint(1, uint64(n))
+	}
+	enc.bytes(2, m.Nonce)
+	enc.bytes(3, m.GasPrice)
+	enc.bytes(4, m.GasLimit)
+	if len(m.To) > 0 {
+		enc.bytes(5, m.To)
+	}
+	enc.bytes(6, m.Value)
+	if len(m.DataInitialChunk) > 0 {
+		enc.bytes(7, m.DataInitialChunk)
+	}
+	if m.DataLength > 0 {
+		enc.uint(8, uint64(m.DataLength))
+	}
+	if m.ChainID > 0 {
+		enc.uint(9, uint64(m.ChainID))
+	}
+	return enc
+}
+
+func (m *trezorEthereumSignTx) unmarshal(b []byte) error {
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		switch field {
+		case 1:
+			m.AddressN = append(m.AddressN, uint32(value))
+		case 2:
+			m.Nonce = append([]byte{}, data...)
+		case 3:
+			m.GasPrice = append([]byte{}, data...)
+		case 4:
+			m.GasLimit = append([]byte{}, data...)
+		case 5:
+			m.To = append([]byte{}, data...)
+		case 6:
+			m.Value = append([]byte{}, data...)
+		case 7:
+			m.DataInitialChunk = append([]byte{}, data...)
+		case 8:
+			m.DataLength = uint32(value)
+		case 9:
+			m.ChainID = uint32(value)
+		}
+	})
+}
+
+// trezorEthereumTxRequest is the device reply to a signing request, either
+// asking for more transaction data or carrying the final signature.
+type trezorEthereumTxRequest struct {
+	DataLength uint32 // Length of the next data chunk requested, zero if none
+	SignatureV uint32 // Signature recovery id, set when signing finished
+	SignatureR []byte // Signature R value, set when signing finished
+	SignatureS []byte // Signature S value, set when signing finished
+}
+
+func (m *trezorEthereumTxRequest) kind() uint16 { return trezorMsgEthereumTxRequest }
+
+func (m *trezorEthereumTxRequest) marshal() []byte {
+	var enc protoEncoder
+	if m.DataLength > 0 {
+		enc.uint(1, uint64(m.DataLength))
+	}
+	if m.SignatureV > 0 {
+		enc.uint(2, uint64(m.SignatureV))
+	}
+	if len(m.SignatureR) > 0 {
+		enc.bytes(3, m.SignatureR)
+	}
+	if len(m.SignatureS) > 0 {
+		enc.bytes(4, m.SignatureS)
+	}
+	return enc
+}
+
+func (m *trezorEthereumTxRequest) unmarshal(b []byte) error {
+	*m = trezorEthereumTxRequest{} // Replies are reused across data chunks
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		switch field {
+		case 1:
+			m.DataLength = uint32(value)
+		case 2:
+			m.SignatureV = uint32(value)
+		case 3:
+			m.SignatureR = append([]byte{}, data...)
+		case 4:
+			m.SignatureS = append([]byte{}, data...)
+		}
+	})
+}
+
+// trezorEthereumTxAck streams the next chunk of transaction data to the device.
+type trezorEthereumTxAck struct {
+	DataChunk []byte // Next chunk of the transaction data
+}
+
+func (m *trezorEthereumTxAck) kind() uint16 { return trezorMsgEthereumTxAck }
+
+func (m *trezorEthereumTxAck) marshal() []byte {
+	var enc protoEncoder
+	enc.bytes(1, m.DataChunk)
+	return enc
+}
+
+func (m *trezorEthereumTxAck) unmarshal(b []byte) error {
+	return protoDecode(b, func(field int, value uint64, data []byte) {
+		if field == 1 {
+			m.DataChunk = append([]byte{}, data...)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package usbwallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/accounts"
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+// Tests that Trezor messages are encoded into the expected protocol buffer
+// representation and can be decoded back.
+func TestTrezorMessageEncoding(t *testing.T) {
+	// Known encodings to guard against wire format mistakes
+	if enc := (&trezorPinMatrixAck{Pin: "123"}).marshal(); !bytes.Equal(enc, []byte{0x0a, 0x03, '1', '2', '3'}) {
+		t.Errorf("pin ack encoding mismatch: have %x", enc)
+	}
+	features := new(trezorFeatures)
+	if err := features.unmarshal([]byte{0x10, 0x01, 0x18, 0x05, 0x20, 0x02, 0x52, 0x04, 't', 'e', 's', 't'}); err != nil {
+		t.Fatalf("failed to decode features: %v", err)
+	}
+	if want := (trezorFeatures{MajorVersion: 1, MinorVersion: 5, PatchVersion: 2, Label: "test"}); *features != want {
+		t.Errorf("features mismatch: have %+v, want %+v", *features, want)
+	}
+	if err := new(trezorFeatures).unmarshal([]byte{0x52, 0x04, 't'}); err != errProtoTruncated {
+		t.Errorf("truncated message error mismatch: have %v, want %v", err, errProtoTruncated)
+	}
+	// Round trip a few messages with multi-byte varints and repeated fields
+	messages := []struct {
+		msg, dec trezorMessage
+	}{
+		{&trezorEthereumGetAddress{AddressN: accounts.DefaultBaseDerivationPath}, new(trezorEthereumGetAddress)},
+		{&trezorEthereumSignTx{
+			AddressN:         accounts.DefaultRootDerivationPath,
+			Nonce:            []byte{0x01},
+			GasPrice:         []byte{0x04, 0xa8, 0x17, 0xc8, 0x00},
+			GasLimit:         []byte{0x52, 0x08},
+			To:               common.HexToAddress("0x0102030405060708091011121314151617181920").Bytes(),
+			Value:            []byte{0x0d, 0xe0, 0xb6, 0xb3, 0xa7, 0x64, 0x00, 0x00},
+			DataInitialChunk: bytes.Repeat([]byte{0xff}, 300),
+			DataLength:       2000,
+			ChainID:          2,
+		}, new(trezorEthereumSignTx)},
+		{&trezorEthereumTxRequest{SignatureV: 39, SignatureR: []byte{1, 2}, SignatureS: []byte{3, 4}}, new(trezorEthereumTxRequest)},
+	}
+	for i, tt := range messages {
+		if err := tt.dec.unmarshal(tt.msg.marshal()); err != nil {
+			t.Errorf("test %d: failed to decode message: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.msg, tt.dec) {
+			t.Errorf("test %d: round trip mismatch: have %+v, want %+v", i, tt.dec, tt.msg)
+		}
+	}
+}
+
+// trezorTestDevice is a fake Trezor USB device recording the written chunks
+// and replying with a pre-assembled stream of chunks.
+type trezorTestDevice struct {
+	written bytes.Buffer
+	replies bytes.Buffer
+}
+
+func (d *trezorTestDevice) Write(b []byte) (int, error) { return d.written.Write(b) }
+func (d *trezorTestDevice) Read(b []byte) (int, error)  { return d.replies.Read(b) }
+
+// reply queues up a message on the fake device, chunked up according to the
+// Trezor transport protocol.
+func (d *trezorTestDevice) reply(msg trezorMessage) {
+	data := msg.marshal()
+
+	payload := make([]byte, 8+len(data))
+	copy(payload, []byte{0x23, 0x23})
+	binary.BigEndian.PutUint16(payload[2:], msg.kind())
+	binary.BigEndian.PutUint32(payload[4:], uint32(len(data)))
+	copy(payload[8:], data)
+
+	for len(payload) > 0 {
+		chunk := make([]byte, 64)
+		chunk[0] = 0x3f
+		payload = payload[copy(chunk[1:], payload):]
+		d.replies.Write(chunk)
+	}
+}
+
+// Tests that data exchanges with a Trezor device are chunked and reassembled
+// correctly, and that button and failure replies are handled.
+func TestTrezorExchange(t *testing.T) {
+	device := new(trezorTestDevice)
+	driver := &trezorDriver{device: device, log: log.New()}
+
+	// Derive an address, requiring user confirmation on the device first
+	want := common.HexToAddress("0x0102030405060708091011121314151617181920")
+
+	device.reply(new(trezorButtonRequest))
+	device.reply(&trezorEthereumAddress{Address: want.Bytes()})
+
+	have, err := driver.Derive(accounts.DefaultBaseDerivationPath)
+	if err != nil {
+		t.Fatalf("failed to derive address: %v", err)
+	}
+	if have != want {
+		t.Errorf("derived address mismatch: have %x, want %x", have, want)
+	}
+	// Ensure the request and the button acknowledgement were both sent
+	sent := device.written.Bytes()
+	if len(sent) != 128 {
+		t.Fatalf("sent data length mismatch: have %d, want %d", len(sent), 128)
+	}
+	if kind := binary.BigEndian.Uint16(sent[3:5]); kind != trezorMsgEthereumGetAddress {
+		t.Errorf("first request type mismatch: have %d, want %d", kind, trezorMsgEthereumGetAddress)
+	}
+	if kind := binary.BigEndian.Uint16(sent[64+3 : 64+5]); kind != trezorMsgButtonAck {
+		t.Errorf("second request type mismatch: have %d, want %d", kind, trezorMsgButtonAck)
+	}
+	// Retrieve a reply spanning multiple chunks
+	label := strings.Repeat("x", 200)
+	device.reply(&trezorFeatures{MajorVersion: 1, MinorVersion: 5, PatchVersion: 2, Label: label})
+	device.reply(&trezorSuccess{})
+
+	if err := driver.Open(device, ""); err != nil {
+		t.Fatalf("failed to open device: %v", err)
+	}
+	if status, want := driver.Status(), "Trezor v1.5.2 '"+label+"' online"; status != want {
+		t.Errorf("status mismatch: have %q, want %q", status, want)
+	}
+	// Ensure failures are reported as errors
+	device.reply(&trezorFailure{Message: "Action cancelled by user"})
+
+	if _, err := driver.Derive(accounts.DefaultBaseDerivationPath); err == nil || err.Error() != "trezor: Action cancelled by user" {
+		t.Errorf("failure error mismatch: have %v", err)
+	}
+}
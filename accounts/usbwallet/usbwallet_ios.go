@@ -14,9 +14,8 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
-// This file contains the implementation for interacting with the Ledger hardware
-// wallets. The wire protocol spec can be found in the Ledger Blue GitHub repo:
-// https://raw.githubusercontent.com/LedgerHQ/blue-app-eth/master/doc/ethapp.asc
+// This file contains the stubs for the USB hardware wallets on iOS, where
+// there is no USB support.
 
 // +build ios
 
@@ -36,3 +35,7 @@ var ErrIOSNotSupported = errors.New("no USB support on iOS")
 func NewLedgerHub() (accounts.Backend, error) {
 	return nil, ErrIOSNotSupported
 }
+
+func NewTrezorHub() (accounts.Backend, error) {
+	return nil, ErrIOSNotSupported
+}
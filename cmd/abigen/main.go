@@ -17,7 +17,6 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -25,7 +24,6 @@ import (
 	"strings"
 
 	"github.com/expanse-org/go-expanse/accounts/abi/bind"
-	"github.com/expanse-org/go-expanse/common/compiler"
 )
 
 var (
@@ -43,87 +41,19 @@ var (
 )
 
 func main() {
-	// Parse and ensure all needed inputs are specified
 	flag.Parse()
 
-	if *abiFlag == "" && *solFlag == "" {
-		fmt.Printf("No contract ABI (--abi) or Solidity source (--sol) specified\n")
-		os.Exit(-1)
-	} else if (*abiFlag != "" || *binFlag != "" || *typFlag != "") && *solFlag != "" {
-		fmt.Printf("Contract ABI (--abi), bytecode (--bin) and type (--type) flags are mutually exclusive with the Solidity source (--sol) flag\n")
-		os.Exit(-1)
-	}
-	if *pkgFlag == "" {
-		fmt.Printf("No destination package specified (--pkg)\n")
-		os.Exit(-1)
-	}
-	var lang bind.Lang
-	switch *langFlag {
-	case "go":
-		lang = bind.LangGo
-	case "java":
-		lang = bind.LangJava
-	case "objc":
-		lang = bind.LangObjC
-	default:
-		fmt.Printf("Unsupported destination language \"%s\" (--lang)\n", *langFlag)
-		os.Exit(-1)
-	}
-	// If the entire solidity code was specified, build and bind based on that
-	var (
-		abis  []string
-		bins  []string
-		types []string
-	)
-	if *solFlag != "" {
-		// Generate the list of types to exclude from binding
-		exclude := make(map[string]bool)
-		for _, kind := range strings.Split(*excFlag, ",") {
-			exclude[strings.ToLower(kind)] = true
-		}
-		contracts, err := compiler.CompileSolidity(*solcFlag, *solFlag)
-		if err != nil {
-			fmt.Printf("Failed to build Solidity contract: %v\n", err)
-			os.Exit(-1)
-		}
-		// Gather all non-excluded contract for binding
-		for name, contract := range contracts {
-			if exclude[strings.ToLower(name)] {
-				continue
-			}
-			abi, _ := json.Marshal(contract.Info.AbiDefinition) // Flatten the compiler parse
-			abis = append(abis, string(abi))
-			bins = append(bins, contract.Code)
-
-			nameParts := strings.Split(name, ":")
-			types = append(types, nameParts[len(nameParts)-1])
-		}
-	} else {
-		// Otherwise load up the ABI, optional bytecode and type name from the parameters
-		abi, err := ioutil.ReadFile(*abiFlag)
-		if err != nil {
-			fmt.Printf("Failed to read input ABI: %v\n", err)
-			os.Exit(-1)
-		}
-		abis = append(abis, string(abi))
-
-		bin := []byte{}
-		if *binFlag != "" {
-			if bin, err = ioutil.ReadFile(*binFlag); err != nil {
-				fmt.Printf("Failed to read input bytecode: %v\n", err)
-				os.Exit(-1)
-			}
-		}
-		bins = append(bins, string(bin))
-
-		kind := *typFlag
-		if kind == "" {
-			kind = *pkgFlag
-		}
-		types = append(types, kind)
-	}
-	// Generate the contract binding
-	code, err := bind.Bind(types, abis, bins, *pkgFlag, lang)
+	// Generate the contract binding from the flags
+	code, err := bind.Generate(&bind.GenerateConfig{
+		ABI:     *abiFlag,
+		Bin:     *binFlag,
+		Type:    *typFlag,
+		Sol:     *solFlag,
+		Solc:    *solcFlag,
+		Exclude: strings.Split(*excFlag, ","),
+		Pkg:     *pkgFlag,
+		Lang:    *langFlag,
+	})
 	if err != nil {
 		fmt.Printf("Failed to generate ABI binding: %v\n", err)
 		os.Exit(-1)
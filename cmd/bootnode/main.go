@@ -41,6 +41,7 @@ func main() {
 		nodeKeyHex  = flag.String("nodekeyhex", "", "private key as hex (for testing)")
 		natdesc     = flag.String("nat", "none", "port mapping mechanism (any|none|upnp|pmp|extip:<IP>)")
 		netrestrict = flag.String("netrestrict", "", "restrict network communication to the given IP networks (CIDR masks)")
+		netdeny     = flag.String("netdeny", "", "deny network communication with the given IP networks (CIDR masks)")
 		runv5       = flag.Bool("v5", false, "run a v5 topic discovery bootnode")
 		verbosity   = flag.Int("verbosity", int(log.LvlInfo), "log verbosity (0-9)")
 		vmodule     = flag.String("vmodule", "", "log verbosity pattern")
@@ -87,20 +88,27 @@ func main() {
 		os.Exit(0)
 	}
 
-	var restrictList *netutil.Netlist
+	var restrictList, denyList *netutil.Netlist
 	if *netrestrict != "" {
 		restrictList, err = netutil.ParseNetlist(*netrestrict)
 		if err != nil {
 			utils.Fatalf("-netrestrict: %v", err)
 		}
 	}
+	if *netdeny != "" {
+		denyList, err = netutil.ParseNetlist(*netdeny)
+		if err != nil {
+			utils.Fatalf("-netdeny: %v", err)
+		}
+	}
+	filter := netutil.NewNetFilter(restrictList, denyList)
 
 	if *runv5 {
-		if _, err := discv5.ListenUDP(nodeKey, *listenAddr, natm, "", restrictList); err != nil {
+		if _, err := discv5.ListenUDP(nodeKey, *listenAddr, natm, "", filter); err != nil {
 			utils.Fatalf("%v", err)
 		}
 	} else {
-		if _, err := discover.ListenUDP(nodeKey, *listenAddr, natm, "", restrictList); err != nil {
+		if _, err := discover.ListenUDP(nodeKey, *listenAddr, natm, "", filter); err != nil {
 			utils.Fatalf("%v", err)
 		}
 	}
@@ -0,0 +1,114 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/expanse-org/go-expanse/accounts/abi/bind"
+	"github.com/expanse-org/go-expanse/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	abigenABIFlag = cli.StringFlag{
+		Name:  "abi",
+		Usage: "Path to the Expanse contract ABI json to bind",
+	}
+	abigenBinFlag = cli.StringFlag{
+		Name:  "bin",
+		Usage: "Path to the Expanse contract bytecode (generate deploy method)",
+	}
+	abigenTypeFlag = cli.StringFlag{
+		Name:  "type",
+		Usage: "Struct name for the binding (default = package name)",
+	}
+	abigenSolFlag = cli.StringFlag{
+		Name:  "sol",
+		Usage: "Path to the Expanse contract Solidity source to build and bind",
+	}
+	abigenExcFlag = cli.StringFlag{
+		Name:  "exc",
+		Usage: "Comma separated types to exclude from binding",
+	}
+	abigenPkgFlag = cli.StringFlag{
+		Name:  "pkg",
+		Usage: "Package name to generate the binding into",
+	}
+	abigenOutFlag = cli.StringFlag{
+		Name:  "out",
+		Usage: "Output file for the generated binding (default = stdout)",
+	}
+	abigenLangFlag = cli.StringFlag{
+		Name:  "lang",
+		Value: "go",
+		Usage: "Destination language for the bindings (go, java, objc)",
+	}
+	abigenCommand = cli.Command{
+		Action:    abigen,
+		Name:      "abigen",
+		Usage:     "Generate typed contract bindings from an ABI or Solidity source",
+		ArgsUsage: " ",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Flags: []cli.Flag{
+			abigenABIFlag,
+			abigenBinFlag,
+			abigenTypeFlag,
+			abigenSolFlag,
+			abigenExcFlag,
+			abigenPkgFlag,
+			abigenOutFlag,
+			abigenLangFlag,
+		},
+		Description: `
+The abigen command generates typed bindings for Expanse contracts, either from
+a contract ABI (and optional bytecode) or from a Solidity source compiled with
+the compiler set by --solc. The Go bindings provide call, transact, event filter
+and event watch helpers that can be used with any contract backend, such as the
+RPC client of a running node.
+`,
+	}
+)
+
+// abigen generates the language bindings of the contracts specified by the
+// command flags.
+func abigen(ctx *cli.Context) error {
+	code, err := bind.Generate(&bind.GenerateConfig{
+		ABI:     ctx.String(abigenABIFlag.Name),
+		Bin:     ctx.String(abigenBinFlag.Name),
+		Type:    ctx.String(abigenTypeFlag.Name),
+		Sol:     ctx.String(abigenSolFlag.Name),
+		Solc:    ctx.GlobalString(utils.SolcPathFlag.Name),
+		Exclude: strings.Split(ctx.String(abigenExcFlag.Name), ","),
+		Pkg:     ctx.String(abigenPkgFlag.Name),
+		Lang:    ctx.String(abigenLangFlag.Name),
+	})
+	if err != nil {
+		utils.Fatalf("Failed to generate ABI binding: %v", err)
+	}
+	// Flush the binding out to a file or the standard output
+	if out := ctx.String(abigenOutFlag.Name); out != "" {
+		if err := ioutil.WriteFile(out, []byte(code), 0600); err != nil {
+			utils.Fatalf("Failed to write ABI binding: %v", err)
+		}
+		return nil
+	}
+	fmt.Println(code)
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests that contract bindings, including the event filters, can be generated
+// from a contract ABI through the abigen command.
+func TestAbigenFromABI(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	abi := `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Deposit","type":"event"}]`
+	if err := ioutil.WriteFile(filepath.Join(dir, "bank.abi"), []byte(abi), 0600); err != nil {
+		t.Fatalf("failed to write contract ABI: %v", err)
+	}
+	out := filepath.Join(dir, "bank.go")
+
+	gexp := runGeth(t, "abigen", "--abi", filepath.Join(dir, "bank.abi"), "--pkg", "bank", "--type", "Bank", "--out", out)
+	gexp.expectExit()
+
+	code, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read generated binding: %v", err)
+	}
+	for _, want := range []string{
+		"package bank",
+		"func NewBankFilterer(address common.Address, filterer bind.ContractFilterer) (*BankFilterer, error)",
+		"func (_Bank *BankFilterer) FilterDeposit(opts *bind.FilterOpts, from []common.Address) (*BankDepositIterator, error)",
+		"func (_Bank *BankFilterer) WatchDeposit(opts *bind.WatchOpts, sink chan<- *BankDeposit, from []common.Address) (event.Subscription, error)",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("generated binding missing %q", want)
+		}
+	}
+}
@@ -29,7 +29,6 @@ import (
 	"github.com/expanse-org/go-expanse/accounts/keystore"
 	"github.com/expanse-org/go-expanse/cmd/utils"
 	"github.com/expanse-org/go-expanse/common"
-	"github.com/expanse-org/go-expanse/common/hexutil"
 	"github.com/expanse-org/go-expanse/console"
 	"github.com/expanse-org/go-expanse/contracts/release"
 	"github.com/expanse-org/go-expanse/eth"
@@ -37,9 +36,9 @@ import (
 	"github.com/expanse-org/go-expanse/internal/debug"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/metrics"
+	"github.com/expanse-org/go-expanse/miner"
 	"github.com/expanse-org/go-expanse/node"
 	"github.com/expanse-org/go-expanse/params"
-	"github.com/expanse-org/go-expanse/rlp"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -77,6 +76,8 @@ func init() {
 		consoleCommand,
 		attachCommand,
 		javascriptCommand,
+		// See abigencmd.go:
+		abigenCommand,
 		// See misccmd.go:
 		makedagCommand,
 		versionCommand,
@@ -97,13 +98,28 @@ func init() {
 		utils.EthashDatasetDirFlag,
 		utils.EthashDatasetsInMemoryFlag,
 		utils.EthashDatasetsOnDiskFlag,
+		utils.EthashCachesQuotaFlag,
+		utils.EthashDatasetsQuotaFlag,
 		utils.FastSyncFlag,
+		utils.ArchiveFlag,
+		utils.PruningFlag,
+		utils.PruningRetentionFlag,
+		utils.SnapshotSyncFlag,
+		utils.SnapshotIntervalFlag,
 		utils.LightModeFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
+		utils.RichListFlag,
+		utils.CreatorIndexFlag,
+		utils.TransferIndexFlag,
+		utils.LogIndexFlag,
 		utils.CacheFlag,
 		utils.TrieCacheGenFlag,
+		utils.MemoryAllowanceFlag,
+		utils.DatabaseBatchSizeFlag,
+		utils.DatabaseSyncIntervalFlag,
+		utils.DatabaseCompactionFlag,
 		utils.JSpathFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
@@ -116,13 +132,29 @@ func init() {
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.BootnodeModeFlag,
 		utils.NetrestrictFlag,
+		utils.NetdenyFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.RPCEnabledFlag,
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.RPCGlobalGasCapFlag,
+		utils.RPCGlobalTxFeeCapFlag,
+		utils.RPCCacheFlag,
+		utils.RPCCacheConfirmationsFlag,
+		utils.RPCSlowThresholdFlag,
+		utils.RPCMaxSubscriptionsFlag,
+		utils.RPCNotificationBufferFlag,
+		utils.RPCDropNotificationsFlag,
+		utils.RPCShedFlag,
+		utils.RPCShedRangeFlag,
+		utils.RPCShedMemoryFlag,
+		utils.AuditLogFlag,
+		utils.AuditLogMaxSizeFlag,
+		utils.AuditLogMaxFilesFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -131,6 +163,8 @@ func init() {
 		utils.IPCDisabledFlag,
 		utils.IPCApiFlag,
 		utils.IPCPathFlag,
+		utils.SystemdFlag,
+		utils.ReloadFileFlag,
 		utils.ExecFlag,
 		utils.PreloadJSFlag,
 		utils.WhisperEnabledFlag,
@@ -140,20 +174,39 @@ func init() {
 		utils.VMJitCacheFlag,
 		utils.VMEnableJitFlag,
 		utils.VMEnableDebugFlag,
+		utils.ShadowForkFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
+		utils.RPCVirtualHostsFlag,
+		utils.FaucetAddrFlag,
+		utils.FaucetAccountFlag,
+		utils.FaucetAmountFlag,
+		utils.FaucetPeriodFlag,
+		utils.FaucetReCaptchaSiteFlag,
+		utils.FaucetReCaptchaSecretFlag,
 		utils.EthStatsURLFlag,
 		utils.MetricsEnabledFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
 		utils.SolcPathFlag,
+		utils.GpoBlocksFlag,
+		utils.GpoPercentileFlag,
 		utils.GpoMinGasPriceFlag,
 		utils.GpoMaxGasPriceFlag,
-		utils.GpoFullBlockRatioFlag,
-		utils.GpobaseStepDownFlag,
-		utils.GpobaseStepUpFlag,
-		utils.GpobaseCorrectionFactorFlag,
 		utils.ExtraDataFlag,
+		utils.MinerTagFlag,
+		utils.MinerStrictParentFlag,
+		utils.MinerBannedBlocksFlag,
+		utils.MinerUnclesFlag,
+		utils.MinerUncleAgeFlag,
+		utils.MinerOwnUnclesFlag,
+		utils.TxQueuedLifetimeFlag,
+		utils.TxAccountLifetimeFlag,
+		utils.TxJournalFlag,
+		utils.TxAccountSlotsFlag,
+		utils.TxGlobalSlotsFlag,
+		utils.TxAccountQueueFlag,
+		utils.TxGlobalQueueFlag,
 	}
 	app.Flags = append(app.Flags, debug.Flags...)
 
@@ -201,21 +254,17 @@ func gexp(ctx *cli.Context) error {
 
 func makeFullNode(ctx *cli.Context) *node.Node {
 	// Create the default extradata and construct the base node
-	var clientInfo = struct {
-		Version   uint
-		Name      string
-		GoVersion string
-		Os        string
-	}{uint(params.VersionMajor<<16 | params.VersionMinor<<8 | params.VersionPatch), clientIdentifier, runtime.Version(), runtime.GOOS}
-	extra, err := rlp.EncodeToBytes(clientInfo)
-	if err != nil {
-		log.Warn("Failed to set canonical miner information", "err", err)
-	}
-	if uint64(len(extra)) > params.MaximumExtraDataSize {
-		log.Warn("Miner extra data exceed limit", "extra", hexutil.Bytes(extra), "limit", params.MaximumExtraDataSize)
-		extra = nil
+	info := miner.NewExtraInfo(clientIdentifier, runtime.Version(), runtime.GOOS, ctx.GlobalString(utils.MinerTagFlag.Name))
+	extra := miner.ComposeExtra(info)
+	if extra == nil {
+		log.Warn("Miner extra data exceed limit", "name", info.Name, "limit", params.MaximumExtraDataSize)
 	}
 	stack := utils.MakeNode(ctx, clientIdentifier, gitCommit)
+
+	// Bootnodes only serve peer discovery, without running any services
+	if ctx.GlobalBool(utils.BootnodeModeFlag.Name) {
+		return stack
+	}
 	utils.RegisterEthService(ctx, stack, extra)
 
 	// Whisper must be explicitly enabled, but is auto-enabled in --dev mode.
@@ -228,6 +277,10 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if url := ctx.GlobalString(utils.EthStatsURLFlag.Name); url != "" {
 		utils.RegisterEthStatsService(stack, url)
 	}
+	// Add the test network faucet if requested
+	if ctx.GlobalString(utils.FaucetAddrFlag.Name) != "" {
+		utils.RegisterFaucetService(ctx, stack)
+	}
 	// Add the release oracle service so it boots along with node.
 	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
 		config := release.Config{
@@ -71,10 +71,19 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.DevModeFlag,
 			utils.IdentityFlag,
 			utils.FastSyncFlag,
+			utils.ArchiveFlag,
+			utils.PruningFlag,
+			utils.PruningRetentionFlag,
+			utils.SnapshotSyncFlag,
+			utils.SnapshotIntervalFlag,
 			utils.LightModeFlag,
 			utils.LightServFlag,
 			utils.LightPeersFlag,
 			utils.LightKDFFlag,
+			utils.RichListFlag,
+			utils.CreatorIndexFlag,
+			utils.TransferIndexFlag,
+			utils.LogIndexFlag,
 		},
 	},
 	{
@@ -86,6 +95,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.EthashDatasetDirFlag,
 			utils.EthashDatasetsInMemoryFlag,
 			utils.EthashDatasetsOnDiskFlag,
+			utils.EthashCachesQuotaFlag,
+			utils.EthashDatasetsQuotaFlag,
 		},
 	},
 	{
@@ -93,6 +104,10 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.CacheFlag,
 			utils.TrieCacheGenFlag,
+			utils.MemoryAllowanceFlag,
+			utils.DatabaseBatchSizeFlag,
+			utils.DatabaseSyncIntervalFlag,
+			utils.DatabaseCompactionFlag,
 		},
 	},
 	{
@@ -109,6 +124,20 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
+			utils.RPCGlobalGasCapFlag,
+			utils.RPCGlobalTxFeeCapFlag,
+			utils.RPCCacheFlag,
+			utils.RPCCacheConfirmationsFlag,
+			utils.RPCSlowThresholdFlag,
+			utils.RPCMaxSubscriptionsFlag,
+			utils.RPCNotificationBufferFlag,
+			utils.RPCDropNotificationsFlag,
+			utils.RPCShedFlag,
+			utils.RPCShedRangeFlag,
+			utils.RPCShedMemoryFlag,
+			utils.AuditLogFlag,
+			utils.AuditLogMaxSizeFlag,
+			utils.AuditLogMaxFilesFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
@@ -117,7 +146,10 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IPCDisabledFlag,
 			utils.IPCApiFlag,
 			utils.IPCPathFlag,
+			utils.SystemdFlag,
+			utils.ReloadFileFlag,
 			utils.RPCCORSDomainFlag,
+			utils.RPCVirtualHostsFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -133,10 +165,23 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.BootnodeModeFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
 		},
 	},
+	{
+		Name: "TRANSACTION POOL",
+		Flags: []cli.Flag{
+			utils.TxQueuedLifetimeFlag,
+			utils.TxAccountLifetimeFlag,
+			utils.TxJournalFlag,
+			utils.TxAccountSlotsFlag,
+			utils.TxGlobalSlotsFlag,
+			utils.TxAccountQueueFlag,
+			utils.TxGlobalQueueFlag,
+		},
+	},
 	{
 		Name: "MINER",
 		Flags: []cli.Flag{
@@ -146,17 +191,32 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.MinerTagFlag,
+			utils.MinerStrictParentFlag,
+			utils.MinerBannedBlocksFlag,
+			utils.MinerUnclesFlag,
+			utils.MinerUncleAgeFlag,
+			utils.MinerOwnUnclesFlag,
 		},
 	},
 	{
 		Name: "GAS PRICE ORACLE",
 		Flags: []cli.Flag{
+			utils.GpoBlocksFlag,
+			utils.GpoPercentileFlag,
 			utils.GpoMinGasPriceFlag,
 			utils.GpoMaxGasPriceFlag,
-			utils.GpoFullBlockRatioFlag,
-			utils.GpobaseStepDownFlag,
-			utils.GpobaseStepUpFlag,
-			utils.GpobaseCorrectionFactorFlag,
+		},
+	},
+	{
+		Name: "FAUCET",
+		Flags: []cli.Flag{
+			utils.FaucetAddrFlag,
+			utils.FaucetAccountFlag,
+			utils.FaucetAmountFlag,
+			utils.FaucetPeriodFlag,
+			utils.FaucetReCaptchaSiteFlag,
+			utils.FaucetReCaptchaSecretFlag,
 		},
 	},
 	{
@@ -166,6 +226,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.VMForceJitFlag,
 			utils.VMJitCacheFlag,
 			utils.VMEnableDebugFlag,
+			utils.ShadowForkFlag,
 		},
 	},
 	{
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -28,10 +29,12 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/expanse-org/go-expanse/accounts"
 	"github.com/expanse-org/go-expanse/accounts/keystore"
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
 	"github.com/expanse-org/go-expanse/core"
 	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/vm"
@@ -40,9 +43,11 @@ import (
 	"github.com/expanse-org/go-expanse/ethdb"
 	"github.com/expanse-org/go-expanse/ethstats"
 	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/faucet"
 	"github.com/expanse-org/go-expanse/les"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/metrics"
+	"github.com/expanse-org/go-expanse/miner"
 	"github.com/expanse-org/go-expanse/node"
 	"github.com/expanse-org/go-expanse/p2p/discover"
 	"github.com/expanse-org/go-expanse/p2p/discv5"
@@ -142,6 +147,14 @@ var (
 		Usage: "Number of recent ethash mining DAGs to keep on disk (1+GB each)",
 		Value: 2,
 	}
+	EthashCachesQuotaFlag = cli.IntFlag{
+		Name:  "ethash.cachequota",
+		Usage: "Megabytes of disk space the ethash caches may use, pruning the oldest above (0 = unlimited)",
+	}
+	EthashDatasetsQuotaFlag = cli.IntFlag{
+		Name:  "ethash.dagquota",
+		Usage: "Megabytes of disk space the ethash mining DAGs may use, pruning the oldest above (0 = unlimited)",
+	}
 	NetworkIdFlag = cli.IntFlag{
 		Name:  "networkid",
 		Usage: "Network identifier (integer, 1=Frontier, 2=Morden (disused), 3=Ropsten)",
@@ -168,6 +181,27 @@ var (
 		Name:  "fast",
 		Usage: "Enable fast syncing through state downloads",
 	}
+	ArchiveFlag = cli.BoolFlag{
+		Name:  "archive",
+		Usage: "Retain every historical state, disabling fast sync",
+	}
+	PruningFlag = cli.BoolFlag{
+		Name:  "pruning",
+		Usage: "Garbage collect the historical states, keeping only the most recent ones",
+	}
+	PruningRetentionFlag = cli.Uint64Flag{
+		Name:  "pruning.retention",
+		Usage: "Number of most recent blocks whose state is kept when pruning",
+		Value: core.DefaultPruneConfig.Retention,
+	}
+	SnapshotSyncFlag = cli.BoolFlag{
+		Name:  "snapshot",
+		Usage: "Enable fast syncing from a recent state snapshot served by peers (implies --fast)",
+	}
+	SnapshotIntervalFlag = cli.Uint64Flag{
+		Name:  "snapshot.interval",
+		Usage: "Number of blocks between two state snapshots served to peers (0 = disabled)",
+	}
 	LightModeFlag = cli.BoolFlag{
 		Name:  "light",
 		Usage: "Enable light client mode",
@@ -186,6 +220,23 @@ var (
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
+	RichListFlag = cli.IntFlag{
+		Name:  "richlist",
+		Usage: "Number of richest accounts to index from the state (0 = disabled)",
+		Value: 0,
+	}
+	CreatorIndexFlag = cli.BoolFlag{
+		Name:  "creatorindex",
+		Usage: "Index the transaction and block creating every contract, including contract created ones",
+	}
+	TransferIndexFlag = cli.BoolFlag{
+		Name:  "transferindex",
+		Usage: "Index the internal value transfers of contracts in the blocks imported from now on",
+	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "logindex",
+		Usage: "Index the addresses and topics of all logs for fast log filtering over wide block ranges",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -197,6 +248,25 @@ var (
 		Usage: "Number of trie node generations to keep in memory",
 		Value: int(state.MaxTrieCacheGen),
 	}
+	MemoryAllowanceFlag = cli.IntFlag{
+		Name:  "memory-allowance",
+		Usage: "Megabytes of heap above which caches are shrunk (0 = unlimited)",
+		Value: 0,
+	}
+	DatabaseBatchSizeFlag = cli.IntFlag{
+		Name:  "db-batch-size",
+		Usage: "Kilobytes of chain data to batch per database write during sync",
+		Value: ethdb.IdealBatchSize / 1024,
+	}
+	DatabaseSyncIntervalFlag = cli.IntFlag{
+		Name:  "db-sync-interval",
+		Usage: "Number of batch writes between disk syncs during sync (0 = sync only near the chain head)",
+		Value: 0,
+	}
+	DatabaseCompactionFlag = cli.BoolFlag{
+		Name:  "db-compaction",
+		Usage: "Compact the chain database in the background while the node is idle",
+	}
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
 		Name:  "mine",
@@ -226,6 +296,67 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerTagFlag = cli.StringFlag{
+		Name:  "minertag",
+		Usage: "Operator tag added to the default block extra data, truncated to fit",
+	}
+	MinerStrictParentFlag = cli.DurationFlag{
+		Name:  "minerstrictparent",
+		Usage: "Only mine on blocks this node fully validated itself within the given time (0 = any block)",
+	}
+	MinerBannedBlocksFlag = cli.StringFlag{
+		Name:  "minerbannedblocks",
+		Usage: "Comma separated list of block hashes whose descendants are never mined on",
+	}
+	MinerUnclesFlag = cli.IntFlag{
+		Name:  "mineruncles",
+		Usage: "Maximum number of uncles included in mined blocks (0-2)",
+		Value: miner.DefaultUnclePolicy.MaxUncles,
+	}
+	MinerUncleAgeFlag = cli.Uint64Flag{
+		Name:  "mineruncleage",
+		Usage: "Maximum number of blocks an included uncle may be behind the mined block (1-7)",
+		Value: miner.DefaultUnclePolicy.MaxAge,
+	}
+	MinerOwnUnclesFlag = cli.BoolFlag{
+		Name:  "minerownuncles",
+		Usage: "Include uncles mined by the own etherbases before others",
+	}
+	// Transaction pool settings
+	TxQueuedLifetimeFlag = cli.DurationFlag{
+		Name:  "txqueuedlifetime",
+		Usage: "Maximum time a transaction is queued behind a nonce gap (0 = until its account goes idle)",
+	}
+	TxAccountLifetimeFlag = cli.DurationFlag{
+		Name:  "txaccountlifetime",
+		Usage: "Maximum time the queued transactions of an account without activity are kept",
+		Value: 3 * time.Hour,
+	}
+	TxJournalFlag = cli.StringFlag{
+		Name:  "txjournal",
+		Usage: "Disk journal of local transactions to survive node restarts (empty = disabled)",
+		Value: "transactions.rlp",
+	}
+	TxAccountSlotsFlag = cli.Uint64Flag{
+		Name:  "txaccountslots",
+		Usage: "Minimum number of executable transaction slots guaranteed per account",
+		Value: core.DefaultTxPoolConfig.AccountSlots,
+	}
+	TxGlobalSlotsFlag = cli.Uint64Flag{
+		Name:  "txglobalslots",
+		Usage: "Maximum number of executable transaction slots for all accounts",
+		Value: core.DefaultTxPoolConfig.GlobalSlots,
+	}
+	TxAccountQueueFlag = cli.Uint64Flag{
+		Name:  "txaccountqueue",
+		Usage: "Maximum number of non-executable transaction slots permitted per account",
+		Value: core.DefaultTxPoolConfig.AccountQueue,
+	}
+	TxGlobalQueueFlag = cli.Uint64Flag{
+		Name:  "txglobalqueue",
+		Usage: "Maximum number of non-executable transaction slots for all accounts",
+		Value: core.DefaultTxPoolConfig.GlobalQueue,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -255,6 +386,37 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	ShadowForkFlag = cli.StringFlag{
+		Name:  "shadowfork",
+		Usage: "JSON chain config to replay the canonical transactions under on a shadow fork of the head state",
+	}
+	// Faucet settings
+	FaucetAddrFlag = cli.StringFlag{
+		Name:  "faucet",
+		Usage: "Listening address of a test network faucet web form (e.g. localhost:8080)",
+	}
+	FaucetAccountFlag = cli.StringFlag{
+		Name:  "faucet.account",
+		Usage: "Account funding the faucet payouts, unlocked with --unlock (address or keystore index)",
+	}
+	FaucetAmountFlag = cli.Uint64Flag{
+		Name:  "faucet.amount",
+		Usage: "Ether paid out per faucet request",
+		Value: 1,
+	}
+	FaucetPeriodFlag = cli.DurationFlag{
+		Name:  "faucet.period",
+		Usage: "Minimum time between faucet payouts to the same address or IP",
+		Value: 24 * time.Hour,
+	}
+	FaucetReCaptchaSiteFlag = cli.StringFlag{
+		Name:  "faucet.recaptcha.site",
+		Usage: "reCAPTCHA site key rendering the captcha of the faucet form",
+	}
+	FaucetReCaptchaSecretFlag = cli.StringFlag{
+		Name:  "faucet.recaptcha.secret",
+		Usage: "reCAPTCHA secret key verifying the faucet requests (captcha disabled if empty)",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -292,11 +454,78 @@ var (
 		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
 		Value: "",
 	}
+	RPCVirtualHostsFlag = cli.StringFlag{
+		Name:  "rpcvhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
+		Value: "localhost",
+	}
 	RPCApiFlag = cli.StringFlag{
 		Name:  "rpcapi",
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: rpc.DefaultHTTPApis,
 	}
+	RPCGlobalGasCapFlag = cli.Uint64Flag{
+		Name:  "rpc.gascap",
+		Usage: "Gas allowance of eth_call and eth_estimateGas requests (0 = unlimited)",
+		Value: 50000000,
+	}
+	RPCGlobalTxFeeCapFlag = cli.Float64Flag{
+		Name:  "rpc.txfeecap",
+		Usage: "Highest fee in ether of transactions sent through the RPC APIs (0 = unlimited)",
+		Value: 1,
+	}
+	RPCCacheFlag = cli.IntFlag{
+		Name:  "rpc.cache",
+		Usage: "Number of immutable block, receipt and trace responses cached per kind (0 = disabled)",
+		Value: 0,
+	}
+	RPCCacheConfirmationsFlag = cli.Uint64Flag{
+		Name:  "rpc.cacheconfirmations",
+		Usage: "Number of blocks a block must be buried under for its responses to be cached",
+		Value: 64,
+	}
+	RPCSlowThresholdFlag = cli.DurationFlag{
+		Name:  "rpc.slowthreshold",
+		Usage: "Execution time above which RPC calls are logged as slow (0 = disabled)",
+	}
+	RPCMaxSubscriptionsFlag = cli.IntFlag{
+		Name:  "rpc.maxsubscriptions",
+		Usage: "Maximum number of concurrent subscriptions per IPC or websocket connection (0 = unlimited)",
+	}
+	RPCNotificationBufferFlag = cli.IntFlag{
+		Name:  "rpc.notificationbuffer",
+		Usage: "Maximum number of notifications queued per connection not reading them (0 = default)",
+	}
+	RPCDropNotificationsFlag = cli.BoolFlag{
+		Name:  "rpc.dropnotifications",
+		Usage: "Drop the oldest queued notifications of slow connections instead of disconnecting them",
+	}
+	RPCShedFlag = cli.StringFlag{
+		Name:  "rpc.shed",
+		Usage: "Comma separated method classes to reject while syncing or under memory/disk pressure (logs, trace)",
+	}
+	RPCShedRangeFlag = cli.Uint64Flag{
+		Name:  "rpc.shedrange",
+		Usage: "Number of blocks a log query needs to span to be rejected by load shedding",
+		Value: node.DefaultLoadSheddingClasses["logs"].MinRange,
+	}
+	RPCShedMemoryFlag = cli.IntFlag{
+		Name:  "rpc.shedmemory",
+		Usage: "Heap size in megabytes above which load shedding considers memory under pressure (0 = unlimited)",
+	}
+	AuditLogFlag = cli.StringFlag{
+		Name:  "auditlog",
+		Usage: "File to record privileged RPC calls (admin, miner, personal, debug setters) into (relative to the data directory)",
+	}
+	AuditLogMaxSizeFlag = cli.IntFlag{
+		Name:  "auditlog.maxsize",
+		Usage: "Size in megabytes above which the audit log is rotated",
+		Value: node.DefaultAuditLogMaxSize / 1024 / 1024,
+	}
+	AuditLogMaxFilesFlag = cli.IntFlag{
+		Name:  "auditlog.maxfiles",
+		Usage: "Number of rotated audit log files to retain (0 = all)",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -311,6 +540,14 @@ var (
 		Usage: "Filename for IPC socket/pipe within the datadir (explicit paths escape it)",
 		Value: DirectoryString{"gexp.ipc"},
 	}
+	SystemdFlag = cli.BoolFlag{
+		Name:  "systemd",
+		Usage: "Use systemd activated sockets for the RPC endpoints and notify systemd once ready",
+	}
+	ReloadFileFlag = cli.StringFlag{
+		Name:  "reloadfile",
+		Usage: "JSON file with the settings (verbosity, vmodule, netrestrict, netdeny, gasprice) to apply upon SIGHUP",
+	}
 	WSEnabledFlag = cli.BoolFlag{
 		Name:  "ws",
 		Usage: "Enable the WS-RPC server",
@@ -386,10 +623,18 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	BootnodeModeFlag = cli.BoolFlag{
+		Name:  "bootnode",
+		Usage: "Run only the peer discovery protocols as a network bootstrap node (no chain database)",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	NetdenyFlag = cli.StringFlag{
+		Name:  "netdeny",
+		Usage: "Denies network communication with the given IP networks (CIDR masks)",
+	}
 
 	WhisperEnabledFlag = cli.BoolFlag{
 		Name:  "shh",
@@ -419,25 +664,15 @@ var (
 		Usage: "Maximum suggested gas price",
 		Value: big.NewInt(500 * params.Shannon),
 	}
-	GpoFullBlockRatioFlag = cli.IntFlag{
-		Name:  "gpofull",
-		Usage: "Full block threshold for gas price calculation (%)",
-		Value: 80,
-	}
-	GpobaseStepDownFlag = cli.IntFlag{
-		Name:  "gpobasedown",
-		Usage: "Suggested gas price base step down ratio (1/1000)",
+	GpoBlocksFlag = cli.IntFlag{
+		Name:  "gpoblocks",
+		Usage: "Number of recent blocks to check for gas prices",
 		Value: 10,
 	}
-	GpobaseStepUpFlag = cli.IntFlag{
-		Name:  "gpobaseup",
-		Usage: "Suggested gas price base step up ratio (1/1000)",
-		Value: 100,
-	}
-	GpobaseCorrectionFactorFlag = cli.IntFlag{
-		Name:  "gpobasecf",
-		Usage: "Suggested gas price base correction factor (%)",
-		Value: 110,
+	GpoPercentileFlag = cli.IntFlag{
+		Name:  "gpopercentile",
+		Usage: "Suggested gas price is the given percentile of a set of recent transaction gas prices",
+		Value: 50,
 	}
 )
 
@@ -608,6 +843,30 @@ func MakeRPCModules(input string) []string {
 	return result
 }
 
+// MakeLoadShedding creates the RPC load shedding policy from the set command line
+// flags, returning nil if no method classes are to be shed.
+func MakeLoadShedding(ctx *cli.Context) *node.LoadSheddingConfig {
+	names := ctx.GlobalString(RPCShedFlag.Name)
+	if names == "" {
+		return nil
+	}
+	config := &node.LoadSheddingConfig{
+		Classes:     make(map[string]node.LoadSheddingClass),
+		MemoryLimit: uint64(ctx.GlobalInt(RPCShedMemoryFlag.Name)) * 1024 * 1024,
+	}
+	for _, name := range MakeRPCModules(names) {
+		class, ok := node.DefaultLoadSheddingClasses[name]
+		if !ok {
+			Fatalf("Unknown load shedding class: %s", name)
+		}
+		if class.MinRange > 0 {
+			class.MinRange = ctx.GlobalUint64(RPCShedRangeFlag.Name)
+		}
+		config.Classes[name] = class
+	}
+	return config
+}
+
 // MakeHTTPRpcHost creates the HTTP RPC listener interface string from the set
 // command line flags, returning empty if the HTTP endpoint is disabled.
 func MakeHTTPRpcHost(ctx *cli.Context) string {
@@ -681,6 +940,56 @@ func MakeEtherbase(ks *keystore.KeyStore, ctx *cli.Context) common.Address {
 	return account.Address
 }
 
+// MakeMinerBannedBlocks parses the block hashes the miner must not extend from
+// the command line flags.
+func MakeMinerBannedBlocks(ctx *cli.Context) []common.Hash {
+	var hashes []common.Hash
+	for _, entry := range strings.Split(ctx.GlobalString(MinerBannedBlocksFlag.Name), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		hash, err := hexutil.Decode(entry)
+		if err != nil || len(hash) != common.HashLength {
+			Fatalf("Option %q: invalid block hash %q", MinerBannedBlocksFlag.Name, entry)
+		}
+		hashes = append(hashes, common.BytesToHash(hash))
+	}
+	return hashes
+}
+
+// MakeMinerUnclePolicy creates the rules the miner picks uncles by from the
+// command line flags.
+func MakeMinerUnclePolicy(ctx *cli.Context) *miner.UnclePolicy {
+	policy := &miner.UnclePolicy{
+		MaxUncles: ctx.GlobalInt(MinerUnclesFlag.Name),
+		MaxAge:    ctx.GlobalUint64(MinerUncleAgeFlag.Name),
+		PreferOwn: ctx.GlobalBool(MinerOwnUnclesFlag.Name),
+	}
+	if err := policy.Validate(); err != nil {
+		Fatalf("Invalid uncle policy: %v", err)
+	}
+	return policy
+}
+
+// MakeShadowFork loads the chain config a shadow fork replays the canonical
+// transactions under, or nil if shadow replay is disabled.
+func MakeShadowFork(ctx *cli.Context) *params.ChainConfig {
+	path := ctx.GlobalString(ShadowForkFlag.Name)
+	if path == "" {
+		return nil
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		Fatalf("Option %q: %v", ShadowForkFlag.Name, err)
+	}
+	config := new(params.ChainConfig)
+	if err := json.Unmarshal(blob, config); err != nil {
+		Fatalf("Option %q: invalid chain config: %v", ShadowForkFlag.Name, err)
+	}
+	return config
+}
+
 // MakeMinerExtra resolves extradata for the miner from the set command line flags
 // or returns a default one composed on the client, runtime and OS metadata.
 func MakeMinerExtra(extra []byte, ctx *cli.Context) []byte {
@@ -720,31 +1029,42 @@ func MakeNode(ctx *cli.Context, name, gitCommit string) *node.Node {
 	forceV5Discovery := (ctx.GlobalBool(LightModeFlag.Name) || ctx.GlobalInt(LightServFlag.Name) > 0) && !ctx.GlobalBool(NoDiscoverFlag.Name)
 
 	config := &node.Config{
-		DataDir:           MakeDataDir(ctx),
-		KeyStoreDir:       ctx.GlobalString(KeyStoreDirFlag.Name),
-		UseLightweightKDF: ctx.GlobalBool(LightKDFFlag.Name),
-		PrivateKey:        MakeNodeKey(ctx),
-		Name:              name,
-		Version:           vsn,
-		UserIdent:         makeNodeUserIdent(ctx),
-		NoDiscovery:       ctx.GlobalBool(NoDiscoverFlag.Name) || ctx.GlobalBool(LightModeFlag.Name), // always disable v4 discovery in light client mode
-		DiscoveryV5:       ctx.GlobalBool(DiscoveryV5Flag.Name) || forceV5Discovery,
-		DiscoveryV5Addr:   MakeDiscoveryV5Address(ctx),
-		BootstrapNodes:    MakeBootstrapNodes(ctx),
-		BootstrapNodesV5:  MakeBootstrapNodesV5(ctx),
-		ListenAddr:        MakeListenAddress(ctx),
-		NAT:               MakeNAT(ctx),
-		MaxPeers:          ctx.GlobalInt(MaxPeersFlag.Name),
-		MaxPendingPeers:   ctx.GlobalInt(MaxPendingPeersFlag.Name),
-		IPCPath:           MakeIPCPath(ctx),
-		HTTPHost:          MakeHTTPRpcHost(ctx),
-		HTTPPort:          ctx.GlobalInt(RPCPortFlag.Name),
-		HTTPCors:          ctx.GlobalString(RPCCORSDomainFlag.Name),
-		HTTPModules:       MakeRPCModules(ctx.GlobalString(RPCApiFlag.Name)),
-		WSHost:            MakeWSRpcHost(ctx),
-		WSPort:            ctx.GlobalInt(WSPortFlag.Name),
-		WSOrigins:         ctx.GlobalString(WSAllowedOriginsFlag.Name),
-		WSModules:         MakeRPCModules(ctx.GlobalString(WSApiFlag.Name)),
+		DataDir:               MakeDataDir(ctx),
+		KeyStoreDir:           ctx.GlobalString(KeyStoreDirFlag.Name),
+		UseLightweightKDF:     ctx.GlobalBool(LightKDFFlag.Name),
+		PrivateKey:            MakeNodeKey(ctx),
+		Name:                  name,
+		Version:               vsn,
+		UserIdent:             makeNodeUserIdent(ctx),
+		NoDiscovery:           ctx.GlobalBool(NoDiscoverFlag.Name) || ctx.GlobalBool(LightModeFlag.Name), // always disable v4 discovery in light client mode
+		DiscoveryV5:           ctx.GlobalBool(DiscoveryV5Flag.Name) || forceV5Discovery,
+		DiscoveryV5Addr:       MakeDiscoveryV5Address(ctx),
+		BootstrapNodes:        MakeBootstrapNodes(ctx),
+		BootstrapNodesV5:      MakeBootstrapNodesV5(ctx),
+		ListenAddr:            MakeListenAddress(ctx),
+		NAT:                   MakeNAT(ctx),
+		MaxPeers:              ctx.GlobalInt(MaxPeersFlag.Name),
+		MaxPendingPeers:       ctx.GlobalInt(MaxPendingPeersFlag.Name),
+		IPCPath:               MakeIPCPath(ctx),
+		HTTPHost:              MakeHTTPRpcHost(ctx),
+		HTTPPort:              ctx.GlobalInt(RPCPortFlag.Name),
+		HTTPCors:              ctx.GlobalString(RPCCORSDomainFlag.Name),
+		HTTPVirtualHosts:      MakeRPCModules(ctx.GlobalString(RPCVirtualHostsFlag.Name)),
+		HTTPModules:           MakeRPCModules(ctx.GlobalString(RPCApiFlag.Name)),
+		WSHost:                MakeWSRpcHost(ctx),
+		WSPort:                ctx.GlobalInt(WSPortFlag.Name),
+		WSOrigins:             ctx.GlobalString(WSAllowedOriginsFlag.Name),
+		WSModules:             MakeRPCModules(ctx.GlobalString(WSApiFlag.Name)),
+		RPCSlowThreshold:      ctx.GlobalDuration(RPCSlowThresholdFlag.Name),
+		RPCMaxSubscriptions:   ctx.GlobalInt(RPCMaxSubscriptionsFlag.Name),
+		RPCNotificationBuffer: ctx.GlobalInt(RPCNotificationBufferFlag.Name),
+		RPCDropNotifications:  ctx.GlobalBool(RPCDropNotificationsFlag.Name),
+		RPCLoadShedding:       MakeLoadShedding(ctx),
+		AuditLog:              ctx.GlobalString(AuditLogFlag.Name),
+		AuditLogMaxSize:       uint64(ctx.GlobalInt(AuditLogMaxSizeFlag.Name)) * 1024 * 1024,
+		AuditLogMaxFiles:      ctx.GlobalInt(AuditLogMaxFilesFlag.Name),
+		Systemd:               ctx.GlobalBool(SystemdFlag.Name),
+		ReloadFile:            ctx.GlobalString(ReloadFileFlag.Name),
 	}
 	if ctx.GlobalBool(DevModeFlag.Name) {
 		if !ctx.GlobalIsSet(DataDirFlag.Name) {
@@ -754,6 +1074,12 @@ func MakeNode(ctx *cli.Context, name, gitCommit string) *node.Node {
 		config.MaxPeers = 0
 		config.ListenAddr = ":0"
 	}
+	if ctx.GlobalBool(BootnodeModeFlag.Name) {
+		if ctx.GlobalBool(NoDiscoverFlag.Name) && !ctx.GlobalBool(DiscoveryV5Flag.Name) {
+			Fatalf("Option %q requires peer discovery", BootnodeModeFlag.Name)
+		}
+		config.BootnodeMode = true
+	}
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
 		if err != nil {
@@ -761,6 +1087,13 @@ func MakeNode(ctx *cli.Context, name, gitCommit string) *node.Node {
 		}
 		config.NetRestrict = list
 	}
+	if netdeny := ctx.GlobalString(NetdenyFlag.Name); netdeny != "" {
+		list, err := netutil.ParseNetlist(netdeny)
+		if err != nil {
+			Fatalf("Option %q: %v", NetdenyFlag.Name, err)
+		}
+		config.NetDeny = list
+	}
 
 	stack, err := node.New(config)
 	if err != nil {
@@ -782,28 +1115,49 @@ func RegisterEthService(ctx *cli.Context, stack *node.Node, extra []byte) {
 	if networks > 1 {
 		Fatalf("The %v flags are mutually exclusive", netFlags)
 	}
+	// Archive nodes need every state, which fast and light syncing skip and pruning deletes
+	if ctx.GlobalBool(ArchiveFlag.Name) {
+		for _, flag := range []cli.BoolFlag{FastSyncFlag, SnapshotSyncFlag, LightModeFlag, PruningFlag} {
+			if ctx.GlobalBool(flag.Name) {
+				Fatalf("Options %q and %q are mutually exclusive", ArchiveFlag.Name, flag.Name)
+			}
+		}
+	}
 	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
 
 	ethConf := &eth.Config{
 		Etherbase:               MakeEtherbase(ks, ctx),
-		FastSync:                ctx.GlobalBool(FastSyncFlag.Name),
+		FastSync:                ctx.GlobalBool(FastSyncFlag.Name) || ctx.GlobalBool(SnapshotSyncFlag.Name),
+		Archive:                 ctx.GlobalBool(ArchiveFlag.Name),
+		Pruning:                 ctx.GlobalBool(PruningFlag.Name),
+		PruningRetention:        ctx.GlobalUint64(PruningRetentionFlag.Name),
+		SnapshotSync:            ctx.GlobalBool(SnapshotSyncFlag.Name),
+		SnapshotInterval:        ctx.GlobalUint64(SnapshotIntervalFlag.Name),
 		LightMode:               ctx.GlobalBool(LightModeFlag.Name),
 		LightServ:               ctx.GlobalInt(LightServFlag.Name),
 		LightPeers:              ctx.GlobalInt(LightPeersFlag.Name),
 		MaxPeers:                ctx.GlobalInt(MaxPeersFlag.Name),
 		DatabaseCache:           ctx.GlobalInt(CacheFlag.Name),
+		MemoryAllowance:         ctx.GlobalInt(MemoryAllowanceFlag.Name),
+		DatabaseBatchSize:       ctx.GlobalInt(DatabaseBatchSizeFlag.Name),
+		DatabaseSyncInterval:    ctx.GlobalInt(DatabaseSyncIntervalFlag.Name),
+		DatabaseCompaction:      ctx.GlobalBool(DatabaseCompactionFlag.Name),
 		DatabaseHandles:         MakeDatabaseHandles(),
 		NetworkId:               ctx.GlobalInt(NetworkIdFlag.Name),
 		MinerThreads:            ctx.GlobalInt(MinerThreadsFlag.Name),
 		ExtraData:               MakeMinerExtra(extra, ctx),
+		MinerStrictParent:       ctx.GlobalDuration(MinerStrictParentFlag.Name),
+		MinerBannedBlocks:       MakeMinerBannedBlocks(ctx),
+		MinerUnclePolicy:        MakeMinerUnclePolicy(ctx),
+		TxQueuedLifetime:        ctx.GlobalDuration(TxQueuedLifetimeFlag.Name),
+		TxAccountLifetime:       ctx.GlobalDuration(TxAccountLifetimeFlag.Name),
+		TxJournal:               ctx.GlobalString(TxJournalFlag.Name),
 		DocRoot:                 ctx.GlobalString(DocRootFlag.Name),
 		GasPrice:                GlobalBig(ctx, GasPriceFlag.Name),
 		GpoMinGasPrice:          GlobalBig(ctx, GpoMinGasPriceFlag.Name),
 		GpoMaxGasPrice:          GlobalBig(ctx, GpoMaxGasPriceFlag.Name),
-		GpoFullBlockRatio:       ctx.GlobalInt(GpoFullBlockRatioFlag.Name),
-		GpobaseStepDown:         ctx.GlobalInt(GpobaseStepDownFlag.Name),
-		GpobaseStepUp:           ctx.GlobalInt(GpobaseStepUpFlag.Name),
-		GpobaseCorrectionFactor: ctx.GlobalInt(GpobaseCorrectionFactorFlag.Name),
+		GpoBlocks:               ctx.GlobalInt(GpoBlocksFlag.Name),
+		GpoPercentile:           ctx.GlobalInt(GpoPercentileFlag.Name),
 		SolcPath:                ctx.GlobalString(SolcPathFlag.Name),
 		EthashCacheDir:          MakeEthashCacheDir(ctx),
 		EthashCachesInMem:       ctx.GlobalInt(EthashCachesInMemoryFlag.Name),
@@ -811,7 +1165,26 @@ func RegisterEthService(ctx *cli.Context, stack *node.Node, extra []byte) {
 		EthashDatasetDir:        MakeEthashDatasetDir(ctx),
 		EthashDatasetsInMem:     ctx.GlobalInt(EthashDatasetsInMemoryFlag.Name),
 		EthashDatasetsOnDisk:    ctx.GlobalInt(EthashDatasetsOnDiskFlag.Name),
+		EthashCachesQuota:       ctx.GlobalInt(EthashCachesQuotaFlag.Name),
+		EthashDatasetsQuota:     ctx.GlobalInt(EthashDatasetsQuotaFlag.Name),
 		EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name),
+		ShadowFork:              MakeShadowFork(ctx),
+		RichListSize:            ctx.GlobalInt(RichListFlag.Name),
+		CreatorIndex:            ctx.GlobalBool(CreatorIndexFlag.Name),
+		TransferIndex:           ctx.GlobalBool(TransferIndexFlag.Name),
+		LogIndex:                ctx.GlobalBool(LogIndexFlag.Name),
+		RPCTxFeeCap:             ctx.GlobalFloat64(RPCGlobalTxFeeCapFlag.Name),
+		RPCCacheSize:            ctx.GlobalInt(RPCCacheFlag.Name),
+		RPCCacheConfirmations:   ctx.GlobalUint64(RPCCacheConfirmationsFlag.Name),
+	}
+	if gasCap := ctx.GlobalUint64(RPCGlobalGasCapFlag.Name); gasCap != 0 {
+		ethConf.RPCGasCap = new(big.Int).SetUint64(gasCap)
+	}
+	ethConf.TxPool = core.TxPoolConfig{
+		AccountSlots: ctx.GlobalUint64(TxAccountSlotsFlag.Name),
+		GlobalSlots:  ctx.GlobalUint64(TxGlobalSlotsFlag.Name),
+		AccountQueue: ctx.GlobalUint64(TxAccountQueueFlag.Name),
+		GlobalQueue:  ctx.GlobalUint64(TxGlobalQueueFlag.Name),
 	}
 
 	// Override any default configs in dev mode or the test net
@@ -877,17 +1250,51 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterFaucetService configures the test network faucet and adds it to the
+// given node.
+func RegisterFaucetService(ctx *cli.Context, stack *node.Node) {
+	// Refuse to give away ether on the main network
+	testnet := ctx.GlobalBool(TestNetFlag.Name) || ctx.GlobalBool(DevModeFlag.Name)
+	if !testnet && ctx.GlobalInt(NetworkIdFlag.Name) == eth.NetworkId {
+		Fatalf("The faucet is only available on test networks")
+	}
+	if ctx.GlobalBool(LightModeFlag.Name) {
+		Fatalf("The faucet requires a full node")
+	}
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	account, err := MakeAddress(ks, ctx.GlobalString(FaucetAccountFlag.Name))
+	if err != nil {
+		Fatalf("Option %q: %v", FaucetAccountFlag.Name, err)
+	}
+	config := faucet.Config{
+		Addr:    ctx.GlobalString(FaucetAddrFlag.Name),
+		Account: account,
+		Amount:  new(big.Int).Mul(new(big.Int).SetUint64(ctx.GlobalUint64(FaucetAmountFlag.Name)), big.NewInt(params.Ether)),
+		Period:  ctx.GlobalDuration(FaucetPeriodFlag.Name),
+	}
+	if secret := ctx.GlobalString(FaucetReCaptchaSecretFlag.Name); secret != "" {
+		config.Captcha = &faucet.ReCaptcha{Site: ctx.GlobalString(FaucetReCaptchaSiteFlag.Name), Secret: secret}
+	}
+	if err := stack.Register(func(sctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := sctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return faucet.New(config, ethServ)
+	}); err != nil {
+		Fatalf("Failed to register the faucet service: %v", err)
+	}
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetwork(ctx *cli.Context) {
 	params.TargetGasLimit = new(big.Int).SetUint64(ctx.GlobalUint64(TargetGasLimitFlag.Name))
 }
 
-func ChainDbName(ctx *cli.Context) string {
-	if ctx.GlobalBool(LightModeFlag.Name) {
-		return "lightchaindata"
-	} else {
-		return "chaindata"
-	}
+// ChainDbName returns the name of the chain database to open in the configured
+// sync mode, reusing the database of the other mode if needed.
+func ChainDbName(ctx *cli.Context, stack *node.Node) string {
+	return eth.ChainDbName(stack.ResolvePath, ctx.GlobalBool(LightModeFlag.Name))
 }
 
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
@@ -895,7 +1302,7 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	var (
 		cache   = ctx.GlobalInt(CacheFlag.Name)
 		handles = MakeDatabaseHandles()
-		name    = ChainDbName(ctx)
+		name    = ChainDbName(ctx, stack)
 	)
 
 	chainDb, err := stack.OpenDatabase(name, cache, handles)
@@ -930,7 +1337,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Fatalf("%v", err)
 	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
-	chain, err = core.NewBlockChain(chainDb, config, seal, new(event.TypeMux), vmcfg)
+	chain, err = core.NewBlockChain(chainDb, config, core.NewPowEngine(seal), new(event.TypeMux), vmcfg)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
@@ -0,0 +1,119 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mclock
+
+import (
+	"sync"
+	"time"
+)
+
+// Simulated implements a virtual Clock for reproducible time-sensitive tests. It
+// simulates a scheduler on a virtual timescale where actual processing takes zero
+// time. The virtual clock doesn't advance on its own, call Run to advance it.
+//
+// The zero value is a valid clock starting at time zero.
+type Simulated struct {
+	now       AbsTime
+	scheduled []simTimer
+	lock      sync.Mutex
+	cond      *sync.Cond
+}
+
+// simTimer is a pending event scheduled on the simulated clock.
+type simTimer struct {
+	at AbsTime
+	ch chan time.Time
+}
+
+// Run moves the clock by the given duration, firing all timers scheduled before
+// the new point in time.
+func (s *Simulated) Run(d time.Duration) {
+	s.lock.Lock()
+	s.init()
+
+	end := s.now + AbsTime(d)
+	var fired []simTimer
+	for len(s.scheduled) > 0 && s.scheduled[0].at <= end {
+		fired = append(fired, s.scheduled[0])
+		s.scheduled = s.scheduled[1:]
+	}
+	s.now = end
+	s.lock.Unlock()
+
+	for _, t := range fired {
+		t.ch <- time.Time{}
+	}
+}
+
+// ActiveTimers returns the number of timers that haven't fired.
+func (s *Simulated) ActiveTimers() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.scheduled)
+}
+
+// WaitForTimers waits until the clock has at least n scheduled timers.
+func (s *Simulated) WaitForTimers(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.init()
+
+	for len(s.scheduled) < n {
+		s.cond.Wait()
+	}
+}
+
+// Now implements Clock.
+func (s *Simulated) Now() AbsTime {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.now
+}
+
+// Sleep implements Clock.
+func (s *Simulated) Sleep(d time.Duration) {
+	<-s.After(d)
+}
+
+// After implements Clock.
+func (s *Simulated) After(d time.Duration) <-chan time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.init()
+
+	timer := simTimer{at: s.now + AbsTime(d), ch: make(chan time.Time, 1)}
+
+	// Insert the timer keeping the schedule sorted by firing time
+	i := len(s.scheduled)
+	for i > 0 && s.scheduled[i-1].at > timer.at {
+		i--
+	}
+	s.scheduled = append(s.scheduled, simTimer{})
+	copy(s.scheduled[i+1:], s.scheduled[i:])
+	s.scheduled[i] = timer
+
+	s.cond.Broadcast()
+	return timer.ch
+}
+
+func (s *Simulated) init() {
+	if s.cond == nil {
+		s.cond = sync.NewCond(&s.lock)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mclock
+
+import (
+	"testing"
+	"time"
+)
+
+var _ Clock = System{}
+var _ Clock = new(Simulated)
+
+// Tests that simulated timers only fire once the virtual clock passes them.
+func TestSimulatedAfter(t *testing.T) {
+	var c Simulated
+
+	late := c.After(2 * time.Second)
+	early := c.After(time.Second)
+	if n := c.ActiveTimers(); n != 2 {
+		t.Fatalf("active timer mismatch: have %d, want 2", n)
+	}
+	c.Run(time.Second)
+	select {
+	case <-early:
+	default:
+		t.Fatalf("early timer didn't fire")
+	}
+	select {
+	case <-late:
+		t.Fatalf("late timer fired prematurely")
+	default:
+	}
+	c.Run(time.Second)
+	select {
+	case <-late:
+	default:
+		t.Fatalf("late timer didn't fire")
+	}
+	if now := c.Now(); now != AbsTime(2*time.Second) {
+		t.Fatalf("clock time mismatch: have %v, want %v", now, AbsTime(2*time.Second))
+	}
+}
+
+// Tests that sleeping goroutines are woken up by advancing the clock.
+func TestSimulatedSleep(t *testing.T) {
+	var c Simulated
+
+	done := make(chan struct{})
+	go func() {
+		c.Sleep(time.Minute)
+		close(done)
+	}()
+	c.WaitForTimers(1)
+	c.Run(time.Minute)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("sleeper not woken up")
+	}
+}
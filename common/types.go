@@ -18,12 +18,14 @@ package common
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
 	"reflect"
 
 	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/crypto/sha3"
 )
 
 const (
@@ -31,6 +33,8 @@ const (
 	AddressLength = 20
 )
 
+var errAddressChecksum = errors.New("invalid EIP-55 checksum for Address")
+
 // Hash represents the 32 byte Keccak256 hash of arbitrary data.
 type Hash [HashLength]byte
 
@@ -155,6 +159,29 @@ func (a Address) Big() *big.Int { return new(big.Int).SetBytes(a[:]) }
 func (a Address) Hash() Hash    { return BytesToHash(a[:]) }
 func (a Address) Hex() string   { return hexutil.Encode(a[:]) }
 
+// ChecksumHex returns the EIP-55 mixed case checksum encoding of the address.
+func (a Address) ChecksumHex() string {
+	unchecksummed := hex.EncodeToString(a[:])
+
+	sha := sha3.NewKeccak256()
+	sha.Write([]byte(unchecksummed))
+	hash := sha.Sum(nil)
+
+	result := []byte(unchecksummed)
+	for i := range result {
+		nibble := hash[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		} else {
+			nibble &= 0x0f
+		}
+		if result[i] > '9' && nibble > 7 {
+			result[i] -= 'a' - 'A'
+		}
+	}
+	return "0x" + string(result)
+}
+
 // String implements the stringer interface and is used also by the logger.
 func (a Address) String() string {
 	return a.Hex()
@@ -206,3 +233,34 @@ func (a *UnprefixedAddress) UnmarshalText(input []byte) error {
 func (a UnprefixedAddress) MarshalText() ([]byte, error) {
 	return []byte(hex.EncodeToString(a[:])), nil
 }
+
+// ChecksumAddress allows marshaling an Address in its EIP-55 mixed case checksum
+// form, as done by the RPC API.
+type ChecksumAddress Address
+
+// UnmarshalText decodes the address from hex. Mixed case input must carry a valid
+// EIP-55 checksum, while input without one, all in lower or upper case, is accepted.
+func (a *ChecksumAddress) UnmarshalText(input []byte) error {
+	if err := hexutil.UnmarshalFixedText("Address", input, a[:]); err != nil {
+		return err
+	}
+	var lower, upper bool
+	for _, c := range input[2:] {
+		lower = lower || ('a' <= c && c <= 'f')
+		upper = upper || ('A' <= c && c <= 'F')
+	}
+	if lower && upper && string(input[2:]) != Address(*a).ChecksumHex()[2:] {
+		return errAddressChecksum
+	}
+	return nil
+}
+
+// MarshalText encodes the address as EIP-55 checksummed hex.
+func (a ChecksumAddress) MarshalText() ([]byte, error) {
+	return []byte(Address(a).ChecksumHex()), nil
+}
+
+// String implements the stringer interface.
+func (a ChecksumAddress) String() string {
+	return Address(a).ChecksumHex()
+}
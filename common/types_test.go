@@ -96,3 +96,50 @@ func TestAddressUnmarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestAddressChecksum(t *testing.T) {
+	var tests = []string{
+		// Test vectors from https://github.com/ethereum/EIPs/blob/master/EIPS/eip-55.md
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	}
+	for i, test := range tests {
+		addr := HexToAddress(test)
+		if have := addr.ChecksumHex(); have != test {
+			t.Errorf("test #%d: checksum mismatch: have %s, want %s", i, have, test)
+		}
+	}
+}
+
+func TestChecksumAddressJSON(t *testing.T) {
+	var tests = []struct {
+		Input string
+		Error error
+	}{
+		{`"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"`, nil},
+		{`"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"`, nil},
+		{`"0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED"`, nil},
+		{`"0x0000000000000000000000000000000000000010"`, nil},
+		{`"0x5aaeb6053F3E94C9b9A09f33669435E7Ef1BeAed"`, errAddressChecksum},
+	}
+	for i, test := range tests {
+		var v ChecksumAddress
+		if err := json.Unmarshal([]byte(test.Input), &v); err != test.Error {
+			t.Errorf("test #%d: error mismatch: have %v, want %v", i, err, test.Error)
+			continue
+		}
+		if test.Error != nil {
+			continue
+		}
+		want := `"` + HexToAddress(test.Input[1:len(test.Input)-1]).ChecksumHex() + `"`
+		if enc, _ := json.Marshal(v); string(enc) != want {
+			t.Errorf("test #%d: encoding mismatch: have %s, want %s", i, enc, want)
+		}
+	}
+	// Plain addresses keep their neutral encoding
+	if enc, _ := json.Marshal(HexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")); string(enc) != `"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"` {
+		t.Errorf("plain address encoding mismatch: have %s", enc)
+	}
+}
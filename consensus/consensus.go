@@ -0,0 +1,80 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package consensus implements different Ethereum consensus engines.
+package consensus
+
+import (
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/params"
+)
+
+// ChainReader defines a small collection of methods needed to access the local
+// blockchain during header and seal verification.
+type ChainReader interface {
+	// Config retrieves the blockchain's chain configuration.
+	Config() *params.ChainConfig
+
+	// CurrentHeader retrieves the current header from the local chain.
+	CurrentHeader() *types.Header
+
+	// GetHeader retrieves a block header from the database by hash and number.
+	GetHeader(hash common.Hash, number uint64) *types.Header
+
+	// GetHeaderByNumber retrieves a block header from the database by number.
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+// Engine is an algorithm agnostic consensus engine. The chain validates the
+// generic rules of the headers (timestamps, gas limits, extra-data size) and
+// delegates the consensus specific fields and the block rewards to the engine.
+type Engine interface {
+	// Author retrieves the address of the account that minted the given block,
+	// which may differ from the header's coinbase if the engine signs blocks.
+	Author(header *types.Header) (common.Address, error)
+
+	// VerifyHeader checks whether the consensus fields of a header (e.g. the
+	// difficulty) conform to the rules of the engine, given its parent. The
+	// seal is verified separately by VerifySeal.
+	VerifyHeader(chain ChainReader, header, parent *types.Header) error
+
+	// VerifySeal checks whether the cryptographic seal of a header is valid
+	// according to the rules of the engine.
+	VerifySeal(chain ChainReader, header *types.Header) error
+
+	// Prepare initializes the consensus fields of a header to be mined on top
+	// of the given parent.
+	Prepare(chain ChainReader, header, parent *types.Header) error
+
+	// Finalize runs the post-transaction state modifications of a block, such
+	// as crediting the block rewards. The header's state root is not set.
+	Finalize(chain ChainReader, state *state.StateDB, header *types.Header, uncles []*types.Header) error
+
+	// Seal generates a new block for the given input block with the local
+	// miner's seal placed on top. It returns a nil block if sealing was
+	// aborted through the stop channel.
+	Seal(chain ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error)
+}
+
+// PoW is a consensus engine based on proof-of-work.
+type PoW interface {
+	Engine
+
+	// Hashrate returns the current mining hashrate of a PoW consensus engine.
+	Hashrate() float64
+}
@@ -19,15 +19,16 @@ package core
 import (
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/math"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/params"
-	"github.com/expanse-org/go-expanse/pow"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -38,6 +39,22 @@ var (
 	bigMinus99    = big.NewInt(-99)
 )
 
+// bigPool recycles the big.Int temporaries of header validation and difficulty
+// calculation, which run for every single header while syncing the chain.
+var bigPool = sync.Pool{New: func() interface{} { return new(big.Int) }}
+
+// getBig retrieves a temporary big.Int from the pool. Its value is undefined.
+func getBig() *big.Int {
+	return bigPool.Get().(*big.Int)
+}
+
+// putBig returns temporaries to the pool. They must not be referenced afterwards.
+func putBig(xs ...*big.Int) {
+	for _, x := range xs {
+		bigPool.Put(x)
+	}
+}
+
 // BlockValidator is responsible for validating block headers, uncles and
 // processed state.
 //
@@ -45,14 +62,14 @@ var (
 type BlockValidator struct {
 	config *params.ChainConfig // Chain configuration options
 	bc     *BlockChain         // Canonical block chain
-	Pow    pow.PoW             // Proof of work used for validating
+	engine consensus.Engine    // Consensus engine used for validating
 }
 
 // NewBlockValidator returns a new block validator which is safe for re-use
-func NewBlockValidator(config *params.ChainConfig, blockchain *BlockChain, pow pow.PoW) *BlockValidator {
+func NewBlockValidator(config *params.ChainConfig, blockchain *BlockChain, engine consensus.Engine) *BlockValidator {
 	validator := &BlockValidator{
 		config: config,
-		Pow:    pow,
+		engine: engine,
 		bc:     blockchain,
 	}
 	return validator
@@ -84,7 +101,7 @@ func (v *BlockValidator) ValidateBlock(block *types.Block) error {
 
 	header := block.Header()
 	// validate the block header
-	if err := ValidateHeader(v.config, v.Pow, header, parent.Header(), false, false); err != nil {
+	if err := ValidateHeader(v.bc, v.engine, header, parent.Header(), false, false); err != nil {
 		return err
 	}
 	// verify the uncles are correctly rewarded
@@ -148,7 +165,7 @@ func (v *BlockValidator) VerifyUncles(block, parent *types.Block) error {
 
 	uncles := set.New()
 	ancestors := make(map[common.Hash]*types.Block)
-	for _, ancestor := range v.bc.GetBlocksFromHash(block.ParentHash(), 7) {
+	for _, ancestor := range v.bc.GetBlocksFromHash(block.ParentHash(), int(v.config.MaxUncleDepth())) {
 		ancestors[ancestor.Hash()] = ancestor
 		// Include ancestors uncles in the uncle set. Uncles must be unique.
 		for _, uncle := range ancestor.Uncles() {
@@ -179,7 +196,7 @@ func (v *BlockValidator) VerifyUncles(block, parent *types.Block) error {
 			return UncleError("uncle[%d](%x)'s parent is not ancestor (%x)", i, hash[:4], uncle.ParentHash[0:4])
 		}
 
-		if err := ValidateHeader(v.config, v.Pow, uncle, ancestors[uncle.ParentHash].Header(), true, true); err != nil {
+		if err := ValidateHeader(v.bc, v.engine, uncle, ancestors[uncle.ParentHash].Header(), true, true); err != nil {
 			return ValidationError(fmt.Sprintf("uncle[%d](%x) header invalid: %v", i, hash[:4], err))
 		}
 	}
@@ -199,13 +216,15 @@ func (v *BlockValidator) ValidateHeader(header, parent *types.Header, checkPow b
 	if v.bc.HasHeader(header.Hash()) {
 		return nil
 	}
-	return ValidateHeader(v.config, v.Pow, header, parent, checkPow, false)
+	return ValidateHeader(v.bc, v.engine, header, parent, checkPow, false)
 }
 
-// Validates a header. Returns an error if the header is invalid.
+// Validates a header. Returns an error if the header is invalid. The consensus
+// fields and, depending on checkPow, the seal are verified by the engine.
 //
 // See YP section 4.3.4. "Block Header Validity"
-func ValidateHeader(config *params.ChainConfig, pow pow.PoW, header *types.Header, parent *types.Header, checkPow, uncle bool) error {
+func ValidateHeader(chain consensus.ChainReader, engine consensus.Engine, header *types.Header, parent *types.Header, checkPow, uncle bool) error {
+	config := chain.Config()
 	if uint64(len(header.Extra)) > params.MaximumExtraDataSize {
 		return fmt.Errorf("Header extra data too long (%d)", len(header.Extra))
 	}
@@ -223,30 +242,35 @@ func ValidateHeader(config *params.ChainConfig, pow pow.PoW, header *types.Heade
 		return BlockEqualTSErr
 	}
 
-	expd := CalcDifficulty(config, header.Time.Uint64(), parent.Time.Uint64(), parent.Number, parent.Difficulty)
-	if expd.Cmp(header.Difficulty) != 0 {
-		return fmt.Errorf("Difficulty check failed for header (remote: %v local: %v)", header.Difficulty, expd)
+	if err := engine.VerifyHeader(chain, header, parent); err != nil {
+		return err
 	}
 
-	a := new(big.Int).Set(parent.GasLimit)
-	a = a.Sub(a, header.GasLimit)
+	// Gas is accounted in 64 bits during execution, make sure the header fits
+	if header.GasLimit.Cmp(math.MaxBig63) > 0 {
+		return fmt.Errorf("GasLimit too big for header (remote: %v max: %v)", header.GasLimit, math.MaxBig63)
+	}
+	if header.GasUsed.Cmp(header.GasLimit) > 0 {
+		return fmt.Errorf("GasUsed above GasLimit for header (used: %v limit: %v)", header.GasUsed, header.GasLimit)
+	}
+	a, b := getBig(), getBig()
+	defer putBig(a, b)
+
+	a.Sub(parent.GasLimit, header.GasLimit)
 	a.Abs(a)
-	b := new(big.Int).Set(parent.GasLimit)
-	b = b.Div(b, params.GasLimitBoundDivisor)
+	b.Div(parent.GasLimit, params.GasLimitBoundDivisor)
 	if !(a.Cmp(b) < 0) || (header.GasLimit.Cmp(params.MinGasLimit) == -1) {
 		return fmt.Errorf("GasLimit check failed for header (remote: %v local_max: %v)", header.GasLimit, b)
 	}
 
-	num := new(big.Int).Set(parent.Number)
-	num.Sub(header.Number, num)
-	if num.Cmp(big.NewInt(1)) != 0 {
+	if a.Sub(header.Number, parent.Number).Cmp(common.Big1) != 0 {
 		return BlockNumberErr
 	}
 
 	if checkPow {
-		// Verify the nonce of the header. Return an error if it's not valid
-		if err := pow.Verify(types.NewBlockWithHeader(header)); err != nil {
-			return &BlockNonceErr{header.Number, header.Hash(), header.Nonce.Uint64()}
+		// Verify the seal of the header. Return an error if it's not valid
+		if err := engine.VerifySeal(chain, header); err != nil {
+			return err
 		}
 	}
 	// If all checks passed, validate the extra-data field for hard forks
@@ -265,7 +289,10 @@ func ValidateHeader(config *params.ChainConfig, pow pow.PoW, header *types.Heade
 // the difficulty that a new block should have when created at time
 // given the parent block's time and difficulty.
 func CalcDifficulty(config *params.ChainConfig, time, parentTime uint64, parentNumber, parentDiff *big.Int) *big.Int {
-	if config.IsHomestead(new(big.Int).Add(parentNumber, common.Big1)) {
+	next := getBig().Add(parentNumber, common.Big1)
+	defer putBig(next)
+
+	if config.IsHomestead(next) {
 		return calcDifficultyHomestead(time, parentTime, parentNumber, parentDiff)
 	} else {
 		return calcDifficultyFrontier(time, parentTime, parentNumber, parentDiff)
@@ -279,12 +306,14 @@ func calcDifficultyHomestead(time, parentTime uint64, parentNumber, parentDiff *
 	//         (parent_diff / 2048 * max(1 - (block_timestamp - parent_timestamp) // 10, -99))
 	//        ) + 2^(periodCount - 2)
 
-	bigTime := new(big.Int).SetUint64(time)
-	bigParentTime := new(big.Int).SetUint64(parentTime)
+	bigTime := getBig().SetUint64(time)
+	bigParentTime := getBig().SetUint64(parentTime)
 
-	// holds intermediate values to make the algo easier to read & audit
+	// holds intermediate values to make the algo easier to read & audit, only
+	// x escaping as the result
 	x := new(big.Int)
-	y := new(big.Int)
+	y := getBig()
+	defer putBig(bigTime, bigParentTime, y)
 
 	// 1 - (block_timestamp -parent_timestamp) // 10
 	x.Sub(bigTime, bigParentTime)
@@ -311,19 +340,18 @@ func calcDifficultyHomestead(time, parentTime uint64, parentNumber, parentDiff *
 
 func calcDifficultyFrontier(time, parentTime uint64, parentNumber, parentDiff *big.Int) *big.Int {
 	diff := new(big.Int)
-	adjust := new(big.Int)
+	adjust := getBig()
+	defer putBig(adjust)
 
 	if parentNumber.Cmp(params.HardFork1) < 0 {
-		adjust = new(big.Int).Div(parentDiff, params.DifficultyBoundDivisor)
+		adjust.Div(parentDiff, params.DifficultyBoundDivisor)
 	} else {
-		adjust = new(big.Int).Div(parentDiff, params.DifficultyBoundDivisor2)
+		adjust.Div(parentDiff, params.DifficultyBoundDivisor2)
 	}
 
-	bigTime := new(big.Int)
-	bigParentTime := new(big.Int)
-
-	bigTime.SetUint64(time)
-	bigParentTime.SetUint64(parentTime)
+	bigTime := getBig().SetUint64(time)
+	bigParentTime := getBig().SetUint64(parentTime)
+	defer putBig(bigTime, bigParentTime)
 
 	if bigTime.Sub(bigTime, bigParentTime).Cmp(params.DurationLimit) < 0 {
 		diff.Add(parentDiff, adjust)
@@ -334,7 +362,9 @@ func calcDifficultyFrontier(time, parentTime uint64, parentNumber, parentDiff *b
 		diff.Set(params.MinimumDifficulty)
 	}
 
-	periodCount := new(big.Int).Add(parentNumber, common.Big1)
+	periodCount := getBig().Add(parentNumber, common.Big1)
+	defer putBig(periodCount)
+
 	periodCount.Div(periodCount, ExpDiffPeriod)
 	if periodCount.Cmp(common.Big1) > 0 {
 		// diff = diff + 2^(periodCount - 2)
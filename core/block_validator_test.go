@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/math"
 	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/ethdb"
@@ -40,18 +41,70 @@ func TestNumber(t *testing.T) {
 	statedb, _ := state.New(chain.Genesis().Root(), chain.chainDb)
 	header := makeHeader(chain.config, chain.Genesis(), statedb)
 	header.Number = big.NewInt(3)
-	err := ValidateHeader(chain.config, pow.FakePow{}, header, chain.Genesis().Header(), false, false)
+	err := ValidateHeader(chain, NewPowEngine(pow.FakePow{}), header, chain.Genesis().Header(), false, false)
 	if err != BlockNumberErr {
 		t.Errorf("expected block number error, got %q", err)
 	}
 
 	header = makeHeader(chain.config, chain.Genesis(), statedb)
-	err = ValidateHeader(chain.config, pow.FakePow{}, header, chain.Genesis().Header(), false, false)
+	err = ValidateHeader(chain, NewPowEngine(pow.FakePow{}), header, chain.Genesis().Header(), false, false)
 	if err == BlockNumberErr {
 		t.Errorf("didn't expect block number error")
 	}
 }
 
+// Tests that headers with fields overflowing the 64 bit arithmetic of the
+// difficulty calculation and the gas accounting are rejected.
+func TestValidateHeaderOverflow(t *testing.T) {
+	chain := newTestBlockChain()
+	statedb, _ := state.New(chain.Genesis().Root(), chain.chainDb)
+	engine := NewPowEngine(pow.FakePow{})
+
+	// A timestamp truncating to the one the difficulty was calculated for
+	header := makeHeader(chain.config, chain.Genesis(), statedb)
+	header.Time.Add(header.Time, new(big.Int).Lsh(common.Big1, 64))
+	if err := ValidateHeader(chain, engine, header, chain.Genesis().Header(), false, true); err != BlockTSTooBigErr {
+		t.Errorf("truncating timestamp: error mismatch: have %v, want %v", err, BlockTSTooBigErr)
+	}
+	// A gas limit beyond 64 bit gas accounting
+	header = makeHeader(chain.config, chain.Genesis(), statedb)
+	header.GasLimit = new(big.Int).Add(math.MaxBig63, common.Big1)
+	if err := ValidateHeader(chain, engine, header, chain.Genesis().Header(), false, false); err == nil {
+		t.Errorf("oversized gas limit accepted")
+	}
+	// More gas used than the limit allows
+	header = makeHeader(chain.config, chain.Genesis(), statedb)
+	header.GasUsed = new(big.Int).Add(header.GasLimit, common.Big1)
+	if err := ValidateHeader(chain, engine, header, chain.Genesis().Header(), false, false); err == nil {
+		t.Errorf("gas used above limit accepted")
+	}
+	// The untouched header must pass
+	header = makeHeader(chain.config, chain.Genesis(), statedb)
+	if err := ValidateHeader(chain, engine, header, chain.Genesis().Header(), false, false); err != nil {
+		t.Errorf("valid header rejected: %v", err)
+	}
+}
+
+func BenchmarkCalcDifficultyFrontier(b *testing.B) {
+	benchmarkCalcDifficulty(b, &params.ChainConfig{})
+}
+
+func BenchmarkCalcDifficultyHomestead(b *testing.B) {
+	benchmarkCalcDifficulty(b, &params.ChainConfig{HomesteadBlock: big.NewInt(0)})
+}
+
+func benchmarkCalcDifficulty(b *testing.B, config *params.ChainConfig) {
+	var (
+		number = big.NewInt(1200000)
+		diff   = big.NewInt(40000000000)
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CalcDifficulty(config, 1500000060, 1500000000, number, diff)
+	}
+}
+
 func TestPutReceipt(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
 
@@ -23,13 +23,13 @@ import (
 	"io"
 	"math/big"
 	mrand "math/rand"
-	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/core/vm"
@@ -39,7 +39,6 @@ import (
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/metrics"
 	"github.com/expanse-org/go-expanse/params"
-	"github.com/expanse-org/go-expanse/pow"
 	"github.com/expanse-org/go-expanse/rlp"
 	"github.com/expanse-org/go-expanse/trie"
 	"github.com/hashicorp/golang-lru"
@@ -52,7 +51,8 @@ var (
 )
 
 const (
-	bodyCacheLimit      = 256
+	bodyCacheSize       = 16 * 1024 * 1024 // Bytes of block bodies to keep cached (both decoded and RLP)
+	receiptsCacheSize   = 16 * 1024 * 1024 // Bytes of block receipts to keep cached
 	blockCacheLimit     = 256
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
@@ -77,6 +77,8 @@ const (
 // included in the canonical one where as GetBlockByNumber always represents the
 // canonical chain.
 type BlockChain struct {
+	insertLatency int64 // processing time of the last imported canonical block (nanoseconds, atomic, 64-bit aligned)
+
 	config *params.ChainConfig // chain & network configuration
 
 	hc           *HeaderChain
@@ -87,16 +89,23 @@ type BlockChain struct {
 	mu      sync.RWMutex // global mutex for locking chain operations
 	chainmu sync.RWMutex // blockchain insertion lock
 	procmu  sync.RWMutex // block processor lock
+	statemu sync.RWMutex // state pruning lock, held for reading while writing state
+
+	priomu   sync.Mutex // priority insertion lock, protecting the waiting insertions
+	priocond *sync.Cond // signalled once no more priority insertions are waiting
+	priority int32      // number of priority insertions waiting or running (atomic)
 
 	checkpoint       int          // checkpoint counts towards the new checkpoint
 	currentBlock     *types.Block // Current head of the block chain
 	currentFastBlock *types.Block // Current head of the fast-sync chain (may be above the block chain!)
 
-	stateCache   *state.StateDB // State database to reuse between imports (contains state cache)
-	bodyCache    *lru.Cache     // Cache for the most recent block bodies
-	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
-	blockCache   *lru.Cache     // Cache for the most recent entire blocks
-	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
+	stateCache    *state.StateDB // State database to reuse between imports (contains state cache)
+	trieCacheGen  uint32         // Temporary trie cache generation limit, applied to stateCache on use (0 = none, atomic)
+	bodyCache     *sizedCache    // Cache for the most recent block bodies
+	bodyRLPCache  *sizedCache    // Cache for the most recent block bodies in RLP encoded format
+	receiptsCache *sizedCache    // Cache for the most recent block receipts
+	blockCache    *lru.Cache     // Cache for the most recent entire blocks
+	futureBlocks  *lru.Cache     // future blocks are blocks added for later processing
 
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
@@ -104,38 +113,43 @@ type BlockChain struct {
 	procInterrupt int32          // interrupt signaler for block processing
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 
-	pow       pow.PoW
+	engine    consensus.Engine
 	processor Processor // block processor interface
 	validator Validator // block and state validator interface
 	vmConfig  vm.Config
 
+	writePolicy WritePolicy            // batching and durability settings of chain writes
+	pruning     *PruneConfig           // state pruning settings, nil if every state is kept
+	pruneRoots  []func() []common.Hash // extra trie roots to keep when pruning state
+
 	badBlocks *lru.Cache // Bad block cache
 }
 
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialiser the default Ethereum Validator and
 // Processor.
-func NewBlockChain(chainDb ethdb.Database, config *params.ChainConfig, pow pow.PoW, mux *event.TypeMux, vmConfig vm.Config) (*BlockChain, error) {
-	bodyCache, _ := lru.New(bodyCacheLimit)
-	bodyRLPCache, _ := lru.New(bodyCacheLimit)
+func NewBlockChain(chainDb ethdb.Database, config *params.ChainConfig, engine consensus.Engine, mux *event.TypeMux, vmConfig vm.Config) (*BlockChain, error) {
 	blockCache, _ := lru.New(blockCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 	badBlocks, _ := lru.New(badBlockLimit)
 
 	bc := &BlockChain{
-		config:       config,
-		chainDb:      chainDb,
-		eventMux:     mux,
-		quit:         make(chan struct{}),
-		bodyCache:    bodyCache,
-		bodyRLPCache: bodyRLPCache,
-		blockCache:   blockCache,
-		futureBlocks: futureBlocks,
-		pow:          pow,
-		vmConfig:     vmConfig,
-		badBlocks:    badBlocks,
-	}
-	bc.SetValidator(NewBlockValidator(config, bc, pow))
+		config:        config,
+		chainDb:       chainDb,
+		eventMux:      mux,
+		quit:          make(chan struct{}),
+		bodyCache:     newSizedCache(bodyCacheSize, "chain/cache/bodies"),
+		bodyRLPCache:  newSizedCache(bodyCacheSize, "chain/cache/bodyrlps"),
+		receiptsCache: newSizedCache(receiptsCacheSize, "chain/cache/receipts"),
+		blockCache:    blockCache,
+		futureBlocks:  futureBlocks,
+		engine:        engine,
+		vmConfig:      vmConfig,
+		badBlocks:     badBlocks,
+		writePolicy:   DefaultWritePolicy,
+	}
+	bc.priocond = sync.NewCond(&bc.priomu)
+	bc.SetValidator(NewBlockValidator(config, bc, engine))
 	bc.SetProcessor(NewStateProcessor(config, bc))
 
 	gv := func() HeaderValidator { return bc.Validator() }
@@ -181,9 +195,14 @@ func (self *BlockChain) loadLastState() error {
 	// Restore the last known head block
 	head := GetHeadBlockHash(self.chainDb)
 	if head == (common.Hash{}) {
-		// Corrupt or empty database, init from scratch
-		log.Warn("Empty database, resetting chain")
-		return self.Reset()
+		if header := GetHeadHeaderHash(self.chainDb); header == (common.Hash{}) || self.GetHeaderByHash(header) == nil {
+			// Corrupt or empty database, init from scratch
+			log.Warn("Empty database, resetting chain")
+			return self.Reset()
+		}
+		// Headers only database of a light node, keep them and sync the blocks
+		log.Info("Upgrading light chain database, keeping headers")
+		head = self.genesisBlock.Hash()
 	}
 	// Make sure the entire head block is available
 	currentBlock := self.GetBlockByHash(head)
@@ -233,8 +252,8 @@ func (self *BlockChain) loadLastState() error {
 	log.Info("Loaded most recent local full block", "number", self.currentBlock.Number(), "hash", self.currentBlock.Hash(), "td", blockTd)
 	log.Info("Loaded most recent local fast block", "number", self.currentFastBlock.Number(), "hash", self.currentFastBlock.Hash(), "td", fastTd)
 
-	// Try to be smart and issue a pow verification for the head to pre-generate caches
-	go self.pow.Verify(types.NewBlockWithHeader(currentHeader))
+	// Try to be smart and issue a seal verification for the head to pre-generate caches
+	go self.engine.VerifySeal(self, currentHeader)
 
 	return nil
 }
@@ -259,6 +278,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
+	bc.receiptsCache.Purge()
 	bc.blockCache.Purge()
 	bc.futureBlocks.Purge()
 
@@ -312,6 +332,30 @@ func (self *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	return nil
 }
 
+// PurgeCaches drops all recently accessed blocks, bodies and receipts from the
+// in-memory caches, releasing their memory. The caches are gradually refilled on
+// access.
+func (bc *BlockChain) PurgeCaches() {
+	bc.bodyCache.Purge()
+	bc.bodyRLPCache.Purge()
+	bc.receiptsCache.Purge()
+	bc.blockCache.Purge()
+}
+
+// LimitTrieCacheGen temporarily caps the number of trie node generations kept in
+// memory by the state tries of this chain below state.MaxTrieCacheGen. A limit of
+// zero lifts the cap. The limit takes effect on the next state opened.
+func (bc *BlockChain) LimitTrieCacheGen(limit uint16) {
+	atomic.StoreUint32(&bc.trieCacheGen, uint32(limit))
+}
+
+// limitStateCache applies the current trie cache generation limit to the given
+// state cache, returning it for convenience.
+func (bc *BlockChain) limitStateCache(statedb *state.StateDB) *state.StateDB {
+	statedb.LimitTrieCacheGen(uint16(atomic.LoadUint32(&bc.trieCacheGen)))
+	return statedb
+}
+
 // GasLimit returns the gas limit of the current HEAD block.
 func (self *BlockChain) GasLimit() *big.Int {
 	self.mu.RLock()
@@ -369,6 +413,67 @@ func (self *BlockChain) SetValidator(validator Validator) {
 	self.validator = validator
 }
 
+// SetWritePolicy sets the policy used to batch and sync chain data writes.
+func (self *BlockChain) SetWritePolicy(policy WritePolicy) {
+	self.procmu.Lock()
+	defer self.procmu.Unlock()
+	self.writePolicy = policy
+}
+
+// WritePolicy returns the current chain data write policy.
+func (self *BlockChain) WritePolicy() WritePolicy {
+	self.procmu.RLock()
+	defer self.procmu.RUnlock()
+	return self.writePolicy
+}
+
+// SetPruning enables the garbage collection of historical states with the given
+// settings, or disables it if config is nil, retaining every state.
+func (self *BlockChain) SetPruning(config *PruneConfig) error {
+	if config != nil {
+		if !canPrune(self.chainDb) {
+			return errPruningUnsupported
+		}
+		if config.Retention < MinPruneRetention {
+			return fmt.Errorf("state retention %d below minimum %d", config.Retention, MinPruneRetention)
+		}
+		config = &PruneConfig{Retention: config.Retention, Interval: config.Interval}
+	}
+	self.procmu.Lock()
+	defer self.procmu.Unlock()
+	self.pruning = config
+	return nil
+}
+
+// Pruning returns the current state pruning settings, or nil if every state is
+// kept.
+func (self *BlockChain) Pruning() *PruneConfig {
+	self.procmu.RLock()
+	defer self.procmu.RUnlock()
+	return self.pruning
+}
+
+// AddPruneRoots registers a callback retrieving the roots of further tries kept
+// in the chain database, which state pruning must not delete.
+func (self *BlockChain) AddPruneRoots(roots func() []common.Hash) {
+	self.procmu.Lock()
+	defer self.procmu.Unlock()
+	self.pruneRoots = append(self.pruneRoots, roots)
+}
+
+// PruneGuard returns a lock holding off state pruning. Code writing trie nodes
+// into the chain database outside of block imports must hold it until the root
+// of the written trie is kept by the state or by a registered prune root.
+func (self *BlockChain) PruneGuard() sync.Locker {
+	return self.statemu.RLocker()
+}
+
+// InsertLatency returns the time it took to process and write the most recently
+// imported canonical block, which is an indicator of the load on the node.
+func (self *BlockChain) InsertLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&self.insertLatency))
+}
+
 // Validator returns the current validator.
 func (self *BlockChain) Validator() Validator {
 	self.procmu.RLock()
@@ -383,8 +488,8 @@ func (self *BlockChain) Processor() Processor {
 	return self.processor
 }
 
-// AuxValidator returns the auxiliary validator (Proof of work atm)
-func (self *BlockChain) AuxValidator() pow.PoW { return self.pow }
+// Engine retrieves the consensus engine of the blockchain.
+func (self *BlockChain) Engine() consensus.Engine { return self.engine }
 
 // State returns a new mutable state based on the current HEAD block.
 func (self *BlockChain) State() (*state.StateDB, error) {
@@ -393,7 +498,7 @@ func (self *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (self *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return self.stateCache.New(root)
+	return self.limitStateCache(self.stateCache).New(root)
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -464,25 +569,47 @@ func (self *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 //
 // Note, this function assumes that the `mu` mutex is held!
 func (bc *BlockChain) insert(block *types.Block) {
-	// If the block is on a side chain or an unknown one, force other heads onto it too
+	batch := bc.chainDb.NewBatch()
+	updateHeads := bc.writeHead(batch, block)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to insert head block", "err", err)
+	}
+	bc.setHead(block, updateHeads)
+}
+
+// writeHead adds the database markers of a new head block to a batch: its
+// canonical number mapping, the head block hash and, if the block is on a side
+// chain or an unknown one, the head fast block hash. The returned flag reports
+// whether the other heads need to be forced onto the block too.
+//
+// Note, this function assumes that the `mu` mutex is held!
+func (bc *BlockChain) writeHead(batch ethdb.Putter, block *types.Block) bool {
 	updateHeads := GetCanonicalHash(bc.chainDb, block.NumberU64()) != block.Hash()
 
-	// Add the block to the canonical chain number scheme and mark as the head
-	if err := WriteCanonicalHash(bc.chainDb, block.Hash(), block.NumberU64()); err != nil {
+	if err := WriteCanonicalHash(batch, block.Hash(), block.NumberU64()); err != nil {
 		log.Crit("Failed to insert block number", "err", err)
 	}
-	if err := WriteHeadBlockHash(bc.chainDb, block.Hash()); err != nil {
+	if err := WriteHeadBlockHash(batch, block.Hash()); err != nil {
 		log.Crit("Failed to insert head block hash", "err", err)
 	}
+	if updateHeads {
+		if err := WriteHeadFastBlockHash(batch, block.Hash()); err != nil {
+			log.Crit("Failed to insert head fast block hash", "err", err)
+		}
+	}
+	return updateHeads
+}
+
+// setHead switches the in-memory heads to a new head block, once the markers
+// added by writeHead are written out.
+//
+// Note, this function assumes that the `mu` mutex is held!
+func (bc *BlockChain) setHead(block *types.Block, updateHeads bool) {
 	bc.currentBlock = block
 
 	// If the block is better than out head or is on a different chain, force update heads
 	if updateHeads {
 		bc.hc.SetCurrentHeader(block.Header())
-
-		if err := WriteHeadFastBlockHash(bc.chainDb, block.Hash()); err != nil {
-			log.Crit("Failed to insert head fast block hash", "err", err)
-		}
 		bc.currentFastBlock = block
 	}
 }
@@ -500,12 +627,17 @@ func (self *BlockChain) GetBody(hash common.Hash) *types.Body {
 		body := cached.(*types.Body)
 		return body
 	}
-	body := GetBody(self.chainDb, hash, self.hc.GetBlockNumber(hash))
-	if body == nil {
+	data := GetBodyRLP(self.chainDb, hash, self.hc.GetBlockNumber(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	body := new(types.Body)
+	if err := rlp.DecodeBytes(data, body); err != nil {
+		log.Error("Invalid block body RLP", "hash", hash, "err", err)
 		return nil
 	}
 	// Cache the found body for next time and return
-	self.bodyCache.Add(hash, body)
+	self.bodyCache.Add(hash, body, len(data))
 	return body
 }
 
@@ -521,10 +653,78 @@ func (self *BlockChain) GetBodyRLP(hash common.Hash) rlp.RawValue {
 		return nil
 	}
 	// Cache the found body for next time and return
-	self.bodyRLPCache.Add(hash, body)
+	self.bodyRLPCache.Add(hash, body, len(body))
 	return body
 }
 
+// GetReceiptsByHash retrieves the receipts for all transactions in a given block
+// from the database, caching them if found.
+func (self *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
+	// Short circuit if the receipts are already in the cache, retrieve otherwise
+	if cached, ok := self.receiptsCache.Get(hash); ok {
+		return cached.(types.Receipts)
+	}
+	receipts := GetBlockReceipts(self.chainDb, hash, self.hc.GetBlockNumber(hash))
+	if receipts == nil {
+		return nil
+	}
+	// Cache the found receipts for next time and return
+	self.receiptsCache.Add(hash, receipts, receiptsSize(receipts))
+	return receipts
+}
+
+// RegenerateReceipts re-executes a block on top of its parent state to rebuild
+// its receipts and logs, storing them if they match the receipt root committed
+// to in the header. It is meant for databases missing the receipts of some
+// blocks, and fails if the state of the parent is no longer available.
+func (self *BlockChain) RegenerateReceipts(block *types.Block) (types.Receipts, error) {
+	parent := self.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("unknown parent %x", block.ParentHash())
+	}
+	statedb, err := state.New(parent.Root(), self.chainDb)
+	if err != nil {
+		return nil, err
+	}
+	receipts, _, _, err := self.Processor().Process(block, statedb, self.vmConfig)
+	if err != nil {
+		return nil, err
+	}
+	if hash := types.DeriveSha(receipts); hash != block.ReceiptHash() {
+		return nil, fmt.Errorf("receipt root mismatch: have %x, want %x", hash, block.ReceiptHash())
+	}
+	batch := self.chainDb.NewBatch()
+	if err := WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
+		return nil, err
+	}
+	if err := WriteReceipts(batch, receipts); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	if err := WriteMipmapBloom(self.chainDb, block.NumberU64(), receipts); err != nil {
+		return nil, err
+	}
+	if err := WriteLogIndex(self.chainDb, block.NumberU64(), receipts); err != nil {
+		return nil, err
+	}
+	self.receiptsCache.Remove(block.Hash())
+	return receipts, nil
+}
+
+// receiptsSize approximates the memory used by a list of receipts.
+func receiptsSize(receipts types.Receipts) int {
+	size := 0
+	for _, receipt := range receipts {
+		size += len(receipt.PostState) + len(receipt.Bloom) + 2*common.HashLength + common.AddressLength + 2*8
+		for _, log := range receipt.Logs {
+			size += common.AddressLength + len(log.Topics)*common.HashLength + len(log.Data)
+		}
+	}
+	return size
+}
+
 // HasBlock checks if a block is fully present in the database or not, caching
 // it if present.
 func (bc *BlockChain) HasBlock(hash common.Hash) bool {
@@ -721,107 +921,86 @@ func (self *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain
 	stats := struct{ processed, ignored int32 }{}
 	start := time.Now()
 
-	// Create the block importing task queue and worker functions
-	tasks := make(chan int, len(blockChain))
-	for i := 0; i < len(blockChain) && i < len(receiptChain); i++ {
-		tasks <- i
-	}
-	close(tasks)
-
-	errs, failed := make([]error, len(tasks)), int32(0)
-	process := func(worker int) {
-		for index := range tasks {
-			block, receipts := blockChain[index], receiptChain[index]
+	// Accumulate the chain data into large batches as dictated by the write policy
+	writer := newChainWriter(self.chainDb, self.WritePolicy())
 
-			// Short circuit insertion if shutting down or processing failed
-			if atomic.LoadInt32(&self.procInterrupt) == 1 {
-				return
-			}
-			if atomic.LoadInt32(&failed) > 0 {
-				return
-			}
-			// Short circuit if the owner header is unknown
-			if !self.HasHeader(block.Hash()) {
-				errs[index] = fmt.Errorf("containing header #%d [%x…] unknown", block.Number(), block.Hash().Bytes()[:4])
-				atomic.AddInt32(&failed, 1)
-				return
-			}
-			// Skip if the entire data is already known
-			if self.HasBlock(block.Hash()) {
-				atomic.AddInt32(&stats.ignored, 1)
-				continue
-			}
-			// Compute all the non-consensus fields of the receipts
-			SetReceiptsData(self.config, block, receipts)
-			// Write all the data out into the database
-			if err := WriteBody(self.chainDb, block.Hash(), block.NumberU64(), block.Body()); err != nil {
-				errs[index] = fmt.Errorf("failed to write block body: %v", err)
-				atomic.AddInt32(&failed, 1)
-				log.Crit("Failed to write block body", "err", err)
-				return
-			}
-			if err := WriteBlockReceipts(self.chainDb, block.Hash(), block.NumberU64(), receipts); err != nil {
-				errs[index] = fmt.Errorf("failed to write block receipts: %v", err)
-				atomic.AddInt32(&failed, 1)
-				log.Crit("Failed to write block receipts", "err", err)
-				return
-			}
-			if err := WriteMipmapBloom(self.chainDb, block.NumberU64(), receipts); err != nil {
-				errs[index] = fmt.Errorf("failed to write log blooms: %v", err)
-				atomic.AddInt32(&failed, 1)
-				log.Crit("Failed to write log blooms", "err", err)
-				return
-			}
-			if err := WriteTransactions(self.chainDb, block); err != nil {
-				errs[index] = fmt.Errorf("failed to write individual transactions: %v", err)
-				atomic.AddInt32(&failed, 1)
-				log.Crit("Failed to write individual transactions", "err", err)
-				return
-			}
-			if err := WriteReceipts(self.chainDb, receipts); err != nil {
-				errs[index] = fmt.Errorf("failed to write individual receipts: %v", err)
-				atomic.AddInt32(&failed, 1)
-				log.Crit("Failed to write individual receipts", "err", err)
-				return
-			}
-			atomic.AddInt32(&stats.processed, 1)
+	// abort writes out the data of the blocks imported so far and fails the import
+	abort := func(index int, err error) (int, error) {
+		if err := writer.Write(false); err != nil {
+			log.Crit("Failed to write block bodies and receipts", "err", err)
 		}
+		return index, err
 	}
-	// Start as many worker threads as goroutines allowed
-	pending := new(sync.WaitGroup)
-	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
-		pending.Add(1)
-		go func(id int) {
-			defer pending.Done()
-			process(id)
-		}(i)
+
+	count := len(blockChain)
+	if len(receiptChain) < count {
+		count = len(receiptChain)
 	}
-	pending.Wait()
+	for i := 0; i < count; i++ {
+		block, receipts := blockChain[i], receiptChain[i]
 
-	// If anything failed, report
-	if failed > 0 {
-		for i, err := range errs {
-			if err != nil {
-				return i, err
+		// Short circuit insertion if shutting down
+		if atomic.LoadInt32(&self.procInterrupt) == 1 {
+			log.Debug("Premature abort during receipts processing")
+			return abort(0, nil)
+		}
+		// Short circuit if the owner header is unknown
+		if !self.HasHeader(block.Hash()) {
+			return abort(i, fmt.Errorf("containing header #%d [%x…] unknown", block.Number(), block.Hash().Bytes()[:4]))
+		}
+		// Skip if the entire data is already known
+		if self.HasBlock(block.Hash()) {
+			stats.ignored++
+			continue
+		}
+		// Compute all the non-consensus fields of the receipts
+		SetReceiptsData(self.config, block, receipts)
+		// Queue all the data up for writing into the database
+		if err := WriteBody(writer, block.Hash(), block.NumberU64(), block.Body()); err != nil {
+			return abort(i, fmt.Errorf("failed to write block body: %v", err))
+		}
+		if err := WriteBlockReceipts(writer, block.Hash(), block.NumberU64(), receipts); err != nil {
+			return abort(i, fmt.Errorf("failed to write block receipts: %v", err))
+		}
+		if err := WriteTransactions(writer, block); err != nil {
+			return abort(i, fmt.Errorf("failed to write individual transactions: %v", err))
+		}
+		if err := WriteReceipts(writer, receipts); err != nil {
+			return abort(i, fmt.Errorf("failed to write individual receipts: %v", err))
+		}
+		// Log blooms are merged into existing bins, so they go to the database directly,
+		// but only after the receipts they index
+		number := block.NumberU64()
+		writer.Defer(func() error {
+			if err := WriteMipmapBloom(self.chainDb, number, receipts); err != nil {
+				return fmt.Errorf("failed to write log blooms: %v", err)
+			}
+			if err := WriteLogIndex(self.chainDb, number, receipts); err != nil {
+				return fmt.Errorf("failed to write log index: %v", err)
 			}
+			return nil
+		})
+		if err := writer.Flush(); err != nil {
+			log.Crit("Failed to write block bodies and receipts", "err", err)
 		}
-	}
-	if atomic.LoadInt32(&self.procInterrupt) == 1 {
-		log.Debug("Premature abort during receipts processing")
-		return 0, nil
+		stats.processed++
 	}
 	// Update the head fast sync block if better
 	self.mu.Lock()
 
-	head := blockChain[len(errs)-1]
+	head := blockChain[count-1]
 	if td := self.GetTd(head.Hash(), head.NumberU64()); td != nil { // Rewind may have occurred, skip in that case
 		if self.GetTd(self.currentFastBlock.Hash(), self.currentFastBlock.NumberU64()).Cmp(td) < 0 {
-			if err := WriteHeadFastBlockHash(self.chainDb, head.Hash()); err != nil {
+			if err := WriteHeadFastBlockHash(writer, head.Hash()); err != nil {
 				log.Crit("Failed to update head fast block hash", "err", err)
 			}
 			self.currentFastBlock = head
 		}
 	}
+	// Write out any leftovers, syncing them to disk if the import reached the head
+	if err := writer.Write(writer.policy.nearHead(head)); err != nil {
+		log.Crit("Failed to write block bodies and receipts", "err", err)
+	}
 	self.mu.Unlock()
 
 	// Report some public statistics so the user has a clue what's going on
@@ -832,8 +1011,15 @@ func (self *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain
 	return 0, nil
 }
 
-// WriteBlock writes the block to the chain.
-func (self *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err error) {
+// WriteBlockWithState writes a processed block together with its receipts and
+// post state to the database, making it the new head if it's heavier than the
+// current one.
+//
+// The state, the block data and the head markers are written as a single batch
+// with the state first, so a crash can never leave the head pointing to a block
+// whose state or receipts are missing. If the block reorgs the chain, the data
+// batch is written before the canonical history is rewritten.
+func (self *BlockChain) WriteBlockWithState(block *types.Block, receipts types.Receipts, statedb *state.StateDB) (status WriteStatus, err error) {
 	self.wg.Add(1)
 	defer self.wg.Done()
 
@@ -849,38 +1035,104 @@ func (self *BlockChain) WriteBlock(block *types.Block) (status WriteStatus, err
 	localTd := self.GetTd(self.currentBlock.Hash(), self.currentBlock.NumberU64())
 	externTd := new(big.Int).Add(block.Difficulty(), ptd)
 
-	// Irrelevant of the canonical status, write the block itself to the database
-	if err := self.hc.WriteTd(block.Hash(), block.NumberU64(), externTd); err != nil {
-		log.Crit("Failed to write block total difficulty", "err", err)
+	// Hold off state pruning until the state is written and referenced by the block
+	self.statemu.RLock()
+	defer self.statemu.RUnlock()
+
+	// Irrelevant of the canonical status, gather the state and the block into a batch
+	batch := self.chainDb.NewBatch()
+	if _, err := statedb.CommitTo(batch, self.config.IsEIP158(block.Number())); err != nil {
+		return NonStatTy, err
 	}
-	if err := WriteBlock(self.chainDb, block); err != nil {
-		log.Crit("Failed to write block contents", "err", err)
+	if err := WriteTd(batch, block.Hash(), block.NumberU64(), externTd); err != nil {
+		return NonStatTy, err
+	}
+	if err := WriteBlock(batch, block); err != nil {
+		return NonStatTy, err
+	}
+	if err := WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
+		return NonStatTy, err
 	}
-
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
 	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
+	status = SideStatTy
 	if externTd.Cmp(localTd) > 0 || (externTd.Cmp(localTd) == 0 && mrand.Float64() < 0.5) {
-		// Reorganise the chain if the parent is not the head block
-		if block.ParentHash() != self.currentBlock.Hash() {
-			if err := self.reorg(self.currentBlock, block); err != nil {
-				return NonStatTy, err
-			}
-		}
-		self.insert(block) // Insert the block as the new head of the chain
 		status = CanonStatTy
-	} else {
-		status = SideStatTy
 	}
+	// Reorganise the chain if the parent is not the head block, gathering the
+	// canonical markers of the new chain into the batch too
+	var reorged func() error
+	if status == CanonStatTy && block.ParentHash() != self.currentBlock.Hash() {
+		if reorged, err = self.reorg(batch, self.currentBlock, block, receipts); err != nil {
+			return NonStatTy, err
+		}
+	}
+	updateHeads := false
+	if status == CanonStatTy {
+		updateHeads = self.writeHead(batch, block)
+	}
+	if err := batch.Write(); err != nil {
+		return NonStatTy, err
+	}
+	self.hc.tdCache.Add(block.Hash(), new(big.Int).Set(externTd))
 
+	if status == CanonStatTy {
+		self.setHead(block, updateHeads)
+	}
+	if reorged != nil {
+		if err := reorged(); err != nil {
+			return NonStatTy, err
+		}
+	}
 	self.futureBlocks.Remove(block.Hash())
 
-	return
+	return status, nil
 }
 
 // InsertChain will attempt to insert the given chain in to the canonical chain or, otherwise, create a fork. If an error is returned
 // it will return the index number of the failing block as well an error describing what went wrong (for possible errors see core/errors.go).
 func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
+	return self.insertChain(chain, false)
+}
+
+// InsertChainPriority inserts the given chain just like InsertChain, but makes
+// any batch import in progress yield to it at the next block boundary instead
+// of waiting for the entire batch. It is meant for the few blocks which should
+// reach the chain promptly, such as locally mined or freshly propagated ones.
+func (self *BlockChain) InsertChainPriority(chain types.Blocks) (int, error) {
+	self.priomu.Lock()
+	atomic.AddInt32(&self.priority, 1)
+	self.priomu.Unlock()
+
+	defer func() {
+		self.priomu.Lock()
+		if atomic.AddInt32(&self.priority, -1) == 0 {
+			self.priocond.Broadcast()
+		}
+		self.priomu.Unlock()
+	}()
+	return self.insertChain(chain, true)
+}
+
+// yieldToPriority releases the chain insertion lock until all the waiting
+// priority insertions are done. This method assumes that the chain insertion
+// lock is held.
+func (self *BlockChain) yieldToPriority() {
+	self.chainmu.Unlock()
+
+	self.priomu.Lock()
+	for atomic.LoadInt32(&self.priority) > 0 {
+		self.priocond.Wait()
+	}
+	self.priomu.Unlock()
+
+	self.chainmu.Lock()
+}
+
+// insertChain is the implementation of InsertChain and InsertChainPriority,
+// the latter never yielding to other priority insertions.
+func (self *BlockChain) insertChain(chain types.Blocks, priority bool) (int, error) {
 	// Do a sanity check that the provided chain is actually ordered and linked
 	for i := 1; i < len(chain); i++ {
 		if chain[i].NumberU64() != chain[i-1].NumberU64()+1 || chain[i].ParentHash() != chain[i-1].Hash() {
@@ -909,15 +1161,29 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		nonceChecked  = make([]bool, len(chain))
 	)
 
-	// Start the parallel nonce verifier.
-	nonceAbort, nonceResults := verifyNoncesFromBlocks(self.pow, chain)
+	// The import is a pipeline: the seals are verified and the transaction senders
+	// recovered concurrently, the blocks are executed and validated in order, and
+	// the lookup entries of the canonical ones are written in the background.
+	nonceAbort, nonceResults := verifyBlocks(self, self.engine, self.config, chain)
 	defer close(nonceAbort)
 
+	lookups := newLookupWriter(self.chainDb)
+	defer lookups.close()
+
 	for i, block := range chain {
 		if atomic.LoadInt32(&self.procInterrupt) == 1 {
 			log.Debug("Premature abort during blocks processing")
 			break
 		}
+		// Let any priority insertion preempt the remainder of a batch import
+		if !priority && i > 0 && atomic.LoadInt32(&self.priority) > 0 {
+			// The priority blocks may reorg, make sure no lookups are pending
+			if index, err := lookups.flush(); err != nil {
+				return index, err
+			}
+			log.Debug("Yielding chain insertion to priority blocks", "number", block.Number(), "remaining", len(chain)-i)
+			self.yieldToPriority()
+		}
 		bstart := time.Now()
 		// Wait for block i's nonce to be verified before processing
 		// its state transition.
@@ -930,11 +1196,16 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 			}
 		}
 
-		if BadHashes[block.Hash()] {
+		if self.hc.IsBadHash(block.Hash()) {
 			err := BadHashError(block.Hash())
 			self.reportBlock(block, nil, err)
 			return i, err
 		}
+		if self.hc.IsBadHash(block.ParentHash()) {
+			err := BadHashError(block.ParentHash())
+			self.reportBlock(block, nil, err)
+			return i, err
+		}
 		// Stage 1 validation of the block using the chain's validator
 		// interface.
 		err := self.Validator().ValidateBlock(block)
@@ -969,6 +1240,7 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		}
 		// Create a new statedb using the parent block and report an
 		// error if it fails.
+		self.limitStateCache(self.stateCache)
 		switch {
 		case i == 0:
 			err = self.stateCache.Reset(self.GetBlock(block.ParentHash(), block.NumberU64()-1).Root())
@@ -991,21 +1263,17 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 			self.reportBlock(block, receipts, err)
 			return i, err
 		}
-		// Write state changes to database
-		_, err = self.stateCache.Commit(self.config.IsEIP158(block.Number()))
-		if err != nil {
-			return i, err
-		}
-
 		// coalesce logs for later processing
 		coalescedLogs = append(coalescedLogs, logs...)
 
-		if err = WriteBlockReceipts(self.chainDb, block.Hash(), block.NumberU64(), receipts); err != nil {
-			return i, err
+		// A reorg may delete lookup entries, make sure none are still pending
+		if block.ParentHash() != self.CurrentBlock().Hash() {
+			if index, err := lookups.flush(); err != nil {
+				return index, err
+			}
 		}
-
-		// write the block to the chain and get the status
-		status, err := self.WriteBlock(block)
+		// write the block and its state to the chain and get the status
+		status, err := self.WriteBlockWithState(block, receipts, self.stateCache)
 		if err != nil {
 			return i, err
 		}
@@ -1016,24 +1284,12 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 				"txs", len(block.Transactions()), "gas", block.GasUsed(), "elapsed", common.PrettyDuration(time.Since(bstart)))
 
 			blockInsertTimer.UpdateSince(bstart)
+			atomic.StoreInt64(&self.insertLatency, int64(time.Since(bstart)))
 			events = append(events, ChainEvent{block, block.Hash(), logs})
 
-			// This puts transactions in a extra db for rpc
-			if err := WriteTransactions(self.chainDb, block); err != nil {
-				return i, err
-			}
-			// store the receipts
-			if err := WriteReceipts(self.chainDb, receipts); err != nil {
-				return i, err
-			}
-			// Write map map bloom filters
-			if err := WriteMipmapBloom(self.chainDb, block.NumberU64(), receipts); err != nil {
-				return i, err
-			}
-			// Write hash preimages
-			if err := WritePreimages(self.chainDb, block.NumberU64(), self.stateCache.Preimages()); err != nil {
-				return i, err
-			}
+			// This puts transactions, receipts, blooms and preimages in an extra db
+			// for rpc. The state cache allocates new preimages on reset.
+			lookups.schedule(&lookupTask{index: i, block: block, receipts: receipts, preimages: self.stateCache.Preimages()})
 		case SideStatTy:
 			log.Debug("Inserted forked block", "number", block.Number(), "hash", block.Hash(), "diff", block.Difficulty(), "elapsed",
 				common.PrettyDuration(time.Since(bstart)), "txs", len(block.Transactions()), "gas", block.GasUsed(), "uncles", len(block.Uncles()))
@@ -1048,11 +1304,78 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		stats.usedGas += usedGas.Uint64()
 		stats.report(chain, i)
 	}
+	// Wait for the lookup entries before announcing the blocks
+	if index, err := lookups.flush(); err != nil {
+		return index, err
+	}
+	// Make sure the new head reaches the disk if the import caught up with the chain
+	if head := self.CurrentBlock(); stats.processed > 0 && self.WritePolicy().nearHead(head) {
+		batch := self.chainDb.NewBatch()
+		if err := WriteHeadBlockHash(batch, head.Hash()); err != nil {
+			log.Crit("Failed to insert head block hash", "err", err)
+		}
+		if err := batch.WriteSync(); err != nil {
+			log.Crit("Failed to sync head block", "err", err)
+		}
+	}
+	// Garbage collect the historical states if it's due
+	self.maybePruneState()
+
 	go self.postChainEvents(events, coalescedLogs)
 
 	return 0, nil
 }
 
+// maybePruneState deletes the historical states if pruning is enabled and the
+// configured number of blocks were imported since the last pruning. This method
+// assumes that the chain insertion mutex is held.
+func (self *BlockChain) maybePruneState() {
+	config := self.Pruning()
+	if config == nil {
+		return
+	}
+	head := self.CurrentBlock().NumberU64()
+	if head < config.Retention || head < GetLastPruneNumber(self.chainDb)+config.Interval {
+		return
+	}
+	start := time.Now()
+	deleted, err := self.pruneState(head-config.Retention+1, head)
+	if err != nil {
+		log.Error("Failed to prune historical states", "number", head, "err", err)
+		return
+	}
+	WriteLastPruneNumber(self.chainDb, head)
+	log.Info("Pruned historical states", "number", head, "retention", config.Retention, "nodes", deleted, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// pruneState deletes every state trie node not reachable from the states of the
+// canonical blocks from..to or from the registered prune roots, returning the
+// number of nodes deleted.
+func (self *BlockChain) pruneState(from, to uint64) (int, error) {
+	self.statemu.Lock()
+	defer self.statemu.Unlock()
+
+	var states, tries []common.Hash
+	for number := from; number <= to; number++ {
+		if header := self.GetHeaderByNumber(number); header != nil {
+			states = append(states, header.Root)
+		}
+	}
+	self.procmu.RLock()
+	for _, roots := range self.pruneRoots {
+		tries = append(tries, roots()...)
+	}
+	self.procmu.RUnlock()
+
+	deleted, err := pruneState(self.chainDb, states, tries)
+
+	// Drop the cached tries, which may still reference deleted nodes
+	if statedb, err := state.New(self.CurrentBlock().Root(), self.chainDb); err == nil {
+		self.stateCache = statedb
+	}
+	return deleted, err
+}
+
 // insertStats tracks and reports on block insertion.
 type insertStats struct {
 	queued, processed, ignored int
@@ -1105,9 +1428,15 @@ func countTransactions(chain []*types.Block) (c int) {
 
 // reorgs takes two blocks, an old chain and a new chain and will reconstruct the blocks and inserts them
 // to be part of the new canonical chain and accumulates potential missing transactions and post an
-// event about them
-func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
+// event about them.
+//
+// The canonical markers, transactions and receipts of the new chain are added to
+// the batch, which also has to carry the new head block and its receipts. The
+// returned function finishes the reorganisation once the batch is written out,
+// updating the indexes and caches and posting the events.
+func (self *BlockChain) reorg(batch ethdb.Putter, oldBlock, newBlock *types.Block, headReceipts types.Receipts) (func() error, error) {
 	var (
+		head        = newBlock
 		newChain    types.Blocks
 		oldChain    types.Blocks
 		commonBlock *types.Block
@@ -1145,10 +1474,10 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		}
 	}
 	if oldBlock == nil {
-		return fmt.Errorf("Invalid old chain")
+		return nil, fmt.Errorf("Invalid old chain")
 	}
 	if newBlock == nil {
-		return fmt.Errorf("Invalid new chain")
+		return nil, fmt.Errorf("Invalid new chain")
 	}
 
 	for {
@@ -1164,10 +1493,10 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 
 		oldBlock, newBlock = self.GetBlock(oldBlock.ParentHash(), oldBlock.NumberU64()-1), self.GetBlock(newBlock.ParentHash(), newBlock.NumberU64()-1)
 		if oldBlock == nil {
-			return fmt.Errorf("Invalid old chain")
+			return nil, fmt.Errorf("Invalid old chain")
 		}
 		if newBlock == nil {
-			return fmt.Errorf("Invalid new chain")
+			return nil, fmt.Errorf("Invalid new chain")
 		}
 	}
 	// Ensure the user sees large reorgs
@@ -1181,53 +1510,80 @@ func (self *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	} else {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
-	var addedTxs types.Transactions
-	// insert blocks. Order does not matter. Last block will be written in ImportChain itself which creates the new head properly
-	for _, block := range newChain {
+	var (
+		addedTxs    types.Transactions
+		newReceipts = make([]types.Receipts, len(newChain))
+	)
+	// insert blocks. Order does not matter. The head block itself is written by the caller
+	for i, block := range newChain {
 		// insert the block in the canonical way, re-writing history
-		self.insert(block)
+		if err := WriteCanonicalHash(batch, block.Hash(), block.NumberU64()); err != nil {
+			return nil, err
+		}
 		// write canonical receipts and transactions
-		if err := WriteTransactions(self.chainDb, block); err != nil {
-			return err
+		receipts := headReceipts
+		if block.Hash() != head.Hash() {
+			receipts = GetBlockReceipts(self.chainDb, block.Hash(), block.NumberU64())
 		}
-		receipts := GetBlockReceipts(self.chainDb, block.Hash(), block.NumberU64())
-		// write receipts
-		if err := WriteReceipts(self.chainDb, receipts); err != nil {
-			return err
+		if err := WriteTransactions(batch, block); err != nil {
+			return nil, err
 		}
-		// Write map map bloom filters
-		if err := WriteMipmapBloom(self.chainDb, block.NumberU64(), receipts); err != nil {
-			return err
+		if err := WriteReceipts(batch, receipts); err != nil {
+			return nil, err
 		}
+		newReceipts[i] = receipts
 		addedTxs = append(addedTxs, block.Transactions()...)
 	}
-
-	// calculate the difference between deleted and added transactions
-	diff := types.TxDifference(deletedTxs, addedTxs)
-	// When transactions get deleted from the database that means the
-	// receipts that were created in the fork must also be deleted
-	for _, tx := range diff {
-		DeleteReceipt(self.chainDb, tx.Hash())
-		DeleteTransaction(self.chainDb, tx.Hash())
-	}
-	// Must be posted in a goroutine because of the transaction pool trying
-	// to acquire the chain manager lock
-	if len(diff) > 0 {
-		go self.eventMux.Post(RemovedTransactionEvent{diff})
-	}
-	if len(deletedLogs) > 0 {
-		go self.eventMux.Post(RemovedLogsEvent{deletedLogs})
-	}
-
-	if len(oldChain) > 0 {
-		go func() {
-			for _, block := range oldChain {
-				self.eventMux.Post(ChainSideEvent{Block: block})
+	return func() error {
+		for i, block := range newChain {
+			// Write map map bloom filters
+			if err := WriteMipmapBloom(self.chainDb, block.NumberU64(), newReceipts[i]); err != nil {
+				return err
 			}
-		}()
-	}
+			if err := WriteLogIndex(self.chainDb, block.NumberU64(), newReceipts[i]); err != nil {
+				return err
+			}
+		}
+		// calculate the difference between deleted and added transactions
+		diff := types.TxDifference(deletedTxs, addedTxs)
+		// When transactions get deleted from the database that means the
+		// receipts that were created in the fork must also be deleted
+		for _, tx := range diff {
+			DeleteReceipt(self.chainDb, tx.Hash())
+			DeleteTransaction(self.chainDb, tx.Hash())
+		}
+		// Evict the blocks dropped from the canonical chain from the caches, they are
+		// unlikely to be requested any more
+		for _, block := range oldChain {
+			self.bodyCache.Remove(block.Hash())
+			self.bodyRLPCache.Remove(block.Hash())
+			self.receiptsCache.Remove(block.Hash())
+		}
+		// Must be posted in a goroutine because of the transaction pool trying
+		// to acquire the chain manager lock
+		if len(diff) > 0 {
+			go self.eventMux.Post(RemovedTransactionEvent{diff})
+		}
+		if len(deletedLogs) > 0 {
+			go self.eventMux.Post(RemovedLogsEvent{deletedLogs})
+		}
 
-	return nil
+		if len(oldChain) > 0 {
+			go self.eventMux.Post(ReorgEvent{
+				OldChain:    oldChain,
+				NewChain:    newChain,
+				RevertedTxs: diff,
+				IncludedTxs: types.TxDifference(addedTxs, deletedTxs),
+			})
+			go func() {
+				for _, block := range oldChain {
+					self.eventMux.Post(ChainSideEvent{Block: block})
+				}
+			}()
+		}
+
+		return nil
+	}, nil
 }
 
 // postChainEvents iterates over the events generated by a chain insertion and
@@ -1283,6 +1639,35 @@ func (bc *BlockChain) addBadBlock(block *types.Block) {
 	bc.badBlocks.Add(block.Header().Hash(), block.Header())
 }
 
+// BanBlock marks the block with the given hash as invalid, so that neither it nor
+// its descendants are ever imported. The ban is persisted in the database. If the
+// block is part of the canonical chain, the chain is rewound to its parent.
+func (bc *BlockChain) BanBlock(hash common.Hash) error {
+	if hash == bc.genesisBlock.Hash() {
+		return ErrBanGenesis
+	}
+	if err := bc.hc.SetBanned(hash, true); err != nil {
+		return err
+	}
+	if number := bc.hc.GetBlockNumber(hash); number != missingNumber && GetCanonicalHash(bc.chainDb, number) == hash {
+		log.Warn("Banned block in canonical chain, rewinding", "number", number, "hash", hash)
+		return bc.SetHead(number - 1)
+	}
+	log.Warn("Banned block", "hash", hash)
+	return nil
+}
+
+// UnbanBlock lifts the ban of the block with the given hash. Blocks rejected
+// while it was banned have to be imported again.
+func (bc *BlockChain) UnbanBlock(hash common.Hash) error {
+	return bc.hc.SetBanned(hash, false)
+}
+
+// BannedBlocks returns the hashes of the blocks banned by the operator.
+func (bc *BlockChain) BannedBlocks() []common.Hash {
+	return bc.hc.BannedHashes()
+}
+
 // reportBlock logs a bad block error.
 func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error) {
 	bc.addBadBlock(block)
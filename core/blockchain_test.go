@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -42,7 +44,7 @@ func newTestBlockChain() *BlockChain {
 		Difficulty: big.NewInt(1),
 	}
 	gspec.MustCommit(db)
-	blockchain, err := NewBlockChain(db, gspec.Config, pow.FakePow{}, new(event.TypeMux), vm.Config{})
+	blockchain, err := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
 	if err != nil {
 		panic(err)
 	}
@@ -473,7 +475,7 @@ func testReorgBadHashes(t *testing.T, full bool) {
 	}
 
 	// Create a new BlockChain and check that it rolled back the state.
-	ncm, err := NewBlockChain(bc.chainDb, bc.config, pow.FakePow{}, new(event.TypeMux), vm.Config{})
+	ncm, err := NewBlockChain(bc.chainDb, bc.config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
 	if err != nil {
 		t.Fatalf("failed to create new chain manager: %v", err)
 	}
@@ -491,6 +493,50 @@ func testReorgBadHashes(t *testing.T, full bool) {
 	}
 }
 
+// Tests that banning a canonical block rewinds the chain to its parent, that the
+// block and its descendants are rejected afterwards, and that the ban persists.
+func TestBanBlock(t *testing.T) {
+	bc := newTestBlockChain()
+
+	blocks := makeBlockChainWithDiff(bc.genesisBlock, []int{1, 2, 3, 4}, 10)
+	if _, err := bc.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import blocks: %v", err)
+	}
+	if err := bc.BanBlock(bc.genesisBlock.Hash()); err != ErrBanGenesis {
+		t.Errorf("genesis ban error mismatch: have %v, want %v", err, ErrBanGenesis)
+	}
+	if err := bc.BanBlock(blocks[1].Hash()); err != nil {
+		t.Fatalf("failed to ban block: %v", err)
+	}
+	if bc.CurrentBlock().Hash() != blocks[0].Hash() {
+		t.Errorf("head mismatch after ban: have %x, want %x", bc.CurrentBlock().Hash(), blocks[0].Hash())
+	}
+	if _, err := bc.InsertChain(blocks[1:]); !IsBadHashError(err) {
+		t.Errorf("banned block import error mismatch: have %v, want BadHashError", err)
+	}
+	if _, err := bc.InsertChain(blocks[2:]); !IsBadHashError(err) {
+		t.Errorf("banned child import error mismatch: have %v, want BadHashError", err)
+	}
+	// Bans survive restarts, and can be lifted
+	ncm, err := NewBlockChain(bc.chainDb, bc.config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create new chain manager: %v", err)
+	}
+	ncm.SetValidator(bproc{})
+	if banned := ncm.BannedBlocks(); len(banned) != 1 || banned[0] != blocks[1].Hash() {
+		t.Fatalf("banned blocks mismatch: have %x, want [%x]", banned, blocks[1].Hash())
+	}
+	if err := ncm.UnbanBlock(blocks[1].Hash()); err != nil {
+		t.Fatalf("failed to unban block: %v", err)
+	}
+	if _, err := ncm.InsertChain(blocks[1:]); err != nil {
+		t.Fatalf("failed to import unbanned blocks: %v", err)
+	}
+	if ncm.CurrentBlock().Hash() != blocks[3].Hash() {
+		t.Errorf("head mismatch after unban: have %x, want %x", ncm.CurrentBlock().Hash(), blocks[3].Hash())
+	}
+}
+
 // Tests chain insertions in the face of one entity containing an invalid nonce.
 func TestHeadersInsertNonceError(t *testing.T) { testInsertNonceError(t, false) }
 func TestBlocksInsertNonceError(t *testing.T)  { testInsertNonceError(t, true) }
@@ -516,7 +562,7 @@ func testInsertNonceError(t *testing.T, full bool) {
 			failNum = blocks[failAt].NumberU64()
 			failHash = blocks[failAt].Hash()
 
-			blockchain.pow = failPow{failNum}
+			blockchain.engine = NewPowEngine(failPow{failNum})
 
 			failRes, err = blockchain.InsertChain(blocks)
 		} else {
@@ -526,8 +572,8 @@ func testInsertNonceError(t *testing.T, full bool) {
 			failNum = headers[failAt].Number.Uint64()
 			failHash = headers[failAt].Hash()
 
-			blockchain.pow = failPow{failNum}
-			blockchain.validator = NewBlockValidator(params.TestChainConfig, blockchain, failPow{failNum})
+			blockchain.engine = NewPowEngine(failPow{failNum})
+			blockchain.validator = NewBlockValidator(params.TestChainConfig, blockchain, NewPowEngine(failPow{failNum}))
 
 			failRes, err = blockchain.InsertHeaderChain(headers, 1)
 		}
@@ -594,7 +640,7 @@ func TestFastVsFullChains(t *testing.T) {
 	// Import the chain as an archive node for the comparison baseline
 	archiveDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(archiveDb)
-	archive, _ := NewBlockChain(archiveDb, gspec.Config, pow.FakePow{}, new(event.TypeMux), vm.Config{})
+	archive, _ := NewBlockChain(archiveDb, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
 
 	if n, err := archive.InsertChain(blocks); err != nil {
 		t.Fatalf("failed to process block %d: %v", n, err)
@@ -603,7 +649,7 @@ func TestFastVsFullChains(t *testing.T) {
 	// Fast import the chain as a non-archive node to test
 	fastDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(fastDb)
-	fast, _ := NewBlockChain(fastDb, gspec.Config, pow.FakePow{}, new(event.TypeMux), vm.Config{})
+	fast, _ := NewBlockChain(fastDb, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
 
 	headers := make([]*types.Header, len(blocks))
 	for i, block := range blocks {
@@ -680,7 +726,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	archiveDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(archiveDb)
 
-	archive, _ := NewBlockChain(archiveDb, gspec.Config, pow.FakePow{}, new(event.TypeMux), vm.Config{})
+	archive, _ := NewBlockChain(archiveDb, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
 
 	if n, err := archive.InsertChain(blocks); err != nil {
 		t.Fatalf("failed to process block %d: %v", n, err)
@@ -692,7 +738,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	// Import the chain as a non-archive node and ensure all pointers are updated
 	fastDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(fastDb)
-	fast, _ := NewBlockChain(fastDb, gspec.Config, pow.FakePow{}, new(event.TypeMux), vm.Config{})
+	fast, _ := NewBlockChain(fastDb, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
 
 	headers := make([]*types.Header, len(blocks))
 	for i, block := range blocks {
@@ -711,7 +757,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	// Import the chain as a light node and ensure all pointers are updated
 	lightDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(lightDb)
-	light, _ := NewBlockChain(lightDb, gspec.Config, pow.FakePow{}, new(event.TypeMux), vm.Config{})
+	light, _ := NewBlockChain(lightDb, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
 
 	if n, err := light.InsertHeaderChain(headers, 1); err != nil {
 		t.Fatalf("failed to insert header %d: %v", n, err)
@@ -721,6 +767,36 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	assert(t, "light", light, height/2, 0, 0)
 }
 
+// Tests that a receipt chain import failing midway still writes out the data of
+// the blocks imported before the failure.
+func TestReceiptChainPartialImport(t *testing.T) {
+	var (
+		gendb, _ = ethdb.NewMemDatabase()
+		db, _    = ethdb.NewMemDatabase()
+		gspec    = &Genesis{Config: params.TestChainConfig}
+		genesis  = gspec.MustCommit(gendb)
+	)
+	gspec.MustCommit(db)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, gendb, 8, nil)
+
+	chain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+	headers := make([]*types.Header, 4)
+	for i := range headers {
+		headers[i] = blocks[i].Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks, receipts); err == nil || n != 4 {
+		t.Fatalf("import result mismatch: have %d (%v), want 4 with unknown header", n, err)
+	}
+	for i, block := range blocks {
+		if have := chain.HasBlock(block.Hash()); have != (i < 4) {
+			t.Errorf("block %d: body presence mismatch: have %v, want %v", i, have, i < 4)
+		}
+	}
+}
+
 // Tests that chain reorganisations handle transaction removals and reinsertions.
 func TestChainTxReorgs(t *testing.T) {
 	var (
@@ -780,7 +856,7 @@ func TestChainTxReorgs(t *testing.T) {
 	})
 	// Import the chain. This runs all block validation rules.
 	evmux := &event.TypeMux{}
-	blockchain, _ := NewBlockChain(db, gspec.Config, pow.FakePow{}, evmux, vm.Config{})
+	blockchain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), evmux, vm.Config{})
 	if i, err := blockchain.InsertChain(chain); err != nil {
 		t.Fatalf("failed to insert original chain[%d]: %v", i, err)
 	}
@@ -851,7 +927,7 @@ func TestLogReorgs(t *testing.T) {
 	)
 
 	var evmux event.TypeMux
-	blockchain, _ := NewBlockChain(db, gspec.Config, pow.FakePow{}, &evmux, vm.Config{})
+	blockchain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), &evmux, vm.Config{})
 
 	subs := evmux.Subscribe(RemovedLogsEvent{})
 	chain, _ := GenerateChain(params.TestChainConfig, genesis, db, 2, func(i int, gen *BlockGen) {
@@ -889,7 +965,7 @@ func TestReorgSideEvent(t *testing.T) {
 	)
 
 	evmux := &event.TypeMux{}
-	blockchain, _ := NewBlockChain(db, gspec.Config, pow.FakePow{}, evmux, vm.Config{})
+	blockchain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), evmux, vm.Config{})
 
 	chain, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, gen *BlockGen) {})
 	if _, err := blockchain.InsertChain(chain); err != nil {
@@ -957,6 +1033,93 @@ done:
 
 }
 
+// Tests that reorganisations post an event with the dropped and the new blocks,
+// and the transactions reverted and included by them.
+func TestReorgEvent(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		gspec   = testGenesis(addr1, big.NewInt(10000000000000))
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	evmux := &event.TypeMux{}
+	blockchain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), evmux, vm.Config{})
+
+	// Both chains contain a transaction per block, the first blocks being identical
+	shared, _ := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), new(big.Int), nil), signer, key1)
+	chain, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, gen *BlockGen) {
+		tx := shared
+		if i > 0 {
+			tx, _ = types.SignTx(types.NewTransaction(gen.TxNonce(addr1), common.Address{0x01}, big.NewInt(1), big.NewInt(21000), new(big.Int), nil), signer, key1)
+		}
+		gen.AddTx(tx)
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	replacement, _ := GenerateChain(gspec.Config, genesis, db, 4, func(i int, gen *BlockGen) {
+		tx := shared
+		if i > 0 {
+			tx, _ = types.SignTx(types.NewTransaction(gen.TxNonce(addr1), common.Address{0x02}, big.NewInt(1), big.NewInt(21000), new(big.Int), nil), signer, key1)
+		}
+		gen.AddTx(tx)
+	})
+	subs := evmux.Subscribe(ReorgEvent{})
+	defer subs.Unsubscribe()
+
+	if _, err := blockchain.InsertChain(replacement); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var ev ReorgEvent
+	select {
+	case e := <-subs.Chan():
+		ev = e.Data.(ReorgEvent)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reorg event not posted")
+	}
+	// Both chains share their first block, everything above it must be swapped
+	if len(ev.OldChain) != len(chain)-1 {
+		t.Fatalf("old chain length mismatch: have %d, want %d", len(ev.OldChain), len(chain)-1)
+	}
+	for i, block := range ev.OldChain {
+		if want := chain[len(chain)-1-i]; block.Hash() != want.Hash() {
+			t.Errorf("old block %d mismatch: have %x, want %x", i, block.Hash(), want.Hash())
+		}
+	}
+	if len(ev.NewChain) == 0 || len(ev.NewChain) > len(replacement)-1 {
+		t.Fatalf("new chain length invalid: %d", len(ev.NewChain))
+	}
+	for i, block := range ev.NewChain {
+		if want := replacement[len(ev.NewChain)-i]; block.Hash() != want.Hash() {
+			t.Errorf("new block %d mismatch: have %x, want %x", i, block.Hash(), want.Hash())
+		}
+	}
+	// The shared transaction is neither reverted nor included
+	if len(ev.RevertedTxs) != 2 {
+		t.Errorf("reverted transaction count mismatch: have %d, want %d", len(ev.RevertedTxs), 2)
+	}
+	if len(ev.IncludedTxs) != len(ev.NewChain) {
+		t.Errorf("included transaction count mismatch: have %d, want %d", len(ev.IncludedTxs), len(ev.NewChain))
+	}
+	for _, tx := range append(ev.RevertedTxs, ev.IncludedTxs...) {
+		if tx.Hash() == shared.Hash() {
+			t.Errorf("shared transaction reported as changed")
+		}
+	}
+	for _, tx := range ev.RevertedTxs {
+		if *tx.To() != (common.Address{0x01}) {
+			t.Errorf("reverted transaction %x not from the old chain", tx.Hash())
+		}
+	}
+	for _, tx := range ev.IncludedTxs {
+		if *tx.To() != (common.Address{0x02}) {
+			t.Errorf("included transaction %x not from the new chain", tx.Hash())
+		}
+	}
+}
+
 // Tests if the canonical block can be fetched from the database during chain insertion.
 func TestCanonicalBlockRetrieval(t *testing.T) {
 	bc := newTestBlockChain()
@@ -1004,7 +1167,7 @@ func TestEIP155Transition(t *testing.T) {
 		mux     event.TypeMux
 	)
 
-	blockchain, _ := NewBlockChain(db, gspec.Config, pow.FakePow{}, &mux, vm.Config{})
+	blockchain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), &mux, vm.Config{})
 	blocks, _ := GenerateChain(gspec.Config, genesis, db, 4, func(i int, block *BlockGen) {
 		var (
 			tx      *types.Transaction
@@ -1110,7 +1273,7 @@ func TestEIP161AccountRemoval(t *testing.T) {
 		}
 		genesis       = gspec.MustCommit(db)
 		mux           event.TypeMux
-		blockchain, _ = NewBlockChain(db, gspec.Config, pow.FakePow{}, &mux, vm.Config{})
+		blockchain, _ = NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), &mux, vm.Config{})
 	)
 	blocks, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, block *BlockGen) {
 		var (
@@ -1155,3 +1318,388 @@ func TestEIP161AccountRemoval(t *testing.T) {
 		t.Error("account should not exist")
 	}
 }
+
+// Tests that a headers only database left behind by a light node is upgraded by
+// a full chain: the headers are kept and the blocks can be imported on top.
+func TestLightDatabaseUpgrade(t *testing.T) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		gspec    = &Genesis{Config: params.TestChainConfig}
+		genesis  = gspec.MustCommit(db)
+	)
+	gspec.MustCommit(gendb)
+	blocks, _ := GenerateChain(gspec.Config, genesis, gendb, 16, nil)
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	blockchain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+	if _, err := blockchain.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	blockchain.Stop()
+
+	// Strip the head block marker, as a light node never writes it
+	db.Delete(headBlockKey)
+
+	blockchain, _ = NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+	defer blockchain.Stop()
+
+	if head := blockchain.CurrentHeader().Number.Uint64(); head != 16 {
+		t.Fatalf("head header mismatch: have %d, want %d", head, 16)
+	}
+	if head := blockchain.CurrentBlock().NumberU64(); head != 0 {
+		t.Fatalf("head block mismatch: have %d, want %d", head, 0)
+	}
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import blocks: %v", err)
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head block mismatch: have %x, want %x", head, blocks[len(blocks)-1].Hash())
+	}
+}
+
+// Tests that the lookup entries of the imported blocks, written in the background
+// during the import, are all available once it returns, and that a reorg drops
+// those of the replaced blocks.
+func TestInsertChainLookups(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	makeChain := func(n int, value int64) []*types.Block {
+		gendb, _ := ethdb.NewMemDatabase()
+		gspec.MustCommit(gendb)
+
+		blocks, _ := GenerateChain(gspec.Config, genesis, gendb, n, func(i int, block *BlockGen) {
+			for j := 0; j < 4; j++ {
+				tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0xaa}, big.NewInt(value), big.NewInt(21000), new(big.Int), nil), signer, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				block.AddTx(tx)
+			}
+		})
+		return blocks
+	}
+	oldChain, newChain := makeChain(8, 1), makeChain(10, 2)
+
+	blockchain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+	defer blockchain.Stop()
+
+	check := func(blocks []*types.Block, exist bool) {
+		for _, block := range blocks {
+			for _, tx := range block.Transactions() {
+				if have, _, _, _ := GetTransaction(db, tx.Hash()); (have != nil) != exist {
+					t.Errorf("block %d: transaction %x presence mismatch: have %v, want %v", block.NumberU64(), tx.Hash(), have != nil, exist)
+				}
+				if have := GetReceipt(db, tx.Hash()); (have != nil) != exist {
+					t.Errorf("block %d: receipt %x presence mismatch: have %v, want %v", block.NumberU64(), tx.Hash(), have != nil, exist)
+				}
+			}
+		}
+	}
+	if _, err := blockchain.InsertChain(oldChain); err != nil {
+		t.Fatalf("failed to import old chain: %v", err)
+	}
+	check(oldChain, true)
+
+	if _, err := blockchain.InsertChain(newChain); err != nil {
+		t.Fatalf("failed to import new chain: %v", err)
+	}
+	check(oldChain, false)
+	check(newChain, true)
+}
+
+// pausingProcessor is a block processor recording the order of the processed
+// blocks and pausing the processing after the block with a given number.
+type pausingProcessor struct {
+	Processor
+
+	pause   uint64
+	reached chan struct{}
+	release chan struct{}
+
+	lock      sync.Mutex
+	processed []common.Hash
+}
+
+func (p *pausingProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, *big.Int, error) {
+	p.lock.Lock()
+	p.processed = append(p.processed, block.Hash())
+	p.lock.Unlock()
+
+	receipts, logs, gas, err := p.Processor.Process(block, statedb, cfg)
+	if block.NumberU64() == p.pause {
+		close(p.reached)
+		<-p.release
+	}
+	return receipts, logs, gas, err
+}
+
+// Tests that a priority insertion preempts a running batch import at the next
+// block boundary, after which the batch import resumes.
+func TestInsertChainPriority(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, db, 32, nil)
+	forks, _ := GenerateChain(gspec.Config, genesis, db, 1, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{0x01}) })
+
+	blockchain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+	defer blockchain.Stop()
+
+	processor := &pausingProcessor{Processor: blockchain.Processor(), pause: 10, reached: make(chan struct{}), release: make(chan struct{})}
+	blockchain.SetProcessor(processor)
+
+	// Start a batch import and wait for it to pause midway
+	batch := make(chan error, 1)
+	go func() {
+		_, err := blockchain.InsertChain(blocks)
+		batch <- err
+	}()
+	<-processor.reached
+
+	// Schedule a priority insertion and release the batch once it's waiting
+	prio := make(chan error, 1)
+	go func() {
+		_, err := blockchain.InsertChainPriority(forks)
+		prio <- err
+	}()
+	for atomic.LoadInt32(&blockchain.priority) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(processor.release)
+
+	if err := <-prio; err != nil {
+		t.Fatalf("failed to insert priority block: %v", err)
+	}
+	if err := <-batch; err != nil {
+		t.Fatalf("failed to import batch: %v", err)
+	}
+	// The priority block must have been processed right after the paused one
+	if len(processor.processed) != len(blocks)+1 {
+		t.Fatalf("processed block count mismatch: have %d, want %d", len(processor.processed), len(blocks)+1)
+	}
+	if processor.processed[10] != forks[0].Hash() {
+		t.Errorf("priority block not processed at the first block boundary")
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != blocks[len(blocks)-1].Hash() {
+		t.Errorf("head block mismatch: have %x, want %x", head, blocks[len(blocks)-1].Hash())
+	}
+}
+
+// crashingDatabase is a chain database simulating a crash after a given number
+// of write operations, silently dropping any write afterwards. Each put and each
+// batch write counts as a single operation.
+type crashingDatabase struct {
+	*ethdb.MemDatabase
+
+	lock    sync.Mutex
+	limit   int  // Number of write operations to let through
+	crashed bool // Whether any write was dropped
+}
+
+func (db *crashingDatabase) write() bool {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.limit == 0 {
+		db.crashed = true
+		return false
+	}
+	db.limit--
+	return true
+}
+
+func (db *crashingDatabase) Put(key []byte, value []byte) error {
+	if !db.write() {
+		return nil
+	}
+	return db.MemDatabase.Put(key, value)
+}
+
+func (db *crashingDatabase) Delete(key []byte) error {
+	if !db.write() {
+		return nil
+	}
+	return db.MemDatabase.Delete(key)
+}
+
+func (db *crashingDatabase) NewBatch() ethdb.Batch {
+	return &crashingBatch{Batch: db.MemDatabase.NewBatch(), db: db}
+}
+
+type crashingBatch struct {
+	ethdb.Batch
+	db *crashingDatabase
+}
+
+func (b *crashingBatch) Write() error {
+	if !b.db.write() {
+		return nil
+	}
+	return b.Batch.Write()
+}
+
+// Tests that a crash at any point of a chain import leaves the database in a
+// consistent state: every block is either written along with its state and
+// receipts or not at all, and the head always points to a complete block.
+func TestInsertChainCrashConsistency(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		signer  = types.HomesteadSigner{}
+	)
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(gendb)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, gendb, 4, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{byte(i)}, big.NewInt(1000), big.NewInt(21000), new(big.Int), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	})
+	for limit := 0; ; limit++ {
+		memdb, _ := ethdb.NewMemDatabase()
+		gspec.MustCommit(memdb)
+
+		db := &crashingDatabase{MemDatabase: memdb, limit: limit}
+		blockchain, err := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+		if err != nil {
+			t.Fatalf("limit %d: failed to create blockchain: %v", limit, err)
+		}
+		// Blocks building on dropped ones fail to import after the crash
+		if _, err := blockchain.InsertChain(blocks); err != nil && !db.crashed {
+			t.Fatalf("limit %d: failed to import chain: %v", limit, err)
+		}
+		blockchain.Stop()
+
+		// Every block must be either fully present or fully missing
+		for _, block := range blocks {
+			hash, number := block.Hash(), block.NumberU64()
+
+			header := GetHeader(memdb, hash, number) != nil
+			if body := GetBody(memdb, hash, number) != nil; body != header {
+				t.Errorf("limit %d, block %d: body presence mismatch: have %v, want %v", limit, number, body, header)
+			}
+			if td := GetTd(memdb, hash, number) != nil; td != header {
+				t.Errorf("limit %d, block %d: td presence mismatch: have %v, want %v", limit, number, td, header)
+			}
+			if receipts := len(GetBlockReceipts(memdb, hash, number)) != 0; receipts != header {
+				t.Errorf("limit %d, block %d: receipts presence mismatch: have %v, want %v", limit, number, receipts, header)
+			}
+			if _, err := state.New(block.Root(), memdb); (err == nil) != header {
+				t.Errorf("limit %d, block %d: state presence mismatch: have %v, want %v", limit, number, err == nil, header)
+			}
+		}
+		// The head block must be complete and canonical
+		head := GetHeadBlockHash(memdb)
+		if number := GetBlockNumber(memdb, head); number != missingNumber {
+			if GetCanonicalHash(memdb, number) != head {
+				t.Errorf("limit %d: head block #%d not canonical", limit, number)
+			}
+			if block := GetBlock(memdb, head, number); block == nil {
+				t.Errorf("limit %d: head block #%d missing", limit, number)
+			} else if _, err := state.New(block.Root(), memdb); err != nil {
+				t.Errorf("limit %d: head block #%d state missing: %v", limit, number, err)
+			}
+		} else if head != (common.Hash{}) {
+			t.Errorf("limit %d: head block %x unknown", limit, head)
+		}
+		if !db.crashed {
+			break
+		}
+	}
+}
+
+// Tests that a crash at any point of a chain reorganisation leaves a consistent
+// canonical chain behind: the head block is complete and all its ancestors are
+// marked canonical, either all on the old chain or all on the new one.
+func TestReorgCrashConsistency(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		signer  = types.HomesteadSigner{}
+	)
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(gendb)
+
+	generate := func(n int, coinbase byte) []*types.Block {
+		blocks, _ := GenerateChain(gspec.Config, genesis, gendb, n, func(i int, block *BlockGen) {
+			block.SetCoinbase(common.Address{coinbase})
+
+			tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{coinbase, byte(i)}, big.NewInt(1000), big.NewInt(21000), new(big.Int), nil), signer, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block.AddTx(tx)
+		})
+		return blocks
+	}
+	shortChain, longChain := generate(3, 1), generate(5, 2)
+
+	for limit := 0; ; limit++ {
+		memdb, _ := ethdb.NewMemDatabase()
+		gspec.MustCommit(memdb)
+
+		db := &crashingDatabase{MemDatabase: memdb, limit: 1 << 30}
+		blockchain, err := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+		if err != nil {
+			t.Fatalf("limit %d: failed to create blockchain: %v", limit, err)
+		}
+		if _, err := blockchain.InsertChain(shortChain); err != nil {
+			t.Fatalf("limit %d: failed to import short chain: %v", limit, err)
+		}
+		db.lock.Lock()
+		db.limit = limit
+		db.lock.Unlock()
+
+		if _, err := blockchain.InsertChain(longChain); err != nil && !db.crashed {
+			t.Fatalf("limit %d: failed to import long chain: %v", limit, err)
+		}
+		blockchain.Stop()
+
+		// Walk the chain back from the head, checking every block's markers
+		head := GetHeadBlockHash(memdb)
+		number := GetBlockNumber(memdb, head)
+		if number == missingNumber {
+			t.Fatalf("limit %d: head block %x unknown", limit, head)
+		}
+		if block := GetBlock(memdb, head, number); block == nil {
+			t.Errorf("limit %d: head block #%d missing", limit, number)
+		} else if _, err := state.New(block.Root(), memdb); err != nil {
+			t.Errorf("limit %d: head block #%d state missing: %v", limit, number, err)
+		}
+		for hash := head; ; number-- {
+			if canon := GetCanonicalHash(memdb, number); canon != hash {
+				t.Errorf("limit %d: block #%d not canonical: have %x, want %x", limit, number, canon, hash)
+			}
+			if number == 0 {
+				break
+			}
+			header := GetHeader(memdb, hash, number)
+			if header == nil {
+				t.Fatalf("limit %d: block #%d missing", limit, number)
+			}
+			hash = header.ParentHash
+		}
+		if !db.crashed {
+			if head != longChain[len(longChain)-1].Hash() {
+				t.Errorf("limit %d: head block mismatch: have %x, want %x", limit, head, longChain[len(longChain)-1].Hash())
+			}
+			break
+		}
+	}
+}
@@ -19,8 +19,9 @@ package core
 import (
 	"runtime"
 
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core/types"
-	"github.com/expanse-org/go-expanse/pow"
+	"github.com/expanse-org/go-expanse/params"
 )
 
 // nonceCheckResult contains the result of a nonce verification.
@@ -32,40 +33,64 @@ type nonceCheckResult struct {
 // verifyNoncesFromHeaders starts a concurrent header nonce verification,
 // returning a quit channel to abort the operations and a results channel
 // to retrieve the async verifications.
-func verifyNoncesFromHeaders(checker pow.PoW, headers []*types.Header) (chan<- struct{}, <-chan nonceCheckResult) {
-	items := make([]pow.Block, len(headers))
-	for i, header := range headers {
-		items[i] = types.NewBlockWithHeader(header)
-	}
-	return verifyNonces(checker, items)
+func verifyNoncesFromHeaders(chain consensus.ChainReader, checker consensus.Engine, headers []*types.Header) (chan<- struct{}, <-chan nonceCheckResult) {
+	return verifyNonces(chain, checker, headers)
 }
 
 // verifyNoncesFromBlocks starts a concurrent block nonce verification,
 // returning a quit channel to abort the operations and a results channel
 // to retrieve the async verifications.
-func verifyNoncesFromBlocks(checker pow.PoW, blocks []*types.Block) (chan<- struct{}, <-chan nonceCheckResult) {
-	items := make([]pow.Block, len(blocks))
+func verifyNoncesFromBlocks(chain consensus.ChainReader, checker consensus.Engine, blocks []*types.Block) (chan<- struct{}, <-chan nonceCheckResult) {
+	items := make([]*types.Header, len(blocks))
 	for i, block := range blocks {
-		items[i] = block
+		items[i] = block.Header()
 	}
-	return verifyNonces(checker, items)
+	return verifyNonces(chain, checker, items)
+}
+
+// verifyBlocks starts a concurrent verification of the stateless parts of the
+// blocks, returning a quit channel to abort the operations and a results channel
+// to retrieve the async verifications. Beside the seal, the senders of all the
+// transactions of valid blocks are recovered and cached, sparing the in order
+// block processing from the signature recoveries.
+func verifyBlocks(chain consensus.ChainReader, checker consensus.Engine, config *params.ChainConfig, blocks []*types.Block) (chan<- struct{}, <-chan nonceCheckResult) {
+	return verifyConcurrently(len(blocks), func(index int) bool {
+		block := blocks[index]
+		if checker.VerifySeal(chain, block.Header()) != nil {
+			return false
+		}
+		signer := types.MakeSigner(config, block.Number())
+		for _, tx := range block.Transactions() {
+			types.Sender(signer, tx) // Invalid signatures are reported during processing
+		}
+		return true
+	})
 }
 
 // verifyNonces starts a concurrent nonce verification, returning a quit channel
 // to abort the operations and a results channel to retrieve the async checks.
-func verifyNonces(checker pow.PoW, items []pow.Block) (chan<- struct{}, <-chan nonceCheckResult) {
+func verifyNonces(chain consensus.ChainReader, checker consensus.Engine, items []*types.Header) (chan<- struct{}, <-chan nonceCheckResult) {
+	return verifyConcurrently(len(items), func(index int) bool {
+		return checker.VerifySeal(chain, items[index]) == nil
+	})
+}
+
+// verifyConcurrently runs the given check on every index up to count, returning
+// a quit channel to abort the operations and a results channel to retrieve the
+// async checks, delivered in completion order.
+func verifyConcurrently(count int, check func(index int) bool) (chan<- struct{}, <-chan nonceCheckResult) {
 	// Spawn as many workers as allowed threads
 	workers := runtime.GOMAXPROCS(0)
-	if len(items) < workers {
-		workers = len(items)
+	if count < workers {
+		workers = count
 	}
 	// Create a task channel and spawn the verifiers
 	tasks := make(chan int, workers)
-	results := make(chan nonceCheckResult, len(items)) // Buffered to make sure all workers stop
+	results := make(chan nonceCheckResult, count) // Buffered to make sure all workers stop
 	for i := 0; i < workers; i++ {
 		go func() {
 			for index := range tasks {
-				results <- nonceCheckResult{index: index, valid: checker.Verify(items[index]) == nil}
+				results <- nonceCheckResult{index: index, valid: check(index)}
 			}
 		}()
 	}
@@ -74,7 +99,7 @@ func verifyNonces(checker pow.PoW, items []pow.Block) (chan<- struct{}, <-chan n
 	go func() {
 		defer close(tasks)
 
-		for i := range items {
+		for i := 0; i < count; i++ {
 			select {
 			case tasks <- i:
 				continue
@@ -24,8 +24,12 @@ import (
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
+	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
 	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
 	"github.com/expanse-org/go-expanse/params"
 	"github.com/expanse-org/go-expanse/pow"
 )
@@ -46,6 +50,9 @@ func (pow failPow) Verify(block pow.Block) error {
 	return nil
 }
 func (pow failPow) Hashrate() float64 { return 0 }
+func (pow failPow) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
 
 // delayedPow is a non-validating proof of work implementation, that returns true
 // from Verify for all blocks, but delays them the configured amount of time.
@@ -58,6 +65,9 @@ func (pow delayedPow) Search(pow.Block, <-chan struct{}) (uint64, []byte) {
 }
 func (pow delayedPow) Verify(block pow.Block) error { time.Sleep(pow.delay); return nil }
 func (pow delayedPow) Hashrate() float64            { return 0 }
+func (pow delayedPow) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
 
 // Tests that simple POW verification works, for both good and bad blocks.
 func TestPowVerification(t *testing.T) {
@@ -79,13 +89,13 @@ func TestPowVerification(t *testing.T) {
 
 				switch {
 				case full && valid:
-					_, results = verifyNoncesFromBlocks(pow.FakePow{}, []*types.Block{blocks[i]})
+					_, results = verifyNoncesFromBlocks(nil, NewPowEngine(pow.FakePow{}), []*types.Block{blocks[i]})
 				case full && !valid:
-					_, results = verifyNoncesFromBlocks(failPow{blocks[i].NumberU64()}, []*types.Block{blocks[i]})
+					_, results = verifyNoncesFromBlocks(nil, NewPowEngine(failPow{blocks[i].NumberU64()}), []*types.Block{blocks[i]})
 				case !full && valid:
-					_, results = verifyNoncesFromHeaders(pow.FakePow{}, []*types.Header{headers[i]})
+					_, results = verifyNoncesFromHeaders(nil, NewPowEngine(pow.FakePow{}), []*types.Header{headers[i]})
 				case !full && !valid:
-					_, results = verifyNoncesFromHeaders(failPow{headers[i].Number.Uint64()}, []*types.Header{headers[i]})
+					_, results = verifyNoncesFromHeaders(nil, NewPowEngine(failPow{headers[i].Number.Uint64()}), []*types.Header{headers[i]})
 				}
 				// Wait for the verification result
 				select {
@@ -138,13 +148,13 @@ func testPowConcurrentVerification(t *testing.T, threads int) {
 
 			switch {
 			case full && valid:
-				_, results = verifyNoncesFromBlocks(pow.FakePow{}, blocks)
+				_, results = verifyNoncesFromBlocks(nil, NewPowEngine(pow.FakePow{}), blocks)
 			case full && !valid:
-				_, results = verifyNoncesFromBlocks(failPow{uint64(len(blocks) - 1)}, blocks)
+				_, results = verifyNoncesFromBlocks(nil, NewPowEngine(failPow{uint64(len(blocks) - 1)}), blocks)
 			case !full && valid:
-				_, results = verifyNoncesFromHeaders(pow.FakePow{}, headers)
+				_, results = verifyNoncesFromHeaders(nil, NewPowEngine(pow.FakePow{}), headers)
 			case !full && !valid:
-				_, results = verifyNoncesFromHeaders(failPow{uint64(len(headers) - 1)}, headers)
+				_, results = verifyNoncesFromHeaders(nil, NewPowEngine(failPow{uint64(len(headers) - 1)}), headers)
 			}
 			// Wait for all the verification results
 			checks := make(map[int]bool)
@@ -208,9 +218,9 @@ func testPowConcurrentAbortion(t *testing.T, threads int) {
 
 		// Start the verifications and immediately abort
 		if full {
-			abort, results = verifyNoncesFromBlocks(delayedPow{time.Millisecond}, blocks)
+			abort, results = verifyNoncesFromBlocks(nil, NewPowEngine(delayedPow{time.Millisecond}), blocks)
 		} else {
-			abort, results = verifyNoncesFromHeaders(delayedPow{time.Millisecond}, headers)
+			abort, results = verifyNoncesFromHeaders(nil, NewPowEngine(delayedPow{time.Millisecond}), headers)
 		}
 		close(abort)
 
@@ -236,3 +246,110 @@ func testPowConcurrentAbortion(t *testing.T, threads int) {
 		}
 	}
 }
+
+// signerPow is a non-validating proof of work implementation, that reports a
+// fixed signer as the author of all blocks.
+type signerPow struct {
+	pow.FakePow
+	signer common.Address
+}
+
+func (pow signerPow) Author(header *types.Header) (common.Address, error) {
+	return pow.signer, nil
+}
+
+// Tests that block and uncle rewards are credited to the authors reported by the
+// engine instead of the coinbases of the headers.
+func TestAccumulateRewardsAuthor(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	var (
+		coinbase = common.Address{0x01}
+		signer   = common.Address{0x02}
+		header   = &types.Header{Number: big.NewInt(10), Coinbase: coinbase}
+		uncle    = &types.Header{Number: big.NewInt(9), Coinbase: coinbase}
+	)
+	if err := AccumulateRewards(NewPowEngine(signerPow{signer: signer}), statedb, header, []*types.Header{uncle}); err != nil {
+		t.Fatalf("failed to accumulate rewards: %v", err)
+	}
+	if balance := statedb.GetBalance(coinbase); balance.Sign() != 0 {
+		t.Errorf("coinbase balance mismatch: have %v, want 0", balance)
+	}
+	uncleReward := new(big.Int).Div(new(big.Int).Mul(big.NewInt(7), BlockReward), big8)
+	inclusionReward := new(big.Int).Div(BlockReward, big32)
+
+	want := new(big.Int).Add(BlockReward, uncleReward)
+	want.Add(want, inclusionReward)
+	if balance := statedb.GetBalance(signer); balance.Cmp(want) != 0 {
+		t.Errorf("signer balance mismatch: have %v, want %v", balance, want)
+	}
+}
+
+// Tests that the reported block issuance matches the rewards credited.
+func TestBlockIssuance(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	header := &types.Header{Number: big.NewInt(10), Coinbase: common.Address{0x01}}
+	uncles := []*types.Header{
+		{Number: big.NewInt(9), Coinbase: common.Address{0x02}},
+		{Number: big.NewInt(4), Coinbase: common.Address{0x03}},
+	}
+	if err := AccumulateRewards(NewPowEngine(pow.FakePow{}), statedb, header, uncles); err != nil {
+		t.Fatalf("failed to accumulate rewards: %v", err)
+	}
+	statedb.CommitTo(db, false)
+
+	if issued, credited := BlockIssuance(header, uncles), statedb.TotalBalance(); issued.Cmp(credited) != 0 {
+		t.Errorf("issuance mismatch: have %v, want %v", issued, credited)
+	}
+	// Ensure the reward breakdown matches the balances credited to each author
+	reward, uncleRewards := BlockRewards(header, uncles)
+	if credited := statedb.GetBalance(header.Coinbase); reward.Cmp(credited) != 0 {
+		t.Errorf("author reward mismatch: have %v, want %v", reward, credited)
+	}
+	for i, uncle := range uncles {
+		if credited := statedb.GetBalance(uncle.Coinbase); uncleRewards[i].Cmp(credited) != 0 {
+			t.Errorf("uncle %d reward mismatch: have %v, want %v", i, uncleRewards[i], credited)
+		}
+	}
+}
+
+// rejectEngine is a proof-of-work engine rejecting the consensus fields of all
+// headers above a given number.
+type rejectEngine struct {
+	*PowEngine
+	limit uint64
+}
+
+var errRejected = errors.New("rejected by engine")
+
+func (e rejectEngine) VerifyHeader(chain consensus.ChainReader, header, parent *types.Header) error {
+	if header.Number.Uint64() > e.limit {
+		return errRejected
+	}
+	return e.PowEngine.VerifyHeader(chain, header, parent)
+}
+
+// Tests that the chain delegates the verification of the consensus fields to
+// a custom engine.
+func TestCustomEngineVerification(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		genesis = GenesisBlockForTesting(db, common.Address{}, new(big.Int))
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, db, 4, nil)
+
+	chain, _ := NewBlockChain(db, params.TestChainConfig, rejectEngine{NewPowEngine(pow.FakePow{}), 2}, new(event.TypeMux), vm.Config{})
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != errRejected {
+		t.Fatalf("insert error mismatch: have %v, want %v", err, errRejected)
+	} else if n != 2 {
+		t.Fatalf("failed block index mismatch: have %d, want %d", n, 2)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 2 {
+		t.Errorf("head mismatch: have %d, want %d", head, 2)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"sync"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/ethdb"
 	"github.com/expanse-org/go-expanse/log"
@@ -39,6 +40,10 @@ var (
 	headBlockKey  = []byte("LastBlock")
 	headFastKey   = []byte("LastFast")
 
+	bannedHashesKey = []byte("BannedBlockHashes") // RLP list of the block hashes banned by the operator
+	lastPruneKey    = []byte("LastPruneNumber")   // Number of the head block at the last state pruning (uint64 big endian)
+	snapshotKey     = []byte("SnapshotManifest")  // RLP of the manifest of the state snapshot served to peers
+
 	headerPrefix        = []byte("h")   // headerPrefix + num (uint64 big endian) + hash -> header
 	tdSuffix            = []byte("t")   // headerPrefix + num (uint64 big endian) + hash + tdSuffix -> td
 	numSuffix           = []byte("n")   // headerPrefix + num (uint64 big endian) + numSuffix -> hash
@@ -55,6 +60,8 @@ var (
 
 	configPrefix = []byte("expanse-config-") // config prefix for the db
 
+	snapshotChunkPrefix = []byte("snapshot-chunk-") // snapshotChunkPrefix + hash -> state snapshot chunk
+
 	// used by old (non-sequential keys) db, now only used for conversion
 	oldBlockPrefix         = []byte("block-")
 	oldHeaderSuffix        = []byte("-header")
@@ -299,7 +306,7 @@ func GetReceipt(db ethdb.Database, hash common.Hash) *types.Receipt {
 }
 
 // WriteCanonicalHash stores the canonical hash for the given block number.
-func WriteCanonicalHash(db ethdb.Database, hash common.Hash, number uint64) error {
+func WriteCanonicalHash(db ethdb.Putter, hash common.Hash, number uint64) error {
 	key := append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...)
 	if err := db.Put(key, hash.Bytes()); err != nil {
 		log.Crit("Failed to store number to hash mapping", "err", err)
@@ -308,7 +315,7 @@ func WriteCanonicalHash(db ethdb.Database, hash common.Hash, number uint64) erro
 }
 
 // WriteHeadHeaderHash stores the head header's hash.
-func WriteHeadHeaderHash(db ethdb.Database, hash common.Hash) error {
+func WriteHeadHeaderHash(db ethdb.Putter, hash common.Hash) error {
 	if err := db.Put(headHeaderKey, hash.Bytes()); err != nil {
 		log.Crit("Failed to store last header's hash", "err", err)
 	}
@@ -316,7 +323,7 @@ func WriteHeadHeaderHash(db ethdb.Database, hash common.Hash) error {
 }
 
 // WriteHeadBlockHash stores the head block's hash.
-func WriteHeadBlockHash(db ethdb.Database, hash common.Hash) error {
+func WriteHeadBlockHash(db ethdb.Putter, hash common.Hash) error {
 	if err := db.Put(headBlockKey, hash.Bytes()); err != nil {
 		log.Crit("Failed to store last block's hash", "err", err)
 	}
@@ -324,7 +331,7 @@ func WriteHeadBlockHash(db ethdb.Database, hash common.Hash) error {
 }
 
 // WriteHeadFastBlockHash stores the fast head block's hash.
-func WriteHeadFastBlockHash(db ethdb.Database, hash common.Hash) error {
+func WriteHeadFastBlockHash(db ethdb.Putter, hash common.Hash) error {
 	if err := db.Put(headFastKey, hash.Bytes()); err != nil {
 		log.Crit("Failed to store last fast block's hash", "err", err)
 	}
@@ -332,7 +339,7 @@ func WriteHeadFastBlockHash(db ethdb.Database, hash common.Hash) error {
 }
 
 // WriteHeader serializes a block header into the database.
-func WriteHeader(db ethdb.Database, header *types.Header) error {
+func WriteHeader(db ethdb.Putter, header *types.Header) error {
 	data, err := rlp.EncodeToBytes(header)
 	if err != nil {
 		return err
@@ -352,7 +359,7 @@ func WriteHeader(db ethdb.Database, header *types.Header) error {
 }
 
 // WriteBody serializes the body of a block into the database.
-func WriteBody(db ethdb.Database, hash common.Hash, number uint64, body *types.Body) error {
+func WriteBody(db ethdb.Putter, hash common.Hash, number uint64, body *types.Body) error {
 	data, err := rlp.EncodeToBytes(body)
 	if err != nil {
 		return err
@@ -361,7 +368,7 @@ func WriteBody(db ethdb.Database, hash common.Hash, number uint64, body *types.B
 }
 
 // WriteBodyRLP writes a serialized body of a block into the database.
-func WriteBodyRLP(db ethdb.Database, hash common.Hash, number uint64, rlp rlp.RawValue) error {
+func WriteBodyRLP(db ethdb.Putter, hash common.Hash, number uint64, rlp rlp.RawValue) error {
 	key := append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 	if err := db.Put(key, rlp); err != nil {
 		log.Crit("Failed to store block body", "err", err)
@@ -370,7 +377,7 @@ func WriteBodyRLP(db ethdb.Database, hash common.Hash, number uint64, rlp rlp.Ra
 }
 
 // WriteTd serializes the total difficulty of a block into the database.
-func WriteTd(db ethdb.Database, hash common.Hash, number uint64, td *big.Int) error {
+func WriteTd(db ethdb.Putter, hash common.Hash, number uint64, td *big.Int) error {
 	data, err := rlp.EncodeToBytes(td)
 	if err != nil {
 		return err
@@ -383,7 +390,7 @@ func WriteTd(db ethdb.Database, hash common.Hash, number uint64, td *big.Int) er
 }
 
 // WriteBlock serializes a block into the database, header and body separately.
-func WriteBlock(db ethdb.Database, block *types.Block) error {
+func WriteBlock(db ethdb.Putter, block *types.Block) error {
 	// Store the body first to retain database consistency
 	if err := WriteBody(db, block.Hash(), block.NumberU64(), block.Body()); err != nil {
 		return err
@@ -398,7 +405,7 @@ func WriteBlock(db ethdb.Database, block *types.Block) error {
 // WriteBlockReceipts stores all the transaction receipts belonging to a block
 // as a single receipt slice. This is used during chain reorganisations for
 // rescheduling dropped transactions.
-func WriteBlockReceipts(db ethdb.Database, hash common.Hash, number uint64, receipts types.Receipts) error {
+func WriteBlockReceipts(db ethdb.Putter, hash common.Hash, number uint64, receipts types.Receipts) error {
 	// Convert the receipts into their storage form and serialize them
 	storageReceipts := make([]*types.ReceiptForStorage, len(receipts))
 	for i, receipt := range receipts {
@@ -419,10 +426,8 @@ func WriteBlockReceipts(db ethdb.Database, hash common.Hash, number uint64, rece
 // WriteTransactions stores the transactions associated with a specific block
 // into the given database. Beside writing the transaction, the function also
 // stores a metadata entry along with the transaction, detailing the position
-// of this within the blockchain.
-func WriteTransactions(db ethdb.Database, block *types.Block) error {
-	batch := db.NewBatch()
-
+// of this within the blockchain. Pass in a batch to store the entries atomically.
+func WriteTransactions(db ethdb.Putter, block *types.Block) error {
 	// Iterate over each transaction and encode it with its metadata
 	for i, tx := range block.Transactions() {
 		// Encode and queue up the transaction for storage
@@ -430,7 +435,7 @@ func WriteTransactions(db ethdb.Database, block *types.Block) error {
 		if err != nil {
 			return err
 		}
-		if err = batch.Put(tx.Hash().Bytes(), data); err != nil {
+		if err = db.Put(tx.Hash().Bytes(), data); err != nil {
 			return err
 		}
 		// Encode and queue up the transaction metadata for storage
@@ -447,14 +452,10 @@ func WriteTransactions(db ethdb.Database, block *types.Block) error {
 		if err != nil {
 			return err
 		}
-		if err := batch.Put(append(tx.Hash().Bytes(), txMetaSuffix...), data); err != nil {
+		if err := db.Put(append(tx.Hash().Bytes(), txMetaSuffix...), data); err != nil {
 			return err
 		}
 	}
-	// Write the scheduled data into the database
-	if err := batch.Write(); err != nil {
-		log.Crit("Failed to store transactions", "err", err)
-	}
 	return nil
 }
 
@@ -468,10 +469,9 @@ func WriteReceipt(db ethdb.Database, receipt *types.Receipt) error {
 	return db.Put(append(receiptsPrefix, receipt.TxHash.Bytes()...), data)
 }
 
-// WriteReceipts stores a batch of transaction receipts into the database.
-func WriteReceipts(db ethdb.Database, receipts types.Receipts) error {
-	batch := db.NewBatch()
-
+// WriteReceipts stores a batch of transaction receipts into the database. Pass
+// in a batch to store the receipts atomically.
+func WriteReceipts(db ethdb.Putter, receipts types.Receipts) error {
 	// Iterate over all the receipts and queue them for database injection
 	for _, receipt := range receipts {
 		storageReceipt := (*types.ReceiptForStorage)(receipt)
@@ -479,14 +479,10 @@ func WriteReceipts(db ethdb.Database, receipts types.Receipts) error {
 		if err != nil {
 			return err
 		}
-		if err := batch.Put(append(receiptsPrefix, receipt.TxHash.Bytes()...), data); err != nil {
+		if err := db.Put(append(receiptsPrefix, receipt.TxHash.Bytes()...), data); err != nil {
 			return err
 		}
 	}
-	// Write the scheduled data into the database
-	if err := batch.Write(); err != nil {
-		log.Crit("Failed to store receipts", "err", err)
-	}
 	return nil
 }
 
@@ -618,6 +614,99 @@ func WriteBlockChainVersion(db ethdb.Database, vsn int) {
 	db.Put([]byte("BlockchainVersion"), enc)
 }
 
+// GetBannedHashes retrieves the block hashes banned by the operator.
+func GetBannedHashes(db ethdb.Database) []common.Hash {
+	var hashes []common.Hash
+	enc, _ := db.Get(bannedHashesKey)
+	if len(enc) == 0 {
+		return nil
+	}
+	if err := rlp.DecodeBytes(enc, &hashes); err != nil {
+		log.Error("Invalid banned block hashes RLP", "err", err)
+		return nil
+	}
+	return hashes
+}
+
+// WriteBannedHashes stores the block hashes banned by the operator.
+func WriteBannedHashes(db ethdb.Putter, hashes []common.Hash) error {
+	enc, err := rlp.EncodeToBytes(hashes)
+	if err != nil {
+		return err
+	}
+	if err := db.Put(bannedHashesKey, enc); err != nil {
+		log.Crit("Failed to store banned block hashes", "err", err)
+	}
+	return nil
+}
+
+// GetLastPruneNumber retrieves the number of the head block at the time the
+// historical states were last pruned, or zero if they never were.
+func GetLastPruneNumber(db ethdb.Database) uint64 {
+	data, _ := db.Get(lastPruneKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteLastPruneNumber stores the number of the head block at the time the
+// historical states were last pruned.
+func WriteLastPruneNumber(db ethdb.Putter, number uint64) error {
+	if err := db.Put(lastPruneKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store last prune number", "err", err)
+	}
+	return nil
+}
+
+// GetSnapshotManifest retrieves the manifest of the state snapshot served to
+// peers, or nil if no snapshot was generated yet.
+func GetSnapshotManifest(db ethdb.Database) *state.SnapshotManifest {
+	enc, _ := db.Get(snapshotKey)
+	if len(enc) == 0 {
+		return nil
+	}
+	manifest := new(state.SnapshotManifest)
+	if err := rlp.DecodeBytes(enc, manifest); err != nil {
+		log.Error("Invalid snapshot manifest RLP", "err", err)
+		return nil
+	}
+	return manifest
+}
+
+// WriteSnapshotManifest stores the manifest of the state snapshot served to
+// peers.
+func WriteSnapshotManifest(db ethdb.Putter, manifest *state.SnapshotManifest) error {
+	enc, err := rlp.EncodeToBytes(manifest)
+	if err != nil {
+		return err
+	}
+	if err := db.Put(snapshotKey, enc); err != nil {
+		log.Crit("Failed to store snapshot manifest", "err", err)
+	}
+	return nil
+}
+
+// GetSnapshotChunk retrieves a chunk of the served state snapshot by its hash,
+// or nil if it is not found.
+func GetSnapshotChunk(db ethdb.Database, hash common.Hash) []byte {
+	data, _ := db.Get(append(snapshotChunkPrefix, hash.Bytes()...))
+	return data
+}
+
+// WriteSnapshotChunk stores a chunk of the served state snapshot.
+func WriteSnapshotChunk(db ethdb.Putter, hash common.Hash, chunk []byte) error {
+	if err := db.Put(append(snapshotChunkPrefix, hash.Bytes()...), chunk); err != nil {
+		log.Crit("Failed to store snapshot chunk", "err", err)
+	}
+	return nil
+}
+
+// DeleteSnapshotChunk removes a chunk of a no longer served state snapshot.
+func DeleteSnapshotChunk(db ethdb.Database, hash common.Hash) {
+	db.Delete(append(snapshotChunkPrefix, hash.Bytes()...))
+}
+
 // WriteChainConfig writes the chain config settings to the database.
 func WriteChainConfig(db ethdb.Database, hash common.Hash, cfg *params.ChainConfig) error {
 	// short circuit and ignore if nil config. GetChainConfig
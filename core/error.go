@@ -29,6 +29,7 @@ var (
 	BlockFutureErr   = errors.New("block time is in the future")
 	BlockTSTooBigErr = errors.New("block time too big")
 	BlockEqualTSErr  = errors.New("block time stamp equal to previous")
+	ErrBanGenesis    = errors.New("genesis block can't be banned")
 )
 
 // Parent error. In case a parent is unknown this error will be thrown
@@ -189,6 +190,8 @@ func IsBadHashError(err error) bool {
 	return ok
 }
 
+// GasLimitErr is returned if the gas left in a block's gas pool doesn't cover
+// the gas limit of a transaction.
 type GasLimitErr struct {
 	Have, Want *big.Int
 }
@@ -199,5 +202,36 @@ func IsGasLimitErr(err error) bool {
 }
 
 func (err *GasLimitErr) Error() string {
-	return fmt.Sprintf("GasLimit reached. Have %d gas, transaction requires %d", err.Have, err.Want)
+	return fmt.Sprintf("gas limit reached: have %d gas, transaction requires %d", err.Have, err.Want)
+}
+
+// IntrinsicGasErr is returned if the gas limit of a transaction doesn't cover
+// the intrinsic gas of its payload.
+type IntrinsicGasErr struct {
+	Have, Want *big.Int
+}
+
+func IsIntrinsicGasErr(err error) bool {
+	_, ok := err.(*IntrinsicGasErr)
+	return ok
+}
+
+func (err *IntrinsicGasErr) Error() string {
+	return fmt.Sprintf("intrinsic gas too low: have %d, want %d", err.Have, err.Want)
+}
+
+// InsufficientFundsErr is returned if the sender of a transaction can't pay
+// for the gas limit at the gas price plus the transferred value.
+type InsufficientFundsErr struct {
+	Address    common.Address
+	Have, Want *big.Int
+}
+
+func IsInsufficientFundsErr(err error) bool {
+	_, ok := err.(*InsufficientFundsErr)
+	return ok
+}
+
+func (err *InsufficientFundsErr) Error() string {
+	return fmt.Sprintf("insufficient funds for gas * price + value: address %x have %d want %d", err.Address, err.Have, err.Want)
 }
@@ -26,6 +26,32 @@ import (
 // TxPreEvent is posted when a transaction enters the transaction pool.
 type TxPreEvent struct{ Tx *types.Transaction }
 
+// TxQueuedEvent is posted when a transaction enters the non-executable queue of
+// the transaction pool, waiting for its nonce gap to be filled.
+type TxQueuedEvent struct{ Tx *types.Transaction }
+
+// TxPromotedEvent is posted when a transaction becomes executable in the
+// transaction pool.
+type TxPromotedEvent struct{ Tx *types.Transaction }
+
+// TxDropReason enumerates why the transaction pool dropped a transaction.
+type TxDropReason string
+
+const (
+	TxDropUnderpriced TxDropReason = "underpriced" // Lost to a better priced transaction with the same nonce
+	TxDropReplaced    TxDropReason = "replaced"    // Replaced by a better priced transaction with the same nonce
+	TxDropExpired     TxDropReason = "expired"     // Queued behind a nonce gap for too long
+	TxDropInvalidated TxDropReason = "invalidated" // Became unpayable after a new head or reorg
+	TxDropOverflow    TxDropReason = "overflow"    // Evicted to keep the pool within its limits
+)
+
+// TxDroppedEvent is posted when a transaction is dropped from the transaction
+// pool without having been included in a block.
+type TxDroppedEvent struct {
+	Tx     *types.Transaction
+	Reason TxDropReason
+}
+
 // TxPostEvent is posted when a transaction has been processed.
 type TxPostEvent struct{ Tx *types.Transaction }
 
@@ -40,7 +66,8 @@ type PendingStateEvent struct{}
 // NewMinedBlockEvent is posted when a block has been imported.
 type NewMinedBlockEvent struct{ Block *types.Block }
 
-// RemovedTransactionEvent is posted when a reorg happens
+// RemovedTransactionEvent is posted when a reorg happens. Its transactions are
+// the RevertedTxs of the ReorgEvent posted alongside it.
 type RemovedTransactionEvent struct{ Txs types.Transactions }
 
 // RemovedLogEvent is posted when a reorg happens
@@ -52,6 +79,18 @@ type ChainSplitEvent struct {
 	Logs  []*types.Log
 }
 
+// ReorgEvent is posted when the canonical chain is reorganised. OldChain holds
+// the blocks dropped from the canonical chain and NewChain the ones replacing
+// them, both ordered from the new head down to the common ancestor. RevertedTxs
+// are the transactions of the dropped blocks which aren't part of the new ones,
+// IncludedTxs the transactions of the new blocks which weren't in the old ones.
+type ReorgEvent struct {
+	OldChain    []*types.Block
+	NewChain    []*types.Block
+	RevertedTxs types.Transactions
+	IncludedTxs types.Transactions
+}
+
 type ChainEvent struct {
 	Block *types.Block
 	Hash  common.Hash
@@ -30,15 +30,20 @@ type HeaderFetcher interface {
 	GetHeader(common.Hash, uint64) *types.Header
 }
 
-// NewEVMContext creates a new context for use in the EVM.
-func NewEVMContext(msg Message, header *types.Header, chain HeaderFetcher) vm.Context {
+// NewEVMContext creates a new context for use in the EVM. The author of the block
+// defaults to its coinbase if nil.
+func NewEVMContext(msg Message, header *types.Header, chain HeaderFetcher, author *common.Address) vm.Context {
+	beneficiary := header.Coinbase
+	if author != nil {
+		beneficiary = *author
+	}
 	return vm.Context{
 		CanTransfer: CanTransfer,
 		Transfer:    Transfer,
 		GetHash:     GetHashFn(header, chain),
 
 		Origin:      msg.From(),
-		Coinbase:    header.Coinbase,
+		Coinbase:    beneficiary,
 		BlockNumber: new(big.Int).Set(header.Number),
 		Time:        new(big.Int).Set(header.Time),
 		Difficulty:  new(big.Int).Set(header.Difficulty),
@@ -0,0 +1,115 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+// ErrFrozenAddress is returned if a transaction is sent from or to an address
+// frozen by the governance of a private network.
+var ErrFrozenAddress = errors.New("address frozen")
+
+// Markers stored in the registry account for the addresses whose freeze state
+// was changed by an update. Addresses without a marker keep the state given by
+// the chain configuration.
+var (
+	frozenMarker   = common.BigToHash(big.NewInt(1))
+	unfrozenMarker = common.BigToHash(big.NewInt(2))
+)
+
+// FreezeUpdate is a change of the address freeze list. Updates are carried on
+// chain as the RLP encoded payload of transactions sent by the governance
+// account to the registry account, the transaction signature authorising them.
+type FreezeUpdate struct {
+	Freeze   []common.Address
+	Unfreeze []common.Address
+}
+
+// IsFrozen returns whether the given address is frozen in the given state.
+func IsFrozen(config *params.ChainConfig, statedb *state.StateDB, addr common.Address) bool {
+	if config.Freeze == nil {
+		return false
+	}
+	switch statedb.GetState(config.Freeze.Registry, addr.Hash()) {
+	case frozenMarker:
+		return true
+	case unfrozenMarker:
+		return false
+	}
+	for _, frozen := range config.Freeze.Frozen {
+		if frozen == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckFreeze returns ErrFrozenAddress if a transaction between the given
+// accounts is invalid in the block with the given number. The to address is nil
+// for contract creations.
+func CheckFreeze(config *params.ChainConfig, statedb *state.StateDB, number *big.Int, from common.Address, to *common.Address) error {
+	if !config.IsFreeze(number) {
+		return nil
+	}
+	return checkFreeze(config, statedb, from, to)
+}
+
+// checkFreeze returns ErrFrozenAddress if any of the given accounts is frozen,
+// regardless of the activation block of the list.
+func checkFreeze(config *params.ChainConfig, statedb *state.StateDB, from common.Address, to *common.Address) error {
+	if IsFrozen(config, statedb, from) {
+		return ErrFrozenAddress
+	}
+	if to != nil && IsFrozen(config, statedb, *to) {
+		return ErrFrozenAddress
+	}
+	return nil
+}
+
+// applyFreezeUpdate records the freeze list update carried by a transaction in
+// the registry account, if the transaction was sent by the governance account
+// to the registry in a block enforcing the list. Malformed updates are ignored,
+// leaving a plain transfer.
+func applyFreezeUpdate(config *params.ChainConfig, statedb *state.StateDB, number *big.Int, from common.Address, to *common.Address, data []byte) {
+	if !config.IsFreeze(number) || from != config.Freeze.Governance || to == nil || *to != config.Freeze.Registry {
+		return
+	}
+	update := new(FreezeUpdate)
+	if err := rlp.DecodeBytes(data, update); err != nil {
+		log.Debug("Ignored malformed address freeze update", "number", number, "err", err)
+		return
+	}
+	// Keep the registry from being deleted as an empty account
+	if statedb.GetNonce(config.Freeze.Registry) == 0 {
+		statedb.SetNonce(config.Freeze.Registry, 1)
+	}
+	for _, addr := range update.Freeze {
+		statedb.SetState(config.Freeze.Registry, addr.Hash(), frozenMarker)
+	}
+	for _, addr := range update.Unfreeze {
+		statedb.SetState(config.Freeze.Registry, addr.Hash(), unfrozenMarker)
+	}
+	log.Debug("Applied address freeze update", "number", number, "frozen", len(update.Freeze), "unfrozen", len(update.Unfreeze))
+}
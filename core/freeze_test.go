@@ -0,0 +1,154 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+// Tests that freeze list updates are carried on chain, taking effect only if sent
+// by the governance account to the registry.
+func TestFreezeListUpdates(t *testing.T) {
+	var (
+		db, _         = ethdb.NewMemDatabase()
+		governance, _ = crypto.GenerateKey()
+		intruder, _   = crypto.GenerateKey()
+		a, b, c       = common.Address{0x0a}, common.Address{0x0b}, common.Address{0x0c}
+		registry      = common.Address{0xee}
+		gspec         = &Genesis{
+			Config: &params.ChainConfig{
+				ChainId:        big.NewInt(1),
+				HomesteadBlock: new(big.Int),
+				EIP158Block:    new(big.Int),
+				Freeze: &params.FreezeConfig{
+					Block:      big.NewInt(0),
+					Governance: crypto.PubkeyToAddress(governance.PublicKey),
+					Registry:   registry,
+					Frozen:     []common.Address{a},
+				},
+			},
+			Alloc: GenesisAlloc{
+				crypto.PubkeyToAddress(governance.PublicKey): {Balance: big.NewInt(1000000000)},
+				crypto.PubkeyToAddress(intruder.PublicKey):   {Balance: big.NewInt(1000000000)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	blockchain, _ := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+
+	// The intruder tries to unfreeze the configured address, the governance freezes
+	// another one and unfreezes the configured one in the next block
+	update := func(key *ecdsa.PrivateKey, nonce uint64, freeze, unfreeze []common.Address) *types.Transaction {
+		data, _ := rlp.EncodeToBytes(&FreezeUpdate{Freeze: freeze, Unfreeze: unfreeze})
+		tx, err := types.SignTx(types.NewTransaction(nonce, registry, new(big.Int), big.NewInt(100000), new(big.Int), data), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	blocks, _ := GenerateChain(gspec.Config, genesis, db, 2, func(i int, block *BlockGen) {
+		switch i {
+		case 0:
+			block.AddTx(update(intruder, 0, []common.Address{c}, []common.Address{a}))
+		case 1:
+			block.AddTx(update(governance, 0, []common.Address{b}, []common.Address{a}))
+		}
+	})
+	if _, err := blockchain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("failed to import intruder update: %v", err)
+	}
+	statedb, _ := blockchain.State()
+	if !IsFrozen(gspec.Config, statedb, a) || IsFrozen(gspec.Config, statedb, c) {
+		t.Fatalf("intruder update applied")
+	}
+	if _, err := blockchain.InsertChain(blocks[1:]); err != nil {
+		t.Fatalf("failed to import governance update: %v", err)
+	}
+	statedb, _ = blockchain.State()
+	if IsFrozen(gspec.Config, statedb, a) {
+		t.Errorf("configured address still frozen")
+	}
+	if !IsFrozen(gspec.Config, statedb, b) {
+		t.Errorf("updated address not frozen")
+	}
+	if IsFrozen(gspec.Config, statedb, c) {
+		t.Errorf("untouched address frozen")
+	}
+	// The list is part of the state, surviving the deletion of empty accounts
+	if !statedb.Exist(registry) {
+		t.Errorf("registry account deleted")
+	}
+}
+
+// Tests that transactions of frozen addresses are rejected by the pool, and make
+// the blocks containing them invalid once the list is activated.
+func TestFreezeListEnforcement(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		frozen  = common.Address{0xff}
+		config  = &params.ChainConfig{
+			ChainId:        big.NewInt(1),
+			HomesteadBlock: new(big.Int),
+			Freeze:         &params.FreezeConfig{Block: big.NewInt(2), Registry: common.Address{0xee}, Frozen: []common.Address{frozen}},
+		}
+		gspec = &Genesis{
+			Config: &params.ChainConfig{ChainId: big.NewInt(1), HomesteadBlock: new(big.Int)},
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	blockchain, _ := NewBlockChain(db, config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+
+	// Generate the transfers without the list, as the chain maker would reject them
+	blocks, _ := GenerateChain(gspec.Config, genesis, db, 2, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), frozen, new(big.Int), big.NewInt(21000), new(big.Int), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	})
+	if _, err := blockchain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("failed to import frozen transfer ahead of activation: %v", err)
+	}
+	if _, err := blockchain.InsertChain(blocks[1:]); err != ErrFrozenAddress {
+		t.Fatalf("frozen transfer import error mismatch: have %v, want %v", err, ErrFrozenAddress)
+	}
+	// The pool rejects the transactions regardless of activation
+	pool := NewTxPool(config, new(event.TypeMux), blockchain.State, blockchain.GasLimit)
+	defer pool.Stop()
+
+	tx, _ := types.SignTx(types.NewTransaction(1, frozen, big.NewInt(100), big.NewInt(100000), big.NewInt(1), nil), signer, key)
+	if err := pool.Add(tx); err != ErrFrozenAddress {
+		t.Errorf("pool error mismatch: have %v, want %v", err, ErrFrozenAddress)
+	}
+}
@@ -28,11 +28,11 @@ import (
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/ethdb"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/params"
-	"github.com/expanse-org/go-expanse/pow"
 	"github.com/hashicorp/golang-lru"
 )
 
@@ -62,6 +62,9 @@ type HeaderChain struct {
 
 	procInterrupt func() bool
 
+	banned     map[common.Hash]struct{} // Block hashes banned by the operator, persisted in the database
+	bannedLock sync.RWMutex             // Protects the banned hashes, which are checked concurrently
+
 	rand         *mrand.Rand
 	getValidator getHeaderValidatorFn
 }
@@ -93,6 +96,10 @@ func NewHeaderChain(chainDb ethdb.Database, config *params.ChainConfig, getValid
 		procInterrupt: procInterrupt,
 		rand:          mrand.New(mrand.NewSource(seed.Int64())),
 		getValidator:  getValidator,
+		banned:        make(map[common.Hash]struct{}),
+	}
+	for _, hash := range GetBannedHashes(chainDb) {
+		hc.banned[hash] = struct{}{}
 	}
 
 	hc.genesisHeader = hc.GetHeaderByNumber(0)
@@ -111,6 +118,50 @@ func NewHeaderChain(chainDb ethdb.Database, config *params.ChainConfig, getValid
 	return hc, nil
 }
 
+// IsBadHash reports whether the block with the given hash is known to be bad,
+// either by being hard coded or banned by the operator.
+func (hc *HeaderChain) IsBadHash(hash common.Hash) bool {
+	if BadHashes[hash] {
+		return true
+	}
+	hc.bannedLock.RLock()
+	defer hc.bannedLock.RUnlock()
+
+	_, banned := hc.banned[hash]
+	return banned
+}
+
+// SetBanned bans or unbans the block with the given hash and persists the set
+// of banned hashes into the database.
+func (hc *HeaderChain) SetBanned(hash common.Hash, banned bool) error {
+	hc.bannedLock.Lock()
+	defer hc.bannedLock.Unlock()
+
+	if banned {
+		hc.banned[hash] = struct{}{}
+	} else {
+		delete(hc.banned, hash)
+	}
+	return WriteBannedHashes(hc.chainDb, hc.bannedHashes())
+}
+
+// BannedHashes returns the block hashes banned by the operator.
+func (hc *HeaderChain) BannedHashes() []common.Hash {
+	hc.bannedLock.RLock()
+	defer hc.bannedLock.RUnlock()
+
+	return hc.bannedHashes()
+}
+
+// bannedHashes returns the banned block hashes. The banned lock must be held.
+func (hc *HeaderChain) bannedHashes() []common.Hash {
+	hashes := make([]common.Hash, 0, len(hc.banned))
+	for hash := range hc.banned {
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
 // GetBlockNumber retrieves the block number belonging to the given hash
 // from the cache or database
 func (hc *HeaderChain) GetBlockNumber(hash common.Hash) uint64 {
@@ -259,11 +310,16 @@ func (hc *HeaderChain) ValidateHeaderChain(chain []*types.Header, checkFreq int)
 				return
 			}
 			// Short circuit if the header is bad or already known
-			if BadHashes[hash] {
+			if hc.IsBadHash(hash) {
 				errs[index] = BadHashError(hash)
 				atomic.AddInt32(&failed, 1)
 				return
 			}
+			if hc.IsBadHash(header.ParentHash) {
+				errs[index] = BadHashError(header.ParentHash)
+				atomic.AddInt32(&failed, 1)
+				return
+			}
 			if hc.HasHeader(hash) {
 				continue
 			}
@@ -435,6 +491,9 @@ func (hc *HeaderChain) CurrentHeader() *types.Header {
 	return hc.currentHeader
 }
 
+// Config retrieves the header chain's chain configuration.
+func (hc *HeaderChain) Config() *params.ChainConfig { return hc.config }
+
 // SetCurrentHeader sets the current head header of the canonical chain.
 func (hc *HeaderChain) SetCurrentHeader(head *types.Header) {
 	if err := WriteHeadHeaderHash(hc.chainDb, head.Hash()); err != nil {
@@ -495,15 +554,15 @@ func (hc *HeaderChain) SetGenesis(head *types.Header) {
 // headerValidator implements HeaderValidator.
 type headerValidator struct {
 	config *params.ChainConfig
-	hc     *HeaderChain // Canonical header chain
-	Pow    pow.PoW      // Proof of work used for validating
+	hc     *HeaderChain     // Canonical header chain
+	engine consensus.Engine // Consensus engine used for validating
 }
 
 // NewBlockValidator returns a new block validator which is safe for re-use
-func NewHeaderValidator(config *params.ChainConfig, chain *HeaderChain, pow pow.PoW) HeaderValidator {
+func NewHeaderValidator(config *params.ChainConfig, chain *HeaderChain, engine consensus.Engine) HeaderValidator {
 	return &headerValidator{
 		config: config,
-		Pow:    pow,
+		engine: engine,
 		hc:     chain,
 	}
 }
@@ -520,5 +579,5 @@ func (v *headerValidator) ValidateHeader(header, parent *types.Header, checkPow
 	if v.hc.HasHeader(header.Hash()) {
 		return nil
 	}
-	return ValidateHeader(v.config, v.Pow, header, parent, checkPow, false)
+	return ValidateHeader(v.hc, v.engine, header, parent, checkPow, false)
 }
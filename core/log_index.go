@@ -0,0 +1,175 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+// LogIndexSectionSize is the number of consecutive blocks whose log index
+// entries are grouped together under a single database key.
+const LogIndexSectionSize = 4096
+
+var (
+	logIndexTailKey     = []byte("LogIndexTail") // First block of the range indexed up to the head (uint64 big endian)
+	logIndexAddrPrefix  = []byte("logindex-a-")  // logIndexAddrPrefix + address + section (uint64 big endian) -> block offsets
+	logIndexTopicPrefix = []byte("logindex-t-")  // logIndexTopicPrefix + topic + section (uint64 big endian) -> block offsets
+
+	logIndexMu sync.Mutex // protect against race condition when updating log index sections
+)
+
+// The log index maps every log address and topic to the canonical blocks that
+// emitted a log containing it, allowing ranged log queries to only retrieve the
+// receipts of the matching blocks. The blocks are grouped into sections of
+// LogIndexSectionSize blocks, each entry storing the sorted offsets within its
+// section as 16 bit big endian integers.
+//
+// Entries are keyed by block number and never deleted, so blocks reorged out of
+// the canonical chain are left in the index as false positives. Readers need to
+// verify the logs of the candidate blocks.
+
+// logIndexKey returns the database key of a log index section of a term.
+func logIndexKey(prefix []byte, term []byte, section uint64) []byte {
+	key := make([]byte, 0, len(prefix)+len(term)+8)
+	key = append(key, prefix...)
+	key = append(key, term...)
+	return append(key, encodeBlockNumber(section)...)
+}
+
+// GetLogIndexTail retrieves the first block of the range the log index covers,
+// which extends up to the chain head. False is returned if the index is not
+// maintained in the database.
+func GetLogIndexTail(db ethdb.Database) (uint64, bool) {
+	data, _ := db.Get(logIndexTailKey)
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// WriteLogIndexTail stores the first block of the range the log index covers,
+// enabling the index maintenance on block import.
+func WriteLogIndexTail(db ethdb.Putter, number uint64) error {
+	if err := db.Put(logIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store log index tail", "err", err)
+	}
+	return nil
+}
+
+// DeleteLogIndexTail disables the maintenance of the log index. The entries of
+// the indexed blocks are left in the database.
+func DeleteLogIndexTail(db ethdb.Database) {
+	db.Delete(logIndexTailKey)
+}
+
+// WriteLogIndex adds the addresses and topics of the logs in the receipts to the
+// log index entries of the given canonical block. Nothing is written if the log
+// index is not maintained in the database.
+func WriteLogIndex(db ethdb.Database, number uint64, receipts types.Receipts) error {
+	if _, ok := GetLogIndexTail(db); !ok {
+		return nil
+	}
+	// Gather the distinct terms of the block's logs
+	var (
+		addrs  = make(map[common.Address]struct{})
+		topics = make(map[common.Hash]struct{})
+	)
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			addrs[log.Address] = struct{}{}
+			for _, topic := range log.Topics {
+				topics[topic] = struct{}{}
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	// Merge the block into the section entry of every term
+	logIndexMu.Lock()
+	defer logIndexMu.Unlock()
+
+	var (
+		batch   = db.NewBatch()
+		section = number / LogIndexSectionSize
+		offset  = uint16(number % LogIndexSectionSize)
+	)
+	insert := func(key []byte) {
+		data, _ := db.Get(key)
+		if data, ok := insertLogIndexOffset(data, offset); ok {
+			batch.Put(key, data)
+		}
+	}
+	for addr := range addrs {
+		insert(logIndexKey(logIndexAddrPrefix, addr[:], section))
+	}
+	for topic := range topics {
+		insert(logIndexKey(logIndexTopicPrefix, topic[:], section))
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("log index write fail for: %d: %v", number, err)
+	}
+	return nil
+}
+
+// insertLogIndexOffset inserts a block offset into an encoded section entry,
+// keeping it sorted. False is returned if the offset was already present.
+func insertLogIndexOffset(data []byte, offset uint16) ([]byte, bool) {
+	n := len(data) / 2
+	i := sort.Search(n, func(i int) bool {
+		return binary.BigEndian.Uint16(data[2*i:]) >= offset
+	})
+	if i < n && binary.BigEndian.Uint16(data[2*i:]) == offset {
+		return data, false
+	}
+	entry := make([]byte, len(data)+2)
+	copy(entry, data[:2*i])
+	binary.BigEndian.PutUint16(entry[2*i:], offset)
+	copy(entry[2*i+2:], data[2*i:])
+	return entry, true
+}
+
+// decodeLogIndex converts an encoded section entry into block numbers.
+func decodeLogIndex(data []byte, section uint64) []uint64 {
+	numbers := make([]uint64, len(data)/2)
+	for i := range numbers {
+		numbers[i] = section*LogIndexSectionSize + uint64(binary.BigEndian.Uint16(data[2*i:]))
+	}
+	return numbers
+}
+
+// GetLogIndexAddress retrieves the ascending numbers of the blocks within the
+// given section that emitted logs from the address.
+func GetLogIndexAddress(db ethdb.Database, addr common.Address, section uint64) []uint64 {
+	data, _ := db.Get(logIndexKey(logIndexAddrPrefix, addr[:], section))
+	return decodeLogIndex(data, section)
+}
+
+// GetLogIndexTopic retrieves the ascending numbers of the blocks within the
+// given section that emitted logs containing the topic.
+func GetLogIndexTopic(db ethdb.Database, topic common.Hash, section uint64) []uint64 {
+	data, _ := db.Get(logIndexKey(logIndexTopicPrefix, topic[:], section))
+	return decodeLogIndex(data, section)
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/ethdb"
+)
+
+// Tests that the log index entries are only maintained once enabled, and that
+// they are kept sorted and free of duplicates regardless of the write order.
+func TestLogIndexStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	var (
+		addr1  = common.BytesToAddress([]byte("addr1"))
+		addr2  = common.BytesToAddress([]byte("addr2"))
+		topic1 = common.BytesToHash([]byte("topic1"))
+		topic2 = common.BytesToHash([]byte("topic2"))
+	)
+	receipts := func(logs ...*types.Log) types.Receipts {
+		receipt := types.NewReceipt(nil, new(big.Int))
+		receipt.Logs = logs
+		return types.Receipts{receipt}
+	}
+	// Writes must be ignored until the index is enabled
+	if err := WriteLogIndex(db, 1, receipts(&types.Log{Address: addr1, Topics: []common.Hash{topic1}})); err != nil {
+		t.Fatalf("failed to write disabled log index: %v", err)
+	}
+	if numbers := GetLogIndexAddress(db, addr1, 0); len(numbers) != 0 {
+		t.Fatalf("disabled log index written: %v", numbers)
+	}
+	if _, ok := GetLogIndexTail(db); ok {
+		t.Fatalf("log index reported enabled")
+	}
+	WriteLogIndexTail(db, 0)
+	if tail, ok := GetLogIndexTail(db); !ok || tail != 0 {
+		t.Fatalf("log index tail mismatch: have %d/%v, want %d/%v", tail, ok, 0, true)
+	}
+	// Index a few blocks out of order, across sections
+	writes := []struct {
+		number uint64
+		logs   []*types.Log
+	}{
+		{100, []*types.Log{{Address: addr1, Topics: []common.Hash{topic1}}}},
+		{3, []*types.Log{{Address: addr1, Topics: []common.Hash{topic1, topic2}}, {Address: addr2}}},
+		{LogIndexSectionSize + 5, []*types.Log{{Address: addr2, Topics: []common.Hash{topic2}}}},
+		{50, []*types.Log{{Address: addr1, Topics: []common.Hash{topic2}}}},
+		{100, []*types.Log{{Address: addr1, Topics: []common.Hash{topic1}}}},
+	}
+	for _, write := range writes {
+		if err := WriteLogIndex(db, write.number, receipts(write.logs...)); err != nil {
+			t.Fatalf("failed to write log index of block %d: %v", write.number, err)
+		}
+	}
+	tests := []struct {
+		have, want []uint64
+	}{
+		{GetLogIndexAddress(db, addr1, 0), []uint64{3, 50, 100}},
+		{GetLogIndexAddress(db, addr2, 0), []uint64{3}},
+		{GetLogIndexAddress(db, addr2, 1), []uint64{LogIndexSectionSize + 5}},
+		{GetLogIndexTopic(db, topic1, 0), []uint64{3, 100}},
+		{GetLogIndexTopic(db, topic2, 0), []uint64{3, 50}},
+		{GetLogIndexTopic(db, topic2, 1), []uint64{LogIndexSectionSize + 5}},
+		{GetLogIndexTopic(db, topic1, 1), []uint64{}},
+	}
+	for i, tt := range tests {
+		if !reflect.DeepEqual(tt.have, tt.want) {
+			t.Errorf("test %d: indexed blocks mismatch: have %v, want %v", i, tt.have, tt.want)
+		}
+	}
+	// Disabling the index must stop further writes
+	DeleteLogIndexTail(db)
+	if err := WriteLogIndex(db, 200, receipts(&types.Log{Address: addr1})); err != nil {
+		t.Fatalf("failed to write disabled log index: %v", err)
+	}
+	if numbers := GetLogIndexAddress(db, addr1, 0); len(numbers) != 3 {
+		t.Errorf("disabled log index written: %v", numbers)
+	}
+}
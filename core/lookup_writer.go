@@ -0,0 +1,132 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/ethdb"
+)
+
+// lookupTask is a canonical block whose lookup entries are yet to be written.
+type lookupTask struct {
+	index     int                    // Index of the block in the imported chain
+	block     *types.Block           // Canonical block to index
+	receipts  types.Receipts         // Receipts generated by the block's processing
+	preimages map[common.Hash][]byte // Hash preimages recorded during the processing
+}
+
+// lookupWriter is the commit stage of the block import, persisting the lookup
+// entries of the canonical blocks (transactions, receipts, bloom filters, log
+// index and hash preimages) on a background goroutine while the next blocks
+// execute.
+//
+// The entries are written strictly in import order. As none of them are needed
+// for processing blocks, the import only has to wait for the pending writes to
+// finish before a reorg might delete some of them, and before announcing the
+// imported blocks.
+type lookupWriter struct {
+	db    ethdb.Database
+	tasks chan *lookupTask
+	wg    sync.WaitGroup // Pending tasks to wait for when flushing
+
+	lock   sync.Mutex
+	failed int   // Index of the first block failing to be written
+	err    error // Error of the first block failing to be written
+	done   chan struct{}
+}
+
+// newLookupWriter creates a lookup writer for the given database and starts its
+// background goroutine.
+func newLookupWriter(db ethdb.Database) *lookupWriter {
+	w := &lookupWriter{
+		db:    db,
+		tasks: make(chan *lookupTask, 64),
+		done:  make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+// loop writes the lookup entries of the scheduled blocks until the writer is
+// closed. After a failure the remaining tasks are skipped.
+func (w *lookupWriter) loop() {
+	defer close(w.done)
+
+	for task := range w.tasks {
+		w.lock.Lock()
+		failed := w.err != nil
+		w.lock.Unlock()
+
+		if !failed {
+			if err := writeLookups(w.db, task); err != nil {
+				w.lock.Lock()
+				w.failed, w.err = task.index, err
+				w.lock.Unlock()
+			}
+		}
+		w.wg.Done()
+	}
+}
+
+// writeLookups persists the lookup entries of a single canonical block.
+func writeLookups(db ethdb.Database, task *lookupTask) error {
+	batch := db.NewBatch()
+	if err := WriteTransactions(batch, task.block); err != nil {
+		return err
+	}
+	if err := WriteReceipts(batch, task.receipts); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if err := WriteMipmapBloom(db, task.block.NumberU64(), task.receipts); err != nil {
+		return err
+	}
+	if err := WriteLogIndex(db, task.block.NumberU64(), task.receipts); err != nil {
+		return err
+	}
+	return WritePreimages(db, task.block.NumberU64(), task.preimages)
+}
+
+// schedule queues a canonical block for having its lookup entries written.
+func (w *lookupWriter) schedule(task *lookupTask) {
+	w.wg.Add(1)
+	w.tasks <- task
+}
+
+// flush waits until all the scheduled blocks are written, returning the index
+// and the error of the first one failing.
+func (w *lookupWriter) flush() (int, error) {
+	w.wg.Wait()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.failed, w.err
+}
+
+// close flushes the pending writes and stops the background goroutine.
+func (w *lookupWriter) close() (int, error) {
+	index, err := w.flush()
+	close(w.tasks)
+	<-w.done
+	return index, err
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// PowEngine is the proof-of-work consensus engine, enforcing the difficulty
+// adjustment and block rewards of the chain on top of a sealing algorithm
+// (ethash or one of its fake and test variants).
+//
+// PowEngine implements consensus.PoW.
+type PowEngine struct {
+	pow.PoW // Sealing algorithm searching and verifying the block nonces
+}
+
+// NewPowEngine creates a proof-of-work consensus engine sealing with the given
+// algorithm.
+func NewPowEngine(pow pow.PoW) *PowEngine {
+	return &PowEngine{PoW: pow}
+}
+
+// VerifyHeader implements consensus.Engine, checking the difficulty of the
+// header against the adjustment algorithm.
+func (e *PowEngine) VerifyHeader(chain consensus.ChainReader, header, parent *types.Header) error {
+	// The difficulty is calculated from 64 bit timestamps, don't let them truncate
+	if header.Time.BitLen() > 64 {
+		return BlockTSTooBigErr
+	}
+	expd := CalcDifficulty(chain.Config(), header.Time.Uint64(), parent.Time.Uint64(), parent.Number, parent.Difficulty)
+	if expd.Cmp(header.Difficulty) != 0 {
+		return fmt.Errorf("Difficulty check failed for header (remote: %v local: %v)", header.Difficulty, expd)
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine, checking the proof of work of the
+// header.
+func (e *PowEngine) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	if err := e.Verify(types.NewBlockWithHeader(header)); err != nil {
+		return &BlockNonceErr{header.Number, header.Hash(), header.Nonce.Uint64()}
+	}
+	return nil
+}
+
+// Prepare implements consensus.Engine, setting the difficulty of the header.
+// Engines bounding the block interval also stamp the header within the bounds.
+func (e *PowEngine) Prepare(chain consensus.ChainReader, header, parent *types.Header) error {
+	if interval, ok := e.PoW.(*pow.IntervalPoW); ok {
+		header.Time.SetUint64(interval.Timestamp(parent, header.Time.Uint64()))
+	}
+	header.Difficulty = CalcDifficulty(chain.Config(), header.Time.Uint64(), parent.Time.Uint64(), parent.Number, parent.Difficulty)
+	return nil
+}
+
+// Finalize implements consensus.Engine, crediting the block and uncle rewards.
+func (e *PowEngine) Finalize(chain consensus.ChainReader, state *state.StateDB, header *types.Header, uncles []*types.Header) error {
+	return AccumulateRewards(e, state, header, uncles)
+}
+
+// Seal implements consensus.Engine, searching for a nonce satisfying the
+// difficulty of the block.
+func (e *PowEngine) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	nonce, mixDigest := e.Search(block, stop)
+	if nonce == 0 {
+		return nil, nil
+	}
+	return block.WithMiningResult(types.EncodeNonce(nonce), common.BytesToHash(mixDigest)), nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math"
+	"sync"
+
+	"github.com/expanse-org/go-expanse/metrics"
+	"github.com/hashicorp/golang-lru/simplelru"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// sizedCache is a least recently used cache whose capacity is bounded by the
+// total size of the cached items instead of their count, so that a few very
+// large blocks cannot blow up memory usage the way a count based cache would.
+type sizedCache struct {
+	items *simplelru.LRU // Cached items, ordered by recency of access
+	size  int            // Total size of the cached items
+	limit int            // Maximum total size of the cached items
+
+	hits   gometrics.Meter // Meter counting the cache lookup hits
+	misses gometrics.Meter // Meter counting the cache lookup misses
+
+	lock sync.Mutex
+}
+
+// sizedItem is a cached value along with its accounted size.
+type sizedItem struct {
+	value interface{}
+	size  int
+}
+
+// newSizedCache creates a cache holding at most limit bytes worth of items,
+// reporting lookup statistics under the given metrics name.
+func newSizedCache(limit int, name string) *sizedCache {
+	cache := &sizedCache{
+		limit:  limit,
+		hits:   metrics.NewMeter(name + "/hits"),
+		misses: metrics.NewMeter(name + "/misses"),
+	}
+	cache.items, _ = simplelru.NewLRU(math.MaxInt32, cache.evicted)
+	return cache
+}
+
+// evicted updates the size accounting when an item leaves the cache.
+func (c *sizedCache) evicted(key interface{}, value interface{}) {
+	c.size -= value.(*sizedItem).size
+}
+
+// Get looks up a key's value from the cache, marking it as recently used.
+func (c *sizedCache) Get(key interface{}) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	item, ok := c.items.Get(key)
+	if !ok {
+		c.misses.Mark(1)
+		return nil, false
+	}
+	c.hits.Mark(1)
+	return item.(*sizedItem).value, true
+}
+
+// Add inserts a value of the given size into the cache, evicting the least
+// recently used items until everything fits. Items larger than the entire cache
+// are not stored.
+func (c *sizedCache) Add(key interface{}, value interface{}, size int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.items.Remove(key)
+	if size > c.limit {
+		return
+	}
+	c.items.Add(key, &sizedItem{value: value, size: size})
+	c.size += size
+
+	for c.size > c.limit {
+		c.items.RemoveOldest()
+	}
+}
+
+// Remove drops a key from the cache.
+func (c *sizedCache) Remove(key interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.items.Remove(key)
+}
+
+// Purge drops all items from the cache.
+func (c *sizedCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.items.Purge()
+}
+
+// Size returns the total size of the items in the cache.
+func (c *sizedCache) Size() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.size
+}
+
+// Len returns the number of items in the cache.
+func (c *sizedCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.items.Len()
+}
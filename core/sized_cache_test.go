@@ -0,0 +1,61 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import "testing"
+
+// Tests that the sized cache evicts the least recently used items once their
+// total size exceeds the limit, and that it keeps its size accounting accurate.
+func TestSizedCacheEviction(t *testing.T) {
+	cache := newSizedCache(100, "test/cache")
+
+	// Fill the cache up to its limit and touch the first item
+	for i := 0; i < 4; i++ {
+		cache.Add(i, i, 25)
+	}
+	if size := cache.Size(); size != 100 {
+		t.Fatalf("filled cache size mismatch: have %d, want %d", size, 100)
+	}
+	if _, ok := cache.Get(0); !ok {
+		t.Fatalf("item 0 missing from filled cache")
+	}
+	// Adding a large item should evict the least recently used ones only
+	cache.Add(4, 4, 40)
+	for i, want := range []bool{true, false, false, true, true} {
+		if _, ok := cache.Get(i); ok != want {
+			t.Errorf("item %d: presence mismatch: have %v, want %v", i, ok, want)
+		}
+	}
+	if size, items := cache.Size(), cache.Len(); size != 90 || items != 3 {
+		t.Fatalf("cache content mismatch: have %d bytes in %d items, want %d bytes in %d items", size, items, 90, 3)
+	}
+	// Replacing and removing items should release their size
+	cache.Add(4, 4, 10)
+	cache.Remove(3)
+	if size := cache.Size(); size != 35 {
+		t.Fatalf("shrunk cache size mismatch: have %d, want %d", size, 35)
+	}
+	// Items too large for the cache should not be stored
+	cache.Add(5, 5, 101)
+	if _, ok := cache.Get(5); ok {
+		t.Fatalf("oversized item stored in cache")
+	}
+	cache.Purge()
+	if size, items := cache.Size(), cache.Len(); size != 0 || items != 0 {
+		t.Fatalf("purged cache not empty: %d bytes in %d items", size, items)
+	}
+}
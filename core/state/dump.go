@@ -19,6 +19,7 @@ package state
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/rlp"
@@ -52,13 +53,13 @@ func (self *StateDB) RawDump() Dump {
 			panic(err)
 		}
 
-		obj := newObject(nil, common.BytesToAddress(addr), data, nil)
+		obj := newObject(self, common.BytesToAddress(addr), data)
 		account := DumpAccount{
 			Balance:  data.Balance.String(),
 			Nonce:    data.Nonce,
 			Root:     common.Bytes2Hex(data.Root[:]),
 			CodeHash: common.Bytes2Hex(data.CodeHash),
-			Code:     common.Bytes2Hex(obj.Code(self.db)),
+			Code:     common.Bytes2Hex(obj.Code()),
 			Storage:  make(map[string]string),
 		}
 		storageIt := obj.getTrie(self.db).Iterator()
@@ -78,3 +79,27 @@ func (self *StateDB) Dump() []byte {
 
 	return json
 }
+
+// TotalBalance sums the balances of all the accounts committed to the state
+// trie. Uncommitted changes are not included.
+func (self *StateDB) TotalBalance() *big.Int {
+	total := new(big.Int)
+	self.ForEachBalance(func(addr common.Address, balance *big.Int) {
+		total.Add(total, balance)
+	})
+	return total
+}
+
+// ForEachBalance calls cb with the balance of every account committed to the
+// state trie. Accounts whose address preimage is unknown are reported with the
+// zero address.
+func (self *StateDB) ForEachBalance(cb func(addr common.Address, balance *big.Int)) {
+	it := self.trie.Iterator()
+	for it.Next() {
+		var data Account
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			panic(err)
+		}
+		cb(common.BytesToAddress(self.trie.GetKey(it.Key)), data.Balance)
+	}
+}
@@ -22,11 +22,75 @@ import (
 	"github.com/expanse-org/go-expanse/common"
 )
 
+// journalEntry is a modification entry in the state change journal that can be
+// reverted on demand.
 type journalEntry interface {
+	// undo reverts the change introduced by this journal entry.
 	undo(*StateDB)
+
+	// dirtied returns the account modified by this journal entry, if any.
+	dirtied() *common.Address
+}
+
+// maxPooledJournalEntries is the capacity above which the entry buffer of a
+// journal is released instead of being reused after a reset.
+const maxPooledJournalEntries = 16384
+
+// journal contains the list of state modifications applied since the last state
+// finalisation, along with the set of accounts they touched. Snapshots are plain
+// indices into the entry list, so reverting costs only the changes made since.
+type journal struct {
+	entries []journalEntry              // Current changes tracked by the journal
+	dirties map[common.Address]struct{} // Accounts modified since the last finalisation
 }
 
-type journal []journalEntry
+// newJournal creates a new, empty state journal.
+func newJournal() *journal {
+	return &journal{
+		dirties: make(map[common.Address]struct{}),
+	}
+}
+
+// append inserts a new modification entry to the end of the journal, marking
+// the account it touches as dirty.
+func (j *journal) append(entry journalEntry) {
+	j.entries = append(j.entries, entry)
+	if addr := entry.dirtied(); addr != nil {
+		j.dirties[*addr] = struct{}{}
+	}
+}
+
+// revert undoes a batch of journalled modifications, from the newest entry back
+// to the given snapshot. Accounts generally stay dirty even if their changes are
+// reverted, they are simply rewritten with their original contents on finalisation.
+func (j *journal) revert(statedb *StateDB, snapshot int) {
+	for i := len(j.entries) - 1; i >= snapshot; i-- {
+		j.entries[i].undo(statedb)
+		j.entries[i] = nil
+	}
+	j.entries = j.entries[:snapshot]
+}
+
+// length returns the current number of entries in the journal.
+func (j *journal) length() int {
+	return len(j.entries)
+}
+
+// reset empties the journal and its dirty set, keeping the allocated entry
+// buffer for reuse unless it grew excessively large.
+func (j *journal) reset() {
+	if cap(j.entries) > maxPooledJournalEntries {
+		j.entries = nil
+	} else {
+		for i := range j.entries {
+			j.entries[i] = nil
+		}
+		j.entries = j.entries[:0]
+	}
+	for addr := range j.dirties {
+		delete(j.dirties, addr)
+	}
+}
 
 type (
 	// Changes to the account trie.
@@ -79,12 +143,21 @@ type (
 func (ch createObjectChange) undo(s *StateDB) {
 	delete(s.stateObjects, *ch.account)
 	delete(s.stateObjectsDirty, *ch.account)
+	delete(s.journal.dirties, *ch.account)
+}
+
+func (ch createObjectChange) dirtied() *common.Address {
+	return ch.account
 }
 
 func (ch resetObjectChange) undo(s *StateDB) {
 	s.setStateObject(ch.prev)
 }
 
+func (ch resetObjectChange) dirtied() *common.Address {
+	return &ch.prev.address
+}
+
 func (ch suicideChange) undo(s *StateDB) {
 	obj := s.getStateObject(*ch.account)
 	if obj != nil {
@@ -93,35 +166,67 @@ func (ch suicideChange) undo(s *StateDB) {
 	}
 }
 
+func (ch suicideChange) dirtied() *common.Address {
+	return ch.account
+}
+
+// ripemd is the address of the RIPEMD precompile. Mainnet block 2675119 touched it
+// in a call that ran out of gas, but clients left it touched anyway, so reverting
+// a touch on it must keep it eligible for empty account deletion.
 var ripemd = common.HexToAddress("0000000000000000000000000000000000000003")
 
 func (ch touchChange) undo(s *StateDB) {
 	if !ch.prev && *ch.account != ripemd {
 		delete(s.stateObjects, *ch.account)
 		delete(s.stateObjectsDirty, *ch.account)
+		delete(s.journal.dirties, *ch.account)
 	}
 }
 
+func (ch touchChange) dirtied() *common.Address {
+	return ch.account
+}
+
 func (ch balanceChange) undo(s *StateDB) {
 	s.getStateObject(*ch.account).setBalance(ch.prev)
 }
 
+func (ch balanceChange) dirtied() *common.Address {
+	return ch.account
+}
+
 func (ch nonceChange) undo(s *StateDB) {
 	s.getStateObject(*ch.account).setNonce(ch.prev)
 }
 
+func (ch nonceChange) dirtied() *common.Address {
+	return ch.account
+}
+
 func (ch codeChange) undo(s *StateDB) {
 	s.getStateObject(*ch.account).setCode(common.BytesToHash(ch.prevhash), ch.prevcode)
 }
 
+func (ch codeChange) dirtied() *common.Address {
+	return ch.account
+}
+
 func (ch storageChange) undo(s *StateDB) {
 	s.getStateObject(*ch.account).setState(ch.key, ch.prevalue)
 }
 
+func (ch storageChange) dirtied() *common.Address {
+	return ch.account
+}
+
 func (ch refundChange) undo(s *StateDB) {
 	s.refund = ch.prev
 }
 
+func (ch refundChange) dirtied() *common.Address {
+	return nil
+}
+
 func (ch addLogChange) undo(s *StateDB) {
 	logs := s.logs[ch.txhash]
 	if len(logs) == 1 {
@@ -131,6 +236,14 @@ func (ch addLogChange) undo(s *StateDB) {
 	}
 }
 
+func (ch addLogChange) dirtied() *common.Address {
+	return nil
+}
+
 func (ch addPreimageChange) undo(s *StateDB) {
 	delete(s.preimages, ch.hash)
 }
+
+func (ch addPreimageChange) dirtied() *common.Address {
+	return nil
+}
@@ -0,0 +1,301 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/rlp"
+	"github.com/expanse-org/go-expanse/trie"
+)
+
+var (
+	errSnapshotOrder = errors.New("snapshot entries out of order")
+	errSnapshotCode  = errors.New("snapshot code mismatch")
+)
+
+// SnapshotManifest describes a state snapshot: the block whose state it holds
+// and the chunks the state was split into. Chunks are identified by the hash of
+// their contents and must be restored in the listed order.
+type SnapshotManifest struct {
+	Number uint64        // Number of the block whose state is snapshotted
+	Hash   common.Hash   // Hash of the block whose state is snapshotted
+	Root   common.Hash   // Root hash of the snapshotted state trie
+	Chunks []common.Hash // Hashes of the snapshot chunks, in restoration order
+}
+
+// snapshotEntry is an account of the state, along with its code and storage, as
+// stored in a snapshot chunk. The storage of large accounts is split across
+// multiple entries, the continuations leaving the account and code empty.
+type snapshotEntry struct {
+	Hash    common.Hash    // Hash of the account address, the key in the state trie
+	Account []byte         // RLP encoded account, empty for storage continuations
+	Code    []byte         // Contract code of the account, if any
+	Storage []snapshotSlot // Storage slots of the account, in trie order
+}
+
+// snapshotSlot is a single storage slot of a snapshotted account.
+type snapshotSlot struct {
+	Key   common.Hash // Hash of the storage slot, the key in the storage trie
+	Value []byte      // RLP encoded value of the storage slot
+}
+
+// size returns the approximate encoded size of the entry.
+func (entry *snapshotEntry) size() int {
+	size := common.HashLength + len(entry.Account) + len(entry.Code)
+	for _, slot := range entry.Storage {
+		size += common.HashLength + len(slot.Value)
+	}
+	return size
+}
+
+// snapshotChunker groups snapshot entries into chunks of a target size.
+type snapshotChunker struct {
+	limit   int                                       // Target size of a chunk
+	write   func(hash common.Hash, blob []byte) error // Callback to store a finished chunk
+	entries []*snapshotEntry                          // Entries of the chunk being assembled
+	size    int                                       // Approximate size of the chunk being assembled
+	chunks  []common.Hash                             // Hashes of the chunks written so far
+}
+
+// add appends an entry to the current chunk, writing the chunk out if it grew
+// beyond the target size.
+func (c *snapshotChunker) add(entry *snapshotEntry) error {
+	c.entries = append(c.entries, entry)
+	if c.size += entry.size(); c.size >= c.limit {
+		return c.flush()
+	}
+	return nil
+}
+
+// flush writes out the current chunk, if it contains any entries.
+func (c *snapshotChunker) flush() error {
+	if len(c.entries) == 0 {
+		return nil
+	}
+	blob, err := rlp.EncodeToBytes(c.entries)
+	if err != nil {
+		return err
+	}
+	hash := crypto.Keccak256Hash(blob)
+	if err := c.write(hash, blob); err != nil {
+		return err
+	}
+	c.chunks = append(c.chunks, hash)
+	c.entries, c.size = nil, 0
+	return nil
+}
+
+// GenerateSnapshot splits the state with the given root into chunks of roughly
+// chunkSize bytes, feeding each into the write callback. The hashes of the chunks
+// are returned in restoration order. The generation is deterministic, so nodes
+// snapshotting the same state with the same chunk size produce the same chunks.
+func GenerateSnapshot(db trie.Database, root common.Hash, chunkSize int, write func(hash common.Hash, blob []byte) error) ([]common.Hash, error) {
+	accounts, err := trie.New(root, db)
+	if err != nil {
+		return nil, err
+	}
+	chunker := &snapshotChunker{limit: chunkSize, write: write}
+
+	it := trie.NewIterator(accounts)
+	for it.Next() {
+		var account Account
+		if err := rlp.DecodeBytes(it.Value, &account); err != nil {
+			return nil, err
+		}
+		entry := &snapshotEntry{
+			Hash:    common.BytesToHash(it.Key),
+			Account: common.CopyBytes(it.Value),
+		}
+		if !bytes.Equal(account.CodeHash, emptyCodeHash) {
+			if entry.Code, err = db.Get(account.CodeHash); err != nil {
+				return nil, fmt.Errorf("code %x: %v", account.CodeHash, err)
+			}
+		}
+		storage, err := trie.New(account.Root, db)
+		if err != nil {
+			return nil, err
+		}
+		sit := trie.NewIterator(storage)
+		for sit.Next() {
+			entry.Storage = append(entry.Storage, snapshotSlot{
+				Key:   common.BytesToHash(sit.Key),
+				Value: common.CopyBytes(sit.Value),
+			})
+			// Split the storage of large accounts into continuation entries
+			if entry.size() >= chunkSize {
+				if err := chunker.add(entry); err != nil {
+					return nil, err
+				}
+				entry = &snapshotEntry{Hash: entry.Hash}
+			}
+		}
+		if sit.Err != nil {
+			return nil, sit.Err
+		}
+		if len(entry.Account) > 0 || len(entry.Storage) > 0 {
+			if err := chunker.add(entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	if err := chunker.flush(); err != nil {
+		return nil, err
+	}
+	return chunker.chunks, nil
+}
+
+// SnapshotRestorer reassembles a state from the chunks of a snapshot, fed to it
+// in order. The tries are committed to the database after every chunk, but as
+// a trie node is only written together with all its children, the state root is
+// only present once the whole state has been restored.
+type SnapshotRestorer struct {
+	db       ethdb.Database
+	root     common.Hash // Expected root of the restored state
+	accounts *trie.Trie  // State trie being restored
+
+	last        common.Hash // Hash of the account restored last
+	account     []byte      // RLP encoded account whose storage is being restored
+	storageRoot common.Hash // Storage root of the account being restored
+	storage     *trie.Trie  // Storage trie of the account being restored
+}
+
+// NewSnapshotRestorer creates a restorer for the state with the given root.
+func NewSnapshotRestorer(db ethdb.Database, root common.Hash) *SnapshotRestorer {
+	accounts, _ := trie.New(common.Hash{}, db)
+	return &SnapshotRestorer{
+		db:       db,
+		root:     root,
+		accounts: accounts,
+	}
+}
+
+// Feed restores the next chunk of the snapshot.
+func (r *SnapshotRestorer) Feed(chunk []byte) error {
+	var entries []*snapshotEntry
+	if err := rlp.DecodeBytes(chunk, &entries); err != nil {
+		return err
+	}
+	batch := r.db.NewBatch()
+	for _, entry := range entries {
+		if len(entry.Account) == 0 {
+			// Storage continuation, must extend the account being restored
+			if r.account == nil || entry.Hash != r.last {
+				return errSnapshotOrder
+			}
+		} else {
+			// New account, finish the previous one and start restoring this
+			if r.account != nil {
+				if bytes.Compare(entry.Hash[:], r.last[:]) <= 0 {
+					return errSnapshotOrder
+				}
+				if err := r.finishAccount(batch); err != nil {
+					return err
+				}
+			}
+			if err := r.startAccount(batch, entry); err != nil {
+				return err
+			}
+		}
+		for _, slot := range entry.Storage {
+			if err := r.storage.TryUpdate(slot.Key[:], slot.Value); err != nil {
+				return err
+			}
+		}
+	}
+	// Flush the completed parts of the tries to keep memory use bounded
+	if r.storage != nil {
+		if _, err := r.storage.CommitTo(batch); err != nil {
+			return err
+		}
+	}
+	if _, err := r.accounts.CommitTo(batch); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// startAccount starts restoring the account of a snapshot entry, storing its
+// code after checking it against the code hash.
+func (r *SnapshotRestorer) startAccount(batch ethdb.Batch, entry *snapshotEntry) error {
+	var account Account
+	if err := rlp.DecodeBytes(entry.Account, &account); err != nil {
+		return err
+	}
+	if bytes.Equal(account.CodeHash, emptyCodeHash) {
+		if len(entry.Code) > 0 {
+			return errSnapshotCode
+		}
+	} else {
+		if !bytes.Equal(crypto.Keccak256(entry.Code), account.CodeHash) {
+			return errSnapshotCode
+		}
+		if err := batch.Put(account.CodeHash, entry.Code); err != nil {
+			return err
+		}
+	}
+	storage, err := trie.New(common.Hash{}, r.db)
+	if err != nil {
+		return err
+	}
+	r.last, r.account, r.storageRoot, r.storage = entry.Hash, entry.Account, account.Root, storage
+	return nil
+}
+
+// finishAccount commits the storage of the account being restored, checking it
+// against the storage root, and inserts the account into the state trie.
+func (r *SnapshotRestorer) finishAccount(batch ethdb.Batch) error {
+	root, err := r.storage.CommitTo(batch)
+	if err != nil {
+		return err
+	}
+	if root != r.storageRoot {
+		return fmt.Errorf("account %x: storage root mismatch: have %x, want %x", r.last, root, r.storageRoot)
+	}
+	if err := r.accounts.TryUpdate(r.last[:], r.account); err != nil {
+		return err
+	}
+	r.account, r.storage = nil, nil
+	return nil
+}
+
+// Finish completes the restoration after all chunks were fed, checking that the
+// restored state matches the expected root.
+func (r *SnapshotRestorer) Finish() error {
+	batch := r.db.NewBatch()
+	if r.account != nil {
+		if err := r.finishAccount(batch); err != nil {
+			return err
+		}
+	}
+	root, err := r.accounts.CommitTo(batch)
+	if err != nil {
+		return err
+	}
+	if root != r.root {
+		return fmt.Errorf("state root mismatch: have %x, want %x", root, r.root)
+	}
+	return batch.Write()
+}
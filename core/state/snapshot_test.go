@@ -0,0 +1,143 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/ethdb"
+)
+
+// makeSnapshotTestState extends the sync test state with contract storage, some
+// accounts holding enough of it to be split across multiple snapshot chunks.
+func makeSnapshotTestState() (ethdb.Database, common.Hash, []*testAccount) {
+	db, root, accounts := makeTestState()
+
+	state, _ := New(root, db)
+	for i, acc := range accounts {
+		for j := 0; j < i%4*16; j++ {
+			state.SetState(acc.address, common.BytesToHash([]byte{byte(j)}), common.BytesToHash([]byte{byte(i), byte(j) + 1}))
+		}
+	}
+	root, _ = state.Commit(false)
+	return db, root, accounts
+}
+
+// generateTestSnapshot snapshots the given state into an in-memory chunk store.
+func generateTestSnapshot(t *testing.T, db ethdb.Database, root common.Hash, chunkSize int) ([]common.Hash, map[common.Hash][]byte) {
+	blobs := make(map[common.Hash][]byte)
+	chunks, err := GenerateSnapshot(db, root, chunkSize, func(hash common.Hash, blob []byte) error {
+		if crypto.Keccak256Hash(blob) != hash {
+			t.Errorf("chunk hash mismatch: have %x, want %x", hash, crypto.Keccak256Hash(blob))
+		}
+		blobs[hash] = blob
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	return chunks, blobs
+}
+
+// Tests that a state can be snapshotted and restored from the chunks, including
+// the storage of accounts split across multiple chunks.
+func TestSnapshotRestore(t *testing.T) {
+	srcDb, root, accounts := makeSnapshotTestState()
+
+	chunks, blobs := generateTestSnapshot(t, srcDb, root, 256)
+	if len(chunks) < 10 {
+		t.Fatalf("too few chunks generated: %d", len(chunks))
+	}
+	// Snapshot generation must be deterministic
+	again, _ := generateTestSnapshot(t, srcDb, root, 256)
+	if len(again) != len(chunks) {
+		t.Fatalf("regenerated chunk count mismatch: have %d, want %d", len(again), len(chunks))
+	}
+	for i := range chunks {
+		if again[i] != chunks[i] {
+			t.Fatalf("regenerated chunk %d mismatch: have %x, want %x", i, again[i], chunks[i])
+		}
+	}
+	// Restore the snapshot, the state root appearing only when finished
+	dstDb, _ := ethdb.NewMemDatabase()
+	restorer := NewSnapshotRestorer(dstDb, root)
+	for i, hash := range chunks {
+		if err := restorer.Feed(blobs[hash]); err != nil {
+			t.Fatalf("failed to feed chunk %d: %v", i, err)
+		}
+	}
+	if _, err := dstDb.Get(root[:]); err == nil {
+		t.Fatalf("state root present before finishing the restoration")
+	}
+	if err := restorer.Finish(); err != nil {
+		t.Fatalf("failed to finish restoration: %v", err)
+	}
+	checkStateAccounts(t, dstDb, root, accounts)
+
+	srcState, _ := New(root, srcDb)
+	dstState, _ := New(root, dstDb)
+	for i, acc := range accounts {
+		for j := 0; j < i%4*16; j++ {
+			key := common.BytesToHash([]byte{byte(j)})
+			if have, want := dstState.GetState(acc.address, key), srcState.GetState(acc.address, key); have != want {
+				t.Errorf("account %d, slot %d: storage mismatch: have %x, want %x", i, j, have, want)
+			}
+		}
+	}
+}
+
+// Tests that restoring an incomplete or mismatching snapshot fails.
+func TestSnapshotRestoreInvalid(t *testing.T) {
+	srcDb, root, _ := makeSnapshotTestState()
+	chunks, blobs := generateTestSnapshot(t, srcDb, root, 256)
+
+	// Skipping a chunk must be detected
+	dstDb, _ := ethdb.NewMemDatabase()
+	restorer := NewSnapshotRestorer(dstDb, root)
+
+	var err error
+	for i, hash := range chunks {
+		if i == len(chunks)/2 {
+			continue
+		}
+		if err = restorer.Feed(blobs[hash]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = restorer.Finish()
+	}
+	if err == nil {
+		t.Fatalf("incomplete snapshot restored")
+	}
+	if _, err := dstDb.Get(root[:]); err == nil {
+		t.Fatalf("state root present after failed restoration")
+	}
+	// Restoring a complete snapshot against the wrong root must be detected
+	dstDb, _ = ethdb.NewMemDatabase()
+	restorer = NewSnapshotRestorer(dstDb, common.Hash{1})
+	for i, hash := range chunks {
+		if err := restorer.Feed(blobs[hash]); err != nil {
+			t.Fatalf("failed to feed chunk %d: %v", i, err)
+		}
+	}
+	if err := restorer.Finish(); err == nil {
+		t.Fatalf("snapshot of different state restored")
+	}
+}
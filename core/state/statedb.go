@@ -22,6 +22,7 @@ import (
 	"math/big"
 	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/core/types"
@@ -43,6 +44,9 @@ const (
 
 	// Number of codehash->size associations to keep.
 	codeSizeCacheSize = 100000
+
+	// Number of codehash->code associations to keep.
+	codeCacheSize = 1024
 )
 
 type revision struct {
@@ -60,6 +64,8 @@ type StateDB struct {
 	trie          *trie.SecureTrie
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
+	codeCache     *lru.Cache // Contract code by hash, shared by all accounts deploying it
+	cacheGenLimit uint32     // Temporary cap on MaxTrieCacheGen for newly opened tries (0 = no cap, atomic)
 
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
@@ -77,7 +83,7 @@ type StateDB struct {
 
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
-	journal        journal
+	journal        *journal
 	validRevisions []revision
 	nextRevisionId int
 
@@ -91,15 +97,18 @@ func New(root common.Hash, db ethdb.Database) (*StateDB, error) {
 		return nil, err
 	}
 	csc, _ := lru.New(codeSizeCacheSize)
+	cc, _ := lru.New(codeCacheSize)
 	return &StateDB{
 		db:                db,
 		trie:              tr,
 		codeSizeCache:     csc,
+		codeCache:         cc,
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		refund:            new(big.Int),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
+		journal:           newJournal(),
 	}, nil
 }
 
@@ -117,11 +126,14 @@ func (self *StateDB) New(root common.Hash) (*StateDB, error) {
 		db:                self.db,
 		trie:              tr,
 		codeSizeCache:     self.codeSizeCache,
+		codeCache:         self.codeCache,
+		cacheGenLimit:     atomic.LoadUint32(&self.cacheGenLimit),
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		refund:            new(big.Int),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
+		journal:           newJournal(),
 	}, nil
 }
 
@@ -158,7 +170,22 @@ func (self *StateDB) openTrie(root common.Hash) (*trie.SecureTrie, error) {
 			return &tr, nil
 		}
 	}
-	return trie.NewSecure(root, self.db, MaxTrieCacheGen)
+	return trie.NewSecure(root, self.db, self.trieCacheGen())
+}
+
+// LimitTrieCacheGen temporarily caps the number of trie node generations kept in
+// memory by tries opened from this state (and the states derived from it) below
+// MaxTrieCacheGen. A limit of zero lifts the cap, restoring the configured value.
+func (self *StateDB) LimitTrieCacheGen(limit uint16) {
+	atomic.StoreUint32(&self.cacheGenLimit, uint32(limit))
+}
+
+// trieCacheGen returns the trie cache generation limit currently in effect.
+func (self *StateDB) trieCacheGen() uint16 {
+	if limit := uint16(atomic.LoadUint32(&self.cacheGenLimit)); limit != 0 && limit < MaxTrieCacheGen {
+		return limit
+	}
+	return MaxTrieCacheGen
 }
 
 func (self *StateDB) pushTrie(t *trie.SecureTrie) {
@@ -180,7 +207,7 @@ func (self *StateDB) StartRecord(thash, bhash common.Hash, ti int) {
 }
 
 func (self *StateDB) AddLog(log *types.Log) {
-	self.journal = append(self.journal, addLogChange{txhash: self.thash})
+	self.journal.append(addLogChange{txhash: self.thash})
 
 	log.TxHash = self.thash
 	log.BlockHash = self.bhash
@@ -205,7 +232,7 @@ func (self *StateDB) Logs() []*types.Log {
 // AddPreimage records a SHA3 preimage seen by the VM.
 func (self *StateDB) AddPreimage(hash common.Hash, preimage []byte) {
 	if _, ok := self.preimages[hash]; !ok {
-		self.journal = append(self.journal, addPreimageChange{hash: hash})
+		self.journal.append(addPreimageChange{hash: hash})
 		pi := make([]byte, len(preimage))
 		copy(pi, preimage)
 		self.preimages[hash] = pi
@@ -218,7 +245,7 @@ func (self *StateDB) Preimages() map[common.Hash][]byte {
 }
 
 func (self *StateDB) AddRefund(gas *big.Int) {
-	self.journal = append(self.journal, refundChange{prev: new(big.Int).Set(self.refund)})
+	self.journal.append(refundChange{prev: new(big.Int).Set(self.refund)})
 	self.refund.Add(self.refund, gas)
 }
 
@@ -256,7 +283,7 @@ func (self *StateDB) GetNonce(addr common.Address) uint64 {
 func (self *StateDB) GetCode(addr common.Address) []byte {
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
-		code := stateObject.Code(self.db)
+		code := stateObject.Code()
 		key := common.BytesToHash(stateObject.CodeHash())
 		self.codeSizeCache.Add(key, len(code))
 		return code
@@ -273,13 +300,41 @@ func (self *StateDB) GetCodeSize(addr common.Address) int {
 	if cached, ok := self.codeSizeCache.Get(key); ok {
 		return cached.(int)
 	}
-	size := len(stateObject.Code(self.db))
+	size := len(stateObject.Code())
 	if stateObject.dbErr == nil {
 		self.codeSizeCache.Add(key, size)
 	}
 	return size
 }
 
+// CodeByHash retrieves contract code by its hash, regardless of which accounts
+// it is deployed at. Database entries not hashing to the requested key are never
+// returned, so arbitrary 32 byte keys can't be used to read other data.
+func (self *StateDB) CodeByHash(hash common.Hash) ([]byte, error) {
+	if hash == common.BytesToHash(emptyCodeHash) {
+		return nil, nil
+	}
+	return self.loadCode(hash)
+}
+
+// loadCode retrieves contract code from the shared code cache, or from the
+// database if not yet cached.
+func (self *StateDB) loadCode(hash common.Hash) ([]byte, error) {
+	if cached, ok := self.codeCache.Get(hash); ok {
+		return cached.([]byte), nil
+	}
+	code, err := self.db.Get(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	if crypto.Keccak256Hash(code) != hash {
+		return nil, fmt.Errorf("no contract code with hash %x", hash)
+	}
+	self.codeCache.Add(hash, code)
+	self.codeSizeCache.Add(hash, len(code))
+	return code, nil
+}
+
 func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
 	stateObject := self.getStateObject(addr)
 	if stateObject == nil {
@@ -362,7 +417,7 @@ func (self *StateDB) Suicide(addr common.Address) bool {
 	if stateObject == nil {
 		return false
 	}
-	self.journal = append(self.journal, suicideChange{
+	self.journal.append(suicideChange{
 		account:     &addr,
 		prev:        stateObject.suicided,
 		prevbalance: new(big.Int).Set(stateObject.Balance()),
@@ -414,7 +469,7 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return nil
 	}
 	// Insert into the live set.
-	obj := newObject(self, addr, data, self.MarkStateObjectDirty)
+	obj := newObject(self, addr, data)
 	self.setStateObject(obj)
 	return obj
 }
@@ -442,12 +497,11 @@ func (self *StateDB) MarkStateObjectDirty(addr common.Address) {
 // the given address, it is overwritten and returned as the second return value.
 func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
 	prev = self.getStateObject(addr)
-	newobj = newObject(self, addr, Account{}, self.MarkStateObjectDirty)
-	newobj.setNonce(0) // sets the object to dirty
+	newobj = newObject(self, addr, Account{})
 	if prev == nil {
-		self.journal = append(self.journal, createObjectChange{account: &addr})
+		self.journal.append(createObjectChange{account: &addr})
 	} else {
-		self.journal = append(self.journal, resetObjectChange{prev: prev})
+		self.journal.append(resetObjectChange{prev: prev})
 	}
 	self.setStateObject(newobj)
 	return newobj, prev
@@ -503,16 +557,28 @@ func (self *StateDB) Copy() *StateDB {
 		trie:              self.trie,
 		pastTries:         self.pastTries,
 		codeSizeCache:     self.codeSizeCache,
+		codeCache:         self.codeCache,
+		cacheGenLimit:     atomic.LoadUint32(&self.cacheGenLimit),
 		stateObjects:      make(map[common.Address]*stateObject, len(self.stateObjectsDirty)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.stateObjectsDirty)),
 		refund:            new(big.Int).Set(self.refund),
 		logs:              make(map[common.Hash][]*types.Log, len(self.logs)),
 		logSize:           self.logSize,
 		preimages:         make(map[common.Hash][]byte),
+		journal:           newJournal(),
+	}
+	// Copy the dirty states, logs, and preimages. Objects modified since the last
+	// finalisation stay in the new journal's dirty set so they get finalised too.
+	for addr := range self.journal.dirties {
+		if object, exist := self.stateObjects[addr]; exist {
+			state.stateObjects[addr] = object.deepCopy(state)
+			state.journal.dirties[addr] = struct{}{}
+		}
 	}
-	// Copy the dirty states, logs, and preimages
 	for addr := range self.stateObjectsDirty {
-		state.stateObjects[addr] = self.stateObjects[addr].deepCopy(state, state.MarkStateObjectDirty)
+		if _, exist := state.stateObjects[addr]; !exist {
+			state.stateObjects[addr] = self.stateObjects[addr].deepCopy(state)
+		}
 		state.stateObjectsDirty[addr] = struct{}{}
 	}
 	for hash, logs := range self.logs {
@@ -529,7 +595,7 @@ func (self *StateDB) Copy() *StateDB {
 func (self *StateDB) Snapshot() int {
 	id := self.nextRevisionId
 	self.nextRevisionId++
-	self.validRevisions = append(self.validRevisions, revision{id, len(self.journal)})
+	self.validRevisions = append(self.validRevisions, revision{id, self.journal.length()})
 	return id
 }
 
@@ -545,10 +611,7 @@ func (self *StateDB) RevertToSnapshot(revid int) {
 	snapshot := self.validRevisions[idx].journalIndex
 
 	// Replay the journal to undo changes.
-	for i := len(self.journal) - 1; i >= snapshot; i-- {
-		self.journal[i].undo(self)
-	}
-	self.journal = self.journal[:snapshot]
+	self.journal.revert(self, snapshot)
 
 	// Remove invalidated snapshots from the stack.
 	self.validRevisions = self.validRevisions[:idx]
@@ -561,21 +624,38 @@ func (self *StateDB) GetRefund() *big.Int {
 	return self.refund
 }
 
-// IntermediateRoot computes the current root hash of the state trie.
-// It is called in between transactions to get the root hash that
-// goes into transaction receipts.
-func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
-	for addr := range s.stateObjectsDirty {
-		stateObject := s.stateObjects[addr]
+// Finalise flushes the accounts modified since the last finalisation into the
+// account trie, deleting the suicided (and optionally the empty) ones, and marks
+// them dirty for the next commit. Empty account deletion implements the EIP158
+// (EIP161) state clearing and must only be requested once that fork is active.
+// Every modification counts as touching an account, but reverted touches don't,
+// with the exception of the RIPEMD precompile (see touchChange). Only the accounts
+// touched since the previous call are processed, so finalising after every
+// transaction doesn't repeatedly rewrite everything modified earlier in the block.
+func (s *StateDB) Finalise(deleteEmptyObjects bool) {
+	for addr := range s.journal.dirties {
+		stateObject, exist := s.stateObjects[addr]
+		if !exist {
+			// The object was dropped by a revert (e.g. its creation was undone)
+			continue
+		}
 		if stateObject.suicided || (deleteEmptyObjects && stateObject.empty()) {
 			s.deleteStateObject(stateObject)
 		} else {
 			stateObject.updateRoot(s.db)
 			s.updateStateObject(stateObject)
 		}
+		s.stateObjectsDirty[addr] = struct{}{}
 	}
 	// Invalidate journal because reverting across transactions is not allowed.
 	s.clearJournalAndRefund()
+}
+
+// IntermediateRoot computes the current root hash of the state trie.
+// It is called in between transactions to get the root hash that
+// goes into transaction receipts.
+func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
+	s.Finalise(deleteEmptyObjects)
 	return s.trie.Hash()
 }
 
@@ -585,6 +665,12 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 // DeleteSuicides should not be used for consensus related updates
 // under any circumstances.
 func (s *StateDB) DeleteSuicides() {
+	// Pull in the journalled modifications before dropping the journal
+	for addr := range s.journal.dirties {
+		if _, exist := s.stateObjects[addr]; exist {
+			s.stateObjectsDirty[addr] = struct{}{}
+		}
+	}
 	// Reset refund so that any used-gas calculations can use this method.
 	s.clearJournalAndRefund()
 
@@ -618,7 +704,7 @@ func (s *StateDB) CommitBatch(deleteEmptyObjects bool) (root common.Hash, batch
 }
 
 func (s *StateDB) clearJournalAndRefund() {
-	s.journal = nil
+	s.journal.reset()
 	s.validRevisions = s.validRevisions[:0]
 	s.refund = new(big.Int)
 }
@@ -627,19 +713,34 @@ func (s *StateDB) clearJournalAndRefund() {
 func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (root common.Hash, err error) {
 	defer s.clearJournalAndRefund()
 
-	// Commit objects to the trie.
-	for addr, stateObject := range s.stateObjects {
-		_, isDirty := s.stateObjectsDirty[addr]
+	// Pull in the journalled modifications not yet finalised
+	for addr := range s.journal.dirties {
+		if _, exist := s.stateObjects[addr]; exist {
+			s.stateObjectsDirty[addr] = struct{}{}
+		}
+	}
+	// Commit the dirty objects to the trie, writing identical code only once
+	var codes map[common.Hash]struct{}
+	for addr := range s.stateObjectsDirty {
+		stateObject := s.stateObjects[addr]
 		switch {
-		case stateObject.suicided || (isDirty && deleteEmptyObjects && stateObject.empty()):
+		case stateObject.suicided || (deleteEmptyObjects && stateObject.empty()):
 			// If the object has been removed, don't bother syncing it
 			// and just mark it for deletion in the trie.
 			s.deleteStateObject(stateObject)
-		case isDirty:
+		default:
 			// Write any contract code associated with the state object
 			if stateObject.code != nil && stateObject.dirtyCode {
-				if err := dbw.Put(stateObject.CodeHash(), stateObject.code); err != nil {
-					return common.Hash{}, err
+				hash := common.BytesToHash(stateObject.CodeHash())
+				if _, written := codes[hash]; !written {
+					if err := dbw.Put(hash[:], stateObject.code); err != nil {
+						return common.Hash{}, err
+					}
+					if codes == nil {
+						codes = make(map[common.Hash]struct{})
+					}
+					codes[hash] = struct{}{}
+					s.codeCache.Add(hash, []byte(stateObject.code))
 				}
 				stateObject.dirtyCode = false
 			}
@@ -30,6 +30,7 @@ import (
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/ethdb"
 )
 
@@ -364,12 +365,271 @@ func TestTouchDelete(t *testing.T) {
 
 	snapshot := state.Snapshot()
 	state.AddBalance(common.Address{}, new(big.Int))
-	if len(state.stateObjectsDirty) != 1 {
+	if len(state.journal.dirties) != 1 {
 		t.Fatal("expected one dirty state object")
 	}
 
 	state.RevertToSnapshot(snapshot)
-	if len(state.stateObjectsDirty) != 0 {
+	if len(state.journal.dirties) != 0 {
 		t.Fatal("expected no dirty state object")
 	}
 }
+
+// Tests that finalising only processes the accounts modified since the previous
+// finalisation, and that unfinalised modifications survive copying the state.
+func TestFinaliseDirtySet(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, db)
+
+	a, b := common.BytesToAddress([]byte{0x01}), common.BytesToAddress([]byte{0x02})
+	state.AddBalance(a, big.NewInt(1))
+	state.IntermediateRoot(false)
+
+	state.AddBalance(b, big.NewInt(2))
+	if len(state.journal.dirties) != 1 {
+		t.Fatalf("journal dirty set size mismatch: have %d, want %d", len(state.journal.dirties), 1)
+	}
+	if _, ok := state.journal.dirties[b]; !ok {
+		t.Fatalf("modified account missing from journal dirty set")
+	}
+	// A copy must account for the pending modification just like the original
+	copy := state.Copy()
+	if have, want := copy.IntermediateRoot(false), state.IntermediateRoot(false); have != want {
+		t.Fatalf("copied state root mismatch: have %x, want %x", have, want)
+	}
+	if len(state.journal.dirties) != 0 || len(state.stateObjectsDirty) != 2 {
+		t.Fatalf("finalised dirty sets mismatch: have %d pending, %d dirty, want 0 pending, 2 dirty", len(state.journal.dirties), len(state.stateObjectsDirty))
+	}
+	// Committing must persist both finalised accounts
+	root, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	reopened, _ := New(root, db)
+	if reopened.GetBalance(a).Int64() != 1 || reopened.GetBalance(b).Int64() != 2 {
+		t.Fatalf("committed balances mismatch: have %v, %v, want 1, 2", reopened.GetBalance(a), reopened.GetBalance(b))
+	}
+}
+
+// Benchmarks reverting deeply nested snapshots, each of which modified a single
+// storage slot, as done by contracts issuing many nested calls.
+func BenchmarkNestedSnapshotRevert(b *testing.B) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, db)
+	addr := common.BytesToAddress([]byte{0x01})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		snapshots := make([]int, 1024)
+		for j := range snapshots {
+			snapshots[j] = state.Snapshot()
+			state.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(i+1))))
+		}
+		for j := len(snapshots) - 1; j >= 0; j-- {
+			state.RevertToSnapshot(snapshots[j])
+		}
+	}
+}
+
+// Tests the EIP158 empty account deletion rules: only touched empty accounts
+// are removed, only if the fork is active, reverted touches don't count (except
+// for the RIPEMD precompile), while suicided accounts are always removed.
+func TestEmptyAccountDeletion(t *testing.T) {
+	var (
+		empty   = common.BytesToAddress([]byte{0x01})
+		drained = common.BytesToAddress([]byte{0x02})
+	)
+	tests := []struct {
+		name   string
+		fork   bool
+		mutate func(*StateDB)
+		exists map[common.Address]bool
+	}{
+		{
+			name:   "untouched empty account",
+			fork:   true,
+			mutate: func(s *StateDB) {},
+			exists: map[common.Address]bool{empty: true, drained: true, ripemd: true},
+		},
+		{
+			name:   "touched empty account before fork",
+			fork:   false,
+			mutate: func(s *StateDB) { s.AddBalance(empty, new(big.Int)) },
+			exists: map[common.Address]bool{empty: true, drained: true, ripemd: true},
+		},
+		{
+			name:   "touched empty account after fork",
+			fork:   true,
+			mutate: func(s *StateDB) { s.AddBalance(empty, new(big.Int)) },
+			exists: map[common.Address]bool{empty: false, drained: true, ripemd: true},
+		},
+		{
+			name: "reverted touch",
+			fork: true,
+			mutate: func(s *StateDB) {
+				snap := s.Snapshot()
+				s.AddBalance(empty, new(big.Int))
+				s.RevertToSnapshot(snap)
+			},
+			exists: map[common.Address]bool{empty: true, drained: true, ripemd: true},
+		},
+		{
+			name: "reverted ripemd touch",
+			fork: true,
+			mutate: func(s *StateDB) {
+				snap := s.Snapshot()
+				s.AddBalance(ripemd, new(big.Int))
+				s.RevertToSnapshot(snap)
+			},
+			exists: map[common.Address]bool{empty: true, drained: true, ripemd: false},
+		},
+		{
+			name:   "drained account before fork",
+			fork:   false,
+			mutate: func(s *StateDB) { s.SubBalance(drained, big.NewInt(1)) },
+			exists: map[common.Address]bool{empty: true, drained: true, ripemd: true},
+		},
+		{
+			name:   "drained account after fork",
+			fork:   true,
+			mutate: func(s *StateDB) { s.SubBalance(drained, big.NewInt(1)) },
+			exists: map[common.Address]bool{empty: true, drained: false, ripemd: true},
+		},
+		{
+			name:   "suicided account before fork",
+			fork:   false,
+			mutate: func(s *StateDB) { s.Suicide(drained) },
+			exists: map[common.Address]bool{empty: true, drained: false, ripemd: true},
+		},
+	}
+	for _, tt := range tests {
+		// Create a pre-fork state containing empty accounts
+		db, _ := ethdb.NewMemDatabase()
+		state, _ := New(common.Hash{}, db)
+
+		state.CreateAccount(empty)
+		state.CreateAccount(ripemd)
+		state.AddBalance(drained, big.NewInt(1))
+		root, _ := state.Commit(false)
+
+		// Apply the mutation, finalise it and check the persisted accounts
+		state, _ = New(root, db)
+		tt.mutate(state)
+		state.Finalise(tt.fork)
+		root, err := state.Commit(tt.fork)
+		if err != nil {
+			t.Fatalf("%s: failed to commit state: %v", tt.name, err)
+		}
+		state, _ = New(root, db)
+		for addr, want := range tt.exists {
+			if have := state.Exist(addr); have != want {
+				t.Errorf("%s: account %x existence mismatch: have %v, want %v", tt.name, addr, have, want)
+			}
+		}
+	}
+}
+
+// Tests that contract code deployed at many accounts is written to the database
+// only once, and is served by hash from the shared code cache.
+func TestCodeDeduplication(t *testing.T) {
+	mem, _ := ethdb.NewMemDatabase()
+	db := &putCountingDatabase{MemDatabase: mem, puts: make(map[string]int), gets: make(map[string]int)}
+	state, _ := New(common.Hash{}, db)
+
+	code := []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	for i := byte(0); i < 16; i++ {
+		state.SetCode(common.BytesToAddress([]byte{i}), code)
+	}
+	root, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	hash := crypto.Keccak256Hash(code)
+	if puts := db.puts[string(hash[:])]; puts != 1 {
+		t.Fatalf("code writes mismatch: have %d, want %d", puts, 1)
+	}
+	// Reopen the state and ensure the code is loaded only once for all accounts
+	state, _ = state.New(root)
+	state.codeCache.Purge()
+	for i := byte(0); i < 16; i++ {
+		if have := state.GetCode(common.BytesToAddress([]byte{i})); !bytes.Equal(have, code) {
+			t.Fatalf("account %d: code mismatch: have %x, want %x", i, have, code)
+		}
+	}
+	if gets := db.gets[string(hash[:])]; gets != 1 {
+		t.Fatalf("code reads mismatch: have %d, want %d", gets, 1)
+	}
+	if have, err := state.CodeByHash(hash); err != nil || !bytes.Equal(have, code) {
+		t.Fatalf("code by hash mismatch: have %x, %v, want %x", have, err, code)
+	}
+	// Ensure other data stored under 32 byte keys isn't served as code
+	key := common.BytesToHash([]byte("not a content addressed key"))
+	db.Put(key[:], []byte("private data"))
+	if have, err := state.CodeByHash(key); err == nil {
+		t.Fatalf("non-code database entry served as code: %x", have)
+	}
+}
+
+// putCountingDatabase is a memory database counting the reads and writes of
+// each key.
+type putCountingDatabase struct {
+	*ethdb.MemDatabase
+	puts, gets map[string]int
+}
+
+func (db *putCountingDatabase) Put(key []byte, value []byte) error {
+	db.puts[string(key)]++
+	return db.MemDatabase.Put(key, value)
+}
+
+func (db *putCountingDatabase) Get(key []byte) ([]byte, error) {
+	db.gets[string(key)]++
+	return db.MemDatabase.Get(key)
+}
+
+func (db *putCountingDatabase) NewBatch() ethdb.Batch {
+	return &putCountingBatch{Batch: db.MemDatabase.NewBatch(), db: db}
+}
+
+type putCountingBatch struct {
+	ethdb.Batch
+	db *putCountingDatabase
+}
+
+func (b *putCountingBatch) Put(key []byte, value []byte) error {
+	b.db.puts[string(key)]++
+	return b.Batch.Put(key, value)
+}
+
+// Tests that the trie cache generation limit is scoped to a state and the states
+// derived from it, leaving other states at the configured maximum.
+func TestTrieCacheGenLimit(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	limited, _ := New(common.Hash{}, db)
+	unlimited, _ := New(common.Hash{}, db)
+
+	limited.LimitTrieCacheGen(1)
+	if gen := limited.trieCacheGen(); gen != 1 {
+		t.Fatalf("limited state generation mismatch: have %d, want %d", gen, 1)
+	}
+	if gen := unlimited.trieCacheGen(); gen != MaxTrieCacheGen {
+		t.Fatalf("unlimited state generation mismatch: have %d, want %d", gen, MaxTrieCacheGen)
+	}
+	derived, _ := limited.New(common.Hash{})
+	if gen := derived.trieCacheGen(); gen != 1 {
+		t.Fatalf("derived state generation mismatch: have %d, want %d", gen, 1)
+	}
+	if gen := limited.Copy().trieCacheGen(); gen != 1 {
+		t.Fatalf("copied state generation mismatch: have %d, want %d", gen, 1)
+	}
+	// Lifting the limit, or setting it above the maximum, restores the configured value
+	limited.LimitTrieCacheGen(MaxTrieCacheGen + 1)
+	if gen := limited.trieCacheGen(); gen != MaxTrieCacheGen {
+		t.Fatalf("over-limit generation mismatch: have %d, want %d", gen, MaxTrieCacheGen)
+	}
+	limited.LimitTrieCacheGen(0)
+	if gen := limited.trieCacheGen(); gen != MaxTrieCacheGen {
+		t.Fatalf("lifted generation mismatch: have %d, want %d", gen, MaxTrieCacheGen)
+	}
+}
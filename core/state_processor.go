@@ -19,6 +19,8 @@ package core
 import (
 	"math/big"
 
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/core/vm"
@@ -64,6 +66,10 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		allLogs      []*types.Log
 		gp           = new(GasPool).AddGas(block.GasLimit())
 	)
+	author, err := p.bc.engine.Author(header)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	// Mutate the the block and state according to any hard-fork specs
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		ApplyDAOHardFork(statedb)
@@ -72,29 +78,34 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	for i, tx := range block.Transactions() {
 		//fmt.Println("tx:", i)
 		statedb.StartRecord(tx.Hash(), block.Hash(), i)
-		receipt, _, err := ApplyTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas, cfg)
+		receipt, _, err := ApplyTransaction(p.config, p.bc, &author, gp, statedb, header, tx, totalUsedGas, cfg)
 		if err != nil {
 			return nil, nil, nil, err
 		}
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
 	}
-	AccumulateRewards(statedb, header, block.Uncles())
-
+	if err := p.bc.engine.Finalize(p.bc, statedb, header, block.Uncles()); err != nil {
+		return nil, nil, nil, err
+	}
 	return receipts, allLogs, totalUsedGas, err
 }
 
 // ApplyTransaction attempts to apply a transaction to the given state database
-// and uses the input parameters for its environment. It returns the receipt
+// and uses the input parameters for its environment. The fees are credited to
+// the author, or the coinbase of the header if nil. It returns the receipt
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
-func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int, cfg vm.Config) (*types.Receipt, *big.Int, error) {
+func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int, cfg vm.Config) (*types.Receipt, *big.Int, error) {
 	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := CheckFreeze(config, statedb, header.Number, msg.From(), msg.To()); err != nil {
+		return nil, nil, err
+	}
 	// Create a new context to be used in the EVM environment
-	context := NewEVMContext(msg, header, bc)
+	context := NewEVMContext(msg, header, bc, author)
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
@@ -103,6 +114,7 @@ func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, gp *GasPool, s
 	if err != nil {
 		return nil, nil, err
 	}
+	applyFreezeUpdate(config, statedb, header.Number, msg.From(), msg.To(), msg.Data())
 
 	// Update the state with pending changes
 	usedGas.Add(usedGas, gas)
@@ -123,22 +135,54 @@ func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, gp *GasPool, s
 	return receipt, gas, err
 }
 
-// AccumulateRewards credits the coinbase of the given block with the
+// AccumulateRewards credits the author of the given block with the
 // mining reward. The total reward consists of the static block reward
-// and rewards for included uncles. The coinbase of each uncle block is
+// and rewards for included uncles. The author of each uncle block is
 // also rewarded.
-func AccumulateRewards(statedb *state.StateDB, header *types.Header, uncles []*types.Header) {
+func AccumulateRewards(engine consensus.Engine, statedb *state.StateDB, header *types.Header, uncles []*types.Header) error {
+	reward, uncleRewards := BlockRewards(header, uncles)
+	for i, uncle := range uncles {
+		author, err := engine.Author(uncle)
+		if err != nil {
+			return err
+		}
+		statedb.AddBalance(author, uncleRewards[i])
+	}
+	author, err := engine.Author(header)
+	if err != nil {
+		return err
+	}
+	statedb.AddBalance(author, reward)
+	return nil
+}
+
+// BlockRewards returns the rewards AccumulateRewards credits for the given block:
+// the reward of its author, including the inclusion rewards of its uncles, and
+// the rewards of the uncle authors, in the order of the uncles.
+func BlockRewards(header *types.Header, uncles []*types.Header) (*big.Int, []*big.Int) {
 	reward := new(big.Int).Set(BlockReward)
-	r := new(big.Int)
-	for _, uncle := range uncles {
-		r.Add(uncle.Number, big8)
+	uncleRewards := make([]*big.Int, len(uncles))
+	for i, uncle := range uncles {
+		r := new(big.Int).Add(uncle.Number, big8)
 		r.Sub(r, header.Number)
 		r.Mul(r, BlockReward)
 		r.Div(r, big8)
-		statedb.AddBalance(uncle.Coinbase, r)
+		if r.Sign() < 0 {
+			r.SetUint64(0) // Uncles beyond eight generations, allowed by raised depth limits, earn nothing
+		}
+		uncleRewards[i] = r
+
+		reward.Add(reward, new(big.Int).Div(BlockReward, big32))
+	}
+	return reward, uncleRewards
+}
 
-		r.Div(BlockReward, big32)
-		reward.Add(reward, r)
+// BlockIssuance returns the amount of ether minted by the given block, the sum
+// of the rewards AccumulateRewards credits to its author and uncle authors.
+func BlockIssuance(header *types.Header, uncles []*types.Header) *big.Int {
+	issued, uncleRewards := BlockRewards(header, uncles)
+	for _, r := range uncleRewards {
+		issued.Add(issued, r)
 	}
-	statedb.AddBalance(header.Coinbase, reward)
+	return issued
 }
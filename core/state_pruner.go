@@ -0,0 +1,171 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/rlp"
+	"github.com/expanse-org/go-expanse/trie"
+)
+
+// MinPruneRetention is the minimum number of recent states a pruning node must
+// keep, so that it can still process the chain reorganisations it may face.
+const MinPruneRetention = 128
+
+var errPruningUnsupported = errors.New("state pruning not supported by the database")
+
+// PruneConfig defines which historical states the chain garbage collects.
+//
+// Pruning is a mark and sweep pass: every Interval imported blocks, the state
+// tries of the most recent Retention canonical blocks and the extra roots added
+// by other subsystems are marked, after which every other trie node is deleted
+// from the database. Contract code is never deleted, as it cannot be told apart
+// from other data keyed by its hash.
+type PruneConfig struct {
+	Retention uint64 // Number of most recent canonical blocks whose state is kept
+	Interval  uint64 // Number of blocks imported between two prunings
+}
+
+// DefaultPruneConfig contains the default settings for state pruning.
+var DefaultPruneConfig = PruneConfig{
+	Retention: 1024,
+	Interval:  4096,
+}
+
+// pruneState deletes every trie node of the database not reachable from any of
+// the given state roots or plain trie roots, returning the number of nodes
+// deleted. Roots missing from the database are skipped.
+func pruneState(db ethdb.Database, states []common.Hash, tries []common.Hash) (int, error) {
+	marked := make(map[common.Hash]struct{})
+	for i, root := range append(states, tries...) {
+		if _, err := db.Get(root[:]); err != nil {
+			continue
+		}
+		if err := markTrie(db, root, marked, i < len(states)); err != nil {
+			return 0, fmt.Errorf("root %x: %v", root, err)
+		}
+	}
+	deleted := 0
+	err := forEachEntry(db, func(key, value []byte) error {
+		if _, ok := marked[common.BytesToHash(key)]; ok || !isTrieNode(key, value) {
+			return nil
+		}
+		if err := db.Delete(key); err != nil {
+			return err
+		}
+		deleted++
+		return nil
+	})
+	return deleted, err
+}
+
+// markTrie marks every node of the trie with the given root, along with all the
+// storage tries of the accounts if the trie is a state trie. Subtries already
+// marked are not descended into.
+func markTrie(db ethdb.Database, root common.Hash, marked map[common.Hash]struct{}, accounts bool) error {
+	if _, ok := marked[root]; ok {
+		return nil
+	}
+	t, err := trie.New(root, db)
+	if err != nil {
+		return err
+	}
+	it := trie.NewNodeIterator(t)
+	for descend := true; it.Next(descend); {
+		descend = true
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if _, ok := marked[hash]; ok {
+				descend = false
+				continue
+			}
+			marked[hash] = struct{}{}
+		}
+		if accounts && it.Leaf() {
+			var account state.Account
+			if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+				return err
+			}
+			if err := markTrie(db, account.Root, marked, false); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
+
+// isTrieNode reports whether a database entry is a trie node, i.e. a short or a
+// full node keyed by its hash.
+func isTrieNode(key, value []byte) bool {
+	if len(key) != common.HashLength {
+		return false
+	}
+	content, rest, err := rlp.SplitList(value)
+	if err != nil || len(rest) != 0 {
+		return false
+	}
+	if n, err := rlp.CountValues(content); err != nil || (n != 2 && n != 17) {
+		return false
+	}
+	return bytes.Equal(crypto.Keccak256(value), key)
+}
+
+// canPrune reports whether the entries of the database can be iterated, which
+// sweeping the unmarked trie nodes requires.
+func canPrune(db ethdb.Database) bool {
+	switch db.(type) {
+	case *ethdb.LDBDatabase, *ethdb.MemDatabase:
+		return true
+	}
+	return false
+}
+
+// forEachEntry calls fn for every entry of the database, stopping at the first
+// error returned.
+func forEachEntry(db ethdb.Database, fn func(key, value []byte) error) error {
+	switch db := db.(type) {
+	case *ethdb.LDBDatabase:
+		it := db.NewIterator()
+		defer it.Release()
+
+		for it.Next() {
+			if err := fn(it.Key(), it.Value()); err != nil {
+				return err
+			}
+		}
+		return it.Error()
+
+	case *ethdb.MemDatabase:
+		for _, key := range db.Keys() {
+			value, err := db.Get(key)
+			if err != nil {
+				continue
+			}
+			if err := fn(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errPruningUnsupported
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+	"github.com/expanse-org/go-expanse/trie"
+)
+
+// newPruningTestChain creates a chain whose every block changes both the account
+// trie and the storage trie of a contract (storing the block number), returning
+// the chain database, the blockchain and the generated blocks.
+func newPruningTestChain(t *testing.T, n int) (*ethdb.MemDatabase, *BlockChain, []*types.Block) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		key, _   = crypto.GenerateKey()
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.Address{0xc0}
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address:  {Balance: big.NewInt(1000000000)},
+				contract: {Balance: new(big.Int), Code: []byte{0x43, 0x60, 0x00, 0x55}}, // NUMBER PUSH1 0 SSTORE
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	gspec.MustCommit(gendb)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, gendb, n, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), contract, big.NewInt(1), big.NewInt(100000), new(big.Int), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	})
+	blockchain, err := NewBlockChain(db, gspec.Config, NewPowEngine(pow.FakePow{}), new(event.TypeMux), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	return db, blockchain, blocks
+}
+
+// Tests that pruning deletes the states older than the retention window, while
+// keeping the recent states intact along with the other chain data and the
+// registered extra tries.
+func TestStatePruning(t *testing.T) {
+	db, blockchain, blocks := newPruningTestChain(t, 200)
+	defer blockchain.Stop()
+
+	if err := blockchain.SetPruning(&PruneConfig{Retention: MinPruneRetention, Interval: 150}); err != nil {
+		t.Fatalf("failed to enable pruning: %v", err)
+	}
+	// Store an unrelated trie that must survive pruning
+	extra, _ := trie.New(common.Hash{}, db)
+	extra.Update([]byte("key"), []byte("value"))
+	extraRoot, err := extra.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit extra trie: %v", err)
+	}
+	blockchain.AddPruneRoots(func() []common.Hash { return []common.Hash{extraRoot} })
+
+	// Import the chain in two batches, the first one not yet triggering pruning
+	if _, err := blockchain.InsertChain(blocks[:100]); err != nil {
+		t.Fatalf("failed to import first batch: %v", err)
+	}
+	if _, err := state.New(blocks[0].Root(), db); err != nil {
+		t.Fatalf("state pruned before interval: %v", err)
+	}
+	if _, err := blockchain.InsertChain(blocks[100:]); err != nil {
+		t.Fatalf("failed to import second batch: %v", err)
+	}
+	if last := GetLastPruneNumber(db); last != 200 {
+		t.Fatalf("last prune number mismatch: have %d, want %d", last, 200)
+	}
+	// States before the retention window must be gone, the ones within intact
+	for _, block := range blocks {
+		number := block.NumberU64()
+
+		statedb, err := state.New(block.Root(), db)
+		if number <= 200-MinPruneRetention {
+			if err == nil {
+				t.Errorf("block %d: state not pruned", number)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("block %d: retained state missing: %v", number, err)
+		}
+		it := state.NewNodeIterator(statedb)
+		for it.Next() {
+		}
+		if it.Error != nil {
+			t.Fatalf("block %d: retained state incomplete: %v", number, it.Error)
+		}
+	}
+	// The rest of the chain data and the extra trie must be untouched
+	for _, block := range blocks {
+		for _, tx := range block.Transactions() {
+			if have, _, _, _ := GetTransaction(db, tx.Hash()); have == nil {
+				t.Fatalf("block %d: transaction %x missing", block.NumberU64(), tx.Hash())
+			}
+		}
+	}
+	if extra, err := trie.New(extraRoot, db); err != nil || string(extra.Get([]byte("key"))) != "value" {
+		t.Errorf("extra trie pruned: %v", err)
+	}
+	// The chain must still be extensible from its retained head state
+	more, _ := GenerateChain(params.TestChainConfig, blocks[len(blocks)-1], db, 1, nil)
+	if _, err := blockchain.InsertChain(more); err != nil {
+		t.Fatalf("failed to extend pruned chain: %v", err)
+	}
+}
+
+// Tests that without pruning every historical state is kept, and that pruning
+// can't be enabled with a retention too short for reorgs.
+func TestStatePruningDisabled(t *testing.T) {
+	db, blockchain, blocks := newPruningTestChain(t, 200)
+	defer blockchain.Stop()
+
+	if err := blockchain.SetPruning(&PruneConfig{Retention: MinPruneRetention - 1, Interval: 1}); err == nil {
+		t.Fatalf("pruning enabled with too short retention")
+	}
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	for _, block := range blocks {
+		if _, err := state.New(block.Root(), db); err != nil {
+			t.Fatalf("block %d: state missing: %v", block.NumberU64(), err)
+		}
+	}
+}
@@ -17,7 +17,6 @@
 package core
 
 import (
-	"errors"
 	"math/big"
 
 	"github.com/expanse-org/go-expanse/common"
@@ -28,8 +27,7 @@ import (
 )
 
 var (
-	Big0                         = big.NewInt(0)
-	errInsufficientBalanceForGas = errors.New("insufficient balance to pay for gas")
+	Big0 = big.NewInt(0)
 )
 
 /*
@@ -58,6 +56,7 @@ type StateTransition struct {
 	value      *big.Int
 	data       []byte
 	state      vm.StateDB
+	vmerr      error // error returned by the EVM execution, if any
 
 	evm *vm.EVM
 }
@@ -137,6 +136,39 @@ func ApplyMessage(evm *vm.EVM, msg Message, gp *GasPool) ([]byte, *big.Int, erro
 	return ret, gasUsed, err
 }
 
+// ExecutionResult holds the outcome of applying a message, including the error
+// raised inside the EVM, which ApplyMessage does not surface since it doesn't
+// affect consensus.
+type ExecutionResult struct {
+	UsedGas    *big.Int // Total gas used, including refunds
+	Err        error    // Error encountered during EVM execution (e.g. out of gas, revert)
+	ReturnData []byte   // Data returned by the EVM (or the revert data)
+}
+
+// Failed returns whether the EVM execution was aborted with an error.
+func (result *ExecutionResult) Failed() bool { return result.Err != nil }
+
+// Revert returns the raw revert data if the execution was reverted, nil otherwise.
+func (result *ExecutionResult) Revert() []byte {
+	if result.Err != vm.ErrExecutionReverted {
+		return nil
+	}
+	return common.CopyBytes(result.ReturnData)
+}
+
+// ApplyMessageResult is like ApplyMessage, but returns the full execution result
+// instead of dropping the EVM error. The returned error is still reserved for
+// core errors making the message invalid for the given state.
+func ApplyMessageResult(evm *vm.EVM, msg Message, gp *GasPool) (*ExecutionResult, error) {
+	st := NewStateTransition(evm, msg, gp)
+
+	ret, _, gasUsed, err := st.TransitionDb()
+	if err != nil {
+		return nil, err
+	}
+	return &ExecutionResult{UsedGas: gasUsed, Err: st.vmerr, ReturnData: ret}, nil
+}
+
 func (self *StateTransition) from() vm.AccountRef {
 	f := self.msg.From()
 	if !self.state.Exist(f) {
@@ -170,10 +202,13 @@ func (self *StateTransition) useGas(amount uint64) error {
 	return nil
 }
 
+// buyGas deducts the gas limit of the message from the block's gas pool and
+// its price from the sender, who must also be able to afford the transferred
+// value.
 func (self *StateTransition) buyGas() error {
 	mgas := self.msg.Gas()
 	if mgas.BitLen() > 64 {
-		return vm.ErrOutOfGas
+		return InvalidTxError(vm.ErrOutOfGas)
 	}
 
 	mgval := new(big.Int).Mul(mgas, self.gasPrice)
@@ -182,8 +217,8 @@ func (self *StateTransition) buyGas() error {
 		state  = self.state
 		sender = self.from()
 	)
-	if state.GetBalance(sender.Address()).Cmp(mgval) < 0 {
-		return errInsufficientBalanceForGas
+	if balance, cost := state.GetBalance(sender.Address()), new(big.Int).Add(mgval, self.value); balance.Cmp(cost) < 0 {
+		return &InsufficientFundsErr{Address: sender.Address(), Have: new(big.Int).Set(balance), Want: cost}
 	}
 	if err := self.gp.SubGas(mgas); err != nil {
 		return err
@@ -205,16 +240,14 @@ func (self *StateTransition) preCheck() (err error) {
 			return NonceError(msg.Nonce(), n)
 		}
 	}
-
-	// Pre-pay gas
-	if err = self.buyGas(); err != nil {
-		if IsGasLimitErr(err) {
-			return err
-		}
-		return InvalidTxError(err)
+	// Make sure the gas limit covers the intrinsic gas before touching the
+	// gas pool, so rejected messages leave it intact
+	homestead := self.evm.ChainConfig().IsHomestead(self.evm.BlockNumber)
+	if intrinsicGas := IntrinsicGas(self.data, MessageCreatesContract(msg), homestead); msg.Gas().Cmp(intrinsicGas) < 0 {
+		return &IntrinsicGasErr{Have: msg.Gas(), Want: intrinsicGas}
 	}
-
-	return nil
+	// Pre-pay gas
+	return self.buyGas()
 }
 
 // TransitionDb will transition the state by applying the current message and returning the result
@@ -229,13 +262,9 @@ func (self *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *b
 
 	homestead := self.evm.ChainConfig().IsHomestead(self.evm.BlockNumber)
 	contractCreation := MessageCreatesContract(msg)
-	// Pay intrinsic gas
+	// Pay intrinsic gas, its coverage was checked in preCheck
 	// TODO convert to uint64
 	intrinsicGas := IntrinsicGas(self.data, contractCreation, homestead)
-	if intrinsicGas.BitLen() > 64 {
-		return nil, nil, nil, InvalidTxError(vm.ErrOutOfGas)
-	}
-
 	if err = self.useGas(intrinsicGas.Uint64()); err != nil {
 		return nil, nil, nil, InvalidTxError(err)
 	}
@@ -255,7 +284,7 @@ func (self *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *b
 		ret, self.gas, vmerr = evm.Call(sender, self.to().Address(), self.data, self.gas, self.value)
 	}
 	if vmerr != nil {
-		log.Debug("VM returned with error", "err", err)
+		log.Debug("VM returned with error", "err", vmerr)
 		// The only possible consensus-error would be if there wasn't
 		// sufficient balance to make the transfer happen. The first
 		// balance transfer may never fail.
@@ -263,6 +292,7 @@ func (self *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *b
 			return nil, nil, nil, InvalidTxError(vmerr)
 		}
 	}
+	self.vmerr = vmerr
 
 	requiredGas = new(big.Int).Set(self.gasUsed())
 
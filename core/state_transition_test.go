@@ -0,0 +1,80 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/params"
+)
+
+// Tests that messages which can never be included in a block fail with the
+// error type describing why, leaving the gas pool untouched.
+func TestStateTransitionErrors(t *testing.T) {
+	var (
+		sender    = common.HexToAddress("0x0101")
+		recipient = common.HexToAddress("0x0202")
+	)
+	tests := []struct {
+		gas, price, value *big.Int
+		pool              *big.Int
+		check             func(error) bool
+	}{
+		// Sender can't pay for gas * price
+		{big.NewInt(21000), big.NewInt(50), new(big.Int), big.NewInt(100000), IsInsufficientFundsErr},
+		// Sender can pay for the gas, but not the value on top
+		{big.NewInt(21000), big.NewInt(1), big.NewInt(980000), big.NewInt(100000), IsInsufficientFundsErr},
+		// Gas limit doesn't cover the intrinsic gas
+		{big.NewInt(20999), big.NewInt(1), new(big.Int), big.NewInt(100000), IsIntrinsicGasErr},
+		// Block gas pool can't accommodate the gas limit
+		{big.NewInt(21000), big.NewInt(1), new(big.Int), big.NewInt(20000), IsGasLimitErr},
+	}
+	for i, tt := range tests {
+		db, _ := ethdb.NewMemDatabase()
+		statedb, _ := state.New(common.Hash{}, db)
+		statedb.AddBalance(sender, big.NewInt(1000000))
+
+		context := vm.Context{
+			CanTransfer: CanTransfer,
+			Transfer:    Transfer,
+			GetHash:     func(uint64) common.Hash { return common.Hash{} },
+			Origin:      sender,
+			GasPrice:    tt.price,
+			GasLimit:    tt.pool,
+			BlockNumber: big.NewInt(1),
+			Time:        new(big.Int),
+			Difficulty:  new(big.Int),
+		}
+		evm := vm.NewEVM(context, statedb, params.TestChainConfig, vm.Config{})
+		msg := types.NewMessage(sender, &recipient, 0, tt.value, tt.gas, tt.price, nil, false)
+		gp := new(GasPool).AddGas(tt.pool)
+
+		_, _, err := ApplyMessage(evm, msg, gp)
+		if !tt.check(err) {
+			t.Errorf("test %d: error mismatch: have %v (%T)", i, err, err)
+		}
+		if (*big.Int)(gp).Cmp(tt.pool) != 0 {
+			t.Errorf("test %d: gas pool mismatch: have %v, want %v", i, gp, tt.pool)
+		}
+	}
+}
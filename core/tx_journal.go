@@ -0,0 +1,149 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+// errNoActiveJournal is returned if a transaction is attempted to be inserted
+// into the journal, but no such file is currently open.
+var errNoActiveJournal = errors.New("no active journal")
+
+// txJournal is a rotating log of transactions with the aim of storing locally
+// created transactions to allow them to survive node restarts. Transactions are
+// appended RLP encoded as they arrive, and the journal is regenerated from the
+// ones still in the pool when others leave it.
+type txJournal struct {
+	path   string                   // Filesystem path to store the transactions at
+	writer io.WriteCloser           // Output stream to write new transactions into
+	txs    map[common.Hash]struct{} // Hashes of the transactions in the journal
+}
+
+// newTxJournal creates a new transaction journal at the given path.
+func newTxJournal(path string) *txJournal {
+	return &txJournal{
+		path: path,
+		txs:  make(map[common.Hash]struct{}),
+	}
+}
+
+// load parses a transaction journal dump from disk, feeding its contents into
+// the specified callback. A missing journal is not an error.
+func (journal *txJournal) load(add func(*types.Transaction) error) error {
+	input, err := os.Open(journal.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	var (
+		stream  = rlp.NewStream(input, 0)
+		total   int
+		dropped int
+	)
+	for {
+		tx := new(types.Transaction)
+		if err = stream.Decode(tx); err != nil {
+			break
+		}
+		total++
+		if err := add(tx); err != nil {
+			log.Debug("Failed to add journaled transaction", "hash", tx.Hash(), "err", err)
+			dropped++
+		}
+	}
+	log.Info("Loaded local transaction journal", "transactions", total, "dropped", dropped)
+
+	// A truncated last entry (e.g. after a crash) only loses that transaction
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil
+	}
+	return err
+}
+
+// insert appends the specified transaction to the journal.
+func (journal *txJournal) insert(tx *types.Transaction) error {
+	if journal.writer == nil {
+		return errNoActiveJournal
+	}
+	if err := rlp.Encode(journal.writer, tx); err != nil {
+		return err
+	}
+	journal.txs[tx.Hash()] = struct{}{}
+	return nil
+}
+
+// rotate regenerates the transaction journal from the given transactions, and
+// opens it for appending new ones.
+func (journal *txJournal) rotate(txs types.Transactions) error {
+	// Close the current journal (if any is open)
+	if journal.writer != nil {
+		if err := journal.writer.Close(); err != nil {
+			return err
+		}
+		journal.writer = nil
+	}
+	// Generate a new journal with the contents of the current pool
+	replacement, err := os.OpenFile(journal.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		if err = rlp.Encode(replacement, tx); err != nil {
+			replacement.Close()
+			return err
+		}
+	}
+	replacement.Close()
+
+	// Replace the live journal with the newly generated one
+	if err = os.Rename(journal.path+".new", journal.path); err != nil {
+		return err
+	}
+	sink, err := os.OpenFile(journal.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	journal.writer = sink
+	journal.txs = make(map[common.Hash]struct{}, len(txs))
+	for _, tx := range txs {
+		journal.txs[tx.Hash()] = struct{}{}
+	}
+	log.Debug("Regenerated local transaction journal", "transactions", len(txs))
+	return nil
+}
+
+// close flushes the transaction journal contents to disk and closes the file.
+func (journal *txJournal) close() error {
+	var err error
+	if journal.writer != nil {
+		err = journal.writer.Close()
+		journal.writer = nil
+	}
+	return err
+}
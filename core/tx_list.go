@@ -22,6 +22,7 @@ import (
 	"math/big"
 	"sort"
 
+	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/core/types"
 )
 
@@ -340,3 +341,108 @@ func (l *txList) Empty() bool {
 func (l *txList) Flatten() types.Transactions {
 	return l.txs.Flatten()
 }
+
+// priceHeap is a heap.Interface implementation over transactions for retrieving
+// price-sorted transactions to discard when the pool fills up.
+type priceHeap []*types.Transaction
+
+func (h priceHeap) Len() int      { return len(h) }
+func (h priceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h priceHeap) Less(i, j int) bool {
+	// Sort primarily by price, returning the cheaper one
+	switch h[i].GasPrice().Cmp(h[j].GasPrice()) {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	// If the prices match, stabilize via nonces (high nonce is worse)
+	return h[i].Nonce() > h[j].Nonce()
+}
+
+func (h *priceHeap) Push(x interface{}) {
+	*h = append(*h, x.(*types.Transaction))
+}
+
+func (h *priceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// txPricedList is a price-sorted heap to allow operating on transactions pool
+// contents in a price-incrementing way. Only remote transactions are tracked, as
+// local ones and those of gas free senders are never evicted. Transactions
+// leaving the pool are not removed from the heap, rather skipped when reached and
+// purged in bulk once they make up too much of it.
+type txPricedList struct {
+	all   *map[common.Hash]*types.Transaction // Pointer to the map of all transactions
+	items *priceHeap                          // Heap of prices of all the stored transactions
+}
+
+// newTxPricedList creates a new price-sorted transaction heap.
+func newTxPricedList(all *map[common.Hash]*types.Transaction) *txPricedList {
+	return &txPricedList{
+		all:   all,
+		items: new(priceHeap),
+	}
+}
+
+// Put inserts a new transaction into the heap, regenerating it if more than half
+// of its contents already left the pool.
+func (l *txPricedList) Put(tx *types.Transaction) {
+	heap.Push(l.items, tx)
+	if len(*l.items) > 2*len(*l.all)+64 {
+		reheap, seen := make(priceHeap, 0, len(*l.all)), make(map[*types.Transaction]struct{})
+		for _, tx := range *l.items {
+			if _, ok := seen[tx]; ok {
+				continue
+			}
+			if current, ok := (*l.all)[tx.Hash()]; ok && current == tx {
+				reheap = append(reheap, tx)
+				seen[tx] = struct{}{}
+			}
+		}
+		heap.Init(&reheap)
+		*l.items = reheap
+	}
+}
+
+// Underpriced checks whether a transaction is cheaper than (or as cheap as) the
+// lowest priced transaction currently being tracked.
+func (l *txPricedList) Underpriced(tx *types.Transaction) bool {
+	// Discard the transactions that left the pool from the top of the heap
+	for len(*l.items) > 0 {
+		head := (*l.items)[0]
+		if current, ok := (*l.all)[head.Hash()]; ok && current == head {
+			break
+		}
+		heap.Pop(l.items)
+	}
+	if len(*l.items) == 0 {
+		return false
+	}
+	return (*l.items)[0].GasPrice().Cmp(tx.GasPrice()) >= 0
+}
+
+// Discard finds a number of most underpriced transactions, removes them from the
+// priced list and returns them for further removal from the entire pool.
+func (l *txPricedList) Discard(count int) types.Transactions {
+	drop, seen := make(types.Transactions, 0, count), make(map[*types.Transaction]struct{})
+	for len(*l.items) > 0 && count > 0 {
+		tx := heap.Pop(l.items).(*types.Transaction)
+		if _, ok := seen[tx]; ok {
+			continue
+		}
+		if current, ok := (*l.all)[tx.Hash()]; !ok || current != tx {
+			continue
+		}
+		seen[tx] = struct{}{}
+		drop = append(drop, tx)
+		count--
+	}
+	return drop
+}
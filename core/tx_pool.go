@@ -31,49 +31,100 @@ import (
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/metrics"
 	"github.com/expanse-org/go-expanse/params"
+	gometrics "github.com/rcrowley/go-metrics"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
 var (
 	// Transaction Pool Errors
-	ErrInvalidSender     = errors.New("Invalid sender")
-	ErrNonce             = errors.New("Nonce too low")
-	ErrCheap             = errors.New("Gas price too low for acceptance")
-	ErrBalance           = errors.New("Insufficient balance")
-	ErrInsufficientFunds = errors.New("Insufficient funds for gas * price + value")
-	ErrIntrinsicGas      = errors.New("Intrinsic gas too low")
-	ErrGasLimit          = errors.New("Exceeds block gas limit")
-	ErrNegativeValue     = errors.New("Negative value")
+	ErrInvalidSender = errors.New("Invalid sender")
+	ErrNonce         = errors.New("Nonce too low")
+	ErrCheap         = errors.New("Gas price too low for acceptance")
+	ErrBalance       = errors.New("Insufficient balance")
+	ErrGasLimit      = errors.New("Exceeds block gas limit")
+	ErrNegativeValue = errors.New("Negative value")
+	ErrUnderpriced   = errors.New("Transaction underpriced for the full pool")
+	ErrOversizedData = errors.New("Oversized data")
 )
 
 var (
-	minPendingPerAccount = uint64(16)    // Min number of guaranteed transaction slots per address
-	maxPendingTotal      = uint64(4096)  // Max limit of pending transactions from all accounts (soft)
-	maxQueuedPerAccount  = uint64(64)    // Max limit of queued transactions per address
-	maxQueuedInTotal     = uint64(1024)  // Max limit of queued transactions from all accounts
-	maxQueuedLifetime    = 3 * time.Hour // Max amount of time transactions from idle accounts are queued
-	evictionInterval     = time.Minute   // Time interval to check for evictable transactions
+	maxQueuedLifetime = 3 * time.Hour // Default max amount of time transactions from idle accounts are queued
+	evictionInterval  = time.Minute   // Time interval to check for evictable transactions
 )
 
-var (
+// TxPoolConfig are the limits on the number of transactions the pool tracks.
+//
+// Once the pool holds GlobalSlots+GlobalQueue transactions, new remote ones are
+// only accepted if they pay more than the cheapest remote transaction pooled,
+// which is evicted in exchange. Local transactions are never evicted.
+type TxPoolConfig struct {
+	AccountSlots uint64 // Minimum number of executable transaction slots guaranteed per account
+	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts (soft)
+	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
+	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
+}
+
+// DefaultTxPoolConfig contains the default limits of the transaction pool.
+var DefaultTxPoolConfig = TxPoolConfig{
+	AccountSlots: 16,
+	GlobalSlots:  4096,
+	AccountQueue: 64,
+	GlobalQueue:  1024,
+}
+
+// txPoolMeters is the collection of metrics reported by a single transaction
+// pool, registered within the metrics registry of the pool's owner.
+type txPoolMeters struct {
 	// Metrics for the pending pool
-	pendingDiscardCounter = metrics.NewCounter("txpool/pending/discard")
-	pendingReplaceCounter = metrics.NewCounter("txpool/pending/replace")
-	pendingRLCounter      = metrics.NewCounter("txpool/pending/ratelimit") // Dropped due to rate limiting
-	pendingNofundsCounter = metrics.NewCounter("txpool/pending/nofunds")   // Dropped due to out-of-funds
+	pendingDiscard gometrics.Counter
+	pendingReplace gometrics.Counter
+	pendingRL      gometrics.Counter // Dropped due to rate limiting
+	pendingNofunds gometrics.Counter // Dropped due to out-of-funds
+	pendingGauge   gometrics.Gauge   // Number of currently processable transactions
 
 	// Metrics for the queued pool
-	queuedDiscardCounter = metrics.NewCounter("txpool/queued/discard")
-	queuedReplaceCounter = metrics.NewCounter("txpool/queued/replace")
-	queuedRLCounter      = metrics.NewCounter("txpool/queued/ratelimit") // Dropped due to rate limiting
-	queuedNofundsCounter = metrics.NewCounter("txpool/queued/nofunds")   // Dropped due to out-of-funds
+	queuedDiscard gometrics.Counter
+	queuedReplace gometrics.Counter
+	queuedRL      gometrics.Counter // Dropped due to rate limiting
+	queuedNofunds gometrics.Counter // Dropped due to out-of-funds
+	queuedGauge   gometrics.Gauge   // Number of currently non-processable transactions
 
 	// General tx metrics
-	invalidTxCounter = metrics.NewCounter("txpool/invalid")
-)
+	invalidTx     gometrics.Counter
+	underpricedTx gometrics.Counter // Rejected or evicted due to the pool being full
+}
+
+// newTxPoolMeters creates the transaction pool metrics within the given registry,
+// or the default one if nil.
+func newTxPoolMeters(registry gometrics.Registry) *txPoolMeters {
+	return &txPoolMeters{
+		pendingDiscard: metrics.NewRegisteredCounter("txpool/pending/discard", registry),
+		pendingReplace: metrics.NewRegisteredCounter("txpool/pending/replace", registry),
+		pendingRL:      metrics.NewRegisteredCounter("txpool/pending/ratelimit", registry),
+		pendingNofunds: metrics.NewRegisteredCounter("txpool/pending/nofunds", registry),
+		pendingGauge:   metrics.NewRegisteredGauge("txpool/pending/count", registry),
+		queuedDiscard:  metrics.NewRegisteredCounter("txpool/queued/discard", registry),
+		queuedReplace:  metrics.NewRegisteredCounter("txpool/queued/replace", registry),
+		queuedRL:       metrics.NewRegisteredCounter("txpool/queued/ratelimit", registry),
+		queuedNofunds:  metrics.NewRegisteredCounter("txpool/queued/nofunds", registry),
+		queuedGauge:    metrics.NewRegisteredGauge("txpool/queued/count", registry),
+		invalidTx:      metrics.NewRegisteredCounter("txpool/invalid", registry),
+		underpricedTx:  metrics.NewRegisteredCounter("txpool/underpriced", registry),
+	}
+}
 
 type stateFn func() (*state.StateDB, error)
 
+// TxStatus is the current status of a transaction as seen by a node.
+type TxStatus uint
+
+const (
+	TxStatusUnknown  TxStatus = iota // Transaction neither in the pool nor in the chain
+	TxStatusQueued                   // Transaction queued in the pool, not yet executable
+	TxStatusPending                  // Transaction executable, pending inclusion
+	TxStatusIncluded                 // Transaction included in the canonical chain
+)
+
 // TxPool contains all currently known transactions. Transactions
 // enter the pool when they are received from the network or submitted
 // locally. They exit the pool when they are included in the blockchain.
@@ -91,6 +142,10 @@ type TxPool struct {
 	events       *event.TypeMuxSubscription
 	localTx      *txSet
 	signer       types.Signer
+	meters       *txPoolMeters
+	journal      *txJournal // Journal of local transactions to back up to disk, nil if disabled
+	limits       TxPoolConfig
+	priced       *txPricedList // All remote transactions sorted by price
 	mu           sync.RWMutex
 
 	pending map[common.Address]*txList         // All currently processable transactions
@@ -98,6 +153,10 @@ type TxPool struct {
 	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
 	beats   map[common.Address]time.Time       // Last heartbeat from each known account
 
+	queuedAt        map[common.Hash]time.Time // Time each queued transaction entered the queue
+	queuedLifetime  time.Duration             // Max time a transaction is queued behind a nonce gap (0 = unlimited)
+	accountLifetime time.Duration             // Max time transactions of idle accounts are queued
+
 	wg   sync.WaitGroup // for shutdown sync
 	quit chan struct{}
 
@@ -108,20 +167,25 @@ func NewTxPool(config *params.ChainConfig, eventMux *event.TypeMux, currentState
 	pool := &TxPool{
 		config:       config,
 		signer:       types.NewEIP155Signer(config.ChainId),
+		meters:       newTxPoolMeters(nil),
 		pending:      make(map[common.Address]*txList),
 		queue:        make(map[common.Address]*txList),
 		all:          make(map[common.Hash]*types.Transaction),
 		beats:        make(map[common.Address]time.Time),
+		queuedAt:     make(map[common.Hash]time.Time),
 		eventMux:     eventMux,
 		currentState: currentStateFn,
 		gasLimit:     gasLimitFn,
 		minGasPrice:  new(big.Int),
 		pendingState: nil,
 		localTx:      newTxSet(),
-		events:       eventMux.Subscribe(ChainHeadEvent{}, GasPriceChanged{}, RemovedTransactionEvent{}),
+		events:       eventMux.Subscribe(ChainHeadEvent{}, GasPriceChanged{}, ReorgEvent{}),
 		quit:         make(chan struct{}),
-	}
 
+		limits:          DefaultTxPoolConfig,
+		accountLifetime: maxQueuedLifetime,
+	}
+	pool.priced = newTxPricedList(&pool.all)
 	pool.resetState()
 
 	pool.wg.Add(2)
@@ -153,8 +217,9 @@ func (pool *TxPool) eventLoop() {
 			pool.mu.Lock()
 			pool.minGasPrice = ev.Price
 			pool.mu.Unlock()
-		case RemovedTransactionEvent:
-			pool.AddBatch(ev.Txs)
+		case ReorgEvent:
+			// Reinject the transactions dropped from the canonical chain
+			pool.AddBatch(ev.RevertedTxs)
 		}
 	}
 }
@@ -181,6 +246,9 @@ func (pool *TxPool) resetState() {
 	// Check the queue and move transactions over to the pending if possible
 	// or remove those that have become invalid
 	pool.promoteExecutables(currentState)
+
+	// Drop the mined and invalidated local transactions from the journal
+	pool.rotateJournal()
 }
 
 func (pool *TxPool) Stop() {
@@ -188,9 +256,25 @@ func (pool *TxPool) Stop() {
 	close(pool.quit)
 	pool.wg.Wait()
 
+	pool.mu.Lock()
+	if pool.journal != nil {
+		pool.journal.close()
+	}
+	pool.mu.Unlock()
+
 	log.Info("Transaction pool stopped")
 }
 
+// Meter re-registers the pool's metrics within the given registry, allowing the
+// pools of multiple nodes running within the same process to be told apart.
+func (pool *TxPool) Meter(registry gometrics.Registry) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.meters = newTxPoolMeters(registry)
+	pool.updateGauges()
+}
+
 func (pool *TxPool) State() *state.ManagedState {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
@@ -198,12 +282,27 @@ func (pool *TxPool) State() *state.ManagedState {
 	return pool.pendingState
 }
 
+// MinGasPrice returns the gas price below which remote transactions are rejected
+// by the pool, as configured by the miner.
+func (pool *TxPool) MinGasPrice() *big.Int {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return new(big.Int).Set(pool.minGasPrice)
+}
+
 // Stats retrieves the current pool stats, namely the number of pending and the
 // number of queued (non-executable) transactions.
 func (pool *TxPool) Stats() (pending int, queued int) {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
+	return pool.stats()
+}
+
+// stats retrieves the current pool stats, namely the number of pending and the
+// number of queued (non-executable) transactions. The pool lock must be held.
+func (pool *TxPool) stats() (pending int, queued int) {
 	for _, list := range pool.pending {
 		pending += list.Len()
 	}
@@ -263,15 +362,130 @@ func (pool *TxPool) SetLocal(tx *types.Transaction) {
 	pool.localTx.add(tx.Hash())
 }
 
+// SetQueueLifetimes sets how long non-executable transactions may stay queued:
+// individually behind a nonce gap, and altogether for accounts without any
+// activity. A zero queued lifetime lets transactions wait for as long as their
+// account is active, a zero account lifetime keeps the default.
+func (pool *TxPool) SetQueueLifetimes(queued, account time.Duration) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.queuedLifetime = queued
+	if account > 0 {
+		pool.accountLifetime = account
+	}
+}
+
+// SetLimits sets the limits on the number of transactions the pool tracks. Any
+// zero limit keeps its default.
+func (pool *TxPool) SetLimits(config TxPoolConfig) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if config.AccountSlots > 0 {
+		pool.limits.AccountSlots = config.AccountSlots
+	}
+	if config.GlobalSlots > 0 {
+		pool.limits.GlobalSlots = config.GlobalSlots
+	}
+	if config.AccountQueue > 0 {
+		pool.limits.AccountQueue = config.AccountQueue
+	}
+	if config.GlobalQueue > 0 {
+		pool.limits.GlobalQueue = config.GlobalQueue
+	}
+}
+
+// SetJournal enables the journaling of local transactions into the file at the
+// given path, so they survive node restarts. The transactions journaled by a
+// previous run are loaded back into the pool as local ones.
+func (pool *TxPool) SetJournal(path string) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.journal != nil {
+		pool.journal.close()
+		pool.journal = nil
+	}
+	journal := newTxJournal(path)
+
+	var loaded types.Transactions
+	err := journal.load(func(tx *types.Transaction) error {
+		pool.localTx.add(tx.Hash())
+		if err := pool.add(tx); err != nil {
+			return err
+		}
+		loaded = append(loaded, tx)
+		return nil
+	})
+	if err != nil {
+		log.Warn("Failed to load transaction journal", "err", err)
+	}
+	if len(loaded) > 0 {
+		if state, err := pool.currentState(); err == nil {
+			pool.promoteExecutables(state)
+		}
+	}
+	// Compact the journal to the transactions still in the pool
+	var txs types.Transactions
+	for _, tx := range loaded {
+		if pool.all[tx.Hash()] != nil {
+			txs = append(txs, tx)
+		}
+	}
+	if err := journal.rotate(txs); err != nil {
+		return err
+	}
+	pool.journal = journal
+	return nil
+}
+
+// journalTx adds a local transaction to the journal, if journaling is enabled.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) journalTx(hash common.Hash, tx *types.Transaction) {
+	if pool.journal == nil || !pool.localTx.contains(hash) {
+		return
+	}
+	if err := pool.journal.insert(tx); err != nil {
+		log.Warn("Failed to journal local transaction", "hash", hash, "err", err)
+	}
+}
+
+// rotateJournal regenerates the journal from the journaled transactions still
+// in the pool, if any of them left it since the last rotation.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) rotateJournal() {
+	if pool.journal == nil {
+		return
+	}
+	var (
+		txs   types.Transactions
+		stale bool
+	)
+	for hash := range pool.journal.txs {
+		if tx := pool.all[hash]; tx != nil {
+			txs = append(txs, tx)
+		} else {
+			stale = true
+		}
+	}
+	if !stale {
+		return
+	}
+	if err := pool.journal.rotate(txs); err != nil {
+		log.Warn("Failed to rotate transaction journal", "err", err)
+	}
+}
+
 // validateTx checks whether a transaction is valid according
 // to the consensus rules.
 func (pool *TxPool) validateTx(tx *types.Transaction) error {
-	local := pool.localTx.contains(tx.Hash())
-	// Drop transactions under our own minimal accepted gas price
-	if !local && pool.minGasPrice.Cmp(tx.GasPrice()) > 0 {
-		return ErrCheap
+	// Reject transactions over the size limit to prevent DOS attacks
+	if uint64(tx.Size()) > pool.config.MaxTxSize() {
+		return ErrOversizedData
 	}
-
 	currentState, err := pool.currentState()
 	if err != nil {
 		return err
@@ -281,6 +495,17 @@ func (pool *TxPool) validateTx(tx *types.Transaction) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// Drop transactions under our own minimal accepted gas price, unless the
+	// sender is whitelisted to transact gas free
+	local := pool.localTx.contains(tx.Hash())
+	if !local && !pool.config.IsGasFreeSender(from) && pool.minGasPrice.Cmp(tx.GasPrice()) > 0 {
+		return ErrCheap
+	}
+	// Drop transactions from or to addresses frozen by the network governance,
+	// ahead of the activation of the list
+	if err := checkFreeze(pool.config, currentState, from, tx.To()); err != nil {
+		return err
+	}
 	// Last but not least check for nonce errors
 	if currentState.GetNonce(from) > tx.Nonce() {
 		return ErrNonce
@@ -301,13 +526,13 @@ func (pool *TxPool) validateTx(tx *types.Transaction) error {
 
 	// Transactor should have enough funds to cover the costs
 	// cost == V + GP * GL
-	if currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
-		return ErrInsufficientFunds
+	if balance := currentState.GetBalance(from); balance.Cmp(tx.Cost()) < 0 {
+		return &InsufficientFundsErr{Address: from, Have: balance, Want: tx.Cost()}
 	}
 
 	intrGas := IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead)
 	if tx.Gas().Cmp(intrGas) < 0 {
-		return ErrIntrinsicGas
+		return &IntrinsicGasErr{Have: tx.Gas(), Want: intrGas}
 	}
 
 	return nil
@@ -325,16 +550,42 @@ func (pool *TxPool) add(tx *types.Transaction) error {
 	// Otherwise ensure basic validation passes and queue it up
 	if err := pool.validateTx(tx); err != nil {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
-		invalidTxCounter.Inc(1)
+		pool.meters.invalidTx.Inc(1)
 		return err
 	}
+	// If the pool is full, make room by evicting cheaper remote transactions
+	if limit := pool.limits.GlobalSlots + pool.limits.GlobalQueue; uint64(len(pool.all)) >= limit {
+		if !pool.evictionExempt(hash, tx) && pool.priced.Underpriced(tx) {
+			log.Trace("Discarding underpriced transaction", "hash", hash, "price", tx.GasPrice())
+			pool.meters.underpricedTx.Inc(1)
+			return ErrUnderpriced
+		}
+		for _, drop := range pool.priced.Discard(len(pool.all) - int(limit) + 1) {
+			log.Trace("Evicting underpriced transaction", "hash", drop.Hash(), "price", drop.GasPrice())
+			pool.removeTx(drop.Hash())
+			pool.meters.underpricedTx.Inc(1)
+			pool.notifyDropped(TxDropOverflow, drop)
+		}
+	}
 	pool.enqueueTx(hash, tx)
+	pool.journalTx(hash, tx)
 
 	// Print a log message if low enough level is set
 	log.Debug("Pooled new transaction", "hash", hash, "from", log.Lazy{Fn: func() common.Address { from, _ := types.Sender(pool.signer, tx); return from }}, "to", tx.To())
 	return nil
 }
 
+// evictionExempt returns whether a transaction is kept out of the price based
+// eviction of a full pool, which holds for local transactions and the ones of
+// gas free senders.
+func (pool *TxPool) evictionExempt(hash common.Hash, tx *types.Transaction) bool {
+	if pool.localTx.contains(hash) {
+		return true
+	}
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	return pool.config.IsGasFreeSender(from)
+}
+
 // enqueueTx inserts a new transaction into the non-executable transaction queue.
 //
 // Note, this method assumes the pool lock is held!
@@ -346,15 +597,25 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) {
 	}
 	inserted, old := pool.queue[from].Add(tx)
 	if !inserted {
-		queuedDiscardCounter.Inc(1)
+		pool.meters.queuedDiscard.Inc(1)
+		pool.notifyDropped(TxDropUnderpriced, tx)
 		return // An older transaction was better, discard this
 	}
 	// Discard any previous transaction and mark this
 	if old != nil {
 		delete(pool.all, old.Hash())
-		queuedReplaceCounter.Inc(1)
+		pool.meters.queuedReplace.Inc(1)
+		pool.notifyDropped(TxDropReplaced, old)
 	}
 	pool.all[hash] = tx
+	if !pool.evictionExempt(hash, tx) {
+		pool.priced.Put(tx)
+	}
+	pool.queuedAt[hash] = time.Now()
+	if _, ok := pool.beats[from]; !ok {
+		pool.beats[from] = time.Now()
+	}
+	go pool.eventMux.Post(TxQueuedEvent{tx})
 }
 
 // promoteTx adds a transaction to the pending (processable) list of transactions.
@@ -371,13 +632,15 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	if !inserted {
 		// An older transaction was better, discard this
 		delete(pool.all, hash)
-		pendingDiscardCounter.Inc(1)
+		pool.meters.pendingDiscard.Inc(1)
+		pool.notifyDropped(TxDropUnderpriced, tx)
 		return
 	}
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
 		delete(pool.all, old.Hash())
-		pendingReplaceCounter.Inc(1)
+		pool.meters.pendingReplace.Inc(1)
+		pool.notifyDropped(TxDropReplaced, old)
 	}
 	pool.all[hash] = tx // Failsafe to work around direct pending inserts (tests)
 
@@ -385,6 +648,14 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr, tx.Nonce()+1)
 	go pool.eventMux.Post(TxPreEvent{tx})
+	go pool.eventMux.Post(TxPromotedEvent{tx})
+}
+
+// notifyDropped posts a drop event for each of the given transactions.
+func (pool *TxPool) notifyDropped(reason TxDropReason, txs ...*types.Transaction) {
+	for _, tx := range txs {
+		go pool.eventMux.Post(TxDroppedEvent{Tx: tx, Reason: reason})
+	}
 }
 
 // Add queues a single transaction in the pool if it is valid.
@@ -437,6 +708,28 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all[hash]
 }
 
+// Status returns the pool status of a batch of transactions identified by their
+// hashes: queued, pending or unknown if not in the pool.
+func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	status := make([]TxStatus, len(hashes))
+	for i, hash := range hashes {
+		tx := pool.all[hash]
+		if tx == nil {
+			continue
+		}
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		if list := pool.pending[from]; list != nil && list.txs.Get(tx.Nonce()) != nil {
+			status[i] = TxStatusPending
+		} else {
+			status[i] = TxStatusQueued
+		}
+	}
+	return status
+}
+
 // Remove removes the transaction with the given hash from the pool.
 func (pool *TxPool) Remove(hash common.Hash) {
 	pool.mu.Lock()
@@ -515,7 +808,8 @@ func (pool *TxPool) promoteExecutables(state *state.StateDB) {
 			hash := tx.Hash()
 			log.Debug("Removed unpayable queued transaction", "hash", hash)
 			delete(pool.all, hash)
-			queuedNofundsCounter.Inc(1)
+			pool.meters.queuedNofunds.Inc(1)
+			pool.notifyDropped(TxDropInvalidated, tx)
 		}
 		// Gather all executable transactions and promote them
 		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
@@ -524,11 +818,12 @@ func (pool *TxPool) promoteExecutables(state *state.StateDB) {
 			pool.promoteTx(addr, hash, tx)
 		}
 		// Drop all transactions over the allowed limit
-		for _, tx := range list.Cap(int(maxQueuedPerAccount)) {
+		for _, tx := range list.Cap(int(pool.limits.AccountQueue)) {
 			hash := tx.Hash()
 			log.Debug("Removed cap-exceeding queued transaction", "hash", hash)
 			delete(pool.all, hash)
-			queuedRLCounter.Inc(1)
+			pool.meters.queuedRL.Inc(1)
+			pool.notifyDropped(TxDropOverflow, tx)
 		}
 		queued += uint64(list.Len())
 
@@ -542,13 +837,13 @@ func (pool *TxPool) promoteExecutables(state *state.StateDB) {
 	for _, list := range pool.pending {
 		pending += uint64(list.Len())
 	}
-	if pending > maxPendingTotal {
+	if pending > pool.limits.GlobalSlots {
 		pendingBeforeCap := pending
 		// Assemble a spam order to penalize large transactors first
 		spammers := prque.New()
 		for addr, list := range pool.pending {
 			// Only evict transactions from high rollers
-			if uint64(list.Len()) > minPendingPerAccount {
+			if uint64(list.Len()) > pool.limits.AccountSlots {
 				// Skip local accounts as pools should maintain backlogs for themselves
 				for _, tx := range list.txs.items {
 					if !pool.localTx.contains(tx.Hash()) {
@@ -560,7 +855,7 @@ func (pool *TxPool) promoteExecutables(state *state.StateDB) {
 		}
 		// Gradually drop transactions from offenders
 		offenders := []common.Address{}
-		for pending > maxPendingTotal && !spammers.Empty() {
+		for pending > pool.limits.GlobalSlots && !spammers.Empty() {
 			// Retrieve the next offender if not local address
 			offender, _ := spammers.Pop()
 			offenders = append(offenders, offender.(common.Address))
@@ -571,29 +866,29 @@ func (pool *TxPool) promoteExecutables(state *state.StateDB) {
 				threshold := pool.pending[offender.(common.Address)].Len()
 
 				// Iteratively reduce all offenders until below limit or threshold reached
-				for pending > maxPendingTotal && pool.pending[offenders[len(offenders)-2]].Len() > threshold {
+				for pending > pool.limits.GlobalSlots && pool.pending[offenders[len(offenders)-2]].Len() > threshold {
 					for i := 0; i < len(offenders)-1; i++ {
 						list := pool.pending[offenders[i]]
-						list.Cap(list.Len() - 1)
+						pool.dropPending(list.Cap(list.Len() - 1))
 						pending--
 					}
 				}
 			}
 		}
 		// If still above threshold, reduce to limit or min allowance
-		if pending > maxPendingTotal && len(offenders) > 0 {
-			for pending > maxPendingTotal && uint64(pool.pending[offenders[len(offenders)-1]].Len()) > minPendingPerAccount {
+		if pending > pool.limits.GlobalSlots && len(offenders) > 0 {
+			for pending > pool.limits.GlobalSlots && uint64(pool.pending[offenders[len(offenders)-1]].Len()) > pool.limits.AccountSlots {
 				for _, addr := range offenders {
 					list := pool.pending[addr]
-					list.Cap(list.Len() - 1)
+					pool.dropPending(list.Cap(list.Len() - 1))
 					pending--
 				}
 			}
 		}
-		pendingRLCounter.Inc(int64(pendingBeforeCap - pending))
+		pool.meters.pendingRL.Inc(int64(pendingBeforeCap - pending))
 	}
 	// If we've queued more transactions than the hard limit, drop oldest ones
-	if queued > maxQueuedInTotal {
+	if queued > pool.limits.GlobalQueue {
 		// Sort all accounts with queued transactions by heartbeat
 		addresses := make(addresssByHeartbeat, 0, len(pool.queue))
 		for addr := range pool.queue {
@@ -602,7 +897,7 @@ func (pool *TxPool) promoteExecutables(state *state.StateDB) {
 		sort.Sort(addresses)
 
 		// Drop transactions until the total is below the limit
-		for drop := queued - maxQueuedInTotal; drop > 0; {
+		for drop := queued - pool.limits.GlobalQueue; drop > 0; {
 			addr := addresses[len(addresses)-1]
 			list := pool.queue[addr.address]
 
@@ -612,20 +907,44 @@ func (pool *TxPool) promoteExecutables(state *state.StateDB) {
 			if size := uint64(list.Len()); size <= drop {
 				for _, tx := range list.Flatten() {
 					pool.removeTx(tx.Hash())
+					pool.notifyDropped(TxDropOverflow, tx)
 				}
 				drop -= size
-				queuedRLCounter.Inc(int64(size))
+				pool.meters.queuedRL.Inc(int64(size))
 				continue
 			}
 			// Otherwise drop only last few transactions
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 				pool.removeTx(txs[i].Hash())
+				pool.notifyDropped(TxDropOverflow, txs[i])
 				drop--
-				queuedRLCounter.Inc(1)
+				pool.meters.queuedRL.Inc(1)
 			}
 		}
 	}
+	pool.updateGauges()
+}
+
+// dropPending forgets the pending transactions evicted to keep the pool within
+// its limits.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) dropPending(txs types.Transactions) {
+	for _, tx := range txs {
+		hash := tx.Hash()
+		log.Debug("Removed cap-exceeding pending transaction", "hash", hash)
+		delete(pool.all, hash)
+		pool.notifyDropped(TxDropOverflow, tx)
+	}
+}
+
+// updateGauges refreshes the pending and queued transaction count gauges. The
+// pool lock must be held.
+func (pool *TxPool) updateGauges() {
+	pending, queued := pool.stats()
+	pool.meters.pendingGauge.Update(int64(pending))
+	pool.meters.queuedGauge.Update(int64(queued))
 }
 
 // demoteUnexecutables removes invalid and processed transactions from the pools
@@ -648,7 +967,8 @@ func (pool *TxPool) demoteUnexecutables(state *state.StateDB) {
 			hash := tx.Hash()
 			log.Debug("Removed unpayable pending transaction", "hash", hash)
 			delete(pool.all, hash)
-			pendingNofundsCounter.Inc(1)
+			pool.meters.pendingNofunds.Inc(1)
+			pool.notifyDropped(TxDropInvalidated, tx)
 		}
 		for _, tx := range invalids {
 			hash := tx.Hash()
@@ -676,13 +996,7 @@ func (pool *TxPool) expirationLoop() {
 		select {
 		case <-evict.C:
 			pool.mu.Lock()
-			for addr := range pool.queue {
-				if time.Since(pool.beats[addr]) > maxQueuedLifetime {
-					for _, tx := range pool.queue[addr].Flatten() {
-						pool.removeTx(tx.Hash())
-					}
-				}
-			}
+			pool.expire(time.Now())
 			pool.mu.Unlock()
 
 		case <-pool.quit:
@@ -691,6 +1005,45 @@ func (pool *TxPool) expirationLoop() {
 	}
 }
 
+// expire drops the queued transactions of accounts idle for longer than the
+// account lifetime, and the transactions stuck behind a nonce gap for longer
+// than the queued lifetime.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) expire(now time.Time) {
+	queuedAt := make(map[common.Hash]time.Time)
+	for addr, list := range pool.queue {
+		beat, ok := pool.beats[addr]
+		if !ok {
+			// The account's pending transactions are gone, restart its clock
+			beat, pool.beats[addr] = now, now
+		}
+		if now.Sub(beat) > pool.accountLifetime {
+			for _, tx := range list.Flatten() {
+				log.Debug("Removed queued transaction of idle account", "hash", tx.Hash())
+				pool.removeTx(tx.Hash())
+				pool.notifyDropped(TxDropExpired, tx)
+			}
+			continue
+		}
+		for _, tx := range list.Flatten() {
+			hash := tx.Hash()
+			added, ok := pool.queuedAt[hash]
+			if !ok {
+				added = now
+			}
+			if pool.queuedLifetime > 0 && now.Sub(added) > pool.queuedLifetime {
+				log.Debug("Removed expired queued transaction", "hash", hash)
+				pool.removeTx(hash)
+				pool.notifyDropped(TxDropExpired, tx)
+				continue
+			}
+			queuedAt[hash] = added
+		}
+	}
+	pool.queuedAt = queuedAt
+}
+
 // addressByHeartbeat is an account address tagged with its last activity timestamp.
 type addressByHeartbeat struct {
 	address   common.Address
@@ -18,8 +18,11 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"io/ioutil"
 	"math/big"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -33,7 +36,11 @@ import (
 )
 
 func transaction(nonce uint64, gaslimit *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
-	tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	return pricedTransaction(nonce, gaslimit, big.NewInt(1), key)
+}
+
+func pricedTransaction(nonce uint64, gaslimit, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, gasprice, nil), types.HomesteadSigner{}, key)
 	return tx
 }
 
@@ -133,14 +140,14 @@ func TestInvalidTransactions(t *testing.T) {
 	from, _ := deriveSender(tx)
 	currentState, _ := pool.currentState()
 	currentState.AddBalance(from, big.NewInt(1))
-	if err := pool.Add(tx); err != ErrInsufficientFunds {
-		t.Error("expected", ErrInsufficientFunds)
+	if err := pool.Add(tx); !IsInsufficientFundsErr(err) {
+		t.Error("expected insufficient funds error, got", err)
 	}
 
 	balance := new(big.Int).Add(tx.Value(), new(big.Int).Mul(tx.Gas(), tx.GasPrice()))
 	currentState.AddBalance(from, balance)
-	if err := pool.Add(tx); err != ErrIntrinsicGas {
-		t.Error("expected", ErrIntrinsicGas, "got", err)
+	if err := pool.Add(tx); !IsIntrinsicGasErr(err) {
+		t.Error("expected intrinsic gas error, got", err)
 	}
 
 	currentState.SetNonce(from, 1)
@@ -162,6 +169,51 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+// Tests that transactions of gas free senders bypass the gas price floor.
+func TestGasFreeTransactions(t *testing.T) {
+	pool, key := setupTxPool()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(from, big.NewInt(1000))
+	pool.minGasPrice = big.NewInt(1000)
+
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(100000), new(big.Int), nil), types.HomesteadSigner{}, key)
+	if err := pool.Add(tx); err != ErrCheap {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrCheap)
+	}
+	config := *pool.config
+	config.GasFree = &params.GasFreeConfig{Senders: []common.Address{from}}
+	pool.config = &config
+
+	if err := pool.Add(tx); err != nil {
+		t.Fatalf("gas free transaction rejected: %v", err)
+	}
+}
+
+// Tests that transactions over the size limit are rejected, and that the limit
+// follows the chain configuration.
+func TestOversizedTransactions(t *testing.T) {
+	pool, key := setupTxPool()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	currentState, _ := pool.currentState()
+	currentState.AddBalance(from, big.NewInt(1000000))
+
+	data := make([]byte, params.MaxTxSize)
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(200000), big.NewInt(1), data), types.HomesteadSigner{}, key)
+	if err := pool.Add(tx); err != ErrOversizedData {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrOversizedData)
+	}
+	config := *pool.config
+	config.Limits = &params.LimitsConfig{MaxTxSize: 2 * params.MaxTxSize}
+	pool.config = &config
+
+	if err := pool.Add(tx); err != nil {
+		t.Fatalf("transaction within raised limit rejected: %v", err)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	pool, key := setupTxPool()
 	tx := transaction(0, big.NewInt(100), key)
@@ -324,6 +376,53 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	}
 }
 
+// Tests that transactions dropped from the pool are announced with the reason
+// of their removal.
+func TestTransactionDropEvents(t *testing.T) {
+	pool, key := setupTxPool()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	sub := pool.eventMux.Subscribe(TxDroppedEvent{})
+	defer sub.Unsubscribe()
+
+	state, _ := pool.currentState()
+	state.AddBalance(addr, big.NewInt(100000000000000))
+
+	signer := types.HomesteadSigner{}
+	tx1, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(100000), big.NewInt(1), nil), signer, key)
+	tx2, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(1000000), big.NewInt(2), nil), signer, key)
+	tx3, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), big.NewInt(1000000), big.NewInt(1), nil), signer, key)
+
+	// Replace a transaction, then try to replace the replacement with a cheaper one
+	pool.add(tx1)
+	pool.add(tx2)
+	pool.promoteExecutables(state)
+	pool.add(tx3)
+	pool.promoteExecutables(state)
+
+	// Drain the account, invalidating the remaining transaction
+	state.SetBalance(addr, new(big.Int))
+	pool.demoteUnexecutables(state)
+
+	want := map[common.Hash]TxDropReason{
+		tx1.Hash(): TxDropReplaced,
+		tx2.Hash(): TxDropInvalidated,
+		tx3.Hash(): TxDropUnderpriced,
+	}
+	for len(want) > 0 {
+		select {
+		case ev := <-sub.Chan():
+			drop := ev.Data.(TxDroppedEvent)
+			if reason, ok := want[drop.Tx.Hash()]; !ok || reason != drop.Reason {
+				t.Fatalf("unexpected drop of %x: have %v, want %v", drop.Tx.Hash(), drop.Reason, reason)
+			}
+			delete(want, drop.Tx.Hash())
+		case <-time.After(time.Second):
+			t.Fatalf("missing drop events: %v", want)
+		}
+	}
+}
+
 func TestMissingNonce(t *testing.T) {
 	pool, key := setupTxPool()
 	addr := crypto.PubkeyToAddress(key.PublicKey)
@@ -371,7 +470,7 @@ func TestRemovedTxEvent(t *testing.T) {
 	currentState, _ := pool.currentState()
 	currentState.AddBalance(from, big.NewInt(1000000000000))
 	pool.resetState()
-	pool.eventMux.Post(RemovedTransactionEvent{types.Transactions{tx}})
+	pool.eventMux.Post(ReorgEvent{RevertedTxs: types.Transactions{tx}})
 	pool.eventMux.Post(ChainHeadEvent{nil})
 	if pool.pending[from].Len() != 1 {
 		t.Error("expected 1 pending tx, got", pool.pending[from].Len())
@@ -531,35 +630,31 @@ func TestTransactionQueueAccountLimiting(t *testing.T) {
 	pool.resetState()
 
 	// Keep queuing up transactions and make sure all above a limit are dropped
-	for i := uint64(1); i <= maxQueuedPerAccount+5; i++ {
+	for i := uint64(1); i <= DefaultTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
 		if len(pool.pending) != 0 {
 			t.Errorf("tx %d: pending pool size mismatch: have %d, want %d", i, len(pool.pending), 0)
 		}
-		if i <= maxQueuedPerAccount {
+		if i <= DefaultTxPoolConfig.AccountQueue {
 			if pool.queue[account].Len() != int(i) {
 				t.Errorf("tx %d: queue size mismatch: have %d, want %d", i, pool.queue[account].Len(), i)
 			}
 		} else {
-			if pool.queue[account].Len() != int(maxQueuedPerAccount) {
-				t.Errorf("tx %d: queue limit mismatch: have %d, want %d", i, pool.queue[account].Len(), maxQueuedPerAccount)
+			if pool.queue[account].Len() != int(DefaultTxPoolConfig.AccountQueue) {
+				t.Errorf("tx %d: queue limit mismatch: have %d, want %d", i, pool.queue[account].Len(), DefaultTxPoolConfig.AccountQueue)
 			}
 		}
 	}
-	if len(pool.all) != int(maxQueuedPerAccount) {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), maxQueuedPerAccount)
+	if len(pool.all) != int(DefaultTxPoolConfig.AccountQueue) {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), DefaultTxPoolConfig.AccountQueue)
 	}
 }
 
 // Tests that if the transaction count belonging to multiple accounts go above
 // some threshold, the higher transactions are dropped to prevent DOS attacks.
 func TestTransactionQueueGlobalLimiting(t *testing.T) {
-	// Reduce the queue limits to shorten test time
-	defer func(old uint64) { maxQueuedInTotal = old }(maxQueuedInTotal)
-	maxQueuedInTotal = maxQueuedPerAccount * 3
-
 	// Create the pool to test the limit enforcement with
 	db, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, db)
@@ -567,6 +662,9 @@ func TestTransactionQueueGlobalLimiting(t *testing.T) {
 	pool := NewTxPool(params.TestChainConfig, new(event.TypeMux), func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
 	pool.resetState()
 
+	// Reduce the pool limits to shorten test time
+	pool.limits.GlobalQueue = DefaultTxPoolConfig.AccountQueue * 3
+
 	// Create a number of test accounts and fund them
 	state, _ := pool.currentState()
 
@@ -578,7 +676,7 @@ func TestTransactionQueueGlobalLimiting(t *testing.T) {
 	// Generate and queue a batch of transactions
 	nonces := make(map[common.Address]uint64)
 
-	txs := make(types.Transactions, 0, 3*maxQueuedInTotal)
+	txs := make(types.Transactions, 0, 3*pool.limits.GlobalQueue)
 	for len(txs) < cap(txs) {
 		key := keys[rand.Intn(len(keys))]
 		addr := crypto.PubkeyToAddress(key.PublicKey)
@@ -591,13 +689,13 @@ func TestTransactionQueueGlobalLimiting(t *testing.T) {
 
 	queued := 0
 	for addr, list := range pool.queue {
-		if list.Len() > int(maxQueuedPerAccount) {
-			t.Errorf("addr %x: queued accounts overflown allowance: %d > %d", addr, list.Len(), maxQueuedPerAccount)
+		if list.Len() > int(DefaultTxPoolConfig.AccountQueue) {
+			t.Errorf("addr %x: queued accounts overflown allowance: %d > %d", addr, list.Len(), DefaultTxPoolConfig.AccountQueue)
 		}
 		queued += list.Len()
 	}
-	if queued > int(maxQueuedInTotal) {
-		t.Fatalf("total transactions overflow allowance: %d > %d", queued, maxQueuedInTotal)
+	if queued > int(pool.limits.GlobalQueue) {
+		t.Fatalf("total transactions overflow allowance: %d > %d", queued, pool.limits.GlobalQueue)
 	}
 }
 
@@ -619,18 +717,68 @@ func TestTransactionQueueTimeLimiting(t *testing.T) {
 	state.AddBalance(account, big.NewInt(1000000))
 
 	// Queue up a batch of transactions
-	for i := uint64(1); i <= maxQueuedPerAccount; i++ {
+	for i := uint64(1); i <= DefaultTxPoolConfig.AccountQueue; i++ {
 		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
 	}
-	// Wait until at least two expiration cycles hit and make sure the transactions are gone
-	time.Sleep(2 * evictionInterval)
+	// Wait until at least two expiration cycles past the lifetime hit and make sure the transactions are gone
+	time.Sleep(3 * evictionInterval)
 	if len(pool.queue) > 0 {
 		t.Fatalf("old transactions remained after eviction")
 	}
 }
 
+// Tests that transactions stuck behind a nonce gap are dropped after the queued
+// lifetime, and that all queued transactions of idle accounts are dropped after
+// the account lifetime.
+func TestTransactionQueueExpiry(t *testing.T) {
+	pool, key := setupTxPool()
+	pool.SetQueueLifetimes(time.Minute, time.Hour)
+
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+	state, _ := pool.currentState()
+	state.AddBalance(account, big.NewInt(1000000))
+
+	sub := pool.eventMux.Subscribe(TxDroppedEvent{})
+	defer sub.Unsubscribe()
+
+	// Queue up an old and a fresh transaction behind a nonce gap
+	old, fresh := transaction(1, big.NewInt(100000), key), transaction(2, big.NewInt(100000), key)
+	if err := pool.Add(old); err != nil {
+		t.Fatalf("failed to add old transaction: %v", err)
+	}
+	if err := pool.Add(fresh); err != nil {
+		t.Fatalf("failed to add fresh transaction: %v", err)
+	}
+	now := time.Now()
+	pool.queuedAt[old.Hash()] = now.Add(-2 * time.Minute)
+
+	pool.mu.Lock()
+	pool.expire(now)
+	pool.mu.Unlock()
+
+	if pool.all[old.Hash()] != nil || pool.all[fresh.Hash()] == nil {
+		t.Fatalf("nonce gap expiry mismatch: old queued %v, fresh queued %v", pool.all[old.Hash()] != nil, pool.all[fresh.Hash()] != nil)
+	}
+	select {
+	case ev := <-sub.Chan():
+		if drop := ev.Data.(TxDroppedEvent); drop.Tx.Hash() != old.Hash() || drop.Reason != TxDropExpired {
+			t.Errorf("drop event mismatch: have %x/%v, want %x/%v", drop.Tx.Hash(), drop.Reason, old.Hash(), TxDropExpired)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no drop event for expired transaction")
+	}
+	// Once the account is idle for long enough, everything else goes too
+	pool.mu.Lock()
+	pool.expire(now.Add(2 * time.Hour))
+	pool.mu.Unlock()
+
+	if len(pool.queue) != 0 || len(pool.queuedAt) != 0 {
+		t.Fatalf("idle account not expired: %d queued accounts, %d tracked", len(pool.queue), len(pool.queuedAt))
+	}
+}
+
 // Tests that even if the transaction count belonging to a single account goes
 // above some threshold, as long as the transactions are executable, they are
 // accepted.
@@ -644,7 +792,7 @@ func TestTransactionPendingLimiting(t *testing.T) {
 	pool.resetState()
 
 	// Keep queuing up transactions and make sure all above a limit are dropped
-	for i := uint64(0); i < maxQueuedPerAccount+5; i++ {
+	for i := uint64(0); i < DefaultTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
@@ -655,8 +803,8 @@ func TestTransactionPendingLimiting(t *testing.T) {
 			t.Errorf("tx %d: queue size mismatch: have %d, want %d", i, pool.queue[account].Len(), 0)
 		}
 	}
-	if len(pool.all) != int(maxQueuedPerAccount+5) {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), maxQueuedPerAccount+5)
+	if len(pool.all) != int(DefaultTxPoolConfig.AccountQueue+5) {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), DefaultTxPoolConfig.AccountQueue+5)
 	}
 }
 
@@ -672,7 +820,7 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	state1, _ := pool1.currentState()
 	state1.AddBalance(account1, big.NewInt(1000000))
 
-	for i := uint64(0); i < maxQueuedPerAccount+5; i++ {
+	for i := uint64(0); i < DefaultTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool1.Add(transaction(origin+i, big.NewInt(100000), key1)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
@@ -684,7 +832,7 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	state2.AddBalance(account2, big.NewInt(1000000))
 
 	txns := []*types.Transaction{}
-	for i := uint64(0); i < maxQueuedPerAccount+5; i++ {
+	for i := uint64(0); i < DefaultTxPoolConfig.AccountQueue+5; i++ {
 		txns = append(txns, transaction(origin+i, big.NewInt(100000), key2))
 	}
 	pool2.AddBatch(txns)
@@ -705,10 +853,6 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 // some hard threshold, the higher transactions are dropped to prevent DOS
 // attacks.
 func TestTransactionPendingGlobalLimiting(t *testing.T) {
-	// Reduce the queue limits to shorten test time
-	defer func(old uint64) { maxPendingTotal = old }(maxPendingTotal)
-	maxPendingTotal = minPendingPerAccount * 10
-
 	// Create the pool to test the limit enforcement with
 	db, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, db)
@@ -716,6 +860,9 @@ func TestTransactionPendingGlobalLimiting(t *testing.T) {
 	pool := NewTxPool(params.TestChainConfig, new(event.TypeMux), func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
 	pool.resetState()
 
+	// Reduce the pool limits to shorten test time
+	pool.limits.GlobalSlots = DefaultTxPoolConfig.AccountSlots * 10
+
 	// Create a number of test accounts and fund them
 	state, _ := pool.currentState()
 
@@ -730,7 +877,7 @@ func TestTransactionPendingGlobalLimiting(t *testing.T) {
 	txs := types.Transactions{}
 	for _, key := range keys {
 		addr := crypto.PubkeyToAddress(key.PublicKey)
-		for j := 0; j < int(maxPendingTotal)/len(keys)*2; j++ {
+		for j := 0; j < int(pool.limits.GlobalSlots)/len(keys)*2; j++ {
 			txs = append(txs, transaction(nonces[addr], big.NewInt(100000), key))
 			nonces[addr]++
 		}
@@ -742,8 +889,8 @@ func TestTransactionPendingGlobalLimiting(t *testing.T) {
 	for _, list := range pool.pending {
 		pending += list.Len()
 	}
-	if pending > int(maxPendingTotal) {
-		t.Fatalf("total pending transactions overflow allowance: %d > %d", pending, maxPendingTotal)
+	if pending > int(pool.limits.GlobalSlots) {
+		t.Fatalf("total pending transactions overflow allowance: %d > %d", pending, pool.limits.GlobalSlots)
 	}
 }
 
@@ -751,10 +898,6 @@ func TestTransactionPendingGlobalLimiting(t *testing.T) {
 // some hard threshold, if they are under the minimum guaranteed slot count then
 // the transactions are still kept.
 func TestTransactionPendingMinimumAllowance(t *testing.T) {
-	// Reduce the queue limits to shorten test time
-	defer func(old uint64) { maxPendingTotal = old }(maxPendingTotal)
-	maxPendingTotal = 0
-
 	// Create the pool to test the limit enforcement with
 	db, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, db)
@@ -762,6 +905,9 @@ func TestTransactionPendingMinimumAllowance(t *testing.T) {
 	pool := NewTxPool(params.TestChainConfig, new(event.TypeMux), func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
 	pool.resetState()
 
+	// Reduce the pool limits to shorten test time
+	pool.limits.GlobalSlots = 0
+
 	// Create a number of test accounts and fund them
 	state, _ := pool.currentState()
 
@@ -776,7 +922,7 @@ func TestTransactionPendingMinimumAllowance(t *testing.T) {
 	txs := types.Transactions{}
 	for _, key := range keys {
 		addr := crypto.PubkeyToAddress(key.PublicKey)
-		for j := 0; j < int(minPendingPerAccount)*2; j++ {
+		for j := 0; j < int(DefaultTxPoolConfig.AccountSlots)*2; j++ {
 			txs = append(txs, transaction(nonces[addr], big.NewInt(100000), key))
 			nonces[addr]++
 		}
@@ -785,8 +931,8 @@ func TestTransactionPendingMinimumAllowance(t *testing.T) {
 	pool.AddBatch(txs)
 
 	for addr, list := range pool.pending {
-		if list.Len() != int(minPendingPerAccount) {
-			t.Errorf("addr %x: total pending transactions mismatch: have %d, want %d", addr, list.Len(), minPendingPerAccount)
+		if list.Len() != int(DefaultTxPoolConfig.AccountSlots) {
+			t.Errorf("addr %x: total pending transactions mismatch: have %d, want %d", addr, list.Len(), DefaultTxPoolConfig.AccountSlots)
 		}
 	}
 }
@@ -883,3 +1029,199 @@ func benchmarkPoolBatchInsert(b *testing.B, size int) {
 		pool.AddBatch(batch)
 	}
 }
+
+// Tests that the pool reports the status of executable, gapped and unknown
+// transactions correctly.
+func TestTransactionStatus(t *testing.T) {
+	pool, key := setupTxPool()
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+
+	state, _ := pool.currentState()
+	state.AddBalance(account, big.NewInt(1000000))
+	pool.resetState()
+
+	pending, queued, unknown := transaction(0, big.NewInt(100000), key), transaction(2, big.NewInt(100000), key), transaction(1, big.NewInt(100000), key)
+	if err := pool.AddBatch([]*types.Transaction{pending, queued}); err != nil {
+		t.Fatalf("failed to add transactions: %v", err)
+	}
+	have := pool.Status([]common.Hash{pending.Hash(), queued.Hash(), unknown.Hash()})
+	want := []TxStatus{TxStatusPending, TxStatusQueued, TxStatusUnknown}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("transaction %d: status mismatch: have %d, want %d", i, have[i], want[i])
+		}
+	}
+}
+
+// Tests that local transactions are journaled to disk and reloaded after a
+// restart, while remote ones are not, and that the journal is rotated as the
+// local transactions get mined.
+func TestTransactionJournaling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txjournal")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "transactions.rlp")
+
+	var (
+		db, _      = ethdb.NewMemDatabase()
+		statedb, _ = state.New(common.Hash{}, db)
+		local, _   = crypto.GenerateKey()
+		remote, _  = crypto.GenerateKey()
+	)
+	statedb.SetBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	statedb.SetBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	newPool := func() *TxPool {
+		pool := NewTxPool(params.TestChainConfig, new(event.TypeMux), func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
+		if err := pool.SetJournal(journal); err != nil {
+			t.Fatalf("failed to enable journal: %v", err)
+		}
+		return pool
+	}
+	pool := newPool()
+	for _, tx := range []*types.Transaction{transaction(0, big.NewInt(100000), local), transaction(1, big.NewInt(100000), local)} {
+		pool.SetLocal(tx)
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("failed to add local transaction: %v", err)
+		}
+	}
+	if err := pool.Add(transaction(0, big.NewInt(100000), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	pool.Stop()
+
+	// Restart the pool and check that only the local transactions are restored
+	pool = newPool()
+	if pending, queued := pool.Stats(); pending != 2 || queued != 0 {
+		t.Fatalf("restored transactions mismatch: have %d pending and %d queued, want 2 and 0", pending, queued)
+	}
+	// Mine the first local transaction and check that the journal is rotated
+	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 1)
+	pool.mu.Lock()
+	pool.resetState()
+	pool.mu.Unlock()
+	pool.Stop()
+
+	pool = newPool()
+	defer pool.Stop()
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("rotated transactions mismatch: have %d pending and %d queued, want 1 and 0", pending, queued)
+	}
+}
+
+// Tests that once the pool is full, remote transactions paying less than the
+// cheapest pooled one are rejected, better paying ones evict the cheapest, and
+// local transactions are always accepted.
+func TestTransactionPoolUnderpricing(t *testing.T) {
+	// Create the pool to test the pricing enforcement with
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	pool := NewTxPool(params.TestChainConfig, new(event.TypeMux), func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
+	pool.resetState()
+	defer pool.Stop()
+
+	pool.SetLimits(TxPoolConfig{GlobalSlots: 2, GlobalQueue: 2})
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		statedb.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	// Fill the pool up with pending and queued transactions
+	for i, tx := range []*types.Transaction{
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(2), keys[0]),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(3), keys[0]),
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(1), keys[1]),
+		pricedTransaction(2, big.NewInt(100000), big.NewInt(4), keys[1]),
+	} {
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool contents mismatch: have %d pending and %d queued, want 3 and 1", pending, queued)
+	}
+	// Ensure that adding an underpriced transaction fails
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(1), keys[2])); err != ErrUnderpriced {
+		t.Fatalf("underpriced transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	// Ensure that a better paying transaction evicts the cheapest one
+	cheapest := pricedTransaction(0, big.NewInt(100000), big.NewInt(1), keys[1])
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(5), keys[2])); err != nil {
+		t.Fatalf("failed to add well priced transaction: %v", err)
+	}
+	if pool.Get(cheapest.Hash()) != nil {
+		t.Fatalf("cheapest transaction not evicted")
+	}
+	if total := len(pool.all); total != 4 {
+		t.Fatalf("pool size mismatch: have %d, want %d", total, 4)
+	}
+	// Ensure that local transactions are accepted even if underpriced
+	local := pricedTransaction(1, big.NewInt(100000), big.NewInt(0), keys[2])
+	pool.SetLocal(local)
+	if err := pool.Add(local); err != nil {
+		t.Fatalf("failed to add underpriced local transaction: %v", err)
+	}
+	if pool.Get(local.Hash()) == nil {
+		t.Fatalf("local transaction missing")
+	}
+	if total := len(pool.all); total != 4 {
+		t.Fatalf("pool size mismatch: have %d, want %d", total, 4)
+	}
+}
+
+// Tests that transactions of gas free senders are admitted into a full pool even
+// if underpriced, and are never evicted to make room for better paying ones.
+func TestTransactionPoolGasFreeUnderpricing(t *testing.T) {
+	// Create the pool to test the pricing enforcement with
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		statedb.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	config := *params.TestChainConfig
+	config.GasFree = &params.GasFreeConfig{Senders: []common.Address{crypto.PubkeyToAddress(keys[2].PublicKey)}}
+
+	pool := NewTxPool(&config, new(event.TypeMux), func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
+	pool.resetState()
+	defer pool.Stop()
+
+	pool.SetLimits(TxPoolConfig{GlobalSlots: 2, GlobalQueue: 2})
+
+	// Fill the pool up with remote transactions
+	for i, tx := range []*types.Transaction{
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(2), keys[0]),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(3), keys[0]),
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(1), keys[1]),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(4), keys[1]),
+	} {
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	// Ensure that a gas free transaction is accepted even if underpriced
+	free := pricedTransaction(0, big.NewInt(100000), big.NewInt(0), keys[2])
+	if err := pool.Add(free); err != nil {
+		t.Fatalf("failed to add underpriced gas free transaction: %v", err)
+	}
+	if total := len(pool.all); total != 4 {
+		t.Fatalf("pool size mismatch: have %d, want %d", total, 4)
+	}
+	// Ensure that better paying transactions evict remote ones, but never the gas free one
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(5), keys[3])); err != nil {
+		t.Fatalf("failed to add well priced transaction: %v", err)
+	}
+	if pool.Get(free.Hash()) == nil {
+		t.Fatalf("gas free transaction evicted")
+	}
+	if total := len(pool.all); total != 4 {
+		t.Fatalf("pool size mismatch: have %d, want %d", total, 4)
+	}
+}
@@ -18,6 +18,7 @@ package types
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"reflect"
@@ -68,3 +69,91 @@ func TestBlockEncoding(t *testing.T) {
 		t.Errorf("encoded block mismatch:\ngot:  %x\nwant: %x", ourBlockEnc, blockEnc)
 	}
 }
+
+// Tests that headers survive a JSON round trip, and that their encoding carries
+// the block hash.
+func TestHeaderJSON(t *testing.T) {
+	header := &Header{
+		ParentHash:  common.Hash{0x01},
+		UncleHash:   EmptyUncleHash,
+		Coinbase:    common.Address{0x02},
+		Root:        common.Hash{0x03},
+		TxHash:      EmptyRootHash,
+		ReceiptHash: EmptyRootHash,
+		Bloom:       Bloom{0x04},
+		Difficulty:  big.NewInt(131072),
+		Number:      big.NewInt(100),
+		GasLimit:    big.NewInt(3141592),
+		GasUsed:     big.NewInt(21000),
+		Time:        big.NewInt(1426516743),
+		Extra:       []byte("extra"),
+		MixDigest:   common.Hash{0x05},
+		Nonce:       EncodeNonce(0xa13a5a8c8f2bb1c4),
+	}
+	enc, err := json.Marshal(header)
+	if err != nil {
+		t.Fatalf("failed to encode header: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		t.Fatalf("failed to decode header fields: %v", err)
+	}
+	if have, want := fields["hash"], header.Hash().Hex(); have != want {
+		t.Errorf("hash mismatch: have %v, want %v", have, want)
+	}
+	if have, want := fields["number"], "0x64"; have != want {
+		t.Errorf("number mismatch: have %v, want %v", have, want)
+	}
+	var dec Header
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatalf("failed to decode header: %v", err)
+	}
+	if !reflect.DeepEqual(&dec, header) {
+		t.Errorf("header mismatch after round trip:\nhave %+v\nwant %+v", &dec, header)
+	}
+}
+
+// Tests that headers without a proof of work, lacking the mixHash and nonce fields,
+// decode from JSON with those left empty.
+func TestHeaderJSONWithoutSeal(t *testing.T) {
+	header := &Header{
+		ParentHash:  common.Hash{0x01},
+		UncleHash:   EmptyUncleHash,
+		Coinbase:    common.Address{0x02},
+		Root:        common.Hash{0x03},
+		TxHash:      EmptyRootHash,
+		ReceiptHash: EmptyRootHash,
+		Difficulty:  big.NewInt(131072),
+		Number:      big.NewInt(100),
+		GasLimit:    big.NewInt(3141592),
+		GasUsed:     big.NewInt(21000),
+		Time:        big.NewInt(1426516743),
+		Extra:       []byte("extra"),
+	}
+	enc, err := json.Marshal(header)
+	if err != nil {
+		t.Fatalf("failed to encode header: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		t.Fatalf("failed to decode header fields: %v", err)
+	}
+	delete(fields, "mixHash")
+	delete(fields, "nonce")
+	if enc, err = json.Marshal(fields); err != nil {
+		t.Fatalf("failed to encode header fields: %v", err)
+	}
+	var dec Header
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatalf("failed to decode header without seal: %v", err)
+	}
+	if !reflect.DeepEqual(&dec, header) {
+		t.Errorf("header mismatch after round trip:\nhave %+v\nwant %+v", &dec, header)
+	}
+	// Any other field remains required
+	delete(fields, "stateRoot")
+	enc, _ = json.Marshal(fields)
+	if err := json.Unmarshal(enc, &dec); err == nil {
+		t.Errorf("header without state root decoded")
+	}
+}
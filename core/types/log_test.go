@@ -115,6 +115,27 @@ func TestUnmarshalLog(t *testing.T) {
 	}
 }
 
+func TestLogJSONRoundTrip(t *testing.T) {
+	for name, test := range unmarshalLogTests {
+		if test.wantError != nil {
+			continue
+		}
+		enc, err := json.Marshal(test.want)
+		if err != nil {
+			t.Errorf("test %q: failed to encode log: %v", name, err)
+			continue
+		}
+		var log *Log
+		if err := json.Unmarshal(enc, &log); err != nil {
+			t.Errorf("test %q: failed to decode log: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(log, test.want) {
+			t.Errorf("test %q: log mismatch after round trip:\nhave %+v\nwant %+v", name, log, test.want)
+		}
+	}
+}
+
 func checkError(t *testing.T, testname string, got, want error) bool {
 	if got == nil {
 		if want != nil {
@@ -0,0 +1,59 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+)
+
+// Tests that receipts, including their logs, survive a JSON round trip.
+func TestReceiptJSON(t *testing.T) {
+	receipt := &Receipt{
+		PostState:         common.Hash{0x01}.Bytes(),
+		CumulativeGasUsed: big.NewInt(42000),
+		Bloom:             Bloom{0x02},
+		Logs: []*Log{{
+			Address:     common.Address{0x03},
+			Topics:      []common.Hash{{0x04}},
+			Data:        []byte{0x05},
+			BlockNumber: 100,
+			TxHash:      common.Hash{0x06},
+			TxIndex:     1,
+			BlockHash:   common.Hash{0x07},
+			Index:       2,
+		}},
+		TxHash:          common.Hash{0x06},
+		ContractAddress: common.Address{0x08},
+		GasUsed:         big.NewInt(21000),
+	}
+	enc, err := json.Marshal(receipt)
+	if err != nil {
+		t.Fatalf("failed to encode receipt: %v", err)
+	}
+	var dec Receipt
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatalf("failed to decode receipt: %v", err)
+	}
+	if !reflect.DeepEqual(&dec, receipt) {
+		t.Errorf("receipt mismatch after round trip:\nhave %+v\nwant %+v", &dec, receipt)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/hexutil"
 	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/crypto/sha3"
 	"github.com/expanse-org/go-expanse/rlp"
 )
 
@@ -200,13 +201,26 @@ func (tx *Transaction) To() *common.Address {
 
 // Hash hashes the RLP encoding of tx.
 // It uniquely identifies the transaction.
+//
+// The hash is computed on first use and cached afterwards. As the size of the
+// encoding falls out of the hashing for free, it is cached along with it.
 func (tx *Transaction) Hash() common.Hash {
 	if hash := tx.hash.Load(); hash != nil {
 		return hash.(common.Hash)
 	}
-	v := rlpHash(tx)
-	tx.hash.Store(v)
-	return v
+	var (
+		h  common.Hash
+		hw = sha3.NewKeccak256()
+		c  = writeCounter(0)
+	)
+	rlp.Encode(io.MultiWriter(hw, &c), &tx.data)
+	hw.Sum(h[:0])
+
+	tx.hash.Store(h)
+	if tx.size.Load() == nil {
+		tx.size.Store(common.StorageSize(c))
+	}
+	return h
 }
 
 // SigHash returns the hash to be signed by the sender.
@@ -215,6 +229,8 @@ func (tx *Transaction) SigHash(signer Signer) common.Hash {
 	return signer.Hash(tx)
 }
 
+// Size returns the true RLP encoded storage size of the transaction, either by
+// encoding and returning it, or returning a previously cached value.
 func (tx *Transaction) Size() common.StorageSize {
 	if size := tx.size.Load(); size != nil {
 		return size.(common.StorageSize)
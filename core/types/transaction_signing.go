@@ -25,10 +25,21 @@ import (
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/params"
+	"github.com/hashicorp/golang-lru"
 )
 
 var ErrInvalidChainId = errors.New("invalid chaid id for signer")
 
+// senderCacheLimit is the number of recently recovered transaction senders to
+// remember across transaction instances.
+const senderCacheLimit = 16384
+
+// senderCache remembers the senders of recently seen transactions by hash. The
+// same transaction is usually decoded several times (once when propagated into
+// the pool, again when included in a block), and without this each instance
+// would need its own expensive signature recovery.
+var senderCache, _ = lru.New(senderCacheLimit)
+
 // sigCache is used to cache the derived sender and contains
 // the signer used to derive it.
 type sigCache struct {
@@ -69,7 +80,9 @@ func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, err
 //
 // Sender may cache the address, allowing it to be used regardless of
 // signing method. The cache is invalidated if the cached signer does
-// not match the signer used in the current call.
+// not match the signer used in the current call. Recently derived senders
+// are also remembered by transaction hash, so other instances of the same
+// transaction don't need to derive them again.
 func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 	if sc := tx.from.Load(); sc != nil {
 		sigCache := sc.(sigCache)
@@ -81,13 +94,25 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 		}
 	}
 
+	// Check whether another instance of the same transaction was recovered before.
+	// The hash covers the signature too, so equal hashes imply the same sender.
+	hash := tx.Hash()
+	if sc, ok := senderCache.Get(hash); ok {
+		if sigCache := sc.(sigCache); sigCache.signer.Equal(signer) {
+			tx.from.Store(sigCache)
+			return sigCache.from, nil
+		}
+	}
 	pubkey, err := signer.PublicKey(tx)
 	if err != nil {
 		return common.Address{}, err
 	}
 	var addr common.Address
 	copy(addr[:], crypto.Keccak256(pubkey[1:])[12:])
-	tx.from.Store(sigCache{signer: signer, from: addr})
+
+	cache := sigCache{signer: signer, from: addr}
+	tx.from.Store(cache)
+	senderCache.Add(hash, cache)
 	return addr, nil
 }
 
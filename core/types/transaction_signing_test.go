@@ -136,3 +136,58 @@ func TestChainId(t *testing.T) {
 		t.Error("expected no error")
 	}
 }
+
+// countingSigner is a signer counting the public key recoveries it performs.
+type countingSigner struct {
+	HomesteadSigner
+	recoveries int
+}
+
+func (s *countingSigner) PublicKey(tx *Transaction) ([]byte, error) {
+	s.recoveries++
+	return s.HomesteadSigner.PublicKey(tx)
+}
+
+func (s *countingSigner) Equal(s2 Signer) bool {
+	return s2 == Signer(s)
+}
+
+// Tests that senders are only recovered once across multiple instances of the
+// same transaction, but are still recovered for new signers.
+func TestSenderCacheAcrossInstances(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	tx, err := SignTx(NewTransaction(0, addr, new(big.Int), new(big.Int), new(big.Int), nil), HomesteadSigner{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, _ := rlp.EncodeToBytes(tx)
+
+	signer := new(countingSigner)
+	for i := 0; i < 3; i++ {
+		// Decode a fresh copy of the transaction, as if received multiple times
+		dec := new(Transaction)
+		if err := rlp.DecodeBytes(blob, dec); err != nil {
+			t.Fatalf("copy %d: failed to decode transaction: %v", i, err)
+		}
+		from, err := Sender(signer, dec)
+		if err != nil {
+			t.Fatalf("copy %d: failed to derive sender: %v", i, err)
+		}
+		if from != addr {
+			t.Fatalf("copy %d: sender mismatch: have %x, want %x", i, from, addr)
+		}
+	}
+	if signer.recoveries != 1 {
+		t.Fatalf("recovery count mismatch: have %d, want %d", signer.recoveries, 1)
+	}
+	// A different signer must not reuse the cached sender
+	other := new(countingSigner)
+	if _, err := Sender(other, tx); err != nil {
+		t.Fatalf("failed to derive sender with new signer: %v", err)
+	}
+	if other.recoveries != 1 {
+		t.Fatalf("new signer recovery count mismatch: have %d, want %d", other.recoveries, 1)
+	}
+}
@@ -232,3 +232,55 @@ func TestTransactionJSON(t *testing.T) {
 		}
 	}
 }
+
+// Tests that hashing a transaction caches its encoded size as well.
+func TestTransactionHashCachesSize(t *testing.T) {
+	tx := &Transaction{data: rightvrsTx.data}
+	tx.Hash()
+
+	cached := tx.size.Load()
+	if cached == nil {
+		t.Fatalf("size not cached by hashing")
+	}
+	enc, _ := rlp.EncodeToBytes(tx)
+	if size := cached.(common.StorageSize); size != common.StorageSize(len(enc)) {
+		t.Fatalf("cached size mismatch: have %v, want %v", size, len(enc))
+	}
+	if hash := rlpHash(tx); tx.Hash() != hash {
+		t.Fatalf("hash mismatch: have %x, want %x", tx.Hash(), hash)
+	}
+}
+
+func BenchmarkTransactionHash(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		tx := &Transaction{data: rightvrsTx.data}
+		tx.Hash()
+	}
+}
+
+func BenchmarkTransactionHashCached(b *testing.B) {
+	tx := &Transaction{data: rightvrsTx.data}
+	tx.Hash()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx.Hash()
+	}
+}
+
+func BenchmarkTransactionSize(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		tx := &Transaction{data: rightvrsTx.data}
+		tx.Size()
+	}
+}
+
+func BenchmarkTransactionSizeCached(b *testing.B) {
+	tx := &Transaction{data: rightvrsTx.data}
+	tx.Size()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx.Size()
+	}
+}
@@ -24,4 +24,9 @@ var (
 	ErrDepth               = errors.New("max call depth exceeded")
 	ErrTraceLimitReached   = errors.New("the number of logs reached the specified limit")
 	ErrInsufficientBalance = errors.New("insufficient balance for transfer")
+
+	ErrContractAddressCollision = errors.New("contract address collision")
+	ErrReturnDataOutOfBounds    = errors.New("return data out of bounds")
+	ErrWriteProtection          = errors.New("write protection")
+	ErrExecutionReverted        = errors.New("execution reverted")
 )
@@ -2,7 +2,10 @@ package vm
 
 import (
 	"math"
+	"math/big"
 	"testing"
+
+	"github.com/expanse-org/go-expanse/params"
 )
 
 func TestMemoryGasCost(t *testing.T) {
@@ -22,3 +25,33 @@ func TestMemoryGasCost(t *testing.T) {
 		t.Error("expected error")
 	}
 }
+
+// Tests the CREATE2 gas costs against the examples of EIP1014, which exclude
+// memory expansion.
+func TestCreate2Gas(t *testing.T) {
+	tests := []struct {
+		size uint64
+		gas  uint64
+	}{
+		{0, 32000},
+		{1, 32006},
+		{4, 32006},
+		{32, 32006},
+		{36, 32012},
+	}
+	for i, tt := range tests {
+		stack := newstack()
+		stack.push(new(big.Int))                    // salt
+		stack.push(new(big.Int).SetUint64(tt.size)) // size
+		stack.push(new(big.Int))                    // offset
+		stack.push(new(big.Int))                    // value
+
+		gas, err := gasCreate2(params.GasTableEIP158, nil, nil, stack, NewMemory(), 0)
+		if err != nil {
+			t.Fatalf("test %d: failed to calculate gas: %v", i, err)
+		}
+		if gas != tt.gas {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, gas, tt.gas)
+		}
+	}
+}
@@ -27,7 +27,10 @@ import (
 	"github.com/expanse-org/go-expanse/params"
 )
 
-var bigZero = new(big.Int)
+var (
+	bigZero = new(big.Int)
+	big256  = big.NewInt(256)
+)
 
 func opAdd(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	x, y := stack.pop(), stack.pop()
@@ -265,6 +268,59 @@ func opByte(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Sta
 	evm.interpreter.intPool.put(th, val)
 	return nil, nil
 }
+
+// opSHL implements Shift Left
+// The SHL instruction (shift left) pops 2 values from the stack, first arg1 and then arg2,
+// and pushes on the stack arg2 shifted to the left by arg1 number of bits.
+func opSHL(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	shift, value := stack.pop(), stack.pop()
+	if shift.Cmp(big256) >= 0 {
+		stack.push(value.SetUint64(0))
+	} else {
+		stack.push(math.U256(value.Lsh(value, uint(shift.Uint64()))))
+	}
+
+	evm.interpreter.intPool.put(shift)
+	return nil, nil
+}
+
+// opSHR implements Logical Shift Right
+// The SHR instruction (logical shift right) pops 2 values from the stack, first arg1 and then arg2,
+// and pushes on the stack arg2 shifted to the right by arg1 number of bits with zero fill.
+func opSHR(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	shift, value := stack.pop(), stack.pop()
+	if shift.Cmp(big256) >= 0 {
+		stack.push(value.SetUint64(0))
+	} else {
+		stack.push(value.Rsh(value, uint(shift.Uint64())))
+	}
+
+	evm.interpreter.intPool.put(shift)
+	return nil, nil
+}
+
+// opSAR implements Arithmetic Shift Right
+// The SAR instruction (arithmetic shift right) pops 2 values from the stack, first arg1 and then arg2,
+// and pushes on the stack arg2 shifted to the right by arg1 number of bits with sign extension.
+func opSAR(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	// Note, S256 returns (potentially) a new bigint, so we're popping, not peeking this one
+	shift, value := stack.pop(), math.S256(stack.pop())
+	if shift.Cmp(big256) >= 0 {
+		if value.Sign() >= 0 {
+			value = evm.interpreter.intPool.get().SetUint64(0)
+		} else {
+			value = evm.interpreter.intPool.get().SetInt64(-1)
+		}
+	} else {
+		// Go's big.Int right shift rounds towards negative infinity, which is
+		// exactly the sign extending behaviour required
+		value = evm.interpreter.intPool.get().Rsh(value, uint(shift.Uint64()))
+	}
+	stack.push(math.U256(value))
+
+	evm.interpreter.intPool.put(shift)
+	return nil, nil
+}
 func opAddmod(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	x, y, z := stack.pop(), stack.pop(), stack.pop()
 	if z.Cmp(bigZero) > 0 {
@@ -357,6 +413,31 @@ func opCalldataCopy(pc *uint64, evm *EVM, contract *Contract, memory *Memory, st
 	return nil, nil
 }
 
+func opReturnDataSize(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	stack.push(evm.interpreter.intPool.get().SetUint64(uint64(len(evm.interpreter.returnData))))
+	return nil, nil
+}
+
+func opReturnDataCopy(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	var (
+		mOff = stack.pop()
+		rOff = stack.pop()
+		l    = stack.pop()
+
+		end = evm.interpreter.intPool.get().Add(rOff, l)
+	)
+	defer evm.interpreter.intPool.put(mOff, rOff, l, end)
+
+	// Unlike the other copy operations, reading past the end of the return
+	// data is an exceptional halt instead of zero padding.
+	if end.BitLen() > 64 || uint64(len(evm.interpreter.returnData)) < end.Uint64() {
+		return nil, ErrReturnDataOutOfBounds
+	}
+	memory.Set(mOff.Uint64(), l.Uint64(), evm.interpreter.returnData[rOff.Uint64():end.Uint64()])
+
+	return nil, nil
+}
+
 func opExtCodeSize(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	a := stack.pop()
 
@@ -367,6 +448,22 @@ func opExtCodeSize(pc *uint64, evm *EVM, contract *Contract, memory *Memory, sta
 	return nil, nil
 }
 
+func opExtCodeHash(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	a := stack.pop()
+
+	// Accounts that don't exist or are empty hash to zero, as opposed to
+	// the hash of empty code.
+	addr := common.BigToAddress(a)
+	if evm.StateDB.Empty(addr) {
+		a.SetUint64(0)
+	} else {
+		a.SetBytes(evm.StateDB.GetCodeHash(addr).Bytes())
+	}
+	stack.push(a)
+
+	return nil, nil
+}
+
 func opCodeSize(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	l := evm.interpreter.intPool.get().SetInt64(int64(len(contract.Code)))
 	stack.push(l)
@@ -550,7 +647,13 @@ func opCreate(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *S
 	}
 
 	contract.UseGas(gas)
-	_, addr, returnGas, suberr := evm.Create(contract, input, gas, value)
+	res, addr, returnGas, suberr := evm.Create(contract, input, gas, value)
+	// Only a reverted creation returns data, the revert reason
+	if suberr == ErrExecutionReverted {
+		evm.interpreter.returnData = res
+	} else {
+		evm.interpreter.returnData = nil
+	}
 	// Push item on the stack based on the returned error. If the ruleset is
 	// homestead we must check for CodeStoreOutOfGasError (homestead only
 	// rule) and treat as an error, if the ruleset is frontier we must
@@ -569,6 +672,41 @@ func opCreate(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *S
 	return nil, nil
 }
 
+func opCreate2(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	var (
+		value        = stack.pop()
+		offset, size = stack.pop(), stack.pop()
+		salt         = stack.pop()
+		input        = memory.Get(offset.Int64(), size.Int64())
+		gas          = contract.Gas
+	)
+	if evm.ChainConfig().IsEIP150(evm.BlockNumber) {
+		gas -= gas / 64
+	}
+
+	contract.UseGas(gas)
+	res, addr, returnGas, suberr := evm.Create2(contract, input, gas, value, salt)
+	// Only a reverted creation returns data, the revert reason
+	if suberr == ErrExecutionReverted {
+		evm.interpreter.returnData = res
+	} else {
+		evm.interpreter.returnData = nil
+	}
+	// Push item on the stack based on the returned error, as for CREATE.
+	if evm.ChainConfig().IsHomestead(evm.BlockNumber) && suberr == ErrCodeStoreOutOfGas {
+		stack.push(new(big.Int))
+	} else if suberr != nil && suberr != ErrCodeStoreOutOfGas {
+		stack.push(new(big.Int))
+	} else {
+		stack.push(addr.Big())
+	}
+	contract.Gas += returnGas
+
+	evm.interpreter.intPool.put(value, offset, size, salt)
+
+	return nil, nil
+}
+
 func opCall(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	gas := stack.pop().Uint64()
 	// pop gas and value of the stack.
@@ -589,11 +727,13 @@ func opCall(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Sta
 	}
 
 	ret, returnGas, err := evm.Call(contract, address, args, gas, value)
+	evm.interpreter.returnData = ret
 	if err != nil {
 		stack.push(new(big.Int))
 	} else {
 		stack.push(big.NewInt(1))
-
+	}
+	if err == nil || err == ErrExecutionReverted {
 		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 	contract.Gas += returnGas
@@ -622,12 +762,13 @@ func opCallCode(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack
 	}
 
 	ret, returnGas, err := evm.CallCode(contract, address, args, gas, value)
+	evm.interpreter.returnData = ret
 	if err != nil {
 		stack.push(new(big.Int))
-
 	} else {
 		stack.push(big.NewInt(1))
-
+	}
+	if err == nil || err == ErrExecutionReverted {
 		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
 	contract.Gas += returnGas
@@ -649,10 +790,35 @@ func opDelegateCall(pc *uint64, evm *EVM, contract *Contract, memory *Memory, st
 	args := memory.Get(inOffset.Int64(), inSize.Int64())
 
 	ret, returnGas, err := evm.DelegateCall(contract, toAddr, args, gas)
+	evm.interpreter.returnData = ret
 	if err != nil {
 		stack.push(new(big.Int))
 	} else {
 		stack.push(big.NewInt(1))
+	}
+	if err == nil || err == ErrExecutionReverted {
+		memory.Set(outOffset.Uint64(), outSize.Uint64(), ret)
+	}
+	contract.Gas += returnGas
+
+	evm.interpreter.intPool.put(to, inOffset, inSize, outOffset, outSize)
+	return nil, nil
+}
+
+func opStaticCall(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	gas, to, inOffset, inSize, outOffset, outSize := stack.pop().Uint64(), stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
+
+	toAddr := common.BigToAddress(to)
+	args := memory.Get(inOffset.Int64(), inSize.Int64())
+
+	ret, returnGas, err := evm.StaticCall(contract, toAddr, args, gas)
+	evm.interpreter.returnData = ret
+	if err != nil {
+		stack.push(new(big.Int))
+	} else {
+		stack.push(big.NewInt(1))
+	}
+	if err == nil || err == ErrExecutionReverted {
 		memory.Set(outOffset.Uint64(), outSize.Uint64(), ret)
 	}
 	contract.Gas += returnGas
@@ -669,6 +835,14 @@ func opReturn(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *S
 	return ret, nil
 }
 
+func opRevert(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	offset, size := stack.pop(), stack.pop()
+	ret := memory.GetPtr(offset.Int64(), size.Int64())
+
+	evm.interpreter.intPool.put(offset, size)
+	return ret, nil
+}
+
 func opStop(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	return nil, nil
 }
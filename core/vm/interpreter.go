@@ -60,22 +60,28 @@ type Interpreter struct {
 	cfg      Config
 	gasTable params.GasTable
 	intPool  *intPool
+
+	stackLimit int // Maximum number of items on the stack
+
+	readOnly   bool   // Whether to throw on stateful modifications
+	returnData []byte // Last CALL's return data for subsequent reuse
 }
 
 // NewInterpreter returns a new instance of the Interpreter.
 func NewInterpreter(env *EVM, cfg Config) *Interpreter {
 	// We use the STOP instruction whether to see
 	// the jump table was initialised. If it was not
-	// we'll set the default jump table.
+	// we'll set the jump table of the active fork.
 	if !cfg.JumpTable[STOP].valid {
-		cfg.JumpTable = defaultJumpTable
+		cfg.JumpTable = jumpTables[jumpTableExtensions(env.ChainConfig(), env.BlockNumber)]
 	}
 
 	return &Interpreter{
-		env:      env,
-		cfg:      cfg,
-		gasTable: env.ChainConfig().GasTable(env.BlockNumber),
-		intPool:  newIntPool(),
+		env:        env,
+		cfg:        cfg,
+		gasTable:   env.ChainConfig().GasTable(env.BlockNumber),
+		intPool:    newIntPool(),
+		stackLimit: int(env.ChainConfig().StackLimit()),
 	}
 }
 
@@ -84,6 +90,10 @@ func (evm *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err e
 	evm.env.depth++
 	defer func() { evm.env.depth-- }()
 
+	// Reset the previous call's return data. It's unimportant to preserve the
+	// old buffer as every returning call will return new data anyway.
+	evm.returnData = nil
+
 	if contract.CodeAddr != nil {
 		if p := PrecompiledContracts[*contract.CodeAddr]; p != nil {
 			return RunPrecompiledContract(p, input, contract)
@@ -142,7 +152,11 @@ func (evm *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err e
 
 		// validate the stack and make sure there enough stack items available
 		// to perform the operation
-		if err := operation.validateStack(stack); err != nil {
+		if err := operation.validateStack(stack, evm.stackLimit); err != nil {
+			return nil, err
+		}
+		// If the operation is valid, enforce the write restrictions
+		if err := evm.enforceRestrictions(op, operation, stack); err != nil {
 			return nil, err
 		}
 
@@ -189,6 +203,8 @@ func (evm *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err e
 		switch {
 		case err != nil:
 			return nil, err
+		case operation.reverts:
+			return res, ErrExecutionReverted
 		case operation.halts:
 			return res, nil
 		case !operation.jumps:
@@ -197,3 +213,16 @@ func (evm *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err e
 	}
 	return nil, nil
 }
+
+// enforceRestrictions returns an error if the operation would modify the state
+// while the interpreter executes a read-only (static) call frame.
+func (evm *Interpreter) enforceRestrictions(op OpCode, operation operation, stack *Stack) error {
+	if evm.readOnly {
+		// The 3rd stack item of a call operation is the value. Transferring
+		// value from one account to another modifies the state as well.
+		if operation.writes || (op == CALL && stack.Back(2).Sign() != 0) {
+			return ErrWriteProtection
+		}
+	}
+	return nil
+}
@@ -26,7 +26,7 @@ import (
 type (
 	executionFunc       func(pc *uint64, env *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error)
 	gasFunc             func(params.GasTable, *EVM, *Contract, *Stack, *Memory, uint64) (uint64, error) // last parameter is the requested memory size as a uint64
-	stackValidationFunc func(*Stack) error
+	stackValidationFunc func(*Stack, int) error
 	memorySizeFunc      func(*Stack) *big.Int
 )
 
@@ -49,9 +49,139 @@ type operation struct {
 	jumps bool
 	// valid is used to check whether the retrieved operation is valid and known
 	valid bool
+	// writes determines whether this a state modifying operation
+	writes bool
+	// reverts determines whether the operation reverts state (implicitly halts)
+	reverts bool
 }
 
-var defaultJumpTable = NewJumpTable()
+// Instruction set extensions introduced by forks after Homestead. Every fork
+// is scheduled separately in the chain configuration, so the active instruction
+// set is any combination of them.
+const (
+	extCreate2    = 1 << iota // EIP1014: CREATE2
+	extReturnData             // EIP211: RETURNDATASIZE and RETURNDATACOPY
+	extShifts                 // EIP145: SHL, SHR and SAR
+	extCodeHash               // EIP1052: EXTCODEHASH
+	extRevert                 // EIP140: REVERT
+	extStaticCall             // EIP214: STATICCALL
+
+	extAll = extCreate2 | extReturnData | extShifts | extCodeHash | extRevert | extStaticCall
+)
+
+// jumpTables contains the instruction set of every combination of extensions,
+// indexed by the extension bitmask.
+var jumpTables [extAll + 1][256]operation
+
+func init() {
+	for ext := range jumpTables {
+		jumpTables[ext] = newExtendedJumpTable(ext)
+	}
+}
+
+// jumpTableExtensions returns the bitmask of the instruction set extensions
+// active at the given block number.
+func jumpTableExtensions(config *params.ChainConfig, num *big.Int) int {
+	var ext int
+	if config.IsEIP1014(num) {
+		ext |= extCreate2
+	}
+	if config.IsEIP211(num) {
+		ext |= extReturnData
+	}
+	if config.IsEIP145(num) {
+		ext |= extShifts
+	}
+	if config.IsEIP1052(num) {
+		ext |= extCodeHash
+	}
+	if config.IsEIP140(num) {
+		ext |= extRevert
+	}
+	if config.IsEIP214(num) {
+		ext |= extStaticCall
+	}
+	return ext
+}
+
+// newExtendedJumpTable returns the default instructions with the operations of
+// the given extensions added.
+func newExtendedJumpTable(ext int) [256]operation {
+	instructionSet := NewJumpTable()
+	if ext&extCreate2 != 0 {
+		instructionSet[CREATE2] = operation{
+			execute:       opCreate2,
+			gasCost:       gasCreate2,
+			validateStack: makeStackFunc(4, 1),
+			memorySize:    memoryCreate,
+			valid:         true,
+			writes:        true,
+		}
+	}
+	if ext&extReturnData != 0 {
+		instructionSet[RETURNDATASIZE] = operation{
+			execute:       opReturnDataSize,
+			gasCost:       constGasFunc(GasQuickStep),
+			validateStack: makeStackFunc(0, 1),
+			valid:         true,
+		}
+		instructionSet[RETURNDATACOPY] = operation{
+			execute:       opReturnDataCopy,
+			gasCost:       gasReturnDataCopy,
+			validateStack: makeStackFunc(3, 0),
+			memorySize:    memoryReturnDataCopy,
+			valid:         true,
+		}
+	}
+	if ext&extShifts != 0 {
+		instructionSet[SHL] = operation{
+			execute:       opSHL,
+			gasCost:       constGasFunc(GasFastestStep),
+			validateStack: makeStackFunc(2, 1),
+			valid:         true,
+		}
+		instructionSet[SHR] = operation{
+			execute:       opSHR,
+			gasCost:       constGasFunc(GasFastestStep),
+			validateStack: makeStackFunc(2, 1),
+			valid:         true,
+		}
+		instructionSet[SAR] = operation{
+			execute:       opSAR,
+			gasCost:       constGasFunc(GasFastestStep),
+			validateStack: makeStackFunc(2, 1),
+			valid:         true,
+		}
+	}
+	if ext&extCodeHash != 0 {
+		instructionSet[EXTCODEHASH] = operation{
+			execute:       opExtCodeHash,
+			gasCost:       gasExtCodeHash,
+			validateStack: makeStackFunc(1, 1),
+			valid:         true,
+		}
+	}
+	if ext&extRevert != 0 {
+		instructionSet[REVERT] = operation{
+			execute:       opRevert,
+			gasCost:       gasRevert,
+			validateStack: makeStackFunc(2, 0),
+			memorySize:    memoryRevert,
+			valid:         true,
+			reverts:       true,
+		}
+	}
+	if ext&extStaticCall != 0 {
+		instructionSet[STATICCALL] = operation{
+			execute:       opStaticCall,
+			gasCost:       gasStaticCall,
+			validateStack: makeStackFunc(6, 1),
+			memorySize:    memoryStaticCall,
+			valid:         true,
+		}
+	}
+	return instructionSet
+}
 
 func NewJumpTable() [256]operation {
 	return [256]operation{
@@ -357,6 +487,7 @@ func NewJumpTable() [256]operation {
 			gasCost:       gasSStore,
 			validateStack: makeStackFunc(2, 0),
 			valid:         true,
+			writes:        true,
 		},
 		JUMP: {
 			execute:       opJump,
@@ -786,6 +917,7 @@ func NewJumpTable() [256]operation {
 			validateStack: makeStackFunc(2, 0),
 			memorySize:    memoryLog,
 			valid:         true,
+			writes:        true,
 		},
 		LOG1: {
 			execute:       makeLog(1),
@@ -793,6 +925,7 @@ func NewJumpTable() [256]operation {
 			validateStack: makeStackFunc(3, 0),
 			memorySize:    memoryLog,
 			valid:         true,
+			writes:        true,
 		},
 		LOG2: {
 			execute:       makeLog(2),
@@ -800,6 +933,7 @@ func NewJumpTable() [256]operation {
 			validateStack: makeStackFunc(4, 0),
 			memorySize:    memoryLog,
 			valid:         true,
+			writes:        true,
 		},
 		LOG3: {
 			execute:       makeLog(3),
@@ -807,6 +941,7 @@ func NewJumpTable() [256]operation {
 			validateStack: makeStackFunc(5, 0),
 			memorySize:    memoryLog,
 			valid:         true,
+			writes:        true,
 		},
 		LOG4: {
 			execute:       makeLog(4),
@@ -814,6 +949,7 @@ func NewJumpTable() [256]operation {
 			validateStack: makeStackFunc(6, 0),
 			memorySize:    memoryLog,
 			valid:         true,
+			writes:        true,
 		},
 		CREATE: {
 			execute:       opCreate,
@@ -821,6 +957,7 @@ func NewJumpTable() [256]operation {
 			validateStack: makeStackFunc(3, 1),
 			memorySize:    memoryCreate,
 			valid:         true,
+			writes:        true,
 		},
 		CALL: {
 			execute:       opCall,
@@ -857,6 +994,7 @@ func NewJumpTable() [256]operation {
 			validateStack: makeStackFunc(1, 0),
 			halts:         true,
 			valid:         true,
+			writes:        true,
 		},
 	}
 }
@@ -63,6 +63,9 @@ const (
 	XOR
 	NOT
 	BYTE
+	SHL
+	SHR
+	SAR
 
 	SHA3 = 0x20
 )
@@ -82,6 +85,9 @@ const (
 	GASPRICE
 	EXTCODESIZE
 	EXTCODECOPY
+	RETURNDATASIZE
+	RETURNDATACOPY
+	EXTCODEHASH
 )
 
 const (
@@ -201,7 +207,10 @@ const (
 	CALLCODE
 	RETURN
 	DELEGATECALL
+	CREATE2
 
+	STATICCALL   = 0xfa
+	REVERT       = 0xfd
 	SELFDESTRUCT = 0xff
 )
 
@@ -231,6 +240,9 @@ var opCodeToString = map[OpCode]string{
 	OR:     "OR",
 	XOR:    "XOR",
 	BYTE:   "BYTE",
+	SHL:    "SHL",
+	SHR:    "SHR",
+	SAR:    "SAR",
 	ADDMOD: "ADDMOD",
 	MULMOD: "MULMOD",
 
@@ -250,6 +262,10 @@ var opCodeToString = map[OpCode]string{
 	CODECOPY:     "CODECOPY",
 	GASPRICE:     "GASPRICE",
 
+	RETURNDATASIZE: "RETURNDATASIZE",
+	RETURNDATACOPY: "RETURNDATACOPY",
+	EXTCODEHASH:    "EXTCODEHASH",
+
 	// 0x40 range - block operations
 	BLOCKHASH:   "BLOCKHASH",
 	COINBASE:    "COINBASE",
@@ -355,6 +371,9 @@ var opCodeToString = map[OpCode]string{
 	RETURN:       "RETURN",
 	CALLCODE:     "CALLCODE",
 	DELEGATECALL: "DELEGATECALL",
+	CREATE2:      "CREATE2",
+	STATICCALL:   "STATICCALL",
+	REVERT:       "REVERT",
 	SELFDESTRUCT: "SELFDESTRUCT",
 
 	PUSH: "PUSH",
@@ -501,7 +520,17 @@ var stringToOp = map[string]OpCode{
 	"CALL":         CALL,
 	"RETURN":       RETURN,
 	"CALLCODE":     CALLCODE,
+	"CREATE2":      CREATE2,
 	"SELFDESTRUCT": SELFDESTRUCT,
+
+	"SHL":            SHL,
+	"SHR":            SHR,
+	"SAR":            SAR,
+	"RETURNDATASIZE": RETURNDATASIZE,
+	"RETURNDATACOPY": RETURNDATACOPY,
+	"EXTCODEHASH":    EXTCODEHASH,
+	"STATICCALL":     STATICCALL,
+	"REVERT":         REVERT,
 }
 
 func StringToOp(str string) OpCode {
@@ -60,6 +60,12 @@ func setDefaults(cfg *Config) {
 			EIP150Block:    new(big.Int),
 			EIP155Block:    new(big.Int),
 			EIP158Block:    new(big.Int),
+			EIP1014Block:   new(big.Int),
+			EIP211Block:    new(big.Int),
+			EIP145Block:    new(big.Int),
+			EIP1052Block:   new(big.Int),
+			EIP140Block:    new(big.Int),
+			EIP214Block:    new(big.Int),
 		}
 	}
 
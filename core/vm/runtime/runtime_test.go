@@ -25,7 +25,9 @@ import (
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/params"
 )
 
 func TestDefaults(t *testing.T) {
@@ -117,6 +119,398 @@ func TestCall(t *testing.T) {
 	}
 }
 
+// Tests that CREATE2 deploys at the address derived from the creator, the salt
+// and the init code, refuses to deploy there twice, and is only available once
+// the EIP1014 fork is active.
+func TestCreate2(t *testing.T) {
+	initCode := []byte{
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}
+	create2 := []byte{
+		byte(vm.PUSH1), 0x2a, // salt
+		byte(vm.PUSH1), byte(len(initCode)),
+		byte(vm.PUSH1), byte(32 - len(initCode)),
+		byte(vm.PUSH1), 0, // value
+		byte(vm.CREATE2),
+	}
+	code := append([]byte{byte(vm.PUSH5)}, initCode...)
+	code = append(code, byte(vm.PUSH1), 0, byte(vm.MSTORE))
+	code = append(code, create2...)
+	code = append(code, byte(vm.PUSH1), 0x20, byte(vm.MSTORE))
+	code = append(code, create2...)
+	code = append(code, byte(vm.PUSH1), 0x40, byte(vm.MSTORE))
+	code = append(code, byte(vm.PUSH1), 0x40, byte(vm.PUSH1), 0x20, byte(vm.RETURN))
+
+	ret, _, err := Execute(code, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to execute CREATE2: %v", err)
+	}
+	want := crypto.CreateAddress2(common.StringToAddress("contract"), common.BigToHash(big.NewInt(0x2a)), crypto.Keccak256(initCode))
+	if have := common.BytesToAddress(ret[:32]); have != want {
+		t.Errorf("created address mismatch: have %x, want %x", have, want)
+	}
+	if have := common.BytesToAddress(ret[32:]); have != (common.Address{}) {
+		t.Errorf("address collision not detected: have %x, want zero address", have)
+	}
+	// Before the fork the opcode must be invalid
+	config := &Config{ChainConfig: &params.ChainConfig{
+		ChainId:        big.NewInt(1),
+		HomesteadBlock: new(big.Int),
+		EIP150Block:    new(big.Int),
+		EIP155Block:    new(big.Int),
+		EIP158Block:    new(big.Int),
+		EIP1014Block:   big.NewInt(10),
+	}, BlockNumber: big.NewInt(9)}
+	if _, _, err := Execute(code, nil, config); err == nil {
+		t.Errorf("CREATE2 executed before the EIP1014 fork")
+	}
+}
+
+// Tests the bitwise shifting opcodes against the test vectors of EIP145.
+func TestShifts(t *testing.T) {
+	tests := []struct {
+		op                   vm.OpCode
+		value, shift, result string
+	}{
+		{vm.SHL, "0000000000000000000000000000000000000000000000000000000000000001", "00", "0000000000000000000000000000000000000000000000000000000000000001"},
+		{vm.SHL, "0000000000000000000000000000000000000000000000000000000000000001", "01", "0000000000000000000000000000000000000000000000000000000000000002"},
+		{vm.SHL, "0000000000000000000000000000000000000000000000000000000000000001", "ff", "8000000000000000000000000000000000000000000000000000000000000000"},
+		{vm.SHL, "0000000000000000000000000000000000000000000000000000000000000001", "0100", "0000000000000000000000000000000000000000000000000000000000000000"},
+		{vm.SHL, "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "01", "fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe"},
+		{vm.SHL, "7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "01", "fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe"},
+		{vm.SHR, "0000000000000000000000000000000000000000000000000000000000000001", "01", "0000000000000000000000000000000000000000000000000000000000000000"},
+		{vm.SHR, "8000000000000000000000000000000000000000000000000000000000000000", "01", "4000000000000000000000000000000000000000000000000000000000000000"},
+		{vm.SHR, "8000000000000000000000000000000000000000000000000000000000000000", "ff", "0000000000000000000000000000000000000000000000000000000000000001"},
+		{vm.SHR, "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "0100", "0000000000000000000000000000000000000000000000000000000000000000"},
+		{vm.SAR, "8000000000000000000000000000000000000000000000000000000000000000", "01", "c000000000000000000000000000000000000000000000000000000000000000"},
+		{vm.SAR, "8000000000000000000000000000000000000000000000000000000000000000", "ff", "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{vm.SAR, "8000000000000000000000000000000000000000000000000000000000000000", "0101", "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{vm.SAR, "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "01", "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{vm.SAR, "4000000000000000000000000000000000000000000000000000000000000000", "fe", "0000000000000000000000000000000000000000000000000000000000000001"},
+		{vm.SAR, "7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "f8", "000000000000000000000000000000000000000000000000000000000000007f"},
+		{vm.SAR, "7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "0100", "0000000000000000000000000000000000000000000000000000000000000000"},
+	}
+	for i, tt := range tests {
+		code := append([]byte{byte(vm.PUSH32)}, common.FromHex(tt.value)...)
+		code = append(code, byte(vm.PUSH32))
+		code = append(code, common.LeftPadBytes(common.FromHex(tt.shift), 32)...)
+		code = append(code, byte(tt.op), byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN))
+
+		ret, _, err := Execute(code, nil, nil)
+		if err != nil {
+			t.Errorf("test %d: %v %s by %s failed: %v", i, tt.op, tt.value, tt.shift, err)
+			continue
+		}
+		if have := common.Bytes2Hex(ret); have != tt.result {
+			t.Errorf("test %d: %v %s by %s result mismatch: have %s, want %s", i, tt.op, tt.value, tt.shift, have, tt.result)
+		}
+	}
+}
+
+// Tests that the return data of the last call can be queried and copied, and
+// that reading past its end aborts the execution.
+func TestReturnData(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+	statedb.SetCode(common.HexToAddress("0x0a"), []byte{
+		byte(vm.PUSH1), 10,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	})
+	call := []byte{
+		byte(vm.PUSH1), 0, // retSize
+		byte(vm.PUSH1), 0, // retOffset
+		byte(vm.PUSH1), 0, // inSize
+		byte(vm.PUSH1), 0, // inOffset
+		byte(vm.PUSH1), 0, // value
+		byte(vm.PUSH1), 0x0a,
+		byte(vm.GAS),
+		byte(vm.CALL),
+		byte(vm.POP),
+	}
+	code := append(call,
+		byte(vm.RETURNDATASIZE), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.PUSH1), 32, byte(vm.RETURNDATACOPY),
+		byte(vm.PUSH1), 64, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	)
+	ret, _, err := Execute(code, nil, &Config{State: statedb})
+	if err != nil {
+		t.Fatalf("failed to execute RETURNDATA operations: %v", err)
+	}
+	if size := new(big.Int).SetBytes(ret[:32]); size.Cmp(big.NewInt(32)) != 0 {
+		t.Errorf("return data size mismatch: have %v, want 32", size)
+	}
+	if data := new(big.Int).SetBytes(ret[32:]); data.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("return data mismatch: have %v, want 10", data)
+	}
+	// Copying past the end of the return data must fail
+	code = append(call[:len(call):len(call)],
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.RETURNDATACOPY),
+		byte(vm.STOP),
+	)
+	if _, _, err := Execute(code, nil, &Config{State: statedb}); err != vm.ErrReturnDataOutOfBounds {
+		t.Errorf("out of bounds copy error mismatch: have %v, want %v", err, vm.ErrReturnDataOutOfBounds)
+	}
+}
+
+// Tests that EXTCODEHASH returns the hash of the code of existing accounts and
+// zero for non existent ones.
+func TestExtCodeHash(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	extCode := []byte{byte(vm.PUSH1), 0, byte(vm.STOP)}
+	statedb.SetCode(common.HexToAddress("0x0a"), extCode)
+	statedb.SetNonce(common.HexToAddress("0x0b"), 1)
+
+	code := []byte{
+		byte(vm.PUSH1), 0x0a, byte(vm.EXTCODEHASH), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x0b, byte(vm.EXTCODEHASH), byte(vm.PUSH1), 32, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x0c, byte(vm.EXTCODEHASH), byte(vm.PUSH1), 64, byte(vm.MSTORE),
+		byte(vm.PUSH1), 96, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}
+	ret, _, err := Execute(code, nil, &Config{State: statedb})
+	if err != nil {
+		t.Fatalf("failed to execute EXTCODEHASH: %v", err)
+	}
+	if have, want := common.BytesToHash(ret[:32]), crypto.Keccak256Hash(extCode); have != want {
+		t.Errorf("contract code hash mismatch: have %x, want %x", have, want)
+	}
+	if have, want := common.BytesToHash(ret[32:64]), crypto.Keccak256Hash(nil); have != want {
+		t.Errorf("codeless account hash mismatch: have %x, want %x", have, want)
+	}
+	if have := common.BytesToHash(ret[64:]); have != (common.Hash{}) {
+		t.Errorf("non existent account hash mismatch: have %x, want zero hash", have)
+	}
+}
+
+// Tests that REVERT rolls back the state changes of the frame, hands its data
+// back to the caller and refunds the remaining gas.
+func TestRevert(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	reverter := common.HexToAddress("0x0a")
+	statedb.SetCode(reverter, []byte{
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.REVERT),
+	})
+	code := []byte{
+		byte(vm.PUSH1), 32, // retSize
+		byte(vm.PUSH1), 0, // retOffset
+		byte(vm.PUSH1), 0, // inSize
+		byte(vm.PUSH1), 0, // inOffset
+		byte(vm.PUSH1), 0, // value
+		byte(vm.PUSH1), 0x0a,
+		byte(vm.GAS),
+		byte(vm.CALL),
+		byte(vm.PUSH1), 32, byte(vm.MSTORE),
+		byte(vm.RETURNDATASIZE), byte(vm.PUSH1), 64, byte(vm.MSTORE),
+		byte(vm.PUSH1), 96, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}
+	ret, _, err := Execute(code, nil, &Config{State: statedb})
+	if err != nil {
+		t.Fatalf("failed to execute reverting call: %v", err)
+	}
+	if data := new(big.Int).SetBytes(ret[:32]); data.Cmp(big.NewInt(0x2a)) != 0 {
+		t.Errorf("revert data mismatch: have %v, want 42", data)
+	}
+	if result := new(big.Int).SetBytes(ret[32:64]); result.Sign() != 0 {
+		t.Errorf("reverted call result mismatch: have %v, want 0", result)
+	}
+	if size := new(big.Int).SetBytes(ret[64:]); size.Cmp(big.NewInt(32)) != 0 {
+		t.Errorf("return data size mismatch: have %v, want 32", size)
+	}
+	if value := statedb.GetState(reverter, common.Hash{}); value != (common.Hash{}) {
+		t.Errorf("reverted storage write persisted: %x", value)
+	}
+	// Calling the reverting contract directly should refund the unused gas
+	cfg := &Config{State: statedb, GasLimit: 100000}
+	setDefaults(cfg)
+
+	ret, gas, err := NewEnv(cfg, statedb).Call(vm.AccountRef(cfg.Origin), reverter, nil, cfg.GasLimit, new(big.Int))
+	if err != vm.ErrExecutionReverted {
+		t.Fatalf("revert error mismatch: have %v, want %v", err, vm.ErrExecutionReverted)
+	}
+	if gas == 0 || gas >= cfg.GasLimit {
+		t.Errorf("leftover gas mismatch: have %d, want in (0, %d)", gas, cfg.GasLimit)
+	}
+	if data := new(big.Int).SetBytes(ret); data.Cmp(big.NewInt(0x2a)) != 0 {
+		t.Errorf("revert data mismatch: have %v, want 42", data)
+	}
+}
+
+// Tests that STATICCALL frames, including any nested calls made from them, can
+// read but not modify the state.
+func TestStaticCall(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	staticcall := func(addr byte) []byte {
+		return []byte{
+			byte(vm.PUSH1), 32, // outSize
+			byte(vm.PUSH1), 0, // outOffset
+			byte(vm.PUSH1), 0, // inSize
+			byte(vm.PUSH1), 0, // inOffset
+			byte(vm.PUSH1), addr,
+			byte(vm.GAS),
+			byte(vm.STATICCALL),
+		}
+	}
+	// 0x0a writes the storage, 0x0b only reads, 0x0c calls 0x0a and returns the result
+	statedb.SetCode(common.HexToAddress("0x0a"), []byte{
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP),
+	})
+	statedb.SetCode(common.HexToAddress("0x0b"), []byte{
+		byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 0x2a, byte(vm.ADD),
+		byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	})
+	statedb.SetCode(common.HexToAddress("0x0c"), []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0x0a, byte(vm.GAS), byte(vm.CALL),
+		byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	})
+	var code []byte
+	code = append(code, staticcall(0x0a)...)
+	code = append(code, byte(vm.PUSH1), 32, byte(vm.MSTORE))
+	code = append(code, staticcall(0x0b)...)
+	code = append(code, byte(vm.PUSH1), 64, byte(vm.MSTORE))
+	code = append(code, staticcall(0x0c)...)
+	code = append(code, byte(vm.POP))
+	code = append(code, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0)
+	code = append(code, byte(vm.PUSH1), 0x0a, byte(vm.GAS), byte(vm.CALL))
+	code = append(code, []byte{
+		byte(vm.PUSH1), 96, byte(vm.MSTORE),
+		byte(vm.PUSH1), 128, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}...)
+	ret, _, err := Execute(code, nil, &Config{State: statedb})
+	if err != nil {
+		t.Fatalf("failed to execute static calls: %v", err)
+	}
+	// The last static call returned the result of the nested write attempt
+	if result := new(big.Int).SetBytes(ret[:32]); result.Sign() != 0 {
+		t.Errorf("nested write in static frame succeeded")
+	}
+	if result := new(big.Int).SetBytes(ret[32:64]); result.Sign() != 0 {
+		t.Errorf("write in static frame succeeded")
+	}
+	if result := new(big.Int).SetBytes(ret[64:96]); result.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("read-only static call failed")
+	}
+	// Regular calls made after the static frames must be writable again
+	if result := new(big.Int).SetBytes(ret[96:]); result.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("write protection leaked out of the static frames")
+	}
+	if value := statedb.GetState(common.HexToAddress("0x0a"), common.Hash{}); value != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("storage mismatch: have %x, want 1", value)
+	}
+}
+
+// Tests that the opcodes introduced by the post Homestead forks are invalid
+// until their respective fork activates.
+func TestForkGatedOpcodes(t *testing.T) {
+	tests := []struct {
+		op   vm.OpCode
+		fork func(*params.ChainConfig, *big.Int)
+	}{
+		{vm.SHL, func(c *params.ChainConfig, n *big.Int) { c.EIP145Block = n }},
+		{vm.SHR, func(c *params.ChainConfig, n *big.Int) { c.EIP145Block = n }},
+		{vm.SAR, func(c *params.ChainConfig, n *big.Int) { c.EIP145Block = n }},
+		{vm.RETURNDATASIZE, func(c *params.ChainConfig, n *big.Int) { c.EIP211Block = n }},
+		{vm.RETURNDATACOPY, func(c *params.ChainConfig, n *big.Int) { c.EIP211Block = n }},
+		{vm.EXTCODEHASH, func(c *params.ChainConfig, n *big.Int) { c.EIP1052Block = n }},
+		{vm.REVERT, func(c *params.ChainConfig, n *big.Int) { c.EIP140Block = n }},
+		{vm.STATICCALL, func(c *params.ChainConfig, n *big.Int) { c.EIP214Block = n }},
+	}
+	for _, tt := range tests {
+		code := []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(tt.op), byte(vm.STOP)}
+		for _, number := range []int64{9, 10} {
+			config := &params.ChainConfig{
+				ChainId:        big.NewInt(1),
+				HomesteadBlock: new(big.Int),
+				EIP150Block:    new(big.Int),
+				EIP155Block:    new(big.Int),
+				EIP158Block:    new(big.Int),
+			}
+			tt.fork(config, big.NewInt(10))
+
+			_, _, err := Execute(code, nil, &Config{ChainConfig: config, BlockNumber: big.NewInt(number)})
+			if err == vm.ErrExecutionReverted {
+				err = nil // REVERT executed successfully
+			}
+			if number < 10 && err == nil {
+				t.Errorf("%v executed before its fork", tt.op)
+			}
+			if number >= 10 && err != nil {
+				t.Errorf("%v failed after its fork: %v", tt.op, err)
+			}
+		}
+	}
+}
+
+// Tests that the call depth and stack size limits of the EVM follow the chain
+// configuration.
+func TestConfiguredLimits(t *testing.T) {
+	newConfig := func(limits *params.LimitsConfig) *Config {
+		return &Config{ChainConfig: &params.ChainConfig{
+			ChainId:        big.NewInt(1),
+			HomesteadBlock: new(big.Int),
+			EIP150Block:    new(big.Int),
+			EIP155Block:    new(big.Int),
+			EIP158Block:    new(big.Int),
+			Limits:         limits,
+		}}
+	}
+	// Count the recursion levels of a contract calling itself until it fails
+	recurse := []byte{
+		byte(vm.PUSH1), 0,
+		byte(vm.SLOAD),
+		byte(vm.PUSH1), 1,
+		byte(vm.ADD),
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+		byte(vm.PUSH1), 0, // retSize
+		byte(vm.PUSH1), 0, // retOffset
+		byte(vm.PUSH1), 0, // inSize
+		byte(vm.PUSH1), 0, // inOffset
+		byte(vm.PUSH1), 0, // value
+		byte(vm.ADDRESS),
+		byte(vm.GAS),
+		byte(vm.CALL),
+		byte(vm.POP),
+		byte(vm.STOP),
+	}
+	for _, depth := range []uint64{8, 100} {
+		_, statedb, err := Execute(recurse, nil, newConfig(&params.LimitsConfig{CallCreateDepth: depth}))
+		if err != nil {
+			t.Fatalf("depth %d: failed to execute recursion: %v", depth, err)
+		}
+		levels := statedb.GetState(common.StringToAddress("contract"), common.Hash{}).Big().Uint64()
+		if levels != depth+1 {
+			t.Errorf("depth %d: recursion levels mismatch: have %d, want %d", depth, levels, depth+1)
+		}
+	}
+	// Push three items onto the stack, exceeding a limit of two
+	push := []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 1,
+		byte(vm.STOP),
+	}
+	if _, _, err := Execute(push, nil, newConfig(nil)); err != nil {
+		t.Errorf("failed to execute with the default stack limit: %v", err)
+	}
+	if _, _, err := Execute(push, nil, newConfig(&params.LimitsConfig{StackLimit: 2})); err == nil {
+		t.Errorf("stack limit of 2 not enforced")
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
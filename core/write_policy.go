@@ -0,0 +1,117 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/ethdb"
+)
+
+// WritePolicy defines how the chain importer batches its database writes and
+// how often it forces them onto stable storage.
+//
+// While syncing old blocks, data is accumulated into large batches that are only
+// synced to disk every few writes, trading crash durability (which is harmless,
+// the data can simply be downloaded again) for import speed. Once the imported
+// blocks are close to the head of the chain, every import is synced.
+type WritePolicy struct {
+	BatchSize    int           // Bytes of chain data to accumulate before writing a batch out
+	SyncInterval int           // Number of batch writes after which to sync to disk (0 = only near the head)
+	HeadAge      time.Duration // Maximum age of a block for it to be considered near the chain head
+}
+
+// DefaultWritePolicy contains the default settings for chain data writes.
+var DefaultWritePolicy = WritePolicy{
+	BatchSize:    ethdb.IdealBatchSize,
+	SyncInterval: 0,
+	HeadAge:      10 * time.Minute,
+}
+
+// nearHead reports whether the block is recent enough for its import to require
+// strict durability.
+func (p WritePolicy) nearHead(block *types.Block) bool {
+	return time.Since(time.Unix(block.Time().Int64(), 0)) < p.HeadAge
+}
+
+// chainWriter accumulates chain data into database batches and writes them out
+// according to a write policy.
+type chainWriter struct {
+	db     ethdb.Database
+	policy WritePolicy
+	batch  ethdb.Batch
+	writes int // Number of batches written out so far
+
+	deferred []func() error // Direct database writes depending on the current batch
+}
+
+// newChainWriter creates a batching chain data writer on top of db.
+func newChainWriter(db ethdb.Database, policy WritePolicy) *chainWriter {
+	return &chainWriter{
+		db:     db,
+		policy: policy,
+		batch:  db.NewBatch(),
+	}
+}
+
+// Put queues a database insert into the current batch.
+func (w *chainWriter) Put(key []byte, value []byte) error {
+	return w.batch.Put(key, value)
+}
+
+// Defer queues a write that can't be batched, as it reads back data from the
+// database, to run directly on the database once the current batch is out.
+func (w *chainWriter) Defer(write func() error) {
+	w.deferred = append(w.deferred, write)
+}
+
+// Flush writes the current batch out if it grew over the policy's size limit.
+func (w *chainWriter) Flush() error {
+	if w.batch.ValueSize() < w.policy.BatchSize {
+		return nil
+	}
+	return w.Write(false)
+}
+
+// Write writes out the current batch regardless of its size, syncing it to disk
+// if requested or if the policy's sync interval was reached, then runs the
+// deferred writes.
+func (w *chainWriter) Write(sync bool) error {
+	w.writes++
+	if w.policy.SyncInterval > 0 && w.writes%w.policy.SyncInterval == 0 {
+		sync = true
+	}
+	var err error
+	if sync {
+		err = w.batch.WriteSync()
+	} else {
+		err = w.batch.Write()
+	}
+	w.batch = w.db.NewBatch()
+	if err != nil {
+		return err
+	}
+	deferred := w.deferred
+	w.deferred = nil
+	for _, write := range deferred {
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/ethdb"
+)
+
+// syncCountingDatabase is a memory database counting the plain and synced batch
+// writes done through it.
+type syncCountingDatabase struct {
+	*ethdb.MemDatabase
+	writes, syncs int
+}
+
+func (db *syncCountingDatabase) NewBatch() ethdb.Batch {
+	return &syncCountingBatch{Batch: db.MemDatabase.NewBatch(), db: db}
+}
+
+type syncCountingBatch struct {
+	ethdb.Batch
+	db *syncCountingDatabase
+}
+
+func (b *syncCountingBatch) Write() error {
+	b.db.writes++
+	return b.Batch.Write()
+}
+
+func (b *syncCountingBatch) WriteSync() error {
+	b.db.syncs++
+	return b.Batch.WriteSync()
+}
+
+// Tests that the chain writer only writes batches out once they grow large
+// enough, and that it syncs them to disk at the configured interval.
+func TestChainWriterPolicy(t *testing.T) {
+	mem, _ := ethdb.NewMemDatabase()
+	db := &syncCountingDatabase{MemDatabase: mem}
+
+	writer := newChainWriter(db, WritePolicy{BatchSize: 100, SyncInterval: 3})
+	for i := 0; i < 20; i++ {
+		if err := writer.Put([]byte{byte(i)}, make([]byte, 50)); err != nil {
+			t.Fatalf("put %d: failed to queue data: %v", i, err)
+		}
+		if err := writer.Flush(); err != nil {
+			t.Fatalf("put %d: failed to flush batch: %v", i, err)
+		}
+	}
+	// Every second insert fills a batch, every third batch should be synced
+	if db.writes != 7 || db.syncs != 3 {
+		t.Fatalf("batch writes mismatch: have %d plain, %d synced, want %d plain, %d synced", db.writes, db.syncs, 7, 3)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := mem.Get([]byte{byte(i)}); err != nil {
+			t.Errorf("item %d: missing from database: %v", i, err)
+		}
+	}
+	// A forced sync should always reach the disk, regardless of the interval
+	writer.Put([]byte{0xff}, []byte{0x01})
+	if err := writer.Write(true); err != nil {
+		t.Fatalf("failed to write leftovers: %v", err)
+	}
+	if db.syncs != 4 {
+		t.Fatalf("synced writes mismatch: have %d, want %d", db.syncs, 4)
+	}
+}
+
+// Tests that blocks are only considered near the head if they are recent.
+func TestWritePolicyNearHead(t *testing.T) {
+	policy := WritePolicy{HeadAge: time.Minute}
+
+	old := types.NewBlockWithHeader(&types.Header{Time: big.NewInt(time.Now().Add(-time.Hour).Unix())})
+	if policy.nearHead(old) {
+		t.Errorf("hour old block reported near head")
+	}
+	recent := types.NewBlockWithHeader(&types.Header{Time: big.NewInt(time.Now().Unix())})
+	if !policy.nearHead(recent) {
+		t.Errorf("fresh block not reported near head")
+	}
+}
+
+// Tests that deferred writes only run once the batch they depend on is out.
+func TestChainWriterDefer(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	writer := newChainWriter(db, WritePolicy{BatchSize: 100})
+
+	var ran int
+	writer.Put([]byte{0x01}, []byte{0x01})
+	writer.Defer(func() error {
+		ran++
+		if _, err := db.Get([]byte{0x01}); err != nil {
+			t.Errorf("deferred write ran before the batch: %v", err)
+		}
+		return nil
+	})
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("failed to flush batch: %v", err)
+	}
+	if ran != 0 {
+		t.Fatalf("deferred write ran with the batch pending")
+	}
+	if err := writer.Write(false); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	if err := writer.Write(false); err != nil {
+		t.Fatalf("failed to write empty batch: %v", err)
+	}
+	if ran != 1 {
+		t.Fatalf("deferred write runs mismatch: have %d, want 1", ran)
+	}
+}
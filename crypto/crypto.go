@@ -22,10 +22,12 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"hash"
 	"io"
 	"io/ioutil"
 	"math/big"
 	"os"
+	"sync"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/crypto/sha3"
@@ -37,9 +39,18 @@ var (
 	secp256k1_halfN = new(big.Int).Div(secp256k1_N, big.NewInt(2))
 )
 
+// keccakPool reuses Keccak256 states across hash calculations to avoid
+// allocating a new sponge for every hashed item.
+var keccakPool = sync.Pool{
+	New: func() interface{} { return sha3.NewKeccak256() },
+}
+
 // Keccak256 calculates and returns the Keccak256 hash of the input data.
 func Keccak256(data ...[]byte) []byte {
-	d := sha3.NewKeccak256()
+	d := keccakPool.Get().(hash.Hash)
+	defer keccakPool.Put(d)
+
+	d.Reset()
 	for _, b := range data {
 		d.Write(b)
 	}
@@ -49,7 +60,10 @@ func Keccak256(data ...[]byte) []byte {
 // Keccak256Hash calculates and returns the Keccak256 hash of the input data,
 // converting it to an internal Hash data structure.
 func Keccak256Hash(data ...[]byte) (h common.Hash) {
-	d := sha3.NewKeccak256()
+	d := keccakPool.Get().(hash.Hash)
+	defer keccakPool.Put(d)
+
+	d.Reset()
 	for _, b := range data {
 		d.Write(b)
 	}
@@ -75,6 +89,12 @@ func CreateAddress(b common.Address, nonce uint64) common.Address {
 	return common.BytesToAddress(Keccak256(data)[12:])
 }
 
+// CreateAddress2 creates an ethereum address given the address bytes, the salt
+// and the hash of the contract initialisation code, as done by CREATE2.
+func CreateAddress2(b common.Address, salt [32]byte, inithash []byte) common.Address {
+	return common.BytesToAddress(Keccak256([]byte{0xff}, b.Bytes(), salt[:], inithash)[12:])
+}
+
 // ToECDSA creates a private key with the given D value.
 func ToECDSA(prv []byte) *ecdsa.PrivateKey {
 	if len(prv) == 0 {
@@ -14,7 +14,7 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
-// +build !nacl,!js,!nocgo
+// +build !nacl,!js,!nocgo,cgo
 
 package crypto
 
@@ -27,6 +27,10 @@ import (
 	"github.com/expanse-org/go-expanse/crypto/secp256k1"
 )
 
+// SignatureBackend names the implementation used for signing and public key
+// recovery, selected at build time.
+const SignatureBackend = "libsecp256k1"
+
 func Ecrecover(hash, sig []byte) ([]byte, error) {
 	return secp256k1.RecoverPubkey(hash, sig)
 }
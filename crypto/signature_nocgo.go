@@ -14,7 +14,7 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
-// +build nacl js nocgo
+// +build nacl js nocgo !cgo
 
 package crypto
 
@@ -26,6 +26,10 @@ import (
 	"github.com/btcsuite/btcd/btcec"
 )
 
+// SignatureBackend names the implementation used for signing and public key
+// recovery, selected at build time.
+const SignatureBackend = "btcec"
+
 func Ecrecover(hash, sig []byte) ([]byte, error) {
 	pub, err := SigToPub(hash, sig)
 	if err != nil {
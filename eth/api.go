@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -40,6 +41,7 @@ import (
 	"github.com/expanse-org/go-expanse/miner"
 	"github.com/expanse-org/go-expanse/params"
 	"github.com/expanse-org/go-expanse/rlp"
+	"github.com/expanse-org/go-expanse/rpc"
 )
 
 const defaultTraceTimeout = 5 * time.Second
@@ -56,12 +58,13 @@ func NewPublicEthereumAPI(e *Ethereum) *PublicEthereumAPI {
 }
 
 // Etherbase is the address that mining rewards will be send to
-func (s *PublicEthereumAPI) Etherbase() (common.Address, error) {
-	return s.e.Etherbase()
+func (s *PublicEthereumAPI) Etherbase() (common.ChecksumAddress, error) {
+	etherbase, err := s.e.Etherbase()
+	return common.ChecksumAddress(etherbase), err
 }
 
 // Coinbase is the address that mining rewards will be send to (alias for Etherbase)
-func (s *PublicEthereumAPI) Coinbase() (common.Address, error) {
+func (s *PublicEthereumAPI) Coinbase() (common.ChecksumAddress, error) {
 	return s.Etherbase()
 }
 
@@ -70,6 +73,44 @@ func (s *PublicEthereumAPI) Hashrate() hexutil.Uint64 {
 	return hexutil.Uint64(s.e.Miner().HashRate())
 }
 
+// ChainStats returns aggregate statistics of the canonical blocks in the given
+// range: average block time, difficulty trend, gas usage, transaction count and
+// uncle rate.
+func (s *PublicEthereumAPI) ChainStats(from, to rpc.BlockNumber) (*ChainStats, error) {
+	first, last, err := resolveBlockRange(s.e.BlockChain(), from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.e.chainStats.stats(first, last)
+}
+
+// StateAvailability returns the ranges of blocks whose historical state this
+// node can serve, so tooling can route state queries to capable nodes.
+func (s *PublicEthereumAPI) StateAvailability() *StateAvailability {
+	return s.e.states.availability()
+}
+
+// Capabilities reports the protocols, sync mode, indexes, tracing and state
+// retention of this node, so load balancers can route requests to nodes able to
+// serve them. It replaces probing eth_protocolVersion and friends.
+func (s *PublicEthereumAPI) Capabilities() *Capabilities {
+	return s.e.capabilities()
+}
+
+// TotalSupply returns the total ether supply after the given block: the genesis
+// allocation plus all the block and uncle rewards issued since.
+func (s *PublicEthereumAPI) TotalSupply(number rpc.BlockNumber) (*hexutil.Big, error) {
+	block, err := ethapi.ResolveBlockNumber(number, s.e.BlockChain().CurrentBlock().NumberU64())
+	if err != nil {
+		return nil, err
+	}
+	supply, err := s.e.chainStats.totalSupply(block)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(supply), nil
+}
+
 // PublicMinerAPI provides an API to control the miner.
 // It offers only methods that operate on data that pose no security risk when it is publicly accessible.
 type PublicMinerAPI struct {
@@ -79,7 +120,7 @@ type PublicMinerAPI struct {
 
 // NewPublicMinerAPI create a new PublicMinerAPI instance.
 func NewPublicMinerAPI(e *Ethereum) *PublicMinerAPI {
-	agent := miner.NewRemoteAgent(e.Pow())
+	agent := miner.NewRemoteAgent(e.BlockChain(), e.Engine())
 	e.Miner().Register(agent)
 
 	return &PublicMinerAPI{e, agent}
@@ -121,6 +162,64 @@ func (s *PublicMinerAPI) SubmitHashrate(hashrate hexutil.Uint64, id common.Hash)
 	return true
 }
 
+const (
+	defaultMinerVersionBlocks = 1000  // Number of recent blocks to scan for client versions by default
+	maxMinerVersionBlocks     = 50000 // Maximum number of recent blocks to scan for client versions
+)
+
+// MinerVersions is the distribution of the client versions miners of a range of
+// blocks reported in the extra-data of their blocks.
+type MinerVersions struct {
+	From     hexutil.Uint64            `json:"from"`
+	To       hexutil.Uint64            `json:"to"`
+	Versions map[string]hexutil.Uint64 `json:"versions"` // Blocks per client name and version
+	Tags     map[string]hexutil.Uint64 `json:"tags"`     // Blocks per operator tag
+	Unknown  hexutil.Uint64            `json:"unknown"`  // Blocks without client information
+}
+
+// MinerVersions scans the given number of most recent blocks, 1000 by default,
+// and reports the client versions their miners ran, to track the rollout of new
+// releases among the miners of the network.
+func (s *PublicMinerAPI) MinerVersions(blocks *hexutil.Uint64) (*MinerVersions, error) {
+	count := uint64(defaultMinerVersionBlocks)
+	if blocks != nil {
+		count = uint64(*blocks)
+	}
+	if count == 0 || count > maxMinerVersionBlocks {
+		return nil, fmt.Errorf("block count must be between 1 and %d", maxMinerVersionBlocks)
+	}
+	return minerVersions(s.e.BlockChain(), count), nil
+}
+
+// minerVersions gathers the client versions of the miners of the given number
+// of most recent blocks of the chain.
+func minerVersions(chain *core.BlockChain, count uint64) *MinerVersions {
+	head := chain.CurrentHeader()
+	stats := &MinerVersions{
+		To:       hexutil.Uint64(head.Number.Uint64()),
+		Versions: make(map[string]hexutil.Uint64),
+		Tags:     make(map[string]hexutil.Uint64),
+	}
+	for header := head; header != nil && count > 0; count-- {
+		number := header.Number.Uint64()
+		stats.From = hexutil.Uint64(number)
+
+		if info, err := miner.ParseExtra(header.Extra); err != nil {
+			stats.Unknown++
+		} else {
+			stats.Versions[info.Name+"/"+info.VersionString()]++
+			if info.Tag != "" {
+				stats.Tags[info.Tag]++
+			}
+		}
+		if number == 0 {
+			break
+		}
+		header = chain.GetHeader(header.ParentHash, number-1)
+	}
+	return stats
+}
+
 // PrivateMinerAPI provides private RPC methods to control the miner.
 // These methods can be abused by external users and must be considered insecure for use by untrusted users.
 type PrivateMinerAPI struct {
@@ -164,10 +263,85 @@ func (s *PrivateMinerAPI) SetGasPrice(gasPrice hexutil.Big) bool {
 	return true
 }
 
-// SetEtherbase sets the etherbase of the miner
-func (s *PrivateMinerAPI) SetEtherbase(etherbase common.Address) bool {
-	s.e.SetEtherbase(etherbase)
-	return true
+// EtherbaseArgs is the etherbase configuration accepted by miner_setEtherbase:
+// either a single address, a list of addresses to rotate through round-robin,
+// or a list of {"address", "weight"} objects to rotate through by weight.
+type EtherbaseArgs struct {
+	Addresses []common.Address
+	Weights   []uint64 // nil for round-robin rotation
+}
+
+// WeightedEtherbase is an etherbase with its share of the rotation.
+type WeightedEtherbase struct {
+	Address common.ChecksumAddress `json:"address"`
+	Weight  hexutil.Uint64         `json:"weight"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (args *EtherbaseArgs) UnmarshalJSON(input []byte) error {
+	var addr common.ChecksumAddress
+	if err := json.Unmarshal(input, &addr); err == nil {
+		args.Addresses, args.Weights = []common.Address{common.Address(addr)}, nil
+		return nil
+	}
+	var addrs []common.ChecksumAddress
+	if err := json.Unmarshal(input, &addrs); err == nil {
+		args.Addresses, args.Weights = make([]common.Address, len(addrs)), nil
+		for i, addr := range addrs {
+			args.Addresses[i] = common.Address(addr)
+		}
+		return nil
+	}
+	var weighted []struct {
+		Address common.ChecksumAddress `json:"address"`
+		Weight  *hexutil.Uint64        `json:"weight"`
+	}
+	if err := json.Unmarshal(input, &weighted); err != nil {
+		return errors.New("etherbase must be an address, a list of addresses or a list of weighted addresses")
+	}
+	args.Addresses, args.Weights = make([]common.Address, len(weighted)), make([]uint64, len(weighted))
+	for i, etherbase := range weighted {
+		if etherbase.Weight == nil {
+			return fmt.Errorf("etherbase %x: missing weight", etherbase.Address)
+		}
+		args.Addresses[i], args.Weights[i] = common.Address(etherbase.Address), uint64(*etherbase.Weight)
+	}
+	return nil
+}
+
+// SetEtherbase sets the etherbase of the miner, or a list of etherbases the
+// rewards of the mined blocks are rotated through.
+func (s *PrivateMinerAPI) SetEtherbase(args EtherbaseArgs) (bool, error) {
+	if len(args.Addresses) == 1 {
+		s.e.SetEtherbase(args.Addresses[0])
+		return true, nil
+	}
+	etherbases, err := miner.NewEtherbases(args.Addresses, args.Weights)
+	if err != nil {
+		return false, err
+	}
+	s.e.SetEtherbases(etherbases)
+	return true, nil
+}
+
+// Etherbases returns the etherbases the miner rotates the rewards of its blocks
+// through, along with their weights.
+func (s *PrivateMinerAPI) Etherbases() []WeightedEtherbase {
+	etherbases := s.e.Miner().Etherbases()
+	if etherbases == nil {
+		eb, err := s.e.Etherbase()
+		if err != nil {
+			return []WeightedEtherbase{}
+		}
+		return []WeightedEtherbase{{Address: common.ChecksumAddress(eb), Weight: 1}}
+	}
+	addrs, weights := etherbases.Addresses(), etherbases.Weights()
+
+	result := make([]WeightedEtherbase, len(addrs))
+	for i := range addrs {
+		result[i] = WeightedEtherbase{Address: common.ChecksumAddress(addrs[i]), Weight: hexutil.Uint64(weights[i])}
+	}
+	return result
 }
 
 // GetHashrate returns the current hashrate of the miner.
@@ -175,6 +349,45 @@ func (s *PrivateMinerAPI) GetHashrate() uint64 {
 	return uint64(s.e.miner.HashRate())
 }
 
+// SetStrictParent makes the miner only mine on blocks this node fully validated
+// itself within the given number of seconds. Zero allows mining on any block.
+func (s *PrivateMinerAPI) SetStrictParent(seconds uint64) bool {
+	s.e.Miner().SetStrictParent(time.Duration(seconds) * time.Second)
+	return true
+}
+
+// BanBlock makes the miner refuse to extend chains containing the given block.
+func (s *PrivateMinerAPI) BanBlock(hash common.Hash) bool {
+	s.e.Miner().BanBlocks(hash)
+	return true
+}
+
+// UnbanBlock lifts the ban of the given block.
+func (s *PrivateMinerAPI) UnbanBlock(hash common.Hash) bool {
+	s.e.Miner().UnbanBlocks(hash)
+	return true
+}
+
+// SetUnclePolicy changes the rules by which the miner picks the uncles of the
+// blocks it mines: the maximum number of uncles per block, the maximum number of
+// blocks they may be behind and whether uncles of the own etherbases go first.
+func (s *PrivateMinerAPI) SetUnclePolicy(policy miner.UnclePolicy) (bool, error) {
+	if err := s.e.Miner().SetUnclePolicy(policy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnclePolicy returns the rules by which the miner picks uncles.
+func (s *PrivateMinerAPI) UnclePolicy() miner.UnclePolicy {
+	return s.e.Miner().UnclePolicy()
+}
+
+// BannedBlocks returns the hashes of the blocks the miner refuses to extend.
+func (s *PrivateMinerAPI) BannedBlocks() []common.Hash {
+	return s.e.Miner().BannedBlocks()
+}
+
 // PrivateAdminAPI is the collection of Etheruem full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -187,8 +400,21 @@ func NewPrivateAdminAPI(eth *Ethereum) *PrivateAdminAPI {
 	return &PrivateAdminAPI{eth: eth}
 }
 
-// ExportChain exports the current blockchain into a local file.
-func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
+// ExportChain exports the canonical chain into a local file, gzip compressed if
+// the file name ends in ".gz". The first and last blocks default to the genesis
+// and the current head.
+func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64) (bool, error) {
+	from, to := uint64(0), api.eth.BlockChain().CurrentBlock().NumberU64()
+	if first != nil {
+		from = *first
+	}
+	if last != nil {
+		to = *last
+	}
+	// Validate the range before touching the file, so existing exports survive
+	if err := checkExportRange(api.eth.BlockChain(), from, to); err != nil {
+		return false, err
+	}
 	// Make sure we can create the file to export into
 	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
 	if err != nil {
@@ -198,28 +424,38 @@ func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 
 	var writer io.Writer = out
 	if strings.HasSuffix(file, ".gz") {
-		writer = gzip.NewWriter(writer)
-		defer writer.(*gzip.Writer).Close()
+		gz := gzip.NewWriter(writer)
+		defer gz.Close()
+		writer = gz
 	}
-
 	// Export the blockchain
-	if err := api.eth.BlockChain().Export(writer); err != nil {
+	if err := api.eth.ExportChain(writer, from, to); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
-	for _, b := range bs {
-		if !chain.HasBlock(b.Hash()) {
-			return false
-		}
+// EncodeFreezeUpdate returns the payload of a transaction of the governance
+// account to the freeze registry, freezing and unfreezing the given addresses.
+func (api *PrivateAdminAPI) EncodeFreezeUpdate(freeze, unfreeze []common.Address) (hexutil.Bytes, error) {
+	enc, err := rlp.EncodeToBytes(&core.FreezeUpdate{Freeze: freeze, Unfreeze: unfreeze})
+	if err != nil {
+		return nil, err
 	}
+	return enc, nil
+}
 
-	return true
+// IsFrozen returns whether the given address is frozen by the network governance
+// in the state of the current head block.
+func (api *PrivateAdminAPI) IsFrozen(addr common.ChecksumAddress) (bool, error) {
+	statedb, err := api.eth.BlockChain().State()
+	if err != nil {
+		return false, err
+	}
+	return core.IsFrozen(api.eth.chainConfig, statedb, common.Address(addr)), nil
 }
 
-// ImportChain imports a blockchain from a local file.
+// ImportChain imports a blockchain from a local file, which may be gzipped.
 func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	// Make sure the can access the file to import
 	in, err := os.Open(file)
@@ -228,42 +464,8 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	}
 	defer in.Close()
 
-	var reader io.Reader = in
-	if strings.HasSuffix(file, ".gz") {
-		if reader, err = gzip.NewReader(reader); err != nil {
-			return false, err
-		}
-	}
-
-	// Run actual the import in pre-configured batches
-	stream := rlp.NewStream(reader, 0)
-
-	blocks, index := make([]*types.Block, 0, 2500), 0
-	for batch := 0; ; batch++ {
-		// Load a batch of blocks from the input file
-		for len(blocks) < cap(blocks) {
-			block := new(types.Block)
-			if err := stream.Decode(block); err == io.EOF {
-				break
-			} else if err != nil {
-				return false, fmt.Errorf("block %d: failed to parse: %v", index, err)
-			}
-			blocks = append(blocks, block)
-			index++
-		}
-		if len(blocks) == 0 {
-			break
-		}
-
-		if hasAllBlocks(api.eth.BlockChain(), blocks) {
-			blocks = blocks[:0]
-			continue
-		}
-		// Import the batch and reset the buffer
-		if _, err := api.eth.BlockChain().InsertChain(blocks); err != nil {
-			return false, fmt.Errorf("batch %d: failed to insert: %v", batch, err)
-		}
-		blocks = blocks[:0]
+	if err := api.eth.ImportChain(in); err != nil {
+		return false, err
 	}
 	return true, nil
 }
@@ -293,6 +495,20 @@ func (api *PublicDebugAPI) DumpBlock(number uint64) (state.Dump, error) {
 	return stateDb.RawDump(), nil
 }
 
+// CodeByHash retrieves contract code by its hash. Code is stored only once no
+// matter how many accounts it is deployed at.
+func (api *PublicDebugAPI) CodeByHash(hash common.Hash) (hexutil.Bytes, error) {
+	stateDb, err := api.eth.BlockChain().State()
+	if err != nil {
+		return nil, err
+	}
+	code, err := stateDb.CodeByHash(hash)
+	if err != nil {
+		return nil, fmt.Errorf("code %x not found", hash)
+	}
+	return code, nil
+}
+
 // PrivateDebugAPI is the collection of Etheruem full node APIs exposed over
 // the private debugging endpoint.
 type PrivateDebugAPI struct {
@@ -307,10 +523,13 @@ func NewPrivateDebugAPI(config *params.ChainConfig, eth *Ethereum) *PrivateDebug
 }
 
 // BlockTraceResult is the returned value when replaying a block to check for
-// consensus results and full VM trace logs for all included transactions.
+// consensus results and full VM trace logs for all included transactions. If a
+// JavaScript tracer was requested, its results are returned for every
+// transaction instead of the logs.
 type BlockTraceResult struct {
 	Validated  bool                  `json:"validated"`
 	StructLogs []ethapi.StructLogRes `json:"structLogs"`
+	Traces     []interface{}         `json:"traces,omitempty"`
 	Error      string                `json:"error"`
 }
 
@@ -323,65 +542,77 @@ type TraceArgs struct {
 
 // TraceBlock processes the given block's RLP but does not import the block in to
 // the chain.
-func (api *PrivateDebugAPI) TraceBlock(blockRlp []byte, config *vm.LogConfig) BlockTraceResult {
+func (api *PrivateDebugAPI) TraceBlock(ctx context.Context, blockRlp []byte, config *TraceArgs) BlockTraceResult {
 	var block types.Block
 	err := rlp.Decode(bytes.NewReader(blockRlp), &block)
 	if err != nil {
 		return BlockTraceResult{Error: fmt.Sprintf("could not decode block: %v", err)}
 	}
-
-	validated, logs, err := api.traceBlock(&block, config)
-	return BlockTraceResult{
-		Validated:  validated,
-		StructLogs: ethapi.FormatLogs(logs),
-		Error:      formatError(err),
-	}
+	return api.traceBlockResult(ctx, &block, config)
 }
 
 // TraceBlockFromFile loads the block's RLP from the given file name and attempts to
 // process it but does not import the block in to the chain.
-func (api *PrivateDebugAPI) TraceBlockFromFile(file string, config *vm.LogConfig) BlockTraceResult {
+func (api *PrivateDebugAPI) TraceBlockFromFile(ctx context.Context, file string, config *TraceArgs) BlockTraceResult {
 	blockRlp, err := ioutil.ReadFile(file)
 	if err != nil {
 		return BlockTraceResult{Error: fmt.Sprintf("could not read file: %v", err)}
 	}
-	return api.TraceBlock(blockRlp, config)
+	return api.TraceBlock(ctx, blockRlp, config)
 }
 
 // TraceBlockByNumber processes the block by canonical block number.
-func (api *PrivateDebugAPI) TraceBlockByNumber(number uint64, config *vm.LogConfig) BlockTraceResult {
+func (api *PrivateDebugAPI) TraceBlockByNumber(ctx context.Context, number uint64, config *TraceArgs) BlockTraceResult {
 	// Fetch the block that we aim to reprocess
 	block := api.eth.BlockChain().GetBlockByNumber(number)
 	if block == nil {
 		return BlockTraceResult{Error: fmt.Sprintf("block #%d not found", number)}
 	}
-
-	validated, logs, err := api.traceBlock(block, config)
-	return BlockTraceResult{
-		Validated:  validated,
-		StructLogs: ethapi.FormatLogs(logs),
-		Error:      formatError(err),
-	}
+	return api.traceBlockResult(ctx, block, config)
 }
 
 // TraceBlockByHash processes the block by hash.
-func (api *PrivateDebugAPI) TraceBlockByHash(hash common.Hash, config *vm.LogConfig) BlockTraceResult {
+func (api *PrivateDebugAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceArgs) BlockTraceResult {
 	// Fetch the block that we aim to reprocess
 	block := api.eth.BlockChain().GetBlockByHash(hash)
 	if block == nil {
 		return BlockTraceResult{Error: fmt.Sprintf("block #%x not found", hash)}
 	}
+	return api.traceBlockResult(ctx, block, config)
+}
 
-	validated, logs, err := api.traceBlock(block, config)
+// traceBlockResult reprocesses the given block, tracing it with the struct
+// logger, or every transaction separately if a JavaScript tracer is requested.
+func (api *PrivateDebugAPI) traceBlockResult(ctx context.Context, block *types.Block, config *TraceArgs) BlockTraceResult {
+	if config == nil || config.Tracer == nil {
+		var logConfig *vm.LogConfig
+		if config != nil {
+			logConfig = config.LogConfig
+		}
+		structLogger := vm.NewStructLogger(logConfig)
+
+		validated, err := api.traceBlock(block, structLogger)
+		return BlockTraceResult{
+			Validated:  validated,
+			StructLogs: ethapi.FormatLogs(structLogger.StructLogs()),
+			Error:      formatError(err),
+		}
+	}
+	validated, err := api.traceBlock(block, nil)
+	if err != nil {
+		return BlockTraceResult{Validated: validated, Error: formatError(err)}
+	}
+	traces, err := api.traceTransactions(ctx, block, 0, len(block.Transactions()), config)
 	return BlockTraceResult{
-		Validated:  validated,
-		StructLogs: ethapi.FormatLogs(logs),
-		Error:      formatError(err),
+		Validated: validated,
+		Traces:    traces,
+		Error:     formatError(err),
 	}
 }
 
-// traceBlock processes the given block but does not save the state.
-func (api *PrivateDebugAPI) traceBlock(block *types.Block, logConfig *vm.LogConfig) (bool, []vm.StructLog, error) {
+// traceBlock processes the given block but does not save the state. The block
+// is traced with the given tracer, if any.
+func (api *PrivateDebugAPI) traceBlock(block *types.Block, tracer vm.Tracer) (bool, error) {
 	// Validate and reprocess the block
 	var (
 		blockchain = api.eth.BlockChain()
@@ -389,29 +620,27 @@ func (api *PrivateDebugAPI) traceBlock(block *types.Block, logConfig *vm.LogConf
 		processor  = blockchain.Processor()
 	)
 
-	structLogger := vm.NewStructLogger(logConfig)
-
 	config := vm.Config{
-		Debug:  true,
-		Tracer: structLogger,
+		Debug:  tracer != nil,
+		Tracer: tracer,
 	}
 
-	if err := core.ValidateHeader(api.config, blockchain.AuxValidator(), block.Header(), blockchain.GetHeader(block.ParentHash(), block.NumberU64()-1), true, false); err != nil {
-		return false, structLogger.StructLogs(), err
+	if err := core.ValidateHeader(blockchain, blockchain.Engine(), block.Header(), blockchain.GetHeader(block.ParentHash(), block.NumberU64()-1), true, false); err != nil {
+		return false, err
 	}
 	statedb, err := blockchain.StateAt(blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1).Root())
 	if err != nil {
-		return false, structLogger.StructLogs(), err
+		return false, err
 	}
 
 	receipts, _, usedGas, err := processor.Process(block, statedb, config)
 	if err != nil {
-		return false, structLogger.StructLogs(), err
+		return false, err
 	}
 	if err := validator.ValidateState(block, blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1), statedb, receipts, usedGas); err != nil {
-		return false, structLogger.StructLogs(), err
+		return false, err
 	}
-	return true, structLogger.StructLogs(), nil
+	return true, nil
 }
 
 // callmsg is the message type used for call transitions.
@@ -449,37 +678,28 @@ func (t *timeoutError) Error() string {
 	return "Execution time exceeded"
 }
 
+// traceCacheKey returns the response cache key of a transaction trace, covering
+// every option changing its result.
+func traceCacheKey(txHash common.Hash, config *TraceArgs) string {
+	key := txHash.Hex()
+	if config != nil {
+		options, _ := json.Marshal(struct {
+			*vm.LogConfig
+			Tracer *string
+		}{config.LogConfig, config.Tracer})
+		key += string(options)
+	}
+	return key
+}
+
 // TraceTransaction returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.Hash, config *TraceArgs) (interface{}, error) {
-	var tracer vm.Tracer
-	if config != nil && config.Tracer != nil {
-		timeout := defaultTraceTimeout
-		if config.Timeout != nil {
-			var err error
-			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
-				return nil, err
-			}
-		}
-
-		var err error
-		if tracer, err = ethapi.NewJavascriptTracer(*config.Tracer); err != nil {
-			return nil, err
-		}
-
-		// Handle timeouts and RPC cancellations
-		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
-		go func() {
-			<-deadlineCtx.Done()
-			tracer.(*ethapi.JavascriptTracer).Stop(&timeoutError{})
-		}()
-		defer cancel()
-	} else if config == nil {
-		tracer = vm.NewStructLogger(nil)
-	} else {
-		tracer = vm.NewStructLogger(config.LogConfig)
+	// Serve the trace from the cache if it was already computed
+	key := traceCacheKey(txHash, config)
+	if result, ok := api.eth.rpcCache.Get(ethapi.CacheTraces, key); ok {
+		return result, nil
 	}
-
 	// Retrieve the tx from the chain and the containing block
 	tx, blockHash, _, txIndex := core.GetTransaction(api.eth.ChainDb(), txHash)
 	if tx == nil {
@@ -489,6 +709,21 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 	if block == nil {
 		return nil, fmt.Errorf("block %x not found", blockHash)
 	}
+	traces, err := api.traceTransactions(ctx, block, int(txIndex), int(txIndex)+1, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(traces) == 0 {
+		return nil, errors.New("database inconsistency")
+	}
+	api.eth.rpcCache.Put(ethapi.CacheTraces, key, block.NumberU64(), block.Hash(), traces[0])
+	return traces[0], nil
+}
+
+// traceTransactions replays the transactions of the given block on top of the
+// state of its parent, and traces the ones with indexes in the range [from, to),
+// each with a new tracer created from the given config.
+func (api *PrivateDebugAPI) traceTransactions(ctx context.Context, block *types.Block, from, to int, config *TraceArgs) ([]interface{}, error) {
 	// Create the state database to mutate and eventually trace
 	parent := api.eth.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
 	if parent == nil {
@@ -499,45 +734,93 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 		return nil, err
 	}
 
+	var traces []interface{}
 	signer := types.MakeSigner(api.config, block.Number())
-	// Mutate the state and trace the selected transaction
+	// Mutate the state and trace the selected transactions
 	for idx, tx := range block.Transactions() {
+		if idx >= to {
+			break
+		}
 		// Assemble the transaction call message
 		msg, err := tx.AsMessage(signer)
 		if err != nil {
 			return nil, fmt.Errorf("sender retrieval failed: %v", err)
 		}
-		context := core.NewEVMContext(msg, block.Header(), api.eth.BlockChain())
+		context := core.NewEVMContext(msg, block.Header(), api.eth.BlockChain(), nil)
 
-		// Mutate the state if we haven't reached the tracing transaction yet
-		if uint64(idx) < txIndex {
+		// Mutate the state if we haven't reached the tracing transactions yet
+		if idx < from {
 			vmenv := vm.NewEVM(context, stateDb, api.config, vm.Config{})
 			_, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
 			if err != nil {
 				return nil, fmt.Errorf("mutation failed: %v", err)
 			}
-			stateDb.DeleteSuicides()
+			// Finalise the same way block processing does, deleting touched empty
+			// accounts once the EIP158 fork is active
+			stateDb.Finalise(api.config.IsEIP158(block.Number()))
 			continue
 		}
 
+		tracer, cancel, err := newTracer(ctx, config)
+		if err != nil {
+			return nil, err
+		}
 		vmenv := vm.NewEVM(context, stateDb, api.config, vm.Config{Debug: true, Tracer: tracer})
 		ret, gas, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
 		if err != nil {
+			cancel()
 			return nil, fmt.Errorf("tracing failed: %v", err)
 		}
+		stateDb.Finalise(api.config.IsEIP158(block.Number()))
 
+		var result interface{}
 		switch tracer := tracer.(type) {
 		case *vm.StructLogger:
-			return &ethapi.ExecutionResult{
+			result = &ethapi.ExecutionResult{
 				Gas:         gas,
 				ReturnValue: fmt.Sprintf("%x", ret),
 				StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
-			}, nil
+			}
 		case *ethapi.JavascriptTracer:
-			return tracer.GetResult()
+			result, err = tracer.GetResult()
+		}
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, result)
+	}
+	return traces, nil
+}
+
+// newTracer creates the tracer requested by the given config, the struct logger
+// by default. JavaScript tracers are stopped once the timeout of the config
+// elapses or the returned function is called, whichever happens first.
+func newTracer(ctx context.Context, config *TraceArgs) (vm.Tracer, context.CancelFunc, error) {
+	if config == nil {
+		return vm.NewStructLogger(nil), func() {}, nil
+	}
+	if config.Tracer == nil {
+		return vm.NewStructLogger(config.LogConfig), func() {}, nil
+	}
+	timeout := defaultTraceTimeout
+	if config.Timeout != nil {
+		var err error
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, nil, err
 		}
 	}
-	return nil, errors.New("database inconsistency")
+	tracer, err := ethapi.NewJavascriptTracer(*config.Tracer)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Handle timeouts and RPC cancellations
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	go func() {
+		<-deadlineCtx.Done()
+		tracer.Stop(&timeoutError{})
+	}()
+	return tracer, cancel, nil
 }
 
 // Preimage is a debug API function that returns the preimage for a sha3 hash, if known.
@@ -551,3 +834,82 @@ func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hex
 func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]core.BadBlockArgs, error) {
 	return api.eth.BlockChain().BadBlocks()
 }
+
+// BanBlockHash marks the block with the given hash as invalid, so that the node
+// never imports or mines on it or its descendants. If the block is part of the
+// canonical chain, the chain is rewound to its parent.
+func (api *PrivateDebugAPI) BanBlockHash(hash common.Hash) (bool, error) {
+	if err := api.eth.BlockChain().BanBlock(hash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnbanBlockHash lifts the ban of the block with the given hash.
+func (api *PrivateDebugAPI) UnbanBlockHash(hash common.Hash) (bool, error) {
+	if err := api.eth.BlockChain().UnbanBlock(hash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BannedBlockHashes returns the hashes of the blocks banned by the operator.
+func (api *PrivateDebugAPI) BannedBlockHashes() []common.Hash {
+	return api.eth.BlockChain().BannedBlocks()
+}
+
+// ShadowForkStats returns how the replay of the canonical transactions on the
+// shadow fork diverged from their live execution.
+func (api *PrivateDebugAPI) ShadowForkStats() (*ShadowStats, error) {
+	if api.eth.shadow == nil {
+		return nil, errShadowDisabled
+	}
+	stats := api.eth.shadow.Stats()
+	return &stats, nil
+}
+
+// RichList returns the richest accounts and the number of accounts by balance
+// bucket, as of the last indexed section of the chain.
+func (api *PrivateDebugAPI) RichList() (*RichList, error) {
+	if api.eth.richList == nil {
+		return nil, errRichListDisabled
+	}
+	return api.eth.richList.ranking()
+}
+
+// GetContractCreator returns the transaction and block that created a contract,
+// or nil if its creation wasn't indexed yet.
+func (api *PrivateDebugAPI) GetContractCreator(address common.ChecksumAddress) (*ContractCreation, error) {
+	if api.eth.creators == nil {
+		return nil, errCreatorIndexDisabled
+	}
+	return api.eth.creators.creation(common.Address(address)), nil
+}
+
+// GetInternalTransactions returns the value transfers made by contracts from or
+// to the given address within a range of canonical blocks.
+func (api *PrivateDebugAPI) GetInternalTransactions(address common.ChecksumAddress, from, to rpc.BlockNumber) ([]*InternalTransfer, error) {
+	if api.eth.transfers == nil {
+		return nil, errTransfersDisabled
+	}
+	first, last, err := resolveBlockRange(api.eth.BlockChain(), from, to)
+	if err != nil {
+		return nil, err
+	}
+	return api.eth.transfers.transfers(common.Address(address), first, last)
+}
+
+// resolveBlockRange resolves the bounds of a block range against the current head
+// of the chain.
+func resolveBlockRange(chain *core.BlockChain, from, to rpc.BlockNumber) (uint64, uint64, error) {
+	head := chain.CurrentBlock().NumberU64()
+	first, err := ethapi.ResolveBlockNumber(from, head)
+	if err != nil {
+		return 0, 0, err
+	}
+	last, err := ethapi.ResolveBlockNumber(to, head)
+	if err != nil {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
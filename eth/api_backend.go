@@ -23,6 +23,7 @@ import (
 	"github.com/expanse-org/go-expanse/accounts"
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/math"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core"
 	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
@@ -39,7 +40,7 @@ import (
 // EthApiBackend implements ethapi.Backend for full nodes
 type EthApiBackend struct {
 	eth *Ethereum
-	gpo *gasprice.GasPriceOracle
+	gpo *gasprice.Oracle
 }
 
 func (b *EthApiBackend) ChainConfig() *params.ChainConfig {
@@ -62,10 +63,19 @@ func (b *EthApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNum
 		return block.Header(), nil
 	}
 	// Otherwise resolve and return the block
+	head := b.eth.blockchain.CurrentBlock()
 	if blockNr == rpc.LatestBlockNumber {
-		return b.eth.blockchain.CurrentBlock().Header(), nil
+		return head.Header(), nil
 	}
-	return b.eth.blockchain.GetHeaderByNumber(uint64(blockNr)), nil
+	number, err := ethapi.ResolveBlockNumber(blockNr, head.NumberU64())
+	if err != nil {
+		return nil, err
+	}
+	return b.eth.blockchain.GetHeaderByNumber(number), nil
+}
+
+func (b *EthApiBackend) HeaderByHash(ctx context.Context, blockHash common.Hash) (*types.Header, error) {
+	return b.eth.blockchain.GetHeaderByHash(blockHash), nil
 }
 
 func (b *EthApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
@@ -75,10 +85,15 @@ func (b *EthApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumb
 		return block, nil
 	}
 	// Otherwise resolve and return the block
+	head := b.eth.blockchain.CurrentBlock()
 	if blockNr == rpc.LatestBlockNumber {
-		return b.eth.blockchain.CurrentBlock(), nil
+		return head, nil
+	}
+	number, err := ethapi.ResolveBlockNumber(blockNr, head.NumberU64())
+	if err != nil {
+		return nil, err
 	}
-	return b.eth.blockchain.GetBlockByNumber(uint64(blockNr)), nil
+	return b.eth.blockchain.GetBlockByNumber(number), nil
 }
 
 func (b *EthApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (ethapi.State, *types.Header, error) {
@@ -96,12 +111,28 @@ func (b *EthApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 	return EthApiState{stateDb}, header, err
 }
 
+func (b *EthApiBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (ethapi.State, *types.Header, error) {
+	if blockNr, ok := blockNrOrHash.Number(); ok {
+		return b.StateAndHeaderByNumber(ctx, blockNr)
+	}
+	hash, _ := blockNrOrHash.Hash()
+	header, err := b.HeaderByHash(ctx, hash)
+	if header == nil || err != nil {
+		return nil, nil, err
+	}
+	if blockNrOrHash.RequireCanonical && core.GetCanonicalHash(b.eth.chainDb, header.Number.Uint64()) != hash {
+		return nil, nil, ethapi.ErrNotCanonical
+	}
+	stateDb, err := b.eth.BlockChain().StateAt(header.Root)
+	return EthApiState{stateDb}, header, err
+}
+
 func (b *EthApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
 	return b.eth.blockchain.GetBlockByHash(blockHash), nil
 }
 
 func (b *EthApiBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
-	return core.GetBlockReceipts(b.eth.chainDb, blockHash, core.GetBlockNumber(b.eth.chainDb, blockHash)), nil
+	return b.eth.blockchain.GetReceiptsByHash(blockHash), nil
 }
 
 func (b *EthApiBackend) GetTd(blockHash common.Hash) *big.Int {
@@ -114,7 +145,7 @@ func (b *EthApiBackend) GetEVM(ctx context.Context, msg core.Message, state etha
 	from.SetBalance(math.MaxBig256)
 	vmError := func() error { return nil }
 
-	context := core.NewEVMContext(msg, header, b.eth.BlockChain())
+	context := core.NewEVMContext(msg, header, b.eth.BlockChain(), nil)
 	return vm.NewEVM(context, statedb, b.eth.chainConfig, vmCfg), vmError, nil
 }
 
@@ -177,6 +208,14 @@ func (b *EthApiBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.eth.TxPool().Content()
 }
 
+func (b *EthApiBackend) Engine() consensus.Engine {
+	return b.eth.Engine()
+}
+
+func (b *EthApiBackend) TxPoolMinGasPrice() *big.Int {
+	return b.eth.TxPool().MinGasPrice()
+}
+
 func (b *EthApiBackend) Downloader() *downloader.Downloader {
 	return b.eth.Downloader()
 }
@@ -186,13 +225,33 @@ func (b *EthApiBackend) ProtocolVersion() int {
 }
 
 func (b *EthApiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
-	return b.gpo.SuggestPrice(), nil
+	return b.gpo.SuggestPrice(ctx)
+}
+
+func (b *EthApiBackend) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, percentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	return b.gpo.FeeHistory(ctx, blocks, lastBlock, percentiles)
+}
+
+func (b *EthApiBackend) RPCGasCap() *big.Int {
+	return b.eth.rpcGasCap
+}
+
+func (b *EthApiBackend) RPCTxFeeCap() float64 {
+	return b.eth.rpcTxFeeCap
+}
+
+func (b *EthApiBackend) ResponseCache() *ethapi.ResponseCache {
+	return b.eth.rpcCache
 }
 
 func (b *EthApiBackend) ChainDb() ethdb.Database {
 	return b.eth.ChainDb()
 }
 
+func (b *EthApiBackend) BloomStatus() bool {
+	return true
+}
+
 func (b *EthApiBackend) EventMux() *event.TypeMux {
 	return b.eth.EventMux()
 }
@@ -0,0 +1,216 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/internal/ethapi"
+	"github.com/expanse-org/go-expanse/miner"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+// Tests that the client versions of miners are counted from the extra-data of
+// the most recent blocks.
+func TestMinerVersions(t *testing.T) {
+	tagged := miner.ExtraInfo{Version: 1<<16 | 6<<8, Name: "gexp", Tag: "pool"}
+	untagged := miner.ExtraInfo{Version: 1<<16 | 5<<8 | 9, Name: "gexp", GoVersion: "go1.8", OS: "linux"}
+
+	pm := newTestProtocolManagerMust(t, false, 10, func(i int, block *core.BlockGen) {
+		switch {
+		case i < 3:
+			block.SetExtra([]byte("vanity"))
+		case i < 6:
+			block.SetExtra(miner.ComposeExtra(untagged))
+		default:
+			block.SetExtra(miner.ComposeExtra(tagged))
+		}
+	}, nil)
+	defer pm.Stop()
+
+	stats := minerVersions(pm.blockchain, 8)
+	if stats.From != 3 || stats.To != 10 {
+		t.Errorf("range mismatch: have %d-%d, want 3-10", stats.From, stats.To)
+	}
+	if stats.Unknown != 1 {
+		t.Errorf("unknown count mismatch: have %d, want 1", stats.Unknown)
+	}
+	want := map[string]hexutil.Uint64{"gexp/v1.6.0": 4, "gexp/v1.5.9": 3}
+	if len(stats.Versions) != len(want) {
+		t.Errorf("version count mismatch: have %v, want %v", stats.Versions, want)
+	}
+	for version, n := range want {
+		if stats.Versions[version] != n {
+			t.Errorf("version %s count mismatch: have %d, want %d", version, stats.Versions[version], n)
+		}
+	}
+	if len(stats.Tags) != 1 || stats.Tags["pool"] != 4 {
+		t.Errorf("tags mismatch: have %v, want pool: 4", stats.Tags)
+	}
+	// Scanning past the genesis should stop there
+	if stats := minerVersions(pm.blockchain, 100); stats.From != 0 || stats.Unknown != 4 {
+		t.Errorf("full range mismatch: have from %d with %d unknown, want from 0 with 4 unknown", stats.From, stats.Unknown)
+	}
+}
+
+// Tests that miner_setEtherbase accepts single addresses as well as plain and
+// weighted lists of addresses.
+func TestEtherbaseArgs(t *testing.T) {
+	var (
+		a = common.HexToAddress("0x000000000000000000000000000000000000000a")
+		b = common.HexToAddress("0x000000000000000000000000000000000000000b")
+	)
+	tests := []struct {
+		input string
+		want  *EtherbaseArgs // nil if invalid
+	}{
+		{`"0x000000000000000000000000000000000000000a"`, &EtherbaseArgs{Addresses: []common.Address{a}}},
+		{`["0x000000000000000000000000000000000000000a", "0x000000000000000000000000000000000000000b"]`, &EtherbaseArgs{Addresses: []common.Address{a, b}}},
+		{`[{"address": "0x000000000000000000000000000000000000000a", "weight": "0x3"}, {"address": "0x000000000000000000000000000000000000000b", "weight": "0x1"}]`, &EtherbaseArgs{Addresses: []common.Address{a, b}, Weights: []uint64{3, 1}}},
+		{`[{"address": "0x000000000000000000000000000000000000000a"}]`, nil},
+		{`"0x5aaeb6053F3E94C9b9A09f33669435E7Ef1BeAed"`, nil}, // broken checksum
+		{`"not an address"`, nil},
+		{`42`, nil},
+	}
+	for i, tt := range tests {
+		var args EtherbaseArgs
+		err := json.Unmarshal([]byte(tt.input), &args)
+		switch {
+		case tt.want == nil && err == nil:
+			t.Errorf("test %d: no error for invalid input", i)
+		case tt.want != nil && err != nil:
+			t.Errorf("test %d: failed to unmarshal: %v", i, err)
+		case tt.want != nil && !reflect.DeepEqual(&args, tt.want):
+			t.Errorf("test %d: args mismatch: have %+v, want %+v", i, args, *tt.want)
+		}
+	}
+}
+
+// Tests that blocks can be traced with JavaScript tracers, resulting in a trace
+// for every transaction, matching the transaction traces.
+func TestTraceBlockTracer(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	pm := newTestProtocolManagerMust(t, false, 2, func(i int, block *core.BlockGen) {
+		if i == 1 {
+			for j := 0; j < 3; j++ {
+				code := deployCode([]byte{0x60, byte(j), 0x60, 0x00, 0x55, 0x00}) // SSTORE j at slot 0 when called
+				tx, _ := types.SignTx(types.NewContractCreation(block.TxNonce(testBank), new(big.Int), big.NewInt(100000), nil, code), signer, testBankKey)
+				block.AddTx(tx)
+			}
+		}
+	}, nil)
+	api := NewPrivateDebugAPI(params.TestChainConfig, &Ethereum{blockchain: pm.blockchain, chainDb: pm.chaindb})
+
+	tracer := "{count: 0, step: function() { this.count += 1; }, result: function() { return this.count; }}"
+	result := api.TraceBlockByNumber(context.Background(), 2, &TraceArgs{Tracer: &tracer})
+	if !result.Validated || result.Error != "" {
+		t.Fatalf("block not validated: %s", result.Error)
+	}
+	block := pm.blockchain.GetBlockByNumber(2)
+	if len(result.Traces) != len(block.Transactions()) {
+		t.Fatalf("trace count mismatch: have %d, want %d", len(result.Traces), len(block.Transactions()))
+	}
+	for i, tx := range block.Transactions() {
+		trace, err := api.TraceTransaction(context.Background(), tx.Hash(), &TraceArgs{Tracer: &tracer})
+		if err != nil {
+			t.Fatalf("tx %d: failed to trace: %v", i, err)
+		}
+		if !reflect.DeepEqual(result.Traces[i], trace) {
+			t.Errorf("tx %d: trace mismatch: have %v, want %v", i, result.Traces[i], trace)
+		}
+	}
+	// Tracing without a JavaScript tracer still returns the struct logs
+	result = api.TraceBlockByNumber(context.Background(), 2, nil)
+	if !result.Validated || len(result.StructLogs) == 0 || result.Traces != nil {
+		t.Errorf("struct log trace mismatch: validated %v, logs %d, traces %v", result.Validated, len(result.StructLogs), result.Traces)
+	}
+}
+
+// Tests that the block range and supply methods resolve the block tags the same
+// way as the backends do: latest and pending to the head, finality tags failing.
+func TestBlockTagResolution(t *testing.T) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		gspec    = &core.Genesis{Config: params.TestChainConfig}
+		genesis  = gspec.MustCommit(db)
+		mux      = new(event.TypeMux)
+	)
+	gspec.MustCommit(gendb)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, 10, nil)
+
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), mux, vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	eth := &Ethereum{
+		blockchain: chain,
+		chainStats: newChainStatsIndexer(chain, db, new(mclock.Simulated), func() bool { return false }),
+		transfers:  newTransferIndexer(chain, db, gspec.Config, mux),
+	}
+
+	var (
+		public = NewPublicEthereumAPI(eth)
+		debug  = NewPrivateDebugAPI(gspec.Config, eth)
+		addr   = common.ChecksumAddress{0x01}
+	)
+	for _, tag := range []rpc.BlockNumber{rpc.LatestBlockNumber, rpc.PendingBlockNumber} {
+		have, err := public.ChainStats(5, tag)
+		want, werr := public.ChainStats(5, 10)
+		if !reflect.DeepEqual(have, want) || err != werr {
+			t.Errorf("tag %d: chain stats mismatch: have %+v (%v), want %+v (%v)", tag, have, err, want, werr)
+		}
+		supply, err := public.TotalSupply(tag)
+		wsupply, werr := public.TotalSupply(10)
+		if err != nil || werr != nil || supply.ToInt().Cmp(wsupply.ToInt()) != 0 {
+			t.Errorf("tag %d: total supply mismatch: have %v (%v), want %v (%v)", tag, supply, err, wsupply, werr)
+		}
+		transfers, err := debug.GetInternalTransactions(addr, 5, tag)
+		wtransfers, werr := debug.GetInternalTransactions(addr, 5, 10)
+		if !reflect.DeepEqual(transfers, wtransfers) || !reflect.DeepEqual(err, werr) {
+			t.Errorf("tag %d: internal transactions mismatch: have %v (%v), want %v (%v)", tag, transfers, err, wtransfers, werr)
+		}
+	}
+	for _, tag := range []rpc.BlockNumber{rpc.SafeBlockNumber, rpc.FinalizedBlockNumber} {
+		if _, err := public.ChainStats(5, tag); err != ethapi.ErrNoFinality {
+			t.Errorf("tag %d: chain stats error mismatch: have %v, want %v", tag, err, ethapi.ErrNoFinality)
+		}
+		if _, err := public.ChainStats(tag, 10); err != ethapi.ErrNoFinality {
+			t.Errorf("tag %d: chain stats error mismatch: have %v, want %v", tag, err, ethapi.ErrNoFinality)
+		}
+		if _, err := public.TotalSupply(tag); err != ethapi.ErrNoFinality {
+			t.Errorf("tag %d: total supply error mismatch: have %v, want %v", tag, err, ethapi.ErrNoFinality)
+		}
+		if _, err := debug.GetInternalTransactions(addr, 5, tag); err != ethapi.ErrNoFinality {
+			t.Errorf("tag %d: internal transactions error mismatch: have %v, want %v", tag, err, ethapi.ErrNoFinality)
+		}
+	}
+}
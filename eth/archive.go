@@ -0,0 +1,240 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+const (
+	availabilityStepInterval = 3 * time.Second // Time to wait between two scanning steps
+	availabilityScanLimit    = 16384           // Maximum number of blocks to check per step
+)
+
+// availabilityKey tracks the scanned state availability of the canonical chain.
+var availabilityKey = []byte("StateAvailability")
+
+// stateAvailability is the persisted progress of the state availability scan.
+type stateAvailability struct {
+	Next   uint64       // First block not yet checked
+	Ranges []stateRange // Ranges of checked blocks with state, in ascending order
+}
+
+// stateRange is an inclusive range of blocks whose states are all available.
+type stateRange struct {
+	From uint64
+	To   uint64
+}
+
+// StateRange is an inclusive range of blocks whose states are all available.
+type StateRange struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// StateAvailability reports the blocks whose state a node can serve.
+type StateAvailability struct {
+	Archive  bool           `json:"archive"`  // Whether the node runs in archive mode
+	Complete bool           `json:"complete"` // Whether every scanned block has its state
+	Scanned  hexutil.Uint64 `json:"scanned"`  // Number of blocks scanned so far
+	Ranges   []StateRange   `json:"ranges"`
+}
+
+// stateScanner maps which canonical blocks have their state available. Fast
+// syncing skips the states of the blocks below its pivot, so only the ranges
+// imported by full block processing are available, and pruning nodes further
+// delete the states older than their retention window.
+//
+// A state is considered available if its root trie node is. State sync only
+// writes trie nodes once all their children are present, so the root being
+// present implies the whole state is.
+type stateScanner struct {
+	chain   *core.BlockChain
+	db      ethdb.Database
+	clock   mclock.Clock
+	syncing func() bool // Reports whether chain synchronisation is running
+	archive bool        // Whether the node guarantees all states, warning on gaps
+
+	progress stateAvailability
+	warned   bool // Whether an archive gap was already reported
+	lock     sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newStateScanner creates a state availability scanner for the given chain,
+// resuming from the progress persisted in db.
+func newStateScanner(chain *core.BlockChain, db ethdb.Database, clock mclock.Clock, syncing func() bool, archive bool) *stateScanner {
+	s := &stateScanner{
+		chain:   chain,
+		db:      db,
+		clock:   clock,
+		syncing: syncing,
+		archive: archive,
+		quit:    make(chan struct{}),
+	}
+	if data, _ := db.Get(availabilityKey); len(data) > 0 {
+		if err := rlp.DecodeBytes(data, &s.progress); err != nil {
+			log.Error("Invalid state availability RLP", "err", err)
+			s.progress = stateAvailability{}
+		}
+	}
+	return s
+}
+
+// start spins up the scanning loop.
+func (s *stateScanner) start() {
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// stop terminates the scanning loop, waiting for any running step.
+func (s *stateScanner) stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// loop keeps scanning the canonical chain as it grows.
+func (s *stateScanner) loop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.clock.After(availabilityStepInterval):
+			s.step()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// step checks the next range of canonical blocks for their states and persists
+// the progress made.
+func (s *stateScanner) step() {
+	if s.syncing() {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	head := s.chain.CurrentBlock().NumberU64()
+	if s.progress.Next > head+1 {
+		s.truncate(head + 1)
+	}
+	start := s.progress.Next
+	for s.progress.Next <= head && s.progress.Next-start < availabilityScanLimit {
+		header := s.chain.GetHeaderByNumber(s.progress.Next)
+		if header == nil {
+			break
+		}
+		if _, err := state.New(header.Root, s.db); err == nil {
+			s.add(s.progress.Next)
+		} else if s.archive && !s.warned {
+			log.Warn("Archive node missing historical state", "number", s.progress.Next, "hash", header.Hash())
+			s.warned = true
+		}
+		s.progress.Next++
+	}
+	// Forget the states deleted by the last pruning, if enabled
+	pruned := false
+	if config := s.chain.Pruning(); config != nil {
+		if last := core.GetLastPruneNumber(s.db); last >= config.Retention {
+			pruned = s.drop(last - config.Retention + 1)
+		}
+	}
+	if s.progress.Next == start && !pruned {
+		return
+	}
+	enc, _ := rlp.EncodeToBytes(&s.progress)
+	if err := s.db.Put(availabilityKey, enc); err != nil {
+		log.Warn("Failed to store state availability", "err", err)
+	}
+}
+
+// add marks the state of the next scanned block available.
+func (s *stateScanner) add(number uint64) {
+	if n := len(s.progress.Ranges); n > 0 && s.progress.Ranges[n-1].To+1 == number {
+		s.progress.Ranges[n-1].To = number
+		return
+	}
+	s.progress.Ranges = append(s.progress.Ranges, stateRange{number, number})
+}
+
+// truncate drops the scan results from the given block onwards, after the head
+// of the chain was rewound below it.
+func (s *stateScanner) truncate(number uint64) {
+	ranges := s.progress.Ranges[:0]
+	for _, r := range s.progress.Ranges {
+		if r.From >= number {
+			break
+		}
+		if r.To >= number {
+			r.To = number - 1
+		}
+		ranges = append(ranges, r)
+	}
+	s.progress.Ranges, s.progress.Next = ranges, number
+}
+
+// drop discards the scan results below the given block, after the states of the
+// preceding blocks were pruned. It returns whether any results were discarded.
+func (s *stateScanner) drop(number uint64) bool {
+	var (
+		ranges  = s.progress.Ranges[:0]
+		dropped bool
+	)
+	for _, r := range s.progress.Ranges {
+		if r.To < number {
+			dropped = true
+			continue
+		}
+		if r.From < number {
+			r.From, dropped = number, true
+		}
+		ranges = append(ranges, r)
+	}
+	s.progress.Ranges = ranges
+	return dropped
+}
+
+// availability returns the state availability scanned so far.
+func (s *stateScanner) availability() *StateAvailability {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	result := &StateAvailability{
+		Archive: s.archive,
+		Scanned: hexutil.Uint64(s.progress.Next),
+		Ranges:  []StateRange{},
+	}
+	for _, r := range s.progress.Ranges {
+		result.Ranges = append(result.Ranges, StateRange{hexutil.Uint64(r.From), hexutil.Uint64(r.To)})
+	}
+	result.Complete = len(s.progress.Ranges) == 1 && s.progress.Ranges[0].From == 0 && s.progress.Ranges[0].To+1 == s.progress.Next
+	return result
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// Tests that the blocks with missing states are detected, the available ranges
+// persisted, and dropped when the chain is rewound.
+func TestStateAvailability(t *testing.T) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	gspec.MustCommit(gendb)
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, 10, nil)
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	// Drop the states of a few blocks, as if they were fast synced
+	for _, block := range blocks[2:5] {
+		db.Delete(block.Root().Bytes())
+	}
+	scanner := newStateScanner(chain, db, new(mclock.Simulated), func() bool { return false }, true)
+	scanner.step()
+
+	want := &StateAvailability{
+		Archive: true,
+		Scanned: 11,
+		Ranges:  []StateRange{{0, 2}, {6, 10}},
+	}
+	if have := scanner.availability(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("availability mismatch: have %+v, want %+v", have, want)
+	}
+	// Ensure the scan results are persisted, and dropped above a rewound head
+	if err := chain.SetHead(7); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	scanner = newStateScanner(chain, db, new(mclock.Simulated), func() bool { return false }, false)
+	scanner.step()
+
+	want = &StateAvailability{
+		Scanned: 8,
+		Ranges:  []StateRange{{0, 2}, {6, 7}},
+	}
+	if have := scanner.availability(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("rewound availability mismatch: have %+v, want %+v", have, want)
+	}
+	// Ensure a chain with all the states is reported complete
+	full, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(full)
+	chain, _ = core.NewBlockChain(full, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	scanner = newStateScanner(chain, full, new(mclock.Simulated), func() bool { return false }, true)
+	scanner.step()
+
+	if have := scanner.availability(); !have.Complete || have.Scanned != hexutil.Uint64(len(blocks)+1) {
+		t.Fatalf("full chain availability mismatch: have %+v", have)
+	}
+}
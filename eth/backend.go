@@ -18,6 +18,7 @@
 package eth
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"regexp"
@@ -27,7 +28,10 @@ import (
 
 	"github.com/expanse-org/go-expanse/accounts"
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/core/vm"
 	"github.com/expanse-org/go-expanse/eth/downloader"
@@ -66,6 +70,7 @@ type Config struct {
 	NetworkId int // Network ID to use for selecting peers to connect to
 
 	FastSync   bool // Enables the state download based fast synchronisation algorithm
+	Archive    bool // Guarantees every historical state is available, disabling fast sync
 	LightMode  bool // Running in light client mode
 	LightServ  int  // Maximum percentage of time allowed for serving LES requests
 	LightPeers int  // Maximum number of LES client peers
@@ -74,8 +79,20 @@ type Config struct {
 	SkipBcVersionCheck bool // e.g. blockchain export
 	DatabaseCache      int
 	DatabaseHandles    int
+	MemoryAllowance    int // Megabytes of heap above which caches are shrunk (0 = unlimited)
+
+	DatabaseBatchSize    int  // Kilobytes of chain data to batch per database write (0 = default)
+	DatabaseSyncInterval int  // Number of batch writes between disk syncs while syncing (0 = only near the head)
+	DatabaseCompaction   bool // Whether to compact the chain database while the node is idle
+
+	Pruning          bool   // Garbage collects the historical states, keeping only the recent ones
+	PruningRetention uint64 // Number of most recent blocks whose state is kept when pruning (0 = default)
+
+	SnapshotSync     bool   // Restores a recent state snapshot served by peers before fast syncing
+	SnapshotInterval uint64 // Number of blocks between two state snapshots served to peers (0 = disabled)
 
 	DocRoot   string
+	Engine    consensus.Engine // Consensus engine replacing ethash (nil = ethash)
 	PowFake   bool
 	PowTest   bool
 	PowShared bool
@@ -87,20 +104,42 @@ type Config struct {
 	EthashDatasetDir     string
 	EthashDatasetsInMem  int
 	EthashDatasetsOnDisk int
+	EthashCachesQuota    int // Megabytes of disk space the ethash caches may use (0 = unlimited)
+	EthashDatasetsQuota  int // Megabytes of disk space the ethash DAGs may use (0 = unlimited)
 
 	Etherbase    common.Address
 	GasPrice     *big.Int
 	MinerThreads int
 	SolcPath     string
 
-	GpoMinGasPrice          *big.Int
-	GpoMaxGasPrice          *big.Int
-	GpoFullBlockRatio       int
-	GpobaseStepDown         int
-	GpobaseStepUp           int
-	GpobaseCorrectionFactor int
+	MinerStrictParent time.Duration      // Only mine on blocks validated locally within this time (0 = any block)
+	MinerBannedBlocks []common.Hash      // Blocks whose descendants are never mined on
+	MinerUnclePolicy  *miner.UnclePolicy // Rules for picking the uncles of mined blocks (nil = default)
+
+	TxQueuedLifetime  time.Duration     // Max time a transaction is queued behind a nonce gap (0 = unlimited)
+	TxAccountLifetime time.Duration     // Max time transactions of idle accounts are queued (0 = default)
+	TxJournal         string            // Disk journal of local transactions surviving restarts ("" = disabled)
+	TxPool            core.TxPoolConfig // Limits on the transactions pooled (zero = default)
+
+	GpoBlocks      int      // Number of recent blocks sampled by the gas price oracle
+	GpoPercentile  int      // Percentile of the sampled block prices suggested
+	GpoMinGasPrice *big.Int // Lower bound of the suggested gas price (nil = unbounded)
+	GpoMaxGasPrice *big.Int // Upper bound of the suggested gas price (nil = unbounded)
 
 	EnablePreimageRecording bool
+
+	ShadowFork *params.ChainConfig // Rules to replay the canonical transactions under on a shadow fork (nil = disabled)
+
+	RichListSize  int  // Number of richest accounts to index (0 = disabled)
+	CreatorIndex  bool // Whether to index the creation of every contract
+	TransferIndex bool // Whether to index the internal value transfers of imported blocks
+	LogIndex      bool // Whether to index the log addresses and topics for fast log filtering
+
+	RPCGasCap   *big.Int // Gas allowance of eth_call and eth_estimateGas requests (nil = unlimited)
+	RPCTxFeeCap float64  // Highest fee in ether of transactions sent through the RPC APIs (0 = unlimited)
+
+	RPCCacheSize          int    // Number of immutable RPC responses cached per kind (0 = disabled)
+	RPCCacheConfirmations uint64 // Blocks a block must be buried under for its responses to be cached
 }
 
 type LesServer interface {
@@ -121,11 +160,12 @@ type Ethereum struct {
 	blockchain      *core.BlockChain
 	protocolManager *ProtocolManager
 	lesServer       LesServer
+	slots           *p2p.PeerSlots // Peer slots shared with the LES server
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
 
 	eventMux       *event.TypeMux
-	pow            pow.PoW
+	engine         consensus.Engine
 	accountManager *accounts.Manager
 
 	ApiBackend *EthApiBackend
@@ -136,8 +176,24 @@ type Ethereum struct {
 	etherbase    common.Address
 	solcPath     string
 
-	netVersionId  int
-	netRPCService *ethapi.PublicNetAPI
+	netVersionId          int
+	netRPCService         *ethapi.PublicNetAPI
+	fingerprintRPCService *ethapi.PublicFingerprintAPI
+	rpcGasCap             *big.Int
+	rpcTxFeeCap           float64
+	rpcCache              *ethapi.ResponseCache // Cache of immutable API responses, nil if disabled
+
+	memoryGovernor *memoryGovernor         // Cache shrinker, nil if memory is unlimited
+	compactor      *compactionScheduler    // Idle time database compactor, nil if disabled
+	regenerator    *receiptRegenerator     // Rebuilder of receipts missing from old blocks
+	shadow         *shadowReplayer         // Replayer of live transactions on a shadow fork, nil if disabled
+	chainStats     *chainStatsIndexer      // Aggregator of historical chain statistics
+	richList       *richListIndexer        // Ranking of accounts by balance, nil if disabled
+	creators       *contractCreatorIndexer // Index of contract creations, nil if disabled
+	transfers      *transferIndexer        // Index of internal value transfers, nil if disabled
+	logIndexer     *logIndexer             // Backfiller of the log index, nil if disabled
+	states         *stateScanner           // Tracker of the blocks with available state
+	snapshotter    *snapshotter            // Generator of the state snapshots served to peers, nil if disabled
 }
 
 func (s *Ethereum) AddLesServer(ls LesServer) {
@@ -148,7 +204,7 @@ func (s *Ethereum) AddLesServer(ls LesServer) {
 // New creates a new Ethereum object (including the
 // initialisation of the common Ethereum object)
 func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
-	chainDb, err := CreateDB(ctx, config, "chaindata")
+	chainDb, err := CreateDB(ctx, config, ChainDbName(ctx.ResolvePath, false))
 	if err != nil {
 		return nil, err
 	}
@@ -159,18 +215,30 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
+	// Archive nodes retain every historical state, which pruning deletes
+	if config.Archive && config.Pruning {
+		return nil, errors.New("state pruning not available in archive mode")
+	}
+	// Fast sync skips historical states, which archive nodes must retain
+	if config.Archive && config.FastSync {
+		log.Warn("Fast sync disabled in archive mode")
+		config.FastSync = false
+	}
+
 	eth := &Ethereum{
 		chainDb:        chainDb,
 		chainConfig:    chainConfig,
 		eventMux:       ctx.EventMux,
 		accountManager: ctx.AccountManager,
-		pow:            CreatePoW(ctx, config),
+		engine:         CreateConsensusEngine(ctx, config, chainConfig),
 		shutdownChan:   make(chan bool),
 		stopDbUpgrade:  stopDbUpgrade,
 		netVersionId:   config.NetworkId,
 		etherbase:      config.Etherbase,
 		MinerThreads:   config.MinerThreads,
 		solcPath:       config.SolcPath,
+		rpcGasCap:      config.RPCGasCap,
+		rpcTxFeeCap:    config.RPCTxFeeCap,
 	}
 
 	if err := addMipmapBloomBins(chainDb); err != nil {
@@ -187,10 +255,36 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 
 	vmConfig := vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-	eth.blockchain, err = core.NewBlockChain(chainDb, eth.chainConfig, eth.pow, eth.eventMux, vmConfig)
+	eth.blockchain, err = core.NewBlockChain(chainDb, eth.chainConfig, eth.engine, eth.eventMux, vmConfig)
 	if err != nil {
 		return nil, err
 	}
+	if engine, ok := eth.engine.(*core.PowEngine); ok {
+		if interval, ok := engine.PoW.(*pow.IntervalPoW); ok {
+			interval.SetChain(eth.blockchain)
+		}
+	}
+	if config.RPCCacheSize > 0 {
+		head := func() uint64 { return eth.blockchain.CurrentBlock().NumberU64() }
+		eth.rpcCache = ethapi.NewResponseCache(chainDb, head, config.RPCCacheSize, config.RPCCacheConfirmations)
+	}
+	writePolicy := core.DefaultWritePolicy
+	if config.DatabaseBatchSize > 0 {
+		writePolicy.BatchSize = config.DatabaseBatchSize * 1024
+	}
+	writePolicy.SyncInterval = config.DatabaseSyncInterval
+	eth.blockchain.SetWritePolicy(writePolicy)
+
+	if config.Pruning {
+		pruning := core.DefaultPruneConfig
+		if config.PruningRetention > 0 {
+			pruning.Retention = config.PruningRetention
+		}
+		if err := eth.blockchain.SetPruning(&pruning); err != nil {
+			return nil, err
+		}
+	}
+
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
@@ -199,65 +293,208 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 
 	newPool := core.NewTxPool(eth.chainConfig, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit)
+	newPool.Meter(ctx.Metrics)
+	newPool.SetQueueLifetimes(config.TxQueuedLifetime, config.TxAccountLifetime)
+	newPool.SetLimits(config.TxPool)
+	if config.TxJournal != "" {
+		if path := ctx.ResolvePath(config.TxJournal); path != "" {
+			if err := newPool.SetJournal(path); err != nil {
+				log.Warn("Failed to enable transaction journal", "path", path, "err", err)
+			}
+		}
+	}
 	eth.txPool = newPool
 
-	maxPeers := config.MaxPeers
-	if config.LightServ > 0 {
-		// if we are running a light server, limit the number of ETH peers so that we reserve some space for incoming LES connections
-		// temporary solution until the new peer connectivity API is finished
-		halfPeers := maxPeers / 2
-		maxPeers -= config.LightPeers
-		if maxPeers < halfPeers {
-			maxPeers = halfPeers
+	// Share the peer slots of the node with the LES server, if one is added
+	eth.slots = ctx.PeerSlots
+	if eth.slots == nil {
+		eth.slots = p2p.NewPeerSlots(config.MaxPeers)
+	}
+	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.FastSync, config.NetworkId, eth.slots, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
+		return nil, err
+	}
+	eth.protocolManager.snapshotSync = config.SnapshotSync
+	if config.SnapshotSync || config.SnapshotInterval > 0 {
+		eth.protocolManager.SubProtocols = append(eth.protocolManager.SubProtocols, eth.protocolManager.snapshotProtocols()...)
+	}
+	// Keep the heap within the allowance by shrinking the caches if requested
+	if config.MemoryAllowance > 0 {
+		resize := func(scale float64) {
+			eth.protocolManager.downloader.SetCacheScale(scale)
+			if ldb, ok := chainDb.(*ethdb.LDBDatabase); ok {
+				ldb.SetCacheScale(scale)
+			}
+			if scale < 1 {
+				gen := uint16(float64(state.MaxTrieCacheGen) * scale)
+				if gen == 0 {
+					gen = 1
+				}
+				eth.blockchain.LimitTrieCacheGen(gen)
+			} else {
+				eth.blockchain.LimitTrieCacheGen(0)
+			}
 		}
+		eth.memoryGovernor = newMemoryGovernor(uint64(config.MemoryAllowance)*1024*1024, resize, eth.blockchain.PurgeCaches)
+		ctx.RegisterLifecycle(node.NewLifecycle(eth.memoryGovernor.start, eth.memoryGovernor.stop))
 	}
+	clock := ctx.Clock
+	if clock == nil {
+		clock = mclock.System{}
+	}
+	// Compact the chain database during idle periods if requested
+	if ldb, ok := chainDb.(*ethdb.LDBDatabase); ok && config.DatabaseCompaction {
+		requests := ctx.RPCRequests
+		if requests == nil {
+			requests = func() uint64 { return 0 }
+		}
+		eth.compactor = newCompactionScheduler(ldb, clock, eth.protocolManager.downloader.Synchronising, requests, eth.blockchain.InsertLatency)
+		ctx.RegisterLifecycle(node.NewLifecycle(eth.compactor.start, eth.compactor.stop))
+	}
+	// Rebuild the receipts of old blocks if previous versions left them out
+	eth.regenerator = newReceiptRegenerator(eth.blockchain, chainDb, clock, eth.protocolManager.downloader.Synchronising)
+	ctx.RegisterLifecycle(node.NewLifecycle(eth.regenerator.start, eth.regenerator.stop))
 
-	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.FastSync, config.NetworkId, maxPeers, eth.eventMux, eth.txPool, eth.pow, eth.blockchain, chainDb); err != nil {
-		return nil, err
+	eth.chainStats = newChainStatsIndexer(eth.blockchain, chainDb, clock, eth.protocolManager.downloader.Synchronising)
+	ctx.RegisterLifecycle(node.NewLifecycle(eth.chainStats.start, eth.chainStats.stop))
+
+	if config.RichListSize > 0 {
+		eth.richList = newRichListIndexer(eth.blockchain, chainDb, clock, eth.protocolManager.downloader.Synchronising, config.RichListSize)
+		ctx.RegisterLifecycle(node.NewLifecycle(eth.richList.start, eth.richList.stop))
 	}
-	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.pow)
+	if config.CreatorIndex {
+		eth.creators = newContractCreatorIndexer(eth.blockchain, chainDb, eth.chainConfig, clock, eth.protocolManager.downloader.Synchronising)
+		ctx.RegisterLifecycle(node.NewLifecycle(eth.creators.start, eth.creators.stop))
+	}
+	if config.TransferIndex {
+		eth.transfers = newTransferIndexer(eth.blockchain, chainDb, eth.chainConfig, eth.eventMux)
+		ctx.RegisterLifecycle(node.NewLifecycle(eth.transfers.start, eth.transfers.stop))
+	}
+	if config.LogIndex {
+		eth.logIndexer = newLogIndexer(eth.blockchain, chainDb, clock, eth.protocolManager.downloader.Synchronising)
+		ctx.RegisterLifecycle(node.NewLifecycle(eth.logIndexer.start, eth.logIndexer.stop))
+	} else {
+		// Stop maintaining the index, it would miss the blocks imported from now on
+		core.DeleteLogIndexTail(chainDb)
+	}
+	eth.states = newStateScanner(eth.blockchain, chainDb, clock, eth.protocolManager.downloader.Synchronising, config.Archive)
+	ctx.RegisterLifecycle(node.NewLifecycle(eth.states.start, eth.states.stop))
+
+	if config.SnapshotInterval > 0 {
+		eth.snapshotter = newSnapshotter(eth.blockchain, chainDb, clock, eth.protocolManager.downloader.Synchronising, config.SnapshotInterval)
+		ctx.RegisterLifecycle(node.NewLifecycle(eth.snapshotter.start, eth.snapshotter.stop))
+	}
+
+	// Rehearse the configured rule changes on the live transactions if requested
+	if config.ShadowFork != nil {
+		if eth.shadow, err = newShadowReplayer(eth.blockchain, chainDb, config.ShadowFork, eth.engine, eth.eventMux); err != nil {
+			return nil, err
+		}
+		ctx.RegisterLifecycle(node.NewLifecycle(eth.shadow.start, eth.shadow.stop))
+		log.Warn("Replaying transactions on shadow fork", "fork", eth.blockchain.CurrentBlock().Number(), "config", config.ShadowFork)
+	}
+
+	// Pause disk hungry operations if the data directory is running out of space
+	if ctx.DiskMonitor != nil {
+		eth.protocolManager.downloader.SetStorageGuard(ctx.DiskMonitor.Critical)
+		if engine, ok := eth.engine.(*core.PowEngine); ok {
+			if ethash, ok := engine.PoW.(*pow.Ethash); ok {
+				ethash.SetStorageGuard(ctx.DiskMonitor.Critical)
+			}
+		}
+	}
+	// Let the node shed expensive RPC calls while catching up with the network
+	if ctx.LoadShedder != nil {
+		ctx.LoadShedder.RegisterSyncStatus(func() (bool, uint64) {
+			progress := eth.protocolManager.downloader.Progress()
+			return progress.CurrentBlock < progress.HighestBlock, eth.blockchain.CurrentBlock().NumberU64()
+		})
+	}
+	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	eth.miner.SetGasPrice(config.GasPrice)
 	eth.miner.SetExtra(config.ExtraData)
-
-	gpoParams := &gasprice.GpoParams{
-		GpoMinGasPrice:          config.GpoMinGasPrice,
-		GpoMaxGasPrice:          config.GpoMaxGasPrice,
-		GpoFullBlockRatio:       config.GpoFullBlockRatio,
-		GpobaseStepDown:         config.GpobaseStepDown,
-		GpobaseStepUp:           config.GpobaseStepUp,
-		GpobaseCorrectionFactor: config.GpobaseCorrectionFactor,
+	eth.miner.SetStrictParent(config.MinerStrictParent)
+	eth.miner.BanBlocks(config.MinerBannedBlocks...)
+	if config.MinerUnclePolicy != nil {
+		if err := eth.miner.SetUnclePolicy(*config.MinerUnclePolicy); err != nil {
+			return nil, err
+		}
 	}
-	gpo := gasprice.NewGasPriceOracle(eth.blockchain, chainDb, eth.eventMux, gpoParams)
-	eth.ApiBackend = &EthApiBackend{eth, gpo}
+
+	eth.ApiBackend = &EthApiBackend{eth, nil}
+	eth.ApiBackend.gpo = gasprice.NewOracle(eth.ApiBackend, config.GasPriceOracle())
 
 	return eth, nil
 }
 
+// ChainDbName returns the name of the chain database of a full or a light node.
+// A node switching between the two modes reuses the database of the other mode
+// if it has none of its own yet: a light node only needs the headers of a full
+// database, whereas a light database gets its blocks filled in by a full sync.
+func ChainDbName(resolve func(string) string, light bool) string {
+	name, other := "chaindata", "lightchaindata"
+	if light {
+		name, other = other, name
+	}
+	if path := resolve(name); path != "" && !common.FileExist(path) {
+		if path := resolve(other); path != "" && common.FileExist(path) {
+			log.Info("Reusing chain database of previous sync mode", "database", path)
+			return other
+		}
+	}
+	return name
+}
+
 // CreateDB creates the chain database.
+// GasPriceOracle returns the gas price oracle settings of the configuration,
+// suggesting the default gas price until any transaction is seen.
+func (config *Config) GasPriceOracle() gasprice.Config {
+	return gasprice.Config{
+		Blocks:     config.GpoBlocks,
+		Percentile: config.GpoPercentile,
+		Default:    config.GasPrice,
+		MinPrice:   config.GpoMinGasPrice,
+		MaxPrice:   config.GpoMaxGasPrice,
+	}
+}
+
 func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Database, error) {
 	db, err := ctx.OpenDatabase(name, config.DatabaseCache, config.DatabaseHandles)
 	if db, ok := db.(*ethdb.LDBDatabase); ok {
-		db.Meter("eth/db/chaindata/")
+		db.MeterRegistry(ctx.Metrics, "eth/db/chaindata/")
 	}
 	return db, err
 }
 
-// CreatePoW creates the required type of PoW instance for an Ethereum service
-func CreatePoW(ctx *node.ServiceContext, config *Config) pow.PoW {
+// CreateConsensusEngine creates the consensus engine of an Ethereum service. A
+// custom engine set in the config takes precedence, otherwise the required type
+// of ethash is created, enforcing the block interval bounds of the chain if
+// configured.
+func CreateConsensusEngine(ctx *node.ServiceContext, config *Config, chainConfig *params.ChainConfig) consensus.Engine {
+	if config.Engine != nil {
+		return config.Engine
+	}
+	var engine pow.PoW
 	switch {
 	case config.PowFake:
 		log.Warn("Ethash used in fake mode")
-		return pow.FakePow{}
+		engine = pow.FakePow{}
 	case config.PowTest:
 		log.Warn("Ethash used in test mode")
-		return pow.NewTestEthash()
+		engine = pow.NewTestEthash()
 	case config.PowShared:
 		log.Warn("Ethash used in shared mode")
-		return pow.NewSharedEthash()
+		engine = pow.NewSharedEthash()
 	default:
-		return pow.NewFullEthash(ctx.ResolvePath(config.EthashCacheDir), config.EthashCachesInMem, config.EthashCachesOnDisk,
-			config.EthashDatasetDir, config.EthashDatasetsInMem, config.EthashDatasetsOnDisk)
+		ethash := pow.NewFullEthash(ctx.ResolvePath(config.EthashCacheDir), config.EthashCachesInMem, config.EthashCachesOnDisk,
+			config.EthashDatasetDir, config.EthashDatasetsInMem, config.EthashDatasetsOnDisk).(*pow.Ethash)
+		ethash.SetStorageQuota(uint64(config.EthashCachesQuota)*1024*1024, uint64(config.EthashDatasetsQuota)*1024*1024)
+		engine = ethash
+	}
+	if interval := chainConfig.BlockInterval; interval != nil {
+		log.Warn("Enforcing block interval bounds", "min", interval.Min, "max", interval.Max)
+		engine = pow.NewIntervalPoW(engine, interval.Min, interval.Max)
 	}
+	return core.NewPowEngine(engine)
 }
 
 // APIs returns the collection of RPC services the ethereum package offers.
@@ -308,24 +545,9 @@ func (s *Ethereum) APIs() []rpc.API {
 			Service:   s.netRPCService,
 			Public:    true,
 		}, {
-			Namespace: "exp",
+			Namespace: "web3",
 			Version:   "1.0",
-			Service:   NewPublicEthereumAPI(s),
-			Public:    true,
-		}, {
-			Namespace: "exp",
-			Version:   "1.0",
-			Service:   NewPublicMinerAPI(s),
-			Public:    true,
-		}, {
-			Namespace: "exp",
-			Version:   "1.0",
-			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader, s.eventMux),
-			Public:    true,
-		}, {
-			Namespace: "exp",
-			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.ApiBackend, false),
+			Service:   s.fingerprintRPCService,
 			Public:    true,
 		},
 	}...)
@@ -353,6 +575,13 @@ func (self *Ethereum) SetEtherbase(etherbase common.Address) {
 	self.miner.SetEtherbase(etherbase)
 }
 
+// SetEtherbases sets the addresses the miner rotates block rewards through, the
+// first of which is reported as the etherbase.
+func (self *Ethereum) SetEtherbases(etherbases *miner.Etherbases) {
+	self.etherbase = etherbases.Primary()
+	self.miner.SetEtherbases(etherbases)
+}
+
 func (s *Ethereum) StartMining(threads int) error {
 	eb, err := s.Etherbase()
 	if err != nil {
@@ -371,12 +600,13 @@ func (s *Ethereum) AccountManager() *accounts.Manager  { return s.accountManager
 func (s *Ethereum) BlockChain() *core.BlockChain       { return s.blockchain }
 func (s *Ethereum) TxPool() *core.TxPool               { return s.txPool }
 func (s *Ethereum) EventMux() *event.TypeMux           { return s.eventMux }
-func (s *Ethereum) Pow() pow.PoW                       { return s.pow }
+func (s *Ethereum) Engine() consensus.Engine           { return s.engine }
 func (s *Ethereum) ChainDb() ethdb.Database            { return s.chainDb }
 func (s *Ethereum) IsListening() bool                  { return true } // Always listening
 func (s *Ethereum) EthVersion() int                    { return int(s.protocolManager.SubProtocols[0].Version) }
 func (s *Ethereum) NetVersion() int                    { return s.netVersionId }
 func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
+func (s *Ethereum) PeerSlots() *p2p.PeerSlots          { return s.slots }
 
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
@@ -389,9 +619,11 @@ func (s *Ethereum) Protocols() []p2p.Protocol {
 }
 
 // Start implements node.Service, starting all internal goroutines needed by the
-// Ethereum protocol implementation.
+// Ethereum protocol implementation. The background indexers and schedulers are
+// registered with the node on construction and started by it right after.
 func (s *Ethereum) Start(srvr *p2p.Server) error {
 	s.netRPCService = ethapi.NewPublicNetAPI(srvr, s.NetVersion())
+	s.fingerprintRPCService = ethapi.NewPublicFingerprintAPI(s.ApiBackend, srvr)
 
 	s.protocolManager.Start()
 	if s.lesServer != nil {
@@ -401,7 +633,7 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 }
 
 // Stop implements node.Service, terminating all internal goroutines used by the
-// Ethereum protocol.
+// Ethereum protocol. The node has stopped the background processes by then.
 func (s *Ethereum) Stop() error {
 	if s.stopDbUpgrade != nil {
 		s.stopDbUpgrade()
@@ -421,6 +653,36 @@ func (s *Ethereum) Stop() error {
 	return nil
 }
 
+// Reload implements node.Reloader, changing the minimal gas price accepted by
+// the miner and the transaction pool.
+func (s *Ethereum) Reload(config *node.ReloadConfig) error {
+	if config.GasPrice != nil {
+		if config.GasPrice.Sign() < 0 {
+			return fmt.Errorf("negative gas price %v", config.GasPrice)
+		}
+		s.miner.SetGasPrice(config.GasPrice)
+	}
+	return nil
+}
+
+// Status implements node.StatusReporter, summarising the chain head, the state
+// of synchronisation and the transaction pool.
+func (s *Ethereum) Status() []interface{} {
+	head := s.blockchain.CurrentBlock()
+	pending, queued := s.txPool.Stats()
+
+	status := []interface{}{
+		"number", head.Number(), "hash", head.Hash(),
+		"peers", s.protocolManager.peers.Len(), "syncing", s.Downloader().Synchronising(),
+	}
+	if s.Downloader().Synchronising() {
+		progress := s.Downloader().Progress()
+		status = append(status, "current", progress.CurrentBlock, "highest", progress.HighestBlock,
+			"pulledstates", progress.PulledStates, "knownstates", progress.KnownStates)
+	}
+	return append(status, "pending", pending, "queued", queued, "gasprice", s.miner.GasPrice())
+}
+
 // This function will wait for a shutdown and resumes main thread execution
 func (s *Ethereum) WaitForShutdown() {
 	<-s.shutdownChan
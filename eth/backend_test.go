@@ -17,7 +17,10 @@
 package eth
 
 import (
+	"io/ioutil"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/expanse-org/go-expanse/common"
@@ -81,3 +84,41 @@ func TestMipmapUpgrade(t *testing.T) {
 		t.Error("setting-mipmap-version not written to database")
 	}
 }
+
+// Tests that nodes switching between full and light mode reuse the chain
+// database of the other mode if none of their own exists.
+func TestChainDbName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chaindbname")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	resolve := func(name string) string { return filepath.Join(dir, name) }
+
+	// Fresh data directories use the database of their own mode
+	if name := ChainDbName(resolve, false); name != "chaindata" {
+		t.Errorf("full node database mismatch: have %s, want %s", name, "chaindata")
+	}
+	if name := ChainDbName(resolve, true); name != "lightchaindata" {
+		t.Errorf("light node database mismatch: have %s, want %s", name, "lightchaindata")
+	}
+	// A light node reuses the full database, a full node upgrades the light one
+	os.Mkdir(resolve("chaindata"), 0700)
+	if name := ChainDbName(resolve, true); name != "chaindata" {
+		t.Errorf("light node database mismatch: have %s, want %s", name, "chaindata")
+	}
+	os.Remove(resolve("chaindata"))
+	os.Mkdir(resolve("lightchaindata"), 0700)
+	if name := ChainDbName(resolve, false); name != "lightchaindata" {
+		t.Errorf("full node database mismatch: have %s, want %s", name, "lightchaindata")
+	}
+	// Once both exist, each mode sticks to its own
+	os.Mkdir(resolve("chaindata"), 0700)
+	if name := ChainDbName(resolve, false); name != "chaindata" {
+		t.Errorf("full node database mismatch: have %s, want %s", name, "chaindata")
+	}
+	// Ephemeral nodes have nothing to reuse
+	if name := ChainDbName(func(string) string { return "" }, true); name != "lightchaindata" {
+		t.Errorf("ephemeral light node database mismatch: have %s, want %s", name, "lightchaindata")
+	}
+}
@@ -21,9 +21,11 @@ import (
 	"math/big"
 
 	"github.com/expanse-org/go-expanse"
+	"github.com/expanse-org/go-expanse/accounts/abi/bind"
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/hexutil"
 	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/eth/filters"
 	"github.com/expanse-org/go-expanse/internal/ethapi"
 	"github.com/expanse-org/go-expanse/rlp"
 	"github.com/expanse-org/go-expanse/rpc"
@@ -40,27 +42,32 @@ type ContractBackend struct {
 	eapi  *ethapi.PublicEthereumAPI        // Wrapper around the Ethereum object to access metadata
 	bcapi *ethapi.PublicBlockChainAPI      // Wrapper around the blockchain to access chain data
 	txapi *ethapi.PublicTransactionPoolAPI // Wrapper around the transaction pool to access transaction data
+	logs  *filters.LogFilterer             // Log filterer over the chain, nil if unsupported by the backend
 }
 
 // NewContractBackend creates a new native contract backend using an existing
 // Etheruem object.
 func NewContractBackend(apiBackend ethapi.Backend) *ContractBackend {
-	return &ContractBackend{
+	backend := &ContractBackend{
 		eapi:  ethapi.NewPublicEthereumAPI(apiBackend),
 		bcapi: ethapi.NewPublicBlockChainAPI(apiBackend),
 		txapi: ethapi.NewPublicTransactionPoolAPI(apiBackend),
 	}
+	if fb, ok := apiBackend.(filters.Backend); ok {
+		backend.logs = filters.NewLogFilterer(fb, false)
+	}
+	return backend
 }
 
 // CodeAt retrieves any code associated with the contract from the local API.
 func (b *ContractBackend) CodeAt(ctx context.Context, contract common.Address, blockNum *big.Int) ([]byte, error) {
-	out, err := b.bcapi.GetCode(ctx, contract, toBlockNumber(blockNum))
+	out, err := b.bcapi.GetCode(ctx, common.ChecksumAddress(contract), rpc.BlockNumberOrHashWithNumber(toBlockNumber(blockNum)))
 	return common.FromHex(out), err
 }
 
 // CodeAt retrieves any code associated with the contract from the local API.
 func (b *ContractBackend) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
-	out, err := b.bcapi.GetCode(ctx, contract, rpc.PendingBlockNumber)
+	out, err := b.bcapi.GetCode(ctx, common.ChecksumAddress(contract), rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber))
 	return common.FromHex(out), err
 }
 
@@ -68,7 +75,7 @@ func (b *ContractBackend) PendingCodeAt(ctx context.Context, contract common.Add
 // call with the specified data as the input. The pending flag requests execution
 // against the pending block, not the stable head of the chain.
 func (b *ContractBackend) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNum *big.Int) ([]byte, error) {
-	out, err := b.bcapi.Call(ctx, toCallArgs(msg), toBlockNumber(blockNum))
+	out, err := b.bcapi.Call(ctx, toCallArgs(msg), rpc.BlockNumberOrHashWithNumber(toBlockNumber(blockNum)), nil)
 	return out, err
 }
 
@@ -76,14 +83,14 @@ func (b *ContractBackend) CallContract(ctx context.Context, msg ethereum.CallMsg
 // call with the specified data as the input. The pending flag requests execution
 // against the pending block, not the stable head of the chain.
 func (b *ContractBackend) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
-	out, err := b.bcapi.Call(ctx, toCallArgs(msg), rpc.PendingBlockNumber)
+	out, err := b.bcapi.Call(ctx, toCallArgs(msg), rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber), nil)
 	return out, err
 }
 
 func toCallArgs(msg ethereum.CallMsg) ethapi.CallArgs {
 	args := ethapi.CallArgs{
-		To:   msg.To,
-		From: msg.From,
+		To:   (*common.ChecksumAddress)(msg.To),
+		From: common.ChecksumAddress(msg.From),
 		Data: msg.Data,
 	}
 	if msg.Gas != nil {
@@ -108,7 +115,7 @@ func toBlockNumber(num *big.Int) rpc.BlockNumber {
 // PendingAccountNonce implements bind.ContractTransactor retrieving the current
 // pending nonce associated with an account.
 func (b *ContractBackend) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
-	out, err := b.txapi.GetTransactionCount(ctx, account, rpc.PendingBlockNumber)
+	out, err := b.txapi.GetTransactionCount(ctx, common.ChecksumAddress(account), rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber))
 	if out != nil {
 		nonce = uint64(*out)
 	}
@@ -138,3 +145,21 @@ func (b *ContractBackend) SendTransaction(ctx context.Context, tx *types.Transac
 	_, err := b.txapi.SendRawTransaction(ctx, raw)
 	return err
 }
+
+// FilterLogs implements bind.ContractFilterer, retrieving the past logs matching
+// the query from the local chain.
+func (b *ContractBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if b.logs == nil {
+		return nil, bind.ErrNoFilterer
+	}
+	return b.logs.FilterLogs(ctx, query)
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer, streaming the logs
+// matching the query from the local chain as new blocks are imported.
+func (b *ContractBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	if b.logs == nil {
+		return nil, bind.ErrNoFilterer
+	}
+	return b.logs.SubscribeFilterLogs(ctx, query, ch)
+}
@@ -0,0 +1,147 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/core"
+)
+
+// Capabilities reports what a node is able to serve, so that load balancers can
+// route requests to appropriately capable nodes without probing them.
+type Capabilities struct {
+	NetworkId int                  `json:"networkId"`
+	Protocols []ProtocolCapability `json:"protocols"` // Wire protocols served to peers
+	SyncMode  string               `json:"syncMode"`  // Current synchronisation mode (full, fast or snapshot)
+	Indexes   IndexCapabilities    `json:"indexes"`
+	Tracing   []BlockRange         `json:"tracing"` // Blocks whose transactions can be traced
+	State     StateCapabilities    `json:"state"`
+}
+
+// ProtocolCapability lists the versions of a wire protocol served to peers.
+type ProtocolCapability struct {
+	Name     string `json:"name"`
+	Versions []uint `json:"versions"`
+}
+
+// BlockRange is an inclusive range of blocks.
+type BlockRange struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// IndexCapabilities reports the chain indexes maintained by a node, with the
+// ranges of blocks they cover. Disabled indexes are nil.
+type IndexCapabilities struct {
+	MipMaps      bool        `json:"mipmaps"`      // Whether MIP mapped bloom filters accelerate log searches
+	Transactions *BlockRange `json:"transactions"` // Blocks whose transactions can be looked up by hash
+	Logs         *BlockRange `json:"logs"`         // Blocks covered by the log address and topic index
+	Creators     *BlockRange `json:"creators"`     // Blocks covered by the contract creator index
+	Transfers    *BlockRange `json:"transfers"`    // Blocks covered by the internal transfer index
+	RichList     int         `json:"richList"`     // Number of richest accounts ranked (0 = disabled)
+}
+
+// StateCapabilities reports the historical states a node retains and serves.
+type StateCapabilities struct {
+	Archive   bool           `json:"archive"`   // Whether every historical state is guaranteed
+	Pruning   bool           `json:"pruning"`   // Whether historical states are garbage collected
+	Retention hexutil.Uint64 `json:"retention"` // Number of recent blocks whose state is kept when pruning
+	Snapshots bool           `json:"snapshots"` // Whether state snapshots are served to syncing peers
+	Ranges    []StateRange   `json:"ranges"`    // Blocks whose state is available
+}
+
+// capabilities assembles the capabilities of the node from its current
+// configuration and index progress.
+func (s *Ethereum) capabilities() *Capabilities {
+	head := s.blockchain.CurrentBlock().NumberU64()
+
+	caps := &Capabilities{
+		NetworkId: s.netVersionId,
+		Protocols: []ProtocolCapability{},
+		SyncMode:  "full",
+		Tracing:   []BlockRange{},
+	}
+	// Group the served protocol versions by name, highest version first
+	index := make(map[string]int)
+	for _, proto := range s.Protocols() {
+		i, ok := index[proto.Name]
+		if !ok {
+			i, index[proto.Name] = len(caps.Protocols), len(caps.Protocols)
+			caps.Protocols = append(caps.Protocols, ProtocolCapability{Name: proto.Name})
+		}
+		caps.Protocols[i].Versions = append(caps.Protocols[i].Versions, proto.Version)
+	}
+	for _, proto := range caps.Protocols {
+		versions := proto.Versions
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	}
+	if atomic.LoadUint32(&s.protocolManager.fastSync) == 1 {
+		caps.SyncMode = "fast"
+		if s.protocolManager.snapshotSync {
+			caps.SyncMode = "snapshot"
+		}
+	}
+	// Report the ranges covered by the enabled indexes
+	caps.Indexes = IndexCapabilities{
+		MipMaps:      true,
+		Transactions: &BlockRange{0, hexutil.Uint64(head)},
+	}
+	if s.logIndexer != nil {
+		if tail, ok := core.GetLogIndexTail(s.chainDb); ok && tail <= head {
+			caps.Indexes.Logs = &BlockRange{hexutil.Uint64(tail), hexutil.Uint64(head)}
+		}
+	}
+	if s.creators != nil {
+		from, to := s.creators.indexed()
+		caps.Indexes.Creators = &BlockRange{hexutil.Uint64(from), hexutil.Uint64(to)}
+	}
+	if s.transfers != nil {
+		if from, to, ok := s.transfers.indexed(); ok {
+			caps.Indexes.Transfers = &BlockRange{hexutil.Uint64(from), hexutil.Uint64(to)}
+		}
+	}
+	if s.richList != nil {
+		caps.Indexes.RichList = s.richList.size
+	}
+	// Report the available states, and the blocks traceable on top of them
+	availability := s.states.availability()
+
+	caps.State = StateCapabilities{
+		Archive:   availability.Archive,
+		Snapshots: s.snapshotter != nil,
+		Ranges:    availability.Ranges,
+	}
+	if pruning := s.blockchain.Pruning(); pruning != nil {
+		caps.State.Pruning = true
+		caps.State.Retention = hexutil.Uint64(pruning.Retention)
+	}
+	for _, r := range availability.Ranges {
+		// Tracing a block requires the state of its parent
+		if uint64(r.From) >= head {
+			continue
+		}
+		to := r.To + 1
+		if uint64(to) > head {
+			to = hexutil.Uint64(head)
+		}
+		caps.Tracing = append(caps.Tracing, BlockRange{r.From + 1, to})
+	}
+	return caps
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common/mclock"
+)
+
+// Tests that the capabilities report the served protocols, the covered index
+// ranges and the traceable blocks matching the available states.
+func TestCapabilities(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, false, 10, nil, nil)
+	defer pm.Stop()
+
+	// Drop the states of a few blocks, as if they were fast synced
+	for _, number := range []uint64{3, 4} {
+		pm.chaindb.Delete(pm.blockchain.GetBlockByNumber(number).Root().Bytes())
+	}
+	syncing := func() bool { return false }
+	eth := &Ethereum{
+		blockchain:      pm.blockchain,
+		chainDb:         pm.chaindb,
+		protocolManager: pm,
+		netVersionId:    NetworkId,
+		states:          newStateScanner(pm.blockchain, pm.chaindb, new(mclock.Simulated), syncing, false),
+		creators:        newContractCreatorIndexer(pm.blockchain, pm.chaindb, pm.chainconfig, new(mclock.Simulated), syncing),
+	}
+	eth.states.step()
+
+	var progress [8]byte
+	binary.BigEndian.PutUint64(progress[:], 6)
+	pm.chaindb.Put(creatorProgressKey, progress[:])
+
+	caps := eth.capabilities()
+	if caps.NetworkId != NetworkId || caps.SyncMode != "full" {
+		t.Errorf("network or sync mode mismatch: have %d/%s, want %d/full", caps.NetworkId, caps.SyncMode, NetworkId)
+	}
+	if want := []ProtocolCapability{{ProtocolName, ProtocolVersions}}; !reflect.DeepEqual(caps.Protocols, want) {
+		t.Errorf("protocols mismatch: have %+v, want %+v", caps.Protocols, want)
+	}
+	want := IndexCapabilities{MipMaps: true, Transactions: &BlockRange{0, 10}, Creators: &BlockRange{0, 5}}
+	if !reflect.DeepEqual(caps.Indexes, want) {
+		t.Errorf("indexes mismatch: have %+v, want %+v", caps.Indexes, want)
+	}
+	if want := []StateRange{{0, 2}, {5, 10}}; !reflect.DeepEqual(caps.State.Ranges, want) {
+		t.Errorf("state ranges mismatch: have %v, want %v", caps.State.Ranges, want)
+	}
+	if want := []BlockRange{{1, 3}, {6, 10}}; !reflect.DeepEqual(caps.Tracing, want) {
+		t.Errorf("tracing ranges mismatch: have %v, want %v", caps.Tracing, want)
+	}
+	if caps.State.Archive || caps.State.Pruning || caps.State.Snapshots {
+		t.Errorf("unexpected state retention: %+v", caps.State)
+	}
+}
+
+// Tests that nodes still fast syncing report it as their sync mode.
+func TestCapabilitiesFastSync(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, true, 0, nil, nil)
+	defer pm.Stop()
+
+	eth := &Ethereum{
+		blockchain:      pm.blockchain,
+		chainDb:         pm.chaindb,
+		protocolManager: pm,
+		states:          newStateScanner(pm.blockchain, pm.chaindb, new(mclock.Simulated), func() bool { return false }, false),
+	}
+	if mode := eth.capabilities().SyncMode; mode != "fast" {
+		t.Errorf("sync mode mismatch: have %s, want fast", mode)
+	}
+}
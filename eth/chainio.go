@@ -0,0 +1,176 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+// chainIOBatchSize is the number of blocks exported or imported between two
+// progress events.
+const chainIOBatchSize = 2500
+
+// gzipMagic is the header gzip streams start with, used to detect compressed
+// chain imports.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ChainExportEvent is posted on the event mux as a chain export progresses, once
+// per batch of blocks written and a final time when the export ends.
+type ChainExportEvent struct {
+	First    uint64 // Number of the first block exported
+	Last     uint64 // Number of the last block exported
+	Exported uint64 // Number of blocks written so far
+	Done     bool   // Whether the export ended
+	Err      error  // Error the export failed with, if it ended
+}
+
+// ChainImportEvent is posted on the event mux as a chain import progresses, once
+// per batch of blocks inserted and a final time when the import ends.
+type ChainImportEvent struct {
+	Processed uint64 // Number of blocks read from the input so far
+	Imported  uint64 // Number of blocks inserted, excluding those already known
+	Done      bool   // Whether the import ended
+	Err       error  // Error the import failed with, if it ended
+}
+
+// ExportChain streams the canonical blocks first..last as RLP into w, posting a
+// ChainExportEvent after every batch. Compressing the output, if desired, is up
+// to the writer.
+func (s *Ethereum) ExportChain(w io.Writer, first, last uint64) error {
+	return exportChain(s.blockchain, s.eventMux, w, first, last)
+}
+
+// ImportChain inserts the RLP encoded blocks streamed from r into the chain,
+// posting a ChainImportEvent after every batch. Gzip compressed input is
+// detected and decompressed transparently.
+func (s *Ethereum) ImportChain(r io.Reader) error {
+	return importChain(s.blockchain, s.eventMux, r)
+}
+
+func exportChain(chain *core.BlockChain, mux *event.TypeMux, w io.Writer, first, last uint64) (err error) {
+	progress := ChainExportEvent{First: first, Last: last}
+	defer func() {
+		progress.Done, progress.Err = true, err
+		mux.Post(progress)
+	}()
+
+	if err := checkExportRange(chain, first, last); err != nil {
+		return err
+	}
+	log.Info("Exporting blockchain", "first", first, "last", last)
+	for start, end := first, uint64(0); ; start = end + 1 {
+		if end = start + chainIOBatchSize - 1; end > last {
+			end = last
+		}
+		if err := chain.ExportN(w, start, end); err != nil {
+			return err
+		}
+		progress.Exported += end - start + 1
+		if end == last {
+			break
+		}
+		mux.Post(progress)
+	}
+	log.Info("Exported blockchain", "blocks", progress.Exported)
+	return nil
+}
+
+// checkExportRange verifies that the blocks first..last form a valid range of the
+// canonical chain that can be exported.
+func checkExportRange(chain *core.BlockChain, first, last uint64) error {
+	if first > last {
+		return fmt.Errorf("first block #%d after last block #%d", first, last)
+	}
+	if head := chain.CurrentBlock().NumberU64(); last > head {
+		return fmt.Errorf("last block #%d beyond head #%d", last, head)
+	}
+	return nil
+}
+
+func importChain(chain *core.BlockChain, mux *event.TypeMux, r io.Reader) (err error) {
+	var progress ChainImportEvent
+	defer func() {
+		progress.Done, progress.Err = true, err
+		mux.Post(progress)
+	}()
+
+	// Decompress the input if it's gzipped
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(len(gzipMagic)); err == nil && string(magic) == string(gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = buffered
+	}
+	stream := rlp.NewStream(r, 0)
+
+	blocks := make([]*types.Block, 0, chainIOBatchSize)
+	for batch := 0; ; batch++ {
+		// Load a batch of blocks from the input, skipping the genesis
+		for len(blocks) < cap(blocks) {
+			block := new(types.Block)
+			if err := stream.Decode(block); err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("block %d: failed to parse: %v", progress.Processed, err)
+			}
+			progress.Processed++
+			if block.NumberU64() > 0 {
+				blocks = append(blocks, block)
+			}
+		}
+		if len(blocks) == 0 {
+			break
+		}
+		// Insert the blocks not yet known and report the progress
+		if missing := missingBlocks(chain, blocks); missing > 0 {
+			if n, err := chain.InsertChain(blocks); err != nil {
+				progress.Imported += uint64(missingBlocks(chain, blocks[:n]))
+				return fmt.Errorf("batch %d: failed to insert: %v", batch, err)
+			}
+			progress.Imported += uint64(missing)
+		}
+		mux.Post(progress)
+		blocks = blocks[:0]
+	}
+	log.Info("Imported blockchain", "processed", progress.Processed, "imported", progress.Imported)
+	return nil
+}
+
+// missingBlocks returns the number of blocks not yet known to the chain.
+func missingBlocks(chain *core.BlockChain, bs []*types.Block) int {
+	missing := 0
+	for _, b := range bs {
+		if !chain.HasBlock(b.Hash()) {
+			missing++
+		}
+	}
+	return missing
+}
@@ -0,0 +1,191 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// newChainIOTestChain creates a blockchain over a fresh database with the given
+// number of empty blocks inserted on top of the test genesis.
+func newChainIOTestChain(t *testing.T, length int) *core.BlockChain {
+	var (
+		db, _ = ethdb.NewMemDatabase()
+		gspec = &core.Genesis{Config: params.TestChainConfig}
+	)
+	genesis := gspec.MustCommit(db)
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	if length > 0 {
+		gendb, _ := ethdb.NewMemDatabase()
+		gspec.MustCommit(gendb)
+		blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, length, nil)
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("failed to import chain: %v", err)
+		}
+	}
+	return chain
+}
+
+// collectEvents gathers the events of the given types posted on a mux until the
+// returned function is called, which returns them.
+func collectEvents(mux *event.TypeMux, types ...interface{}) func() []interface{} {
+	var (
+		sub    = mux.Subscribe(types...)
+		events []interface{}
+		done   = make(chan struct{})
+	)
+	go func() {
+		for ev := range sub.Chan() {
+			events = append(events, ev.Data)
+		}
+		close(done)
+	}()
+	return func() []interface{} {
+		sub.Unsubscribe()
+		<-done
+		return events
+	}
+}
+
+// Tests that a chain range can be exported, both plain and compressed, and is
+// imported again with progress reported along the way.
+func TestChainExportImport(t *testing.T) {
+	length := chainIOBatchSize + 100
+	source := newChainIOTestChain(t, length)
+
+	for _, compress := range []bool{false, true} {
+		// Export the chain without the genesis
+		var (
+			buf    = new(bytes.Buffer)
+			mux    = new(event.TypeMux)
+			events = collectEvents(mux, ChainExportEvent{})
+		)
+		if compress {
+			gz := gzip.NewWriter(buf)
+			if err := exportChain(source, mux, gz, 1, uint64(length)); err != nil {
+				t.Fatalf("compressed export failed: %v", err)
+			}
+			gz.Close()
+		} else if err := exportChain(source, mux, buf, 1, uint64(length)); err != nil {
+			t.Fatalf("export failed: %v", err)
+		}
+		exports := events()
+		if len(exports) != 2 {
+			t.Fatalf("compress %v: export event count mismatch: have %d, want 2", compress, len(exports))
+		}
+		if ev := exports[0].(ChainExportEvent); ev.Done || ev.Exported != chainIOBatchSize {
+			t.Errorf("compress %v: progress event mismatch: %+v", compress, ev)
+		}
+		if ev := exports[1].(ChainExportEvent); !ev.Done || ev.Err != nil || ev.Exported != uint64(length) {
+			t.Errorf("compress %v: final event mismatch: %+v", compress, ev)
+		}
+		// Import the chain into a fresh one and check the head
+		target := newChainIOTestChain(t, 0)
+		events = collectEvents(mux, ChainImportEvent{})
+		if err := importChain(target, mux, buf); err != nil {
+			t.Fatalf("compress %v: import failed: %v", compress, err)
+		}
+		if head := target.CurrentBlock(); head.Hash() != source.CurrentBlock().Hash() {
+			t.Errorf("compress %v: head mismatch: have #%d, want #%d", compress, head.NumberU64(), length)
+		}
+		imports := events()
+		if len(imports) != 3 {
+			t.Fatalf("compress %v: import event count mismatch: have %d, want 3", compress, len(imports))
+		}
+		if ev := imports[2].(ChainImportEvent); !ev.Done || ev.Err != nil || ev.Processed != uint64(length) || ev.Imported != uint64(length) {
+			t.Errorf("compress %v: final event mismatch: %+v", compress, ev)
+		}
+	}
+}
+
+// Tests that exports of invalid ranges are rejected, and that re-importing known
+// blocks skips inserting them.
+func TestChainExportImportLimits(t *testing.T) {
+	chain := newChainIOTestChain(t, 10)
+	mux := new(event.TypeMux)
+
+	if err := exportChain(chain, mux, new(bytes.Buffer), 5, 4); err == nil {
+		t.Errorf("inverted range exported")
+	}
+	if err := exportChain(chain, mux, new(bytes.Buffer), 0, 11); err == nil {
+		t.Errorf("range beyond the head exported")
+	}
+	buf := new(bytes.Buffer)
+	if err := exportChain(chain, mux, buf, 0, 10); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	events := collectEvents(mux, ChainImportEvent{})
+	if err := importChain(chain, mux, buf); err != nil {
+		t.Fatalf("re-import failed: %v", err)
+	}
+	imports := events()
+	if ev := imports[len(imports)-1].(ChainImportEvent); ev.Processed != 11 || ev.Imported != 0 {
+		t.Errorf("re-import progress mismatch: have %+v, want 11 processed, 0 imported", ev)
+	}
+	// Import into a chain knowing part of the batch, only the new blocks counting
+	partial := newChainIOTestChain(t, 4)
+	buf.Reset()
+	if err := exportChain(chain, mux, buf, 0, 10); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	events = collectEvents(mux, ChainImportEvent{})
+	if err := importChain(partial, mux, buf); err != nil {
+		t.Fatalf("partial import failed: %v", err)
+	}
+	imports = events()
+	if ev := imports[len(imports)-1].(ChainImportEvent); ev.Processed != 11 || ev.Imported != 6 {
+		t.Errorf("partial import progress mismatch: have %+v, want 11 processed, 6 imported", ev)
+	}
+}
+
+// Tests that the admin API rejects invalid export ranges without truncating an
+// existing export file.
+func TestAdminExportChainInvalidRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "export.rlp")
+	if err := ioutil.WriteFile(file, []byte("previous export"), 0600); err != nil {
+		t.Fatalf("failed to write previous export: %v", err)
+	}
+	api := NewPrivateAdminAPI(&Ethereum{blockchain: newChainIOTestChain(t, 10), eventMux: new(event.TypeMux)})
+
+	for _, bounds := range [][2]uint64{{5, 4}, {0, 11}} {
+		first, last := bounds[0], bounds[1]
+		if _, err := api.ExportChain(file, &first, &last); err == nil {
+			t.Errorf("range %d..%d exported", first, last)
+		}
+		if blob, _ := ioutil.ReadFile(file); string(blob) != "previous export" {
+			t.Errorf("range %d..%d: export file clobbered: %q", first, last, blob)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+const (
+	chainStatsSectionSize    = 256              // Number of blocks aggregated per indexed section
+	chainStatsConfirmations  = 256              // Blocks a section must be buried under before being indexed
+	chainStatsStepInterval   = 10 * time.Second // Time to wait between two indexing steps
+	chainStatsStepLimit      = 64               // Maximum number of sections to index per step
+	chainStatsUnindexedLimit = 4 * chainStatsSectionSize
+)
+
+var (
+	chainStatsPrefix      = []byte("ChainStats-")        // chainStatsPrefix + section (uint64 big endian) -> chainStatsSection
+	chainStatsProgressKey = []byte("ChainStatsProgress") // Number of sections indexed
+
+	errChainStatsRange     = errors.New("invalid block range")
+	errChainStatsUnindexed = errors.New("block range not indexed yet, narrow it down")
+)
+
+// chainStatsSection is the aggregate of the statistics of a range of blocks.
+type chainStatsSection struct {
+	Head       common.Hash // Hash of the last block of the section, detecting reorgs
+	Difficulty *big.Int
+	GasUsed    *big.Int
+	GasLimit   *big.Int
+	Txs        uint64
+	Uncles     uint64
+	Issued     *big.Int // Ether minted by the blocks of the section
+	Supply     *big.Int // Total ether supply after the last block (indexed sections only)
+}
+
+func newChainStatsSection() *chainStatsSection {
+	return &chainStatsSection{Difficulty: new(big.Int), GasUsed: new(big.Int), GasLimit: new(big.Int), Issued: new(big.Int), Supply: new(big.Int)}
+}
+
+// merge adds the statistics of another section to this one.
+func (s *chainStatsSection) merge(other *chainStatsSection) {
+	s.Difficulty.Add(s.Difficulty, other.Difficulty)
+	s.GasUsed.Add(s.GasUsed, other.GasUsed)
+	s.GasLimit.Add(s.GasLimit, other.GasLimit)
+	s.Txs += other.Txs
+	s.Uncles += other.Uncles
+	s.Issued.Add(s.Issued, other.Issued)
+}
+
+// addBlock adds the statistics of the canonical block with the given number.
+func (s *chainStatsSection) addBlock(db ethdb.Database, number uint64) error {
+	hash := core.GetCanonicalHash(db, number)
+	header := core.GetHeader(db, hash, number)
+	body := core.GetBody(db, hash, number)
+	if header == nil || body == nil {
+		return fmt.Errorf("block #%d not available", number)
+	}
+	s.Difficulty.Add(s.Difficulty, header.Difficulty)
+	s.GasUsed.Add(s.GasUsed, header.GasUsed)
+	s.GasLimit.Add(s.GasLimit, header.GasLimit)
+	s.Txs += uint64(len(body.Transactions))
+	s.Uncles += uint64(len(body.Uncles))
+	if number > 0 {
+		s.Issued.Add(s.Issued, core.BlockIssuance(header, body.Uncles))
+	}
+	s.Head = hash
+	return nil
+}
+
+// ChainStats are aggregate statistics of a range of canonical blocks.
+type ChainStats struct {
+	From            hexutil.Uint64 `json:"from"`
+	To              hexutil.Uint64 `json:"to"`
+	AvgBlockTime    float64        `json:"avgBlockTime"` // Seconds between blocks
+	FirstDifficulty *hexutil.Big   `json:"firstDifficulty"`
+	LastDifficulty  *hexutil.Big   `json:"lastDifficulty"`
+	AvgDifficulty   *hexutil.Big   `json:"avgDifficulty"`
+	GasUsed         *hexutil.Big   `json:"gasUsed"`
+	GasUsedRatio    float64        `json:"gasUsedRatio"` // Gas used over the gas limit
+	Transactions    hexutil.Uint64 `json:"transactions"`
+	Uncles          hexutil.Uint64 `json:"uncles"`
+	UncleRate       float64        `json:"uncleRate"` // Uncles per block
+	Issued          *hexutil.Big   `json:"issued"`    // Ether minted by the blocks
+}
+
+// chainStatsIndexer aggregates the statistics of the canonical chain in sections
+// of fixed size, so statistics over long block ranges can be served without
+// reading every block. Only sections buried deep enough to be safe from reorgs
+// are indexed, and never while syncing. Sections reorged out nonetheless are
+// detected and reindexed.
+//
+// Sections also track the total ether supply: the genesis allocation plus the
+// rewards issued by every block up to the end of the section.
+type chainStatsIndexer struct {
+	chain   *core.BlockChain
+	db      ethdb.Database
+	clock   mclock.Clock
+	syncing func() bool // Reports whether chain synchronisation is running
+
+	sections uint64 // Number of indexed sections (atomic access)
+
+	genesis     *big.Int // Ether allocated in the genesis block, loaded on first use
+	genesisErr  error
+	genesisOnce sync.Once
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newChainStatsIndexer creates a chain statistics indexer for the given chain,
+// resuming from the progress persisted in db.
+func newChainStatsIndexer(chain *core.BlockChain, db ethdb.Database, clock mclock.Clock, syncing func() bool) *chainStatsIndexer {
+	c := &chainStatsIndexer{
+		chain:   chain,
+		db:      db,
+		clock:   clock,
+		syncing: syncing,
+		quit:    make(chan struct{}),
+	}
+	if data, _ := db.Get(chainStatsProgressKey); len(data) == 8 {
+		c.sections = binary.BigEndian.Uint64(data)
+	}
+	return c
+}
+
+// start spins up the indexing loop.
+func (c *chainStatsIndexer) start() {
+	c.wg.Add(1)
+	go c.loop()
+}
+
+// stop terminates the indexing loop, waiting for any running step.
+func (c *chainStatsIndexer) stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// loop runs indexing steps until the indexer is stopped.
+func (c *chainStatsIndexer) loop() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.clock.After(chainStatsStepInterval):
+			c.step()
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// step drops the indexed sections reorged out of the canonical chain, indexes
+// the next confirmed sections and persists the progress made.
+func (c *chainStatsIndexer) step() {
+	if c.syncing() {
+		return
+	}
+	sections := atomic.LoadUint64(&c.sections)
+	start := sections
+
+	for sections > 0 {
+		last := sections*chainStatsSectionSize - 1
+		if section := c.section(sections - 1); section != nil && section.Head == core.GetCanonicalHash(c.db, last) {
+			break
+		}
+		sections--
+	}
+	if sections < start {
+		log.Warn("Reindexing reorged chain statistics", "sections", start-sections)
+	}
+	head := c.chain.CurrentBlock().NumberU64()
+	for indexed := 0; indexed < chainStatsStepLimit; indexed++ {
+		last := (sections+1)*chainStatsSectionSize - 1
+		if last+chainStatsConfirmations > head {
+			break
+		}
+		supply, err := c.supply(sections)
+		if err != nil {
+			log.Debug("Failed to index chain statistics", "section", sections, "err", err)
+			break
+		}
+		section := newChainStatsSection()
+		for number := sections * chainStatsSectionSize; number <= last; number++ {
+			if err := section.addBlock(c.db, number); err != nil {
+				log.Debug("Failed to index chain statistics", "section", sections, "err", err)
+				c.setSections(sections)
+				return
+			}
+		}
+		section.Supply.Add(supply, section.Issued)
+
+		enc, _ := rlp.EncodeToBytes(section)
+		if err := c.db.Put(chainStatsKey(sections), enc); err != nil {
+			log.Warn("Failed to store chain statistics", "section", sections, "err", err)
+			break
+		}
+		sections++
+	}
+	c.setSections(sections)
+}
+
+// setSections updates and persists the number of indexed sections.
+func (c *chainStatsIndexer) setSections(sections uint64) {
+	if sections == atomic.LoadUint64(&c.sections) {
+		return
+	}
+	atomic.StoreUint64(&c.sections, sections)
+
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], sections)
+	if err := c.db.Put(chainStatsProgressKey, enc[:]); err != nil {
+		log.Warn("Failed to store chain statistics progress", "err", err)
+	}
+}
+
+// section retrieves an indexed section from the database.
+func (c *chainStatsIndexer) section(index uint64) *chainStatsSection {
+	data, _ := c.db.Get(chainStatsKey(index))
+	if len(data) == 0 {
+		return nil
+	}
+	section := new(chainStatsSection)
+	if err := rlp.DecodeBytes(data, section); err != nil {
+		return nil
+	}
+	return section
+}
+
+// stats aggregates the statistics of the canonical blocks in the given range,
+// combining the indexed sections with the blocks around them.
+func (c *chainStatsIndexer) stats(from, to uint64) (*ChainStats, error) {
+	if from > to || to > c.chain.CurrentBlock().NumberU64() {
+		return nil, errChainStatsRange
+	}
+	var (
+		total     = newChainStatsSection()
+		sections  = atomic.LoadUint64(&c.sections)
+		unindexed = 0
+	)
+	for number := from; number <= to; {
+		if index := number / chainStatsSectionSize; number%chainStatsSectionSize == 0 && index < sections && number+chainStatsSectionSize-1 <= to {
+			if section := c.section(index); section != nil {
+				total.merge(section)
+				number += chainStatsSectionSize
+				continue
+			}
+		}
+		if unindexed++; unindexed > chainStatsUnindexedLimit {
+			return nil, errChainStatsUnindexed
+		}
+		if err := total.addBlock(c.db, number); err != nil {
+			return nil, err
+		}
+		number++
+	}
+	first := core.GetHeader(c.db, core.GetCanonicalHash(c.db, from), from)
+	last := core.GetHeader(c.db, core.GetCanonicalHash(c.db, to), to)
+	if first == nil || last == nil {
+		return nil, errChainStatsRange
+	}
+	blocks := to - from + 1
+	stats := &ChainStats{
+		From:            hexutil.Uint64(from),
+		To:              hexutil.Uint64(to),
+		FirstDifficulty: (*hexutil.Big)(first.Difficulty),
+		LastDifficulty:  (*hexutil.Big)(last.Difficulty),
+		AvgDifficulty:   (*hexutil.Big)(new(big.Int).Div(total.Difficulty, new(big.Int).SetUint64(blocks))),
+		GasUsed:         (*hexutil.Big)(total.GasUsed),
+		Transactions:    hexutil.Uint64(total.Txs),
+		Uncles:          hexutil.Uint64(total.Uncles),
+		UncleRate:       float64(total.Uncles) / float64(blocks),
+		Issued:          (*hexutil.Big)(total.Issued),
+	}
+	if to > from {
+		stats.AvgBlockTime = float64(new(big.Int).Sub(last.Time, first.Time).Uint64()) / float64(to-from)
+	}
+	if total.GasLimit.Sign() > 0 {
+		used, _ := new(big.Float).SetInt(total.GasUsed).Float64()
+		limit, _ := new(big.Float).SetInt(total.GasLimit).Float64()
+		stats.GasUsedRatio = used / limit
+	}
+	return stats, nil
+}
+
+// supply returns the total ether supply before the first block of the given
+// section, which must either be the first one or follow an indexed section.
+func (c *chainStatsIndexer) supply(section uint64) (*big.Int, error) {
+	if section == 0 {
+		c.genesisOnce.Do(func() {
+			statedb, err := c.chain.StateAt(c.chain.Genesis().Root())
+			if err != nil {
+				c.genesisErr = err
+				return
+			}
+			c.genesis = statedb.TotalBalance()
+		})
+		return c.genesis, c.genesisErr
+	}
+	prev := c.section(section - 1)
+	if prev == nil {
+		return nil, fmt.Errorf("chain statistics section %d missing", section-1)
+	}
+	return prev.Supply, nil
+}
+
+// totalSupply returns the total ether supply after the canonical block with the
+// given number, starting from the last indexed section before it.
+func (c *chainStatsIndexer) totalSupply(number uint64) (*big.Int, error) {
+	if number > c.chain.CurrentBlock().NumberU64() {
+		return nil, errChainStatsRange
+	}
+	section := (number + 1) / chainStatsSectionSize
+	if sections := atomic.LoadUint64(&c.sections); section > sections {
+		section = sections
+	}
+	supply, err := c.supply(section)
+	if err != nil {
+		return nil, err
+	}
+	first := section * chainStatsSectionSize
+	if number+1-first > chainStatsUnindexedLimit {
+		return nil, errChainStatsUnindexed
+	}
+	rest := newChainStatsSection()
+	for n := first; n <= number; n++ {
+		if err := rest.addBlock(c.db, n); err != nil {
+			return nil, err
+		}
+	}
+	return new(big.Int).Add(supply, rest.Issued), nil
+}
+
+// chainStatsKey returns the database key of an indexed section.
+func chainStatsKey(section uint64) []byte {
+	key := make([]byte, len(chainStatsPrefix)+8)
+	copy(key, chainStatsPrefix)
+	binary.BigEndian.PutUint64(key[len(chainStatsPrefix):], section)
+	return key
+}
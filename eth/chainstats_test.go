@@ -0,0 +1,108 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// Tests that the chain statistics served from indexed sections match the ones
+// aggregated block by block, and that reorged sections are dropped.
+func TestChainStatsIndexing(t *testing.T) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	gspec.MustCommit(gendb)
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, 2*chainStatsSectionSize+chainStatsConfirmations+10, func(i int, block *core.BlockGen) {
+		if i%3 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{0x01}, big.NewInt(1000), bigTxGas, nil, nil), signer, testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	indexer := newChainStatsIndexer(chain, db, new(mclock.Simulated), func() bool { return false })
+
+	// Aggregate a range block by block, then again from the index
+	from, to := uint64(100), uint64(2*chainStatsSectionSize+50)
+	want, err := indexer.stats(from, to)
+	if err != nil {
+		t.Fatalf("failed to aggregate unindexed stats: %v", err)
+	}
+	txs := 0
+	for _, block := range blocks[from-1 : to] {
+		txs += len(block.Transactions())
+	}
+	if want.Transactions != hexutil.Uint64(txs) {
+		t.Errorf("transaction count mismatch: have %d, want %d", want.Transactions, txs)
+	}
+	indexer.step()
+	if indexer.sections != 2 {
+		t.Fatalf("indexed section count mismatch: have %d, want 2", indexer.sections)
+	}
+	have, err := indexer.stats(from, to)
+	if err != nil {
+		t.Fatalf("failed to aggregate indexed stats: %v", err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("indexed stats mismatch: have %+v, want %+v", have, want)
+	}
+	// The total supply should match the balances in the state, indexed or not
+	for _, number := range []uint64{0, 1, chainStatsSectionSize - 1, chainStatsSectionSize, to} {
+		supply, err := indexer.totalSupply(number)
+		if err != nil {
+			t.Fatalf("block #%d: failed to retrieve total supply: %v", number, err)
+		}
+		statedb, _ := chain.StateAt(chain.GetBlockByNumber(number).Root())
+		if want := statedb.TotalBalance(); supply.Cmp(want) != 0 {
+			t.Errorf("block #%d: total supply mismatch: have %v, want %v", number, supply, want)
+		}
+	}
+	// Reorging the last section out should drop it from the index
+	core.WriteCanonicalHash(db, common.Hash{0xff}, 2*chainStatsSectionSize-1)
+	indexer.step()
+	if indexer.sections != 1 {
+		t.Errorf("reorged section count mismatch: have %d, want 1", indexer.sections)
+	}
+	// A restarted indexer should resume where it left off
+	if sections := newChainStatsIndexer(chain, db, new(mclock.Simulated), func() bool { return false }).sections; sections != 1 {
+		t.Errorf("resumed section count mismatch: have %d, want 1", sections)
+	}
+}
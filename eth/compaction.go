@@ -0,0 +1,146 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+const (
+	compactionStepInterval = 30 * time.Second       // Time to wait between compacting two key ranges
+	compactionPassInterval = 6 * time.Hour          // Time to rest after compacting the whole key space
+	compactionMaxBackoff   = 32                     // Maximum multiplier of the step interval when throttled
+	compactionRPCLimit     = 100                    // RPC requests per step above which the node isn't idle
+	compactionMinLatency   = 200 * time.Millisecond // Block import latency never considered a slowdown
+)
+
+// compacter is the database operation the compaction scheduler relies on.
+type compacter interface {
+	Compact(start []byte, limit []byte) error
+}
+
+// compactionScheduler gradually compacts the chain database one key range at a
+// time while the node is idle (not syncing and serving few RPC requests), so that
+// compaction debt is paid off in quiet periods instead of piling up until it is
+// forced at an inconvenient time. If block imports slow down while compacting,
+// the scheduler backs off.
+type compactionScheduler struct {
+	db       compacter
+	clock    mclock.Clock
+	syncing  func() bool          // Reports whether chain synchronisation is running
+	requests func() uint64        // Number of RPC requests served so far
+	latency  func() time.Duration // Processing time of the last imported block
+
+	next     int    // First key byte of the next range to compact
+	backoff  int    // Multiplier of the step interval due to import slowdowns
+	served   uint64 // RPC request count at the previous step
+	passTime mclock.AbsTime
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newCompactionScheduler creates a compaction scheduler for db, using the given
+// callbacks to decide whether the node is idle.
+func newCompactionScheduler(db compacter, clock mclock.Clock, syncing func() bool, requests func() uint64, latency func() time.Duration) *compactionScheduler {
+	return &compactionScheduler{
+		db:       db,
+		clock:    clock,
+		syncing:  syncing,
+		requests: requests,
+		latency:  latency,
+		backoff:  1,
+		quit:     make(chan struct{}),
+	}
+}
+
+// start spins up the compaction loop.
+func (s *compactionScheduler) start() {
+	s.served = s.requests()
+	s.passTime = s.clock.Now()
+
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// stop terminates the compaction loop, waiting for any running compaction.
+func (s *compactionScheduler) stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// loop runs compaction steps until the scheduler is stopped.
+func (s *compactionScheduler) loop() {
+	defer s.wg.Done()
+
+	wait := compactionStepInterval
+	for {
+		select {
+		case <-s.clock.After(wait):
+			wait = s.step()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// step compacts the next key range if the node is idle, returning the time to
+// wait before the next step.
+func (s *compactionScheduler) step() time.Duration {
+	// Skip compacting if the node is busy
+	served := s.requests()
+	busy := served-s.served > compactionRPCLimit
+	s.served = served
+
+	if busy || s.syncing() {
+		return compactionStepInterval
+	}
+	// Node idle, compact the next range and note if imports suffered
+	base := s.latency()
+
+	start, limit := []byte{byte(s.next)}, []byte{byte(s.next + 1)}
+	if s.next == 0xff {
+		limit = nil
+	}
+	if err := s.db.Compact(start, limit); err != nil {
+		log.Warn("Database compaction failed", "range", s.next, "err", err)
+		return compactionStepInterval
+	}
+	if latency := s.latency(); latency > compactionMinLatency && latency > 2*base {
+		if s.backoff < compactionMaxBackoff {
+			s.backoff *= 2
+		}
+		log.Debug("Block imports slowed by compaction, throttling", "latency", common.PrettyDuration(latency), "backoff", s.backoff)
+	} else if s.backoff > 1 {
+		s.backoff /= 2
+	}
+	// Move on to the next range, resting after a full pass
+	if s.next++; s.next > 0xff {
+		log.Info("Compacted chain database", "elapsed", common.PrettyDuration(s.clock.Now()-s.passTime))
+
+		s.next = 0
+		s.passTime = s.clock.Now() + mclock.AbsTime(compactionPassInterval)
+		return compactionPassInterval
+	}
+	return compactionStepInterval * time.Duration(s.backoff)
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common/mclock"
+)
+
+// testCompacter records the key ranges it was asked to compact, simulating an
+// import slowdown during compaction if requested.
+type testCompacter struct {
+	ranges  [][2][]byte
+	latency *time.Duration
+	slow    bool
+}
+
+func (c *testCompacter) Compact(start []byte, limit []byte) error {
+	c.ranges = append(c.ranges, [2][]byte{start, limit})
+	if c.slow {
+		*c.latency = time.Second
+	} else {
+		*c.latency = 10 * time.Millisecond
+	}
+	return nil
+}
+
+// Tests that the compaction scheduler only compacts while the node is idle, and
+// that it backs off if block imports slow down meanwhile.
+func TestCompactionScheduling(t *testing.T) {
+	var (
+		syncing  bool
+		requests uint64
+		latency  = 10 * time.Millisecond
+	)
+	db := &testCompacter{latency: &latency}
+	sched := newCompactionScheduler(db, new(mclock.Simulated),
+		func() bool { return syncing },
+		func() uint64 { return requests },
+		func() time.Duration { return latency },
+	)
+	// Busy nodes should not compact
+	syncing = true
+	if wait := sched.step(); wait != compactionStepInterval || len(db.ranges) != 0 {
+		t.Fatalf("syncing node: wait %v, compactions %d, want %v, 0", wait, len(db.ranges), compactionStepInterval)
+	}
+	syncing, requests = false, compactionRPCLimit+1
+	if wait := sched.step(); wait != compactionStepInterval || len(db.ranges) != 0 {
+		t.Fatalf("loaded node: wait %v, compactions %d, want %v, 0", wait, len(db.ranges), compactionStepInterval)
+	}
+	// Idle nodes should compact the key space range by range
+	if wait := sched.step(); wait != compactionStepInterval || len(db.ranges) != 1 {
+		t.Fatalf("idle node: wait %v, compactions %d, want %v, 1", wait, len(db.ranges), compactionStepInterval)
+	}
+	if r := db.ranges[0]; len(r[0]) != 1 || r[0][0] != 0x00 || len(r[1]) != 1 || r[1][0] != 0x01 {
+		t.Fatalf("first range mismatch: have [%x, %x), want [00, 01)", r[0], r[1])
+	}
+	// Slowdowns during compaction should throttle the scheduler
+	db.slow = true
+	if wait := sched.step(); wait != 2*compactionStepInterval {
+		t.Fatalf("slowed imports: wait %v, want %v", wait, 2*compactionStepInterval)
+	}
+	db.slow = false
+	if wait := sched.step(); wait != compactionStepInterval {
+		t.Fatalf("recovered imports: wait %v, want %v", wait, compactionStepInterval)
+	}
+	// Finishing a full pass should rest the scheduler
+	var wait time.Duration
+	for len(db.ranges) < 256 {
+		wait = sched.step()
+	}
+	if wait != compactionPassInterval {
+		t.Fatalf("full pass: wait %v, want %v", wait, compactionPassInterval)
+	}
+	if r := db.ranges[255]; len(r[0]) != 1 || r[0][0] != 0xff || r[1] != nil {
+		t.Fatalf("last range mismatch: have [%x, %x), want [ff, nil)", r[0], r[1])
+	}
+}
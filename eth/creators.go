@@ -0,0 +1,334 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+const (
+	creatorIndexStepInterval = 3 * time.Second // Time to wait between two indexing steps
+	creatorIndexStepLimit    = 256             // Maximum number of blocks to index per step
+)
+
+var (
+	creatorPrefix           = []byte("ContractCreator-")        // creatorPrefix + address -> contractCreation
+	creatorProgressKey      = []byte("ContractCreatorProgress") // First block not yet indexed
+	errCreatorIndexDisabled = errors.New("contract creator index not enabled")
+)
+
+// contractCreation is the index entry of a contract, locating its creation.
+type contractCreation struct {
+	Creator     common.Address // Sender of the transaction or contract running CREATE
+	TxHash      common.Hash
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Internal    bool // Whether the contract was created by another contract
+}
+
+// ContractCreation locates the creation of a contract.
+type ContractCreation struct {
+	Address         common.ChecksumAddress `json:"address"`
+	Creator         common.ChecksumAddress `json:"creator"`
+	TransactionHash common.Hash            `json:"transactionHash"`
+	BlockHash       common.Hash            `json:"blockHash"`
+	BlockNumber     hexutil.Uint64         `json:"blockNumber"`
+	Internal        bool                   `json:"internal"`
+}
+
+// creationTracer is a vm.Tracer collecting the contracts created by the CREATE
+// instructions of a transaction. A creation is only collected once the calling
+// frame resumes with the new address on its stack, so failed creations are
+// skipped.
+type creationTracer struct {
+	pending []creationFrame // CREATE instructions awaiting their result
+	created []creationFrame // Successful creations, in execution order
+}
+
+// creationFrame is a CREATE instruction executed at a given call depth.
+type creationFrame struct {
+	depth   int
+	creator common.Address
+	address common.Address
+}
+
+// CaptureState implements vm.Tracer.
+func (t *creationTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	// Resolve the creation the frame was waiting on once it resumes
+	for len(t.pending) > 0 {
+		last := t.pending[len(t.pending)-1]
+		if depth > last.depth {
+			break
+		}
+		t.pending = t.pending[:len(t.pending)-1]
+		if depth == last.depth && len(stack.Data()) > 0 && common.BigToAddress(stack.Back(0)) == last.address {
+			t.created = append(t.created, last)
+		}
+	}
+	// Track new creations, deriving the address the same way the EVM does
+	if (op == vm.CREATE || op == vm.CREATE2) && err == nil {
+		creator := contract.Address()
+		t.pending = append(t.pending, creationFrame{
+			depth:   depth,
+			creator: creator,
+			address: creationAddress(env, op, creator, memory, stack),
+		})
+	}
+	return nil
+}
+
+// creationAddress returns the address of the contract a CREATE or CREATE2 about
+// to be executed by creator will deploy.
+func creationAddress(env *vm.EVM, op vm.OpCode, creator common.Address, memory *vm.Memory, stack *vm.Stack) common.Address {
+	if op == vm.CREATE2 {
+		code := memory.Get(stack.Back(1).Int64(), stack.Back(2).Int64())
+		return crypto.CreateAddress2(creator, common.BigToHash(stack.Back(3)), crypto.Keccak256(code))
+	}
+	return crypto.CreateAddress(creator, env.StateDB.GetNonce(creator))
+}
+
+// contractCreatorIndexer maps contract addresses to the transaction and block
+// that created them, so the creator of a contract can be looked up without
+// tracing the entire chain. Top level creations are read from the receipts,
+// while the ones made by other contracts are found by replaying the blocks with
+// a tracer. Blocks whose parent state is unavailable (e.g. fast synced) are
+// indexed from their receipts only.
+//
+// Only blocks buried under the confirmations of the chain statistics index are
+// indexed, and entries of blocks reorged out nonetheless are hidden on lookup.
+type contractCreatorIndexer struct {
+	chain   *core.BlockChain
+	db      ethdb.Database
+	config  *params.ChainConfig
+	clock   mclock.Clock
+	syncing func() bool // Reports whether chain synchronisation is running
+
+	next uint64 // First block not yet indexed
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newContractCreatorIndexer creates a contract creator indexer for the given
+// chain, resuming from the progress persisted in db.
+func newContractCreatorIndexer(chain *core.BlockChain, db ethdb.Database, config *params.ChainConfig, clock mclock.Clock, syncing func() bool) *contractCreatorIndexer {
+	c := &contractCreatorIndexer{
+		chain:   chain,
+		db:      db,
+		config:  config,
+		clock:   clock,
+		syncing: syncing,
+		next:    1,
+		quit:    make(chan struct{}),
+	}
+	if data, _ := db.Get(creatorProgressKey); len(data) == 8 {
+		c.next = binary.BigEndian.Uint64(data)
+	}
+	return c
+}
+
+// indexed returns the range of blocks whose contract creations are indexed, as
+// last persisted (not racing with the indexing loop). The genesis block has no
+// transactions to index, so the range always starts with it.
+func (c *contractCreatorIndexer) indexed() (from, to uint64) {
+	next := uint64(1)
+	if data, _ := c.db.Get(creatorProgressKey); len(data) == 8 {
+		next = binary.BigEndian.Uint64(data)
+	}
+	return 0, next - 1
+}
+
+// start spins up the indexing loop.
+func (c *contractCreatorIndexer) start() {
+	c.wg.Add(1)
+	go c.loop()
+}
+
+// stop terminates the indexing loop, waiting for any running step.
+func (c *contractCreatorIndexer) stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// loop keeps indexing the confirmed blocks of the chain.
+func (c *contractCreatorIndexer) loop() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.clock.After(creatorIndexStepInterval):
+			c.step()
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// step indexes the next range of confirmed canonical blocks and persists the
+// progress made.
+func (c *contractCreatorIndexer) step() {
+	if c.syncing() {
+		return
+	}
+	head := c.chain.CurrentBlock().NumberU64()
+	if head < chainStatsConfirmations {
+		return
+	}
+	batch := c.db.NewBatch()
+	for start := c.next; c.next <= head-chainStatsConfirmations && c.next-start < creatorIndexStepLimit; c.next++ {
+		block := c.chain.GetBlockByNumber(c.next)
+		if block == nil {
+			break
+		}
+		if err := c.index(batch, block); err != nil {
+			log.Debug("Failed to index contract creations", "number", block.Number(), "hash", block.Hash(), "err", err)
+			break
+		}
+	}
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], c.next)
+	batch.Put(creatorProgressKey, enc[:])
+	if err := batch.Write(); err != nil {
+		log.Warn("Failed to store contract creations", "err", err)
+	}
+}
+
+// index adds the contracts created by a block to the batch.
+func (c *contractCreatorIndexer) index(batch ethdb.Batch, block *types.Block) error {
+	if len(block.Transactions()) == 0 {
+		return nil
+	}
+	put := func(address common.Address, creation *contractCreation) {
+		enc, _ := rlp.EncodeToBytes(creation)
+		batch.Put(creatorKey(address), enc)
+	}
+	parent := c.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return errors.New("parent block not available")
+	}
+	statedb, err := c.chain.StateAt(parent.Root())
+	if err != nil {
+		return c.indexReceipts(block, put)
+	}
+	signer := types.MakeSigner(c.config, block.Number())
+	return replayBlock(c.chain, c.config, block, statedb, func() vm.Tracer { return new(creationTracer) }, func(tx *types.Transaction, receipt *types.Receipt, tracer vm.Tracer) {
+		// Creations reverted by a failing enclosing call leave no account behind
+		if tx.To() == nil && statedb.Exist(receipt.ContractAddress) {
+			from, _ := types.Sender(signer, tx)
+			put(receipt.ContractAddress, &contractCreation{Creator: from, TxHash: tx.Hash(), BlockHash: block.Hash(), BlockNumber: block.NumberU64()})
+		}
+		for _, created := range tracer.(*creationTracer).created {
+			if statedb.Exist(created.address) {
+				put(created.address, &contractCreation{Creator: created.creator, TxHash: tx.Hash(), BlockHash: block.Hash(), BlockNumber: block.NumberU64(), Internal: true})
+			}
+		}
+	})
+}
+
+// replayBlock re-executes the transactions of a block on the state of its parent,
+// tracing each of them with a fresh tracer. The receipt and the tracer of every
+// transaction are passed to done once it's applied.
+func replayBlock(chain *core.BlockChain, config *params.ChainConfig, block *types.Block, statedb *state.StateDB, newTracer func() vm.Tracer, done func(tx *types.Transaction, receipt *types.Receipt, tracer vm.Tracer)) error {
+	var (
+		header  = block.Header()
+		gp      = new(core.GasPool).AddGas(block.GasLimit())
+		usedGas = new(big.Int)
+	)
+	if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		core.ApplyDAOHardFork(statedb)
+	}
+	for i, tx := range block.Transactions() {
+		tracer := newTracer()
+		statedb.StartRecord(tx.Hash(), block.Hash(), i)
+
+		receipt, _, err := core.ApplyTransaction(config, chain, nil, gp, statedb, header, tx, usedGas, vm.Config{Debug: true, Tracer: tracer})
+		if err != nil {
+			return err
+		}
+		done(tx, receipt, tracer)
+	}
+	return nil
+}
+
+// indexReceipts adds the top level contract creations of a block whose state
+// is not available to the batch, as recorded by its receipts.
+func (c *contractCreatorIndexer) indexReceipts(block *types.Block, put func(common.Address, *contractCreation)) error {
+	receipts := core.GetBlockReceipts(c.db, block.Hash(), block.NumberU64())
+	if len(receipts) != len(block.Transactions()) {
+		return errors.New("receipts not available")
+	}
+	signer := types.MakeSigner(c.config, block.Number())
+	for i, tx := range block.Transactions() {
+		if tx.To() != nil {
+			continue
+		}
+		from, _ := types.Sender(signer, tx)
+		put(receipts[i].ContractAddress, &contractCreation{Creator: from, TxHash: tx.Hash(), BlockHash: block.Hash(), BlockNumber: block.NumberU64()})
+	}
+	return nil
+}
+
+// creatorKey returns the database key of the creation of a contract.
+func creatorKey(address common.Address) []byte {
+	key := make([]byte, len(creatorPrefix)+common.AddressLength)
+	copy(key, creatorPrefix)
+	copy(key[len(creatorPrefix):], address[:])
+	return key
+}
+
+// creation looks up the creation of a contract, returning nil if the contract
+// is not indexed or was created by a block since reorged out.
+func (c *contractCreatorIndexer) creation(address common.Address) *ContractCreation {
+	data, _ := c.db.Get(creatorKey(address))
+	if len(data) == 0 {
+		return nil
+	}
+	creation := new(contractCreation)
+	if err := rlp.DecodeBytes(data, creation); err != nil {
+		log.Error("Invalid contract creation RLP", "address", address, "err", err)
+		return nil
+	}
+	if core.GetCanonicalHash(c.db, creation.BlockNumber) != creation.BlockHash {
+		return nil
+	}
+	return &ContractCreation{
+		Address:         common.ChecksumAddress(address),
+		Creator:         common.ChecksumAddress(creation.Creator),
+		TransactionHash: creation.TxHash,
+		BlockHash:       creation.BlockHash,
+		BlockNumber:     hexutil.Uint64(creation.BlockNumber),
+		Internal:        creation.Internal,
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// deployCode wraps runtime code into init code returning it.
+func deployCode(runtime []byte) []byte {
+	return append([]byte{0x60, byte(len(runtime)), 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, byte(len(runtime)), 0x60, 0x00, 0xf3}, runtime...)
+}
+
+// Tests that both top level and contract created contracts are indexed, while
+// creations reverted by a failing call are not.
+func TestContractCreatorIndexing(t *testing.T) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+
+		// Factories creating an empty contract when called, the second failing afterwards
+		factory = deployCode([]byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0xf0, 0x50, 0x00})
+		failing = deployCode([]byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0xf0, 0x50, 0xfe})
+
+		factoryAddr = crypto.CreateAddress(testBank, 0)
+		failingAddr = crypto.CreateAddress(testBank, 1)
+		childAddr   = crypto.CreateAddress(factoryAddr, 1)
+		orphanAddr  = crypto.CreateAddress(failingAddr, 1)
+		gas         = big.NewInt(200000)
+	)
+	gspec.MustCommit(gendb)
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, chainStatsConfirmations+8, func(i int, block *core.BlockGen) {
+		switch i {
+		case 0:
+			for _, code := range [][]byte{factory, failing} {
+				tx, _ := types.SignTx(types.NewContractCreation(block.TxNonce(testBank), new(big.Int), gas, nil, code), signer, testBankKey)
+				block.AddTx(tx)
+			}
+		case 1:
+			for _, addr := range []common.Address{factoryAddr, failingAddr} {
+				tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), addr, new(big.Int), gas, nil, nil), signer, testBankKey)
+				block.AddTx(tx)
+			}
+		}
+	})
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	indexer := newContractCreatorIndexer(chain, db, gspec.Config, new(mclock.Simulated), func() bool { return false })
+	indexer.step()
+
+	if want := uint64(len(blocks)) - chainStatsConfirmations + 1; indexer.next != want {
+		t.Fatalf("indexing progress mismatch: have %d, want %d", indexer.next, want)
+	}
+	tests := []struct {
+		address  common.Address
+		creator  common.Address
+		tx       common.Hash
+		internal bool
+	}{
+		{factoryAddr, testBank, blocks[0].Transactions()[0].Hash(), false},
+		{failingAddr, testBank, blocks[0].Transactions()[1].Hash(), false},
+		{childAddr, factoryAddr, blocks[1].Transactions()[0].Hash(), true},
+	}
+	for i, tt := range tests {
+		creation := indexer.creation(tt.address)
+		if creation == nil {
+			t.Errorf("test %d: creation of %x not indexed", i, tt.address)
+			continue
+		}
+		if creation.Creator != common.ChecksumAddress(tt.creator) || creation.TransactionHash != tt.tx || creation.Internal != tt.internal {
+			t.Errorf("test %d: creation mismatch: have %x/%x/%v, want %x/%x/%v", i, creation.Creator, creation.TransactionHash, creation.Internal, tt.creator, tt.tx, tt.internal)
+		}
+	}
+	if creation := indexer.creation(orphanAddr); creation != nil {
+		t.Errorf("reverted creation indexed: %+v", creation)
+	}
+	// Ensure the progress is persisted across restarts
+	if next := newContractCreatorIndexer(chain, db, gspec.Config, new(mclock.Simulated), func() bool { return false }).next; next != indexer.next {
+		t.Errorf("persisted progress mismatch: have %d, want %d", next, indexer.next)
+	}
+}
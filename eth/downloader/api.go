@@ -19,12 +19,17 @@ package downloader
 import (
 	"context"
 	"sync"
+	"time"
 
 	ethereum "github.com/expanse-org/go-expanse"
 	"github.com/expanse-org/go-expanse/event"
 	"github.com/expanse-org/go-expanse/rpc"
 )
 
+// syncProgressInterval is the interval at which sync progress is pushed to the
+// syncing subscriptions while a synchronisation is running.
+var syncProgressInterval = 3 * time.Second
+
 // PublicDownloaderAPI provides an API which gives information about the current synchronisation status.
 // It offers only methods that operates on data that can be available to anyone without security risks.
 type PublicDownloaderAPI struct {
@@ -40,8 +45,8 @@ type PublicDownloaderAPI struct {
 // installSyncSubscription channel.
 func NewPublicDownloaderAPI(d *Downloader, m *event.TypeMux) *PublicDownloaderAPI {
 	api := &PublicDownloaderAPI{
-		d:   d,
-		mux: m,
+		d:                         d,
+		mux:                       m,
 		installSyncSubscription:   make(chan chan interface{}),
 		uninstallSyncSubscription: make(chan *uninstallSyncSubscriptionRequest),
 	}
@@ -53,43 +58,76 @@ func NewPublicDownloaderAPI(d *Downloader, m *event.TypeMux) *PublicDownloaderAP
 
 // eventLoop runs an loop until the event mux closes. It will install and uninstall new
 // sync subscriptions and broadcasts sync status updates to the installed sync subscriptions.
+// While a synchronisation is running, the progress is periodically broadcast too.
 func (api *PublicDownloaderAPI) eventLoop() {
 	var (
 		sub               = api.mux.Subscribe(StartEvent{}, DoneEvent{}, FailedEvent{})
 		syncSubscriptions = make(map[chan interface{}]struct{})
+
+		syncing  bool                  // whether a synchronisation is running
+		last     ethereum.SyncProgress // last progress broadcast while syncing
+		ticker   *time.Ticker          // progress report ticker, only set while syncing
+		progress <-chan time.Time
 	)
+	broadcast := func(notification interface{}) {
+		for c := range syncSubscriptions {
+			c <- notification
+		}
+	}
+	stopTicker := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, progress = nil, nil
+		}
+	}
+	defer stopTicker()
 
 	for {
 		select {
 		case i := <-api.installSyncSubscription:
 			syncSubscriptions[i] = struct{}{}
+
+			// Bring subscriptions installed mid-sync up to date
+			if syncing {
+				i <- &SyncingResult{Syncing: true, Status: last}
+			}
 		case u := <-api.uninstallSyncSubscription:
 			delete(syncSubscriptions, u.c)
 			close(u.uninstalled)
+		case <-progress:
+			// Only report progress if the synchronisation advanced
+			if status := api.d.Progress(); status != last {
+				last = status
+				broadcast(&SyncingResult{Syncing: true, Status: status})
+			}
 		case event := <-sub.Chan():
 			if event == nil {
 				return
 			}
-
-			var notification interface{}
-			switch event.Data.(type) {
+			switch ev := event.Data.(type) {
 			case StartEvent:
-				notification = &SyncingResult{
-					Syncing: true,
-					Status:  api.d.Progress(),
-				}
-			case DoneEvent, FailedEvent:
-				notification = false
-			}
-			// broadcast
-			for c := range syncSubscriptions {
-				c <- notification
+				syncing, last = true, api.d.Progress()
+
+				stopTicker()
+				ticker = time.NewTicker(syncProgressInterval)
+				progress = ticker.C
+
+				broadcast(&SyncingResult{Syncing: true, Status: last})
+			case DoneEvent:
+				syncing = false
+				stopTicker()
+				broadcast(&SyncingResult{Syncing: false, Status: api.d.Progress()})
+			case FailedEvent:
+				syncing = false
+				stopTicker()
+				broadcast(&SyncingResult{Syncing: false, Status: api.d.Progress(), Error: ev.Err.Error()})
 			}
 		}
 	}
 }
 
 // Syncing provides information when this nodes starts synchronising with the Ethereum network and when it's finished.
+// While synchronising, the progress of the sync is pushed whenever it advances.
 func (api *PublicDownloaderAPI) Syncing(ctx context.Context) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
@@ -120,9 +158,11 @@ func (api *PublicDownloaderAPI) Syncing(ctx context.Context) (*rpc.Subscription,
 }
 
 // SyncingResult provides information about the current synchronisation status for this node.
+// Error is set if the synchronisation ended by failing.
 type SyncingResult struct {
 	Syncing bool                  `json:"syncing"`
 	Status  ethereum.SyncProgress `json:"status"`
+	Error   string                `json:"error,omitempty"`
 }
 
 // uninstallSyncSubscriptionRequest uninstalles a syncing subscription in the API event loop.
@@ -159,7 +199,8 @@ func (s *SyncStatusSubscription) Unsubscribe() {
 }
 
 // SubscribeSyncStatus creates a subscription that will broadcast new synchronisation updates.
-// The given channel must receive interface values, the result can either
+// The given channel must receive interface values, the results are *SyncingResult
+// values reporting the start, progress and end of synchronisations.
 func (api *PublicDownloaderAPI) SubscribeSyncStatus(status chan interface{}) *SyncStatusSubscription {
 	api.installSyncSubscription <- status
 	return &SyncStatusSubscription{api: api, c: status}
@@ -0,0 +1,81 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Tests that syncing subscriptions are notified of the start, progress and end
+// of synchronisations, and that late subscriptions are brought up to date.
+func TestSyncStatusSubscription(t *testing.T) {
+	defer func(old time.Duration) { syncProgressInterval = old }(syncProgressInterval)
+	syncProgressInterval = 10 * time.Millisecond
+
+	tester := newTester()
+	defer tester.terminate()
+
+	api := NewPublicDownloaderAPI(tester.downloader, tester.downloader.mux)
+
+	statuses := make(chan interface{})
+	sub := api.SubscribeSyncStatus(statuses)
+	defer sub.Unsubscribe()
+
+	next := func(ch chan interface{}) *SyncingResult {
+		select {
+		case status := <-ch:
+			return status.(*SyncingResult)
+		case <-time.After(time.Second):
+			t.Fatalf("no sync status received")
+		}
+		return nil
+	}
+	tester.downloader.mux.Post(StartEvent{})
+	if status := next(statuses); !status.Syncing {
+		t.Fatalf("sync start not reported: %+v", status)
+	}
+	// Progress must only be pushed once it advances
+	select {
+	case status := <-statuses:
+		t.Fatalf("unchanged progress reported: %+v", status)
+	case <-time.After(5 * syncProgressInterval):
+	}
+	tester.downloader.syncStatsLock.Lock()
+	tester.downloader.syncStatsChainHeight = 100
+	tester.downloader.syncStatsLock.Unlock()
+
+	if status := next(statuses); !status.Syncing || status.Status.HighestBlock != 100 {
+		t.Fatalf("sync progress not reported: %+v", status)
+	}
+	// Subscriptions installed mid-sync must receive the current status
+	late := make(chan interface{})
+	lateSub := api.SubscribeSyncStatus(late)
+	defer lateSub.Unsubscribe()
+
+	if status := next(late); !status.Syncing || status.Status.HighestBlock != 100 {
+		t.Fatalf("late subscription not updated: %+v", status)
+	}
+	// Failures must end the sync, reporting the reason
+	go tester.downloader.mux.Post(FailedEvent{errors.New("sync failed")})
+	for _, ch := range []chan interface{}{statuses, late} {
+		if status := next(ch); status.Syncing || status.Error != "sync failed" {
+			t.Fatalf("sync failure not reported: %+v", status)
+		}
+	}
+}
@@ -99,6 +99,8 @@ type Downloader struct {
 	mode SyncMode       // Synchronisation mode defining the strategy used (per sync cycle)
 	mux  *event.TypeMux // Event multiplexer to announce sync operation events
 
+	stateDb ethdb.Database // Database to restore state snapshots into
+
 	queue *queue   // Scheduler for selecting the hashes to download
 	peers *peerSet // Set of active peers from which download can proceed
 
@@ -129,6 +131,7 @@ type Downloader struct {
 	insertReceipts   receiptChainInsertFn     // Injects a batch of blocks and their receipts into the chain
 	rollback         chainRollbackFn          // Removes a batch of recently added chain links
 	dropPeer         peerDropFn               // Drops a peer for misbehaving
+	storageLow       func() bool              // Reports whether disk space is critically low (nil = never)
 
 	// Status
 	synchroniseMock func(id string, hash common.Hash) error // Replacement for synchronise during testing
@@ -136,15 +139,17 @@ type Downloader struct {
 	notified        int32
 
 	// Channels
-	newPeerCh     chan *peer
-	headerCh      chan dataPack        // [eth/62] Channel receiving inbound block headers
-	bodyCh        chan dataPack        // [eth/62] Channel receiving inbound block bodies
-	receiptCh     chan dataPack        // [eth/63] Channel receiving inbound receipts
-	stateCh       chan dataPack        // [eth/63] Channel receiving inbound node state data
-	bodyWakeCh    chan bool            // [eth/62] Channel to signal the block body fetcher of new tasks
-	receiptWakeCh chan bool            // [eth/63] Channel to signal the receipt fetcher of new tasks
-	stateWakeCh   chan bool            // [eth/63] Channel to signal the state fetcher of new tasks
-	headerProcCh  chan []*types.Header // [eth/62] Channel to feed the header processor new tasks
+	newPeerCh      chan *peer
+	headerCh       chan dataPack        // [eth/62] Channel receiving inbound block headers
+	bodyCh         chan dataPack        // [eth/62] Channel receiving inbound block bodies
+	receiptCh      chan dataPack        // [eth/63] Channel receiving inbound receipts
+	stateCh        chan dataPack        // [eth/63] Channel receiving inbound node state data
+	bodyWakeCh     chan bool            // [eth/62] Channel to signal the block body fetcher of new tasks
+	receiptWakeCh  chan bool            // [eth/63] Channel to signal the receipt fetcher of new tasks
+	stateWakeCh    chan bool            // [eth/63] Channel to signal the state fetcher of new tasks
+	headerProcCh   chan []*types.Header // [eth/62] Channel to feed the header processor new tasks
+	snapManifestCh chan dataPack        // [expsnap/1] Channel receiving inbound state snapshot manifests
+	snapChunkCh    chan dataPack        // [expsnap/1] Channel receiving inbound state snapshot chunks
 
 	// Cancellation and termination
 	cancelPeer string        // Identifier of the peer currently being used as the master (cancel on drop)
@@ -170,6 +175,7 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, hasHeader he
 	dl := &Downloader{
 		mode:             mode,
 		mux:              mux,
+		stateDb:          stateDb,
 		queue:            newQueue(stateDb),
 		peers:            newPeerSet(),
 		rttEstimate:      uint64(rttMaxEstimate),
@@ -197,12 +203,28 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, hasHeader he
 		receiptWakeCh:    make(chan bool, 1),
 		stateWakeCh:      make(chan bool, 1),
 		headerProcCh:     make(chan []*types.Header, 1),
+		snapManifestCh:   make(chan dataPack, 1),
+		snapChunkCh:      make(chan dataPack, 1),
 		quitCh:           make(chan struct{}),
 	}
 	go dl.qosTuner()
 	return dl
 }
 
+// SetStorageGuard sets a callback reporting whether the disk space available to
+// the database is critically low. While it does, no further state entries are
+// requested during fast sync, pausing the write heavy state reassembly.
+func (d *Downloader) SetStorageGuard(low func() bool) {
+	d.storageLow = low
+}
+
+// SetCacheScale scales the number of downloaded but not yet imported blocks the
+// downloader is allowed to buffer, relative to its default limit. It is used to
+// bound memory usage under pressure, with a scale of 1 restoring the default.
+func (d *Downloader) SetCacheScale(scale float64) {
+	d.queue.SetResultSlots(int(float64(blockCacheLimit) * scale))
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -330,7 +352,7 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 		default:
 		}
 	}
-	for _, ch := range []chan dataPack{d.headerCh, d.bodyCh, d.receiptCh, d.stateCh} {
+	for _, ch := range []chan dataPack{d.headerCh, d.bodyCh, d.receiptCh, d.stateCh, d.snapManifestCh, d.snapChunkCh} {
 		for empty := false; !empty; {
 			select {
 			case <-ch:
@@ -356,7 +378,7 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 
 	// Set the requested sync mode, unless it's forbidden
 	d.mode = mode
-	if d.mode == FastSync && atomic.LoadUint32(&d.fsPivotFails) >= fsCriticalTrials {
+	if (d.mode == FastSync || d.mode == SnapshotSync) && atomic.LoadUint32(&d.fsPivotFails) >= fsCriticalTrials {
 		d.mode = FullSync
 	}
 	// Retrieve the origin peer and initiate the downloading process
@@ -408,12 +430,31 @@ func (d *Downloader) syncWithPeer(p *peer, hash common.Hash, td *big.Int) (err e
 
 	// Initiate the sync using a concurrent header and content retrieval algorithm
 	pivot := uint64(0)
+	if d.mode == SnapshotSync {
+		// Restore the state of a recent snapshot and fast sync pivoting on it,
+		// falling back to a plain fast sync if no snapshot can be restored
+		d.mode = FastSync
+		if d.fsPivotLock == nil {
+			manifest, err := d.syncSnapshot(p, origin, height)
+			switch err {
+			case nil:
+				pivot = manifest.Number
+			case errCancelSnapshotFetch:
+				return err
+			default:
+				log.Debug("Snapshot sync unavailable, fast syncing", "err", err)
+			}
+		}
+	}
 	switch d.mode {
 	case LightSync:
 		pivot = height
 	case FastSync:
 		// Calculate the new fast/slow sync pivot point
-		if d.fsPivotLock == nil {
+		if d.fsPivotLock != nil {
+			// Pivot point locked in, use this and do not pick a new one!
+			pivot = d.fsPivotLock.Number.Uint64()
+		} else if pivot == 0 {
 			pivotOffset, err := rand.Int(rand.Reader, big.NewInt(int64(fsPivotInterval)))
 			if err != nil {
 				panic(fmt.Sprintf("Failed to access crypto random source: %v", err))
@@ -421,9 +462,6 @@ func (d *Downloader) syncWithPeer(p *peer, hash common.Hash, td *big.Int) (err e
 			if height > uint64(fsMinFullBlocks)+pivotOffset.Uint64() {
 				pivot = height - uint64(fsMinFullBlocks) - pivotOffset.Uint64()
 			}
-		} else {
-			// Pivot point locked in, use this and do not pick a new one!
-			pivot = d.fsPivotLock.Number.Uint64()
 		}
 		// If the point is below the origin, move origin back to ensure state download
 		if pivot < origin {
@@ -524,6 +562,16 @@ func (d *Downloader) fetchHeight(p *peer) (*types.Header, error) {
 	head, _ := p.currentHead()
 	go p.getRelHeaders(head, 1, 0, false)
 
+	header, err := d.waitHeader(p)
+	if err != nil {
+		return nil, err
+	}
+	p.log.Debug("Remote head header identified", "number", header.Number, "hash", header.Hash())
+	return header, nil
+}
+
+// waitHeader waits for the response to a single header request sent to a peer.
+func (d *Downloader) waitHeader(p *peer) (*types.Header, error) {
 	ttl := d.requestTTL()
 	timeout := time.After(ttl)
 	for {
@@ -543,12 +591,10 @@ func (d *Downloader) fetchHeight(p *peer) (*types.Header, error) {
 				p.log.Debug("Multiple headers for single request", "headers", len(headers))
 				return nil, errBadPeer
 			}
-			head := headers[0]
-			p.log.Debug("Remote head header identified", "number", head.Number, "hash", head.Hash())
-			return head, nil
+			return headers[0], nil
 
 		case <-timeout:
-			p.log.Debug("Waiting for head header timed out", "elapsed", ttl)
+			p.log.Debug("Waiting for header timed out", "elapsed", ttl)
 			return nil, errTimeout
 
 		case <-d.bodyCh:
@@ -973,7 +1019,7 @@ func (d *Downloader) fetchNodeData() error {
 			})
 		}
 		expire   = func() map[string]int { return d.queue.ExpireNodeData(d.requestTTL()) }
-		throttle = func() bool { return false }
+		throttle = func() bool { return d.storageLow != nil && d.storageLow() }
 		reserve  = func(p *peer, count int) (*fetchRequest, bool, error) {
 			return d.queue.ReserveNodeData(p, count), false, nil
 		}
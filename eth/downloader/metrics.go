@@ -42,4 +42,8 @@ var (
 	stateReqTimer     = metrics.NewTimer("eth/downloader/states/req")
 	stateDropMeter    = metrics.NewMeter("eth/downloader/states/drop")
 	stateTimeoutMeter = metrics.NewMeter("eth/downloader/states/timeout")
+
+	snapshotInMeter      = metrics.NewMeter("eth/downloader/snapshots/in")
+	snapshotDropMeter    = metrics.NewMeter("eth/downloader/snapshots/drop")
+	snapshotTimeoutMeter = metrics.NewMeter("eth/downloader/snapshots/timeout")
 )
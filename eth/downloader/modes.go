@@ -20,9 +20,10 @@ package downloader
 type SyncMode int
 
 const (
-	FullSync  SyncMode = iota // Synchronise the entire blockchain history from full blocks
-	FastSync                  // Quickly download the headers, full sync only at the chain head
-	LightSync                 // Download only the headers and terminate afterwards
+	FullSync     SyncMode = iota // Synchronise the entire blockchain history from full blocks
+	FastSync                     // Quickly download the headers, full sync only at the chain head
+	LightSync                    // Download only the headers and terminate afterwards
+	SnapshotSync                 // Restore a recent state snapshot, then fast sync the blocks since
 )
 
 // String implements the stringer interface.
@@ -34,6 +35,8 @@ func (mode SyncMode) String() string {
 		return "fast"
 	case LightSync:
 		return "light"
+	case SnapshotSync:
+		return "snapshot"
 	default:
 		return "unknown"
 	}
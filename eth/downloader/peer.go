@@ -48,6 +48,10 @@ type blockBodyFetcherFn func([]common.Hash) error
 type receiptFetcherFn func([]common.Hash) error
 type stateFetcherFn func([]common.Hash) error
 
+// State snapshot fetchers belonging to expsnap/1 and above
+type snapshotManifestFetcherFn func() error
+type snapshotChunkFetcherFn func([]common.Hash) error
+
 var (
 	errAlreadyFetching   = errors.New("already fetching blocks from peer")
 	errAlreadyRegistered = errors.New("peer is already registered")
@@ -86,6 +90,9 @@ type peer struct {
 	getReceipts receiptFetcherFn // [eth/63] Method to retrieve a batch of block transaction receipts
 	getNodeData stateFetcherFn   // [eth/63] Method to retrieve a batch of state trie data
 
+	getSnapshotManifest snapshotManifestFetcherFn // [expsnap/1] Method to retrieve the manifest of the served state snapshot
+	getSnapshotChunks   snapshotChunkFetcherFn    // [expsnap/1] Method to retrieve a batch of state snapshot chunks
+
 	version int        // Eth protocol version number to switch strategies
 	log     log.Logger // Contextual logger to add extra infos to peer logs
 	lock    sync.RWMutex
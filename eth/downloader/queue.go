@@ -105,6 +105,7 @@ type queue struct {
 
 	resultCache  []*fetchResult // Downloaded but not yet delivered fetch results
 	resultOffset uint64         // Offset of the first cached fetch result in the block chain
+	resultSlots  int            // Number of result cache slots allowed to be filled (memory pressure)
 
 	lock   *sync.Mutex
 	active *sync.Cond
@@ -130,6 +131,7 @@ func newQueue(stateDb ethdb.Database) *queue {
 		statePendPool:    make(map[string]*fetchRequest),
 		stateDatabase:    stateDb,
 		resultCache:      make([]*fetchResult, blockCacheLimit),
+		resultSlots:      blockCacheLimit,
 		active:           sync.NewCond(lock),
 		lock:             lock,
 	}
@@ -283,6 +285,22 @@ func (q *queue) FastSyncPivot() uint64 {
 	return q.fastSyncPivot
 }
 
+// SetResultSlots limits the number of result cache slots that may be filled with
+// downloaded block parts, bounding the memory used by the queue. The limit is
+// capped between a single slot and the full cache size.
+func (q *queue) SetResultSlots(slots int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if slots < 1 {
+		slots = 1
+	}
+	if slots > len(q.resultCache) {
+		slots = len(q.resultCache)
+	}
+	q.resultSlots = slots
+}
+
 // ShouldThrottleBlocks checks if the download should be throttled (active block (body)
 // fetches exceed block cache).
 func (q *queue) ShouldThrottleBlocks() bool {
@@ -295,7 +313,7 @@ func (q *queue) ShouldThrottleBlocks() bool {
 		pending += len(request.Hashes) + len(request.Headers)
 	}
 	// Throttle if more blocks (bodies) are in-flight than free space in the cache
-	return pending >= len(q.resultCache)-len(q.blockDonePool)
+	return pending >= q.resultSlots-len(q.blockDonePool)
 }
 
 // ShouldThrottleReceipts checks if the download should be throttled (active receipt
@@ -310,7 +328,7 @@ func (q *queue) ShouldThrottleReceipts() bool {
 		pending += len(request.Headers)
 	}
 	// Throttle if more receipts are in-flight than free space in the cache
-	return pending >= len(q.resultCache)-len(q.receiptDonePool)
+	return pending >= q.resultSlots-len(q.receiptDonePool)
 }
 
 // ScheduleSkeleton adds a batch of header retrieval tasks to the queue to fill
@@ -638,7 +656,7 @@ func (q *queue) reserveHeaders(p *peer, count int, taskPool map[common.Hash]*typ
 		return nil, false, nil
 	}
 	// Calculate an upper limit on the items we might fetch (i.e. throttling)
-	space := len(q.resultCache) - len(donePool)
+	space := q.resultSlots - len(donePool)
 	for _, request := range pendPool {
 		space -= len(request.Headers)
 	}
@@ -0,0 +1,277 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+var (
+	MaxSnapshotChunkFetch = 16 // Amount of state snapshot chunks to allow fetching per request
+
+	snapshotChunkWindow = 256 // Maximum number of chunks to download ahead of the restoration
+)
+
+var (
+	errNoSnapshot          = errors.New("no suitable state snapshot available")
+	errInvalidSnapshot     = errors.New("retrieved state snapshot is invalid")
+	errCancelSnapshotFetch = errors.New("state snapshot download canceled (requested)")
+)
+
+// RegisterSnapshotPeer marks an already registered peer as serving state
+// snapshots, injecting the methods to retrieve them with. Nil methods mark the
+// peer as no longer serving them.
+func (d *Downloader) RegisterSnapshotPeer(id string, getManifest snapshotManifestFetcherFn, getChunks snapshotChunkFetcherFn) error {
+	p := d.peers.Peer(id)
+	if p == nil {
+		return errNotRegistered
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.getSnapshotManifest, p.getSnapshotChunks = getManifest, getChunks
+	return nil
+}
+
+// snapshotFetchers returns the state snapshot retrieval methods of a peer, nil
+// if it doesn't serve snapshots.
+func (p *peer) snapshotFetchers() (snapshotManifestFetcherFn, snapshotChunkFetcherFn) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.getSnapshotManifest, p.getSnapshotChunks
+}
+
+// DeliverSnapshotManifest injects the state snapshot manifest received from a
+// remote node, nil if it doesn't serve any.
+func (d *Downloader) DeliverSnapshotManifest(id string, manifest *state.SnapshotManifest) (err error) {
+	return d.deliver(id, d.snapManifestCh, &snapshotManifestPack{id, manifest}, snapshotInMeter, snapshotDropMeter)
+}
+
+// DeliverSnapshotChunks injects a new batch of state snapshot chunks received
+// from a remote node.
+func (d *Downloader) DeliverSnapshotChunks(id string, chunks [][]byte) (err error) {
+	return d.deliver(id, d.snapChunkCh, &snapshotChunkPack{id, chunks}, snapshotInMeter, snapshotDropMeter)
+}
+
+// syncSnapshot retrieves the state snapshot served by the master peer and, if
+// it is recent enough to pivot the fast sync on, restores it from the chunks
+// served by all the snapshot capable peers. The manifest of the restored
+// snapshot is returned.
+func (d *Downloader) syncSnapshot(p *peer, origin uint64, height uint64) (*state.SnapshotManifest, error) {
+	manifest, err := d.fetchSnapshotManifest(p)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Number <= origin || manifest.Number+uint64(fsMinFullBlocks) > height {
+		return nil, errNoSnapshot
+	}
+	// Make sure the snapshot belongs to the chain of the master peer
+	go p.getAbsHeaders(manifest.Number, 1, 0, false)
+
+	header, err := d.waitHeader(p)
+	if err != nil {
+		return nil, err
+	}
+	if header.Hash() != manifest.Hash || header.Root != manifest.Root {
+		return nil, errInvalidSnapshot
+	}
+	// Skip the download if the state is already present (e.g. failed sync retry)
+	if _, err := d.stateDb.Get(manifest.Root[:]); err == nil {
+		log.Debug("Snapshot state already present", "number", manifest.Number, "root", manifest.Root)
+		return manifest, nil
+	}
+	if err := d.fetchSnapshotChunks(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// fetchSnapshotManifest retrieves the manifest of the state snapshot served by
+// a peer.
+func (d *Downloader) fetchSnapshotManifest(p *peer) (*state.SnapshotManifest, error) {
+	getManifest, _ := p.snapshotFetchers()
+	if getManifest == nil {
+		return nil, errNoSnapshot
+	}
+	p.log.Debug("Retrieving state snapshot manifest")
+	go getManifest()
+
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCancelSnapshotFetch
+
+		case packet := <-d.snapManifestCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received snapshot manifest from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			manifest := packet.(*snapshotManifestPack).manifest
+			if manifest == nil {
+				return nil, errNoSnapshot
+			}
+			p.log.Debug("Remote state snapshot identified", "number", manifest.Number, "hash", manifest.Hash, "chunks", len(manifest.Chunks))
+			return manifest, nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for snapshot manifest timed out", "elapsed", ttl)
+			return nil, errTimeout
+		}
+	}
+}
+
+// snapshotRequest is a batch of state snapshot chunks requested from a peer.
+type snapshotRequest struct {
+	peer    *peer
+	indices []int     // Positions of the requested chunks in the manifest
+	time    time.Time // Time when the request was sent
+}
+
+// fetchSnapshotChunks downloads the chunks of a state snapshot from every peer
+// serving them and restores the state in chunk order. Chunks are checked
+// against the hashes in the manifest, and the restored state against its root.
+func (d *Downloader) fetchSnapshotChunks(manifest *state.SnapshotManifest) error {
+	var (
+		restorer = state.NewSnapshotRestorer(d.stateDb, manifest.Root)
+		pending  = make([]int, len(manifest.Chunks)) // Positions of the chunks to request, ascending
+		active   = make(map[string]*snapshotRequest) // Requests currently in flight, by peer
+		results  = make(map[int][]byte)              // Chunks downloaded but not restored yet
+		stalled  = make(map[string]struct{})         // Peers that timed out or returned junk
+		restored = 0                                 // Number of chunks restored so far
+		start    = time.Now()
+	)
+	for i := range pending {
+		pending[i] = i
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for restored < len(manifest.Chunks) {
+		// Assign the pending chunks to the idle snapshot peers
+		for _, p := range d.peers.AllPeers() {
+			if _, ok := active[p.id]; ok {
+				continue
+			}
+			if _, ok := stalled[p.id]; ok {
+				continue
+			}
+			_, getChunks := p.snapshotFetchers()
+			if getChunks == nil {
+				continue
+			}
+			var (
+				indices []int
+				hashes  []common.Hash
+				rest    = pending[:0]
+			)
+			for _, index := range pending {
+				if len(indices) < MaxSnapshotChunkFetch && index < restored+snapshotChunkWindow && !p.Lacks(manifest.Chunks[index]) {
+					indices = append(indices, index)
+					hashes = append(hashes, manifest.Chunks[index])
+				} else {
+					rest = append(rest, index)
+				}
+			}
+			pending = rest
+			if len(indices) > 0 {
+				active[p.id] = &snapshotRequest{peer: p, indices: indices, time: time.Now()}
+				go getChunks(hashes)
+			}
+		}
+		if len(active) == 0 {
+			return errPeersUnavailable
+		}
+		// Wait for chunks to arrive or requests to time out
+		select {
+		case <-d.cancelCh:
+			return errCancelSnapshotFetch
+
+		case packet := <-d.snapChunkCh:
+			req := active[packet.PeerId()]
+			if req == nil {
+				break // Unrequested or timed out delivery
+			}
+			delete(active, req.peer.id)
+
+			requested := make(map[common.Hash]int)
+			for _, index := range req.indices {
+				requested[manifest.Chunks[index]] = index
+			}
+			for _, chunk := range packet.(*snapshotChunkPack).chunks {
+				hash := crypto.Keccak256Hash(chunk)
+				index, ok := requested[hash]
+				if !ok {
+					req.peer.log.Debug("Peer delivered unrequested snapshot chunk", "hash", hash)
+					stalled[req.peer.id] = struct{}{}
+					break
+				}
+				results[index] = chunk
+				delete(requested, hash)
+			}
+			// Reschedule the chunks not delivered, not asking this peer again
+			for hash, index := range requested {
+				req.peer.MarkLacking(hash)
+				pending = append(pending, index)
+			}
+			sort.Ints(pending)
+
+			// Restore the chunks available in order
+			for chunk, ok := results[restored]; ok; chunk, ok = results[restored] {
+				if err := restorer.Feed(chunk); err != nil {
+					log.Warn("Failed to restore state snapshot", "number", manifest.Number, "chunk", restored, "err", err)
+					return errInvalidSnapshot
+				}
+				delete(results, restored)
+				restored++
+			}
+			log.Debug("Restoring state snapshot", "number", manifest.Number, "restored", restored, "chunks", len(manifest.Chunks))
+
+		case <-ticker.C:
+			// Reschedule the chunks of the timed out requests
+			ttl := d.requestTTL()
+			for id, req := range active {
+				if time.Since(req.time) < ttl {
+					continue
+				}
+				req.peer.log.Debug("Snapshot chunk request timed out", "chunks", len(req.indices))
+				snapshotTimeoutMeter.Mark(1)
+
+				delete(active, id)
+				stalled[id] = struct{}{}
+				pending = append(pending, req.indices...)
+			}
+			sort.Ints(pending)
+		}
+	}
+	if err := restorer.Finish(); err != nil {
+		log.Warn("Failed to restore state snapshot", "number", manifest.Number, "err", err)
+		return errInvalidSnapshot
+	}
+	log.Info("Restored state snapshot", "number", manifest.Number, "hash", manifest.Hash, "chunks", len(manifest.Chunks), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
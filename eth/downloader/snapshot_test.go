@@ -0,0 +1,140 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+)
+
+// makeSnapshot snapshots the peer state of the block with the given number,
+// returning the manifest and the chunks by hash.
+func (dl *downloadTester) makeSnapshot(t *testing.T, hashes []common.Hash, headers map[common.Hash]*types.Header, number int) (*state.SnapshotManifest, map[common.Hash][]byte) {
+	header := headers[hashes[len(hashes)-1-number]]
+
+	blobs := make(map[common.Hash][]byte)
+	chunks, err := state.GenerateSnapshot(dl.peerDb, header.Root, 64, func(hash common.Hash, blob []byte) error {
+		blobs[hash] = blob
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	return &state.SnapshotManifest{Number: header.Number.Uint64(), Hash: header.Hash(), Root: header.Root, Chunks: chunks}, blobs
+}
+
+// registerSnapshotPeer marks a tester peer as serving the given state snapshot,
+// returning a counter of the chunks it delivered.
+func (dl *downloadTester) registerSnapshotPeer(t *testing.T, id string, manifest *state.SnapshotManifest, blobs map[common.Hash][]byte) *int32 {
+	served := new(int32)
+	getManifest := func() error {
+		go dl.downloader.DeliverSnapshotManifest(id, manifest)
+		return nil
+	}
+	getChunks := func(hashes []common.Hash) error {
+		var chunks [][]byte
+		for _, hash := range hashes {
+			if blob, ok := blobs[hash]; ok {
+				chunks = append(chunks, blob)
+			}
+		}
+		atomic.AddInt32(served, int32(len(chunks)))
+		go dl.downloader.DeliverSnapshotChunks(id, chunks)
+		return nil
+	}
+	if err := dl.downloader.RegisterSnapshotPeer(id, getManifest, getChunks); err != nil {
+		t.Fatalf("failed to register snapshot peer: %v", err)
+	}
+	return served
+}
+
+// Tests that snapshot sync restores the state of the snapshot from the chunks
+// served by the peers, pivots the fast sync on it and only downloads the blocks
+// after it fully.
+func TestSnapshotSync(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	targetBlocks := blockCacheLimit - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	number := targetBlocks - fsMinFullBlocks - 10
+	manifest, blobs := tester.makeSnapshot(t, hashes, headers, number)
+	if len(manifest.Chunks) < 2 {
+		t.Fatalf("too few snapshot chunks: %d", len(manifest.Chunks))
+	}
+	// Serve the snapshot from a peer having the chunks and one lacking them all
+	tester.newPeer("peer", 64, hashes, headers, blocks, receipts)
+	tester.newPeer("lacking", 64, hashes, headers, blocks, receipts)
+
+	served := tester.registerSnapshotPeer(t, "peer", manifest, blobs)
+	tester.registerSnapshotPeer(t, "lacking", manifest, nil)
+
+	if err := tester.sync("peer", nil, SnapshotSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if n := atomic.LoadInt32(served); int(n) != len(manifest.Chunks) {
+		t.Errorf("served chunk count mismatch: have %d, want %d", n, len(manifest.Chunks))
+	}
+	if pivot := tester.downloader.queue.fastSyncPivot; pivot != uint64(number) {
+		t.Errorf("pivot mismatch: have %d, want %d", pivot, number)
+	}
+	if _, err := state.New(manifest.Root, tester.stateDb); err != nil {
+		t.Fatalf("snapshot state not restored: %v", err)
+	}
+	if rs := len(tester.ownReceipts); rs != number+1 {
+		t.Errorf("synchronised receipts mismatch: have %d, want %d", rs, number+1)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+}
+
+// Tests that snapshot sync falls back to plain fast sync if no snapshot is served
+// at all, or the one served is too recent to pivot on.
+func TestSnapshotSyncFallback(t *testing.T) {
+	t.Parallel()
+
+	for _, serve := range []bool{false, true} {
+		tester := newTester()
+		defer tester.terminate()
+
+		targetBlocks := blockCacheLimit - 15
+		hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+		tester.newPeer("peer", 64, hashes, headers, blocks, receipts)
+
+		served := new(int32)
+		if serve {
+			manifest, blobs := tester.makeSnapshot(t, hashes, headers, targetBlocks-fsMinFullBlocks/2)
+			served = tester.registerSnapshotPeer(t, "peer", manifest, blobs)
+		}
+		if err := tester.sync("peer", nil, SnapshotSync); err != nil {
+			t.Fatalf("serving %v: failed to synchronise blocks: %v", serve, err)
+		}
+		if n := atomic.LoadInt32(served); n != 0 {
+			t.Errorf("serving %v: chunks of unsuitable snapshot retrieved: %d", serve, n)
+		}
+		if tester.downloader.mode != FastSync {
+			t.Errorf("serving %v: sync mode mismatch: have %v, want %v", serve, tester.downloader.mode, FastSync)
+		}
+		assertOwnChain(t, tester, targetBlocks+1)
+	}
+}
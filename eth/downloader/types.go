@@ -21,6 +21,7 @@ import (
 	"math/big"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 )
 
@@ -118,3 +119,29 @@ type statePack struct {
 func (p *statePack) PeerId() string { return p.peerId }
 func (p *statePack) Items() int     { return len(p.states) }
 func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
+
+// snapshotManifestPack is the state snapshot manifest returned by a peer, nil
+// if it doesn't serve any.
+type snapshotManifestPack struct {
+	peerId   string
+	manifest *state.SnapshotManifest
+}
+
+func (p *snapshotManifestPack) PeerId() string { return p.peerId }
+func (p *snapshotManifestPack) Items() int {
+	if p.manifest == nil {
+		return 0
+	}
+	return 1
+}
+func (p *snapshotManifestPack) Stats() string { return fmt.Sprintf("%d", p.Items()) }
+
+// snapshotChunkPack is a batch of state snapshot chunks returned by a peer.
+type snapshotChunkPack struct {
+	peerId string
+	chunks [][]byte
+}
+
+func (p *snapshotChunkPack) PeerId() string { return p.peerId }
+func (p *snapshotChunkPack) Items() int     { return len(p.chunks) }
+func (p *snapshotChunkPack) Stats() string  { return fmt.Sprintf("%d", len(p.chunks)) }
@@ -27,9 +27,11 @@ import (
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/core"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/ethdb"
 	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/internal/ethapi"
 	"github.com/expanse-org/go-expanse/rpc"
 )
 
@@ -52,7 +54,6 @@ type filter struct {
 // information related to the Ethereum protocol such als blocks, transactions and logs.
 type PublicFilterAPI struct {
 	backend   Backend
-	useMipMap bool
 	mux       *event.TypeMux
 	quit      chan struct{}
 	chainDb   ethdb.Database
@@ -64,12 +65,11 @@ type PublicFilterAPI struct {
 // NewPublicFilterAPI returns a new PublicFilterAPI instance.
 func NewPublicFilterAPI(backend Backend, lightMode bool) *PublicFilterAPI {
 	api := &PublicFilterAPI{
-		backend:   backend,
-		useMipMap: !lightMode,
-		mux:       backend.EventMux(),
-		chainDb:   backend.ChainDb(),
-		events:    NewEventSystem(backend.EventMux(), backend, lightMode),
-		filters:   make(map[rpc.ID]*filter),
+		backend: backend,
+		mux:     backend.EventMux(),
+		chainDb: backend.ChainDb(),
+		events:  NewEventSystem(backend.EventMux(), backend, lightMode),
+		filters: make(map[rpc.ID]*filter),
 	}
 
 	go api.timeoutLoop()
@@ -166,6 +166,141 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// NewPendingTransactionsFull creates a subscription that is triggered each time
+// a transaction enters the transaction pool, streaming the full transaction in
+// its RPC representation instead of only its hash.
+func (api *PublicFilterAPI) NewPendingTransactionsFull(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		txs := make(chan *types.Transaction)
+		pendingTxSub := api.events.SubscribePendingTxs(txs)
+
+		for {
+			select {
+			case tx := <-txs:
+				notifier.Notify(rpcSub.ID, ethapi.NewRPCPendingTransaction(tx))
+			case <-rpcSub.Err():
+				pendingTxSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				pendingTxSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// droppedTransaction is the notification sent to subscribers for every
+// transaction dropped from the pool.
+type droppedTransaction struct {
+	Hash   common.Hash       `json:"hash"`
+	Reason core.TxDropReason `json:"reason"`
+}
+
+// DroppedTransactions creates a subscription that is triggered each time a
+// transaction is dropped from the transaction pool without being included in
+// a block, reporting the reason it was dropped.
+func (api *PublicFilterAPI) DroppedTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		drops := make(chan core.TxDroppedEvent)
+		droppedTxSub := api.events.SubscribeDroppedTxEvents(drops)
+
+		for {
+			select {
+			case ev := <-drops:
+				notifier.Notify(rpcSub.ID, &droppedTransaction{Hash: ev.Tx.Hash(), Reason: ev.Reason})
+			case <-rpcSub.Err():
+				droppedTxSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				droppedTxSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// chainReorg is the notification sent to subscribers for every reorganisation
+// of the canonical chain.
+type chainReorg struct {
+	OldChain    []common.Hash `json:"oldChain"`
+	NewChain    []common.Hash `json:"newChain"`
+	RevertedTxs []common.Hash `json:"revertedTransactions"`
+	IncludedTxs []common.Hash `json:"includedTransactions"`
+}
+
+// newChainReorg converts a reorg event into its notification.
+func newChainReorg(ev core.ReorgEvent) *chainReorg {
+	reorg := &chainReorg{
+		OldChain:    make([]common.Hash, len(ev.OldChain)),
+		NewChain:    make([]common.Hash, len(ev.NewChain)),
+		RevertedTxs: make([]common.Hash, len(ev.RevertedTxs)),
+		IncludedTxs: make([]common.Hash, len(ev.IncludedTxs)),
+	}
+	for i, block := range ev.OldChain {
+		reorg.OldChain[i] = block.Hash()
+	}
+	for i, block := range ev.NewChain {
+		reorg.NewChain[i] = block.Hash()
+	}
+	for i, tx := range ev.RevertedTxs {
+		reorg.RevertedTxs[i] = tx.Hash()
+	}
+	for i, tx := range ev.IncludedTxs {
+		reorg.IncludedTxs[i] = tx.Hash()
+	}
+	return reorg
+}
+
+// Reorgs creates a subscription that is triggered each time the canonical chain
+// is reorganised, reporting the dropped and the new blocks, as well as the
+// transactions reverted and included by the reorganisation.
+func (api *PublicFilterAPI) Reorgs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		reorgs := make(chan core.ReorgEvent)
+		reorgSub := api.events.SubscribeReorgs(reorgs)
+
+		for {
+			select {
+			case ev := <-reorgs:
+				notifier.Notify(rpcSub.ID, newChainReorg(ev))
+			case <-rpcSub.Err():
+				reorgSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				reorgSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 //
@@ -333,7 +468,7 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 		crit.ToBlock = big.NewInt(rpc.LatestBlockNumber.Int64())
 	}
 
-	filter := New(api.backend, api.useMipMap)
+	filter := New(api.backend)
 	filter.SetBeginBlock(crit.FromBlock.Int64())
 	filter.SetEndBlock(crit.ToBlock.Int64())
 	filter.SetAddresses(crit.Addresses)
@@ -373,7 +508,7 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		return nil, fmt.Errorf("filter not found")
 	}
 
-	filter := New(api.backend, api.useMipMap)
+	filter := New(api.backend)
 	if f.crit.FromBlock != nil {
 		filter.SetBeginBlock(f.crit.FromBlock.Int64())
 	} else {
@@ -446,6 +581,19 @@ func returnLogs(logs []*types.Log) []*types.Log {
 	return logs
 }
 
+// BlockRange implements rpc.BlockRanger, reporting the blocks spanned by the
+// criteria, unset bounds defaulting to the latest block.
+func (args FilterCriteria) BlockRange() (from, to rpc.BlockNumber) {
+	from, to = rpc.LatestBlockNumber, rpc.LatestBlockNumber
+	if args.FromBlock != nil {
+		from = rpc.BlockNumber(args.FromBlock.Int64())
+	}
+	if args.ToBlock != nil {
+		to = rpc.BlockNumber(args.ToBlock.Int64())
+	}
+	return from, to
+}
+
 // UnmarshalJSON sets *args fields with given data.
 func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 	type input struct {
@@ -460,6 +608,15 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	// Proof-of-work blocks are never final, so the finality tags can't bound a range
+	for _, number := range []*rpc.BlockNumber{raw.From, raw.ToBlock} {
+		if number == nil {
+			continue
+		}
+		if _, err := ethapi.ResolveBlockNumber(*number, 0); err != nil {
+			return err
+		}
+	}
 	if raw.From != nil {
 		args.FromBlock = big.NewInt(raw.From.Int64())
 	}
@@ -538,11 +695,9 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 }
 
 func decodeAddress(s string) (common.Address, error) {
-	b, err := hexutil.Decode(s)
-	if err == nil && len(b) != common.AddressLength {
-		err = fmt.Errorf("hex has invalid length %d after decoding", len(b))
-	}
-	return common.BytesToAddress(b), err
+	var addr common.ChecksumAddress
+	err := addr.UnmarshalText([]byte(s))
+	return common.Address(addr), err
 }
 
 func decodeTopic(s string) (common.Hash, error) {
@@ -97,6 +97,18 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 		t.Fatalf("expected address %x, got %x", address1, test3.Addresses[1])
 	}
 
+	// checksummed addresses
+	var checksummed FilterCriteria
+	if err := json.Unmarshal([]byte(`{"address": "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}`), &checksummed); err != nil {
+		t.Fatal(err)
+	}
+	if want := common.HexToAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"); checksummed.Addresses[0] != want {
+		t.Fatalf("expected address %x, got %x", want, checksummed.Addresses[0])
+	}
+	if err := json.Unmarshal([]byte(`{"address": "0x5aaeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}`), &checksummed); err == nil {
+		t.Fatal("expected an error for an address with an invalid checksum")
+	}
+
 	// single topic
 	var test4 FilterCriteria
 	vector = fmt.Sprintf(`{"topics": ["%s"]}`, topic0.Hex())
@@ -30,11 +30,19 @@ import (
 	"github.com/expanse-org/go-expanse/rpc"
 )
 
+// Backend is the chain access the filters need, implemented by both the full
+// and the light client, so that log filtering works the same way on both.
 type Backend interface {
 	ChainDb() ethdb.Database
 	EventMux() *event.TypeMux
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
+	HeaderByHash(ctx context.Context, blockHash common.Hash) (*types.Header, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
+
+	// BloomStatus reports whether MIP mapped bloom filters are maintained in the
+	// chain database, allowing past logs to be searched without checking every
+	// block header.
+	BloomStatus() bool
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -52,12 +60,12 @@ type Filter struct {
 
 // New creates a new filter which uses a bloom filter on blocks to figure out whether
 // a particular block is interesting or not.
-// MipMaps allow past blocks to be searched much more efficiently, but are not available
-// to light clients.
-func New(backend Backend, useMipMap bool) *Filter {
+// MipMaps allow past blocks to be searched much more efficiently, but are only used
+// if the backend maintains them (light clients don't).
+func New(backend Backend) *Filter {
 	return &Filter{
 		backend:   backend,
-		useMipMap: useMipMap,
+		useMipMap: backend.BloomStatus(),
 		db:        backend.ChainDb(),
 	}
 }
@@ -105,18 +113,37 @@ func (f *Filter) FindOnce(ctx context.Context) ([]*types.Log, error) {
 		endBlockNo = headBlockNumber
 	}
 
+	// Blocks covered by the log index are looked up directly, the older ones are
+	// searched via the bloom filters
+	if tail, ok := core.GetLogIndexTail(f.db); ok && f.indexed() && endBlockNo >= tail {
+		if beginBlockNo < tail {
+			logs, blockNumber, err := f.bloomFind(ctx, beginBlockNo, tail-1)
+			if len(logs) > 0 || err != nil {
+				f.begin = int64(blockNumber + 1)
+				return logs, err
+			}
+			beginBlockNo = tail
+		}
+		logs, blockNumber, err := f.indexFind(ctx, beginBlockNo, endBlockNo)
+		f.begin = int64(blockNumber + 1)
+		return logs, err
+	}
+	logs, blockNumber, err := f.bloomFind(ctx, beginBlockNo, endBlockNo)
+	f.begin = int64(blockNumber + 1)
+	return logs, err
+}
+
+// bloomFind searches the given range for the first block with matching logs,
+// using the bloom filters to skip the uninteresting ones.
+func (f *Filter) bloomFind(ctx context.Context, start, end uint64) ([]*types.Log, uint64, error) {
 	// if no addresses are present we can't make use of fast search which
 	// uses the mipmap bloom filters to check for fast inclusion and uses
 	// higher range probability in order to ensure at least a false positive
 	if !f.useMipMap || len(f.addresses) == 0 {
-		logs, blockNumber, err := f.getLogs(ctx, beginBlockNo, endBlockNo)
-		f.begin = int64(blockNumber + 1)
-		return logs, err
+		return f.getLogs(ctx, start, end)
 	}
-
-	logs, blockNumber := f.mipFind(beginBlockNo, endBlockNo, 0)
-	f.begin = int64(blockNumber + 1)
-	return logs, nil
+	logs, blockNumber := f.mipFind(start, end, 0)
+	return logs, blockNumber, nil
 }
 
 // Run filters logs with the current parameters set
@@ -182,11 +209,7 @@ func (f *Filter) getLogs(ctx context.Context, start, end uint64) (logs []*types.
 			if err != nil {
 				return nil, end, err
 			}
-			var unfiltered []*types.Log
-			for _, receipt := range receipts {
-				unfiltered = append(unfiltered, ([]*types.Log)(receipt.Logs)...)
-			}
-			logs = filterLogs(unfiltered, nil, nil, f.addresses, f.topics)
+			logs = filterLogs(blockLogs(header, receipts, false), nil, nil, f.addresses, f.topics)
 			if len(logs) > 0 {
 				return logs, uint64(blockNumber), nil
 			}
@@ -196,6 +219,147 @@ func (f *Filter) getLogs(ctx context.Context, start, end uint64) (logs []*types.
 	return logs, end, nil
 }
 
+// indexed returns whether the filter criteria restrict the matching logs, so
+// that the log index can be used to look up the candidate blocks.
+func (f *Filter) indexed() bool {
+	if len(f.addresses) > 0 {
+		return true
+	}
+	for _, sub := range f.topics {
+		if !includesWildcard(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexFind searches the given range for the first block with matching logs,
+// only retrieving the receipts of the candidate blocks of the log index.
+func (f *Filter) indexFind(ctx context.Context, start, end uint64) ([]*types.Log, uint64, error) {
+	for section := start / core.LogIndexSectionSize; section <= end/core.LogIndexSectionSize; section++ {
+		for _, number := range f.indexCandidates(section) {
+			if number < start || number > end {
+				continue
+			}
+			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return nil, end, err
+			}
+			// Stale entries of reorged blocks may be present, check the actual logs
+			receipts, err := f.backend.GetReceipts(ctx, header.Hash())
+			if err != nil {
+				return nil, end, err
+			}
+			if logs := filterLogs(blockLogs(header, receipts, false), nil, nil, f.addresses, f.topics); len(logs) > 0 {
+				return logs, number, nil
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, end, err
+		}
+	}
+	return nil, end, nil
+}
+
+// indexCandidates retrieves the ascending numbers of the blocks within a log
+// index section that might contain logs matching the filter criteria.
+func (f *Filter) indexCandidates(section uint64) []uint64 {
+	var (
+		candidates  []uint64
+		constrained bool
+	)
+	restrict := func(numbers []uint64) {
+		if !constrained {
+			candidates, constrained = numbers, true
+		} else {
+			candidates = intersectNumbers(candidates, numbers)
+		}
+	}
+	if len(f.addresses) > 0 {
+		var numbers []uint64
+		for _, addr := range f.addresses {
+			numbers = unionNumbers(numbers, core.GetLogIndexAddress(f.db, addr, section))
+		}
+		restrict(numbers)
+	}
+	for _, sub := range f.topics {
+		if constrained && len(candidates) == 0 {
+			break
+		}
+		if includesWildcard(sub) {
+			continue
+		}
+		var numbers []uint64
+		for _, topic := range sub {
+			numbers = unionNumbers(numbers, core.GetLogIndexTopic(f.db, topic, section))
+		}
+		restrict(numbers)
+	}
+	return candidates
+}
+
+// includesWildcard returns whether a topic position matches any topic.
+func includesWildcard(topics []common.Hash) bool {
+	for _, topic := range topics {
+		if (topic == common.Hash{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// unionNumbers merges two ascending lists of block numbers.
+func unionNumbers(a, b []uint64) []uint64 {
+	union := make([]uint64, 0, len(a)+len(b))
+	for len(a) > 0 || len(b) > 0 {
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0] < b[0]):
+			union, a = append(union, a[0]), a[1:]
+		case len(a) == 0 || b[0] < a[0]:
+			union, b = append(union, b[0]), b[1:]
+		default:
+			union, a, b = append(union, a[0]), a[1:], b[1:]
+		}
+	}
+	return union
+}
+
+// intersectNumbers returns the block numbers present in both ascending lists.
+func intersectNumbers(a, b []uint64) []uint64 {
+	var intersection []uint64
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case b[0] < a[0]:
+			b = b[1:]
+		default:
+			intersection, a, b = append(intersection, a[0]), a[1:], b[1:]
+		}
+	}
+	return intersection
+}
+
+// blockLogs gathers copies of the logs in a block's receipts, filling in the
+// block context light clients don't receive along with the receipts.
+func blockLogs(header *types.Header, receipts types.Receipts, removed bool) []*types.Log {
+	var (
+		hash   = header.Hash()
+		number = header.Number.Uint64()
+		logs   []*types.Log
+	)
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			logcopy := *log
+			logcopy.BlockHash = hash
+			logcopy.BlockNumber = number
+			logcopy.Removed = removed
+			logs = append(logs, &logcopy)
+		}
+	}
+	return logs
+}
+
 func includes(addresses []common.Address, a common.Address) bool {
 	for _, addr := range addresses {
 		if addr == a {
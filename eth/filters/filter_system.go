@@ -50,6 +50,14 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// DroppedTransactionsSubscription queries transactions dropped from the
+	// transaction pool along with the reason
+	DroppedTransactionsSubscription
+	// PendingTransactionsFullSubscription queries full transactions for
+	// pending transactions entering the pending state
+	PendingTransactionsFullSubscription
+	// ReorgsSubscription queries the reorganisations of the canonical chain
+	ReorgsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -66,6 +74,9 @@ type subscription struct {
 	logs      chan []*types.Log
 	hashes    chan common.Hash
 	headers   chan *types.Header
+	drops     chan core.TxDroppedEvent
+	txs       chan *types.Transaction
+	reorgs    chan core.ReorgEvent
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
 }
@@ -130,6 +141,9 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.drops:
+			case <-sub.f.txs:
+			case <-sub.f.reorgs:
 			}
 		}
 
@@ -197,6 +211,9 @@ func (es *EventSystem) subscribeMinedPendingLogs(crit FilterCriteria, logs chan
 		logs:      logs,
 		hashes:    make(chan common.Hash),
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDroppedEvent),
+		txs:       make(chan *types.Transaction),
+		reorgs:    make(chan core.ReorgEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -215,6 +232,9 @@ func (es *EventSystem) subscribeLogs(crit FilterCriteria, logs chan []*types.Log
 		logs:      logs,
 		hashes:    make(chan common.Hash),
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDroppedEvent),
+		txs:       make(chan *types.Transaction),
+		reorgs:    make(chan core.ReorgEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -233,6 +253,9 @@ func (es *EventSystem) subscribePendingLogs(crit FilterCriteria, logs chan []*ty
 		logs:      logs,
 		hashes:    make(chan common.Hash),
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDroppedEvent),
+		txs:       make(chan *types.Transaction),
+		reorgs:    make(chan core.ReorgEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -250,6 +273,9 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscripti
 		logs:      make(chan []*types.Log),
 		hashes:    make(chan common.Hash),
 		headers:   headers,
+		drops:     make(chan core.TxDroppedEvent),
+		txs:       make(chan *types.Transaction),
+		reorgs:    make(chan core.ReorgEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -267,6 +293,69 @@ func (es *EventSystem) SubscribePendingTxEvents(hashes chan common.Hash) *Subscr
 		logs:      make(chan []*types.Log),
 		hashes:    hashes,
 		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDroppedEvent),
+		txs:       make(chan *types.Transaction),
+		reorgs:    make(chan core.ReorgEvent),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+
+	return es.subscribe(sub)
+}
+
+// SubscribeDroppedTxEvents creates a subscription that writes the transactions
+// dropped from the transaction pool without being included in a block.
+func (es *EventSystem) SubscribeDroppedTxEvents(drops chan core.TxDroppedEvent) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       DroppedTransactionsSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan common.Hash),
+		headers:   make(chan *types.Header),
+		drops:     drops,
+		txs:       make(chan *types.Transaction),
+		reorgs:    make(chan core.ReorgEvent),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+
+	return es.subscribe(sub)
+}
+
+// SubscribePendingTxs creates a subscription that writes the full transactions
+// entering the transaction pool.
+func (es *EventSystem) SubscribePendingTxs(txs chan *types.Transaction) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       PendingTransactionsFullSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan common.Hash),
+		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDroppedEvent),
+		txs:       txs,
+		reorgs:    make(chan core.ReorgEvent),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+
+	return es.subscribe(sub)
+}
+
+// SubscribeReorgs creates a subscription that writes the reorganisations of the
+// canonical chain.
+func (es *EventSystem) SubscribeReorgs(reorgs chan core.ReorgEvent) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       ReorgsSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan common.Hash),
+		headers:   make(chan *types.Header),
+		drops:     make(chan core.TxDroppedEvent),
+		txs:       make(chan *types.Transaction),
+		reorgs:    reorgs,
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -315,6 +404,23 @@ func (es *EventSystem) broadcast(filters filterIndex, ev *event.TypeMuxEvent) {
 				f.hashes <- e.Tx.Hash()
 			}
 		}
+		for _, f := range filters[PendingTransactionsFullSubscription] {
+			if ev.Time.After(f.created) {
+				f.txs <- e.Tx
+			}
+		}
+	case core.TxDroppedEvent:
+		for _, f := range filters[DroppedTransactionsSubscription] {
+			if ev.Time.After(f.created) {
+				f.drops <- e
+			}
+		}
+	case core.ReorgEvent:
+		for _, f := range filters[ReorgsSubscription] {
+			if ev.Time.After(f.created) {
+				f.reorgs <- e
+			}
+		}
 	case core.ChainEvent:
 		for _, f := range filters[BlocksSubscription] {
 			if ev.Time.After(f.created) {
@@ -342,17 +448,24 @@ func (es *EventSystem) lightFilterNewHead(newHeader *types.Header, callBack func
 		return
 	}
 	newh := newHeader
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
 	// find common ancestor, create list of rolled back and new block hashes
 	var oldHeaders, newHeaders []*types.Header
 	for oldh.Hash() != newh.Hash() {
 		if oldh.Number.Uint64() >= newh.Number.Uint64() {
 			oldHeaders = append(oldHeaders, oldh)
-			oldh = core.GetHeader(es.backend.ChainDb(), oldh.ParentHash, oldh.Number.Uint64()-1)
+			if oldh, _ = es.backend.HeaderByHash(ctx, oldh.ParentHash); oldh == nil {
+				// rolled back chain not available locally, can't report removals
+				oldHeaders, oldh = nil, newh
+				break
+			}
 		}
 		if oldh.Number.Uint64() < newh.Number.Uint64() {
 			newHeaders = append(newHeaders, newh)
-			newh = core.GetHeader(es.backend.ChainDb(), newh.ParentHash, newh.Number.Uint64()-1)
-			if newh == nil {
+			if newh, _ = es.backend.HeaderByHash(ctx, newh.ParentHash); newh == nil {
 				// happens when CHT syncing, nothing to do
 				newh = oldh
 			}
@@ -378,16 +491,7 @@ func (es *EventSystem) lightFilterLogs(header *types.Header, addresses []common.
 		if err != nil {
 			return nil
 		}
-		var unfiltered []*types.Log
-		for _, receipt := range receipts {
-			for _, log := range receipt.Logs {
-				logcopy := *log
-				logcopy.Removed = remove
-				unfiltered = append(unfiltered, &logcopy)
-			}
-		}
-		logs := filterLogs(unfiltered, nil, nil, addresses, topics)
-		return logs
+		return filterLogs(blockLogs(header, receipts, remove), nil, nil, addresses, topics)
 	}
 	return nil
 }
@@ -396,7 +500,7 @@ func (es *EventSystem) lightFilterLogs(header *types.Header, addresses []common.
 func (es *EventSystem) eventLoop() {
 	var (
 		index = make(filterIndex)
-		sub   = es.mux.Subscribe(core.PendingLogsEvent{}, core.RemovedLogsEvent{}, []*types.Log{}, core.TxPreEvent{}, core.ChainEvent{})
+		sub   = es.mux.Subscribe(core.PendingLogsEvent{}, core.RemovedLogsEvent{}, []*types.Log{}, core.TxPreEvent{}, core.TxDroppedEvent{}, core.ReorgEvent{}, core.ChainEvent{})
 	)
 
 	for i := UnknownSubscription; i < LastIndexSubscription; i++ {
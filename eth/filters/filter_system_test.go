@@ -58,6 +58,15 @@ func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumbe
 	return core.GetHeader(b.db, hash, num), nil
 }
 
+func (b *testBackend) HeaderByHash(ctx context.Context, blockHash common.Hash) (*types.Header, error) {
+	num := core.GetBlockNumber(b.db, blockHash)
+	return core.GetHeader(b.db, blockHash, num), nil
+}
+
+func (b *testBackend) BloomStatus() bool {
+	return true
+}
+
 func (b *testBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
 	num := core.GetBlockNumber(b.db, blockHash)
 	return core.GetBlockReceipts(b.db, blockHash, num), nil
@@ -171,6 +180,126 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestDroppedTxSubscription tests whether dropped transaction subscriptions
+// receive the transactions dropped from the pool along with the reasons.
+func TestDroppedTxSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux     = new(event.TypeMux)
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{mux, db}
+		api     = NewPublicFilterAPI(backend, false)
+
+		drops = []core.TxDroppedEvent{
+			{Tx: types.NewTransaction(0, common.Address{}, new(big.Int), new(big.Int), new(big.Int), nil), Reason: core.TxDropReplaced},
+			{Tx: types.NewTransaction(1, common.Address{}, new(big.Int), new(big.Int), new(big.Int), nil), Reason: core.TxDropExpired},
+			{Tx: types.NewTransaction(2, common.Address{}, new(big.Int), new(big.Int), new(big.Int), nil), Reason: core.TxDropOverflow},
+		}
+	)
+	events := make(chan core.TxDroppedEvent)
+	sub := api.events.SubscribeDroppedTxEvents(events)
+
+	go func() {
+		time.Sleep(1 * time.Second)
+		for _, ev := range drops {
+			mux.Post(ev)
+		}
+	}()
+	for i := range drops {
+		select {
+		case ev := <-events:
+			if ev.Tx.Hash() != drops[i].Tx.Hash() || ev.Reason != drops[i].Reason {
+				t.Errorf("drop %d mismatch: have %x/%v, want %x/%v", i, ev.Tx.Hash(), ev.Reason, drops[i].Tx.Hash(), drops[i].Reason)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("drop %d not received", i)
+		}
+	}
+	sub.Unsubscribe()
+}
+
+// TestReorgSubscription tests whether reorg subscriptions receive the chain
+// reorganisations along with the reverted and included transactions.
+func TestReorgSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux     = new(event.TypeMux)
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{mux, db}
+		api     = NewPublicFilterAPI(backend, false)
+
+		genesis     = new(core.Genesis).MustCommit(db)
+		oldChain, _ = core.GenerateChain(params.TestChainConfig, genesis, db, 2, func(i int, gen *core.BlockGen) {})
+		newChain, _ = core.GenerateChain(params.TestChainConfig, genesis, db, 3, func(i int, gen *core.BlockGen) {
+			gen.OffsetTime(-1)
+		})
+		reorg = core.ReorgEvent{
+			OldChain:    []*types.Block{oldChain[1], oldChain[0]},
+			NewChain:    []*types.Block{newChain[2], newChain[1], newChain[0]},
+			RevertedTxs: types.Transactions{types.NewTransaction(0, common.Address{}, new(big.Int), new(big.Int), new(big.Int), nil)},
+			IncludedTxs: types.Transactions{types.NewTransaction(1, common.Address{}, new(big.Int), new(big.Int), new(big.Int), nil)},
+		}
+	)
+	events := make(chan core.ReorgEvent)
+	sub := api.events.SubscribeReorgs(events)
+
+	go func() {
+		time.Sleep(1 * time.Second)
+		mux.Post(reorg)
+	}()
+	select {
+	case ev := <-events:
+		have, want := newChainReorg(ev), newChainReorg(reorg)
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("reorg mismatch: have %+v, want %+v", have, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("reorg not received")
+	}
+	sub.Unsubscribe()
+}
+
+// TestPendingTxFullSubscription tests whether full pending transaction
+// subscriptions receive the transactions entering the pool.
+func TestPendingTxFullSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux     = new(event.TypeMux)
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{mux, db}
+		api     = NewPublicFilterAPI(backend, false)
+
+		transactions = []*types.Transaction{
+			types.NewTransaction(0, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), new(big.Int), new(big.Int), nil),
+			types.NewTransaction(1, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), new(big.Int), new(big.Int), nil),
+			types.NewTransaction(2, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), new(big.Int), new(big.Int), nil),
+		}
+	)
+	txs := make(chan *types.Transaction)
+	sub := api.events.SubscribePendingTxs(txs)
+
+	go func() {
+		time.Sleep(1 * time.Second)
+		for _, tx := range transactions {
+			mux.Post(core.TxPreEvent{Tx: tx})
+		}
+	}()
+	for i := range transactions {
+		select {
+		case tx := <-txs:
+			if tx.Hash() != transactions[i].Hash() {
+				t.Errorf("tx %d mismatch: have %x, want %x", i, tx.Hash(), transactions[i].Hash())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("tx %d not received", i)
+		}
+	}
+	sub.Unsubscribe()
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
@@ -349,6 +478,53 @@ func TestLogFilter(t *testing.T) {
 	}
 }
 
+// Tests that the light client head tracking reports the headers rolled back
+// and added by a reorg, looking ancestors up through the backend, and that it
+// copes with a rolled back chain that isn't available locally.
+func TestLightFilterReorg(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux     = new(event.TypeMux)
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{mux, db}
+		es      = NewEventSystem(mux, backend, true)
+		genesis = new(core.Genesis).MustCommit(db)
+		oldc, _ = core.GenerateChain(params.TestChainConfig, genesis, db, 5, func(i int, gen *core.BlockGen) {})
+		newc, _ = core.GenerateChain(params.TestChainConfig, oldc[1], db, 6, func(i int, gen *core.BlockGen) {
+			gen.SetCoinbase(common.Address{0x01})
+		})
+	)
+	for _, block := range append(oldc, newc...) {
+		core.WriteHeader(db, block.Header())
+	}
+	type report struct {
+		number  uint64
+		removed bool
+	}
+	var reports []report
+	collect := func(header *types.Header, removed bool) {
+		reports = append(reports, report{header.Number.Uint64(), removed})
+	}
+	// Switch from the old chain to the new one and check the reported headers
+	es.lastHead = oldc[len(oldc)-1].Header()
+	es.lightFilterNewHead(newc[len(newc)-1].Header(), collect)
+
+	want := []report{{5, true}, {4, true}, {3, true}, {3, false}, {4, false}, {5, false}, {6, false}, {7, false}, {8, false}}
+	if !reflect.DeepEqual(reports, want) {
+		t.Fatalf("reorg reports mismatch: have %v, want %v", reports, want)
+	}
+	// Switch away from an unknown chain, only new headers can be reported
+	reports = nil
+	es.lastHead = &types.Header{Number: big.NewInt(6), ParentHash: common.Hash{0xff}}
+	es.lightFilterNewHead(newc[len(newc)-1].Header(), collect)
+
+	want = []report{{7, false}, {8, false}}
+	if !reflect.DeepEqual(reports, want) {
+		t.Fatalf("unknown chain reports mismatch: have %v, want %v", reports, want)
+	}
+}
+
 // TestPendingLogsSubscription tests if a subscription receives the correct pending logs that are posted to the event mux.
 func TestPendingLogsSubscription(t *testing.T) {
 	t.Parallel()
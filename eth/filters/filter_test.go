@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/expanse-org/go-expanse/common"
@@ -104,7 +105,7 @@ func BenchmarkMipmaps(b *testing.B) {
 	}
 	b.ResetTimer()
 
-	filter := New(backend, true)
+	filter := New(backend)
 	filter.SetAddresses([]common.Address{addr1, addr2, addr3, addr4})
 	filter.SetBeginBlock(0)
 	filter.SetEndBlock(-1)
@@ -207,7 +208,7 @@ func TestFilters(t *testing.T) {
 		}
 	}
 
-	filter := New(backend, true)
+	filter := New(backend)
 	filter.SetAddresses([]common.Address{addr})
 	filter.SetTopics([][]common.Hash{{hash1, hash2, hash3, hash4}})
 	filter.SetBeginBlock(0)
@@ -218,7 +219,7 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 4 log, got", len(logs))
 	}
 
-	filter = New(backend, true)
+	filter = New(backend)
 	filter.SetAddresses([]common.Address{addr})
 	filter.SetTopics([][]common.Hash{{hash3}})
 	filter.SetBeginBlock(900)
@@ -231,7 +232,7 @@ func TestFilters(t *testing.T) {
 		t.Errorf("expected log[0].Topics[0] to be %x, got %x", hash3, logs[0].Topics[0])
 	}
 
-	filter = New(backend, true)
+	filter = New(backend)
 	filter.SetAddresses([]common.Address{addr})
 	filter.SetTopics([][]common.Hash{{hash3}})
 	filter.SetBeginBlock(990)
@@ -244,7 +245,7 @@ func TestFilters(t *testing.T) {
 		t.Errorf("expected log[0].Topics[0] to be %x, got %x", hash3, logs[0].Topics[0])
 	}
 
-	filter = New(backend, true)
+	filter = New(backend)
 	filter.SetTopics([][]common.Hash{{hash1, hash2}})
 	filter.SetBeginBlock(1)
 	filter.SetEndBlock(10)
@@ -255,7 +256,7 @@ func TestFilters(t *testing.T) {
 	}
 
 	failHash := common.BytesToHash([]byte("fail"))
-	filter = New(backend, true)
+	filter = New(backend)
 	filter.SetTopics([][]common.Hash{{failHash}})
 	filter.SetBeginBlock(0)
 	filter.SetEndBlock(-1)
@@ -266,7 +267,7 @@ func TestFilters(t *testing.T) {
 	}
 
 	failAddr := common.BytesToAddress([]byte("failmenow"))
-	filter = New(backend, true)
+	filter = New(backend)
 	filter.SetAddresses([]common.Address{failAddr})
 	filter.SetBeginBlock(0)
 	filter.SetEndBlock(-1)
@@ -276,7 +277,7 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 
-	filter = New(backend, true)
+	filter = New(backend)
 	filter.SetTopics([][]common.Hash{{failHash}, {hash1}})
 	filter.SetBeginBlock(0)
 	filter.SetEndBlock(-1)
@@ -286,3 +287,98 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+func TestFiltersLogIndex(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		mux     = new(event.TypeMux)
+		backend = &testBackend{mux, db}
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = common.BytesToAddress([]byte("jeff"))
+
+		hash1 = common.BytesToHash([]byte("topic1"))
+		hash2 = common.BytesToHash([]byte("topic2"))
+
+		tail = uint64(core.LogIndexSectionSize + 100) // First block covered by the log index
+	)
+	// Create a chain spanning a few log index sections with logs below and above the index tail
+	logs := map[int]*types.Log{
+		10:                             {Address: addr1, Topics: []common.Hash{hash1}},
+		core.LogIndexSectionSize:       {Address: addr2, Topics: []common.Hash{hash1}},
+		5000:                           {Address: addr2, Topics: []common.Hash{hash2}},
+		2*core.LogIndexSectionSize + 7: {Address: addr1, Topics: []common.Hash{hash2}},
+	}
+	genesis := core.GenesisBlockForTesting(db, addr1, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, db, 3*core.LogIndexSectionSize, func(i int, gen *core.BlockGen) {
+		var receipts types.Receipts
+		if log, ok := logs[i+1]; ok {
+			receipt := types.NewReceipt(nil, new(big.Int))
+			receipt.Logs = []*types.Log{log}
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+			gen.AddUncheckedReceipt(receipt)
+			receipts = types.Receipts{receipt}
+		}
+		core.WriteMipmapBloom(db, uint64(i+1), receipts)
+	})
+	core.WriteLogIndexTail(db, tail)
+	for i, block := range chain {
+		core.WriteBlock(db, block)
+		if err := core.WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteHeadBlockHash(db, block.Hash()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts[i]); err != nil {
+			t.Fatal("error writing block receipts:", err)
+		}
+		if block.NumberU64() >= tail {
+			if err := core.WriteLogIndex(db, block.NumberU64(), receipts[i]); err != nil {
+				t.Fatal("error writing log index:", err)
+			}
+		}
+	}
+	// Insert a stale entry of a reorged block, which must be filtered out
+	stale := types.NewReceipt(nil, new(big.Int))
+	stale.Logs = []*types.Log{{Address: addr1, Topics: []common.Hash{hash1, hash2}}}
+	if err := core.WriteLogIndex(db, 6000, types.Receipts{stale}); err != nil {
+		t.Fatal("error writing log index:", err)
+	}
+
+	tests := []struct {
+		addresses  []common.Address
+		topics     [][]common.Hash
+		begin, end int64
+		want       []uint64
+	}{
+		{[]common.Address{addr1}, nil, 0, -1, []uint64{10, 2*core.LogIndexSectionSize + 7}},
+		{[]common.Address{addr1, addr2}, nil, 0, -1, []uint64{10, core.LogIndexSectionSize, 5000, 2*core.LogIndexSectionSize + 7}},
+		{nil, [][]common.Hash{{hash2}}, 0, -1, []uint64{5000, 2*core.LogIndexSectionSize + 7}},
+		{nil, [][]common.Hash{{hash1, hash2}}, int64(tail), -1, []uint64{5000, 2*core.LogIndexSectionSize + 7}},
+		{[]common.Address{addr2}, [][]common.Hash{{hash2}}, 0, 6000, []uint64{5000}},
+		{[]common.Address{addr1}, [][]common.Hash{{hash1}}, 0, -1, []uint64{10}},
+		{nil, [][]common.Hash{{common.Hash{}}}, 4000, 6000, []uint64{core.LogIndexSectionSize, 5000}},
+		{[]common.Address{addr2}, nil, 5001, 2 * core.LogIndexSectionSize, nil},
+	}
+	for i, tt := range tests {
+		filter := New(backend)
+		filter.SetAddresses(tt.addresses)
+		filter.SetTopics(tt.topics)
+		filter.SetBeginBlock(tt.begin)
+		filter.SetEndBlock(tt.end)
+
+		logs, err := filter.Find(context.Background())
+		if err != nil {
+			t.Errorf("test %d: failed to filter logs: %v", i, err)
+			continue
+		}
+		var have []uint64
+		for _, log := range logs {
+			have = append(have, log.BlockNumber)
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: log blocks mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
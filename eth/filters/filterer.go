@@ -0,0 +1,114 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+
+	"github.com/expanse-org/go-expanse"
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+// LogFilterer implements ethereum.LogFilterer on top of a filter backend, giving
+// in-process users (e.g. contract bindings) access to the logs of the chain
+// without going through the RPC layer.
+type LogFilterer struct {
+	backend Backend
+	events  *EventSystem
+}
+
+// NewLogFilterer creates a log filterer over the chain of the given backend.
+func NewLogFilterer(backend Backend, lightMode bool) *LogFilterer {
+	return &LogFilterer{
+		backend: backend,
+		events:  NewEventSystem(backend.EventMux(), backend, lightMode),
+	}
+}
+
+// FilterLogs retrieves the logs matching the query from the chain. A nil start
+// block means the genesis block, a nil end block the head of the chain.
+func (f *LogFilterer) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	filter := New(f.backend)
+	if q.FromBlock != nil {
+		filter.SetBeginBlock(q.FromBlock.Int64())
+	} else {
+		filter.SetBeginBlock(0)
+	}
+	if q.ToBlock != nil {
+		filter.SetEndBlock(q.ToBlock.Int64())
+	} else {
+		filter.SetEndBlock(rpc.LatestBlockNumber.Int64())
+	}
+	filter.SetAddresses(q.Addresses)
+	filter.SetTopics(wildcardTopics(q.Topics))
+
+	found, err := filter.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]types.Log, len(found))
+	for i, log := range found {
+		logs[i] = *log
+	}
+	return logs, nil
+}
+
+// SubscribeFilterLogs streams the logs matching the query, as blocks containing
+// them are added to (or removed from) the chain, into ch.
+func (f *LogFilterer) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	matches := make(chan []*types.Log)
+
+	sub, err := f.events.SubscribeLogs(FilterCriteria{FromBlock: q.FromBlock, ToBlock: q.ToBlock, Addresses: q.Addresses, Topics: wildcardTopics(q.Topics)}, matches)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case logs := <-matches:
+				for _, log := range logs {
+					select {
+					case ch <- *log:
+					case <-quit:
+						return nil
+					}
+				}
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// wildcardTopics converts the empty topic positions of a query, matching anything
+// by the ethereum.FilterQuery semantics, to the explicit wildcards the filters
+// expect (the same way JSON null topics are converted by the RPC API).
+func wildcardTopics(topics [][]common.Hash) [][]common.Hash {
+	converted := make([][]common.Hash, len(topics))
+	for i, sub := range topics {
+		if len(sub) == 0 {
+			converted[i] = []common.Hash{{}}
+		} else {
+			converted[i] = sub
+		}
+	}
+	return converted
+}
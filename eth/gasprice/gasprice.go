@@ -17,189 +17,184 @@
 package gasprice
 
 import (
+	"context"
+	"errors"
 	"math/big"
-	"math/rand"
+	"sort"
 	"sync"
 
-	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/core/types"
-	"github.com/expanse-org/go-expanse/ethdb"
-	"github.com/expanse-org/go-expanse/event"
-	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/internal/ethapi"
+	"github.com/expanse-org/go-expanse/rpc"
 )
 
-const (
-	gpoProcessPastBlocks = 100
+// MaxFeeHistory is the maximum number of blocks a fee history can be requested for.
+const MaxFeeHistory = 1024
 
-	// for testing
-	gpoDefaultBaseCorrectionFactor = 110
-	gpoDefaultMinGasPrice          = 10000000000000
+var (
+	errInvalidPercentile = errors.New("invalid reward percentile")
+	errMissingBlock      = errors.New("block not found")
 )
 
-type blockPriceInfo struct {
-	baseGasPrice *big.Int
+// Config are the settings of the gas price oracle.
+type Config struct {
+	Blocks     int      // Number of recent blocks with transactions to sample
+	Percentile int      // Percentile of the sampled lowest block prices to suggest
+	Default    *big.Int // Price suggested until any transaction is seen
+	MinPrice   *big.Int // Lower bound of the suggested price (nil = unbounded)
+	MaxPrice   *big.Int // Upper bound of the suggested price (nil = unbounded)
 }
 
-type GpoParams struct {
-	GpoMinGasPrice          *big.Int
-	GpoMaxGasPrice          *big.Int
-	GpoFullBlockRatio       int
-	GpobaseStepDown         int
-	GpobaseStepUp           int
-	GpobaseCorrectionFactor int
-}
+// Oracle recommends gas prices based on the content of recent blocks: it
+// samples the lowest transaction price of each of the last few blocks and
+// suggests a configurable percentile of them. Suitable for both light and full
+// clients.
+type Oracle struct {
+	backend   ethapi.Backend
+	lastHead  common.Hash
+	lastPrice *big.Int
+	cacheLock sync.RWMutex
+	fetchLock sync.Mutex
 
-// GasPriceOracle recommends gas prices based on the content of recent
-// blocks.
-type GasPriceOracle struct {
-	chain         *core.BlockChain
-	db            ethdb.Database
-	evmux         *event.TypeMux
-	params        *GpoParams
-	initOnce      sync.Once
-	minPrice      *big.Int
-	lastBaseMutex sync.Mutex
-	lastBase      *big.Int
-
-	// state of listenLoop
-	blocks                        map[uint64]*blockPriceInfo
-	firstProcessed, lastProcessed uint64
-	minBase                       *big.Int
+	checkBlocks int // Number of non-empty blocks to sample
+	maxEmpty    int // Number of empty blocks tolerated among the sampled ones
+	maxBlocks   int // Maximum number of blocks to look at while sampling
+	percentile  int
+	minPrice    *big.Int
+	maxPrice    *big.Int
 }
 
-// NewGasPriceOracle returns a new oracle.
-func NewGasPriceOracle(chain *core.BlockChain, db ethdb.Database, evmux *event.TypeMux, params *GpoParams) *GasPriceOracle {
-	minprice := params.GpoMinGasPrice
-	if minprice == nil {
-		minprice = big.NewInt(gpoDefaultMinGasPrice)
-	}
-	minbase := new(big.Int).Mul(minprice, big.NewInt(100))
-	if params.GpobaseCorrectionFactor > 0 {
-		minbase = minbase.Div(minbase, big.NewInt(int64(params.GpobaseCorrectionFactor)))
-	}
-	return &GasPriceOracle{
-		chain:    chain,
-		db:       db,
-		evmux:    evmux,
-		params:   params,
-		blocks:   make(map[uint64]*blockPriceInfo),
-		minBase:  minbase,
-		minPrice: minprice,
-		lastBase: minprice,
+// NewOracle returns a new gas price oracle, sanitizing the given configuration.
+func NewOracle(backend ethapi.Backend, config Config) *Oracle {
+	blocks := config.Blocks
+	if blocks < 1 {
+		blocks = 1
 	}
-}
-
-func (gpo *GasPriceOracle) init() {
-	gpo.initOnce.Do(func() {
-		gpo.processPastBlocks()
-		go gpo.listenLoop()
-	})
-}
-
-func (self *GasPriceOracle) processPastBlocks() {
-	last := int64(-1)
-	cblock := self.chain.CurrentBlock()
-	if cblock != nil {
-		last = int64(cblock.NumberU64())
-	}
-	first := int64(0)
-	if last > gpoProcessPastBlocks {
-		first = last - gpoProcessPastBlocks
-	}
-	self.firstProcessed = uint64(first)
-	for i := first; i <= last; i++ {
-		block := self.chain.GetBlockByNumber(uint64(i))
-		if block != nil {
-			self.processBlock(block)
-		}
+	percentile := config.Percentile
+	if percentile < 0 {
+		percentile = 0
+	}
+	if percentile > 100 {
+		percentile = 100
+	}
+	price := config.Default
+	if price == nil {
+		price = new(big.Int)
+	}
+	return &Oracle{
+		backend:     backend,
+		lastPrice:   price,
+		checkBlocks: blocks,
+		maxEmpty:    blocks / 2,
+		maxBlocks:   blocks * 5,
+		percentile:  percentile,
+		minPrice:    config.MinPrice,
+		maxPrice:    config.MaxPrice,
 	}
-
 }
 
-func (self *GasPriceOracle) listenLoop() {
-	events := self.evmux.Subscribe(core.ChainEvent{}, core.ChainSplitEvent{})
-	defer events.Unsubscribe()
+// SuggestPrice returns the recommended gas price.
+func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	gpo.cacheLock.RLock()
+	lastHead := gpo.lastHead
+	lastPrice := gpo.lastPrice
+	gpo.cacheLock.RUnlock()
 
-	for event := range events.Chan() {
-		switch event := event.Data.(type) {
-		case core.ChainEvent:
-			self.processBlock(event.Block)
-		case core.ChainSplitEvent:
-			self.processBlock(event.Block)
-		}
+	head, _ := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	headHash := head.Hash()
+	if headHash == lastHead {
+		return lastPrice, nil
 	}
-}
 
-func (self *GasPriceOracle) processBlock(block *types.Block) {
-	i := block.NumberU64()
-	if i > self.lastProcessed {
-		self.lastProcessed = i
-	}
+	gpo.fetchLock.Lock()
+	defer gpo.fetchLock.Unlock()
 
-	lastBase := self.minPrice
-	bpl := self.blocks[i-1]
-	if bpl != nil {
-		lastBase = bpl.baseGasPrice
-	}
-	if lastBase == nil {
-		return
+	// try checking the cache again, maybe the last fetch fetched what we need
+	gpo.cacheLock.RLock()
+	lastHead = gpo.lastHead
+	lastPrice = gpo.lastPrice
+	gpo.cacheLock.RUnlock()
+	if headHash == lastHead {
+		return lastPrice, nil
 	}
 
-	var corr int
-	lp := self.lowestPrice(block)
-	if lp == nil {
-		return
+	// Sample the lowest prices of the recent blocks, looking further back if
+	// too many of them are empty. The channel is large enough for abandoned
+	// fetches not to block.
+	var (
+		blockNum = head.Number.Uint64()
+		ch       = make(chan lpResult, gpo.maxBlocks)
+		sent     = 0
+		exp      = 0
+		maxEmpty = gpo.maxEmpty
+		lps      bigIntArray
+	)
+	for sent < gpo.checkBlocks && blockNum > 0 {
+		go gpo.getLowestPrice(ctx, blockNum, ch)
+		sent++
+		exp++
+		blockNum--
 	}
-
-	if lastBase.Cmp(lp) < 0 {
-		corr = self.params.GpobaseStepUp
-	} else {
-		corr = -self.params.GpobaseStepDown
+	for exp > 0 {
+		res := <-ch
+		if res.err != nil {
+			return lastPrice, res.err
+		}
+		exp--
+		if res.price != nil {
+			lps = append(lps, res.price)
+			continue
+		}
+		if maxEmpty > 0 {
+			maxEmpty--
+			continue
+		}
+		if blockNum > 0 && sent < gpo.maxBlocks {
+			go gpo.getLowestPrice(ctx, blockNum, ch)
+			sent++
+			exp++
+			blockNum--
+		}
 	}
-
-	crand := int64(corr * (900 + rand.Intn(201)))
-	newBase := new(big.Int).Mul(lastBase, big.NewInt(1000000+crand))
-	newBase.Div(newBase, big.NewInt(1000000))
-
-	if newBase.Cmp(self.minBase) < 0 {
-		newBase = self.minBase
+	price := lastPrice
+	if len(lps) > 0 {
+		sort.Sort(lps)
+		price = lps[(len(lps)-1)*gpo.percentile/100]
 	}
-
-	bpi := self.blocks[i]
-	if bpi == nil {
-		bpi = &blockPriceInfo{}
-		self.blocks[i] = bpi
+	if gpo.maxPrice != nil && price.Cmp(gpo.maxPrice) > 0 {
+		price = new(big.Int).Set(gpo.maxPrice)
+	}
+	if gpo.minPrice != nil && price.Cmp(gpo.minPrice) < 0 {
+		price = new(big.Int).Set(gpo.minPrice)
 	}
-	bpi.baseGasPrice = newBase
-	self.lastBaseMutex.Lock()
-	self.lastBase = newBase
-	self.lastBaseMutex.Unlock()
 
-	log.Trace("Processed block, base price updated", "number", i, "base", newBase)
+	gpo.cacheLock.Lock()
+	gpo.lastHead = headHash
+	gpo.lastPrice = price
+	gpo.cacheLock.Unlock()
+	return price, nil
 }
 
-// returns the lowers possible price with which a tx was or could have been included
-func (self *GasPriceOracle) lowestPrice(block *types.Block) *big.Int {
-	gasUsed := big.NewInt(0)
-
-	receipts := core.GetBlockReceipts(self.db, block.Hash(), block.NumberU64())
-	if len(receipts) > 0 {
-		if cgu := receipts[len(receipts)-1].CumulativeGasUsed; cgu != nil {
-			gasUsed = receipts[len(receipts)-1].CumulativeGasUsed
-		}
-	}
+type lpResult struct {
+	price *big.Int
+	err   error
+}
 
-	if new(big.Int).Mul(gasUsed, big.NewInt(100)).Cmp(new(big.Int).Mul(block.GasLimit(),
-		big.NewInt(int64(self.params.GpoFullBlockRatio)))) < 0 {
-		// block is not full, could have posted a tx with MinGasPrice
-		return big.NewInt(0)
+// getLowestPrice calculates the lowest transaction gas price in a given block
+// and sends it to the result channel. If the block is empty, price is nil.
+func (gpo *Oracle) getLowestPrice(ctx context.Context, blockNum uint64, ch chan lpResult) {
+	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
+	if block == nil {
+		ch <- lpResult{nil, err}
+		return
 	}
-
 	txs := block.Transactions()
 	if len(txs) == 0 {
-		return big.NewInt(0)
+		ch <- lpResult{nil, nil}
+		return
 	}
-	// block is full, find smallest gasPrice
+	// find smallest gasPrice
 	minPrice := txs[0].GasPrice()
 	for i := 1; i < len(txs); i++ {
 		price := txs[i].GasPrice()
@@ -207,22 +202,119 @@ func (self *GasPriceOracle) lowestPrice(block *types.Block) *big.Int {
 			minPrice = price
 		}
 	}
-	return minPrice
+	ch <- lpResult{minPrice, nil}
 }
 
-// SuggestPrice returns the recommended gas price.
-func (self *GasPriceOracle) SuggestPrice() *big.Int {
-	self.init()
-	self.lastBaseMutex.Lock()
-	price := new(big.Int).Set(self.lastBase)
-	self.lastBaseMutex.Unlock()
-
-	price.Mul(price, big.NewInt(int64(self.params.GpobaseCorrectionFactor)))
-	price.Div(price, big.NewInt(100))
-	if price.Cmp(self.minPrice) < 0 {
-		price.Set(self.minPrice)
-	} else if self.params.GpoMaxGasPrice != nil && price.Cmp(self.params.GpoMaxGasPrice) > 0 {
-		price.Set(self.params.GpoMaxGasPrice)
-	}
-	return price
+// FeeHistory returns the gas usage and the paid gas prices of a range of blocks
+// ending with lastBlock: the number of the oldest block, the gas prices paid at
+// the given percentiles of each block's gas used, and each block's ratio of
+// gas used to the gas limit. At most MaxFeeHistory blocks are returned, and no
+// prices if no percentiles are requested.
+func (gpo *Oracle) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, percentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	for i, p := range percentiles {
+		if p < 0 || p > 100 || (i > 0 && p < percentiles[i-1]) {
+			return nil, nil, nil, errInvalidPercentile
+		}
+	}
+	head, err := gpo.backend.HeaderByNumber(ctx, lastBlock)
+	if head == nil {
+		if err == nil {
+			err = errMissingBlock
+		}
+		return nil, nil, nil, err
+	}
+	last := head.Number.Uint64()
+	if blocks > MaxFeeHistory {
+		blocks = MaxFeeHistory
+	}
+	if uint64(blocks) > last+1 {
+		blocks = int(last + 1)
+	}
+	if blocks < 1 {
+		return new(big.Int).SetUint64(last + 1), nil, nil, nil
+	}
+	var (
+		oldest  = last + 1 - uint64(blocks)
+		rewards [][]*big.Int
+		ratios  = make([]float64, blocks)
+	)
+	if len(percentiles) > 0 {
+		rewards = make([][]*big.Int, blocks)
+	}
+	for i := 0; i < blocks; i++ {
+		block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(oldest+uint64(i)))
+		if block == nil {
+			if err == nil {
+				err = errMissingBlock
+			}
+			return nil, nil, nil, err
+		}
+		if block.GasLimit().Sign() > 0 {
+			ratio, _ := new(big.Rat).SetFrac(block.GasUsed(), block.GasLimit()).Float64()
+			ratios[i] = ratio
+		}
+		if rewards != nil {
+			if rewards[i], err = gpo.blockRewards(ctx, block, percentiles); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	return new(big.Int).SetUint64(oldest), rewards, ratios, nil
 }
+
+// txGasAndPrice is the gas used by a transaction and the price paid for it.
+type txGasAndPrice struct {
+	gasUsed *big.Int
+	price   *big.Int
+}
+
+// blockRewards returns the gas prices paid at the given percentiles of the gas
+// used by a block, zero for empty blocks.
+func (gpo *Oracle) blockRewards(ctx context.Context, block *types.Block, percentiles []float64) ([]*big.Int, error) {
+	rewards := make([]*big.Int, len(percentiles))
+	txs := block.Transactions()
+	if len(txs) == 0 || block.GasUsed().Sign() == 0 {
+		for i := range rewards {
+			rewards[i] = new(big.Int)
+		}
+		return rewards, nil
+	}
+	receipts, err := gpo.backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) != len(txs) {
+		return nil, errMissingBlock
+	}
+	// Order the transactions by price, along with the gas each of them used
+	sorted := make([]txGasAndPrice, len(txs))
+	prev := new(big.Int)
+	for i, tx := range txs {
+		sorted[i] = txGasAndPrice{new(big.Int).Sub(receipts[i].CumulativeGasUsed, prev), tx.GasPrice()}
+		prev = receipts[i].CumulativeGasUsed
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].price.Cmp(sorted[j].price) < 0 })
+
+	// Walk the transactions until the gas used reaches each percentile
+	var (
+		total     = new(big.Float).SetInt(block.GasUsed())
+		threshold = new(big.Float)
+		cumulated = new(big.Int).Set(sorted[0].gasUsed)
+		index     = 0
+	)
+	for i, p := range percentiles {
+		threshold.Mul(total, big.NewFloat(p/100))
+		for index < len(sorted)-1 && new(big.Float).SetInt(cumulated).Cmp(threshold) < 0 {
+			index++
+			cumulated.Add(cumulated, sorted[index].gasUsed)
+		}
+		rewards[i] = new(big.Int).Set(sorted[index].price)
+	}
+	return rewards, nil
+}
+
+type bigIntArray []*big.Int
+
+func (s bigIntArray) Len() int           { return len(s) }
+func (s bigIntArray) Less(i, j int) bool { return s[i].Cmp(s[j]) < 0 }
+func (s bigIntArray) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,175 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/internal/ethapi"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+// testBackend implements the parts of ethapi.Backend the oracle relies on.
+type testBackend struct {
+	ethapi.Backend
+	blocks   []*types.Block
+	receipts map[common.Hash]types.Receipts
+}
+
+// newTestBackend creates a chain of the given length, block n containing a
+// 21000 gas transaction priced at n Shannon and a 42000 gas one priced at n+5.
+func newTestBackend(length int) *testBackend {
+	backend := &testBackend{receipts: make(map[common.Hash]types.Receipts)}
+	backend.blocks = append(backend.blocks, types.NewBlockWithHeader(&types.Header{Number: new(big.Int), GasLimit: big.NewInt(100000), GasUsed: new(big.Int)}))
+	for n := 1; n <= length; n++ {
+		txs := []*types.Transaction{
+			types.NewTransaction(0, common.Address{}, new(big.Int), big.NewInt(21000), big.NewInt(int64(n)*params.Shannon), nil),
+			types.NewTransaction(1, common.Address{}, new(big.Int), big.NewInt(42000), big.NewInt(int64(n+5)*params.Shannon), nil),
+		}
+		receipts := []*types.Receipt{
+			types.NewReceipt(nil, big.NewInt(21000)),
+			types.NewReceipt(nil, big.NewInt(63000)),
+		}
+		header := &types.Header{
+			ParentHash: backend.blocks[n-1].Hash(),
+			Number:     big.NewInt(int64(n)),
+			GasLimit:   big.NewInt(100000),
+			GasUsed:    big.NewInt(63000),
+		}
+		block := types.NewBlock(header, txs, nil, receipts)
+		backend.blocks = append(backend.blocks, block)
+		backend.receipts[block.Hash()] = receipts
+	}
+	return backend
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.blocks[len(b.blocks)-1], nil
+	}
+	if number < 0 || int(number) >= len(b.blocks) {
+		return nil, nil
+	}
+	return b.blocks[number], nil
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	block, err := b.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.receipts[hash], nil
+}
+
+func shannon(n int64) *big.Int {
+	return big.NewInt(n * params.Shannon)
+}
+
+// Tests that the suggested price is the configured percentile of the lowest
+// prices of the recent blocks, bounded by the configured limits.
+func TestSuggestPrice(t *testing.T) {
+	backend := newTestBackend(20)
+
+	tests := []struct {
+		config Config
+		want   *big.Int
+	}{
+		{Config{Blocks: 10, Percentile: 50}, shannon(15)},
+		{Config{Blocks: 10, Percentile: 0}, shannon(11)},
+		{Config{Blocks: 10, Percentile: 100}, shannon(20)},
+		{Config{Blocks: 20, Percentile: 50}, shannon(10)},
+		{Config{Blocks: 50, Percentile: 100}, shannon(20)},
+		{Config{Blocks: 10, Percentile: 50, MaxPrice: shannon(12)}, shannon(12)},
+		{Config{Blocks: 10, Percentile: 50, MinPrice: shannon(18)}, shannon(18)},
+	}
+	for i, tt := range tests {
+		price, err := NewOracle(backend, tt.config).SuggestPrice(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to suggest price: %v", i, err)
+		}
+		if price.Cmp(tt.want) != 0 {
+			t.Errorf("test %d: price mismatch: have %v, want %v", i, price, tt.want)
+		}
+	}
+}
+
+// Tests that the default price is suggested until any transaction is seen.
+func TestSuggestPriceEmptyChain(t *testing.T) {
+	backend := newTestBackend(0)
+	for n := 1; n <= 5; n++ {
+		header := &types.Header{Number: big.NewInt(int64(n)), GasLimit: big.NewInt(100000), GasUsed: new(big.Int)}
+		backend.blocks = append(backend.blocks, types.NewBlockWithHeader(header))
+	}
+	price, err := NewOracle(backend, Config{Blocks: 2, Percentile: 50, Default: shannon(20)}).SuggestPrice(context.Background())
+	if err != nil {
+		t.Fatalf("failed to suggest price: %v", err)
+	}
+	if price.Cmp(shannon(20)) != 0 {
+		t.Errorf("price mismatch: have %v, want %v", price, shannon(20))
+	}
+}
+
+// Tests that the fee history reports the gas used ratios and the prices paid
+// at the requested percentiles of each block's gas used.
+func TestFeeHistory(t *testing.T) {
+	oracle := NewOracle(newTestBackend(20), Config{Blocks: 10, Percentile: 50})
+
+	oldest, rewards, ratios, err := oracle.FeeHistory(context.Background(), 2, rpc.LatestBlockNumber, []float64{0, 25, 50, 100})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	if oldest.Uint64() != 19 {
+		t.Errorf("oldest block mismatch: have %v, want %v", oldest, 19)
+	}
+	if len(ratios) != 2 || ratios[0] != 0.63 || ratios[1] != 0.63 {
+		t.Errorf("gas used ratios mismatch: have %v, want [0.63 0.63]", ratios)
+	}
+	for i, n := range []int64{19, 20} {
+		want := []*big.Int{shannon(n), shannon(n), shannon(n + 5), shannon(n + 5)}
+		if len(rewards[i]) != len(want) {
+			t.Fatalf("block %d: reward count mismatch: have %d, want %d", n, len(rewards[i]), len(want))
+		}
+		for j := range want {
+			if rewards[i][j].Cmp(want[j]) != 0 {
+				t.Errorf("block %d, percentile %d: reward mismatch: have %v, want %v", n, j, rewards[i][j], want[j])
+			}
+		}
+	}
+	// Requests beyond the genesis block are truncated, and omit rewards without percentiles
+	oldest, rewards, ratios, err = oracle.FeeHistory(context.Background(), 10, 4, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve truncated fee history: %v", err)
+	}
+	if oldest.Sign() != 0 || len(ratios) != 5 || rewards != nil {
+		t.Errorf("truncated history mismatch: oldest %v, %d ratios, rewards %v", oldest, len(ratios), rewards)
+	}
+	// Invalid percentiles are rejected
+	for _, percentiles := range [][]float64{{-1}, {101}, {50, 10}} {
+		if _, _, _, err := oracle.FeeHistory(context.Background(), 1, rpc.LatestBlockNumber, percentiles); err != errInvalidPercentile {
+			t.Errorf("percentiles %v: error mismatch: have %v, want %v", percentiles, err, errInvalidPercentile)
+		}
+	}
+}
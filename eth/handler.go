@@ -27,7 +27,9 @@ import (
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/eth/downloader"
 	"github.com/expanse-org/go-expanse/eth/fetcher"
@@ -37,7 +39,6 @@ import (
 	"github.com/expanse-org/go-expanse/p2p"
 	"github.com/expanse-org/go-expanse/p2p/discover"
 	"github.com/expanse-org/go-expanse/params"
-	"github.com/expanse-org/go-expanse/pow"
 	"github.com/expanse-org/go-expanse/rlp"
 )
 
@@ -64,16 +65,21 @@ type ProtocolManager struct {
 	fastSync uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
 	synced   uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
+	snapshotSync bool // Flag whether fast sync should start from a peer served state snapshot
+
 	txpool      txPool
 	blockchain  *core.BlockChain
 	chaindb     ethdb.Database
 	chainconfig *params.ChainConfig
-	maxPeers    int
+	slots       *p2p.PeerSlots
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
 	peers      *peerSet
 
+	snapPeers map[string]*snapPeer // Snapshot protocol sessions, linked to the eth peers of the same id
+	snapLock  sync.Mutex
+
 	SubProtocols []p2p.Protocol
 
 	eventMux      *event.TypeMux
@@ -97,7 +103,7 @@ type ProtocolManager struct {
 
 // NewProtocolManager returns a new ethereum sub protocol manager. The Ethereum sub protocol manages peers capable
 // with the ethereum network.
-func NewProtocolManager(config *params.ChainConfig, fastSync bool, networkId int, maxPeers int, mux *event.TypeMux, txpool txPool, pow pow.PoW, blockchain *core.BlockChain, chaindb ethdb.Database) (*ProtocolManager, error) {
+func NewProtocolManager(config *params.ChainConfig, fastSync bool, networkId int, slots *p2p.PeerSlots, mux *event.TypeMux, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb ethdb.Database) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkId:   networkId,
@@ -106,8 +112,9 @@ func NewProtocolManager(config *params.ChainConfig, fastSync bool, networkId int
 		blockchain:  blockchain,
 		chaindb:     chaindb,
 		chainconfig: config,
-		maxPeers:    maxPeers,
+		slots:       slots,
 		peers:       newPeerSet(),
+		snapPeers:   make(map[string]*snapPeer),
 		newPeerCh:   make(chan *peer),
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
@@ -166,14 +173,14 @@ func NewProtocolManager(config *params.ChainConfig, fastSync bool, networkId int
 		manager.removePeer)
 
 	validator := func(block *types.Block, parent *types.Block) error {
-		return core.ValidateHeader(config, pow, block.Header(), parent.Header(), true, false)
+		return core.ValidateHeader(blockchain, engine, block.Header(), parent.Header(), true, false)
 	}
 	heighter := func() uint64 {
 		return blockchain.CurrentBlock().NumberU64()
 	}
 	inserter := func(blocks types.Blocks) (int, error) {
 		atomic.StoreUint32(&manager.synced, 1) // Mark initial sync done on any fetcher import
-		return manager.insertChain(blocks)
+		return manager.insertChainPriority(blocks)
 	}
 	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
 
@@ -187,10 +194,24 @@ func NewProtocolManager(config *params.ChainConfig, fastSync bool, networkId int
 
 func (pm *ProtocolManager) insertChain(blocks types.Blocks) (i int, err error) {
 	i, err = pm.blockchain.InsertChain(blocks)
+	pm.reportBadBlock(blocks, i, err)
+	return i, err
+}
+
+// insertChainPriority inserts freshly propagated blocks, preempting any batch
+// the downloader is importing.
+func (pm *ProtocolManager) insertChainPriority(blocks types.Blocks) (i int, err error) {
+	i, err = pm.blockchain.InsertChainPriority(blocks)
+	pm.reportBadBlock(blocks, i, err)
+	return i, err
+}
+
+// reportBadBlock sends a bad block report if enabled and the insertion failed
+// due to a validation error.
+func (pm *ProtocolManager) reportBadBlock(blocks types.Blocks, i int, err error) {
 	if pm.badBlockReportingEnabled && core.IsValidationErr(err) && i < len(blocks) {
 		go sendBadBlockReport(blocks[i], err)
 	}
-	return i, err
 }
 
 func (pm *ProtocolManager) removePeer(id string) {
@@ -257,15 +278,20 @@ func (pm *ProtocolManager) newPeer(pv int, p *p2p.Peer, rw p2p.MsgReadWriter) *p
 // handle is the callback invoked to manage the life cycle of an eth peer. When
 // this function terminates, the peer is disconnected.
 func (pm *ProtocolManager) handle(p *peer) error {
-	if pm.peers.Len() >= pm.maxPeers {
-		return p2p.DiscTooManyPeers
+	if err := pm.slots.Acquire(ProtocolName, p.Peer); err != nil {
+		return err
 	}
+	defer pm.slots.Release(ProtocolName, p.Peer)
+
 	p.Log().Debug("Ethereum peer connected", "name", p.Name())
 
 	// Execute the Ethereum handshake
 	td, head, genesis := pm.blockchain.Status()
 	if err := p.Handshake(pm.networkId, td, head, genesis); err != nil {
 		p.Log().Debug("Ethereum handshake failed", "err", err)
+		if _, ok := err.(incompatibleError); ok {
+			return p2p.DiscUselessPeer
+		}
 		return err
 	}
 	if rw, ok := p.rw.(*meteredMsgReadWriter); ok {
@@ -282,6 +308,13 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	if err := pm.downloader.RegisterPeer(p.id, p.version, p.Head, p.RequestHeadersByHash, p.RequestHeadersByNumber, p.RequestBodies, p.RequestReceipts, p.RequestNodeData); err != nil {
 		return err
 	}
+	// Make the snapshot protocol session of the peer available for syncing, if any
+	pm.snapLock.Lock()
+	if sp := pm.snapPeers[p.id]; sp != nil {
+		pm.downloader.RegisterSnapshotPeer(p.id, sp.RequestSnapshotManifest, sp.RequestSnapshotChunks)
+	}
+	pm.snapLock.Unlock()
+
 	// Propagate existing transactions. new transactions appearing
 	// after this will be sent via broadcasts.
 	pm.syncTransactions(p)
@@ -314,6 +347,148 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 }
 
+// snapshotProtocols returns the state snapshot sub-protocols, to be run next to
+// the eth protocol by nodes serving state snapshots or syncing from them.
+func (pm *ProtocolManager) snapshotProtocols() []p2p.Protocol {
+	protocols := make([]p2p.Protocol, 0, len(SnapshotProtocolVersions))
+	for i, version := range SnapshotProtocolVersions {
+		version := version // Closure for the run
+		protocols = append(protocols, p2p.Protocol{
+			Name:    SnapshotProtocolName,
+			Version: version,
+			Length:  SnapshotProtocolLengths[i],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				select {
+				case <-pm.quitSync:
+					return p2p.DiscQuitting
+				default:
+				}
+				pm.wg.Add(1)
+				defer pm.wg.Done()
+				return pm.handleSnapshot(newSnapPeer(int(version), p, newMeteredSnapMsgWriter(rw)))
+			},
+		})
+	}
+	return protocols
+}
+
+// handleSnapshot is the callback invoked to manage the life cycle of a snapshot
+// protocol session. The session is offered to the downloader as soon as the eth
+// session of the same peer is registered too, whichever of the two comes first.
+func (pm *ProtocolManager) handleSnapshot(p *snapPeer) error {
+	pm.snapLock.Lock()
+	if _, ok := pm.snapPeers[p.id]; ok {
+		pm.snapLock.Unlock()
+		return errAlreadyRegistered
+	}
+	pm.snapPeers[p.id] = p
+	if pm.peers.Peer(p.id) != nil {
+		pm.downloader.RegisterSnapshotPeer(p.id, p.RequestSnapshotManifest, p.RequestSnapshotChunks)
+	}
+	pm.snapLock.Unlock()
+
+	defer func() {
+		pm.snapLock.Lock()
+		delete(pm.snapPeers, p.id)
+		pm.downloader.RegisterSnapshotPeer(p.id, nil, nil)
+		pm.snapLock.Unlock()
+	}()
+	for {
+		if err := pm.handleSnapshotMsg(p); err != nil {
+			p.Log().Debug("Snapshot message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleSnapshotMsg is invoked whenever an inbound snapshot protocol message is
+// received from a remote peer. The remote connection is torn down upon returning
+// any error.
+func (pm *ProtocolManager) handleSnapshotMsg(p *snapPeer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	// Handle the message depending on its contents
+	switch {
+	case msg.Code == GetSnapshotManifestMsg:
+		// Decode the empty request and reply with the served snapshot's manifest, if any
+		var query []interface{}
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		var manifests []*state.SnapshotManifest
+		if manifest := core.GetSnapshotManifest(pm.chaindb); manifest != nil {
+			manifests = append(manifests, manifest)
+		}
+		return p.SendSnapshotManifest(manifests)
+
+	case msg.Code == SnapshotManifestMsg:
+		// The manifest of a remote state snapshot arrived to our previous request
+		var manifests []*state.SnapshotManifest
+		if err := msg.Decode(&manifests); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if len(manifests) > 1 {
+			return errResp(ErrDecode, "msg %v: multiple snapshot manifests", msg)
+		}
+		var manifest *state.SnapshotManifest
+		if len(manifests) > 0 {
+			manifest = manifests[0]
+		}
+		if err := pm.downloader.DeliverSnapshotManifest(p.id, manifest); err != nil {
+			log.Debug("Failed to deliver snapshot manifest", "err", err)
+		}
+
+	case msg.Code == GetSnapshotChunksMsg:
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+		// Gather snapshot chunks until the fetch or network limits is reached
+		var (
+			hash   common.Hash
+			bytes  int
+			chunks [][]byte
+		)
+		for bytes < softResponseLimit && len(chunks) < downloader.MaxSnapshotChunkFetch {
+			// Retrieve the hash of the next chunk
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			// Retrieve the requested chunk, skipping if no longer served
+			if chunk := core.GetSnapshotChunk(pm.chaindb, hash); len(chunk) > 0 {
+				chunks = append(chunks, chunk)
+				bytes += len(chunk)
+			}
+		}
+		return p.SendSnapshotChunks(chunks)
+
+	case msg.Code == SnapshotChunksMsg:
+		// A batch of snapshot chunks arrived to one of our previous requests
+		var chunks [][]byte
+		if err := msg.Decode(&chunks); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if err := pm.downloader.DeliverSnapshotChunks(p.id, chunks); err != nil {
+			log.Debug("Failed to deliver snapshot chunks", "err", err)
+		}
+
+	default:
+		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+	return nil
+}
+
 // handleMsg is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
 func (pm *ProtocolManager) handleMsg(p *peer) error {
@@ -576,7 +751,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				return errResp(ErrDecode, "msg %v: %v", msg, err)
 			}
 			// Retrieve the requested block's receipts, skipping if unknown to us
-			results := core.GetBlockReceipts(pm.chaindb, hash, core.GetBlockNumber(pm.chaindb, hash))
+			results := pm.blockchain.GetReceiptsByHash(hash)
 			if results == nil {
 				if header := pm.blockchain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
 					continue
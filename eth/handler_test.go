@@ -47,8 +47,8 @@ func TestProtocolCompatibility(t *testing.T) {
 		fastSync   bool
 		compatible bool
 	}{
-		{61, false, true}, {62, false, true}, {63, false, true},
-		{61, true, false}, {62, true, false}, {63, true, true},
+		{61, false, true}, {62, false, true}, {63, false, true}, {64, false, true},
+		{61, true, false}, {62, true, false}, {63, true, true}, {64, true, true},
 	}
 	// Make sure anything we screw up is restored
 	backup := ProtocolVersions
@@ -450,6 +450,45 @@ func testGetReceipt(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that the served state snapshot can be retrieved from a remote node over
+// the snapshot protocol.
+func TestGetSnapshot1(t *testing.T) { testGetSnapshot(t, 1) }
+
+func testGetSnapshot(t *testing.T, protocol int) {
+	pm := newTestProtocolManagerMust(t, false, 4, nil, nil)
+	peer, _ := newTestSnapPeer("peer", protocol, pm)
+	defer peer.Close()
+
+	// Without a snapshot, an empty manifest list is expected
+	p2p.Send(peer, 0x00, []interface{}{})
+	if err := p2p.ExpectMsg(peer, 0x01, []*state.SnapshotManifest{}); err != nil {
+		t.Errorf("missing manifest mismatch: %v", err)
+	}
+	// Snapshot the head state and request the manifest and chunks
+	head := pm.blockchain.CurrentBlock()
+	chunks, err := state.GenerateSnapshot(pm.chaindb, head.Root(), 64, func(hash common.Hash, blob []byte) error {
+		return core.WriteSnapshotChunk(pm.chaindb, hash, blob)
+	})
+	if err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	manifest := &state.SnapshotManifest{Number: head.NumberU64(), Hash: head.Hash(), Root: head.Root(), Chunks: chunks}
+	core.WriteSnapshotManifest(pm.chaindb, manifest)
+
+	p2p.Send(peer, 0x00, []interface{}{})
+	if err := p2p.ExpectMsg(peer, 0x01, []*state.SnapshotManifest{manifest}); err != nil {
+		t.Errorf("manifest mismatch: %v", err)
+	}
+	blobs := make([][]byte, 0, len(chunks))
+	for _, hash := range chunks {
+		blobs = append(blobs, core.GetSnapshotChunk(pm.chaindb, hash))
+	}
+	p2p.Send(peer, 0x02, append(chunks, common.Hash{}))
+	if err := p2p.ExpectMsg(peer, 0x03, blobs); err != nil {
+		t.Errorf("chunks mismatch: %v", err)
+	}
+}
+
 // Tests that post eth protocol handshake, DAO fork-enabled clients also execute
 // a DAO "challenge" verifying each others' DAO fork headers to ensure they're on
 // compatible chains.
@@ -469,14 +508,14 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 	// Create a DAO aware protocol manager
 	var (
 		evmux         = new(event.TypeMux)
-		pow           = new(pow.FakePow)
+		pow           = core.NewPowEngine(new(pow.FakePow))
 		db, _         = ethdb.NewMemDatabase()
 		config        = &params.ChainConfig{DAOForkBlock: big.NewInt(1), DAOForkSupport: localForked}
 		gspec         = &core.Genesis{Config: config}
 		genesis       = gspec.MustCommit(db)
 		blockchain, _ = core.NewBlockChain(db, config, pow, evmux, vm.Config{})
 	)
-	pm, err := NewProtocolManager(config, false, NetworkId, 1000, evmux, new(testTxPool), pow, blockchain, db)
+	pm, err := NewProtocolManager(config, false, NetworkId, p2p.NewPeerSlots(1000), evmux, new(testTxPool), pow, blockchain, db)
 	if err != nil {
 		t.Fatalf("failed to start test protocol manager: %v", err)
 	}
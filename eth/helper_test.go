@@ -51,7 +51,7 @@ var (
 func newTestProtocolManager(fastSync bool, blocks int, generator func(int, *core.BlockGen), newtx chan<- []*types.Transaction) (*ProtocolManager, error) {
 	var (
 		evmux = new(event.TypeMux)
-		pow   = new(pow.FakePow)
+		pow   = core.NewPowEngine(new(pow.FakePow))
 		db, _ = ethdb.NewMemDatabase()
 		gspec = &core.Genesis{
 			Config: params.TestChainConfig,
@@ -65,7 +65,7 @@ func newTestProtocolManager(fastSync bool, blocks int, generator func(int, *core
 		panic(err)
 	}
 
-	pm, err := NewProtocolManager(gspec.Config, fastSync, NetworkId, 1000, evmux, &testTxPool{added: newtx}, pow, blockchain, db)
+	pm, err := NewProtocolManager(gspec.Config, fastSync, NetworkId, p2p.NewPeerSlots(1000), evmux, &testTxPool{added: newtx}, pow, blockchain, db)
 	if err != nil {
 		return nil, err
 	}
@@ -167,6 +167,21 @@ func newTestPeer(name string, version int, pm *ProtocolManager, shake bool) (*te
 	return tp, errc
 }
 
+// newTestSnapPeer creates a new snapshot protocol session at the given protocol
+// manager, returning the application side of its message pipe.
+func newTestSnapPeer(name string, version int, pm *ProtocolManager) (*p2p.MsgPipeRW, <-chan error) {
+	app, net := p2p.MsgPipe()
+
+	var id discover.NodeID
+	rand.Read(id[:])
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- pm.handleSnapshot(newSnapPeer(version, p2p.NewPeer(id, name, nil), net))
+	}()
+	return app, errc
+}
+
 // handshake simulates a trivial handshake that expects the same state from the
 // remote side as we are simulating locally.
 func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash) {
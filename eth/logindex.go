@@ -0,0 +1,131 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+const (
+	logIndexStepInterval = time.Second                  // Time to wait between two backfilling steps
+	logIndexStepLimit    = 4 * core.LogIndexSectionSize // Maximum number of blocks to backfill per step
+)
+
+// logIndexer backfills the log index of the blocks imported before it was
+// enabled. The blocks imported afterwards are indexed by the chain itself, so
+// the backfilling walks the chain backwards, extending the indexed range down
+// to the genesis block.
+type logIndexer struct {
+	db      ethdb.Database
+	clock   mclock.Clock
+	syncing func() bool // Reports whether chain synchronisation is running
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newLogIndexer enables the log index maintenance of the chain, starting with
+// the blocks imported after the current head if the index is not yet present.
+func newLogIndexer(chain *core.BlockChain, db ethdb.Database, clock mclock.Clock, syncing func() bool) *logIndexer {
+	if _, ok := core.GetLogIndexTail(db); !ok {
+		head := chain.CurrentBlock().NumberU64()
+		log.Info("Enabling log index", "backfill", head+1)
+		core.WriteLogIndexTail(db, head+1)
+	}
+	return &logIndexer{
+		db:      db,
+		clock:   clock,
+		syncing: syncing,
+		quit:    make(chan struct{}),
+	}
+}
+
+// start spins up the backfilling loop.
+func (l *logIndexer) start() {
+	l.wg.Add(1)
+	go l.loop()
+}
+
+// stop terminates the backfilling loop, waiting for any running step.
+func (l *logIndexer) stop() {
+	close(l.quit)
+	l.wg.Wait()
+}
+
+// loop keeps backfilling the log index until it covers the whole chain.
+func (l *logIndexer) loop() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case <-l.clock.After(logIndexStepInterval):
+			if l.step() {
+				return
+			}
+
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+// step indexes the next range of canonical blocks below the indexed ones and
+// persists the progress made, returning whether the backfilling is complete.
+func (l *logIndexer) step() bool {
+	if l.syncing() {
+		return false
+	}
+	tail, ok := core.GetLogIndexTail(l.db)
+	if !ok || tail == 0 {
+		return true
+	}
+	start := time.Now()
+	for end := tail; tail > 0 && end-tail < logIndexStepLimit; tail-- {
+		number := tail - 1
+
+		hash := core.GetCanonicalHash(l.db, number)
+		if (hash == common.Hash{}) {
+			log.Warn("Log index backfill missing block", "number", number)
+			break
+		}
+		// Wait for the receipts of old blocks to be regenerated if left out
+		receipts := core.GetBlockReceipts(l.db, hash, number)
+		if body := core.GetBody(l.db, hash, number); body == nil || len(body.Transactions) != len(receipts) {
+			log.Debug("Log index backfill missing receipts", "number", number, "hash", hash)
+			break
+		}
+		if err := core.WriteLogIndex(l.db, number, receipts); err != nil {
+			log.Warn("Failed to backfill log index", "number", number, "err", err)
+			break
+		}
+	}
+	core.WriteLogIndexTail(l.db, tail)
+
+	if tail == 0 {
+		log.Info("Log index backfill completed")
+		return true
+	}
+	log.Debug("Backfilled log index", "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+	return false
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+const (
+	memoryCheckInterval = 3 * time.Second // Time interval between two heap size checks
+	minMemoryScale      = 1.0 / 16        // Lowest fraction the caches are shrunk to
+)
+
+// memoryGovernor periodically samples the Go heap and, whenever it grows beyond
+// the configured allowance, shrinks the node's in-memory caches (trie nodes,
+// recent blocks, downloader buffers) to keep the process from being killed on
+// memory constrained machines. Once the pressure subsides, the caches are
+// gradually allowed to regrow.
+type memoryGovernor struct {
+	allowance uint64              // Heap size (bytes) above which to shrink the caches
+	resize    func(scale float64) // Callback to scale the caches relative to their defaults
+	purge     func()              // Callback to drop any caches that can't be scaled
+
+	scale float64 // Currently applied cache scale (1 = unconstrained)
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newMemoryGovernor creates a memory governor keeping the heap under allowance
+// bytes by invoking the given cache scaling and purging callbacks.
+func newMemoryGovernor(allowance uint64, resize func(float64), purge func()) *memoryGovernor {
+	return &memoryGovernor{
+		allowance: allowance,
+		resize:    resize,
+		purge:     purge,
+		scale:     1,
+		quit:      make(chan struct{}),
+	}
+}
+
+// start spins up the heap monitoring loop.
+func (g *memoryGovernor) start() {
+	g.wg.Add(1)
+	go g.loop()
+}
+
+// stop terminates the heap monitoring loop and restores the default cache sizes.
+func (g *memoryGovernor) stop() {
+	close(g.quit)
+	g.wg.Wait()
+
+	if g.scale < 1 {
+		g.scale = 1
+		g.resize(g.scale)
+	}
+}
+
+// loop periodically samples the heap size and adjusts the caches accordingly.
+func (g *memoryGovernor) loop() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	var stats runtime.MemStats
+	for {
+		select {
+		case <-ticker.C:
+			runtime.ReadMemStats(&stats)
+			g.adjust(stats.HeapAlloc)
+
+		case <-g.quit:
+			return
+		}
+	}
+}
+
+// adjust shrinks the caches if the heap exceeds the allowance, or regrows them if
+// the heap comfortably fits within it again.
+func (g *memoryGovernor) adjust(heap uint64) {
+	switch {
+	case heap > g.allowance:
+		// Memory pressure, halve the caches and drop what can't be scaled
+		if g.purge != nil {
+			g.purge()
+		}
+		if g.scale <= minMemoryScale {
+			return
+		}
+		g.scale /= 2
+		if g.scale < minMemoryScale {
+			g.scale = minMemoryScale
+		}
+		log.Warn("Memory allowance exceeded, shrinking caches", "heap", common.StorageSize(heap), "allowance", common.StorageSize(g.allowance), "scale", g.scale)
+
+	case heap < g.allowance/4*3 && g.scale < 1:
+		// Pressure subsided, allow the caches to regrow
+		g.scale *= 2
+		if g.scale > 1 {
+			g.scale = 1
+		}
+		log.Info("Memory pressure relieved, regrowing caches", "heap", common.StorageSize(heap), "allowance", common.StorageSize(g.allowance), "scale", g.scale)
+
+	default:
+		return
+	}
+	g.resize(g.scale)
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tests that the memory governor shrinks the caches under pressure down to a
+// lower bound, and regrows them once the pressure subsides.
+func TestMemoryGovernorScaling(t *testing.T) {
+	var (
+		scales []float64
+		purges int
+	)
+	gov := newMemoryGovernor(1000, func(scale float64) { scales = append(scales, scale) }, func() { purges++ })
+
+	// Exceed the allowance repeatedly, the scale should halve until bottoming out
+	for i := 0; i < 6; i++ {
+		gov.adjust(2000)
+	}
+	if want := []float64{0.5, 0.25, 0.125, minMemoryScale}; !reflect.DeepEqual(scales, want) {
+		t.Fatalf("shrink scales mismatch: have %v, want %v", scales, want)
+	}
+	if purges != 6 {
+		t.Fatalf("purge count mismatch: have %d, want %d", purges, 6)
+	}
+	// Heap within the allowance but above the regrow threshold should be a noop
+	scales = nil
+	gov.adjust(900)
+	if len(scales) != 0 {
+		t.Fatalf("caches resized within hysteresis band: %v", scales)
+	}
+	// Heap well below the allowance should regrow the caches up to the default
+	for i := 0; i < 6; i++ {
+		gov.adjust(100)
+	}
+	if want := []float64{0.125, 0.25, 0.5, 1}; !reflect.DeepEqual(scales, want) {
+		t.Fatalf("regrow scales mismatch: have %v, want %v", scales, want)
+	}
+}
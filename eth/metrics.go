@@ -50,6 +50,10 @@ var (
 	reqReceiptInTrafficMeter  = metrics.NewMeter("eth/req/receipts/in/traffic")
 	reqReceiptOutPacketsMeter = metrics.NewMeter("eth/req/receipts/out/packets")
 	reqReceiptOutTrafficMeter = metrics.NewMeter("eth/req/receipts/out/traffic")
+	reqSnapInPacketsMeter     = metrics.NewMeter("eth/req/snapshots/in/packets")
+	reqSnapInTrafficMeter     = metrics.NewMeter("eth/req/snapshots/in/traffic")
+	reqSnapOutPacketsMeter    = metrics.NewMeter("eth/req/snapshots/out/packets")
+	reqSnapOutTrafficMeter    = metrics.NewMeter("eth/req/snapshots/out/traffic")
 	miscInPacketsMeter        = metrics.NewMeter("eth/misc/in/packets")
 	miscInTrafficMeter        = metrics.NewMeter("eth/misc/in/traffic")
 	miscOutPacketsMeter       = metrics.NewMeter("eth/misc/out/packets")
@@ -137,3 +141,49 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 	// Send the packet to the p2p layer
 	return rw.MsgReadWriter.WriteMsg(msg)
 }
+
+// meteredSnapMsgReadWriter is a wrapper around the p2p.MsgReadWriter of a state
+// snapshot protocol session, metering the snapshot data traffic.
+type meteredSnapMsgReadWriter struct {
+	p2p.MsgReadWriter // Wrapped message stream to meter
+}
+
+// newMeteredSnapMsgWriter wraps a snapshot protocol MsgReadWriter with metering
+// support. If the metrics system is disabled, this function returns the original
+// object.
+func newMeteredSnapMsgWriter(rw p2p.MsgReadWriter) p2p.MsgReadWriter {
+	if !metrics.Enabled {
+		return rw
+	}
+	return &meteredSnapMsgReadWriter{MsgReadWriter: rw}
+}
+
+func (rw *meteredSnapMsgReadWriter) ReadMsg() (p2p.Msg, error) {
+	// Read the message and short circuit in case of an error
+	msg, err := rw.MsgReadWriter.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	// Account for the data traffic
+	packets, traffic := miscInPacketsMeter, miscInTrafficMeter
+	if msg.Code == SnapshotChunksMsg {
+		packets, traffic = reqSnapInPacketsMeter, reqSnapInTrafficMeter
+	}
+	packets.Mark(1)
+	traffic.Mark(int64(msg.Size))
+
+	return msg, err
+}
+
+func (rw *meteredSnapMsgReadWriter) WriteMsg(msg p2p.Msg) error {
+	// Account for the data traffic
+	packets, traffic := miscOutPacketsMeter, miscOutTrafficMeter
+	if msg.Code == SnapshotChunksMsg {
+		packets, traffic = reqSnapOutPacketsMeter, reqSnapOutTrafficMeter
+	}
+	packets.Mark(1)
+	traffic.Mark(int64(msg.Size))
+
+	// Send the packet to the p2p layer
+	return rw.MsgReadWriter.WriteMsg(msg)
+}
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/p2p"
 	"github.com/expanse-org/go-expanse/rlp"
@@ -279,17 +280,21 @@ func (p *peer) readStatus(network int, status *statusData, genesis common.Hash)
 		return errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
 	if status.GenesisBlock != genesis {
-		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
+		return incompatibleError{errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])}
 	}
 	if int(status.NetworkId) != network {
-		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)
+		return incompatibleError{errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)}
 	}
 	if int(status.ProtocolVersion) != p.version {
-		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+		return incompatibleError{errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)}
 	}
 	return nil
 }
 
+// incompatibleError is a handshake failure caused by the remote peer running on
+// another chain, network or protocol version, making it useless to stay connected.
+type incompatibleError struct{ error }
+
 // String implements fmt.Stringer.
 func (p *peer) String() string {
 	return fmt.Sprintf("Peer %s [%s]", p.id,
@@ -297,6 +302,54 @@ func (p *peer) String() string {
 	)
 }
 
+// snapPeer is a session of the state snapshot protocol with a remote node, run
+// next to the eth protocol session of the same node.
+type snapPeer struct {
+	id string
+
+	*p2p.Peer
+	rw p2p.MsgReadWriter
+
+	version int // Snapshot protocol version negotiated
+}
+
+func newSnapPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *snapPeer {
+	id := p.ID()
+
+	return &snapPeer{
+		Peer:    p,
+		rw:      rw,
+		version: version,
+		id:      fmt.Sprintf("%x", id[:8]),
+	}
+}
+
+// SendSnapshotManifest sends the manifest of the state snapshot served by the
+// node, or an empty list if there is none.
+func (p *snapPeer) SendSnapshotManifest(manifests []*state.SnapshotManifest) error {
+	return p2p.Send(p.rw, SnapshotManifestMsg, manifests)
+}
+
+// SendSnapshotChunks sends a batch of state snapshot chunks, corresponding to
+// the hashes requested.
+func (p *snapPeer) SendSnapshotChunks(chunks [][]byte) error {
+	return p2p.Send(p.rw, SnapshotChunksMsg, chunks)
+}
+
+// RequestSnapshotManifest fetches the manifest of the state snapshot served by
+// a remote node.
+func (p *snapPeer) RequestSnapshotManifest() error {
+	p.Log().Debug("Fetching state snapshot manifest")
+	return p2p.Send(p.rw, GetSnapshotManifestMsg, []interface{}{})
+}
+
+// RequestSnapshotChunks fetches a batch of state snapshot chunks from a remote
+// node, corresponding to the specified hashes.
+func (p *snapPeer) RequestSnapshotChunks(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of snapshot chunks", "count", len(hashes))
+	return p2p.Send(p.rw, GetSnapshotChunksMsg, hashes)
+}
+
 // peerSet represents the collection of active peers currently participating in
 // the Ethereum sub-protocol.
 type peerSet struct {
@@ -41,6 +41,21 @@ var ProtocolVersions = []uint{eth63, eth62}
 // Number of implemented message corresponding to different protocol versions.
 var ProtocolLengths = []uint64{17, 8}
 
+// Constants to match up snapshot protocol versions and messages
+const (
+	snap1 = 1
+)
+
+// Short name of the state snapshot protocol, negotiated next to the eth protocol
+// by nodes serving state snapshots or syncing from them.
+var SnapshotProtocolName = "expsnap"
+
+// Supported versions of the snapshot protocol (first is primary).
+var SnapshotProtocolVersions = []uint{snap1}
+
+// Number of implemented message corresponding to different snapshot protocol versions.
+var SnapshotProtocolLengths = []uint64{4}
+
 const (
 	NetworkId          = 1
 	ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
@@ -65,6 +80,15 @@ const (
 	ReceiptsMsg    = 0x10
 )
 
+// snapshot protocol message codes
+const (
+	// Protocol messages belonging to expsnap/1
+	GetSnapshotManifestMsg = 0x00
+	SnapshotManifestMsg    = 0x01
+	GetSnapshotChunksMsg   = 0x02
+	SnapshotChunksMsg      = 0x03
+)
+
 type errCode int
 
 const (
@@ -0,0 +1,162 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+const (
+	regenerationStepInterval = 3 * time.Second // Time to wait between two regeneration steps
+	regenerationScanLimit    = 2048            // Maximum number of blocks to check per step
+	regenerationReplayLimit  = 32              // Maximum number of blocks to re-execute per step
+)
+
+// regenerationProgressKey tracks the first block not yet checked for missing
+// receipts, so the regenerator resumes where it left off after a restart.
+var regenerationProgressKey = []byte("ReceiptRegenerationProgress")
+
+// receiptRegenerator walks the canonical chain from the genesis up and rebuilds
+// the receipts of blocks that have transactions but no stored receipts, which is
+// the case for parts of the history of databases synced by older versions. Blocks
+// are re-executed a limited number at a time and never while syncing, so the
+// work doesn't compete with block imports. Blocks whose parent state has been
+// pruned cannot be replayed and are skipped.
+type receiptRegenerator struct {
+	chain   *core.BlockChain
+	db      ethdb.Database
+	clock   mclock.Clock
+	syncing func() bool // Reports whether chain synchronisation is running
+
+	next        uint64 // Number of the next block to check
+	regenerated int    // Blocks whose receipts were rebuilt since startup
+	unavailable int    // Blocks which could not be replayed since startup
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newReceiptRegenerator creates a receipt regenerator for the given chain,
+// resuming from the progress persisted in db.
+func newReceiptRegenerator(chain *core.BlockChain, db ethdb.Database, clock mclock.Clock, syncing func() bool) *receiptRegenerator {
+	r := &receiptRegenerator{
+		chain:   chain,
+		db:      db,
+		clock:   clock,
+		syncing: syncing,
+		next:    1,
+		quit:    make(chan struct{}),
+	}
+	if data, _ := db.Get(regenerationProgressKey); len(data) == 8 {
+		r.next = binary.BigEndian.Uint64(data)
+	}
+	return r
+}
+
+// start spins up the regeneration loop.
+func (r *receiptRegenerator) start() {
+	r.wg.Add(1)
+	go r.loop()
+}
+
+// stop terminates the regeneration loop, waiting for any running step.
+func (r *receiptRegenerator) stop() {
+	close(r.quit)
+	r.wg.Wait()
+}
+
+// loop runs regeneration steps until the head of the chain is reached or the
+// regenerator is stopped. Blocks imported afterwards are stored with receipts,
+// so there's nothing left to do after catching up.
+func (r *receiptRegenerator) loop() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-r.clock.After(regenerationStepInterval):
+			if r.step() {
+				if r.regenerated > 0 || r.unavailable > 0 {
+					log.Info("Receipt regeneration completed", "regenerated", r.regenerated, "unavailable", r.unavailable)
+				}
+				return
+			}
+
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// step checks the next range of canonical blocks, rebuilding missing receipts
+// where possible, and persists the progress made. It reports whether the head
+// of the chain has been reached.
+func (r *receiptRegenerator) step() bool {
+	if r.syncing() {
+		return false
+	}
+	head := r.chain.CurrentBlock().NumberU64()
+
+	start, replayed := r.next, 0
+	for r.next <= head && r.next-start < regenerationScanLimit && replayed < regenerationReplayLimit {
+		block := r.chain.GetBlockByNumber(r.next)
+		if block == nil {
+			break
+		}
+		if r.missingReceipts(block) {
+			replayed++
+			if _, err := r.chain.RegenerateReceipts(block); err != nil {
+				log.Debug("Failed to regenerate receipts", "number", block.Number(), "hash", block.Hash(), "err", err)
+				r.unavailable++
+			} else {
+				r.regenerated++
+			}
+		}
+		r.next++
+	}
+	if r.next != start {
+		var enc [8]byte
+		binary.BigEndian.PutUint64(enc[:], r.next)
+		if err := r.db.Put(regenerationProgressKey, enc[:]); err != nil {
+			log.Warn("Failed to store receipt regeneration progress", "err", err)
+		}
+	}
+	if replayed > 0 {
+		log.Info("Regenerated missing receipts", "number", r.next-1, "replayed", replayed, "total", r.regenerated)
+	}
+	return r.next > head
+}
+
+// missingReceipts reports whether the receipts of a block with transactions are
+// absent from the database, either as a whole or indexed by transaction.
+func (r *receiptRegenerator) missingReceipts(block *types.Block) bool {
+	txs := block.Transactions()
+	if len(txs) == 0 {
+		return false
+	}
+	if core.GetBlockReceipts(r.db, block.Hash(), block.NumberU64()) == nil {
+		return true
+	}
+	return core.GetReceipt(r.db, txs[0].Hash()) == nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// Tests that the receipt regenerator rebuilds the receipts missing from the
+// database, skips blocks that cannot be replayed and persists its progress.
+func TestReceiptRegeneration(t *testing.T) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	gspec.MustCommit(gendb)
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, 8, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{0x01}, big.NewInt(1000), bigTxGas, nil, nil), signer, testBankKey)
+		block.AddTx(tx)
+	})
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	// Drop the receipts of a few blocks, making one of them unreplayable
+	dropped := []*types.Block{blocks[1], blocks[2], blocks[4], blocks[6]}
+	for _, block := range dropped {
+		core.DeleteBlockReceipts(db, block.Hash(), block.NumberU64())
+		for _, tx := range block.Transactions() {
+			core.DeleteReceipt(db, tx.Hash())
+		}
+	}
+	db.Delete(blocks[5].Root().Bytes())
+
+	syncing := true
+	regen := newReceiptRegenerator(chain, db, new(mclock.Simulated), func() bool { return syncing })
+
+	// Nothing should be done while syncing
+	if done := regen.step(); done || regen.next != 1 {
+		t.Fatalf("regeneration progressed while syncing: done %v, next %d", done, regen.next)
+	}
+	// Once idle, the missing receipts should be rebuilt
+	syncing = false
+	if done := regen.step(); !done {
+		t.Fatalf("regeneration didn't reach the head")
+	}
+	if regen.regenerated != 3 || regen.unavailable != 1 {
+		t.Fatalf("regeneration count mismatch: regenerated %d, unavailable %d", regen.regenerated, regen.unavailable)
+	}
+	for _, block := range dropped[:3] {
+		receipts := core.GetBlockReceipts(db, block.Hash(), block.NumberU64())
+		if receipts == nil {
+			t.Fatalf("block #%d: receipts not regenerated", block.NumberU64())
+		}
+		if hash := types.DeriveSha(receipts); hash != block.ReceiptHash() {
+			t.Errorf("block #%d: receipt root mismatch: have %x, want %x", block.NumberU64(), hash, block.ReceiptHash())
+		}
+		for _, tx := range block.Transactions() {
+			if core.GetReceipt(db, tx.Hash()) == nil {
+				t.Errorf("block #%d: receipt of tx %x not regenerated", block.NumberU64(), tx.Hash())
+			}
+		}
+	}
+	if core.GetBlockReceipts(db, dropped[3].Hash(), dropped[3].NumberU64()) != nil {
+		t.Errorf("block #%d: receipts regenerated without parent state", dropped[3].NumberU64())
+	}
+	// A restarted regenerator should resume after the checked blocks
+	if next := newReceiptRegenerator(chain, db, new(mclock.Simulated), func() bool { return false }).next; next != 9 {
+		t.Errorf("resumed progress mismatch: have %d, want %d", next, 9)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"container/heap"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+// richListBuckets is the number of balance buckets accounts are counted in: one
+// below 1 ether, one per decade up to 10^8 ether and one above.
+const richListBuckets = 10
+
+var (
+	richListKey = []byte("RichList") // richListKey -> richList
+
+	errRichListDisabled = errors.New("rich list not enabled")
+	errRichListPending  = errors.New("rich list not built yet")
+)
+
+// richList is the balance ranking of the state at a canonical block.
+type richList struct {
+	Number   uint64
+	Hash     common.Hash
+	Accounts []richAccount // Richest accounts, by descending balance
+	Buckets  []uint64      // Number of accounts per balance bucket
+}
+
+// richAccount is an account ranked by its balance.
+type richAccount struct {
+	Address common.Address
+	Balance *big.Int
+}
+
+// richHeap is a min-heap of accounts by balance, used to retain the richest.
+type richHeap []richAccount
+
+func (h richHeap) Len() int            { return len(h) }
+func (h richHeap) Less(i, j int) bool  { return h[i].Balance.Cmp(h[j].Balance) < 0 }
+func (h richHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *richHeap) Push(x interface{}) { *h = append(*h, x.(richAccount)) }
+func (h *richHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// RichList is the balance ranking of the accounts at a canonical block.
+type RichList struct {
+	Number   hexutil.Uint64  `json:"number"`
+	Hash     common.Hash     `json:"hash"`
+	Accounts []RichAccount   `json:"accounts"` // Richest accounts, by descending balance
+	Buckets  []BalanceBucket `json:"buckets"`  // Account counts by balance
+	Total    hexutil.Uint64  `json:"total"`    // Number of accounts
+}
+
+// RichAccount is an account ranked by its balance.
+type RichAccount struct {
+	Address common.ChecksumAddress `json:"address"`
+	Balance *hexutil.Big           `json:"balance"`
+}
+
+// BalanceBucket is the number of accounts with a balance in [Min, Max).
+type BalanceBucket struct {
+	Min   *hexutil.Big   `json:"min"`
+	Max   *hexutil.Big   `json:"max"` // Nil for the last, unbounded bucket
+	Count hexutil.Uint64 `json:"count"`
+}
+
+// richListIndexer periodically ranks the accounts of the state by balance,
+// retaining the richest ones and counting all of them by balance bucket. The
+// ranking is rebuilt from the state of the last block of every new section of
+// the chain statistics index, once buried under the same confirmations.
+type richListIndexer struct {
+	chain   *core.BlockChain
+	db      ethdb.Database
+	clock   mclock.Clock
+	syncing func() bool // Reports whether chain synchronisation is running
+	size    int         // Number of richest accounts to retain
+
+	list *richList // Last built ranking, nil if none yet
+	lock sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newRichListIndexer creates a rich list indexer retaining the given number of
+// accounts, loading the last ranking from db.
+func newRichListIndexer(chain *core.BlockChain, db ethdb.Database, clock mclock.Clock, syncing func() bool, size int) *richListIndexer {
+	r := &richListIndexer{
+		chain:   chain,
+		db:      db,
+		clock:   clock,
+		syncing: syncing,
+		size:    size,
+		quit:    make(chan struct{}),
+	}
+	if data, _ := db.Get(richListKey); len(data) > 0 {
+		list := new(richList)
+		if err := rlp.DecodeBytes(data, list); err == nil && len(list.Accounts) <= size {
+			r.list = list
+		}
+	}
+	return r
+}
+
+// start spins up the indexing loop.
+func (r *richListIndexer) start() {
+	r.wg.Add(1)
+	go r.loop()
+}
+
+// stop terminates the indexing loop, waiting for any running rebuild.
+func (r *richListIndexer) stop() {
+	close(r.quit)
+	r.wg.Wait()
+}
+
+// loop rebuilds the ranking whenever a new section is confirmed.
+func (r *richListIndexer) loop() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-r.clock.After(chainStatsStepInterval):
+			r.step()
+
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// step rebuilds the ranking if a new section was confirmed since the last one,
+// or if the block it was built at was reorged out.
+func (r *richListIndexer) step() {
+	if r.syncing() {
+		return
+	}
+	head := r.chain.CurrentBlock().NumberU64()
+	if head+1 < chainStatsConfirmations+chainStatsSectionSize {
+		return
+	}
+	number := (head+1-chainStatsConfirmations)/chainStatsSectionSize*chainStatsSectionSize - 1
+
+	r.lock.RLock()
+	list := r.list
+	r.lock.RUnlock()
+
+	if list != nil && list.Number >= number && list.Hash == core.GetCanonicalHash(r.db, list.Number) {
+		return
+	}
+	block := r.chain.GetBlockByNumber(number)
+	if block == nil {
+		return
+	}
+	list, err := r.build(block.NumberU64(), block.Hash(), block.Root())
+	if err != nil {
+		log.Debug("Failed to build rich list", "number", number, "err", err)
+		return
+	}
+	enc, _ := rlp.EncodeToBytes(list)
+	if err := r.db.Put(richListKey, enc); err != nil {
+		log.Warn("Failed to store rich list", "err", err)
+	}
+	r.lock.Lock()
+	r.list = list
+	r.lock.Unlock()
+}
+
+// build ranks the accounts of the state with the given root.
+func (r *richListIndexer) build(number uint64, hash, root common.Hash) (*richList, error) {
+	statedb, err := r.chain.StateAt(root)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		richest = make(richHeap, 0, r.size+1)
+		buckets = make([]uint64, richListBuckets)
+		ether   = big.NewInt(params.Ether)
+		whole   = new(big.Int)
+	)
+	statedb.ForEachBalance(func(addr common.Address, balance *big.Int) {
+		// Count the account in the bucket of its balance decade
+		bucket := 0
+		for whole.Div(balance, ether); whole.Sign() > 0 && bucket < richListBuckets-1; whole.Div(whole, big.NewInt(10)) {
+			bucket++
+		}
+		buckets[bucket]++
+
+		// Retain the account if it's among the richest
+		if len(richest) < r.size {
+			heap.Push(&richest, richAccount{addr, new(big.Int).Set(balance)})
+		} else if r.size > 0 && balance.Cmp(richest[0].Balance) > 0 {
+			richest[0] = richAccount{addr, new(big.Int).Set(balance)}
+			heap.Fix(&richest, 0)
+		}
+	})
+	accounts := make([]richAccount, len(richest))
+	for i := len(accounts) - 1; i >= 0; i-- {
+		accounts[i] = heap.Pop(&richest).(richAccount)
+	}
+	return &richList{Number: number, Hash: hash, Accounts: accounts, Buckets: buckets}, nil
+}
+
+// ranking returns the last built ranking in its API representation.
+func (r *richListIndexer) ranking() (*RichList, error) {
+	r.lock.RLock()
+	list := r.list
+	r.lock.RUnlock()
+
+	if list == nil {
+		return nil, errRichListPending
+	}
+	result := &RichList{
+		Number: hexutil.Uint64(list.Number),
+		Hash:   list.Hash,
+	}
+	for _, account := range list.Accounts {
+		result.Accounts = append(result.Accounts, RichAccount{common.ChecksumAddress(account.Address), (*hexutil.Big)(account.Balance)})
+	}
+	min := new(big.Int)
+	max := big.NewInt(params.Ether)
+	for i, count := range list.Buckets {
+		bucket := BalanceBucket{Min: (*hexutil.Big)(min), Count: hexutil.Uint64(count)}
+		if i < len(list.Buckets)-1 {
+			bucket.Max = (*hexutil.Big)(max)
+		}
+		result.Buckets = append(result.Buckets, bucket)
+		result.Total += hexutil.Uint64(count)
+
+		min, max = max, new(big.Int).Mul(max, big.NewInt(10))
+	}
+	return result, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// Tests that the rich list ranks the accounts of the last confirmed section
+// and counts them by balance bucket.
+func TestRichListIndexing(t *testing.T) {
+	ether := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.Ether)) }
+
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank:          {Balance: ether(1000000000)},
+				common.Address{1}: {Balance: ether(5)},
+				common.Address{2}: {Balance: ether(50000)},
+				common.Address{3}: {Balance: big.NewInt(1)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	gspec.MustCommit(gendb)
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, chainStatsSectionSize+chainStatsConfirmations+10, func(i int, block *core.BlockGen) {
+		if i < 16 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{0x10, byte(i)}, ether(int64(1)<<uint(i)), bigTxGas, nil, nil), signer, testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	indexer := newRichListIndexer(chain, db, new(mclock.Simulated), func() bool { return false }, 5)
+	if _, err := indexer.ranking(); err != errRichListPending {
+		t.Fatalf("unbuilt ranking error mismatch: have %v, want %v", err, errRichListPending)
+	}
+	indexer.step()
+
+	list, err := indexer.ranking()
+	if err != nil {
+		t.Fatalf("failed to retrieve ranking: %v", err)
+	}
+	if want := uint64(chainStatsSectionSize - 1); uint64(list.Number) != want {
+		t.Fatalf("ranked block mismatch: have %d, want %d", list.Number, want)
+	}
+	// Cross check the ranking against the state it was built from
+	statedb, _ := chain.StateAt(chain.GetBlockByNumber(uint64(list.Number)).Root())
+
+	if len(list.Accounts) != 5 {
+		t.Fatalf("ranked account count mismatch: have %d, want 5", len(list.Accounts))
+	}
+	if list.Accounts[0].Address != common.ChecksumAddress(testBank) {
+		t.Errorf("richest account mismatch: have %x, want %x", list.Accounts[0].Address, testBank)
+	}
+	for i, account := range list.Accounts {
+		if balance := statedb.GetBalance(common.Address(account.Address)); balance.Cmp(account.Balance.ToInt()) != 0 {
+			t.Errorf("account %d: balance mismatch: have %v, want %v", i, account.Balance.ToInt(), balance)
+		}
+		if i > 0 && list.Accounts[i-1].Balance.ToInt().Cmp(account.Balance.ToInt()) < 0 {
+			t.Errorf("account %d: not ordered by descending balance", i)
+		}
+	}
+	var (
+		counts = make([]uint64, richListBuckets)
+		total  uint64
+	)
+	statedb.ForEachBalance(func(addr common.Address, balance *big.Int) {
+		for i, bucket := range list.Buckets {
+			if balance.Cmp(bucket.Min.ToInt()) >= 0 && (bucket.Max == nil || balance.Cmp(bucket.Max.ToInt()) < 0) {
+				counts[i]++
+			}
+		}
+		total++
+	})
+	for i, bucket := range list.Buckets {
+		if uint64(bucket.Count) != counts[i] {
+			t.Errorf("bucket %d: account count mismatch: have %d, want %d", i, bucket.Count, counts[i])
+		}
+	}
+	if uint64(list.Total) != total {
+		t.Errorf("total account count mismatch: have %d, want %d", list.Total, total)
+	}
+	// Ensure the ranking is persisted and reloaded
+	if reloaded, _ := newRichListIndexer(chain, db, new(mclock.Simulated), func() bool { return false }, 5).ranking(); reloaded == nil || reloaded.Hash != list.Hash {
+		t.Errorf("ranking not reloaded from the database")
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/params"
+)
+
+// shadowReplayLimit is the maximum number of blocks replayed per chain head
+// event, so a node catching up doesn't stall the replayer's event loop.
+const shadowReplayLimit = 256
+
+var errShadowDisabled = errors.New("shadow fork replay not enabled")
+
+// ShadowStats summarises how the shadow fork diverged from the live chain.
+type ShadowStats struct {
+	Fork            uint64 `json:"fork"`            // Number of the live block the shadow forked off
+	Head            uint64 `json:"head"`            // Number of the last replayed block
+	Blocks          uint64 `json:"blocks"`          // Blocks replayed on the shadow fork
+	Transactions    uint64 `json:"transactions"`    // Transactions replayed on the shadow fork
+	Rejected        uint64 `json:"rejected"`        // Transactions invalid under the shadow rules
+	GasMismatches   uint64 `json:"gasMismatches"`   // Transactions using different gas than live
+	LogMismatches   uint64 `json:"logMismatches"`   // Transactions emitting a different number of logs than live
+	StateMismatches uint64 `json:"stateMismatches"` // Blocks whose shadow state root differs from live
+	FirstDivergence uint64 `json:"firstDivergence"` // First block whose state root diverged (0 = none)
+	Reorgs          uint64 `json:"reorgs"`          // Live reorgs below the shadow head, not rewound
+}
+
+// shadowReplayer follows the canonical chain and re-executes the transactions
+// of every new block on a fork of the state taken at startup, under modified
+// chain rules. The divergence between the live and the shadow executions lets
+// operators rehearse hard forks with real traffic.
+//
+// The shadow state is never committed to the database, so it lives in memory
+// and is lost on restart. Reorgs of the live chain below the shadow head can't
+// be rewound either; the shadow continues on the new canonical chain and counts
+// the reorg.
+type shadowReplayer struct {
+	chain  *core.BlockChain
+	db     ethdb.Database
+	config *params.ChainConfig // Modified rules the transactions are replayed under
+	engine consensus.Engine
+	mux    *event.TypeMux
+
+	state *state.StateDB // Shadow state, kept in memory only
+	next  uint64         // Number of the next block to replay
+	last  common.Hash    // Hash of the last replayed live block
+	stats ShadowStats
+	lock  sync.RWMutex
+
+	sub  *event.TypeMuxSubscription
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newShadowReplayer forks the shadow state off the current head of the chain.
+func newShadowReplayer(chain *core.BlockChain, db ethdb.Database, config *params.ChainConfig, engine consensus.Engine, mux *event.TypeMux) (*shadowReplayer, error) {
+	head := chain.CurrentBlock()
+	statedb, err := chain.StateAt(head.Root())
+	if err != nil {
+		return nil, err
+	}
+	return &shadowReplayer{
+		chain:  chain,
+		db:     db,
+		config: config,
+		engine: engine,
+		mux:    mux,
+		state:  statedb,
+		next:   head.NumberU64() + 1,
+		last:   head.Hash(),
+		stats:  ShadowStats{Fork: head.NumberU64(), Head: head.NumberU64()},
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// start spins up the replay loop.
+func (s *shadowReplayer) start() {
+	s.sub = s.mux.Subscribe(core.ChainHeadEvent{})
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// stop terminates the replay loop, waiting for any running replay.
+func (s *shadowReplayer) stop() {
+	s.sub.Unsubscribe()
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// loop replays the canonical chain up to every new head.
+func (s *shadowReplayer) loop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case _, ok := <-s.sub.Chan():
+			if !ok {
+				return
+			}
+			s.step()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// step replays the canonical blocks between the shadow and the live head.
+func (s *shadowReplayer) step() {
+	head := s.chain.CurrentBlock().NumberU64()
+	for replayed := 0; s.next <= head && replayed < shadowReplayLimit; replayed++ {
+		block := s.chain.GetBlockByNumber(s.next)
+		if block == nil {
+			return
+		}
+		if err := s.replay(block); err != nil {
+			log.Error("Shadow fork replay failed", "number", block.Number(), "hash", block.Hash(), "err", err)
+			return
+		}
+	}
+}
+
+// replay re-executes the block on the shadow state and records the divergence
+// from its live execution.
+func (s *shadowReplayer) replay(block *types.Block) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if block.ParentHash() != s.last {
+		log.Warn("Live chain reorged below shadow head", "number", block.Number(), "hash", block.Hash())
+		s.stats.Reorgs++
+	}
+	var (
+		header  = block.Header()
+		live    = core.GetBlockReceipts(s.db, block.Hash(), block.NumberU64())
+		gp      = new(core.GasPool).AddGas(block.GasLimit())
+		usedGas = new(big.Int)
+	)
+	author, err := s.engine.Author(header)
+	if err != nil {
+		return err
+	}
+	if s.config.DAOForkSupport && s.config.DAOForkBlock != nil && s.config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		core.ApplyDAOHardFork(s.state)
+	}
+	for i, tx := range block.Transactions() {
+		s.state.StartRecord(tx.Hash(), block.Hash(), i)
+		snap := s.state.Snapshot()
+
+		receipt, gas, err := core.ApplyTransaction(s.config, s.chain, &author, gp, s.state, header, tx, usedGas, vm.Config{})
+		s.stats.Transactions++
+		if err != nil {
+			log.Debug("Transaction rejected on shadow fork", "hash", tx.Hash(), "err", err)
+			s.state.RevertToSnapshot(snap)
+			s.stats.Rejected++
+			continue
+		}
+		if i < len(live) {
+			if gas.Cmp(live[i].GasUsed) != 0 {
+				s.stats.GasMismatches++
+			}
+			if len(receipt.Logs) != len(live[i].Logs) {
+				s.stats.LogMismatches++
+			}
+		}
+	}
+	if err := s.engine.Finalize(s.chain, s.state, header, block.Uncles()); err != nil {
+		return err
+	}
+	if root := s.state.IntermediateRoot(s.config.IsEIP158(block.Number())); root != block.Root() {
+		if s.stats.StateMismatches == 0 {
+			log.Warn("Shadow fork state diverged", "number", block.Number(), "hash", block.Hash(), "live", block.Root(), "shadow", root)
+			s.stats.FirstDivergence = block.NumberU64()
+		}
+		s.stats.StateMismatches++
+	}
+	s.stats.Blocks++
+	s.stats.Head = block.NumberU64()
+	s.next, s.last = block.NumberU64()+1, block.Hash()
+	return nil
+}
+
+// Stats returns the divergence statistics of the shadow fork so far.
+func (s *shadowReplayer) Stats() ShadowStats {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.stats
+}
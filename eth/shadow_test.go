@@ -0,0 +1,83 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// Tests that the shadow fork replays the live transactions and reports where
+// the modified rules make their execution diverge.
+func TestShadowReplay(t *testing.T) {
+	// Replaying under the live rules shouldn't diverge at all
+	stats := testShadowReplay(t, params.TestChainConfig)
+	if stats.Blocks != 8 || stats.Transactions != 8 || stats.Head != 8 {
+		t.Errorf("replay progress mismatch: %+v", stats)
+	}
+	if stats.Rejected != 0 || stats.GasMismatches != 0 || stats.StateMismatches != 0 || stats.FirstDivergence != 0 {
+		t.Errorf("identical rules diverged: %+v", stats)
+	}
+	// Replaying on another chain id rejects all the replay protected transactions
+	config := *params.TestChainConfig
+	config.ChainId = big.NewInt(2)
+
+	stats = testShadowReplay(t, &config)
+	if stats.Rejected != 8 || stats.StateMismatches != 8 || stats.FirstDivergence != 1 {
+		t.Errorf("modified rules divergence mismatch: %+v", stats)
+	}
+}
+
+func testShadowReplay(t *testing.T, config *params.ChainConfig) ShadowStats {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	gspec.MustCommit(gendb)
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, 8, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{0x01}, big.NewInt(1000), bigTxGas, nil, nil), signer, testBankKey)
+		block.AddTx(tx)
+	})
+	engine := core.NewPowEngine(new(pow.FakePow))
+	chain, _ := core.NewBlockChain(db, gspec.Config, engine, new(event.TypeMux), vm.Config{})
+
+	shadow, err := newShadowReplayer(chain, db, config, engine, new(event.TypeMux))
+	if err != nil {
+		t.Fatalf("failed to create shadow replayer: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	shadow.step()
+	return shadow.Stats()
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+const (
+	snapshotChunkSize     = 64 * 1024        // Target size of the chunks a state snapshot is split into
+	snapshotConfirmations = 64               // Blocks a block must be buried under before its state is snapshotted
+	snapshotStepInterval  = 10 * time.Second // Time to wait between two snapshot checks
+)
+
+// snapshotter periodically snapshots the state of the canonical chain for peers
+// to restore it from during snapshot sync. Only a single snapshot is served at a
+// time: the one of the most recent block at a multiple of the interval, buried
+// deep enough to be safe from reorgs. As chunk generation is deterministic,
+// nodes snapshotting the same block serve identical chunks, allowing syncing
+// nodes to download them from every peer.
+type snapshotter struct {
+	chain    *core.BlockChain
+	db       ethdb.Database
+	clock    mclock.Clock
+	syncing  func() bool // Reports whether chain synchronisation is running
+	interval uint64      // Number of blocks between two snapshots
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newSnapshotter creates a state snapshotter for the given chain, generating a
+// snapshot every interval blocks.
+func newSnapshotter(chain *core.BlockChain, db ethdb.Database, clock mclock.Clock, syncing func() bool, interval uint64) *snapshotter {
+	return &snapshotter{
+		chain:    chain,
+		db:       db,
+		clock:    clock,
+		syncing:  syncing,
+		interval: interval,
+		quit:     make(chan struct{}),
+	}
+}
+
+// start spins up the snapshotting loop.
+func (s *snapshotter) start() {
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// stop terminates the snapshotting loop, waiting for any running step.
+func (s *snapshotter) stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// loop keeps snapshotting the state as the chain grows.
+func (s *snapshotter) loop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.clock.After(snapshotStepInterval):
+			s.step()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// step snapshots the state of the most recent confirmed block at a multiple of
+// the interval, if not done yet, replacing the previously served snapshot.
+func (s *snapshotter) step() {
+	if s.syncing() {
+		return
+	}
+	head := s.chain.CurrentBlock().NumberU64()
+	if head < snapshotConfirmations {
+		return
+	}
+	number := (head - snapshotConfirmations) / s.interval * s.interval
+	if number == 0 {
+		return
+	}
+	header := s.chain.GetHeaderByNumber(number)
+	if header == nil {
+		return
+	}
+	old := core.GetSnapshotManifest(s.db)
+	if old != nil && old.Hash == header.Hash() {
+		return
+	}
+	// Hold off state pruning while reading the state to snapshot
+	guard := s.chain.PruneGuard()
+	guard.Lock()
+	defer guard.Unlock()
+
+	// The state may be missing if fast synced past it or pruned since
+	if _, err := state.New(header.Root, s.db); err != nil {
+		log.Debug("State unavailable for snapshot", "number", number, "hash", header.Hash(), "err", err)
+		return
+	}
+	reused := make(map[common.Hash]bool)
+	if old != nil {
+		for _, hash := range old.Chunks {
+			reused[hash] = false
+		}
+	}
+	var (
+		start   = time.Now()
+		written []common.Hash
+	)
+	chunks, err := state.GenerateSnapshot(s.db, header.Root, snapshotChunkSize, func(hash common.Hash, blob []byte) error {
+		if _, ok := reused[hash]; ok {
+			reused[hash] = true
+			return nil
+		}
+		written = append(written, hash)
+		return core.WriteSnapshotChunk(s.db, hash, blob)
+	})
+	if err != nil {
+		log.Warn("Failed to generate state snapshot", "number", number, "hash", header.Hash(), "err", err)
+		for _, hash := range written {
+			core.DeleteSnapshotChunk(s.db, hash)
+		}
+		return
+	}
+	manifest := &state.SnapshotManifest{
+		Number: number,
+		Hash:   header.Hash(),
+		Root:   header.Root,
+		Chunks: chunks,
+	}
+	if err := core.WriteSnapshotManifest(s.db, manifest); err != nil {
+		log.Warn("Failed to store snapshot manifest", "number", number, "err", err)
+		return
+	}
+	// Drop the chunks of the replaced snapshot not shared with the new one
+	for hash, shared := range reused {
+		if !shared {
+			core.DeleteSnapshotChunk(s.db, hash)
+		}
+	}
+	log.Info("Generated state snapshot", "number", number, "hash", header.Hash(), "chunks", len(chunks), "elapsed", common.PrettyDuration(time.Since(start)))
+}
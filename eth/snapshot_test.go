@@ -0,0 +1,107 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// Tests that the snapshotter serves a restorable snapshot of the most recent
+// confirmed block at a multiple of the interval, replacing it as the chain grows.
+func TestSnapshotter(t *testing.T) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		gendb, _ = ethdb.NewMemDatabase()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	gspec.MustCommit(gendb)
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, 300, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{byte(i)}, big.NewInt(1000), bigTxGas, nil, nil), signer, testBankKey)
+		block.AddTx(tx)
+	})
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	if _, err := chain.InsertChain(blocks[:200]); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	snapshotter := newSnapshotter(chain, db, new(mclock.Simulated), func() bool { return false }, 50)
+
+	// Snapshot the chain and check that the served snapshot restores
+	snapshotter.step()
+	old := core.GetSnapshotManifest(db)
+	if old == nil || old.Number != 100 || old.Hash != blocks[99].Hash() {
+		t.Fatalf("snapshot manifest mismatch: have %+v, want block #100", old)
+	}
+	checkSnapshot(t, db, old)
+
+	// Grow the chain and check that the snapshot is replaced
+	if _, err := chain.InsertChain(blocks[200:]); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	snapshotter.step()
+	manifest := core.GetSnapshotManifest(db)
+	if manifest == nil || manifest.Number != 200 || manifest.Hash != blocks[199].Hash() {
+		t.Fatalf("snapshot manifest mismatch: have %+v, want block #200", manifest)
+	}
+	checkSnapshot(t, db, manifest)
+
+	served := make(map[common.Hash]bool)
+	for _, hash := range manifest.Chunks {
+		served[hash] = true
+	}
+	for i, hash := range old.Chunks {
+		if !served[hash] && core.GetSnapshotChunk(db, hash) != nil {
+			t.Errorf("replaced chunk %d not deleted", i)
+		}
+	}
+}
+
+// checkSnapshot restores a served snapshot into an empty database, checking that
+// it reproduces the snapshotted state.
+func checkSnapshot(t *testing.T, db ethdb.Database, manifest *state.SnapshotManifest) {
+	restoreDb, _ := ethdb.NewMemDatabase()
+	restorer := state.NewSnapshotRestorer(restoreDb, manifest.Root)
+	for i, hash := range manifest.Chunks {
+		chunk := core.GetSnapshotChunk(db, hash)
+		if chunk == nil {
+			t.Fatalf("snapshot chunk %d missing", i)
+		}
+		if err := restorer.Feed(chunk); err != nil {
+			t.Fatalf("failed to restore chunk %d: %v", i, err)
+		}
+	}
+	if err := restorer.Finish(); err != nil {
+		t.Fatalf("failed to finish restoration: %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+const (
+	transferSectionSize = 4096   // Number of blocks per section of the address lookups
+	transferStepLimit   = 256    // Maximum number of blocks to index per chain head event
+	transferRangeLimit  = 100000 // Maximum number of blocks to look up transfers in at once
+)
+
+var (
+	transferBlockPrefix   = []byte("InternalTx-b")       // transferBlockPrefix + number (uint64 big endian) + hash -> []internalTransfer
+	transferAddressPrefix = []byte("InternalTx-a")       // transferAddressPrefix + address + section (uint64 big endian) -> []uint64
+	transferProgressKey   = []byte("InternalTxProgress") // First block indexed and first block not yet indexed
+
+	errTransfersDisabled = errors.New("internal transaction index not enabled")
+	errTransfersRange    = errors.New("invalid block range")
+)
+
+// Kinds of internal value transfers.
+const (
+	transferCall uint8 = iota
+	transferCreate
+	transferSelfdestruct
+)
+
+var transferKinds = []string{"call", "create", "selfdestruct"}
+
+// internalTransfer is a value transfer made by a contract.
+type internalTransfer struct {
+	TxHash common.Hash
+	Kind   uint8
+	From   common.Address
+	To     common.Address
+	Value  *big.Int
+}
+
+// InternalTransfer is a value transfer made by a contract while executing a
+// transaction.
+type InternalTransfer struct {
+	BlockNumber     hexutil.Uint64         `json:"blockNumber"`
+	BlockHash       common.Hash            `json:"blockHash"`
+	TransactionHash common.Hash            `json:"transactionHash"`
+	Type            string                 `json:"type"` // call, create or selfdestruct
+	From            common.ChecksumAddress `json:"from"`
+	To              common.ChecksumAddress `json:"to"`
+	Value           *hexutil.Big           `json:"value"`
+}
+
+// transferTracer is a lightweight vm.Tracer collecting the value transfers made
+// by the CALL, CREATE and SELFDESTRUCT instructions of a transaction. Calls and
+// creations are only collected once the calling frame resumes with a success
+// result on its stack, and transfers made within a call that failed later on
+// are dropped, so only transfers persisted in the state are collected.
+type transferTracer struct {
+	pending   []transferFrame    // Calls and creations awaiting their result
+	transfers []internalTransfer // Successful transfers, in execution order
+}
+
+// transferFrame is a value transferring instruction executed at a given call
+// depth, along with the number of transfers collected before it.
+type transferFrame struct {
+	depth    int
+	start    int
+	transfer internalTransfer
+}
+
+// CaptureState implements vm.Tracer.
+func (t *transferTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	// Resolve the transfers the frame was waiting on once it resumes
+	for len(t.pending) > 0 {
+		last := t.pending[len(t.pending)-1]
+		if depth > last.depth {
+			break
+		}
+		t.pending = t.pending[:len(t.pending)-1]
+
+		success := false
+		if depth == last.depth && len(stack.Data()) > 0 {
+			switch last.transfer.Kind {
+			case transferCall:
+				success = stack.Back(0).Sign() != 0
+			case transferCreate:
+				success = common.BigToAddress(stack.Back(0)) == last.transfer.To
+			}
+		}
+		if success {
+			t.transfers = append(t.transfers, internalTransfer{})
+			copy(t.transfers[last.start+1:], t.transfers[last.start:])
+			t.transfers[last.start] = last.transfer
+		} else {
+			t.transfers = t.transfers[:last.start]
+		}
+	}
+	if err != nil {
+		// A failing top level frame reverts every transfer of the transaction
+		if depth == 1 {
+			t.transfers = nil
+		}
+		return nil
+	}
+	switch op {
+	case vm.CALL:
+		if value := stack.Back(2); value.Sign() > 0 {
+			t.pending = append(t.pending, transferFrame{depth, len(t.transfers), internalTransfer{
+				Kind: transferCall, From: contract.Address(), To: common.BigToAddress(stack.Back(1)), Value: new(big.Int).Set(value),
+			}})
+		}
+	case vm.CREATE, vm.CREATE2:
+		if value := stack.Back(0); value.Sign() > 0 {
+			creator := contract.Address()
+			t.pending = append(t.pending, transferFrame{depth, len(t.transfers), internalTransfer{
+				Kind: transferCreate, From: creator, To: creationAddress(env, op, creator, memory, stack), Value: new(big.Int).Set(value),
+			}})
+		}
+	case vm.SELFDESTRUCT:
+		if balance := env.StateDB.GetBalance(contract.Address()); balance.Sign() > 0 {
+			t.transfers = append(t.transfers, internalTransfer{
+				Kind: transferSelfdestruct, From: contract.Address(), To: common.BigToAddress(stack.Back(0)), Value: new(big.Int).Set(balance),
+			})
+		}
+	}
+	return nil
+}
+
+// transferIndexer records the internal value transfers of the canonical chain,
+// found by replaying every imported block with a transferTracer, so explorers
+// can serve complete balance histories without retracing transactions.
+//
+// The transfers of every block are stored by block number and hash, and the
+// blocks involving an address are listed per section of the chain. Blocks
+// reorged out are rewound and their replacements indexed, while lookups only
+// ever return transfers of canonical blocks.
+//
+// Indexing starts at the head of the chain when first enabled, since older
+// states might not be available for replaying.
+type transferIndexer struct {
+	chain  *core.BlockChain
+	db     ethdb.Database
+	config *params.ChainConfig
+	mux    *event.TypeMux
+
+	first uint64 // First block indexed, reorgs aren't rewound past it
+	next  uint64 // First block not yet indexed
+
+	sub  *event.TypeMuxSubscription
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newTransferIndexer creates an internal transfer indexer for the given chain,
+// resuming from the progress persisted in db.
+func newTransferIndexer(chain *core.BlockChain, db ethdb.Database, config *params.ChainConfig, mux *event.TypeMux) *transferIndexer {
+	t := &transferIndexer{
+		chain:  chain,
+		db:     db,
+		config: config,
+		mux:    mux,
+		first:  chain.CurrentBlock().NumberU64() + 1,
+		quit:   make(chan struct{}),
+	}
+	t.next = t.first
+	if data, _ := db.Get(transferProgressKey); len(data) == 16 {
+		t.first, t.next = binary.BigEndian.Uint64(data[:8]), binary.BigEndian.Uint64(data[8:])
+	}
+	return t
+}
+
+// indexed returns the range of blocks whose internal transfers are indexed, as
+// last persisted (not racing with the indexing loop). The returned ok flag is
+// false if no block was indexed yet.
+func (t *transferIndexer) indexed() (from, to uint64, ok bool) {
+	data, _ := t.db.Get(transferProgressKey)
+	if len(data) != 16 {
+		return 0, 0, false
+	}
+	first, next := binary.BigEndian.Uint64(data[:8]), binary.BigEndian.Uint64(data[8:])
+	if next <= first {
+		return 0, 0, false
+	}
+	return first, next - 1, true
+}
+
+// start spins up the indexing loop.
+func (t *transferIndexer) start() {
+	t.sub = t.mux.Subscribe(core.ChainHeadEvent{})
+	t.wg.Add(1)
+	go t.loop()
+}
+
+// stop terminates the indexing loop, waiting for any running step.
+func (t *transferIndexer) stop() {
+	t.sub.Unsubscribe()
+	close(t.quit)
+	t.wg.Wait()
+}
+
+// loop indexes the canonical chain up to every new head.
+func (t *transferIndexer) loop() {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case _, ok := <-t.sub.Chan():
+			if !ok {
+				return
+			}
+			t.step()
+
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// step rewinds the blocks reorged out since the last step, indexes the next
+// range of canonical blocks and persists the progress made.
+func (t *transferIndexer) step() {
+	// Rewind to the last canonical block indexed
+	for t.next > t.first {
+		number := t.next - 1
+		if data, _ := t.db.Get(transferBlockKey(number, core.GetCanonicalHash(t.db, number))); data != nil {
+			break
+		}
+		t.next--
+	}
+	var (
+		head     = t.chain.CurrentBlock().NumberU64()
+		batch    = t.db.NewBatch()
+		sections = make(map[string][]uint64) // Address lookups modified by this step
+	)
+	for start := t.next; t.next <= head && t.next-start < transferStepLimit; t.next++ {
+		block := t.chain.GetBlockByNumber(t.next)
+		if block == nil {
+			break
+		}
+		transfers, err := t.trace(block)
+		if err != nil {
+			// Leave the block unindexed and retry it on the next head
+			log.Warn("Failed to index internal transactions", "number", block.Number(), "hash", block.Hash(), "err", err)
+			break
+		}
+		enc, _ := rlp.EncodeToBytes(transfers)
+		batch.Put(transferBlockKey(block.NumberU64(), block.Hash()), enc)
+
+		for _, transfer := range transfers {
+			for _, address := range []common.Address{transfer.From, transfer.To} {
+				key := string(transferAddressKey(address, block.NumberU64()/transferSectionSize))
+				numbers, ok := sections[key]
+				if !ok {
+					numbers = t.addressBlocks([]byte(key))
+				}
+				if len(numbers) == 0 || numbers[len(numbers)-1] < block.NumberU64() {
+					numbers = append(numbers, block.NumberU64())
+				}
+				sections[key] = numbers
+			}
+		}
+	}
+	for key, numbers := range sections {
+		enc, _ := rlp.EncodeToBytes(numbers)
+		batch.Put([]byte(key), enc)
+	}
+	var enc [16]byte
+	binary.BigEndian.PutUint64(enc[:8], t.first)
+	binary.BigEndian.PutUint64(enc[8:], t.next)
+	batch.Put(transferProgressKey, enc[:])
+	if err := batch.Write(); err != nil {
+		log.Warn("Failed to store internal transactions", "err", err)
+	}
+}
+
+// trace replays a block, collecting the internal transfers of its transactions.
+func (t *transferIndexer) trace(block *types.Block) ([]internalTransfer, error) {
+	if len(block.Transactions()) == 0 {
+		return nil, nil
+	}
+	parent := t.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, errors.New("parent block not available")
+	}
+	statedb, err := t.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	var transfers []internalTransfer
+	err = replayBlock(t.chain, t.config, block, statedb, func() vm.Tracer { return new(transferTracer) }, func(tx *types.Transaction, receipt *types.Receipt, tracer vm.Tracer) {
+		for _, transfer := range tracer.(*transferTracer).transfers {
+			transfer.TxHash = tx.Hash()
+			transfers = append(transfers, transfer)
+		}
+	})
+	return transfers, err
+}
+
+// addressBlocks returns the numbers of the blocks listed by an address lookup.
+func (t *transferIndexer) addressBlocks(key []byte) []uint64 {
+	var numbers []uint64
+	if data, _ := t.db.Get(key); len(data) > 0 {
+		if err := rlp.DecodeBytes(data, &numbers); err != nil {
+			log.Error("Invalid internal transaction lookup RLP", "err", err)
+		}
+	}
+	return numbers
+}
+
+// transfers returns the internal transfers from or to an address within the
+// given range of canonical blocks.
+func (t *transferIndexer) transfers(address common.Address, from, to uint64) ([]*InternalTransfer, error) {
+	if from > to || to-from >= transferRangeLimit {
+		return nil, errTransfersRange
+	}
+	result := []*InternalTransfer{}
+	for section := from / transferSectionSize; section <= to/transferSectionSize; section++ {
+		for _, number := range t.addressBlocks(transferAddressKey(address, section)) {
+			if number < from || number > to {
+				continue
+			}
+			hash := core.GetCanonicalHash(t.db, number)
+			data, _ := t.db.Get(transferBlockKey(number, hash))
+			if len(data) == 0 {
+				continue
+			}
+			var transfers []internalTransfer
+			if err := rlp.DecodeBytes(data, &transfers); err != nil {
+				return nil, err
+			}
+			for _, transfer := range transfers {
+				if transfer.From != address && transfer.To != address {
+					continue
+				}
+				result = append(result, &InternalTransfer{
+					BlockNumber:     hexutil.Uint64(number),
+					BlockHash:       hash,
+					TransactionHash: transfer.TxHash,
+					Type:            transferKinds[transfer.Kind],
+					From:            common.ChecksumAddress(transfer.From),
+					To:              common.ChecksumAddress(transfer.To),
+					Value:           (*hexutil.Big)(transfer.Value),
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// transferBlockKey returns the database key of the transfers of a block.
+func transferBlockKey(number uint64, hash common.Hash) []byte {
+	key := make([]byte, len(transferBlockPrefix)+8+common.HashLength)
+	copy(key, transferBlockPrefix)
+	binary.BigEndian.PutUint64(key[len(transferBlockPrefix):], number)
+	copy(key[len(transferBlockPrefix)+8:], hash[:])
+	return key
+}
+
+// transferAddressKey returns the database key of the blocks of a section with
+// transfers from or to an address.
+func transferAddressKey(address common.Address, section uint64) []byte {
+	key := make([]byte, len(transferAddressPrefix)+common.AddressLength+8)
+	copy(key, transferAddressPrefix)
+	copy(key[len(transferAddressPrefix):], address[:])
+	binary.BigEndian.PutUint64(key[len(transferAddressPrefix)+common.AddressLength:], section)
+	return key
+}
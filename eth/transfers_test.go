@@ -0,0 +1,173 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// Tests that value transfers made by contracts are indexed by address, that the
+// ones of failed transactions are not, and that reorged blocks are reindexed.
+func TestTransferIndexing(t *testing.T) {
+	var (
+		receiver   = common.Address{0xaa}
+		forwarder  = common.Address{0x01} // Forwards the call value to the receiver
+		failing    = common.Address{0x02} // Forwards the call value, then fails
+		destructor = common.Address{0x03} // Self destructs to the receiver
+
+		// PUSH1 0 PUSH1 0 PUSH1 0 PUSH1 0 CALLVALUE PUSH20 receiver GAS CALL POP, then the given op
+		forward = func(op byte) []byte {
+			return append(append([]byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x34, 0x73}, receiver[:]...), 0x5a, 0xf1, 0x50, op)
+		}
+
+		db, _ = ethdb.NewMemDatabase()
+		gspec = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank:   {Balance: big.NewInt(1000000000)},
+				forwarder:  {Balance: new(big.Int), Code: forward(0x00)},
+				failing:    {Balance: new(big.Int), Code: forward(0xfe)},
+				destructor: {Balance: big.NewInt(7), Code: append([]byte{0x73}, append(receiver[:], 0xff)...)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+		gas     = big.NewInt(100000)
+	)
+	// generate creates a chain calling the given contracts with the given values
+	// in its first blocks.
+	generate := func(n int, calls [][]common.Address, values []int64) []*types.Block {
+		gendb, _ := ethdb.NewMemDatabase()
+		gspec.MustCommit(gendb)
+
+		blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, n, func(i int, block *core.BlockGen) {
+			if i >= len(calls) {
+				return
+			}
+			for _, to := range calls[i] {
+				tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), to, big.NewInt(values[i]), gas, nil, nil), signer, testBankKey)
+				block.AddTx(tx)
+			}
+		})
+		return blocks
+	}
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	indexer := newTransferIndexer(chain, db, gspec.Config, new(event.TypeMux))
+
+	blocks := generate(3, [][]common.Address{{forwarder, failing}, {destructor}}, []int64{1000, 0})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	indexer.step()
+
+	check := func(address common.Address, want []InternalTransfer) {
+		have, err := indexer.transfers(address, 0, chain.CurrentBlock().NumberU64())
+		if err != nil {
+			t.Fatalf("failed to look up transfers of %x: %v", address, err)
+		}
+		if len(have) != len(want) {
+			t.Fatalf("transfer count mismatch for %x: have %d, want %d", address, len(have), len(want))
+		}
+		for i := range want {
+			if have[i].BlockNumber != want[i].BlockNumber || have[i].Type != want[i].Type || have[i].From != want[i].From || have[i].To != want[i].To || have[i].Value.ToInt().Cmp(want[i].Value.ToInt()) != 0 {
+				t.Errorf("transfer %d of %x mismatch: have %+v, want %+v", i, address, have[i], want[i])
+			}
+		}
+	}
+	transfer := func(number uint64, kind string, from common.Address, value int64) InternalTransfer {
+		return InternalTransfer{BlockNumber: hexutil.Uint64(number), Type: kind, From: common.ChecksumAddress(from), To: common.ChecksumAddress(receiver), Value: (*hexutil.Big)(big.NewInt(value))}
+	}
+	check(receiver, []InternalTransfer{transfer(1, "call", forwarder, 1000), transfer(2, "selfdestruct", destructor, 7)})
+	check(forwarder, []InternalTransfer{transfer(1, "call", forwarder, 1000)})
+	check(failing, nil)
+
+	// Reorg to a longer chain with different transfers and ensure it's reindexed
+	fork := generate(4, [][]common.Address{{}, {forwarder}}, []int64{0, 2000})
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to import fork: %v", err)
+	}
+	indexer.step()
+
+	check(receiver, []InternalTransfer{transfer(2, "call", forwarder, 2000)})
+	check(destructor, nil)
+}
+
+// Tests that blocks failing to be traced are left unindexed and retried on the
+// next step instead of being recorded without their transfers.
+func TestTransferIndexingRetry(t *testing.T) {
+	var (
+		receiver  = common.Address{0xaa}
+		forwarder = common.Address{0x01}
+
+		db, _ = ethdb.NewMemDatabase()
+		gspec = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank:  {Balance: big.NewInt(1000000000)},
+				forwarder: {Balance: new(big.Int), Code: append(append([]byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x34, 0x73}, receiver[:]...), 0x5a, 0xf1, 0x50, 0x00)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	gendb, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(gendb)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, gendb, 1, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), forwarder, big.NewInt(1000), big.NewInt(100000), nil, nil), types.HomesteadSigner{}, testBankKey)
+		block.AddTx(tx)
+	})
+	chain, _ := core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	// Drop the state the block is traced on, reopening the chain to flush its caches
+	root, _ := db.Get(genesis.Root().Bytes())
+	db.Delete(genesis.Root().Bytes())
+
+	chain, _ = core.NewBlockChain(db, gspec.Config, core.NewPowEngine(new(pow.FakePow)), new(event.TypeMux), vm.Config{})
+	indexer := newTransferIndexer(chain, db, gspec.Config, new(event.TypeMux))
+	indexer.first, indexer.next = 1, 1
+
+	indexer.step()
+	if _, _, ok := indexer.indexed(); ok {
+		t.Fatalf("block indexed without its state")
+	}
+	// Restore the state and ensure the block is indexed on the next step
+	db.Put(genesis.Root().Bytes(), root)
+	indexer.step()
+
+	if from, to, ok := indexer.indexed(); !ok || from != 1 || to != 1 {
+		t.Fatalf("indexed range mismatch: have %d-%d (%v), want 1-1", from, to, ok)
+	}
+	transfers, err := indexer.transfers(receiver, 1, 1)
+	if err != nil {
+		t.Fatalf("failed to look up transfers: %v", err)
+	}
+	if len(transfers) != 1 {
+		t.Fatalf("transfer count mismatch: have %d, want 1", len(transfers))
+	}
+}
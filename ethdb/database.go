@@ -25,10 +25,12 @@ import (
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/metrics"
 	"github.com/syndtr/goleveldb/leveldb"
+	ldbcache "github.com/syndtr/goleveldb/leveldb/cache"
 	"github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 
 	gometrics "github.com/rcrowley/go-metrics"
 )
@@ -39,6 +41,9 @@ type LDBDatabase struct {
 	fn string      // filename for reporting
 	db *leveldb.DB // LevelDB instance
 
+	blockCache    ldbcache.Cacher // Block cache of the LevelDB instance, resized under memory pressure
+	blockCacheCap int             // Configured capacity of the block cache in bytes
+
 	getTimer       gometrics.Timer // Timer for measuring the database get request counts and latencies
 	putTimer       gometrics.Timer // Timer for measuring the database put request counts and latencies
 	delTimer       gometrics.Timer // Timer for measuring the database delete request counts and latencies
@@ -68,27 +73,52 @@ func NewLDBDatabase(file string, cache int, handles int) (*LDBDatabase, error) {
 	}
 	logger.Info("Allocated cache and file handles", "cache", cache, "handles", handles)
 
-	// Open the db and recover any potential corruptions
-	db, err := leveldb.OpenFile(file, &opt.Options{
+	// Open the db and recover any potential corruptions, retaining the block cache
+	var blockCache ldbcache.Cacher
+	options := &opt.Options{
 		OpenFilesCacheCapacity: handles,
 		BlockCacheCapacity:     cache / 2 * opt.MiB,
-		WriteBuffer:            cache / 4 * opt.MiB, // Two of these are used internally
-		Filter:                 filter.NewBloomFilter(10),
-	})
+		BlockCacher: &opt.CacherFunc{NewFunc: func(capacity int) ldbcache.Cacher {
+			blockCache = ldbcache.NewLRU(capacity)
+			return blockCache
+		}},
+		WriteBuffer: cache / 4 * opt.MiB, // Two of these are used internally
+		Filter:      filter.NewBloomFilter(10),
+	}
+	db, err := leveldb.OpenFile(file, options)
 	if _, corrupted := err.(*errors.ErrCorrupted); corrupted {
-		db, err = leveldb.RecoverFile(file, nil)
+		db, err = leveldb.RecoverFile(file, options)
 	}
 	// (Re)check for errors and abort if opening of the db failed
 	if err != nil {
 		return nil, err
 	}
 	return &LDBDatabase{
-		fn:  file,
-		db:  db,
-		log: logger,
+		fn:            file,
+		db:            db,
+		blockCache:    blockCache,
+		blockCacheCap: options.BlockCacheCapacity,
+		log:           logger,
 	}, nil
 }
 
+// SetCacheScale resizes the LevelDB block cache to the given fraction of its
+// configured capacity, evicting cached blocks if it shrinks. A scale of one (or
+// more) restores the configured capacity.
+func (db *LDBDatabase) SetCacheScale(scale float64) {
+	if db.blockCache == nil {
+		return
+	}
+	if scale > 1 {
+		scale = 1
+	}
+	capacity := int(float64(db.blockCacheCap) * scale)
+	if capacity < opt.MiB && capacity < db.blockCacheCap {
+		capacity = opt.MiB
+	}
+	db.blockCache.SetCapacity(capacity)
+}
+
 // Path returns the path to the database directory.
 func (db *LDBDatabase) Path() string {
 	return db.fn
@@ -165,26 +195,42 @@ func (db *LDBDatabase) Close() {
 	}
 }
 
+// Compact flattens the underlying data store for the given key range. In essence,
+// deleted and overwritten versions are discarded, and the data is rearranged to
+// reduce the cost of operations needed to access it. A nil start is treated as a
+// key before all keys in the database, a nil limit as a key after all of them.
+func (db *LDBDatabase) Compact(start []byte, limit []byte) error {
+	return db.db.CompactRange(util.Range{Start: start, Limit: limit})
+}
+
 func (db *LDBDatabase) LDB() *leveldb.DB {
 	return db.db
 }
 
-// Meter configures the database metrics collectors and
+// Meter configures the database metrics collectors and starts the periodic
+// compaction stats collection, reporting into the default metrics registry.
 func (db *LDBDatabase) Meter(prefix string) {
+	db.MeterRegistry(nil, prefix)
+}
+
+// MeterRegistry configures the database metrics collectors within the given
+// registry, allowing multiple databases of the same kind (e.g. belonging to
+// different nodes) to be metered within a single process.
+func (db *LDBDatabase) MeterRegistry(registry gometrics.Registry, prefix string) {
 	// Short circuit metering if the metrics system is disabled
 	if !metrics.Enabled {
 		return
 	}
 	// Initialize all the metrics collector at the requested prefix
-	db.getTimer = metrics.NewTimer(prefix + "user/gets")
-	db.putTimer = metrics.NewTimer(prefix + "user/puts")
-	db.delTimer = metrics.NewTimer(prefix + "user/dels")
-	db.missMeter = metrics.NewMeter(prefix + "user/misses")
-	db.readMeter = metrics.NewMeter(prefix + "user/reads")
-	db.writeMeter = metrics.NewMeter(prefix + "user/writes")
-	db.compTimeMeter = metrics.NewMeter(prefix + "compact/time")
-	db.compReadMeter = metrics.NewMeter(prefix + "compact/input")
-	db.compWriteMeter = metrics.NewMeter(prefix + "compact/output")
+	db.getTimer = metrics.NewRegisteredTimer(prefix+"user/gets", registry)
+	db.putTimer = metrics.NewRegisteredTimer(prefix+"user/puts", registry)
+	db.delTimer = metrics.NewRegisteredTimer(prefix+"user/dels", registry)
+	db.missMeter = metrics.NewRegisteredMeter(prefix+"user/misses", registry)
+	db.readMeter = metrics.NewRegisteredMeter(prefix+"user/reads", registry)
+	db.writeMeter = metrics.NewRegisteredMeter(prefix+"user/writes", registry)
+	db.compTimeMeter = metrics.NewRegisteredMeter(prefix+"compact/time", registry)
+	db.compReadMeter = metrics.NewRegisteredMeter(prefix+"compact/input", registry)
+	db.compWriteMeter = metrics.NewRegisteredMeter(prefix+"compact/output", registry)
 
 	// Create a quit channel for the periodic collector and run it
 	db.quitLock.Lock()
@@ -278,19 +324,29 @@ func (db *LDBDatabase) NewBatch() Batch {
 }
 
 type ldbBatch struct {
-	db *leveldb.DB
-	b  *leveldb.Batch
+	db   *leveldb.DB
+	b    *leveldb.Batch
+	size int
 }
 
 func (b *ldbBatch) Put(key, value []byte) error {
 	b.b.Put(key, value)
+	b.size += len(value)
 	return nil
 }
 
+func (b *ldbBatch) ValueSize() int {
+	return b.size
+}
+
 func (b *ldbBatch) Write() error {
 	return b.db.Write(b.b, nil)
 }
 
+func (b *ldbBatch) WriteSync() error {
+	return b.db.Write(b.b, &opt.WriteOptions{Sync: true})
+}
+
 type table struct {
 	db     Database
 	prefix string
@@ -339,6 +395,14 @@ func (tb *tableBatch) Put(key, value []byte) error {
 	return tb.batch.Put(append([]byte(tb.prefix), key...), value)
 }
 
+func (tb *tableBatch) ValueSize() int {
+	return tb.batch.ValueSize()
+}
+
 func (tb *tableBatch) Write() error {
 	return tb.batch.Write()
 }
+
+func (tb *tableBatch) WriteSync() error {
+	return tb.batch.WriteSync()
+}
@@ -19,8 +19,10 @@ package ethdb
 import (
 	"os"
 	"path/filepath"
+	"testing"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
 func newDb() *LDBDatabase {
@@ -32,3 +34,29 @@ func newDb() *LDBDatabase {
 
 	return db
 }
+
+// Tests that the block cache of the database shrinks with the cache scale and is
+// restored to its configured capacity once the scale recovers.
+func TestCacheScale(t *testing.T) {
+	db := newDb()
+	defer db.Close()
+
+	if db.blockCache == nil {
+		t.Fatalf("block cache not retained")
+	}
+	if have := db.blockCache.Capacity(); have != db.blockCacheCap {
+		t.Fatalf("initial capacity mismatch: have %d, want %d", have, db.blockCacheCap)
+	}
+	db.SetCacheScale(0.5)
+	if have, want := db.blockCache.Capacity(), db.blockCacheCap/2; have != want {
+		t.Fatalf("shrunk capacity mismatch: have %d, want %d", have, want)
+	}
+	db.SetCacheScale(0)
+	if have, want := db.blockCache.Capacity(), opt.MiB; have != want {
+		t.Fatalf("minimum capacity mismatch: have %d, want %d", have, want)
+	}
+	db.SetCacheScale(2)
+	if have := db.blockCache.Capacity(); have != db.blockCacheCap {
+		t.Fatalf("restored capacity mismatch: have %d, want %d", have, db.blockCacheCap)
+	}
+}
@@ -16,6 +16,16 @@
 
 package ethdb
 
+// IdealBatchSize is the amount of data, in bytes, worth accumulating in a batch
+// before writing it out to the database.
+const IdealBatchSize = 100 * 1024
+
+// Putter wraps the database write operation supported by both batches and
+// regular databases.
+type Putter interface {
+	Put(key []byte, value []byte) error
+}
+
 type Database interface {
 	Put(key []byte, value []byte) error
 	Get(key []byte) ([]byte, error)
@@ -26,5 +36,7 @@ type Database interface {
 
 type Batch interface {
 	Put(key, value []byte) error
+	ValueSize() int // amount of data in the batch
 	Write() error
+	WriteSync() error // like Write, but waits for the data to reach stable storage
 }
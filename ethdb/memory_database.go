@@ -100,6 +100,7 @@ type kv struct{ k, v []byte }
 type memBatch struct {
 	db     *MemDatabase
 	writes []kv
+	size   int
 	lock   sync.RWMutex
 }
 
@@ -108,9 +109,17 @@ func (b *memBatch) Put(key, value []byte) error {
 	defer b.lock.Unlock()
 
 	b.writes = append(b.writes, kv{common.CopyBytes(key), common.CopyBytes(value)})
+	b.size += len(value)
 	return nil
 }
 
+func (b *memBatch) ValueSize() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.size
+}
+
 func (b *memBatch) Write() error {
 	b.lock.RLock()
 	defer b.lock.RUnlock()
@@ -123,3 +132,9 @@ func (b *memBatch) Write() error {
 	}
 	return nil
 }
+
+// WriteSync flushes the batch into the database. Memory databases have no stable
+// storage to wait for, so it is equivalent to Write.
+func (b *memBatch) WriteSync() error {
+	return b.Write()
+}
@@ -0,0 +1,244 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package faucet implements a test network faucet service, paying out a fixed
+// amount of ether to the addresses requested through a web form.
+package faucet
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/accounts"
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/eth"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/p2p"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+const (
+	payoutGas        = 21000           // Gas allowance of a payout transaction
+	defaultQueueSize = 256             // Maximum number of payouts waiting to be sent
+	minExpireCycle   = 1 * time.Minute // Minimum period of dropping expired rate limits
+)
+
+var (
+	errInvalidAddress = errors.New("invalid address")
+	errRateLimited    = errors.New("funds already requested recently, try again later")
+	errQueueFull      = errors.New("too many pending requests, try again later")
+)
+
+// Captcha is the hook verifying that funding requests are made by humans.
+type Captcha interface {
+	// SiteKey returns the public key rendering the challenge in the web form.
+	SiteKey() string
+
+	// Verify checks the challenge response submitted along with a request from
+	// the given remote IP.
+	Verify(response string, remoteIP string) error
+}
+
+// Config holds the settings of the faucet.
+type Config struct {
+	Addr      string           // Listening address of the HTTP server
+	Account   accounts.Account // Account funding the payouts, must be unlocked
+	Amount    *big.Int         // Amount of wei paid out per request
+	Period    time.Duration    // Minimum time between payouts to the same address or IP
+	QueueSize int              // Maximum number of payouts waiting to be sent
+	Captcha   Captcha          // Optional captcha verifying the requests
+}
+
+// Service implements a faucet paying out ether from an unlocked account of the
+// node, through the transaction pool of the full node.
+type Service struct {
+	config Config
+	eth    *eth.Ethereum
+
+	limits map[string]time.Time // Time of the last request of addresses and IPs
+	queue  chan common.Address  // Addresses waiting to be paid
+	lock   sync.Mutex
+
+	listener net.Listener
+	quit     chan struct{}
+}
+
+// New returns a faucet service paying out through the given full node.
+func New(config Config, ethServ *eth.Ethereum) (*Service, error) {
+	if config.Amount == nil || config.Amount.Sign() <= 0 {
+		return nil, errors.New("faucet payout amount must be positive")
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+	return &Service{
+		config: config,
+		eth:    ethServ,
+		limits: make(map[string]time.Time),
+		queue:  make(chan common.Address, config.QueueSize),
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the faucet (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// faucet (nil as it is only accessible through its web form).
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting the HTTP server and the payout loop.
+func (s *Service) Start(server *p2p.Server) error {
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	go http.Serve(listener, s)
+	go s.loop()
+
+	log.Info("Faucet started", "addr", listener.Addr(), "account", s.config.Account.Address)
+	return nil
+}
+
+// Stop implements node.Service, terminating the HTTP server and the payouts.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.listener.Close()
+
+	log.Info("Faucet stopped")
+	return nil
+}
+
+// request validates a funding request of the given address from the given IP,
+// and queues the payout if neither of them was funded within the rate limiting
+// period.
+func (s *Service) request(address, response, ip string) (common.Address, error) {
+	if !common.IsHexAddress(address) {
+		return common.Address{}, errInvalidAddress
+	}
+	if s.config.Captcha != nil {
+		if err := s.config.Captcha.Verify(response, ip); err != nil {
+			return common.Address{}, err
+		}
+	}
+	addr := common.HexToAddress(address)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for _, key := range []string{addr.Hex(), ip} {
+		if last, ok := s.limits[key]; ok && now.Sub(last) < s.config.Period {
+			return common.Address{}, errRateLimited
+		}
+	}
+	select {
+	case s.queue <- addr:
+	default:
+		return common.Address{}, errQueueFull
+	}
+	s.limits[addr.Hex()] = now
+	s.limits[ip] = now
+
+	return addr, nil
+}
+
+// expire drops the rate limits older than the rate limiting period.
+func (s *Service) expire() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for key, last := range s.limits {
+		if now.Sub(last) >= s.config.Period {
+			delete(s.limits, key)
+		}
+	}
+}
+
+// loop sends the queued payouts one by one, and drops the expired rate limits
+// periodically.
+func (s *Service) loop() {
+	cycle := s.config.Period
+	if cycle < minExpireCycle {
+		cycle = minExpireCycle
+	}
+	expire := time.NewTicker(cycle)
+	defer expire.Stop()
+
+	for {
+		select {
+		case addr := <-s.queue:
+			if hash, err := s.pay(addr); err != nil {
+				log.Warn("Faucet payout failed", "address", addr, "err", err)
+			} else {
+				log.Info("Faucet payout sent", "address", addr, "hash", hash)
+			}
+		case <-expire.C:
+			s.expire()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// pay signs a payout transaction to the given address with the funding account
+// and adds it to the transaction pool.
+func (s *Service) pay(addr common.Address) (common.Hash, error) {
+	var (
+		pool  = s.eth.TxPool()
+		chain = s.eth.BlockChain()
+		from  = s.config.Account
+	)
+	price, err := s.eth.ApiBackend.SuggestPrice(context.Background())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	nonce := pool.State().GetNonce(from.Address)
+	tx := types.NewTransaction(nonce, addr, s.config.Amount, big.NewInt(payoutGas), price, nil)
+
+	var chainID *big.Int
+	if config := chain.Config(); config.IsEIP155(chain.CurrentBlock().Number()) {
+		chainID = config.ChainId
+	}
+	wallet, err := s.eth.AccountManager().Find(from)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	signed, err := wallet.SignTx(from, tx, chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := pool.Add(signed); err != nil {
+		return common.Hash{}, err
+	}
+	return signed.Hash(), nil
+}
+
+// ether converts an amount of wei to ether for display.
+func ether(wei *big.Int) string {
+	return new(big.Rat).SetFrac(wei, big.NewInt(params.Ether)).FloatString(2)
+}
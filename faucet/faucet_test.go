@@ -0,0 +1,129 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package faucet
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+)
+
+// testCaptcha is a captcha accepting a single response.
+type testCaptcha struct{ valid string }
+
+func (c *testCaptcha) SiteKey() string { return "site" }
+
+func (c *testCaptcha) Verify(response string, remoteIP string) error {
+	if response != c.valid {
+		return errors.New("invalid captcha")
+	}
+	return nil
+}
+
+// post submits the faucet form of the given service from the given IP.
+func post(s *Service, ip string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = ip + ":30303"
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+// Tests that funding requests are queued, rejecting invalid addresses and repeated
+// requests of an address or IP within the rate limiting period.
+func TestRequestLimits(t *testing.T) {
+	s, err := New(Config{Amount: big.NewInt(1), Period: time.Hour, QueueSize: 3}, nil)
+	if err != nil {
+		t.Fatalf("failed to create faucet: %v", err)
+	}
+	var (
+		addr1 = "0x0000000000000000000000000000000000000001"
+		addr2 = "0x0000000000000000000000000000000000000002"
+		addr3 = "0x0000000000000000000000000000000000000003"
+		addr4 = "0x0000000000000000000000000000000000000004"
+	)
+	tests := []struct {
+		ip, addr string
+		code     int
+	}{
+		{"10.0.0.1", "invalid", http.StatusBadRequest},
+		{"10.0.0.1", addr1, http.StatusOK},
+		{"10.0.0.1", addr2, http.StatusTooManyRequests}, // Same IP
+		{"10.0.0.2", addr1, http.StatusTooManyRequests}, // Same address
+		{"10.0.0.2", addr2, http.StatusOK},
+		{"10.0.0.3", addr3, http.StatusOK},
+		{"10.0.0.4", addr4, http.StatusServiceUnavailable}, // Queue full
+	}
+	for i, tt := range tests {
+		if rec := post(s, tt.ip, url.Values{"address": {tt.addr}}); rec.Code != tt.code {
+			t.Errorf("test %d: status mismatch: have %d, want %d", i, rec.Code, tt.code)
+		}
+	}
+	for i, want := range []string{addr1, addr2, addr3} {
+		if have := <-s.queue; have != common.HexToAddress(want) {
+			t.Errorf("payout %d: address mismatch: have %x, want %s", i, have, want)
+		}
+	}
+	// A request failing on a full queue must not be rate limited
+	if rec := post(s, "10.0.0.4", url.Values{"address": {addr4}}); rec.Code != http.StatusOK {
+		t.Errorf("retry status mismatch: have %d, want %d", rec.Code, http.StatusOK)
+	}
+	// Expired rate limits must be dropped
+	s.config.Period = 0
+	s.expire()
+	if len(s.limits) != 0 {
+		t.Errorf("rate limits not expired: %v", s.limits)
+	}
+}
+
+// Tests that requests are only queued with a valid captcha response if a captcha
+// is configured.
+func TestRequestCaptcha(t *testing.T) {
+	s, err := New(Config{Amount: big.NewInt(1), Period: time.Hour, Captcha: &testCaptcha{valid: "human"}}, nil)
+	if err != nil {
+		t.Fatalf("failed to create faucet: %v", err)
+	}
+	addr := "0x0000000000000000000000000000000000000001"
+
+	if rec := post(s, "10.0.0.1", url.Values{"address": {addr}, "g-recaptcha-response": {"robot"}}); rec.Code != http.StatusForbidden {
+		t.Errorf("invalid captcha status mismatch: have %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if len(s.queue) != 0 {
+		t.Errorf("request queued with invalid captcha")
+	}
+	if rec := post(s, "10.0.0.1", url.Values{"address": {addr}, "g-recaptcha-response": {"human"}}); rec.Code != http.StatusOK {
+		t.Errorf("valid captcha status mismatch: have %d, want %d", rec.Code, http.StatusOK)
+	}
+	if len(s.queue) != 1 {
+		t.Errorf("request not queued with valid captcha")
+	}
+	// The form must render the captcha challenge
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), `data-sitekey="site"`) {
+		t.Errorf("captcha missing from form")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package faucet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/expanse-org/go-expanse/log"
+)
+
+// reCaptchaVerifyURL is the endpoint verifying reCAPTCHA responses.
+const reCaptchaVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+
+var errCaptchaFailed = errors.New("captcha verification failed")
+
+// ReCaptcha is a Captcha verifying requests through Google's reCAPTCHA service.
+type ReCaptcha struct {
+	Site   string // Public site key rendering the challenge
+	Secret string // Secret key verifying the responses
+}
+
+// SiteKey implements Captcha, returning the public site key.
+func (c *ReCaptcha) SiteKey() string { return c.Site }
+
+// Verify implements Captcha, checking the response with the reCAPTCHA service.
+func (c *ReCaptcha) Verify(response string, remoteIP string) error {
+	if response == "" {
+		return errCaptchaFailed
+	}
+	res, err := http.PostForm(reCaptchaVerifyURL, url.Values{
+		"secret":   {c.Secret},
+		"response": {response},
+		"remoteip": {remoteIP},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		Success bool     `json:"success"`
+		Errors  []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		log.Debug("Faucet captcha rejected", "ip", remoteIP, "errors", result.Errors)
+		return errCaptchaFailed
+	}
+	return nil
+}
+
+// page is the content of the faucet web form.
+type page struct {
+	Amount  string
+	Period  string
+	SiteKey string
+	Message string
+	Failed  bool
+}
+
+var pageTemplate = template.Must(template.New("faucet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Expanse Faucet</title>
+	{{if .SiteKey}}<script src="https://www.google.com/recaptcha/api.js" async defer></script>{{end}}
+</head>
+<body>
+	<h1>Expanse Faucet</h1>
+	<p>Request {{.Amount}} ether, once every {{.Period}} per address and IP.</p>
+	{{if .Message}}<p style="color: {{if .Failed}}red{{else}}green{{end}}">{{.Message}}</p>{{end}}
+	<form method="POST">
+		<input type="text" name="address" size="50" placeholder="0x...">
+		{{if .SiteKey}}<div class="g-recaptcha" data-sitekey="{{.SiteKey}}"></div>{{end}}
+		<input type="submit" value="Request funds">
+	</form>
+</body>
+</html>
+`))
+
+// ServeHTTP implements http.Handler, serving the faucet web form and queueing
+// the payouts submitted through it.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := &page{
+		Amount: ether(s.config.Amount),
+		Period: s.config.Period.String(),
+	}
+	if s.config.Captcha != nil {
+		p.SiteKey = s.config.Captcha.SiteKey()
+	}
+	switch r.Method {
+	case "GET":
+	case "POST":
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		addr, err := s.request(strings.TrimSpace(r.FormValue("address")), r.FormValue("g-recaptcha-response"), ip)
+		switch err {
+		case nil:
+			p.Message = fmt.Sprintf("Funding request of %s queued", addr.Hex())
+		case errInvalidAddress:
+			w.WriteHeader(http.StatusBadRequest)
+		case errRateLimited:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case errQueueFull:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+		if err != nil {
+			p.Message, p.Failed = err.Error(), true
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := pageTemplate.Execute(w, p); err != nil {
+		log.Debug("Failed to render faucet page", "err", err)
+	}
+}
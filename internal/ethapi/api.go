@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/expanse-org/go-expanse/accounts"
+	"github.com/expanse-org/go-expanse/accounts/abi"
 	"github.com/expanse-org/go-expanse/accounts/keystore"
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/hexutil"
@@ -68,6 +69,37 @@ func (s *PublicEthereumAPI) GasPrice(ctx context.Context) (*big.Int, error) {
 	return s.b.SuggestPrice(ctx)
 }
 
+// FeeHistoryResult is the gas usage and the gas prices paid in a range of blocks.
+type FeeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// FeeHistory returns the ratio of gas used to the gas limit of the given
+// number of blocks up to lastBlock, and for each of them the gas prices paid
+// at the requested percentiles of the block's gas used.
+func (s *PublicEthereumAPI) FeeHistory(ctx context.Context, blockCount hexutil.Uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*FeeHistoryResult, error) {
+	oldest, rewards, ratios, err := s.b.FeeHistory(ctx, int(blockCount), lastBlock, rewardPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	result := &FeeHistoryResult{
+		OldestBlock:  (*hexutil.Big)(oldest),
+		GasUsedRatio: ratios,
+	}
+	if rewards != nil {
+		result.Reward = make([][]*hexutil.Big, len(rewards))
+		for i, prices := range rewards {
+			result.Reward[i] = make([]*hexutil.Big, len(prices))
+			for j, price := range prices {
+				result.Reward[i][j] = (*hexutil.Big)(price)
+			}
+		}
+	}
+	return result, nil
+}
+
 // ProtocolVersion returns the current Ethereum protocol version this node supports
 func (s *PublicEthereumAPI) ProtocolVersion() hexutil.Uint {
 	return hexutil.Uint(s.b.ProtocolVersion())
@@ -119,17 +151,17 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for nonce, tx := range txs {
-			dump[fmt.Sprintf("%d", nonce)] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", nonce)] = NewRPCPendingTransaction(tx)
 		}
-		content["pending"][account.Hex()] = dump
+		content["pending"][account.ChecksumHex()] = dump
 	}
 	// Flatten the queued transactions
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for nonce, tx := range txs {
-			dump[fmt.Sprintf("%d", nonce)] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", nonce)] = NewRPCPendingTransaction(tx)
 		}
-		content["queued"][account.Hex()] = dump
+		content["queued"][account.ChecksumHex()] = dump
 	}
 	return content
 }
@@ -165,7 +197,7 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 		for nonce, tx := range txs {
 			dump[fmt.Sprintf("%d", nonce)] = format(tx)
 		}
-		content["pending"][account.Hex()] = dump
+		content["pending"][account.ChecksumHex()] = dump
 	}
 	// Flatten the queued transactions
 	for account, txs := range queue {
@@ -173,7 +205,7 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 		for nonce, tx := range txs {
 			dump[fmt.Sprintf("%d", nonce)] = format(tx)
 		}
-		content["queued"][account.Hex()] = dump
+		content["queued"][account.ChecksumHex()] = dump
 	}
 	return content
 }
@@ -190,11 +222,11 @@ func NewPublicAccountAPI(am *accounts.Manager) *PublicAccountAPI {
 }
 
 // Accounts returns the collection of accounts this node manages
-func (s *PublicAccountAPI) Accounts() []common.Address {
-	var addresses []common.Address
+func (s *PublicAccountAPI) Accounts() []common.ChecksumAddress {
+	var addresses []common.ChecksumAddress
 	for _, wallet := range s.am.Wallets() {
 		for _, account := range wallet.Accounts() {
-			addresses = append(addresses, account.Address)
+			addresses = append(addresses, common.ChecksumAddress(account.Address))
 		}
 	}
 	return addresses
@@ -217,11 +249,11 @@ func NewPrivateAccountAPI(b Backend) *PrivateAccountAPI {
 }
 
 // ListAccounts will return a list of addresses for accounts this node manages.
-func (s *PrivateAccountAPI) ListAccounts() []common.Address {
-	var addresses []common.Address
+func (s *PrivateAccountAPI) ListAccounts() []common.ChecksumAddress {
+	var addresses []common.ChecksumAddress
 	for _, wallet := range s.am.Wallets() {
 		for _, account := range wallet.Accounts() {
-			addresses = append(addresses, account.Address)
+			addresses = append(addresses, common.ChecksumAddress(account.Address))
 		}
 	}
 	return addresses
@@ -248,6 +280,22 @@ func (s *PrivateAccountAPI) ListWallets() []rawWallet {
 	return wallets
 }
 
+// OpenWallet initiates a hardware wallet opening procedure, establishing a USB
+// connection and attempting to authenticate via the provided passphrase. Note,
+// the method may return an extra challenge requiring a second open (e.g. the
+// Trezor PIN matrix challenge).
+func (s *PrivateAccountAPI) OpenWallet(url string, passphrase *string) error {
+	wallet, err := s.am.Wallet(url)
+	if err != nil {
+		return err
+	}
+	pass := ""
+	if passphrase != nil {
+		pass = *passphrase
+	}
+	return wallet.Open(pass)
+}
+
 // DeriveAccount requests a HD wallet to derive a new account, optionally pinning
 // it for later reuse.
 func (s *PrivateAccountAPI) DeriveAccount(url string, path string, pin *bool) (accounts.Account, error) {
@@ -266,12 +314,12 @@ func (s *PrivateAccountAPI) DeriveAccount(url string, path string, pin *bool) (a
 }
 
 // NewAccount will create a new account and returns the address for the new account.
-func (s *PrivateAccountAPI) NewAccount(password string) (common.Address, error) {
+func (s *PrivateAccountAPI) NewAccount(password string) (common.ChecksumAddress, error) {
 	acc, err := fetchKeystore(s.am).NewAccount(password)
 	if err == nil {
-		return acc.Address, nil
+		return common.ChecksumAddress(acc.Address), nil
 	}
-	return common.Address{}, err
+	return common.ChecksumAddress{}, err
 }
 
 // fetchKeystore retrives the encrypted keystore from the account manager.
@@ -281,20 +329,20 @@ func fetchKeystore(am *accounts.Manager) *keystore.KeyStore {
 
 // ImportRawKey stores the given hex encoded ECDSA key into the key directory,
 // encrypting it with the passphrase.
-func (s *PrivateAccountAPI) ImportRawKey(privkey string, password string) (common.Address, error) {
+func (s *PrivateAccountAPI) ImportRawKey(privkey string, password string) (common.ChecksumAddress, error) {
 	hexkey, err := hex.DecodeString(privkey)
 	if err != nil {
-		return common.Address{}, err
+		return common.ChecksumAddress{}, err
 	}
 
 	acc, err := fetchKeystore(s.am).ImportECDSA(crypto.ToECDSA(hexkey), password)
-	return acc.Address, err
+	return common.ChecksumAddress(acc.Address), err
 }
 
 // UnlockAccount will unlock the account associated with the given address with
 // the given password for duration seconds. If duration is nil it will use a
 // default of 300 seconds. It returns an indication if the account was unlocked.
-func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string, duration *uint64) (bool, error) {
+func (s *PrivateAccountAPI) UnlockAccount(addr common.ChecksumAddress, password string, duration *uint64) (bool, error) {
 	const max = uint64(time.Duration(math.MaxInt64) / time.Second)
 	var d time.Duration
 	if duration == nil {
@@ -304,13 +352,13 @@ func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string,
 	} else {
 		d = time.Duration(*duration) * time.Second
 	}
-	err := fetchKeystore(s.am).TimedUnlock(accounts.Account{Address: addr}, password, d)
+	err := fetchKeystore(s.am).TimedUnlock(accounts.Account{Address: common.Address(addr)}, password, d)
 	return err == nil, err
 }
 
 // LockAccount will lock the account associated with the given address when it's unlocked.
-func (s *PrivateAccountAPI) LockAccount(addr common.Address) bool {
-	return fetchKeystore(s.am).Lock(addr) == nil
+func (s *PrivateAccountAPI) LockAccount(addr common.ChecksumAddress) bool {
+	return fetchKeystore(s.am).Lock(common.Address(addr)) == nil
 }
 
 // SendTransaction will create a transaction from the given arguments and
@@ -322,7 +370,7 @@ func (s *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs
 		return common.Hash{}, err
 	}
 	// Look up the wallet containing the requested signer
-	account := accounts.Account{Address: args.From}
+	account := accounts.Account{Address: common.Address(args.From)}
 
 	wallet, err := s.am.Find(account)
 	if err != nil {
@@ -363,9 +411,9 @@ func signHash(data []byte) []byte {
 // The key used to calculate the signature is decrypted with the given password.
 //
 // https://github.com/expanse-org/go-expanse/wiki/Management-APIs#personal_sign
-func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr common.Address, passwd string) (hexutil.Bytes, error) {
+func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr common.ChecksumAddress, passwd string) (hexutil.Bytes, error) {
 	// Look up the wallet containing the requested signer
-	account := accounts.Account{Address: addr}
+	account := accounts.Account{Address: common.Address(addr)}
 
 	wallet, err := s.b.AccountManager().Find(account)
 	if err != nil {
@@ -390,22 +438,22 @@ func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr c
 // the V value must be be 27 or 28 for legacy reasons.
 //
 // https://github.com/expanse-org/go-expanse/wiki/Management-APIs#personal_ecRecover
-func (s *PrivateAccountAPI) EcRecover(ctx context.Context, data, sig hexutil.Bytes) (common.Address, error) {
+func (s *PrivateAccountAPI) EcRecover(ctx context.Context, data, sig hexutil.Bytes) (common.ChecksumAddress, error) {
 	if len(sig) != 65 {
-		return common.Address{}, fmt.Errorf("signature must be 65 bytes long")
+		return common.ChecksumAddress{}, fmt.Errorf("signature must be 65 bytes long")
 	}
 	if sig[64] != 27 && sig[64] != 28 {
-		return common.Address{}, fmt.Errorf("invalid Expanse signature (V is not 27 or 28)")
+		return common.ChecksumAddress{}, fmt.Errorf("invalid Expanse signature (V is not 27 or 28)")
 	}
 	sig[64] -= 27 // Transform yellow paper V from 27/28 to 0/1
 
 	rpk, err := crypto.Ecrecover(signHash(data), sig)
 	if err != nil {
-		return common.Address{}, err
+		return common.ChecksumAddress{}, err
 	}
 	pubKey := crypto.ToECDSAPub(rpk)
 	recoveredAddr := crypto.PubkeyToAddress(*pubKey)
-	return recoveredAddr, nil
+	return common.ChecksumAddress(recoveredAddr), nil
 }
 
 // SignAndSendTransaction was renamed to SendTransaction. This method is deprecated
@@ -432,20 +480,29 @@ func (s *PublicBlockChainAPI) BlockNumber() *big.Int {
 }
 
 // GetBalance returns the amount of wei for the given address in the state of the
-// given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
-// block numbers are also allowed.
-func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*big.Int, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+// given block number or hash. The rpc.LatestBlockNumber and rpc.PendingBlockNumber
+// meta block numbers are also allowed.
+func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.ChecksumAddress, blockNrOrHash rpc.BlockNumberOrHash) (*big.Int, error) {
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
 
-	return state.GetBalance(ctx, address)
+	return state.GetBalance(ctx, common.Address(address))
 }
 
 // GetBlockByNumber returns the requested block. When blockNr is -1 the chain head is returned. When fullTx is true all
-// transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
-func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+// transactions in the block are returned in full detail, otherwise only the transaction hash is returned. When the
+// optional inclRewards is true, the rewards and fees credited for the block are included too.
+func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber, fullTx bool, inclRewards *bool) (map[string]interface{}, error) {
+	rewards := inclRewards != nil && *inclRewards
+
+	key := fmt.Sprintf("n%d/%v/%v", blockNr, fullTx, rewards)
+	if blockNr >= 0 {
+		if response, ok := s.b.ResponseCache().Get(CacheBlocks, key); ok {
+			return response.(map[string]interface{}), nil
+		}
+	}
 	block, err := s.b.BlockByNumber(ctx, blockNr)
 	if block != nil {
 		response, err := s.rpcOutputBlock(block, true, fullTx)
@@ -455,17 +512,38 @@ func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, blockNr rpc.
 				response[field] = nil
 			}
 		}
+		// Pending blocks have no receipts stored to derive the fees from
+		if err == nil && rewards && blockNr != rpc.PendingBlockNumber {
+			err = s.rpcOutputRewards(ctx, block, response)
+		}
+		if err == nil && blockNr >= 0 {
+			s.b.ResponseCache().Put(CacheBlocks, key, block.NumberU64(), block.Hash(), response)
+		}
 		return response, err
 	}
 	return nil, err
 }
 
 // GetBlockByHash returns the requested block. When fullTx is true all transactions in the block are returned in full
-// detail, otherwise only the transaction hash is returned.
-func (s *PublicBlockChainAPI) GetBlockByHash(ctx context.Context, blockHash common.Hash, fullTx bool) (map[string]interface{}, error) {
+// detail, otherwise only the transaction hash is returned. When the optional inclRewards is true, the rewards and fees
+// credited for the block are included too.
+func (s *PublicBlockChainAPI) GetBlockByHash(ctx context.Context, blockHash common.Hash, fullTx bool, inclRewards *bool) (map[string]interface{}, error) {
+	rewards := inclRewards != nil && *inclRewards
+
+	key := fmt.Sprintf("h%x/%v/%v", blockHash, fullTx, rewards)
+	if response, ok := s.b.ResponseCache().Get(CacheBlocks, key); ok {
+		return response.(map[string]interface{}), nil
+	}
 	block, err := s.b.GetBlock(ctx, blockHash)
 	if block != nil {
-		return s.rpcOutputBlock(block, true, fullTx)
+		response, err := s.rpcOutputBlock(block, true, fullTx)
+		if err == nil && rewards {
+			err = s.rpcOutputRewards(ctx, block, response)
+		}
+		if err == nil {
+			s.b.ResponseCache().Put(CacheBlocks, key, block.NumberU64(), block.Hash(), response)
+		}
+		return response, err
 	}
 	return nil, err
 }
@@ -520,13 +598,13 @@ func (s *PublicBlockChainAPI) GetUncleCountByBlockHash(ctx context.Context, bloc
 	return nil
 }
 
-// GetCode returns the code stored at the given address in the state for the given block number.
-func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (string, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+// GetCode returns the code stored at the given address in the state for the given block number or hash.
+func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.ChecksumAddress, blockNrOrHash rpc.BlockNumberOrHash) (string, error) {
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return "", err
 	}
-	res, err := state.GetCode(ctx, address)
+	res, err := state.GetCode(ctx, common.Address(address))
 	if len(res) == 0 || err != nil { // backwards compatibility
 		return "0x", err
 	}
@@ -534,14 +612,14 @@ func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Addres
 }
 
 // GetStorageAt returns the storage from the state at the given address, key and
-// block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
-// numbers are also allowed.
-func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNr rpc.BlockNumber) (string, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+// block number or hash. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
+// block numbers are also allowed.
+func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.ChecksumAddress, key string, blockNrOrHash rpc.BlockNumberOrHash) (string, error) {
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return "0x", err
 	}
-	res, err := state.GetState(ctx, address, common.HexToHash(key))
+	res, err := state.GetState(ctx, common.Address(address), common.HexToHash(key))
 	if err != nil {
 		return "0x", err
 	}
@@ -570,23 +648,55 @@ func (m callmsg) Data() []byte                          { return m.data }
 
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
-	From     common.Address  `json:"from"`
-	To       *common.Address `json:"to"`
-	Gas      hexutil.Big     `json:"gas"`
-	GasPrice hexutil.Big     `json:"gasPrice"`
-	Value    hexutil.Big     `json:"value"`
-	Data     hexutil.Bytes   `json:"data"`
+	From     common.ChecksumAddress  `json:"from"`
+	To       *common.ChecksumAddress `json:"to"`
+	Gas      hexutil.Big             `json:"gas"`
+	GasPrice hexutil.Big             `json:"gasPrice"`
+	Value    hexutil.Big             `json:"value"`
+	Data     hexutil.Bytes           `json:"data"`
+}
+
+// OverrideAccount specifies the fields of an account replaced in the state a
+// call is executed on. Storage slots not listed keep their original values.
+type OverrideAccount struct {
+	Nonce   *hexutil.Uint64             `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Balance *hexutil.Big                `json:"balance"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// StateOverride is the set of accounts overridden for the execution of a call.
+type StateOverride map[common.ChecksumAddress]OverrideAccount
+
+// apply replaces the overridden account fields in the given state.
+func (diff StateOverride) apply(statedb vm.StateDB) {
+	for key, account := range diff {
+		addr := common.Address(key)
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, uint64(*account.Nonce))
+		}
+		if account.Code != nil {
+			statedb.SetCode(addr, *account.Code)
+		}
+		if account.Balance != nil {
+			statedb.SubBalance(addr, statedb.GetBalance(addr))
+			statedb.AddBalance(addr, account.Balance.ToInt())
+		}
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config) ([]byte, *big.Int, error) {
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides StateOverride, vmCfg vm.Config) (*core.ExecutionResult, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
-	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	state, header, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
-		return nil, common.Big0, err
+		return nil, err
 	}
 	// Set sender address or use a default if none specified
-	addr := args.From
+	addr := common.Address(args.From)
 	if addr == (common.Address{}) {
 		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
 			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
@@ -599,12 +709,16 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if gas.Sign() == 0 {
 		gas = big.NewInt(50000000)
 	}
+	if gasCap := s.b.RPCGasCap(); gasCap != nil && gas.Cmp(gasCap) > 0 {
+		log.Warn("Caller gas above allowance, capping", "requested", gas, "cap", gasCap)
+		gas = new(big.Int).Set(gasCap)
+	}
 	if gasPrice.Sign() == 0 {
 		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
 	}
 
 	// Create new call message
-	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
+	msg := types.NewMessage(addr, (*common.Address)(args.To), 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
 
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
@@ -621,8 +735,10 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Get a new instance of the EVM.
 	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
 	if err != nil {
-		return nil, common.Big0, err
+		return nil, err
 	}
+	overrides.apply(evm.StateDB)
+
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
 	go func() {
@@ -635,20 +751,51 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Setup the gas pool (also for unmetered requests)
 	// and apply the message.
 	gp := new(core.GasPool).AddGas(math.MaxBig256)
-	res, gas, err := core.ApplyMessage(evm, msg, gp)
+	result, err := core.ApplyMessageResult(evm, msg, gp)
 	if err := vmError(); err != nil {
-		return nil, common.Big0, err
+		return nil, err
 	}
-	return res, gas, err
+	return result, err
 }
 
-// Call executes the given transaction on the state for the given block number.
+// Call executes the given transaction on the state for the given block number or hash.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, err := s.doCall(ctx, args, blockNr, vm.Config{DisableGasMetering: true})
-	return (hexutil.Bytes)(result), err
+// The optional overrides replace account fields in the state before executing the call.
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Bytes, error) {
+	var diff StateOverride
+	if overrides != nil {
+		diff = *overrides
+	}
+	result, err := s.doCall(ctx, args, blockNrOrHash, diff, vm.Config{DisableGasMetering: true})
+	if err != nil {
+		return nil, err
+	}
+	return (hexutil.Bytes)(result.ReturnData), nil
+}
+
+// revertError is an API error reporting a reverted execution. The revert reason
+// is included in the message and returned as the error data if it could be
+// decoded, otherwise the data holds the raw hex encoded revert output.
+type revertError struct {
+	error
+	data string
+}
+
+// newRevertError creates a revertError from the output of a reverted execution.
+func newRevertError(revert []byte) *revertError {
+	reason, err := abi.UnpackRevert(revert)
+	if err != nil {
+		return &revertError{error: vm.ErrExecutionReverted, data: hexutil.Encode(revert)}
+	}
+	return &revertError{error: fmt.Errorf("%v: %v", vm.ErrExecutionReverted, reason), data: reason}
 }
 
+// ErrorCode returns the JSON-RPC error code of a reverted execution.
+func (e *revertError) ErrorCode() int { return 3 }
+
+// ErrorData returns the revert reason, or the raw revert output.
+func (e *revertError) ErrorData() interface{} { return e.data }
+
 // EstimateGas returns an estimate of the amount of gas needed to execute the given transaction.
 func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*hexutil.Big, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
@@ -663,21 +810,55 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*
 		}
 		hi = block.GasLimit().Uint64()
 	}
-	for lo+1 < hi {
-		// Take a guess at the gas, and check transaction validity
-		mid := (hi + lo) / 2
-		(*big.Int)(&args.Gas).SetUint64(mid)
+	if gasCap := s.b.RPCGasCap(); gasCap != nil && hi > gasCap.Uint64() {
+		log.Warn("Caller gas above allowance, capping", "requested", hi, "cap", gasCap)
+		hi = gasCap.Uint64()
+	}
+	allowance := hi
 
-		_, gas, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{})
+	// executable runs the transaction with the given gas limit, reporting whether
+	// it failed. Invalid transactions (e.g. below intrinsic gas) count as failed.
+	executable := func(gas uint64) (bool, *core.ExecutionResult, error) {
+		(*big.Int)(&args.Gas).SetUint64(gas)
 
-		// If the transaction became invalid or used all the gas (failed), raise the gas limit
-		if err != nil || gas.Cmp((*big.Int)(&args.Gas)) == 0 {
+		result, err := s.doCall(ctx, args, rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber), nil, vm.Config{})
+		if err != nil {
+			return true, nil, err
+		}
+		return result.Failed(), result, nil
+	}
+	// No transaction can use less than the base transaction gas
+	if lo < params.TxGas-1 && hi > params.TxGas {
+		lo = params.TxGas - 1
+	}
+	for lo+1 < hi {
+		// Take a guess at the gas, raising the limit if the transaction failed
+		// (reverts included, as they may stem from gas starved inner calls)
+		mid := (hi + lo) / 2
+		if failed, _, _ := executable(mid); failed {
 			lo = mid
 			continue
 		}
 		// Otherwise assume the transaction succeeded, lower the gas limit
 		hi = mid
 	}
+	// If the transaction fails even with the highest allowance, report why
+	if hi == allowance {
+		failed, result, err := executable(hi)
+		if err != nil {
+			return nil, err
+		}
+		if failed {
+			switch {
+			case result.Err == vm.ErrExecutionReverted:
+				return nil, newRevertError(result.Revert())
+			case result.Err == vm.ErrOutOfGas:
+				return nil, fmt.Errorf("gas required exceeds allowance (%d)", allowance)
+			default:
+				return nil, result.Err
+			}
+		}
+	}
 	return (*hexutil.Big)(new(big.Int).SetUint64(hi)), nil
 }
 
@@ -734,31 +915,42 @@ func FormatLogs(structLogs []vm.StructLog) []StructLogRes {
 	return formattedStructLogs
 }
 
-// rpcOutputBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
-// returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
-// transaction hashes.
-func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]interface{}, error) {
-	head := b.Header() // copies the header once
-	fields := map[string]interface{}{
+// rpcOutputHeader converts the given header to the RPC output fields of its block.
+// The fields carry the same names and encodings as the JSON encoding of the header.
+func rpcOutputHeader(head *types.Header) map[string]interface{} {
+	return map[string]interface{}{
 		"number":           (*hexutil.Big)(head.Number),
-		"hash":             b.Hash(),
+		"hash":             head.Hash(),
 		"parentHash":       head.ParentHash,
 		"nonce":            head.Nonce,
 		"mixHash":          head.MixDigest,
 		"sha3Uncles":       head.UncleHash,
 		"logsBloom":        head.Bloom,
 		"stateRoot":        head.Root,
-		"miner":            head.Coinbase,
+		"miner":            common.ChecksumAddress(head.Coinbase),
 		"difficulty":       (*hexutil.Big)(head.Difficulty),
-		"totalDifficulty":  (*hexutil.Big)(s.b.GetTd(b.Hash())),
 		"extraData":        hexutil.Bytes(head.Extra),
-		"size":             hexutil.Uint64(uint64(b.Size().Int64())),
 		"gasLimit":         (*hexutil.Big)(head.GasLimit),
 		"gasUsed":          (*hexutil.Big)(head.GasUsed),
 		"timestamp":        (*hexutil.Big)(head.Time),
 		"transactionsRoot": head.TxHash,
 		"receiptsRoot":     head.ReceiptHash,
 	}
+}
+
+// rpcOutputBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
+// returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
+// transaction hashes.
+func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]interface{}, error) {
+	head := b.Header() // copies the header once
+	author, err := s.b.Engine().Author(head)
+	if err != nil {
+		return nil, err
+	}
+	fields := rpcOutputHeader(head)
+	fields["miner"] = common.ChecksumAddress(author)
+	fields["totalDifficulty"] = (*hexutil.Big)(s.b.GetTd(b.Hash()))
+	fields["size"] = hexutil.Uint64(uint64(b.Size().Int64()))
 
 	if inclTx {
 		formatTx := func(tx *types.Transaction) (interface{}, error) {
@@ -792,26 +984,56 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 	return fields, nil
 }
 
+// rpcOutputRewards adds the rewards credited for the given block to its RPC output:
+// the static block reward, the rewards for including uncles, the rewards of the
+// uncle authors and the sum of the fees paid by its transactions. The rewards
+// are computed by the same code crediting them during block processing.
+func (s *PublicBlockChainAPI) rpcOutputRewards(ctx context.Context, b *types.Block, fields map[string]interface{}) error {
+	receipts, err := s.b.GetReceipts(ctx, b.Hash())
+	if err != nil {
+		return err
+	}
+	txs := b.Transactions()
+	if len(receipts) != len(txs) {
+		return fmt.Errorf("receipts of block %x not available", b.Hash())
+	}
+	fees := new(big.Int)
+	for i, tx := range txs {
+		fees.Add(fees, new(big.Int).Mul(receipts[i].GasUsed, tx.GasPrice()))
+	}
+	reward, uncleRewards := core.BlockRewards(b.Header(), b.Uncles())
+
+	uncles := make([]*hexutil.Big, len(uncleRewards))
+	for i, r := range uncleRewards {
+		uncles[i] = (*hexutil.Big)(r)
+	}
+	fields["staticReward"] = (*hexutil.Big)(core.BlockReward)
+	fields["uncleInclusionRewards"] = (*hexutil.Big)(new(big.Int).Sub(reward, core.BlockReward))
+	fields["uncleRewards"] = uncles
+	fields["totalFees"] = (*hexutil.Big)(fees)
+	return nil
+}
+
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
-	BlockHash        common.Hash     `json:"blockHash"`
-	BlockNumber      *hexutil.Big    `json:"blockNumber"`
-	From             common.Address  `json:"from"`
-	Gas              *hexutil.Big    `json:"gas"`
-	GasPrice         *hexutil.Big    `json:"gasPrice"`
-	Hash             common.Hash     `json:"hash"`
-	Input            hexutil.Bytes   `json:"input"`
-	Nonce            hexutil.Uint64  `json:"nonce"`
-	To               *common.Address `json:"to"`
-	TransactionIndex hexutil.Uint    `json:"transactionIndex"`
-	Value            *hexutil.Big    `json:"value"`
-	V                *hexutil.Big    `json:"v"`
-	R                *hexutil.Big    `json:"r"`
-	S                *hexutil.Big    `json:"s"`
-}
-
-// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
+	BlockHash        common.Hash             `json:"blockHash"`
+	BlockNumber      *hexutil.Big            `json:"blockNumber"`
+	From             common.ChecksumAddress  `json:"from"`
+	Gas              *hexutil.Big            `json:"gas"`
+	GasPrice         *hexutil.Big            `json:"gasPrice"`
+	Hash             common.Hash             `json:"hash"`
+	Input            hexutil.Bytes           `json:"input"`
+	Nonce            hexutil.Uint64          `json:"nonce"`
+	To               *common.ChecksumAddress `json:"to"`
+	TransactionIndex hexutil.Uint            `json:"transactionIndex"`
+	Value            *hexutil.Big            `json:"value"`
+	V                *hexutil.Big            `json:"v"`
+	R                *hexutil.Big            `json:"r"`
+	S                *hexutil.Big            `json:"s"`
+}
+
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	var signer types.Signer = types.FrontierSigner{}
 	if tx.Protected() {
 		signer = types.NewEIP155Signer(tx.ChainId())
@@ -819,13 +1041,13 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	from, _ := types.Sender(signer, tx)
 	v, r, s := tx.RawSignatureValues()
 	return &RPCTransaction{
-		From:     from,
+		From:     common.ChecksumAddress(from),
 		Gas:      (*hexutil.Big)(tx.Gas()),
 		GasPrice: (*hexutil.Big)(tx.GasPrice()),
 		Hash:     tx.Hash(),
 		Input:    hexutil.Bytes(tx.Data()),
 		Nonce:    hexutil.Uint64(tx.Nonce()),
-		To:       tx.To(),
+		To:       (*common.ChecksumAddress)(tx.To()),
 		Value:    (*hexutil.Big)(tx.Value()),
 		V:        (*hexutil.Big)(v),
 		R:        (*hexutil.Big)(r),
@@ -846,13 +1068,13 @@ func newRPCTransactionFromBlockIndex(b *types.Block, txIndex uint) (*RPCTransact
 		return &RPCTransaction{
 			BlockHash:        b.Hash(),
 			BlockNumber:      (*hexutil.Big)(b.Number()),
-			From:             from,
+			From:             common.ChecksumAddress(from),
 			Gas:              (*hexutil.Big)(tx.Gas()),
 			GasPrice:         (*hexutil.Big)(tx.GasPrice()),
 			Hash:             tx.Hash(),
 			Input:            hexutil.Bytes(tx.Data()),
 			Nonce:            hexutil.Uint64(tx.Nonce()),
-			To:               tx.To(),
+			To:               (*common.ChecksumAddress)(tx.To()),
 			TransactionIndex: hexutil.Uint(txIndex),
 			Value:            (*hexutil.Big)(tx.Value()),
 			V:                (*hexutil.Big)(v),
@@ -963,13 +1185,13 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByBlockHashAndIndex(ctx cont
 	return nil, nil
 }
 
-// GetTransactionCount returns the number of transactions the given address has sent for the given block number
-func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*hexutil.Uint64, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+// GetTransactionCount returns the number of transactions the given address has sent for the given block number or hash
+func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, address common.ChecksumAddress, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
-	nonce, err := state.GetNonce(ctx, address)
+	nonce, err := state.GetNonce(ctx, common.Address(address))
 	if err != nil {
 		return nil, err
 	}
@@ -1011,7 +1233,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 		return nil, nil
 	}
 	if isPending {
-		return newRPCPendingTransaction(tx), nil
+		return NewRPCPendingTransaction(tx), nil
 	}
 
 	blockHash, _, _, err := getTransactionBlockData(s.b.ChainDb(), hash)
@@ -1041,8 +1263,29 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 	return rlp.EncodeToBytes(tx)
 }
 
+// RPCReceipt represents a transaction receipt that will serialize to the RPC
+// representation of a receipt.
+type RPCReceipt struct {
+	Root              hexutil.Bytes           `json:"root"`
+	BlockHash         common.Hash             `json:"blockHash"`
+	BlockNumber       hexutil.Uint64          `json:"blockNumber"`
+	TransactionHash   common.Hash             `json:"transactionHash"`
+	TransactionIndex  hexutil.Uint64          `json:"transactionIndex"`
+	From              common.ChecksumAddress  `json:"from"`
+	To                *common.ChecksumAddress `json:"to"`
+	GasUsed           *hexutil.Big            `json:"gasUsed"`
+	CumulativeGasUsed *hexutil.Big            `json:"cumulativeGasUsed"`
+	ContractAddress   *common.ChecksumAddress `json:"contractAddress"`
+	Logs              []*types.Log            `json:"logs"`
+	LogsBloom         types.Bloom             `json:"logsBloom"`
+}
+
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
-func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[string]interface{}, error) {
+func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (*RPCReceipt, error) {
+	key := hash.Hex()
+	if fields, ok := s.b.ResponseCache().Get(CacheReceipts, key); ok {
+		return fields.(*RPCReceipt), nil
+	}
 	receipt := core.GetReceipt(s.b.ChainDb(), hash)
 	if receipt == nil {
 		log.Debug("Receipt not found for transaction", "hash", hash)
@@ -1060,35 +1303,42 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[
 		log.Debug("Failed to retrieve transaction block", "hash", hash, "err", err)
 		return nil, nil
 	}
+	fields := newRPCReceipt(receipt, tx, txBlock, blockIndex, index)
+
+	s.b.ResponseCache().Put(CacheReceipts, key, blockIndex, txBlock, fields)
+	return fields, nil
+}
 
+// newRPCReceipt returns a receipt of a transaction included in the given block
+// that will serialize to the RPC representation.
+func newRPCReceipt(receipt *types.Receipt, tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64) *RPCReceipt {
 	var signer types.Signer = types.FrontierSigner{}
 	if tx.Protected() {
 		signer = types.NewEIP155Signer(tx.ChainId())
 	}
 	from, _ := types.Sender(signer, tx)
 
-	fields := map[string]interface{}{
-		"root":              hexutil.Bytes(receipt.PostState),
-		"blockHash":         txBlock,
-		"blockNumber":       hexutil.Uint64(blockIndex),
-		"transactionHash":   hash,
-		"transactionIndex":  hexutil.Uint64(index),
-		"from":              from,
-		"to":                tx.To(),
-		"gasUsed":           (*hexutil.Big)(receipt.GasUsed),
-		"cumulativeGasUsed": (*hexutil.Big)(receipt.CumulativeGasUsed),
-		"contractAddress":   nil,
-		"logs":              receipt.Logs,
-		"logsBloom":         receipt.Bloom,
+	fields := &RPCReceipt{
+		Root:              hexutil.Bytes(receipt.PostState),
+		BlockHash:         blockHash,
+		BlockNumber:       hexutil.Uint64(blockNumber),
+		TransactionHash:   tx.Hash(),
+		TransactionIndex:  hexutil.Uint64(index),
+		From:              common.ChecksumAddress(from),
+		To:                (*common.ChecksumAddress)(tx.To()),
+		GasUsed:           (*hexutil.Big)(receipt.GasUsed),
+		CumulativeGasUsed: (*hexutil.Big)(receipt.CumulativeGasUsed),
+		Logs:              receipt.Logs,
+		LogsBloom:         receipt.Bloom,
 	}
 	if receipt.Logs == nil {
-		fields["logs"] = [][]*types.Log{}
+		fields.Logs = []*types.Log{}
 	}
 	// If the ContractAddress is 20 0x0 bytes, assume it is not a contract creation
 	if receipt.ContractAddress != (common.Address{}) {
-		fields["contractAddress"] = receipt.ContractAddress
+		fields.ContractAddress = (*common.ChecksumAddress)(&receipt.ContractAddress)
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
@@ -1110,13 +1360,13 @@ func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transacti
 
 // SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
 type SendTxArgs struct {
-	From     common.Address  `json:"from"`
-	To       *common.Address `json:"to"`
-	Gas      *hexutil.Big    `json:"gas"`
-	GasPrice *hexutil.Big    `json:"gasPrice"`
-	Value    *hexutil.Big    `json:"value"`
-	Data     hexutil.Bytes   `json:"data"`
-	Nonce    *hexutil.Uint64 `json:"nonce"`
+	From     common.ChecksumAddress  `json:"from"`
+	To       *common.ChecksumAddress `json:"to"`
+	Gas      *hexutil.Big            `json:"gas"`
+	GasPrice *hexutil.Big            `json:"gasPrice"`
+	Value    *hexutil.Big            `json:"value"`
+	Data     hexutil.Bytes           `json:"data"`
+	Nonce    *hexutil.Uint64         `json:"nonce"`
 }
 
 // prepareSendTxArgs is a helper function that fills in default values for unspecified tx fields.
@@ -1135,7 +1385,7 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 		args.Value = new(hexutil.Big)
 	}
 	if args.Nonce == nil {
-		nonce, err := b.GetPoolNonce(ctx, args.From)
+		nonce, err := b.GetPoolNonce(ctx, common.Address(args.From))
 		if err != nil {
 			return err
 		}
@@ -1148,11 +1398,28 @@ func (args *SendTxArgs) toTransaction() *types.Transaction {
 	if args.To == nil {
 		return types.NewContractCreation(uint64(*args.Nonce), (*big.Int)(args.Value), (*big.Int)(args.Gas), (*big.Int)(args.GasPrice), args.Data)
 	}
-	return types.NewTransaction(uint64(*args.Nonce), *args.To, (*big.Int)(args.Value), (*big.Int)(args.Gas), (*big.Int)(args.GasPrice), args.Data)
+	return types.NewTransaction(uint64(*args.Nonce), common.Address(*args.To), (*big.Int)(args.Value), (*big.Int)(args.Gas), (*big.Int)(args.GasPrice), args.Data)
+}
+
+// checkTxFee rejects transactions whose maximum fee exceeds the configured cap,
+// protecting users from accidentally burning funds on mistyped gas prices.
+func checkTxFee(tx *types.Transaction, cap float64) error {
+	if cap == 0 {
+		return nil
+	}
+	fee := new(big.Float).SetInt(new(big.Int).Mul(tx.GasPrice(), tx.Gas()))
+	fee.Quo(fee, new(big.Float).SetInt(big.NewInt(params.Ether)))
+	if feeEther, _ := fee.Float64(); feeEther > cap {
+		return fmt.Errorf("tx fee (%.2f ether) exceeds the configured cap (%.2f ether)", feeEther, cap)
+	}
+	return nil
 }
 
 // submitTransaction is a helper function that submits tx to txPool and logs a message.
 func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (common.Hash, error) {
+	if err := checkTxFee(tx, b.RPCTxFeeCap()); err != nil {
+		return common.Hash{}, err
+	}
 	if err := b.SendTx(ctx, tx); err != nil {
 		return common.Hash{}, err
 	}
@@ -1175,7 +1442,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 		return common.Hash{}, err
 	}
 	// Look up the wallet containing the requested signer
-	account := accounts.Account{Address: args.From}
+	account := accounts.Account{Address: common.Address(args.From)}
 
 	wallet, err := s.b.AccountManager().Find(account)
 	if err != nil {
@@ -1202,7 +1469,9 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
 		return "", err
 	}
-
+	if err := checkTxFee(tx, s.b.RPCTxFeeCap()); err != nil {
+		return "", err
+	}
 	if err := s.b.SendTx(ctx, tx); err != nil {
 		return "", err
 	}
@@ -1231,9 +1500,9 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 // The account associated with addr must be unlocked.
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_sign
-func (s *PublicTransactionPoolAPI) Sign(addr common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+func (s *PublicTransactionPoolAPI) Sign(addr common.ChecksumAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
 	// Look up the wallet containing the requested signer
-	account := accounts.Account{Address: addr}
+	account := accounts.Account{Address: common.Address(addr)}
 
 	wallet, err := s.b.AccountManager().Find(account)
 	if err != nil {
@@ -1260,7 +1529,10 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 	if err := args.setDefaults(ctx, s.b); err != nil {
 		return nil, err
 	}
-	tx, err := s.sign(args.From, args.toTransaction())
+	if err := checkTxFee(args.toTransaction(), s.b.RPCTxFeeCap()); err != nil {
+		return nil, err
+	}
+	tx, err := s.sign(common.Address(args.From), args.toTransaction())
 	if err != nil {
 		return nil, err
 	}
@@ -1272,22 +1544,23 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 }
 
 // PendingTransactions returns the transactions that are in the transaction pool and have a from address that is one of
-// the accounts this node manages.
+// the accounts this node manages. The pool is looked up by account, so the cost doesn't grow with the pool size.
 func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, error) {
-	pending, err := s.b.GetPoolTransactions()
-	if err != nil {
-		return nil, err
-	}
+	pending, _ := s.b.TxPoolContent()
 
-	transactions := make([]*RPCTransaction, 0, len(pending))
-	for _, tx := range pending {
-		var signer types.Signer = types.HomesteadSigner{}
-		if tx.Protected() {
-			signer = types.NewEIP155Signer(tx.ChainId())
-		}
-		from, _ := types.Sender(signer, tx)
-		if _, err := s.b.AccountManager().Find(accounts.Account{Address: from}); err == nil {
-			transactions = append(transactions, newRPCPendingTransaction(tx))
+	var (
+		transactions = make([]*RPCTransaction, 0)
+		seen         = make(map[common.Address]bool)
+	)
+	for _, wallet := range s.b.AccountManager().Wallets() {
+		for _, account := range wallet.Accounts() {
+			if seen[account.Address] {
+				continue // Account available through multiple wallets
+			}
+			seen[account.Address] = true
+			for _, tx := range pending[account.Address] {
+				transactions = append(transactions, NewRPCPendingTransaction(tx))
+			}
 		}
 	}
 	return transactions, nil
@@ -1315,7 +1588,7 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 		}
 		wantSigHash := signer.Hash(matchTx)
 
-		if pFrom, err := types.Sender(signer, p); err == nil && pFrom == sendArgs.From && signer.Hash(p) == wantSigHash {
+		if pFrom, err := types.Sender(signer, p); err == nil && pFrom == common.Address(sendArgs.From) && signer.Hash(p) == wantSigHash {
 			// Match. Re-sign and send the transaction.
 			if gasPrice != nil {
 				sendArgs.GasPrice = gasPrice
@@ -1323,7 +1596,10 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 			if gasLimit != nil {
 				sendArgs.Gas = gasLimit
 			}
-			signedTx, err := s.sign(sendArgs.From, sendArgs.toTransaction())
+			if err := checkTxFee(sendArgs.toTransaction(), s.b.RPCTxFeeCap()); err != nil {
+				return common.Hash{}, err
+			}
+			signedTx, err := s.sign(common.Address(sendArgs.From), sendArgs.toTransaction())
 			if err != nil {
 				return common.Hash{}, err
 			}
@@ -1457,3 +1733,47 @@ func (s *PublicNetAPI) PeerCount() hexutil.Uint {
 func (s *PublicNetAPI) Version() string {
 	return fmt.Sprintf("%d", s.networkVersion)
 }
+
+// Connectivity returns the number of connected peers broken down by protocol
+// and connection direction, along with the dial activity and the size of the
+// discovery table.
+func (s *PublicNetAPI) Connectivity() *p2p.ConnectivityInfo {
+	return s.net.ConnectivityInfo()
+}
+
+// NodeFingerprint identifies a node together with the chain it follows, letting
+// clients verify in a single call that they talk to the node and network they
+// expect.
+type NodeFingerprint struct {
+	Enode   string       `json:"enode"`
+	Genesis common.Hash  `json:"genesis"`
+	ChainId *hexutil.Big `json:"chainId"`
+}
+
+// PublicFingerprintAPI offers the node fingerprint as part of the web3 namespace.
+type PublicFingerprintAPI struct {
+	b   Backend
+	net *p2p.Server
+}
+
+// NewPublicFingerprintAPI creates a new fingerprint API instance.
+func NewPublicFingerprintAPI(b Backend, net *p2p.Server) *PublicFingerprintAPI {
+	return &PublicFingerprintAPI{b, net}
+}
+
+// Fingerprint returns the enode URL of the node, the hash of the genesis block and
+// the chain ID used for replay protected transactions.
+func (s *PublicFingerprintAPI) Fingerprint(ctx context.Context) (*NodeFingerprint, error) {
+	genesis, err := s.b.HeaderByNumber(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if genesis == nil {
+		return nil, fmt.Errorf("genesis block not found")
+	}
+	return &NodeFingerprint{
+		Enode:   s.net.NodeInfo().Enode,
+		Genesis: genesis.Hash(),
+		ChainId: (*hexutil.Big)(s.b.ChainConfig().ChainId),
+	}, nil
+}
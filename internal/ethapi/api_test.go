@@ -0,0 +1,453 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/accounts"
+	"github.com/expanse-org/go-expanse/accounts/keystore"
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/math"
+	"github.com/expanse-org/go-expanse/consensus"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/p2p"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+// Tests that transactions are only rejected if their maximum fee exceeds the
+// configured cap, and that a zero cap disables the check.
+func TestCheckTxFee(t *testing.T) {
+	tests := []struct {
+		gasPrice *big.Int
+		gas      *big.Int
+		cap      float64
+		fail     bool
+	}{
+		{big.NewInt(params.Shannon), big.NewInt(21000), 1, false},
+		{big.NewInt(params.Ether), big.NewInt(21000), 1, true},
+		{big.NewInt(params.Ether), big.NewInt(21000), 0, false},
+		{new(big.Int).Div(big.NewInt(params.Ether), big.NewInt(1000000)), big.NewInt(1000000), 1, false},
+		{new(big.Int).Div(big.NewInt(params.Ether), big.NewInt(1000000)), big.NewInt(1000001), 1, true},
+	}
+	for i, tt := range tests {
+		tx := types.NewTransaction(0, common.Address{}, new(big.Int), tt.gas, tt.gasPrice, nil)
+		if err := checkTxFee(tx, tt.cap); (err != nil) != tt.fail {
+			t.Errorf("test %d: failure mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+}
+
+// fingerprintBackend implements the parts of Backend the fingerprint relies on.
+type fingerprintBackend struct {
+	Backend
+	genesis *types.Header
+	config  *params.ChainConfig
+}
+
+func (b *fingerprintBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number != 0 {
+		return nil, nil
+	}
+	return b.genesis, nil
+}
+
+func (b *fingerprintBackend) ChainConfig() *params.ChainConfig {
+	return b.config
+}
+
+// Tests that the node fingerprint combines the enode, genesis and chain ID.
+func TestNodeFingerprint(t *testing.T) {
+	backend := &fingerprintBackend{
+		genesis: &types.Header{Number: new(big.Int), Difficulty: big.NewInt(131072), Extra: []byte("genesis")},
+		config:  params.TestChainConfig,
+	}
+	api := NewPublicFingerprintAPI(backend, &p2p.Server{})
+
+	fingerprint, err := api.Fingerprint(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve fingerprint: %v", err)
+	}
+	if !strings.HasPrefix(fingerprint.Enode, "enode://") {
+		t.Errorf("enode mismatch: have %q, want enode URL", fingerprint.Enode)
+	}
+	if fingerprint.Genesis != backend.genesis.Hash() {
+		t.Errorf("genesis mismatch: have %x, want %x", fingerprint.Genesis, backend.genesis.Hash())
+	}
+	if fingerprint.ChainId.ToInt().Cmp(params.TestChainConfig.ChainId) != 0 {
+		t.Errorf("chain ID mismatch: have %v, want %v", fingerprint.ChainId, params.TestChainConfig.ChainId)
+	}
+}
+
+// authorBackend implements the parts of Backend block marshalling relies on,
+// sealing blocks with an engine that reports a fixed signer as their author.
+type authorBackend struct {
+	Backend
+	signer common.Address
+}
+
+func (b *authorBackend) GetTd(hash common.Hash) *big.Int {
+	return new(big.Int)
+}
+
+func (b *authorBackend) Engine() consensus.Engine {
+	return core.NewPowEngine(signerPow{signer: b.signer})
+}
+
+// signerPow is a non-validating engine reporting a fixed signer as block author.
+type signerPow struct {
+	pow.FakePow
+	signer common.Address
+}
+
+func (p signerPow) Author(header *types.Header) (common.Address, error) {
+	return p.signer, nil
+}
+
+// Tests that blocks report the author retrieved from the engine as their miner
+// instead of the coinbase.
+func TestBlockMinerAuthor(t *testing.T) {
+	backend := &authorBackend{signer: common.Address{0x02}}
+	api := NewPublicBlockChainAPI(backend)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Coinbase: common.Address{0x01}})
+	fields, err := api.rpcOutputBlock(block, false, false)
+	if err != nil {
+		t.Fatalf("failed to marshal block: %v", err)
+	}
+	if miner := fields["miner"]; miner != common.ChecksumAddress(backend.signer) {
+		t.Errorf("miner mismatch: have %v, want %x", miner, backend.signer)
+	}
+}
+
+// Tests that the header fields of block responses carry the same names and
+// encodings as the JSON encoding of the header.
+func TestBlockHeaderFields(t *testing.T) {
+	head := &types.Header{
+		ParentHash: common.Hash{0x01},
+		Coinbase:   common.Address{0x02},
+		Root:       common.Hash{0x03},
+		Difficulty: big.NewInt(131072),
+		Number:     big.NewInt(100),
+		GasLimit:   big.NewInt(4712388),
+		GasUsed:    big.NewInt(21000),
+		Time:       big.NewInt(1500000000),
+		Extra:      []byte("extra"),
+		Nonce:      types.EncodeNonce(42),
+	}
+	have, err := json.Marshal(rpcOutputHeader(head))
+	if err != nil {
+		t.Fatalf("failed to marshal header fields: %v", err)
+	}
+	want, err := json.Marshal(head)
+	if err != nil {
+		t.Fatalf("failed to marshal header: %v", err)
+	}
+	var haveFields, wantFields map[string]interface{}
+	json.Unmarshal(have, &haveFields)
+	json.Unmarshal(want, &wantFields)
+	if !reflect.DeepEqual(haveFields, wantFields) {
+		t.Errorf("header fields mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
+
+// Tests that receipt responses decode into the receipt they were created from.
+func TestReceiptFields(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.Address{0x01}
+
+	tests := []struct {
+		tx       *types.Transaction
+		contract common.Address
+	}{
+		{types.NewTransaction(0, to, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil), common.Address{}},
+		{types.NewContractCreation(1, big.NewInt(1), big.NewInt(100000), big.NewInt(1), []byte{0x60}), common.Address{0x02}},
+	}
+	for i, tt := range tests {
+		tx, _ := types.SignTx(tt.tx, types.HomesteadSigner{}, key)
+		receipt := &types.Receipt{
+			PostState:         common.Hash{0x03}.Bytes(),
+			CumulativeGasUsed: big.NewInt(42000),
+			TxHash:            tx.Hash(),
+			ContractAddress:   tt.contract,
+			GasUsed:           big.NewInt(21000),
+			Logs:              []*types.Log{{Address: to, Topics: []common.Hash{{0x04}}, Data: []byte{0x05}, BlockNumber: 7, TxHash: tx.Hash(), Index: 1}},
+		}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+		enc, err := json.Marshal(newRPCReceipt(receipt, tx, common.Hash{0x06}, 7, 1))
+		if err != nil {
+			t.Fatalf("test %d: failed to marshal receipt: %v", i, err)
+		}
+		var dec types.Receipt
+		if err := json.Unmarshal(enc, &dec); err != nil {
+			t.Fatalf("test %d: failed to decode receipt: %v", i, err)
+		}
+		if !reflect.DeepEqual(&dec, receipt) {
+			t.Errorf("test %d: receipt mismatch:\nhave %+v\nwant %+v", i, &dec, receipt)
+		}
+		var fields struct {
+			From            common.Address  `json:"from"`
+			To              *common.Address `json:"to"`
+			ContractAddress *common.Address `json:"contractAddress"`
+		}
+		if err := json.Unmarshal(enc, &fields); err != nil {
+			t.Fatalf("test %d: failed to decode receipt fields: %v", i, err)
+		}
+		if from := crypto.PubkeyToAddress(key.PublicKey); fields.From != from {
+			t.Errorf("test %d: sender mismatch: have %x, want %x", i, fields.From, from)
+		}
+		if from := crypto.PubkeyToAddress(key.PublicKey); !strings.Contains(string(enc), `"from":"`+from.ChecksumHex()+`"`) {
+			t.Errorf("test %d: sender not checksummed: %s", i, enc)
+		}
+		if (fields.To == nil) != (tx.To() == nil) {
+			t.Errorf("test %d: recipient mismatch: have %v, want %v", i, fields.To, tx.To())
+		}
+		if tt.contract == (common.Address{}) && fields.ContractAddress != nil {
+			t.Errorf("test %d: contract address mismatch: have %x, want null", i, *fields.ContractAddress)
+		}
+	}
+}
+
+// poolBackend implements the parts of Backend the pending transaction lookup
+// relies on.
+type poolBackend struct {
+	Backend
+	am      *accounts.Manager
+	pending map[common.Address]types.Transactions
+}
+
+func (b *poolBackend) AccountManager() *accounts.Manager {
+	return b.am
+}
+
+func (b *poolBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	return b.pending, nil
+}
+
+// Tests that only the pending transactions of the accounts managed by the node
+// are returned.
+func TestPendingTransactionsOwnAccounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pending-txs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	own, err := ks.NewAccount("")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	var (
+		foreign = common.Address{0xff}
+		mine    = types.NewTransaction(0, foreign, new(big.Int), big.NewInt(21000), new(big.Int), nil)
+		theirs  = types.NewTransaction(0, own.Address, new(big.Int), big.NewInt(21000), new(big.Int), nil)
+	)
+	backend := &poolBackend{
+		am: accounts.NewManager(ks),
+		pending: map[common.Address]types.Transactions{
+			own.Address: {mine},
+			foreign:     {theirs},
+		},
+	}
+	txs, err := NewPublicTransactionPoolAPI(backend).PendingTransactions()
+	if err != nil {
+		t.Fatalf("failed to retrieve pending transactions: %v", err)
+	}
+	if len(txs) != 1 || txs[0].Hash != mine.Hash() {
+		t.Fatalf("pending transactions mismatch: have %v, want [%x]", txs, mine.Hash())
+	}
+}
+
+// callState wraps a state database to implement the State of a call backend.
+type callState struct {
+	*state.StateDB
+}
+
+func (s callState) GetBalance(ctx context.Context, addr common.Address) (*big.Int, error) {
+	return s.StateDB.GetBalance(addr), nil
+}
+
+func (s callState) GetCode(ctx context.Context, addr common.Address) ([]byte, error) {
+	return s.StateDB.GetCode(addr), nil
+}
+
+func (s callState) GetState(ctx context.Context, addr common.Address, key common.Hash) (common.Hash, error) {
+	return s.StateDB.GetState(addr, key), nil
+}
+
+func (s callState) GetNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return s.StateDB.GetNonce(addr), nil
+}
+
+// callBackend implements the parts of Backend executing calls rely on, serving
+// a copy of the same state for every block.
+type callBackend struct {
+	Backend
+	statedb *state.StateDB
+	header  *types.Header
+}
+
+func (b *callBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (State, *types.Header, error) {
+	return callState{b.statedb.Copy()}, b.header, nil
+}
+
+func (b *callBackend) RPCGasCap() *big.Int {
+	return nil
+}
+
+func (b *callBackend) GetEVM(ctx context.Context, msg core.Message, state State, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	statedb := state.(callState).StateDB
+	statedb.SetBalance(msg.From(), math.MaxBig256)
+
+	context := core.NewEVMContext(msg, header, nil, &common.Address{})
+	return vm.NewEVM(context, statedb, params.TestChainConfig, vmCfg), func() error { return nil }, nil
+}
+
+// Tests that calls are executed on the state with the requested account fields
+// overridden, leaving the state of the chain untouched.
+func TestCallStateOverride(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	var (
+		from    = common.Address{0x01}
+		storage = common.Address{0x02} // Returns storage slot 0
+		balance = common.Address{0x03} // Returns its own balance
+	)
+	statedb.SetCode(storage, common.FromHex("0x60005460005260206000f3"))
+	statedb.SetState(storage, common.Hash{}, common.BigToHash(big.NewInt(1)))
+	statedb.SetBalance(balance, big.NewInt(2))
+
+	backend := &callBackend{
+		statedb: statedb,
+		header:  &types.Header{Number: big.NewInt(1), Time: new(big.Int), Difficulty: new(big.Int), GasLimit: big.NewInt(4712388)},
+	}
+	api := NewPublicBlockChainAPI(backend)
+
+	tests := []struct {
+		to        common.Address
+		overrides *StateOverride
+		want      int64
+	}{
+		{storage, nil, 1},
+		{storage, &StateOverride{common.ChecksumAddress(storage): {Storage: map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(3))}}}, 3},
+		{balance, &StateOverride{common.ChecksumAddress(balance): {Code: (*hexutil.Bytes)(&[]byte{0x30, 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3})}}, 2},
+		{balance, &StateOverride{common.ChecksumAddress(balance): {Code: (*hexutil.Bytes)(&[]byte{0x30, 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}), Balance: (*hexutil.Big)(big.NewInt(4))}}, 4},
+	}
+	for i, tt := range tests {
+		to := common.ChecksumAddress(tt.to)
+		out, err := api.Call(context.Background(), CallArgs{From: common.ChecksumAddress(from), To: &to}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), tt.overrides)
+		if err != nil {
+			t.Errorf("test %d: call failed: %v", i, err)
+			continue
+		}
+		if have := new(big.Int).SetBytes(out); have.Int64() != tt.want {
+			t.Errorf("test %d: result mismatch: have %v, want %d", i, have, tt.want)
+		}
+	}
+	// The overrides must not leak into the state of the chain
+	if have := statedb.GetState(storage, common.Hash{}); have != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("overridden storage leaked: have %x", have)
+	}
+	if have := statedb.GetBalance(balance); have.Int64() != 2 {
+		t.Errorf("overridden balance leaked: have %v", have)
+	}
+}
+
+// Tests that gas estimation finds the minimal gas allowance a transaction needs
+// and reports why transactions failing at any allowance do so.
+func TestEstimateGas(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, db)
+
+	var (
+		from     = common.Address{0x01}
+		store    = common.Address{0x02} // Sets storage slot 0
+		reason   = common.Address{0x03} // Reverts with Error("revert reason")
+		revert   = common.Address{0x04} // Reverts without data
+		infinite = common.Address{0x05} // Loops until out of gas
+	)
+	statedb.SetCode(store, common.FromHex("0x600160005500"))
+	statedb.SetCode(reason, common.FromHex("0x6064600c60003960646000fd"+
+		"08c379a0"+
+		"0000000000000000000000000000000000000000000000000000000000000020"+
+		"000000000000000000000000000000000000000000000000000000000000000d"+
+		"72657665727420726561736f6e00000000000000000000000000000000000000"))
+	statedb.SetCode(revert, common.FromHex("0x60006000fd"))
+	statedb.SetCode(infinite, common.FromHex("0x5b600056"))
+
+	backend := &callBackend{
+		statedb: statedb,
+		header:  &types.Header{Number: big.NewInt(1), Time: new(big.Int), Difficulty: new(big.Int), GasLimit: big.NewInt(4712388)},
+	}
+	api := NewPublicBlockChainAPI(backend)
+
+	tests := []struct {
+		to   common.Address
+		want uint64
+		err  string
+		data interface{}
+	}{
+		{common.Address{0xff}, params.TxGas, "", nil},
+		{store, params.TxGas + 20006, "", nil},
+		{reason, 0, "execution reverted: revert reason", "revert reason"},
+		{revert, 0, "execution reverted", "0x"},
+		{infinite, 0, "gas required exceeds allowance (100000)", nil},
+	}
+	for i, tt := range tests {
+		to := common.ChecksumAddress(tt.to)
+		args := CallArgs{From: common.ChecksumAddress(from), To: &to, Gas: *(*hexutil.Big)(big.NewInt(100000))}
+
+		gas, err := api.EstimateGas(context.Background(), args)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+				continue
+			}
+			if tt.data != nil {
+				if derr, ok := err.(rpc.DataError); !ok || derr.ErrorData() != tt.data {
+					t.Errorf("test %d: error data mismatch: have %v, want %v", i, err, tt.data)
+				}
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: estimation failed: %v", i, err)
+			continue
+		}
+		if have := (*big.Int)(gas).Uint64(); have != tt.want {
+			t.Errorf("test %d: estimate mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+}
@@ -19,10 +19,13 @@ package ethapi
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/expanse-org/go-expanse/accounts"
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/core/vm"
@@ -33,6 +36,34 @@ import (
 	"github.com/expanse-org/go-expanse/rpc"
 )
 
+var (
+	// ErrNoFinality is returned for the safe and finalized block tags, as the
+	// blocks of proof-of-work chains are never final.
+	ErrNoFinality = errors.New("safe and finalized blocks not available on proof-of-work chains")
+
+	// ErrNotCanonical is returned for hash based queries requiring a canonical
+	// block, if the block is not part of the canonical chain.
+	ErrNotCanonical = errors.New("hash is not currently canonical")
+)
+
+// ResolveBlockNumber resolves a block number or tag into the number of a canonical
+// block, given the number of the current head. The latest and pending tags are
+// resolved to the head, whereas the finality tags fail with ErrNoFinality. All
+// APIs accepting block tags are expected to resolve them through this method, so
+// they behave the same way.
+func ResolveBlockNumber(number rpc.BlockNumber, head uint64) (uint64, error) {
+	switch number {
+	case rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		return 0, ErrNoFinality
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		return head, nil
+	}
+	if number < 0 {
+		return 0, fmt.Errorf("invalid block number %d", number)
+	}
+	return uint64(number), nil
+}
+
 // Backend interface provides the common API services (that are provided by
 // both full and light clients) with access to necessary functions.
 type Backend interface {
@@ -40,14 +71,20 @@ type Backend interface {
 	Downloader() *downloader.Downloader
 	ProtocolVersion() int
 	SuggestPrice(ctx context.Context) (*big.Int, error)
+	FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, percentiles []float64) (*big.Int, [][]*big.Int, []float64, error)
+	RPCGasCap() *big.Int           // Gas allowance of eth_call and eth_estimateGas (nil = unlimited)
+	RPCTxFeeCap() float64          // Highest fee in ether of transactions sent via the APIs (0 = unlimited)
+	ResponseCache() *ResponseCache // Cache of immutable API responses (nil = disabled)
 	ChainDb() ethdb.Database
 	EventMux() *event.TypeMux
 	AccountManager() *accounts.Manager
 	// BlockChain API
 	SetHead(number uint64)
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
+	HeaderByHash(ctx context.Context, blockHash common.Hash) (*types.Header, error)
 	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
 	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (State, *types.Header, error)
+	StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (State, *types.Header, error)
 	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 	GetTd(blockHash common.Hash) *big.Int
@@ -60,9 +97,11 @@ type Backend interface {
 	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
 	Stats() (pending int, queued int)
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
+	TxPoolMinGasPrice() *big.Int // Gas price floor of the pool (nil = none)
 
 	ChainConfig() *params.ChainConfig
 	CurrentBlock() *types.Block
+	Engine() consensus.Engine // Engine sealing the blocks, reporting their authors
 }
 
 type State interface {
@@ -0,0 +1,114 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/metrics"
+	"github.com/hashicorp/golang-lru"
+)
+
+// Tiers of the response cache, each limited in size independently so large
+// traces don't evict the cheaper but far more frequent block responses.
+const (
+	CacheBlocks   = iota // Blocks by number and hash
+	CacheReceipts        // Transaction receipts
+	CacheTraces          // Transaction traces
+	cacheTiers
+)
+
+// cacheTierDivisors scales the configured cache size down per tier.
+var cacheTierDivisors = [cacheTiers]int{1, 1, 16}
+
+var (
+	cacheHitMeter  = metrics.NewMeter("rpc/cache/hit")
+	cacheMissMeter = metrics.NewMeter("rpc/cache/miss")
+)
+
+// cachedResponse is an API response derived from a canonical block.
+type cachedResponse struct {
+	number uint64
+	hash   common.Hash
+	value  interface{}
+}
+
+// ResponseCache caches the API responses derived from blocks buried deep enough
+// in the canonical chain to be considered immutable, sparing public endpoints
+// from reassembling them on every request.
+//
+// Every response remembers the block it was derived from, and is dropped when
+// retrieved after a reorg deeper than the confirmations replaced that block.
+//
+// A nil cache is valid and caches nothing.
+type ResponseCache struct {
+	db            ethdb.Database
+	head          func() uint64 // Number of the current head block
+	confirmations uint64        // Blocks a response's block must be buried under to be cached
+	tiers         [cacheTiers]*lru.Cache
+}
+
+// NewResponseCache creates a response cache holding up to size responses per
+// tier, derived from blocks at least the given number of confirmations deep.
+func NewResponseCache(db ethdb.Database, head func() uint64, size int, confirmations uint64) *ResponseCache {
+	c := &ResponseCache{
+		db:            db,
+		head:          head,
+		confirmations: confirmations,
+	}
+	for i := range c.tiers {
+		limit := size / cacheTierDivisors[i]
+		if limit < 1 {
+			limit = 1
+		}
+		c.tiers[i], _ = lru.New(limit)
+	}
+	return c
+}
+
+// Get retrieves a cached response, if its block is still canonical.
+func (c *ResponseCache) Get(tier int, key string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	cached, ok := c.tiers[tier].Get(key)
+	if !ok {
+		cacheMissMeter.Mark(1)
+		return nil, false
+	}
+	response := cached.(*cachedResponse)
+	if core.GetCanonicalHash(c.db, response.number) != response.hash {
+		c.tiers[tier].Remove(key)
+		cacheMissMeter.Mark(1)
+		return nil, false
+	}
+	cacheHitMeter.Mark(1)
+	return response.value, true
+}
+
+// Put caches a response derived from the given block, unless the block is still
+// within the window of reorgs.
+func (c *ResponseCache) Put(tier int, key string, number uint64, hash common.Hash, value interface{}) {
+	if c == nil {
+		return
+	}
+	if head := c.head(); number > head || head-number < c.confirmations {
+		return
+	}
+	c.tiers[tier].Add(key, &cachedResponse{number: number, hash: hash, value: value})
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/ethdb"
+)
+
+// Tests that only responses of confirmed blocks are cached, and that they are
+// dropped once their block is reorged out.
+func TestResponseCache(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	for i := uint64(0); i <= 100; i++ {
+		core.WriteCanonicalHash(db, common.Hash{byte(i)}, i)
+	}
+	cache := NewResponseCache(db, func() uint64 { return 100 }, 32, 10)
+
+	// Responses of blocks within the confirmations must not be cached
+	cache.Put(CacheBlocks, "recent", 95, common.Hash{95}, "recent")
+	if _, ok := cache.Get(CacheBlocks, "recent"); ok {
+		t.Errorf("unconfirmed response cached")
+	}
+	cache.Put(CacheBlocks, "old", 90, common.Hash{90}, "old")
+	if response, ok := cache.Get(CacheBlocks, "old"); !ok || response != "old" {
+		t.Errorf("confirmed response mismatch: have %v/%v, want old/true", response, ok)
+	}
+	// Tiers must be separate
+	if _, ok := cache.Get(CacheReceipts, "old"); ok {
+		t.Errorf("response leaked across tiers")
+	}
+	// Reorg the block out and ensure its response is dropped
+	core.WriteCanonicalHash(db, common.Hash{0xff}, 90)
+	if _, ok := cache.Get(CacheBlocks, "old"); ok {
+		t.Errorf("response of reorged block served")
+	}
+	// Ensure the size of every tier is limited
+	for i := 0; i < 64; i++ {
+		cache.Put(CacheTraces, fmt.Sprintf("trace-%d", i), 10, common.Hash{10}, i)
+	}
+	if have, want := cache.tiers[CacheTraces].Len(), 32/cacheTierDivisors[CacheTraces]; have != want {
+		t.Errorf("trace tier size mismatch: have %d, want %d", have, want)
+	}
+	// Ensure a disabled cache is usable
+	var disabled *ResponseCache
+	disabled.Put(CacheBlocks, "old", 10, common.Hash{10}, "old")
+	if _, ok := disabled.Get(CacheBlocks, "old"); ok {
+		t.Errorf("disabled cache served response")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+// forecastBlocks is the number of recent blocks the fullness of the chain is
+// averaged over when forecasting transaction inclusion.
+const forecastBlocks = 20
+
+var errTxNotInPool = errors.New("transaction not in pool")
+
+// InclusionForecast estimates how soon a transaction paying a given gas price
+// gets included into the chain, assuming miners order transactions by price and
+// fill blocks up to the recent average gas limit.
+type InclusionForecast struct {
+	GasPrice    *hexutil.Big    `json:"gasPrice"`
+	MinGasPrice *hexutil.Big    `json:"minGasPrice"`      // Floor of the pool, nil if unknown
+	Blocks      *hexutil.Uint64 `json:"blocks"`           // Expected blocks until inclusion, nil if not includable
+	Reason      string          `json:"reason,omitempty"` // Why the transaction isn't includable
+	GasAhead    hexutil.Uint64  `json:"gasAhead"`         // Pending gas to be included first
+	Capacity    hexutil.Uint64  `json:"capacity"`         // Average gas limit of recent blocks
+	Fullness    float64         `json:"fullness"`         // Average ratio of gas used to gas limit of recent blocks
+}
+
+// ForecastInclusion estimates the number of blocks until a new transaction with
+// the given gas price is included, based on the pending transactions paying at
+// least as much, the gas price floor of the pool and recent block fullness.
+func (s *PublicTxPoolAPI) ForecastInclusion(ctx context.Context, gasPrice hexutil.Big) (*InclusionForecast, error) {
+	pending, _ := s.b.TxPoolContent()
+	return s.forecast(ctx, (*big.Int)(&gasPrice), pendingGasAhead(pending, (*big.Int)(&gasPrice), common.Hash{}))
+}
+
+// ForecastTxInclusion estimates the number of blocks until the pool transaction
+// with the given hash is included. Transactions of the same sender with lower
+// nonces are accounted for regardless of their gas price, and transactions which
+// can't be executed yet due to a nonce gap aren't includable at all.
+func (s *PublicTxPoolAPI) ForecastTxInclusion(ctx context.Context, hash common.Hash) (*InclusionForecast, error) {
+	tx := s.b.GetPoolTransaction(hash)
+	if tx == nil {
+		return nil, errTxNotInPool
+	}
+	pending, queued := s.b.TxPoolContent()
+	for _, txs := range queued {
+		for _, qtx := range txs {
+			if qtx.Hash() == hash {
+				forecast, err := s.forecast(ctx, tx.GasPrice(), 0)
+				if forecast != nil && forecast.Reason == "" {
+					forecast.Blocks, forecast.Reason = nil, "nonce gap"
+				}
+				return forecast, err
+			}
+		}
+	}
+	return s.forecast(ctx, tx.GasPrice(), pendingGasAhead(pending, tx.GasPrice(), hash))
+}
+
+// forecast assembles the inclusion forecast of a transaction with the given gas
+// price, preceded by the given amount of pending gas.
+func (s *PublicTxPoolAPI) forecast(ctx context.Context, gasPrice *big.Int, gasAhead uint64) (*InclusionForecast, error) {
+	capacity, fullness, err := recentFullness(ctx, s.b, forecastBlocks)
+	if err != nil {
+		return nil, err
+	}
+	forecast := &InclusionForecast{
+		GasPrice: (*hexutil.Big)(new(big.Int).Set(gasPrice)),
+		GasAhead: hexutil.Uint64(gasAhead),
+		Capacity: hexutil.Uint64(capacity),
+		Fullness: fullness,
+	}
+	floor := s.b.TxPoolMinGasPrice()
+	if floor != nil {
+		forecast.MinGasPrice = (*hexutil.Big)(floor)
+	}
+	switch {
+	case floor != nil && gasPrice.Cmp(floor) < 0:
+		forecast.Reason = "gas price below pool minimum"
+	case capacity == 0:
+		forecast.Reason = "no recent blocks"
+	default:
+		blocks := hexutil.Uint64(gasAhead/capacity + 1)
+		forecast.Blocks = &blocks
+	}
+	return forecast, nil
+}
+
+// pendingGasAhead sums up the gas of the pending transactions which miners would
+// include before a transaction with the given gas price: the ones paying at
+// least as much, and if the hash of a pending transaction is given, the ones of
+// its sender with lower nonces.
+func pendingGasAhead(pending map[common.Address]types.Transactions, gasPrice *big.Int, hash common.Hash) uint64 {
+	var gas uint64
+	for _, txs := range pending {
+		// Transactions of the same sender are included in nonce order
+		own := -1
+		for i, tx := range txs {
+			if tx.Hash() == hash {
+				own = i
+				break
+			}
+		}
+		if own >= 0 {
+			for _, tx := range txs[:own] {
+				gas += tx.Gas().Uint64()
+			}
+			continue
+		}
+		for _, tx := range txs {
+			if tx.GasPrice().Cmp(gasPrice) >= 0 {
+				gas += tx.Gas().Uint64()
+			}
+		}
+	}
+	return gas
+}
+
+// recentFullness returns the average gas limit of the given number of most
+// recent blocks and their average ratio of used gas.
+func recentFullness(ctx context.Context, b Backend, blocks int) (capacity uint64, fullness float64, err error) {
+	head, err := b.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil || head == nil {
+		return 0, 0, err
+	}
+	var (
+		used, limit = new(big.Int), new(big.Int)
+		counted     int64
+	)
+	for header := head; header != nil; {
+		used.Add(used, header.GasUsed)
+		limit.Add(limit, header.GasLimit)
+		counted++
+
+		if counted == int64(blocks) || header.Number.Sign() == 0 {
+			break
+		}
+		if header, err = b.HeaderByNumber(ctx, rpc.BlockNumber(header.Number.Int64()-1)); err != nil {
+			return 0, 0, err
+		}
+	}
+	if limit.Sign() == 0 {
+		return 0, 0, nil
+	}
+	fullness, _ = new(big.Rat).SetFrac(used, limit).Float64()
+	return new(big.Int).Div(limit, big.NewInt(counted)).Uint64(), fullness, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+// forecastBackend implements the parts of Backend the inclusion forecast relies on.
+type forecastBackend struct {
+	Backend
+	headers []*types.Header
+	pending map[common.Address]types.Transactions
+	queued  map[common.Address]types.Transactions
+	floor   *big.Int
+}
+
+func (b *forecastBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		number = rpc.BlockNumber(len(b.headers) - 1)
+	}
+	if int(number) >= len(b.headers) {
+		return nil, nil
+	}
+	return b.headers[number], nil
+}
+
+func (b *forecastBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	return b.pending, b.queued
+}
+
+func (b *forecastBackend) TxPoolMinGasPrice() *big.Int {
+	return b.floor
+}
+
+func (b *forecastBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	for _, content := range []map[common.Address]types.Transactions{b.pending, b.queued} {
+		for _, txs := range content {
+			for _, tx := range txs {
+				if tx.Hash() == hash {
+					return tx
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func newForecastTx(nonce uint64, gas, gasPrice int64) *types.Transaction {
+	return types.NewTransaction(nonce, common.Address{}, new(big.Int), big.NewInt(gas), big.NewInt(gasPrice), nil)
+}
+
+// Tests that inclusion is forecast from the pending gas paying at least as much,
+// the recent block capacity and the gas price floor of the pool.
+func TestForecastInclusion(t *testing.T) {
+	var (
+		alice = common.Address{1}
+		bob   = common.Address{2}
+	)
+	backend := &forecastBackend{
+		pending: map[common.Address]types.Transactions{
+			alice: {newForecastTx(0, 60000, 10), newForecastTx(1, 60000, 50)},
+			bob:   {newForecastTx(0, 100000, 30), newForecastTx(1, 100000, 30)},
+		},
+		queued: map[common.Address]types.Transactions{
+			bob: {newForecastTx(5, 21000, 100)},
+		},
+		floor: big.NewInt(5),
+	}
+	for i := int64(0); i < 3; i++ {
+		backend.headers = append(backend.headers, &types.Header{
+			Number:   big.NewInt(i),
+			GasLimit: big.NewInt(100000),
+			GasUsed:  big.NewInt(25000 * i),
+		})
+	}
+	api := NewPublicTxPoolAPI(backend)
+
+	tests := []struct {
+		forecast func() (*InclusionForecast, error)
+		blocks   uint64 // 0 = not includable
+		gasAhead uint64
+		reason   string
+	}{
+		// New transactions are preceded by the ones paying at least as much
+		{func() (*InclusionForecast, error) {
+			return api.ForecastInclusion(context.Background(), hexutil.Big(*big.NewInt(100)))
+		}, 1, 0, ""},
+		{func() (*InclusionForecast, error) {
+			return api.ForecastInclusion(context.Background(), hexutil.Big(*big.NewInt(30)))
+		}, 3, 260000, ""},
+		{func() (*InclusionForecast, error) {
+			return api.ForecastInclusion(context.Background(), hexutil.Big(*big.NewInt(1)))
+		}, 0, 320000, "gas price below pool minimum"},
+		// Pool transactions are preceded by the lower nonces of their sender
+		{func() (*InclusionForecast, error) {
+			return api.ForecastTxInclusion(context.Background(), backend.pending[alice][1].Hash())
+		}, 1, 60000, ""},
+		{func() (*InclusionForecast, error) {
+			return api.ForecastTxInclusion(context.Background(), backend.pending[bob][1].Hash())
+		}, 2, 160000, ""},
+		{func() (*InclusionForecast, error) {
+			return api.ForecastTxInclusion(context.Background(), backend.queued[bob][0].Hash())
+		}, 0, 0, "nonce gap"},
+	}
+	for i, tt := range tests {
+		forecast, err := tt.forecast()
+		if err != nil {
+			t.Fatalf("test %d: failed to forecast inclusion: %v", i, err)
+		}
+		switch {
+		case tt.blocks == 0 && forecast.Blocks != nil:
+			t.Errorf("test %d: blocks mismatch: have %d, want none", i, *forecast.Blocks)
+		case tt.blocks != 0 && (forecast.Blocks == nil || uint64(*forecast.Blocks) != tt.blocks):
+			t.Errorf("test %d: blocks mismatch: have %v, want %d", i, forecast.Blocks, tt.blocks)
+		}
+		if uint64(forecast.GasAhead) != tt.gasAhead {
+			t.Errorf("test %d: gas ahead mismatch: have %d, want %d", i, forecast.GasAhead, tt.gasAhead)
+		}
+		if forecast.Reason != tt.reason {
+			t.Errorf("test %d: reason mismatch: have %q, want %q", i, forecast.Reason, tt.reason)
+		}
+		if forecast.Capacity != 100000 || forecast.Fullness != 0.25 {
+			t.Errorf("test %d: block stats mismatch: have capacity %d fullness %v, want 100000 and 0.25", i, forecast.Capacity, forecast.Fullness)
+		}
+	}
+	if _, err := api.ForecastTxInclusion(context.Background(), common.Hash{}); err != errTxNotInPool {
+		t.Errorf("unknown transaction error mismatch: have %v, want %v", err, errTxNotInPool)
+	}
+}
@@ -32,12 +32,6 @@ func makeCompilerAPIs(solcPath string) []rpc.API {
 			Service:   (*PublicCompilerAPI)(c),
 			Public:    true,
 		},
-		{
-			Namespace: "exp",
-			Version:   "1.0",
-			Service:   (*PublicCompilerAPI)(c),
-			Public:    true,
-		},
 		{
 			Namespace: "admin",
 			Version:   "1.0",
@@ -22,6 +22,7 @@ var Modules = map[string]string{
 	"chequebook": Chequebook_JS,
 	"debug":      Debug_JS,
 	"eth":        Eth_JS,
+	"health":     Health_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
@@ -80,11 +81,21 @@ web3._extend({
 			call: 'admin_removePeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'addTrustedPeer',
+			call: 'admin_addTrustedPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removeTrustedPeer',
+			call: 'admin_removeTrustedPeer',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
-			params: 1,
-			inputFormatter: [null]
+			params: 3,
+			inputFormatter: [null, null, null]
 		}),
 		new web3._extend.Method({
 			name: 'importChain',
@@ -104,8 +115,8 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'startRPC',
 			call: 'admin_startRPC',
-			params: 4,
-			inputFormatter: [null, null, null, null]
+			params: 5,
+			inputFormatter: [null, null, null, null, null]
 		}),
 		new web3._extend.Method({
 			name: 'stopRPC',
@@ -120,6 +131,50 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'stopWS',
 			call: 'admin_stopWS'
+		}),
+		new web3._extend.Method({
+			name: 'auditLog',
+			call: 'admin_auditLog',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'verifyAuditLog',
+			call: 'admin_verifyAuditLog'
+		}),
+		new web3._extend.Method({
+			name: 'rotateNodeKey',
+			call: 'admin_rotateNodeKey'
+		}),
+		new web3._extend.Method({
+			name: 'setNetRestrictions',
+			call: 'admin_setNetRestrictions',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'setMaxPeers',
+			call: 'admin_setMaxPeers',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'reservePeerSlots',
+			call: 'admin_reservePeerSlots',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'reloadConfig',
+			call: 'admin_reloadConfig'
+		}),
+		new web3._extend.Method({
+			name: 'encodeFreezeUpdate',
+			call: 'admin_encodeFreezeUpdate',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'isFrozen',
+			call: 'admin_isFrozen',
+			params: 1
 		})
 	],
 	properties:
@@ -135,11 +190,41 @@ web3._extend({
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
+		}),
+		new web3._extend.Property({
+			name: 'nodeKey',
+			getter: 'admin_nodeKey'
+		}),
+		new web3._extend.Property({
+			name: 'netRestrictions',
+			getter: 'admin_netRestrictions'
+		}),
+		new web3._extend.Property({
+			name: 'peerSlots',
+			getter: 'admin_peerSlots'
 		})
 	]
 });
 `
 
+const Health_JS = `
+web3._extend({
+	property: 'health',
+	methods: [],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'diskUsage',
+			getter: 'health_diskUsage'
+		}),
+		new web3._extend.Property({
+			name: 'loadStatus',
+			getter: 'health_loadStatus'
+		}),
+	]
+});
+`
+
 const Debug_JS = `
 web3._extend({
 	property: 'debug',
@@ -163,22 +248,26 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'traceBlock',
 			call: 'debug_traceBlock',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'traceBlockByFile',
 			call: 'debug_traceBlockByFile',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'traceBlockByNumber',
 			call: 'debug_traceBlockByNumber',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'traceBlockByHash',
 			call: 'debug_traceBlockByHash',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'seedHash',
@@ -190,6 +279,11 @@ web3._extend({
 			call: 'debug_dumpBlock',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'codeByHash',
+			call: 'debug_codeByHash',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'chaindbProperty',
 			call: 'debug_chaindbProperty',
@@ -205,6 +299,10 @@ web3._extend({
 			call: 'debug_metrics',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'rpcStats',
+			call: 'debug_rpcStats'
+		}),
 		new web3._extend.Method({
 			name: 'verbosity',
 			call: 'debug_verbosity',
@@ -303,6 +401,43 @@ web3._extend({
 			call: 'debug_getBadBlocks',
 			params: 0,
 		}),
+		new web3._extend.Method({
+			name: 'banBlockHash',
+			call: 'debug_banBlockHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'unbanBlockHash',
+			call: 'debug_unbanBlockHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'bannedBlockHashes',
+			call: 'debug_bannedBlockHashes',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'shadowForkStats',
+			call: 'debug_shadowForkStats',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'richList',
+			call: 'debug_richList',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getContractCreator',
+			call: 'debug_getContractCreator',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getInternalTransactions',
+			call: 'debug_getInternalTransactions',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 	],
 	properties: []
 });
@@ -342,6 +477,29 @@ web3._extend({
 			call: 'eth_getRawTransactionByHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'chainStats',
+			call: 'eth_chainStats',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'totalSupply',
+			call: 'eth_totalSupply',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter],
+			outputFormatter: web3._extend.utils.toBigNumber
+		}),
+		new web3._extend.Method({
+			name: 'stateAvailability',
+			call: 'eth_stateAvailability',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'capabilities',
+			call: 'eth_capabilities',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'getRawTransactionFromBlock',
 			call: function(args) {
@@ -349,6 +507,18 @@ web3._extend({
 			},
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex]
+		}),
+		new web3._extend.Method({
+			name: 'minerVersions',
+			call: 'eth_minerVersions',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'feeHistory',
+			call: 'eth_feeHistory',
+			params: 3,
+			inputFormatter: [web3._extend.utils.toHex, web3._extend.formatters.inputBlockNumberFormatter, null]
 		})
 	],
 	properties:
@@ -388,7 +558,9 @@ web3._extend({
 			name: 'setEtherbase',
 			call: 'miner_setEtherbase',
 			params: 1,
-			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+			inputFormatter: [function(etherbase) {
+				return web3._extend.utils.isArray(etherbase) ? etherbase : web3._extend.formatters.inputAddressFormatter(etherbase);
+			}]
 		}),
 		new web3._extend.Method({
 			name: 'setExtra',
@@ -404,9 +576,43 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
+		}),
+		new web3._extend.Method({
+			name: 'setStrictParent',
+			call: 'miner_setStrictParent',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'banBlock',
+			call: 'miner_banBlock',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'unbanBlock',
+			call: 'miner_unbanBlock',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setUnclePolicy',
+			call: 'miner_setUnclePolicy',
+			params: 1
 		})
 	],
-	properties: []
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'etherbases',
+			getter: 'miner_etherbases'
+		}),
+		new web3._extend.Property({
+			name: 'bannedBlocks',
+			getter: 'miner_bannedBlocks'
+		}),
+		new web3._extend.Property({
+			name: 'unclePolicy',
+			getter: 'miner_unclePolicy'
+		})
+	]
 });
 `
 
@@ -419,6 +625,10 @@ web3._extend({
 		new web3._extend.Property({
 			name: 'version',
 			getter: 'net_version'
+		}),
+		new web3._extend.Property({
+			name: 'connectivity',
+			getter: 'net_connectivity'
 		})
 	]
 });
@@ -445,6 +655,11 @@ web3._extend({
 			call: 'personal_ecRecover',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'openWallet',
+			call: 'personal_openWallet',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'deriveAccount',
 			call: 'personal_deriveAccount',
@@ -470,6 +685,10 @@ web3._extend({
 		new web3._extend.Property({
 			name: 'modules',
 			getter: 'rpc_modules'
+		}),
+		new web3._extend.Property({
+			name: 'moduleVersions',
+			getter: 'rpc_moduleVersions'
 		})
 	]
 });
@@ -519,7 +738,20 @@ web3._extend({
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'forecastInclusion',
+			call: 'txpool_forecastInclusion',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'forecastTxInclusion',
+			call: 'txpool_forecastTxInclusion',
+			params: 1
+		}),
+	],
 	properties:
 	[
 		new web3._extend.Property({
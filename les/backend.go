@@ -19,12 +19,14 @@ package les
 
 import (
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/expanse-org/go-expanse/accounts"
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/compiler"
 	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/eth"
@@ -59,17 +61,20 @@ type LightEthereum struct {
 	ApiBackend *LesApiBackend
 
 	eventMux       *event.TypeMux
-	pow            pow.PoW
+	engine         consensus.Engine
 	accountManager *accounts.Manager
 	solcPath       string
 	solc           *compiler.Solidity
 
-	netVersionId  int
-	netRPCService *ethapi.PublicNetAPI
+	netVersionId          int
+	netRPCService         *ethapi.PublicNetAPI
+	fingerprintRPCService *ethapi.PublicFingerprintAPI
+	rpcGasCap             *big.Int
+	rpcTxFeeCap           float64
 }
 
 func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
-	chainDb, err := eth.CreateDB(ctx, config, "lightchaindata")
+	chainDb, err := eth.CreateDB(ctx, config, eth.ChainDbName(ctx.ResolvePath, true))
 	if err != nil {
 		return nil, err
 	}
@@ -88,16 +93,23 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 		chainConfig:    chainConfig,
 		eventMux:       ctx.EventMux,
 		accountManager: ctx.AccountManager,
-		pow:            eth.CreatePoW(ctx, config),
+		engine:         eth.CreateConsensusEngine(ctx, config, chainConfig),
 		shutdownChan:   make(chan bool),
 		netVersionId:   config.NetworkId,
 		solcPath:       config.SolcPath,
+		rpcGasCap:      config.RPCGasCap,
+		rpcTxFeeCap:    config.RPCTxFeeCap,
 	}
 
-	eth.blockchain, err = light.NewLightChain(odr, eth.chainConfig, eth.pow, eth.eventMux)
+	eth.blockchain, err = light.NewLightChain(odr, eth.chainConfig, eth.engine, eth.eventMux)
 	if err != nil {
 		return nil, err
 	}
+	if engine, ok := eth.engine.(*core.PowEngine); ok {
+		if interval, ok := engine.PoW.(*pow.IntervalPoW); ok {
+			interval.SetChain(eth.blockchain)
+		}
+	}
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
@@ -106,14 +118,22 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	}
 
 	eth.txPool = light.NewTxPool(eth.chainConfig, eth.eventMux, eth.blockchain, eth.relay)
-	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.LightMode, config.NetworkId, eth.eventMux, eth.pow, eth.blockchain, nil, chainDb, odr, relay); err != nil {
+	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.LightMode, config.NetworkId, eth.eventMux, eth.engine, eth.blockchain, nil, chainDb, odr, relay); err != nil {
 		return nil, err
 	}
 	relay.ps = eth.protocolManager.peers
 	relay.reqDist = eth.protocolManager.reqDist
-
+	relay.odr = odr
+
+	// Let the node shed expensive RPC calls while catching up with the network
+	if ctx.LoadShedder != nil {
+		ctx.LoadShedder.RegisterSyncStatus(func() (bool, uint64) {
+			progress := eth.protocolManager.downloader.Progress()
+			return progress.CurrentBlock < progress.HighestBlock, eth.blockchain.CurrentHeader().Number.Uint64()
+		})
+	}
 	eth.ApiBackend = &LesApiBackend{eth, nil}
-	eth.ApiBackend.gpo = gasprice.NewLightPriceOracle(eth.ApiBackend)
+	eth.ApiBackend.gpo = gasprice.NewOracle(eth.ApiBackend, config.GasPriceOracle())
 	return eth, nil
 }
 
@@ -160,25 +180,14 @@ func (s *LightEthereum) APIs() []rpc.API {
 			Public:    true,
 		},
 		{
-			Namespace: "exp",
-			Version:   "1.0",
-			Service:   &LightDummyAPI{},
-			Public:    true,
-		}, {
-			Namespace: "exp",
+			Namespace: "net",
 			Version:   "1.0",
-			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader, s.eventMux),
+			Service:   s.netRPCService,
 			Public:    true,
 		}, {
-			Namespace: "exp",
-			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true),
-			Public:    true,
-		},
-		{
-			Namespace: "net",
+			Namespace: "web3",
 			Version:   "1.0",
-			Service:   s.netRPCService,
+			Service:   s.fingerprintRPCService,
 			Public:    true,
 		},
 	}...)
@@ -205,6 +214,7 @@ func (s *LightEthereum) Protocols() []p2p.Protocol {
 func (s *LightEthereum) Start(srvr *p2p.Server) error {
 	log.Warn("Light client mode is an experimental feature")
 	s.netRPCService = ethapi.NewPublicNetAPI(srvr, s.netVersionId)
+	s.fingerprintRPCService = ethapi.NewPublicFingerprintAPI(s.ApiBackend, srvr)
 	s.protocolManager.Start(srvr)
 	return nil
 }
@@ -225,3 +235,19 @@ func (s *LightEthereum) Stop() error {
 
 	return nil
 }
+
+// Status implements node.StatusReporter, summarising the header chain head, the
+// state of synchronisation and the transaction pool.
+func (s *LightEthereum) Status() []interface{} {
+	head := s.blockchain.CurrentHeader()
+
+	status := []interface{}{
+		"number", head.Number, "hash", head.Hash(),
+		"peers", s.protocolManager.peers.Len(), "syncing", s.Downloader().Synchronising(),
+	}
+	if s.Downloader().Synchronising() {
+		progress := s.Downloader().Progress()
+		status = append(status, "current", progress.CurrentBlock, "highest", progress.HighestBlock)
+	}
+	return append(status, "pending", s.txPool.Stats())
+}
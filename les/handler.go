@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core"
 	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
@@ -34,12 +35,12 @@ import (
 	"github.com/expanse-org/go-expanse/eth/downloader"
 	"github.com/expanse-org/go-expanse/ethdb"
 	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/light"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/p2p"
 	"github.com/expanse-org/go-expanse/p2p/discover"
 	"github.com/expanse-org/go-expanse/p2p/discv5"
 	"github.com/expanse-org/go-expanse/params"
-	"github.com/expanse-org/go-expanse/pow"
 	"github.com/expanse-org/go-expanse/rlp"
 	"github.com/expanse-org/go-expanse/trie"
 )
@@ -57,6 +58,8 @@ const (
 	MaxProofsFetch       = 64  // Amount of merkle proofs to be fetched per retrieval request
 	MaxHeaderProofsFetch = 64  // Amount of merkle proofs to be fetched per retrieval request
 	MaxTxSend            = 64  // Amount of transactions to be send per request
+	MaxTxStatus          = 256 // Amount of transactions to queried per request
+	MaxBloomBitsFetch    = 64  // Amount of bloom bit vectors to be fetched per retrieval request
 
 	disableClientRemovePeer = false
 )
@@ -89,6 +92,9 @@ type BlockChain interface {
 type txPool interface {
 	// AddTransactions should add the given transactions to the pool.
 	AddBatch([]*types.Transaction) error
+
+	// Status returns the pool status of the given transactions.
+	Status(hashes []common.Hash) []core.TxStatus
 }
 
 type ProtocolManager struct {
@@ -128,7 +134,7 @@ type ProtocolManager struct {
 
 // NewProtocolManager returns a new ethereum sub protocol manager. The Ethereum sub protocol manages peers capable
 // with the ethereum network.
-func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, networkId int, mux *event.TypeMux, pow pow.PoW, blockchain BlockChain, txpool txPool, chainDb ethdb.Database, odr *LesOdr, txrelay *LesTxRelay) (*ProtocolManager, error) {
+func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, networkId int, mux *event.TypeMux, engine consensus.Engine, blockchain BlockChain, txpool txPool, chainDb ethdb.Database, odr *LesOdr, txrelay *LesTxRelay) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		lightSync:   lightSync,
@@ -151,7 +157,7 @@ func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, network
 		// Compatible, initialize the sub-protocol
 		version := version // Closure for the run
 		manager.SubProtocols = append(manager.SubProtocols, p2p.Protocol{
-			Name:    "les",
+			Name:    lesProtocolName,
 			Version: version,
 			Length:  ProtocolLengths[i],
 			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
@@ -317,6 +323,12 @@ func (pm *ProtocolManager) newPeer(pv, nv int, p *p2p.Peer, rw p2p.MsgReadWriter
 // handle is the callback invoked to manage the life cycle of a les peer. When
 // this function terminates, the peer is disconnected.
 func (pm *ProtocolManager) handle(p *peer) error {
+	if pm.server != nil {
+		if err := pm.server.slots.Acquire(lesProtocolName, p.Peer); err != nil {
+			return err
+		}
+		defer pm.server.slots.Release(lesProtocolName, p.Peer)
+	}
 	p.Log().Debug("Light Ethereum peer connected", "name", p.Name())
 
 	// Execute the LES handshake
@@ -324,6 +336,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	headNum := core.GetBlockNumber(pm.chainDb, head)
 	if err := p.Handshake(td, head, headNum, genesis, pm.server); err != nil {
 		p.Log().Debug("Light Ethereum handshake failed", "err", err)
+		if _, ok := err.(incompatibleError); ok {
+			return p2p.DiscUselessPeer
+		}
 		return err
 	}
 	if rw, ok := p.rw.(*meteredMsgReadWriter); ok {
@@ -432,7 +447,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 }
 
-var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsMsg, SendTxMsg, GetHeaderProofsMsg}
+// reqList contains the request messages of all protocol versions, see
+// requestList for the requests of a specific version.
+var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsMsg, SendTxMsg, GetHeaderProofsMsg, GetTxStatusMsg, GetBloomBitsMsg}
 
 // handleMsg is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
@@ -467,6 +484,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	}
 	defer msg.Discard()
 
+	if !msgAvailable(msg.Code, p.version) {
+		return errResp(ErrInvalidMsgCode, "%v not available in les/%d", msg.Code, p.version)
+	}
 	var deliverMsg *Msg
 
 	// Handle the message depending on its contents
@@ -920,6 +940,108 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		_, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 
+	case GetTxStatusMsg:
+		if pm.txpool == nil {
+			return errResp(ErrRequestRejected, "")
+		}
+		p.Log().Trace("Received transaction status request")
+		// Decode the retrieval message
+		var req struct {
+			ReqID  uint64
+			Hashes []common.Hash
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		reqCnt := len(req.Hashes)
+		if reject(uint64(reqCnt), MaxTxStatus) {
+			return errResp(ErrRequestRejected, "")
+		}
+		stats := pm.txStatus(req.Hashes)
+
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+		return p.SendTxStatus(req.ReqID, bv, stats)
+
+	case TxStatusMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+		p.Log().Trace("Received transaction status response")
+		var resp struct {
+			ReqID, BV uint64
+			Status    []light.TxStatus
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgTxStatus,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Status,
+		}
+
+	case GetBloomBitsMsg:
+		p.Log().Trace("Received bloom bits request")
+		// Decode the retrieval message
+		var req struct {
+			ReqID uint64
+			Reqs  []BloomReq
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather bloom bit proofs until the fetch or network limits is reached
+		var (
+			bytes  int
+			proofs [][]rlp.RawValue
+		)
+		reqCnt := len(req.Reqs)
+		if reject(uint64(reqCnt), MaxBloomBitsFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		for _, req := range req.Reqs {
+			if bytes >= softResponseLimit {
+				break
+			}
+			if req.SectionIdx >= req.BloomTrieNum {
+				continue
+			}
+			if root := getBloomTrieRoot(pm.chainDb, req.BloomTrieNum); root != (common.Hash{}) {
+				if tr, _ := trie.New(root, pm.chainDb); tr != nil {
+					proof := tr.Prove(light.BloomTrieKey(uint(req.BitIdx), req.SectionIdx))
+					proofs = append(proofs, proof)
+					for _, node := range proof {
+						bytes += len(node)
+					}
+				}
+			}
+		}
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+		return p.SendBloomBits(req.ReqID, bv, proofs)
+
+	case BloomBitsMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+
+		p.Log().Trace("Received bloom bits response")
+		var resp struct {
+			ReqID, BV uint64
+			Data      [][]rlp.RawValue
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgBloomBits,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Data,
+		}
+
 	default:
 		p.Log().Trace("Received unknown message", "code", msg.Code)
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -937,6 +1059,22 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	return nil
 }
 
+// txStatus returns the status of the given transactions, looking up the ones
+// unknown to the pool in the canonical chain.
+func (pm *ProtocolManager) txStatus(hashes []common.Hash) []light.TxStatus {
+	stats := make([]light.TxStatus, len(hashes))
+	for i, status := range pm.txpool.Status(hashes) {
+		stats[i].Status = status
+		if status != core.TxStatusUnknown {
+			continue
+		}
+		if tx, blockHash, number, index := core.GetTransaction(pm.chainDb, hashes[i]); tx != nil {
+			stats[i] = light.TxStatus{Status: core.TxStatusIncluded, BlockHash: blockHash, BlockNumber: number, Index: index}
+		}
+	}
+	return stats
+}
+
 // NodeInfo retrieves some protocol metadata about the running host node.
 func (self *ProtocolManager) NodeInfo() *eth.EthNodeInfo {
 	return &eth.EthNodeInfo{
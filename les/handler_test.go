@@ -17,15 +17,21 @@
 package les
 
 import (
+	"math/big"
 	"math/rand"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/core"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/eth/downloader"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/light"
 	"github.com/expanse-org/go-expanse/p2p"
+	"github.com/expanse-org/go-expanse/params"
 	"github.com/expanse-org/go-expanse/rlp"
 	"github.com/expanse-org/go-expanse/trie"
 )
@@ -40,6 +46,7 @@ func expectResponse(r p2p.MsgReader, msgcode, reqID, bv uint64, data interface{}
 
 // Tests that block headers can be retrieved from a remote chain based on user queries.
 func TestGetBlockHeadersLes1(t *testing.T) { testGetBlockHeaders(t, 1) }
+func TestGetBlockHeadersLes2(t *testing.T) { testGetBlockHeaders(t, 2) }
 
 func testGetBlockHeaders(t *testing.T, protocol int) {
 	pm, _, _ := newTestProtocolManagerMust(t, false, downloader.MaxHashFetch+15, nil)
@@ -168,6 +175,7 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 
 // Tests that block contents can be retrieved from a remote chain based on their hashes.
 func TestGetBlockBodiesLes1(t *testing.T) { testGetBlockBodies(t, 1) }
+func TestGetBlockBodiesLes2(t *testing.T) { testGetBlockBodies(t, 2) }
 
 func testGetBlockBodies(t *testing.T, protocol int) {
 	pm, _, _ := newTestProtocolManagerMust(t, false, downloader.MaxBlockFetch+15, nil)
@@ -243,6 +251,7 @@ func testGetBlockBodies(t *testing.T, protocol int) {
 
 // Tests that the contract codes can be retrieved based on account addresses.
 func TestGetCodeLes1(t *testing.T) { testGetCode(t, 1) }
+func TestGetCodeLes2(t *testing.T) { testGetCode(t, 2) }
 
 func testGetCode(t *testing.T, protocol int) {
 	// Assemble the test environment
@@ -275,6 +284,7 @@ func testGetCode(t *testing.T, protocol int) {
 
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetReceiptLes1(t *testing.T) { testGetReceipt(t, 1) }
+func TestGetReceiptLes2(t *testing.T) { testGetReceipt(t, 2) }
 
 func testGetReceipt(t *testing.T, protocol int) {
 	// Assemble the test environment
@@ -336,3 +346,80 @@ func testGetProofs(t *testing.T, protocol int) {
 		t.Errorf("proofs mismatch: %v", err)
 	}
 }
+
+// Tests that the status of transactions can be retrieved based on hashes.
+func TestGetTxStatusLes2(t *testing.T) { testGetTxStatus(t, 2) }
+
+func testGetTxStatus(t *testing.T, protocol int) {
+	// Assemble the test environment
+	pm, _, _ := newTestProtocolManagerMust(t, false, 4, testChainGen)
+	bc := pm.blockchain.(*core.BlockChain)
+	pool := core.NewTxPool(params.TestChainConfig, new(event.TypeMux), bc.State, bc.GasLimit)
+	pm.txpool = pool
+	peer, _ := newTestPeer(t, "peer", protocol, pm, true)
+	defer peer.close()
+
+	// Add an executable transaction to the pool
+	statedb, _ := bc.State()
+	tx, _ := types.SignTx(types.NewTransaction(statedb.GetNonce(testBankAddress), acc1Addr, big.NewInt(10000), bigTxGas, big.NewInt(1), nil), types.HomesteadSigner{}, testBankKey)
+	if err := pool.Add(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	// Collect the hashes to request, and the statuses to expect
+	hashes := []common.Hash{tx.Hash(), {}}
+	stats := []light.TxStatus{{Status: core.TxStatusPending}, {Status: core.TxStatusUnknown}}
+
+	block := bc.GetBlockByNumber(2)
+	for i, tx := range block.Transactions() {
+		hashes = append(hashes, tx.Hash())
+		stats = append(stats, light.TxStatus{Status: core.TxStatusIncluded, BlockHash: block.Hash(), BlockNumber: block.NumberU64(), Index: uint64(i)})
+	}
+	// Send the status request and verify the response
+	cost := peer.GetRequestCost(GetTxStatusMsg, len(hashes))
+	sendRequest(peer.app, GetTxStatusMsg, 42, cost, hashes)
+	if err := expectResponse(peer.app, TxStatusMsg, 42, testBufLimit, stats); err != nil {
+		t.Errorf("transaction statuses mismatch: %v", err)
+	}
+}
+
+// Tests that messages introduced in later protocol versions are rejected from
+// peers negotiating an older version.
+func TestGetTxStatusLes1(t *testing.T) {
+	pm, _, _ := newTestProtocolManagerMust(t, false, 4, testChainGen)
+	bc := pm.blockchain.(*core.BlockChain)
+	pm.txpool = core.NewTxPool(params.TestChainConfig, new(event.TypeMux), bc.State, bc.GasLimit)
+	peer, errc := newTestPeer(t, "peer", 1, pm, true)
+	defer peer.close()
+
+	sendRequest(peer.app, GetTxStatusMsg, 42, 0, []common.Hash{{}})
+	select {
+	case err := <-errc:
+		if err == nil || !strings.HasPrefix(err.Error(), errCode(ErrInvalidMsgCode).String()) {
+			t.Errorf("error mismatch: have %v, want %v", err, errCode(ErrInvalidMsgCode))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("les/1 peer not dropped")
+	}
+}
+
+// Tests that the cost tables exchanged in the handshake only contain the
+// requests available in the negotiated protocol version.
+func TestRequestListVersions(t *testing.T) {
+	for _, version := range ProtocolVersions {
+		codes := requestList(int(version))
+		for _, code := range codes {
+			if !msgAvailable(code, int(version)) {
+				t.Errorf("les/%d: request %d not available", version, code)
+			}
+		}
+		if have, want := msgAvailable(GetTxStatusMsg, int(version)), version >= lpv2; have != want {
+			t.Errorf("les/%d: tx status availability mismatch: have %v, want %v", version, have, want)
+		}
+		if have, want := msgAvailable(GetBloomBitsMsg, int(version)), version >= lpv2; have != want {
+			t.Errorf("les/%d: bloom bits availability mismatch: have %v, want %v", version, have, want)
+		}
+	}
+	if len(requestList(lpv2)) != len(requestList(lpv1))+2 {
+		t.Errorf("request count mismatch: les/1 %d, les/2 %d", len(requestList(lpv1)), len(requestList(lpv2)))
+	}
+}
@@ -119,9 +119,10 @@ func testChainGen(i int, block *core.BlockGen) {
 	}
 }
 
-func testRCL() RequestCostList {
-	cl := make(RequestCostList, len(reqList))
-	for i, code := range reqList {
+func testRCL(version int) RequestCostList {
+	codes := requestList(version)
+	cl := make(RequestCostList, len(codes))
+	for i, code := range codes {
 		cl[i].MsgCode = code
 		cl[i].BaseCost = 0
 		cl[i].ReqCost = 0
@@ -135,7 +136,7 @@ func testRCL() RequestCostList {
 func newTestProtocolManager(lightSync bool, blocks int, generator func(int, *core.BlockGen)) (*ProtocolManager, ethdb.Database, *LesOdr, error) {
 	var (
 		evmux = new(event.TypeMux)
-		pow   = new(pow.FakePow)
+		pow   = core.NewPowEngine(new(pow.FakePow))
 		db, _ = ethdb.NewMemDatabase()
 		gspec = core.Genesis{
 			Config: params.TestChainConfig,
@@ -163,7 +164,7 @@ func newTestProtocolManager(lightSync bool, blocks int, generator func(int, *cor
 		return nil, nil, nil, err
 	}
 	if !lightSync {
-		srv := &LesServer{protocolManager: pm}
+		srv := &LesServer{protocolManager: pm, slots: p2p.NewPeerSlots(100)}
 		pm.server = srv
 
 		srv.defParams = &flowcontrol.ServerParams{
@@ -322,7 +323,7 @@ func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, headNu
 	expList = expList.add("txRelay", nil)
 	expList = expList.add("flowControl/BL", testBufLimit)
 	expList = expList.add("flowControl/MRR", uint64(1))
-	expList = expList.add("flowControl/MRC", testRCL())
+	expList = expList.add("flowControl/MRC", testRCL(p.version))
 
 	if err := p2p.ExpectMsg(p.app, StatusMsg, expList); err != nil {
 		t.Fatalf("status recv: %v", err)
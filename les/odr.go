@@ -85,6 +85,8 @@ const (
 	MsgReceipts
 	MsgProofs
 	MsgHeaderProofs
+	MsgTxStatus
+	MsgBloomBits
 )
 
 // Msg encodes a LES message that delivers reply data for a request
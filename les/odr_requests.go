@@ -43,6 +43,8 @@ var (
 	errReceiptHashMismatch = errors.New("receipt hash mismatch")
 	errDataHashMismatch    = errors.New("data hash mismatch")
 	errCHTHashMismatch     = errors.New("cht hash mismatch")
+	errTxStatusMismatch    = errors.New("tx status count mismatch")
+	errBloomBitsMismatch   = errors.New("bloom bits mismatch")
 )
 
 type LesOdrRequest interface {
@@ -64,6 +66,10 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*CodeRequest)(r)
 	case *light.ChtRequest:
 		return (*ChtRequest)(r)
+	case *light.TxStatusRequest:
+		return (*TxStatusRequest)(r)
+	case *light.BloomRequest:
+		return (*BloomRequest)(r)
 	default:
 		return nil
 	}
@@ -354,3 +360,112 @@ func (r *ChtRequest) Validate(db ethdb.Database, msg *Msg) error {
 
 	return nil
 }
+
+// TxStatusRequest is the ODR request type for transaction status
+type TxStatusRequest light.TxStatusRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *TxStatusRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetTxStatusMsg, len(r.Hashes))
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *TxStatusRequest) CanSend(peer *peer) bool {
+	return msgAvailable(GetTxStatusMsg, peer.version)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *TxStatusRequest) Request(reqID uint64, peer *peer) error {
+	return peer.RequestTxStatus(reqID, r.GetCost(peer), r.Hashes)
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *TxStatusRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating transaction status", "count", len(r.Hashes))
+
+	// Ensure we have a correct message with a status for every transaction
+	if msg.MsgType != MsgTxStatus {
+		return errInvalidMessageType
+	}
+	status := msg.Obj.([]light.TxStatus)
+	if len(status) != len(r.Hashes) {
+		return errTxStatusMismatch
+	}
+	r.Status = status
+	return nil
+}
+
+type BloomReq struct {
+	BloomTrieNum, BitIdx, SectionIdx uint64
+}
+
+// BloomRequest is the ODR request type for bloom bit vectors, see LesOdrRequest interface
+type BloomRequest light.BloomRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *BloomRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetBloomBitsMsg, len(r.SectionIdxList))
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *BloomRequest) CanSend(peer *peer) bool {
+	if !msgAvailable(GetBloomBitsMsg, peer.version) {
+		return false
+	}
+	peer.lock.RLock()
+	defer peer.lock.RUnlock()
+
+	if peer.headInfo.Number < light.BloomTrieConfirmations {
+		return false
+	}
+	return r.BloomTrieNum <= (peer.headInfo.Number-light.BloomTrieConfirmations)/light.BloomTrieFrequency
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *BloomRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting bloom bits", "bloomTrie", r.BloomTrieNum, "bit", r.BitIdx, "sections", r.SectionIdxList)
+	reqs := make([]*BloomReq, len(r.SectionIdxList))
+	for i, sectionIdx := range r.SectionIdxList {
+		reqs[i] = &BloomReq{
+			BloomTrieNum: r.BloomTrieNum,
+			BitIdx:       uint64(r.BitIdx),
+			SectionIdx:   sectionIdx,
+		}
+	}
+	return peer.RequestBloomBits(reqID, r.GetCost(peer), reqs)
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *BloomRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating bloom bits", "bloomTrie", r.BloomTrieNum, "bit", r.BitIdx, "sections", r.SectionIdxList)
+
+	// Ensure we have a correct message with a proof for every section
+	if msg.MsgType != MsgBloomBits {
+		return errInvalidMessageType
+	}
+	proofs := msg.Obj.([][]rlp.RawValue)
+	if len(proofs) != len(r.SectionIdxList) {
+		return errBloomBitsMismatch
+	}
+	// Verify the bit vectors against the bloom trie
+	bitSets := make([][]byte, len(proofs))
+	for i, proof := range proofs {
+		bits, err := trie.VerifyProof(r.BloomTrieRoot, light.BloomTrieKey(r.BitIdx, r.SectionIdxList[i]), proof)
+		if err != nil {
+			return err
+		}
+		if uint64(len(bits)) != light.BloomTrieFrequency/8 {
+			return errBloomBitsMismatch
+		}
+		bitSets[i] = bits
+	}
+	// Verifications passed, store and return
+	r.BitSets = bitSets
+	return nil
+}
@@ -30,15 +30,19 @@ import (
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/core/vm"
 	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
 	"github.com/expanse-org/go-expanse/light"
 	"github.com/expanse-org/go-expanse/params"
 	"github.com/expanse-org/go-expanse/rlp"
+	"github.com/expanse-org/go-expanse/rpc"
 )
 
 type odrTestFn func(ctx context.Context, db ethdb.Database, config *params.ChainConfig, bc *core.BlockChain, lc *light.LightChain, bhash common.Hash) []byte
 
 func TestOdrGetBlockLes1(t *testing.T) { testOdr(t, 1, 1, odrGetBlock) }
 
+func TestOdrGetBlockLes2(t *testing.T) { testOdr(t, 2, 1, odrGetBlock) }
+
 func odrGetBlock(ctx context.Context, db ethdb.Database, config *params.ChainConfig, bc *core.BlockChain, lc *light.LightChain, bhash common.Hash) []byte {
 	var block *types.Block
 	if bc != nil {
@@ -55,6 +59,8 @@ func odrGetBlock(ctx context.Context, db ethdb.Database, config *params.ChainCon
 
 func TestOdrGetReceiptsLes1(t *testing.T) { testOdr(t, 1, 1, odrGetReceipts) }
 
+func TestOdrGetReceiptsLes2(t *testing.T) { testOdr(t, 2, 1, odrGetReceipts) }
+
 func odrGetReceipts(ctx context.Context, db ethdb.Database, config *params.ChainConfig, bc *core.BlockChain, lc *light.LightChain, bhash common.Hash) []byte {
 	var receipts types.Receipts
 	if bc != nil {
@@ -71,6 +77,8 @@ func odrGetReceipts(ctx context.Context, db ethdb.Database, config *params.Chain
 
 func TestOdrAccountsLes1(t *testing.T) { testOdr(t, 1, 1, odrAccounts) }
 
+func TestOdrAccountsLes2(t *testing.T) { testOdr(t, 2, 1, odrAccounts) }
+
 func odrAccounts(ctx context.Context, db ethdb.Database, config *params.ChainConfig, bc *core.BlockChain, lc *light.LightChain, bhash common.Hash) []byte {
 	dummyAddr := common.HexToAddress("1234567812345678123456781234567812345678")
 	acc := []common.Address{testBankAddress, acc1Addr, acc2Addr, dummyAddr}
@@ -101,6 +109,8 @@ func odrAccounts(ctx context.Context, db ethdb.Database, config *params.ChainCon
 
 func TestOdrContractCallLes1(t *testing.T) { testOdr(t, 1, 2, odrContractCall) }
 
+func TestOdrContractCallLes2(t *testing.T) { testOdr(t, 2, 2, odrContractCall) }
+
 type callmsg struct {
 	types.Message
 }
@@ -123,7 +133,7 @@ func odrContractCall(ctx context.Context, db ethdb.Database, config *params.Chai
 
 				msg := callmsg{types.NewMessage(from.Address(), &testContractAddr, 0, new(big.Int), big.NewInt(100000), new(big.Int), data, false)}
 
-				context := core.NewEVMContext(msg, header, bc)
+				context := core.NewEVMContext(msg, header, bc, nil)
 				vmenv := vm.NewEVM(context, statedb, config, vm.Config{})
 
 				//vmenv := core.NewEnv(statedb, config, bc, msg, header, vm.Config{})
@@ -141,7 +151,7 @@ func odrContractCall(ctx context.Context, db ethdb.Database, config *params.Chai
 
 				msg := callmsg{types.NewMessage(from.Address(), &testContractAddr, 0, new(big.Int), big.NewInt(100000), new(big.Int), data, false)}
 
-				context := core.NewEVMContext(msg, header, lc)
+				context := core.NewEVMContext(msg, header, lc, nil)
 				vmenv := vm.NewEVM(context, vmstate, config, vm.Config{})
 
 				//vmenv := light.NewEnv(ctx, state, config, lc, msg, header, vm.Config{})
@@ -156,6 +166,52 @@ func odrContractCall(ctx context.Context, db ethdb.Database, config *params.Chai
 	return res
 }
 
+func TestOdrApiBackendLes1(t *testing.T) { testOdr(t, 1, 1, odrApiBackend) }
+
+func TestOdrApiBackendLes2(t *testing.T) { testOdr(t, 2, 1, odrApiBackend) }
+
+// odrApiBackend retrieves account balances and runs contract calls through the
+// RPC backend of the light client, the way eth_getBalance and eth_call do.
+func odrApiBackend(ctx context.Context, db ethdb.Database, config *params.ChainConfig, bc *core.BlockChain, lc *light.LightChain, bhash common.Hash) []byte {
+	if bc != nil {
+		return append(odrAccounts(ctx, db, config, bc, lc, bhash), odrContractCall(ctx, db, config, bc, lc, bhash)...)
+	}
+	backend := &LesApiBackend{eth: &LightEthereum{odr: lc.Odr().(*LesOdr), blockchain: lc, chainDb: db, chainConfig: config}}
+	number := rpc.BlockNumber(core.GetBlockNumber(db, bhash))
+
+	var res []byte
+	for _, addr := range []common.Address{testBankAddress, acc1Addr, acc2Addr, common.HexToAddress("1234567812345678123456781234567812345678")} {
+		st, _, err := backend.StateAndHeaderByNumber(ctx, number)
+		if err != nil {
+			continue
+		}
+		if bal, err := st.GetBalance(ctx, addr); err == nil {
+			rlp, _ := rlp.EncodeToBytes(bal)
+			res = append(res, rlp...)
+		}
+	}
+	data := common.Hex2Bytes("60CD26850000000000000000000000000000000000000000000000000000000000000000")
+	for i := 0; i < 3; i++ {
+		data[35] = byte(i)
+
+		st, header, err := backend.StateAndHeaderByNumber(ctx, number)
+		if err != nil {
+			continue
+		}
+		msg := callmsg{types.NewMessage(testBankAddress, &testContractAddr, 0, new(big.Int), big.NewInt(100000), new(big.Int), data, false)}
+		vmenv, vmError, err := backend.GetEVM(ctx, msg, st, header, vm.Config{})
+		if err != nil {
+			continue
+		}
+		gp := new(core.GasPool).AddGas(math.MaxBig256)
+		ret, _, _ := core.ApplyMessage(vmenv, msg, gp)
+		if vmError() == nil {
+			res = append(res, ret...)
+		}
+	}
+	return res
+}
+
 func testOdr(t *testing.T, protocol int, expFail uint64, fn odrTestFn) {
 	// Assemble the test environment
 	pm, db, odr := newTestProtocolManagerMust(t, false, 4, testChainGen)
@@ -208,3 +264,140 @@ func testOdr(t *testing.T, protocol int, expFail uint64, fn odrTestFn) {
 	// still expect all retrievals to pass, now data should be cached locally
 	test(5)
 }
+
+// Tests that light clients retrieve the status of transactions from servers
+// speaking les/2, but don't send the request to les/1 servers.
+func TestOdrTxStatusLes1(t *testing.T) { testOdrTxStatus(t, 1) }
+
+func TestOdrTxStatusLes2(t *testing.T) { testOdrTxStatus(t, 2) }
+
+func testOdrTxStatus(t *testing.T, protocol int) {
+	// Assemble the test environment
+	pm, db, _ := newTestProtocolManagerMust(t, false, 4, testChainGen)
+	bc := pm.blockchain.(*core.BlockChain)
+	pm.txpool = core.NewTxPool(params.TestChainConfig, new(event.TypeMux), bc.State, bc.GasLimit)
+	lpm, _, odr := newTestProtocolManagerMust(t, true, 0, nil)
+	_, err1, lpeer, err2 := newTestPeerPair("peer", protocol, pm, lpm)
+	pool := &testServerPool{}
+	lpm.reqDist = newRequestDistributor(pool.getAllPeers, lpm.quitSync)
+	odr.reqDist = lpm.reqDist
+	pool.setPeer(lpeer)
+	odr.serverPool = pool
+	select {
+	case <-time.After(time.Millisecond * 100):
+	case err := <-err1:
+		t.Fatalf("peer 1 handshake error: %v", err)
+	case err := <-err2:
+		t.Fatalf("peer 2 handshake error: %v", err)
+	}
+	// Request the status of a transaction included in the chain
+	block := bc.GetBlockByNumber(1)
+	tx := block.Transactions()[0]
+	if tx, _, _, _ := core.GetTransaction(db, tx.Hash()); tx == nil {
+		t.Fatalf("test transaction not indexed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	req := &light.TxStatusRequest{Hashes: []common.Hash{tx.Hash()}}
+	err := odr.Retrieve(ctx, req)
+	if protocol < lpv2 {
+		if err == nil {
+			t.Fatalf("tx status retrieved from les/%d server", protocol)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("failed to retrieve tx status: %v", err)
+	}
+	want := light.TxStatus{Status: core.TxStatusIncluded, BlockHash: block.Hash(), BlockNumber: block.NumberU64()}
+	if len(req.Status) != 1 || req.Status[0] != want {
+		t.Errorf("tx status mismatch: have %+v, want %+v", req.Status, want)
+	}
+}
+
+// Tests that light clients retrieve bloom bit vectors from servers speaking
+// les/2, verifying them against the trusted bloom trie.
+func TestOdrBloomBitsLes1(t *testing.T) { testOdrBloomBits(t, 1) }
+
+func TestOdrBloomBitsLes2(t *testing.T) { testOdrBloomBits(t, 2) }
+
+// bloomChainGen creates a contract emitting a log in the middle of the first
+// section, so that the bloom of the block has some bits set.
+func bloomChainGen(i int, block *core.BlockGen) {
+	if i == 100 {
+		code := []byte{0x60, 0x00, 0x60, 0x00, 0xa0} // PUSH1 0 PUSH1 0 LOG0
+		tx, _ := types.SignTx(types.NewContractCreation(block.TxNonce(testBankAddress), big.NewInt(0), big.NewInt(100000), big.NewInt(0), code), types.HomesteadSigner{}, testBankKey)
+		block.AddTx(tx)
+	}
+}
+
+func testOdrBloomBits(t *testing.T, protocol int) {
+	// Assemble the test environment with a server covering a full section
+	blocks := int(light.BloomTrieFrequency+light.BloomTrieConfirmations) + 1
+	pm, db, _ := newTestProtocolManagerMust(t, false, blocks, bloomChainGen)
+	if makeBloomTrie(db) {
+		t.Fatalf("more bloom trie sections reported")
+	}
+	root := getBloomTrieRoot(db, 1)
+	if root == (common.Hash{}) {
+		t.Fatalf("bloom trie not generated")
+	}
+	lpm, ldb, odr := newTestProtocolManagerMust(t, true, 0, nil)
+	light.WriteTrustedBloomTrie(ldb, light.TrustedBloomTrie{Number: 1, Root: root})
+
+	_, err1, lpeer, err2 := newTestPeerPair("peer", protocol, pm, lpm)
+	pool := &testServerPool{}
+	lpm.reqDist = newRequestDistributor(pool.getAllPeers, lpm.quitSync)
+	odr.reqDist = lpm.reqDist
+	pool.setPeer(lpeer)
+	odr.serverPool = pool
+	select {
+	case <-time.After(time.Millisecond * 100):
+	case err := <-err1:
+		t.Fatalf("peer 1 handshake error: %v", err)
+	case err := <-err2:
+		t.Fatalf("peer 2 handshake error: %v", err)
+	}
+	// Request bit 0 and the bits set in the bloom of the logging block
+	bloom := types.Bloom{}
+	for i := uint64(0); i < light.BloomTrieFrequency; i++ {
+		if header := pm.blockchain.GetHeaderByNumber(i); header.Bloom != (types.Bloom{}) {
+			bloom = header.Bloom
+			break
+		}
+	}
+	bits := []uint{0}
+	for i := uint(1); i < light.BloomBitsLength; i++ {
+		if bloom.Big().Bit(int(i)) == 1 {
+			bits = append(bits, i)
+		}
+	}
+	if len(bits) == 1 {
+		t.Fatalf("no logs in the test chain")
+	}
+	for _, bit := range bits {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		vectors, err := light.GetBloomBits(ctx, odr, bit, []uint64{0})
+		cancel()
+		if protocol < lpv2 {
+			if err == nil {
+				t.Fatalf("bloom bits retrieved from les/%d server", protocol)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("bit %d: failed to retrieve bloom bits: %v", bit, err)
+		}
+		for i := uint64(0); i < light.BloomTrieFrequency; i++ {
+			want := pm.blockchain.GetHeaderByNumber(i).Bloom.Big().Bit(int(bit)) == 1
+			if have := vectors[0][i/8]&(1<<(7-i%8)) != 0; have != want {
+				t.Fatalf("bit %d, block %d: have %v, want %v", bit, i, have, want)
+			}
+		}
+	}
+	// Requests beyond the trusted bloom trie must fail
+	if _, err := light.GetBloomBits(context.Background(), odr, 0, []uint64{1}); err != light.ErrNoTrustedBloomTrie {
+		t.Errorf("untrusted section error mismatch: have %v, want %v", err, light.ErrNoTrustedBloomTrie)
+	}
+}
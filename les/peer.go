@@ -28,6 +28,7 @@ import (
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/eth"
 	"github.com/expanse-org/go-expanse/les/flowcontrol"
+	"github.com/expanse-org/go-expanse/light"
 	"github.com/expanse-org/go-expanse/p2p"
 	"github.com/expanse-org/go-expanse/rlp"
 )
@@ -41,8 +42,14 @@ var (
 const (
 	maxHeadInfoLen    = 20
 	maxResponseErrors = 50 // number of invalid responses tolerated (makes the protocol less brittle but still avoids spam)
+	handshakeTimeout  = 5 * time.Second
 )
 
+// incompatibleError is a handshake failure caused by the remote peer running on
+// another chain, network or protocol version, or not offering the services we
+// need, making it useless to stay connected.
+type incompatibleError struct{ error }
+
 type peer struct {
 	*p2p.Peer
 
@@ -210,6 +217,16 @@ func (p *peer) SendHeaderProofs(reqID, bv uint64, proofs []ChtResp) error {
 	return sendResponse(p.rw, HeaderProofsMsg, reqID, bv, proofs)
 }
 
+// SendTxStatus sends a batch of transaction status records, corresponding to the ones requested.
+func (p *peer) SendTxStatus(reqID, bv uint64, stats []light.TxStatus) error {
+	return sendResponse(p.rw, TxStatusMsg, reqID, bv, stats)
+}
+
+// SendBloomBits sends a batch of bloom trie proofs of bit vectors, corresponding to the ones requested.
+func (p *peer) SendBloomBits(reqID, bv uint64, proofs [][]rlp.RawValue) error {
+	return sendResponse(p.rw, BloomBitsMsg, reqID, bv, proofs)
+}
+
 // RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
 func (p *peer) RequestHeadersByHash(reqID, cost uint64, origin common.Hash, amount int, skip int, reverse bool) error {
@@ -256,6 +273,19 @@ func (p *peer) RequestHeaderProofs(reqID, cost uint64, reqs []*ChtReq) error {
 	return sendRequest(p.rw, GetHeaderProofsMsg, reqID, cost, reqs)
 }
 
+// RequestTxStatus fetches a batch of transaction status records from a remote node.
+func (p *peer) RequestTxStatus(reqID, cost uint64, hashes []common.Hash) error {
+	p.Log().Debug("Requesting transaction status", "count", len(hashes))
+	return sendRequest(p.rw, GetTxStatusMsg, reqID, cost, hashes)
+}
+
+// RequestBloomBits fetches a batch of bloom bit vectors with their bloom trie
+// proofs from a remote node.
+func (p *peer) RequestBloomBits(reqID, cost uint64, reqs []*BloomReq) error {
+	p.Log().Debug("Fetching batch of bloom bits", "count", len(reqs))
+	return sendRequest(p.rw, GetBloomBitsMsg, reqID, cost, reqs)
+}
+
 func (p *peer) SendTxs(reqID, cost uint64, txs types.Transactions) error {
 	p.Log().Debug("Fetching batch of transactions", "count", len(txs))
 	return p2p.Send(p.rw, SendTxMsg, txs)
@@ -301,12 +331,36 @@ func (m keyValueMap) get(key string, val interface{}) error {
 }
 
 func (p *peer) sendReceiveHandshake(sendList keyValueList) (keyValueList, error) {
+	errc := make(chan error, 2)
+	var recvList keyValueList // safe to read after two values have been received from errc
+
 	// Send out own handshake in a new thread
-	errc := make(chan error, 1)
 	go func() {
 		errc <- p2p.Send(p.rw, StatusMsg, sendList)
 	}()
 	// In the mean time retrieve the remote status message
+	go func() {
+		var err error
+		recvList, err = p.readHandshake()
+		errc <- err
+	}()
+	timeout := time.NewTimer(handshakeTimeout)
+	defer timeout.Stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return nil, err
+			}
+		case <-timeout.C:
+			return nil, p2p.DiscReadTimeout
+		}
+	}
+	return recvList, nil
+}
+
+// readHandshake reads and decodes the status message of the remote peer.
+func (p *peer) readHandshake() (keyValueList, error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return nil, err
@@ -322,9 +376,6 @@ func (p *peer) sendReceiveHandshake(sendList keyValueList) (keyValueList, error)
 	if err := msg.Decode(&recvList); err != nil {
 		return nil, errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
-	if err := <-errc; err != nil {
-		return nil, err
-	}
 	return recvList, nil
 }
 
@@ -348,7 +399,7 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		send = send.add("txRelay", nil)
 		send = send.add("flowControl/BL", server.defParams.BufLimit)
 		send = send.add("flowControl/MRR", server.defParams.MinRecharge)
-		list := server.fcCostStats.getCurrentList()
+		list := server.fcCostStats.getCurrentList(p.version)
 		send = send.add("flowControl/MRC", list)
 		p.fcCosts = list.decode()
 	}
@@ -382,28 +433,28 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 	}
 
 	if rGenesis != genesis {
-		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", rGenesis[:8], genesis[:8])
+		return incompatibleError{errResp(ErrGenesisBlockMismatch, "%x (!= %x)", rGenesis[:8], genesis[:8])}
 	}
 	if int(rNetwork) != p.network {
-		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", rNetwork, p.network)
+		return incompatibleError{errResp(ErrNetworkIdMismatch, "%d (!= %d)", rNetwork, p.network)}
 	}
 	if int(rVersion) != p.version {
-		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", rVersion, p.version)
+		return incompatibleError{errResp(ErrProtocolVersionMismatch, "%d (!= %d)", rVersion, p.version)}
 	}
 	if server != nil {
 		if recv.get("serveStateSince", nil) == nil {
-			return errResp(ErrUselessPeer, "wanted client, got server")
+			return incompatibleError{errResp(ErrUselessPeer, "wanted client, got server")}
 		}
 		p.fcClient = flowcontrol.NewClientNode(server.fcManager, server.defParams)
 	} else {
 		if recv.get("serveChainSince", nil) != nil {
-			return errResp(ErrUselessPeer, "peer cannot serve chain")
+			return incompatibleError{errResp(ErrUselessPeer, "peer cannot serve chain")}
 		}
 		if recv.get("serveStateSince", nil) != nil {
-			return errResp(ErrUselessPeer, "peer cannot serve state")
+			return incompatibleError{errResp(ErrUselessPeer, "peer cannot serve state")}
 		}
 		if recv.get("txRelay", nil) != nil {
-			return errResp(ErrUselessPeer, "peer cannot relay transactions")
+			return incompatibleError{errResp(ErrUselessPeer, "peer cannot relay transactions")}
 		}
 		params := &flowcontrol.ServerParams{}
 		if err := recv.get("flowControl/BL", &params.BufLimit); err != nil {
@@ -416,9 +467,15 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		if err := recv.get("flowControl/MRC", &MRC); err != nil {
 			return err
 		}
+		costs := MRC.decode()
+		for _, code := range requestList(p.version) {
+			if costs[code] == nil {
+				return incompatibleError{errResp(ErrUselessPeer, "missing cost of request %d", code)}
+			}
+		}
 		p.fcServerParams = params
 		p.fcServer = flowcontrol.NewServerNode(params)
-		p.fcCosts = MRC.decode()
+		p.fcCosts = costs
 	}
 
 	p.headInfo = &announceData{Td: rTd, Hash: rHash, Number: rNum}
@@ -30,13 +30,17 @@ import (
 // Constants to match up protocol versions and messages
 const (
 	lpv1 = 1
+	lpv2 = 2
 )
 
+// lesProtocolName is the official short name of the protocol used during capability negotiation.
+const lesProtocolName = "les"
+
 // Supported versions of the les protocol (first is primary).
-var ProtocolVersions = []uint{lpv1}
+var ProtocolVersions = []uint{lpv2, lpv1}
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{15}
+var ProtocolLengths = []uint64{19, 15}
 
 const (
 	NetworkId          = 1
@@ -61,8 +65,40 @@ const (
 	SendTxMsg          = 0x0c
 	GetHeaderProofsMsg = 0x0d
 	HeaderProofsMsg    = 0x0e
+	// Protocol messages belonging to LPV2
+	GetTxStatusMsg  = 0x0f
+	TxStatusMsg     = 0x10
+	GetBloomBitsMsg = 0x11
+	BloomBitsMsg    = 0x12
 )
 
+// msgVersions maps the messages introduced after LPV1 to the protocol version
+// introducing them. Messages missing from the map belong to every version.
+var msgVersions = map[uint64]int{
+	GetTxStatusMsg:  lpv2,
+	TxStatusMsg:     lpv2,
+	GetBloomBitsMsg: lpv2,
+	BloomBitsMsg:    lpv2,
+}
+
+// msgAvailable reports whether the given message is part of the given version
+// of the protocol.
+func msgAvailable(code uint64, version int) bool {
+	return msgVersions[code] <= version
+}
+
+// requestList returns the request messages available in the given version of
+// the protocol, in the order their costs are listed in the handshake.
+func requestList(version int) []uint64 {
+	list := make([]uint64, 0, len(reqList))
+	for _, code := range reqList {
+		if msgAvailable(code, version) {
+			list = append(list, code)
+		}
+	}
+	return list
+}
+
 type errCode int
 
 const (
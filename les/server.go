@@ -41,19 +41,27 @@ type LesServer struct {
 	fcManager       *flowcontrol.ClientManager // nil if our node is client only
 	fcCostStats     *requestCostStats
 	defParams       *flowcontrol.ServerParams
+	slots           *p2p.PeerSlots // Peer slots shared with the eth protocol manager
 	stopped         bool
 }
 
 func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
-	pm, err := NewProtocolManager(eth.BlockChain().Config(), false, config.NetworkId, eth.EventMux(), eth.Pow(), eth.BlockChain(), eth.TxPool(), eth.ChainDb(), nil, nil)
+	pm, err := NewProtocolManager(eth.BlockChain().Config(), false, config.NetworkId, eth.EventMux(), eth.Engine(), eth.BlockChain(), eth.TxPool(), eth.ChainDb(), nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	pm.blockLoop()
+	// Keep the CHTs and bloom tries if the chain prunes its historical states
+	eth.BlockChain().AddPruneRoots(func() []common.Hash { return trieRoots(pm.chainDb) })
+	pm.blockLoop(eth.BlockChain().PruneGuard())
 
-	srv := &LesServer{protocolManager: pm}
+	srv := &LesServer{protocolManager: pm, slots: eth.PeerSlots()}
 	pm.server = srv
 
+	// Reserve slots for the light clients, leaving the rest to the eth peers
+	if reserved := srv.slots.Reserve(lesProtocolName, config.LightPeers); reserved < config.LightPeers {
+		log.Info("Limited LES client slots", "requested", config.LightPeers, "reserved", reserved)
+	}
+
 	srv.defParams = &flowcontrol.ServerParams{
 		BufLimit:    300000000,
 		MinRecharge: 50000,
@@ -103,9 +111,10 @@ func (list RequestCostList) decode() requestCostTable {
 	return table
 }
 
-func (table requestCostTable) encode() RequestCostList {
-	list := make(RequestCostList, len(table))
-	for idx, code := range reqList {
+func (table requestCostTable) encode(version int) RequestCostList {
+	codes := requestList(version)
+	list := make(RequestCostList, len(codes))
+	for idx, code := range codes {
 		list[idx].MsgCode = code
 		list[idx].BaseCost = table[code].baseCost
 		list[idx].ReqCost = table[code].reqCost
@@ -230,13 +239,16 @@ func (s *requestCostStats) store() {
 	}
 }
 
-func (s *requestCostStats) getCurrentList() RequestCostList {
+// getCurrentList returns the current costs of the requests available in the
+// given protocol version.
+func (s *requestCostStats) getCurrentList(version int) RequestCostList {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	list := make(RequestCostList, len(reqList))
+	codes := requestList(version)
+	list := make(RequestCostList, len(codes))
 	//fmt.Println("RequestCostList")
-	for idx, code := range reqList {
+	for idx, code := range codes {
 		b, m := s.stats[code].calc()
 		//fmt.Println(code, s.stats[code].cnt, b/1000000, m/1000000)
 		if m < 0 {
@@ -265,7 +277,10 @@ func (s *requestCostStats) update(msgCode, reqCnt, cost uint64) {
 	c.add(float64(reqCnt), float64(cost))
 }
 
-func (pm *ProtocolManager) blockLoop() {
+// blockLoop announces the new chain heads to the clients and generates the CHTs
+// and bloom tries, holding the guard while writing them to stop state pruning
+// from deleting their nodes before their roots are stored.
+func (pm *ProtocolManager) blockLoop(guard sync.Locker) {
 	pm.wg.Add(1)
 	sub := pm.eventMux.Subscribe(core.ChainHeadEvent{})
 	newCht := make(chan struct{}, 10)
@@ -307,7 +322,10 @@ func (pm *ProtocolManager) blockLoop() {
 			case <-newCht:
 				go func() {
 					mu.Lock()
+					guard.Lock()
 					more := makeCht(pm.chainDb)
+					more = makeBloomTrie(pm.chainDb) || more
+					guard.Unlock()
 					mu.Unlock()
 					if more {
 						time.Sleep(time.Millisecond * 10)
@@ -323,6 +341,24 @@ func (pm *ProtocolManager) blockLoop() {
 	}()
 }
 
+// trieRoots retrieves the roots of all the CHTs and bloom tries generated so far.
+func trieRoots(db ethdb.Database) []common.Hash {
+	var roots []common.Hash
+	for _, last := range []struct {
+		key  []byte
+		root func(ethdb.Database, uint64) common.Hash
+	}{{lastChtKey, getChtRoot}, {lastBloomTrieKey, getBloomTrieRoot}} {
+		data, _ := db.Get(last.key)
+		if len(data) != 8 {
+			continue
+		}
+		for num := uint64(1); num <= binary.BigEndian.Uint64(data); num++ {
+			roots = append(roots, last.root(db, num))
+		}
+	}
+	return roots
+}
+
 var (
 	lastChtKey = []byte("LastChtNumber") // chtNum (uint64 big endian)
 	chtPrefix  = []byte("cht")           // chtPrefix + chtNum (uint64 big endian) -> trie root hash
@@ -405,3 +441,87 @@ func makeCht(db ethdb.Database) bool {
 
 	return newChtNum > lastChtNum
 }
+
+var (
+	lastBloomTrieKey = []byte("LastBloomTrieNumber") // bloomTrieNum (uint64 big endian)
+	bloomTriePrefix  = []byte("blt")                 // bloomTriePrefix + bloomTrieNum (uint64 big endian) -> trie root hash
+)
+
+func getBloomTrieRoot(db ethdb.Database, num uint64) common.Hash {
+	var encNumber [8]byte
+	binary.BigEndian.PutUint64(encNumber[:], num)
+	data, _ := db.Get(append(bloomTriePrefix, encNumber[:]...))
+	return common.BytesToHash(data)
+}
+
+func storeBloomTrieRoot(db ethdb.Database, num uint64, root common.Hash) {
+	var encNumber [8]byte
+	binary.BigEndian.PutUint64(encNumber[:], num)
+	db.Put(append(bloomTriePrefix, encNumber[:]...), root[:])
+}
+
+// makeBloomTrie adds the bit vectors of the next confirmed section of headers
+// to the bloom trie, returning whether there are further sections to add. The
+// bloom trie numbered N covers the first N sections.
+func makeBloomTrie(db ethdb.Database) bool {
+	headHash := core.GetHeadBlockHash(db)
+	headNum := core.GetBlockNumber(db, headHash)
+
+	var newBloomTrieNum uint64
+	if headNum > light.BloomTrieConfirmations {
+		newBloomTrieNum = (headNum - light.BloomTrieConfirmations) / light.BloomTrieFrequency
+	}
+
+	var lastBloomTrieNum uint64
+	data, _ := db.Get(lastBloomTrieKey)
+	if len(data) == 8 {
+		lastBloomTrieNum = binary.BigEndian.Uint64(data[:])
+	}
+	if newBloomTrieNum <= lastBloomTrieNum {
+		return false
+	}
+
+	var t *trie.Trie
+	if lastBloomTrieNum > 0 {
+		var err error
+		t, err = trie.New(getBloomTrieRoot(db, lastBloomTrieNum), db)
+		if err != nil {
+			lastBloomTrieNum = 0
+		}
+	}
+	if lastBloomTrieNum == 0 {
+		t, _ = trie.New(common.Hash{}, db)
+	}
+
+	blooms := make([]types.Bloom, 0, light.BloomTrieFrequency)
+	for num := lastBloomTrieNum * light.BloomTrieFrequency; num < (lastBloomTrieNum+1)*light.BloomTrieFrequency; num++ {
+		hash := core.GetCanonicalHash(db, num)
+		if hash == (common.Hash{}) {
+			panic("Canonical hash not found")
+		}
+		header := core.GetHeader(db, hash, num)
+		if header == nil {
+			panic("Header not found")
+		}
+		blooms = append(blooms, header.Bloom)
+	}
+	for bitIdx, bits := range light.BloomBitVectors(blooms) {
+		t.Update(light.BloomTrieKey(uint(bitIdx), lastBloomTrieNum), bits)
+	}
+
+	root, err := t.Commit()
+	if err != nil {
+		lastBloomTrieNum = 0
+	} else {
+		lastBloomTrieNum++
+
+		log.Trace("Generated bloom trie", "number", lastBloomTrieNum, "root", root.Hex())
+
+		storeBloomTrieRoot(db, lastBloomTrieNum, root)
+		var data [8]byte
+		binary.BigEndian.PutUint64(data[:], lastBloomTrieNum)
+		db.Put(lastBloomTrieKey, data[:])
+	}
+
+	return newBloomTrieNum > lastBloomTrieNum
+}
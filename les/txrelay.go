@@ -17,12 +17,21 @@
 package les
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core"
 	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/light"
+	"github.com/expanse-org/go-expanse/log"
 )
 
+// txStatusTimeout is the time allowed for a server to report the status of the
+// pending transactions.
+const txStatusTimeout = 10 * time.Second
+
 type ltrInfo struct {
 	tx     *types.Transaction
 	sentTo map[*peer]struct{}
@@ -34,9 +43,11 @@ type LesTxRelay struct {
 	ps           *peerSet
 	peerList     []*peer
 	peerStartPos int
+	checking     bool // Whether a status check of the pending transactions is running
 	lock         sync.RWMutex
 
 	reqDist *requestDistributor
+	odr     *LesOdr
 }
 
 func NewLesTxRelay() *LesTxRelay {
@@ -159,6 +170,49 @@ func (self *LesTxRelay) NewHead(head common.Hash, mined []common.Hash, rollback
 			i++
 		}
 		self.send(txs, 1)
+
+		if self.odr != nil && !self.checking {
+			self.checking = true
+			go self.checkDropped(txs)
+		}
+	}
+}
+
+// checkDropped asks a server for the status of the given pending transactions,
+// and resends the ones it doesn't know about to any servers again, as they were
+// dropped, e.g. evicted from the pools of the servers they were relayed to.
+func (self *LesTxRelay) checkDropped(txs types.Transactions) {
+	if len(txs) > MaxTxStatus {
+		txs = txs[:MaxTxStatus]
+	}
+	req := &light.TxStatusRequest{Hashes: make([]common.Hash, len(txs))}
+	for i, tx := range txs {
+		req.Hashes[i] = tx.Hash()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), txStatusTimeout)
+	err := self.odr.Retrieve(ctx, req)
+	cancel()
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.checking = false
+	if err != nil {
+		log.Debug("Failed to retrieve transaction status", "err", err)
+		return
+	}
+	var dropped types.Transactions
+	for i, hash := range req.Hashes {
+		if _, ok := self.txPending[hash]; !ok || req.Status[i].Status != core.TxStatusUnknown {
+			continue
+		}
+		ltr := self.txSent[hash]
+		ltr.sentTo = make(map[*peer]struct{})
+		dropped = append(dropped, ltr.tx)
+	}
+	if len(dropped) > 0 {
+		log.Debug("Resending dropped transactions", "count", len(dropped))
+		self.send(dropped, 3)
 	}
 }
 
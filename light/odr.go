@@ -138,6 +138,25 @@ func (req *ReceiptsRequest) StoreResult(db ethdb.Database) {
 	core.WriteBlockReceipts(db, req.Hash, req.Number, req.Receipts)
 }
 
+// TxStatus describes the status of a transaction as reported by a server, along
+// with its position in the chain if it was included.
+type TxStatus struct {
+	Status      core.TxStatus
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Index       uint64
+}
+
+// TxStatusRequest is the ODR request type for retrieving the status of transactions
+type TxStatusRequest struct {
+	OdrRequest
+	Hashes []common.Hash
+	Status []TxStatus
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *TxStatusRequest) StoreResult(db ethdb.Database) {}
+
 // TrieRequest is the ODR request type for state/storage trie entries
 type ChtRequest struct {
 	OdrRequest
@@ -157,3 +176,21 @@ func (req *ChtRequest) StoreResult(db ethdb.Database) {
 	core.WriteCanonicalHash(db, hash, num)
 	//storeProof(db, req.Proof)
 }
+
+// BloomRequest is the ODR request type for retrieving the bit vectors of a bloom
+// bit for a list of sections, verified against the bloom trie
+type BloomRequest struct {
+	OdrRequest
+	BloomTrieNum   uint64
+	BloomTrieRoot  common.Hash
+	BitIdx         uint
+	SectionIdxList []uint64
+	BitSets        [][]byte
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *BloomRequest) StoreResult(db ethdb.Database) {
+	for i, sectionIdx := range req.SectionIdxList {
+		storeBloomBits(db, req.BitIdx, sectionIdx, req.BitSets[i])
+	}
+}
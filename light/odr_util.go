@@ -19,6 +19,7 @@ package light
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"math/big"
 
@@ -33,14 +34,24 @@ import (
 var sha3_nil = crypto.Keccak256Hash(nil)
 
 var (
-	ErrNoTrustedCht = errors.New("No trusted canonical hash trie")
-	ErrNoHeader     = errors.New("Header not found")
+	ErrNoTrustedCht       = errors.New("No trusted canonical hash trie")
+	ErrNoTrustedBloomTrie = errors.New("No trusted bloom trie")
+	ErrNoHeader           = errors.New("Header not found")
 
 	ChtFrequency     = uint64(4096)
 	ChtConfirmations = uint64(2048)
 	trustedChtKey    = []byte("TrustedCHT")
+
+	BloomTrieFrequency     = uint64(4096) // Number of blocks in a bloom bits section
+	BloomTrieConfirmations = uint64(2048) // Number of confirmations before a section is added
+	trustedBloomTrieKey    = []byte("TrustedBloomTrie")
+	bloomBitsPrefix        = []byte("blb") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) -> bit vector
 )
 
+// BloomBitsLength is the number of bits in a header bloom, each of them having
+// a bit vector per section in the bloom trie.
+const BloomBitsLength = 2048
+
 type ChtNode struct {
 	Hash common.Hash
 	Td   *big.Int
@@ -69,6 +80,104 @@ func DeleteTrustedCht(db ethdb.Database) {
 	db.Delete(trustedChtKey)
 }
 
+// TrustedBloomTrie is the root of the bloom trie covering the first Number
+// sections of the chain, trusted by the light client.
+type TrustedBloomTrie struct {
+	Number uint64
+	Root   common.Hash
+}
+
+func GetTrustedBloomTrie(db ethdb.Database) TrustedBloomTrie {
+	data, _ := db.Get(trustedBloomTrieKey)
+	var res TrustedBloomTrie
+	if err := rlp.DecodeBytes(data, &res); err != nil {
+		return TrustedBloomTrie{0, common.Hash{}}
+	}
+	return res
+}
+
+func WriteTrustedBloomTrie(db ethdb.Database, bt TrustedBloomTrie) {
+	data, _ := rlp.EncodeToBytes(bt)
+	db.Put(trustedBloomTrieKey, data)
+}
+
+func DeleteTrustedBloomTrie(db ethdb.Database) {
+	db.Delete(trustedBloomTrieKey)
+}
+
+// BloomTrieKey returns the key of the bit vector belonging to the given bloom
+// bit and section in the bloom trie.
+func BloomTrieKey(bitIdx uint, sectionIdx uint64) []byte {
+	var key [10]byte
+	binary.BigEndian.PutUint16(key[0:2], uint16(bitIdx))
+	binary.BigEndian.PutUint64(key[2:10], sectionIdx)
+	return key[:]
+}
+
+// BloomBitVectors rotates the blooms of a section of headers into bit vectors,
+// one for every bit of the bloom. Bit i of the bloom is bit i of the bloom as a
+// big endian number, and bit j of its vector (counting from the most significant
+// bit of the first byte) is set if it is set in the j-th bloom of the section.
+func BloomBitVectors(blooms []types.Bloom) [][]byte {
+	vectors := make([][]byte, BloomBitsLength)
+	for i := range vectors {
+		vectors[i] = make([]byte, (len(blooms)+7)/8)
+	}
+	for j, bloom := range blooms {
+		for i := 0; i < BloomBitsLength; i++ {
+			if bloom[len(bloom)-1-i/8]&(1<<uint(i%8)) != 0 {
+				vectors[i][j/8] |= 1 << uint(7-j%8)
+			}
+		}
+	}
+	return vectors
+}
+
+func getBloomBits(db ethdb.Database, bitIdx uint, sectionIdx uint64) []byte {
+	data, _ := db.Get(append(bloomBitsPrefix, BloomTrieKey(bitIdx, sectionIdx)...))
+	return data
+}
+
+func storeBloomBits(db ethdb.Database, bitIdx uint, sectionIdx uint64, bits []byte) {
+	db.Put(append(bloomBitsPrefix, BloomTrieKey(bitIdx, sectionIdx)...), bits)
+}
+
+// GetBloomBits retrieves the bit vectors of the given bloom bit for a list of
+// sections, from the local database if available or from the network, verified
+// against the trusted bloom trie.
+func GetBloomBits(ctx context.Context, odr OdrBackend, bitIdx uint, sectionIdxList []uint64) ([][]byte, error) {
+	db := odr.Database()
+	result := make([][]byte, len(sectionIdxList))
+
+	var (
+		missing []uint64
+		indices []int
+	)
+	bt := GetTrustedBloomTrie(db)
+	for i, sectionIdx := range sectionIdxList {
+		if bits := getBloomBits(db, bitIdx, sectionIdx); bits != nil {
+			result[i] = bits
+			continue
+		}
+		if sectionIdx >= bt.Number {
+			return nil, ErrNoTrustedBloomTrie
+		}
+		missing = append(missing, sectionIdx)
+		indices = append(indices, i)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+	r := &BloomRequest{BloomTrieRoot: bt.Root, BloomTrieNum: bt.Number, BitIdx: bitIdx, SectionIdxList: missing}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	for i, bits := range r.BitSets {
+		result[indices[i]] = bits
+	}
+	return result, nil
+}
+
 func GetHeaderByNumber(ctx context.Context, odr OdrBackend, number uint64) (*types.Header, error) {
 	db := odr.Database()
 	hash := core.GetCanonicalHash(db, number)
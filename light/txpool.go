@@ -345,6 +345,10 @@ func (pool *TxPool) Stats() (pending int) {
 
 // validateTx checks whether a transaction is valid according to the consensus rules.
 func (pool *TxPool) validateTx(ctx context.Context, tx *types.Transaction) error {
+	// Reject transactions over the size limit to prevent DOS attacks
+	if uint64(tx.Size()) > pool.config.MaxTxSize() {
+		return core.ErrOversizedData
+	}
 	// Validate sender
 	var (
 		from common.Address
@@ -384,15 +388,15 @@ func (pool *TxPool) validateTx(ctx context.Context, tx *types.Transaction) error
 	// cost == V + GP * GL
 	if b, err := currentState.GetBalance(ctx, from); err == nil {
 		if b.Cmp(tx.Cost()) < 0 {
-			return core.ErrInsufficientFunds
+			return &core.InsufficientFundsErr{Address: from, Have: b, Want: tx.Cost()}
 		}
 	} else {
 		return err
 	}
 
 	// Should supply enough intrinsic gas
-	if tx.Gas().Cmp(core.IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead)) < 0 {
-		return core.ErrIntrinsicGas
+	if intrGas := core.IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead); tx.Gas().Cmp(intrGas) < 0 {
+		return &core.IntrinsicGasErr{Have: tx.Gas(), Want: intrGas}
 	}
 
 	return nil
@@ -47,31 +47,81 @@ func init() {
 	exp.Exp(metrics.DefaultRegistry)
 }
 
+// NewRegistry creates a metrics registry scoped to a single instance (e.g. a node
+// or a service). All metrics are stored in the default registry, but under the
+// given prefix, allowing multiple instances to run within the same process. An
+// empty prefix returns the default registry itself.
+func NewRegistry(prefix string) metrics.Registry {
+	if prefix == "" {
+		return metrics.DefaultRegistry
+	}
+	return metrics.NewPrefixedChildRegistry(metrics.DefaultRegistry, prefix)
+}
+
 // NewCounter create a new metrics Counter, either a real one of a NOP stub depending
 // on the metrics flag.
 func NewCounter(name string) metrics.Counter {
+	return NewRegisteredCounter(name, nil)
+}
+
+// NewRegisteredCounter creates a new metrics Counter within the given registry
+// (or the default one if nil), or a NOP stub if metrics are disabled.
+func NewRegisteredCounter(name string, registry metrics.Registry) metrics.Counter {
 	if !Enabled {
 		return new(metrics.NilCounter)
 	}
-	return metrics.GetOrRegisterCounter(name, metrics.DefaultRegistry)
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+	return metrics.GetOrRegisterCounter(name, registry)
+}
+
+// NewRegisteredGauge creates a new metrics Gauge within the given registry (or
+// the default one if nil), or a NOP stub if metrics are disabled.
+func NewRegisteredGauge(name string, registry metrics.Registry) metrics.Gauge {
+	if !Enabled {
+		return new(metrics.NilGauge)
+	}
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+	return metrics.GetOrRegisterGauge(name, registry)
 }
 
 // NewMeter create a new metrics Meter, either a real one of a NOP stub depending
 // on the metrics flag.
 func NewMeter(name string) metrics.Meter {
+	return NewRegisteredMeter(name, nil)
+}
+
+// NewRegisteredMeter creates a new metrics Meter within the given registry (or
+// the default one if nil), or a NOP stub if metrics are disabled.
+func NewRegisteredMeter(name string, registry metrics.Registry) metrics.Meter {
 	if !Enabled {
 		return new(metrics.NilMeter)
 	}
-	return metrics.GetOrRegisterMeter(name, metrics.DefaultRegistry)
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+	return metrics.GetOrRegisterMeter(name, registry)
 }
 
 // NewTimer create a new metrics Timer, either a real one of a NOP stub depending
 // on the metrics flag.
 func NewTimer(name string) metrics.Timer {
+	return NewRegisteredTimer(name, nil)
+}
+
+// NewRegisteredTimer creates a new metrics Timer within the given registry (or
+// the default one if nil), or a NOP stub if metrics are disabled.
+func NewRegisteredTimer(name string, registry metrics.Registry) metrics.Timer {
 	if !Enabled {
 		return new(metrics.NilTimer)
 	}
-	return metrics.GetOrRegisterTimer(name, metrics.DefaultRegistry)
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+	return metrics.GetOrRegisterTimer(name, registry)
 }
 
 // CollectProcessMetrics periodically collects various metrics about the running
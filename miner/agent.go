@@ -22,10 +22,8 @@ import (
 
 	"sync/atomic"
 
-	"github.com/expanse-org/go-expanse/common"
-	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/log"
-	"github.com/expanse-org/go-expanse/pow"
 )
 
 type CpuAgent struct {
@@ -36,15 +34,17 @@ type CpuAgent struct {
 	quitCurrentOp chan struct{}
 	returnCh      chan<- *Result
 
-	index int
-	pow   pow.PoW
+	index  int
+	chain  consensus.ChainReader
+	engine consensus.Engine
 
 	isMining int32 // isMining indicates whether the agent is currently mining
 }
 
-func NewCpuAgent(index int, pow pow.PoW) *CpuAgent {
+func NewCpuAgent(index int, chain consensus.ChainReader, engine consensus.Engine) *CpuAgent {
 	miner := &CpuAgent{
-		pow:    pow,
+		chain:  chain,
+		engine: engine,
 		index:  index,
 		quit:   make(chan struct{}),
 		workCh: make(chan *Work, 1),
@@ -54,7 +54,7 @@ func NewCpuAgent(index int, pow pow.PoW) *CpuAgent {
 }
 
 func (self *CpuAgent) Work() chan<- *Work            { return self.workCh }
-func (self *CpuAgent) Pow() pow.PoW                  { return self.pow }
+func (self *CpuAgent) Engine() consensus.Engine      { return self.engine }
 func (self *CpuAgent) SetReturnCh(ch chan<- *Result) { self.returnCh = ch }
 
 func (self *CpuAgent) Stop() {
@@ -79,8 +79,11 @@ out:
 			if self.quitCurrentOp != nil {
 				close(self.quitCurrentOp)
 			}
-			self.quitCurrentOp = make(chan struct{})
-			go self.mine(work, self.quitCurrentOp)
+			self.quitCurrentOp = nil
+			if work != nil {
+				self.quitCurrentOp = make(chan struct{})
+				go self.mine(work, self.quitCurrentOp)
+			}
 			self.mu.Unlock()
 		case <-self.quit:
 			self.mu.Lock()
@@ -111,9 +114,11 @@ func (self *CpuAgent) mine(work *Work, stop <-chan struct{}) {
 	log.Debug(fmt.Sprintf("(re)started agent[%d]. mining...\n", self.index))
 
 	// Mine
-	nonce, mixDigest := self.pow.Search(work.Block, stop)
-	if nonce != 0 {
-		block := work.Block.WithMiningResult(types.EncodeNonce(nonce), common.BytesToHash(mixDigest))
+	block, err := self.engine.Seal(self.chain, work.Block, stop)
+	if err != nil {
+		log.Warn("Block sealing failed", "err", err)
+	}
+	if block != nil {
 		self.returnCh <- &Result{work, block}
 	} else {
 		self.returnCh <- nil
@@ -121,5 +126,8 @@ func (self *CpuAgent) mine(work *Work, stop <-chan struct{}) {
 }
 
 func (self *CpuAgent) GetHashRate() int64 {
-	return int64(self.pow.Hashrate())
+	if pow, ok := self.engine.(consensus.PoW); ok {
+		return int64(pow.Hashrate())
+	}
+	return 0
 }
@@ -0,0 +1,99 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+
+	"github.com/expanse-org/go-expanse/common"
+)
+
+var (
+	errNoEtherbase         = errors.New("no etherbase given")
+	errEtherbaseWeights    = errors.New("etherbase and weight count mismatch")
+	errZeroEtherbaseWeight = errors.New("etherbase weight must be positive")
+)
+
+// Etherbases is a set of addresses the miner rotates the rewards of its blocks
+// through. The etherbase of a block is picked by its number, so that each address
+// receives a share of the blocks proportional to its weight, and a block keeps
+// its etherbase while its work is updated with new transactions.
+type Etherbases struct {
+	addrs   []common.Address
+	weights []uint64
+	total   uint64
+}
+
+// NewEtherbases creates an etherbase rotation over the given addresses. If the
+// weights are nil, the addresses are rotated through round-robin.
+func NewEtherbases(addrs []common.Address, weights []uint64) (*Etherbases, error) {
+	if len(addrs) == 0 {
+		return nil, errNoEtherbase
+	}
+	if weights == nil {
+		weights = make([]uint64, len(addrs))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	if len(weights) != len(addrs) {
+		return nil, errEtherbaseWeights
+	}
+	e := &Etherbases{
+		addrs:   append([]common.Address(nil), addrs...),
+		weights: append([]uint64(nil), weights...),
+	}
+	for _, weight := range weights {
+		if weight == 0 {
+			return nil, errZeroEtherbaseWeight
+		}
+		e.total += weight
+	}
+	return e, nil
+}
+
+// singleEtherbase creates an etherbase "rotation" of a single address.
+func singleEtherbase(addr common.Address) *Etherbases {
+	return &Etherbases{addrs: []common.Address{addr}, weights: []uint64{1}, total: 1}
+}
+
+// Primary returns the first etherbase of the rotation.
+func (e *Etherbases) Primary() common.Address {
+	return e.addrs[0]
+}
+
+// Addresses returns the etherbases of the rotation.
+func (e *Etherbases) Addresses() []common.Address {
+	return append([]common.Address(nil), e.addrs...)
+}
+
+// Weights returns the weights of the etherbases of the rotation.
+func (e *Etherbases) Weights() []uint64 {
+	return append([]uint64(nil), e.weights...)
+}
+
+// At returns the etherbase of the block with the given number.
+func (e *Etherbases) At(number uint64) common.Address {
+	slot := number % e.total
+	for i, weight := range e.weights {
+		if slot < weight {
+			return e.addrs[i]
+		}
+		slot -= weight
+	}
+	return e.addrs[0] // unreachable, slots are below the total weight
+}
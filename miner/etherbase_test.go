@@ -0,0 +1,67 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+)
+
+// Tests that blocks are assigned to the etherbases in proportion to their weights.
+func TestEtherbasesRotation(t *testing.T) {
+	var (
+		a = common.Address{0x0a}
+		b = common.Address{0x0b}
+		c = common.Address{0x0c}
+	)
+	tests := []struct {
+		weights []uint64
+		want    []common.Address // etherbases of blocks 0, 1, ...
+	}{
+		{nil, []common.Address{a, b, c, a, b, c}},
+		{[]uint64{1, 2, 3}, []common.Address{a, b, b, c, c, c, a, b}},
+	}
+	for i, tt := range tests {
+		etherbases, err := NewEtherbases([]common.Address{a, b, c}, tt.weights)
+		if err != nil {
+			t.Fatalf("test %d: failed to create rotation: %v", i, err)
+		}
+		for number, want := range tt.want {
+			if have := etherbases.At(uint64(number)); have != want {
+				t.Errorf("test %d: etherbase of block %d mismatch: have %x, want %x", i, number, have, want)
+			}
+		}
+		if etherbases.Primary() != a {
+			t.Errorf("test %d: primary etherbase mismatch: have %x, want %x", i, etherbases.Primary(), a)
+		}
+	}
+}
+
+// Tests that invalid etherbase rotations are rejected.
+func TestEtherbasesInvalid(t *testing.T) {
+	addrs := []common.Address{{0x0a}, {0x0b}}
+	if _, err := NewEtherbases(nil, nil); err != errNoEtherbase {
+		t.Errorf("empty rotation error mismatch: have %v, want %v", err, errNoEtherbase)
+	}
+	if _, err := NewEtherbases(addrs, []uint64{1}); err != errEtherbaseWeights {
+		t.Errorf("weight count error mismatch: have %v, want %v", err, errEtherbaseWeights)
+	}
+	if _, err := NewEtherbases(addrs, []uint64{1, 0}); err != errZeroEtherbaseWeight {
+		t.Errorf("zero weight error mismatch: have %v, want %v", err, errZeroEtherbaseWeight)
+	}
+}
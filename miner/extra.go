@@ -0,0 +1,109 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+var errInvalidExtra = errors.New("extra-data is not client information")
+
+// ExtraInfo is the client information miners put into the extra-data of their
+// blocks, encoded as the RLP list [version, name, go version, os, tag]. The
+// operator tag is optional, leaving the list of clients not setting one intact.
+type ExtraInfo struct {
+	Version   uint   // Client version as major<<16 | minor<<8 | patch
+	Name      string // Client name
+	GoVersion string // Go runtime the client was built with
+	OS        string // Operating system the client runs on
+	Tag       string // Free form tag chosen by the operator of the miner
+}
+
+// NewExtraInfo creates the client information with the version of this client.
+func NewExtraInfo(name, goVersion, os, tag string) ExtraInfo {
+	return ExtraInfo{
+		Version:   uint(params.VersionMajor<<16 | params.VersionMinor<<8 | params.VersionPatch),
+		Name:      name,
+		GoVersion: goVersion,
+		OS:        os,
+		Tag:       tag,
+	}
+}
+
+// VersionString returns the client version in vX.Y.Z notation.
+func (info ExtraInfo) VersionString() string {
+	return fmt.Sprintf("v%d.%d.%d", info.Version>>16, info.Version>>8&0xff, info.Version&0xff)
+}
+
+// encode RLP encodes the client information, omitting an empty tag.
+func (info ExtraInfo) encode() []byte {
+	fields := []interface{}{info.Version, info.Name, info.GoVersion, info.OS}
+	if info.Tag != "" {
+		fields = append(fields, info.Tag)
+	}
+	extra, err := rlp.EncodeToBytes(fields)
+	if err != nil {
+		panic(err) // can't happen, all fields are encodable
+	}
+	return extra
+}
+
+// ComposeExtra encodes the client information into extra-data, fitting it into
+// the maximum extra-data size. If it doesn't fit, the runtime details are dropped
+// first, then the tag is truncated. Nil is returned if even the bare version and
+// name of the client don't fit.
+func ComposeExtra(info ExtraInfo) []byte {
+	if extra := info.encode(); uint64(len(extra)) <= params.MaximumExtraDataSize {
+		return extra
+	}
+	info.GoVersion, info.OS = "", ""
+	for {
+		if extra := info.encode(); uint64(len(extra)) <= params.MaximumExtraDataSize {
+			return extra
+		}
+		if info.Tag == "" {
+			return nil
+		}
+		_, size := utf8.DecodeLastRuneInString(info.Tag)
+		info.Tag = info.Tag[:len(info.Tag)-size]
+	}
+}
+
+// ParseExtra decodes the client information from the extra-data of a block, as
+// composed by ComposeExtra or the clients predating operator tags.
+func ParseExtra(extra []byte) (*ExtraInfo, error) {
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(extra, &fields); err != nil {
+		return nil, errInvalidExtra
+	}
+	if len(fields) != 4 && len(fields) != 5 {
+		return nil, errInvalidExtra
+	}
+	info := new(ExtraInfo)
+	dests := []interface{}{&info.Version, &info.Name, &info.GoVersion, &info.OS, &info.Tag}
+	for i, field := range fields {
+		if err := rlp.DecodeBytes(field, dests[i]); err != nil {
+			return nil, errInvalidExtra
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+// Tests that the client information is composed within the extra-data limit,
+// dropping the runtime details before truncating the operator tag.
+func TestComposeExtra(t *testing.T) {
+	tests := []struct {
+		info ExtraInfo
+		want *ExtraInfo // nil if not composable
+	}{
+		// Everything fits, with and without tag
+		{
+			info: ExtraInfo{Version: 0x010600, Name: "gexp", GoVersion: "go1.8.3", OS: "linux"},
+			want: &ExtraInfo{Version: 0x010600, Name: "gexp", GoVersion: "go1.8.3", OS: "linux"},
+		},
+		{
+			info: ExtraInfo{Version: 0x010600, Name: "gexp", GoVersion: "go1.8", OS: "linux", Tag: "pool"},
+			want: &ExtraInfo{Version: 0x010600, Name: "gexp", GoVersion: "go1.8", OS: "linux", Tag: "pool"},
+		},
+		// Runtime details are dropped to make room for the tag
+		{
+			info: ExtraInfo{Version: 0x010600, Name: "gexp", GoVersion: "go1.8.3", OS: "linux", Tag: "expanse-pool-01"},
+			want: &ExtraInfo{Version: 0x010600, Name: "gexp", Tag: "expanse-pool-01"},
+		},
+		// Overly long tags are truncated, without splitting characters
+		{
+			info: ExtraInfo{Version: 0x010600, Name: "gexp", GoVersion: "go1.8.3", OS: "linux", Tag: strings.Repeat("x", 40)},
+			want: &ExtraInfo{Version: 0x010600, Name: "gexp", Tag: strings.Repeat("x", 19)},
+		},
+		{
+			info: ExtraInfo{Version: 0x010600, Name: "gexp", Tag: strings.Repeat("x", 18) + "ü"},
+			want: &ExtraInfo{Version: 0x010600, Name: "gexp", Tag: strings.Repeat("x", 18)},
+		},
+		// Nothing to truncate if the name itself doesn't fit
+		{
+			info: ExtraInfo{Version: 0x010600, Name: strings.Repeat("x", 40)},
+		},
+	}
+	for i, tt := range tests {
+		extra := ComposeExtra(tt.info)
+		if tt.want == nil {
+			if extra != nil {
+				t.Errorf("test %d: composed %x, want none", i, extra)
+			}
+			continue
+		}
+		if uint64(len(extra)) > params.MaximumExtraDataSize {
+			t.Errorf("test %d: extra-data too long: %d bytes", i, len(extra))
+		}
+		info, err := ParseExtra(extra)
+		if err != nil {
+			t.Errorf("test %d: failed to parse extra-data: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(info, tt.want) {
+			t.Errorf("test %d: client info mismatch: have %+v, want %+v", i, info, tt.want)
+		}
+	}
+}
+
+// Tests that extra-data which isn't client information is rejected.
+func TestParseExtraInvalid(t *testing.T) {
+	short, _ := rlp.EncodeToBytes([]interface{}{uint(1), "gexp"})
+	tests := [][]byte{nil, []byte("vanity"), short}
+	for i, extra := range tests {
+		if _, err := ParseExtra(extra); err != errInvalidExtra {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, errInvalidExtra)
+		}
+	}
+}
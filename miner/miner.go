@@ -21,9 +21,11 @@ import (
 	"fmt"
 	"math/big"
 	"sync/atomic"
+	"time"
 
 	"github.com/expanse-org/go-expanse/accounts"
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core"
 	"github.com/expanse-org/go-expanse/core/state"
 	"github.com/expanse-org/go-expanse/core/types"
@@ -32,7 +34,6 @@ import (
 	"github.com/expanse-org/go-expanse/event"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/params"
-	"github.com/expanse-org/go-expanse/pow"
 )
 
 // Backend wraps all methods required for mining.
@@ -49,21 +50,21 @@ type Miner struct {
 
 	worker *worker
 
-	threads  int
-	coinbase common.Address
-	mining   int32
-	eth      Backend
-	pow      pow.PoW
+	threads    int
+	etherbases *Etherbases
+	mining     int32
+	eth        Backend
+	engine     consensus.Engine
 
 	canStart    int32 // can start indicates whether we can start the mining operation
 	shouldStart int32 // should start indicates whether we should start after sync
 }
 
-func New(eth Backend, config *params.ChainConfig, mux *event.TypeMux, pow pow.PoW) *Miner {
+func New(eth Backend, config *params.ChainConfig, mux *event.TypeMux, engine consensus.Engine) *Miner {
 	miner := &Miner{
 		eth:      eth,
 		mux:      mux,
-		pow:      pow,
+		engine:   engine,
 		worker:   newWorker(config, common.Address{}, eth, mux),
 		canStart: 1,
 	}
@@ -94,7 +95,7 @@ out:
 			atomic.StoreInt32(&self.canStart, 1)
 			atomic.StoreInt32(&self.shouldStart, 0)
 			if shouldStart {
-				self.Start(self.coinbase, self.threads)
+				self.Start(self.etherbases.Primary(), self.threads)
 			}
 			// unsubscribe. we're only interested in this event once
 			events.Unsubscribe()
@@ -116,10 +117,14 @@ func (m *Miner) SetGasPrice(price *big.Int) {
 	m.worker.setGasPrice(price)
 }
 
+// Start begins mining with the given number of threads. The etherbase rotation
+// is kept if the coinbase is its primary address, otherwise it's replaced by the
+// coinbase.
 func (self *Miner) Start(coinbase common.Address, threads int) {
 	atomic.StoreInt32(&self.shouldStart, 1)
-	self.worker.setEtherbase(coinbase)
-	self.coinbase = coinbase
+	if self.etherbases == nil || self.etherbases.Primary() != coinbase {
+		self.SetEtherbase(coinbase)
+	}
 	self.threads = threads
 
 	if atomic.LoadInt32(&self.canStart) == 0 {
@@ -129,7 +134,7 @@ func (self *Miner) Start(coinbase common.Address, threads int) {
 	atomic.StoreInt32(&self.mining, 1)
 
 	for i := 0; i < threads; i++ {
-		self.worker.register(NewCpuAgent(i, self.pow))
+		self.worker.register(NewCpuAgent(i, self.eth.BlockChain(), self.engine))
 	}
 
 	log.Info(fmt.Sprintf("Starting mining operation (CPU=%d TOT=%d)\n", threads, len(self.worker.agents)))
@@ -159,7 +164,9 @@ func (self *Miner) Mining() bool {
 }
 
 func (self *Miner) HashRate() (tot int64) {
-	tot += int64(self.pow.Hashrate())
+	if pow, ok := self.engine.(consensus.PoW); ok {
+		tot += int64(pow.Hashrate())
+	}
 	// do we care this might race? is it worth we're rewriting some
 	// aspects of the worker/locking up agents so we can get an accurate
 	// hashrate?
@@ -193,7 +200,66 @@ func (self *Miner) PendingBlock() *types.Block {
 	return self.worker.pendingBlock()
 }
 
+// SetEtherbase sets the single address block rewards are credited to.
 func (self *Miner) SetEtherbase(addr common.Address) {
-	self.coinbase = addr
-	self.worker.setEtherbase(addr)
+	self.SetEtherbases(singleEtherbase(addr))
+}
+
+// Etherbases returns the etherbase rotation of the miner, nil if none was set.
+func (self *Miner) Etherbases() *Etherbases {
+	return self.etherbases
+}
+
+// SetEtherbases sets the addresses the rewards of the mined blocks are rotated
+// through.
+func (self *Miner) SetEtherbases(etherbases *Etherbases) {
+	self.etherbases = etherbases
+	self.worker.setEtherbases(etherbases)
+}
+
+// SetStrictParent makes the miner only mine on top of blocks this node fully
+// validated itself within the given age. Zero allows mining on any head.
+func (self *Miner) SetStrictParent(age time.Duration) {
+	self.worker.setStrictParent(age)
+}
+
+// BanBlocks makes the miner refuse to extend chains containing any of the given
+// blocks, and to include them or their children as uncles.
+func (self *Miner) BanBlocks(hashes ...common.Hash) {
+	for _, hash := range hashes {
+		self.worker.setBanned(hash, true)
+	}
+	if self.Mining() {
+		self.worker.commitNewWork()
+	}
+}
+
+// UnbanBlocks lifts the ban of the given blocks.
+func (self *Miner) UnbanBlocks(hashes ...common.Hash) {
+	for _, hash := range hashes {
+		self.worker.setBanned(hash, false)
+	}
+	if self.Mining() {
+		self.worker.commitNewWork()
+	}
+}
+
+// BannedBlocks returns the hashes of the blocks the miner refuses to extend.
+func (self *Miner) BannedBlocks() []common.Hash {
+	return self.worker.bannedBlocks()
+}
+
+// SetUnclePolicy changes the rules by which the miner picks the uncles of the
+// blocks it mines, taking effect from the next block.
+func (self *Miner) SetUnclePolicy(policy UnclePolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	self.worker.setUnclePolicy(policy)
+	return nil
+}
+
+// UnclePolicy returns the rules by which the miner picks uncles.
+func (self *Miner) UnclePolicy() UnclePolicy {
+	return self.worker.getUnclePolicy()
 }
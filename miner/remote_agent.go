@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
 	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/pow"
@@ -42,7 +43,8 @@ type RemoteAgent struct {
 	workCh   chan *Work
 	returnCh chan<- *Result
 
-	pow         pow.PoW
+	chain       consensus.ChainReader
+	engine      consensus.Engine
 	currentWork *Work
 	work        map[common.Hash]*Work
 
@@ -52,9 +54,10 @@ type RemoteAgent struct {
 	running int32 // running indicates whether the agent is active. Call atomically
 }
 
-func NewRemoteAgent(pow pow.PoW) *RemoteAgent {
+func NewRemoteAgent(chain consensus.ChainReader, engine consensus.Engine) *RemoteAgent {
 	return &RemoteAgent{
-		pow:      pow,
+		chain:    chain,
+		engine:   engine,
 		work:     make(map[common.Hash]*Work),
 		hashrate: make(map[common.Hash]hashrate),
 	}
@@ -144,7 +147,7 @@ func (a *RemoteAgent) SubmitWork(nonce types.BlockNonce, mixDigest, hash common.
 	}
 	// Make sure the PoW solutions is indeed valid
 	block := work.Block.WithMiningResult(nonce, mixDigest)
-	if err := a.pow.Verify(block); err != nil {
+	if err := a.engine.VerifySeal(a.chain, block.Header()); err != nil {
 		log.Warn(fmt.Sprintf("Invalid PoW submitted for %x: %v", hash, err))
 		return false
 	}
@@ -0,0 +1,89 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+)
+
+const (
+	maxUnclesPerBlock = 2 // Maximum number of uncles the protocol allows in a block
+	maxUncleAge       = 7 // Maximum number of blocks an uncle may be behind the block including it
+)
+
+var (
+	errTooManyUncles = errors.New("uncle count exceeds the protocol maximum of 2")
+	errUncleAgeRange = errors.New("uncle age must be between 1 and 7 blocks")
+)
+
+// DefaultUnclePolicy includes as many uncles as the protocol allows, regardless
+// of who mined them.
+var DefaultUnclePolicy = UnclePolicy{MaxUncles: maxUnclesPerBlock, MaxAge: maxUncleAge}
+
+// UnclePolicy controls which of the side blocks known to the miner are included
+// as uncles into the blocks it mines.
+type UnclePolicy struct {
+	MaxUncles int    `json:"maxUncles"` // Maximum number of uncles included per block (0 = none)
+	MaxAge    uint64 `json:"maxAge"`    // Maximum number of blocks an uncle may be behind the mined block
+	PreferOwn bool   `json:"preferOwn"` // Whether uncles mined by the own etherbases go first
+}
+
+// Validate checks that the policy stays within the limits of the protocol.
+func (p UnclePolicy) Validate() error {
+	if p.MaxUncles < 0 || p.MaxUncles > maxUnclesPerBlock {
+		return errTooManyUncles
+	}
+	if p.MaxAge == 0 || p.MaxAge > maxUncleAge {
+		return errUncleAgeRange
+	}
+	return nil
+}
+
+// orderUncles returns the candidate uncles for the block with the given number,
+// in the order they should be tried, along with the candidates too old to ever
+// be included again. Uncles of the own etherbases go first if the policy prefers
+// them, then younger uncles before older ones as they are rewarded more.
+func orderUncles(candidates map[common.Hash]*types.Block, number uint64, policy UnclePolicy, own func(common.Address) bool) (ordered []*types.Block, stale []common.Hash) {
+	for hash, uncle := range candidates {
+		if uncle.NumberU64() >= number {
+			continue
+		}
+		if number-uncle.NumberU64() > policy.MaxAge {
+			stale = append(stale, hash)
+			continue
+		}
+		ordered = append(ordered, uncle)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if policy.PreferOwn {
+			if owni, ownj := own(ordered[i].Coinbase()), own(ordered[j].Coinbase()); owni != ownj {
+				return owni
+			}
+		}
+		if ni, nj := ordered[i].NumberU64(), ordered[j].NumberU64(); ni != nj {
+			return ni > nj
+		}
+		hi, hj := ordered[i].Hash(), ordered[j].Hash()
+		return bytes.Compare(hi[:], hj[:]) < 0
+	})
+	return ordered, stale
+}
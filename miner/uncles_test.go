@@ -0,0 +1,93 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+)
+
+// Tests that uncle policies beyond the protocol limits are rejected.
+func TestUnclePolicyValidation(t *testing.T) {
+	tests := []struct {
+		policy UnclePolicy
+		err    error
+	}{
+		{DefaultUnclePolicy, nil},
+		{UnclePolicy{MaxUncles: 0, MaxAge: 1}, nil},
+		{UnclePolicy{MaxUncles: 1, MaxAge: 3, PreferOwn: true}, nil},
+		{UnclePolicy{MaxUncles: 3, MaxAge: 7}, errTooManyUncles},
+		{UnclePolicy{MaxUncles: -1, MaxAge: 7}, errTooManyUncles},
+		{UnclePolicy{MaxUncles: 2, MaxAge: 0}, errUncleAgeRange},
+		{UnclePolicy{MaxUncles: 2, MaxAge: 8}, errUncleAgeRange},
+	}
+	for i, tt := range tests {
+		if err := tt.policy.Validate(); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that candidate uncles are filtered by age and ordered by the policy.
+func TestOrderUncles(t *testing.T) {
+	own, other := common.Address{0x01}, common.Address{0x02}
+
+	newUncle := func(number int64, coinbase common.Address) *types.Block {
+		return types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Coinbase: coinbase})
+	}
+	var (
+		young    = newUncle(9, other)
+		ownOld   = newUncle(6, own)
+		old      = newUncle(5, other)
+		tooOld   = newUncle(2, own)
+		tooYoung = newUncle(10, other)
+	)
+	candidates := make(map[common.Hash]*types.Block)
+	for _, uncle := range []*types.Block{young, ownOld, old, tooOld, tooYoung} {
+		candidates[uncle.Hash()] = uncle
+	}
+	isOwn := func(addr common.Address) bool { return addr == own }
+
+	tests := []struct {
+		policy UnclePolicy
+		order  []*types.Block
+		stale  int
+	}{
+		{DefaultUnclePolicy, []*types.Block{young, ownOld, old}, 1},
+		{UnclePolicy{MaxUncles: 2, MaxAge: 7, PreferOwn: true}, []*types.Block{ownOld, young, old}, 1},
+		{UnclePolicy{MaxUncles: 2, MaxAge: 4, PreferOwn: true}, []*types.Block{ownOld, young}, 2},
+		{UnclePolicy{MaxUncles: 2, MaxAge: 1}, []*types.Block{young}, 3},
+	}
+	for i, tt := range tests {
+		order, stale := orderUncles(candidates, 10, tt.policy, isOwn)
+		if len(stale) != tt.stale {
+			t.Errorf("test %d: stale count mismatch: have %d, want %d", i, len(stale), tt.stale)
+		}
+		if len(order) != len(tt.order) {
+			t.Errorf("test %d: candidate count mismatch: have %d, want %d", i, len(order), len(tt.order))
+			continue
+		}
+		for j, uncle := range order {
+			if uncle != tt.order[j] {
+				t.Errorf("test %d, candidate %d: have block #%d, want #%d", i, j, uncle.NumberU64(), tt.order[j].NumberU64())
+			}
+		}
+	}
+}
@@ -18,6 +18,7 @@ package miner
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
@@ -34,7 +35,6 @@ import (
 	"github.com/expanse-org/go-expanse/event"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/params"
-	"github.com/expanse-org/go-expanse/pow"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -43,6 +43,12 @@ const (
 	miningLogAtDepth = 5
 )
 
+var (
+	errParentNotValidated = errors.New("parent not validated by this node")
+	errParentStale        = errors.New("parent validated too long ago")
+	errBannedChain        = errors.New("chain contains banned block")
+)
+
 // Agent can register themself with the worker
 type Agent interface {
 	Work() chan<- *Work
@@ -64,6 +70,7 @@ type Work struct {
 	uncles        *set.Set       // uncle set
 	tcount        int            // tx count in cycle
 	ownedAccounts *set.Set
+	gasPool       *core.GasPool // available gas for transactions in the block
 	lowGasTxs     types.Transactions
 	failedTxs     types.Transactions
 
@@ -94,16 +101,20 @@ type worker struct {
 
 	agents map[Agent]struct{}
 	recv   chan *Result
-	pow    pow.PoW
 
 	eth     Backend
 	chain   *core.BlockChain
 	proc    core.Validator
 	chainDb ethdb.Database
 
-	coinbase common.Address
-	gasPrice *big.Int
-	extra    []byte
+	etherbases *Etherbases
+	gasPrice   *big.Int
+	extra      []byte
+
+	strictParent time.Duration             // Maximum age of the validation of mined on parents (0 = any parent)
+	validated    map[common.Hash]time.Time // Heads fully validated by this node, when strict about parents
+	banned       map[common.Hash]struct{}  // Blocks whose descendants are never mined on or taken as uncles
+	unclePolicy  UnclePolicy               // Rules for picking the uncles of mined blocks
 
 	currentMu sync.Mutex
 	current   *Work
@@ -134,7 +145,10 @@ func newWorker(config *params.ChainConfig, coinbase common.Address, eth Backend,
 		chain:          eth.BlockChain(),
 		proc:           eth.BlockChain().Validator(),
 		possibleUncles: make(map[common.Hash]*types.Block),
-		coinbase:       coinbase,
+		etherbases:     singleEtherbase(coinbase),
+		validated:      make(map[common.Hash]time.Time),
+		banned:         make(map[common.Hash]struct{}),
+		unclePolicy:    DefaultUnclePolicy,
 		txQueue:        make(map[common.Hash]*types.Transaction),
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(eth.BlockChain(), 5),
@@ -149,10 +163,101 @@ func newWorker(config *params.ChainConfig, coinbase common.Address, eth Backend,
 	return worker
 }
 
-func (self *worker) setEtherbase(addr common.Address) {
+func (self *worker) setEtherbases(etherbases *Etherbases) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.etherbases = etherbases
+}
+
+func (self *worker) setStrictParent(age time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.strictParent = age
+}
+
+// markValidated records that a new head was fully validated by this node.
+func (self *worker) markValidated(hash common.Hash, now time.Time) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.strictParent == 0 {
+		return
+	}
+	for hash, validated := range self.validated {
+		if now.Sub(validated) > self.strictParent {
+			delete(self.validated, hash)
+		}
+	}
+	self.validated[hash] = now
+}
+
+func (self *worker) setUnclePolicy(policy UnclePolicy) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
-	self.coinbase = addr
+	self.unclePolicy = policy
+}
+
+func (self *worker) getUnclePolicy() UnclePolicy {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.unclePolicy
+}
+
+// isOwnEtherbase reports whether the address is one of the miner's etherbases.
+func (self *worker) isOwnEtherbase(addr common.Address) bool {
+	if self.etherbases == nil {
+		return false
+	}
+	for _, etherbase := range self.etherbases.Addresses() {
+		if etherbase == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *worker) setBanned(hash common.Hash, banned bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if banned {
+		self.banned[hash] = struct{}{}
+	} else {
+		delete(self.banned, hash)
+	}
+}
+
+func (self *worker) bannedBlocks() []common.Hash {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	hashes := make([]common.Hash, 0, len(self.banned))
+	for hash := range self.banned {
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// checkParent returns why the given canonical block must not be mined on, if it
+// was not validated by this node recently enough or its chain contains a banned
+// block. The worker lock must be held.
+func (self *worker) checkParent(parent *types.Block) error {
+	if self.strictParent > 0 {
+		validated, ok := self.validated[parent.Hash()]
+		if !ok {
+			return errParentNotValidated
+		}
+		if time.Since(validated) > self.strictParent {
+			return errParentStale
+		}
+	}
+	for hash := range self.banned {
+		number := core.GetBlockNumber(self.chainDb, hash)
+		if number <= parent.NumberU64() && core.GetCanonicalHash(self.chainDb, number) == hash {
+			return errBannedChain
+		}
+	}
+	return nil
 }
 
 func (self *worker) setExtra(extra []byte) {
@@ -242,6 +347,7 @@ func (self *worker) update() {
 		// A real event arrived, process interesting content
 		switch ev := event.Data.(type) {
 		case core.ChainHeadEvent:
+			self.markValidated(ev.Block.Hash(), time.Now())
 			self.commitNewWork()
 		case core.ChainSideEvent:
 			self.uncleMu.Lock()
@@ -276,31 +382,23 @@ func (self *worker) wait() {
 			work := result.Work
 
 			if self.fullValidation {
-				if _, err := self.chain.InsertChain(types.Blocks{block}); err != nil {
+				if _, err := self.chain.InsertChainPriority(types.Blocks{block}); err != nil {
 					log.Error(fmt.Sprint("mining err", err))
 					continue
 				}
 				go self.mux.Post(core.NewMinedBlockEvent{Block: block})
 			} else {
-				work.state.Commit(self.config.IsEIP158(block.Number()))
 				parent := self.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
 				if parent == nil {
 					log.Error(fmt.Sprint("Invalid block found during mining"))
 					continue
 				}
 
-				auxValidator := self.eth.BlockChain().AuxValidator()
-				if err := core.ValidateHeader(self.config, auxValidator, block.Header(), parent.Header(), true, false); err != nil && err != core.BlockFutureErr {
+				if err := core.ValidateHeader(self.chain, self.chain.Engine(), block.Header(), parent.Header(), true, false); err != nil && err != core.BlockFutureErr {
 					log.Error(fmt.Sprint("Invalid header on mined block:", err))
 					continue
 				}
 
-				stat, err := self.chain.WriteBlock(block)
-				if err != nil {
-					log.Error(fmt.Sprint("error writing block to chain", err))
-					continue
-				}
-
 				// update block hash since it is now available and not when the receipt/log of individual transactions were created
 				for _, r := range work.receipts {
 					for _, l := range r.Logs {
@@ -310,21 +408,28 @@ func (self *worker) wait() {
 				for _, log := range work.state.Logs() {
 					log.BlockHash = block.Hash()
 				}
+				stat, err := self.chain.WriteBlockWithState(block, work.receipts, work.state)
+				if err != nil {
+					log.Error(fmt.Sprint("error writing block to chain", err))
+					continue
+				}
 
 				// check if canon block and write transactions
 				if stat == core.CanonStatTy {
-					// This puts transactions in a extra db for rpc
-					core.WriteTransactions(self.chainDb, block)
-					// store the receipts
-					core.WriteReceipts(self.chainDb, work.receipts)
+					// This puts transactions and receipts in a extra db for rpc
+					batch := self.chainDb.NewBatch()
+					core.WriteTransactions(batch, block)
+					core.WriteReceipts(batch, work.receipts)
+					batch.Write()
 					// Write map map bloom filters
 					core.WriteMipmapBloom(self.chainDb, block.NumberU64(), work.receipts)
+					core.WriteLogIndex(self.chainDb, block.NumberU64(), work.receipts)
 					// implicit by posting ChainHeadEvent
 					mustCommitNewWork = false
 				}
 
 				// broadcast before waiting for validation
-				go func(block *types.Block, logs []*types.Log, receipts []*types.Receipt) {
+				go func(block *types.Block, logs []*types.Log) {
 					self.mux.Post(core.NewMinedBlockEvent{Block: block})
 					self.mux.Post(core.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
 
@@ -332,10 +437,7 @@ func (self *worker) wait() {
 						self.mux.Post(core.ChainHeadEvent{Block: block})
 						self.mux.Post(logs)
 					}
-					if err := core.WriteBlockReceipts(self.chainDb, block.Hash(), block.NumberU64(), receipts); err != nil {
-						log.Warn(fmt.Sprint("error writing block receipts:", err))
-					}
-				}(block, work.state.Logs(), work.receipts)
+				}(block, work.state.Logs())
 			}
 			// Insert the block into the set of pending ones to wait for confirmations
 			self.unconfirmed.Insert(block.NumberU64(), block.Hash())
@@ -348,12 +450,16 @@ func (self *worker) wait() {
 }
 
 // push sends a new work task to currently live miner agents.
+// push hands the work to the agents. Nil work makes them stop mining on their
+// current work.
 func (self *worker) push(work *Work) {
 	if atomic.LoadInt32(&self.mining) != 1 {
 		return
 	}
 	for agent := range self.agents {
-		atomic.AddInt32(&self.atWork, 1)
+		if work != nil {
+			atomic.AddInt32(&self.atWork, 1)
+		}
 		if ch := agent.Work(); ch != nil {
 			ch <- work
 		}
@@ -374,11 +480,12 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 		family:    set.New(),
 		uncles:    set.New(),
 		header:    header,
+		gasPool:   new(core.GasPool).AddGas(header.GasLimit),
 		createdAt: time.Now(),
 	}
 
 	// when 08 is processed ancestors contain 07 (quick block)
-	for _, ancestor := range self.chain.GetBlocksFromHash(parent.Hash(), 7) {
+	for _, ancestor := range self.chain.GetBlocksFromHash(parent.Hash(), int(self.config.MaxUncleDepth())) {
 		for _, uncle := range ancestor.Uncles() {
 			work.family.Add(uncle.Hash())
 		}
@@ -429,18 +536,21 @@ func (self *worker) commitNewWork() {
 		log.Info(fmt.Sprint("We are too far in the future. Waiting for", wait))
 		time.Sleep(wait)
 	}
-
 	num := parent.Number()
 	header := &types.Header{
 		ParentHash: parent.Hash(),
 		Number:     num.Add(num, common.Big1),
-		Difficulty: core.CalcDifficulty(self.config, uint64(tstamp), parent.Time().Uint64(), parent.Number(), parent.Difficulty()),
 		GasLimit:   core.CalcGasLimit(parent),
 		GasUsed:    new(big.Int),
-		Coinbase:   self.coinbase,
+		Coinbase:   self.etherbases.At(num.Uint64()),
 		Extra:      self.extra,
 		Time:       big.NewInt(tstamp),
 	}
+	// Initialize the consensus fields, engines bounding the block interval may restamp the header
+	if err := self.chain.Engine().Prepare(self.chain, header, parent.Header()); err != nil {
+		log.Error("Failed to prepare header for mining", "err", err)
+		return
+	}
 	// If we are care about TheDAO hard-fork check whether to override the extra-data or not
 	if daoBlock := self.config.DAOForkBlock; daoBlock != nil {
 		// Check whether the block is among the fork extra-override range
@@ -472,8 +582,10 @@ func (self *worker) commitNewWork() {
 		return
 	}
 
-	txs := types.NewTransactionsByPriceAndNonce(pending)
-	work.commitTransactions(self.mux, txs, self.gasPrice, self.chain)
+	// Transactions of gas free senders go first, then the paying ones by price
+	free, paying := splitGasFree(self.config, pending)
+	work.commitTransactions(self.mux, types.NewTransactionsByPriceAndNonce(free), self.gasPrice, self.chain)
+	work.commitTransactions(self.mux, types.NewTransactionsByPriceAndNonce(paying), self.gasPrice, self.chain)
 
 	self.eth.TxPool().RemoveBatch(work.lowGasTxs)
 	self.eth.TxPool().RemoveBatch(work.failedTxs)
@@ -483,10 +595,19 @@ func (self *worker) commitNewWork() {
 		uncles    []*types.Header
 		badUncles []common.Hash
 	)
-	for hash, uncle := range self.possibleUncles {
-		if len(uncles) == 2 {
+	policy := self.unclePolicy
+	if depth := self.config.MaxUncleDepth(); policy.MaxAge > depth {
+		policy.MaxAge = depth
+	}
+	candidates, stale := orderUncles(self.possibleUncles, header.Number.Uint64(), policy, self.isOwnEtherbase)
+	for _, hash := range stale {
+		delete(self.possibleUncles, hash)
+	}
+	for _, uncle := range candidates {
+		if len(uncles) >= policy.MaxUncles {
 			break
 		}
+		hash := uncle.Hash()
 		if err := self.commitUncle(work, uncle.Header()); err != nil {
 			log.Trace(fmt.Sprintf("Bad uncle found and will be removed (%x)\n", hash[:4]))
 			log.Trace(fmt.Sprint(uncle))
@@ -503,7 +624,10 @@ func (self *worker) commitNewWork() {
 
 	if atomic.LoadInt32(&self.mining) == 1 {
 		// commit state root after all state transitions.
-		core.AccumulateRewards(work.state, header, uncles)
+		if err := self.chain.Engine().Finalize(self.chain, work.state, header, uncles); err != nil {
+			log.Error("Failed to accumulate mining rewards", "err", err)
+			return
+		}
 		header.Root = work.state.IntermediateRoot(self.config.IsEIP158(header.Number))
 	}
 
@@ -512,6 +636,11 @@ func (self *worker) commitNewWork() {
 
 	// We only care about logging if we're actually mining.
 	if atomic.LoadInt32(&self.mining) == 1 {
+		if err := self.checkParent(parent); err != nil {
+			log.Warn("Refusing to mine on block", "number", parent.Number(), "hash", parent.Hash(), "err", err)
+			self.push(nil)
+			return
+		}
 		log.Info(fmt.Sprintf("commit new work on block %v with %d txs & %d uncles. Took %v\n", work.Block.Number(), work.tcount, len(uncles), time.Since(tstart)))
 		self.unconfirmed.Shift(work.Block.NumberU64() - 1)
 	}
@@ -529,12 +658,30 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	if work.family.Has(hash) {
 		return core.UncleError(fmt.Sprintf("Uncle already in family (%x)", hash))
 	}
+	if _, ok := self.banned[hash]; ok {
+		return core.UncleError("Uncle banned (%x)", hash)
+	}
 	work.uncles.Add(uncle.Hash())
 	return nil
 }
 
+// splitGasFree separates the pending transactions of the senders whitelisted to
+// transact gas free from the rest.
+func splitGasFree(config *params.ChainConfig, pending map[common.Address]types.Transactions) (free, paying map[common.Address]types.Transactions) {
+	free = make(map[common.Address]types.Transactions)
+	paying = make(map[common.Address]types.Transactions)
+	for from, txs := range pending {
+		if config.IsGasFreeSender(from) {
+			free[from] = txs
+		} else {
+			paying[from] = txs
+		}
+	}
+	return free, paying
+}
+
 func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, gasPrice *big.Int, bc *core.BlockChain) {
-	gp := new(core.GasPool).AddGas(env.header.GasLimit)
+	gp := env.gasPool
 
 	var coalescedLogs []*types.Log
 
@@ -557,9 +704,15 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			txs.Pop()
 			continue
 		}
+		// Ignore any transactions (and accounts subsequently) of frozen addresses
+		if err := core.CheckFreeze(env.config, env.state, env.header.Number, from, tx.To()); err != nil {
+			log.Trace("Transaction of frozen address ignored", "hash", tx.Hash(), "from", from)
 
+			txs.Pop()
+			continue
+		}
 		// Ignore any transactions (and accounts subsequently) with low gas limits
-		if tx.GasPrice().Cmp(gasPrice) < 0 && !env.ownedAccounts.Has(from) {
+		if tx.GasPrice().Cmp(gasPrice) < 0 && !env.ownedAccounts.Has(from) && !env.config.IsGasFreeSender(from) {
 			// Pop the current low-priced transaction without shifting in the next from the account
 			log.Info(fmt.Sprintf("Transaction (%x) below gas price (tx=%dwei ask=%dwei). All sequential txs from this address(%x) will be ignored\n", tx.Hash().Bytes()[:4], tx.GasPrice(), gasPrice, from[:4]))
 
@@ -613,9 +766,13 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 }
 
 func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, gp *core.GasPool) (error, []*types.Log) {
+	author, err := bc.Engine().Author(env.header)
+	if err != nil {
+		return err, nil
+	}
 	snap := env.state.Snapshot()
 
-	receipt, _, err := core.ApplyTransaction(env.config, bc, gp, env.state, env.header, tx, env.header.GasUsed, vm.Config{})
+	receipt, _, err := core.ApplyTransaction(env.config, bc, &author, gp, env.state, env.header, tx, env.header.GasUsed, vm.Config{})
 	if err != nil {
 		env.state.RevertToSnapshot(snap)
 		return err, nil
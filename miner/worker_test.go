@@ -0,0 +1,265 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/consensus"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/core/vm"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/event"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/pow"
+	"gopkg.in/fatih/set.v0"
+)
+
+// newParentTestWorker creates a worker over a canonical chain of the given length
+// and returns it along with the blocks of the chain.
+func newParentTestWorker(t *testing.T, length int) (*worker, []*types.Block) {
+	db, _ := ethdb.NewMemDatabase()
+	blocks := make([]*types.Block, length)
+	for i := range blocks {
+		header := &types.Header{Number: big.NewInt(int64(i)), Extra: []byte("canonical")}
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		blocks[i] = types.NewBlockWithHeader(header)
+		if err := core.WriteHeader(db, header); err != nil {
+			t.Fatalf("failed to write header %d: %v", i, err)
+		}
+		if err := core.WriteCanonicalHash(db, blocks[i].Hash(), uint64(i)); err != nil {
+			t.Fatalf("failed to write canonical hash %d: %v", i, err)
+		}
+	}
+	w := &worker{
+		chainDb:   db,
+		validated: make(map[common.Hash]time.Time),
+		banned:    make(map[common.Hash]struct{}),
+	}
+	return w, blocks
+}
+
+// Tests that in strict mode only recently validated parents are mined on.
+func TestWorkerStrictParent(t *testing.T) {
+	w, blocks := newParentTestWorker(t, 3)
+
+	// Without strict mode any parent goes, and validations aren't tracked
+	w.markValidated(blocks[1].Hash(), time.Now())
+	if err := w.checkParent(blocks[2]); err != nil {
+		t.Fatalf("lenient parent check failed: %v", err)
+	}
+	if len(w.validated) != 0 {
+		t.Fatalf("validations tracked in lenient mode: %d", len(w.validated))
+	}
+	// In strict mode, parents must have been validated recently
+	w.setStrictParent(time.Minute)
+	w.markValidated(blocks[2].Hash(), time.Now())
+	w.validated[blocks[1].Hash()] = time.Now().Add(-2 * time.Minute)
+
+	if err := w.checkParent(blocks[0]); err != errParentNotValidated {
+		t.Errorf("unvalidated parent error mismatch: have %v, want %v", err, errParentNotValidated)
+	}
+	if err := w.checkParent(blocks[1]); err != errParentStale {
+		t.Errorf("stale parent error mismatch: have %v, want %v", err, errParentStale)
+	}
+	if err := w.checkParent(blocks[2]); err != nil {
+		t.Errorf("fresh parent check failed: %v", err)
+	}
+	// Stale validations are dropped as new heads come in
+	w.markValidated(common.Hash{1}, time.Now())
+	if _, ok := w.validated[blocks[1].Hash()]; ok {
+		t.Errorf("stale validation not pruned")
+	}
+}
+
+// Tests that chains containing banned blocks are not mined on, and that banned
+// blocks and their children aren't taken as uncles.
+func TestWorkerBannedBlocks(t *testing.T) {
+	w, blocks := newParentTestWorker(t, 4)
+
+	// Ban a canonical block and a block unknown to the chain
+	w.setBanned(blocks[2].Hash(), true)
+	w.setBanned(common.Hash{0xff}, true)
+
+	for i, block := range blocks {
+		err := w.checkParent(block)
+		if i < 2 && err != nil {
+			t.Errorf("block %d: check failed: %v", i, err)
+		}
+		if i >= 2 && err != errBannedChain {
+			t.Errorf("block %d: error mismatch: have %v, want %v", i, err, errBannedChain)
+		}
+	}
+	if banned := w.bannedBlocks(); len(banned) != 2 {
+		t.Errorf("banned block count mismatch: have %d, want 2", len(banned))
+	}
+	// Banned blocks are rejected as uncles
+	work := &Work{
+		family:    set.New(),
+		uncles:    set.New(),
+		Block:     blocks[3],
+		ancestors: set.New(blocks[0].Hash(), blocks[1].Hash(), blocks[2].Hash()),
+	}
+	banned := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), ParentHash: blocks[1].Hash(), Extra: []byte("banned")})
+	sibling := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), ParentHash: blocks[1].Hash(), Extra: []byte("sibling")})
+
+	w.setBanned(banned.Hash(), true)
+	if err := w.commitUncle(work, banned.Header()); err == nil {
+		t.Errorf("banned uncle accepted")
+	}
+	if err := w.commitUncle(work, sibling.Header()); err != nil {
+		t.Errorf("uncle rejected: %v", err)
+	}
+	// Lifting the ban reopens the chain
+	w.setBanned(blocks[2].Hash(), false)
+	if err := w.checkParent(blocks[3]); err != nil {
+		t.Errorf("check failed after unban: %v", err)
+	}
+}
+
+// Tests that the pending transactions of gas free senders are separated from the
+// paying ones, so that they can be included first.
+func TestSplitGasFree(t *testing.T) {
+	var (
+		free   = common.Address{0x01}
+		paying = common.Address{0x02}
+		config = &params.ChainConfig{GasFree: &params.GasFreeConfig{Senders: []common.Address{free}}}
+	)
+	pending := map[common.Address]types.Transactions{
+		free:   {types.NewTransaction(0, paying, new(big.Int), big.NewInt(21000), new(big.Int), nil)},
+		paying: {types.NewTransaction(0, free, new(big.Int), big.NewInt(21000), big.NewInt(1), nil)},
+	}
+	freeTxs, payingTxs := splitGasFree(config, pending)
+	if len(freeTxs) != 1 || freeTxs[free] == nil {
+		t.Errorf("gas free transactions mismatch: have %v", freeTxs)
+	}
+	if len(payingTxs) != 1 || payingTxs[paying] == nil {
+		t.Errorf("paying transactions mismatch: have %v", payingTxs)
+	}
+}
+
+// newTxTestWork creates a chain with the given accounts funded in its genesis and
+// returns it along with a pending block on top of the genesis, mined by coinbase.
+func newTxTestWork(t *testing.T, config *params.ChainConfig, engine consensus.Engine, coinbase common.Address, funded ...common.Address) (*core.BlockChain, *Work) {
+	db, _ := ethdb.NewMemDatabase()
+	gspec := &core.Genesis{Config: config, Alloc: make(core.GenesisAlloc)}
+	for _, addr := range funded {
+		gspec.Alloc[addr] = core.GenesisAccount{Balance: big.NewInt(1000000000000)}
+	}
+	genesis := gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, config, engine, new(event.TypeMux), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	statedb, err := chain.StateAt(genesis.Root())
+	if err != nil {
+		t.Fatalf("failed to open genesis state: %v", err)
+	}
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		Coinbase:   coinbase,
+		GasLimit:   genesis.GasLimit(),
+		GasUsed:    new(big.Int),
+		Difficulty: big.NewInt(1),
+		Time:       big.NewInt(1),
+	}
+	work := &Work{
+		config:        config,
+		signer:        types.NewEIP155Signer(config.ChainId),
+		state:         statedb,
+		header:        header,
+		gasPool:       new(core.GasPool).AddGas(header.GasLimit),
+		ownedAccounts: set.New(),
+	}
+	return chain, work
+}
+
+// Tests that the miner leaves out the transactions of frozen addresses, which
+// would make the block invalid.
+func TestCommitFrozenTransactions(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		frozen  = common.Address{0xff}
+		allowed = common.Address{0xaa}
+		config  = &params.ChainConfig{
+			ChainId:        big.NewInt(1),
+			HomesteadBlock: new(big.Int),
+			Freeze:         &params.FreezeConfig{Block: big.NewInt(1), Frozen: []common.Address{frozen}},
+		}
+	)
+	chain, work := newTxTestWork(t, config, core.NewPowEngine(new(pow.FakePow)), common.Address{}, sender)
+
+	signer := types.HomesteadSigner{}
+	tx1, _ := types.SignTx(types.NewTransaction(0, frozen, big.NewInt(1), big.NewInt(21000), new(big.Int), nil), signer, key)
+	tx2, _ := types.SignTx(types.NewTransaction(0, allowed, big.NewInt(1), big.NewInt(21000), new(big.Int), nil), signer, key)
+
+	txs := types.NewTransactionsByPriceAndNonce(map[common.Address]types.Transactions{sender: {tx1}})
+	work.commitTransactions(new(event.TypeMux), txs, new(big.Int), chain)
+	if len(work.txs) != 0 {
+		t.Fatalf("frozen transfer included: %v", work.txs)
+	}
+	txs = types.NewTransactionsByPriceAndNonce(map[common.Address]types.Transactions{sender: {tx2}})
+	work.commitTransactions(new(event.TypeMux), txs, new(big.Int), chain)
+	if len(work.txs) != 1 || work.txs[0].Hash() != tx2.Hash() {
+		t.Fatalf("allowed transfer not included: %v", work.txs)
+	}
+}
+
+// signerPow is a non-validating proof of work implementation, that reports a
+// fixed signer as the author of all blocks.
+type signerPow struct {
+	pow.FakePow
+	signer common.Address
+}
+
+func (pow signerPow) Author(header *types.Header) (common.Address, error) {
+	return pow.signer, nil
+}
+
+// Tests that the fees of mined transactions are credited to the author reported
+// by the engine, as block processing does, instead of the coinbase.
+func TestCommitTransactionAuthor(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		coinbase = common.Address{0x01}
+		author   = common.Address{0x02}
+		config   = &params.ChainConfig{ChainId: big.NewInt(1), HomesteadBlock: new(big.Int)}
+	)
+	chain, work := newTxTestWork(t, config, core.NewPowEngine(signerPow{signer: author}), coinbase, sender)
+
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	if err, _ := work.commitTransaction(tx, chain, work.gasPool); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	if fee := work.state.GetBalance(author); fee.Cmp(big.NewInt(21000)) != 0 {
+		t.Errorf("author fee mismatch: have %v, want %v", fee, 21000)
+	}
+	if fee := work.state.GetBalance(coinbase); fee.Sign() != 0 {
+		t.Errorf("coinbase credited: %v", fee)
+	}
+}
@@ -17,6 +17,8 @@
 package node
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -25,6 +27,8 @@ import (
 	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/p2p"
 	"github.com/expanse-org/go-expanse/p2p/discover"
+	"github.com/expanse-org/go-expanse/p2p/netutil"
+	"github.com/expanse-org/go-expanse/rpc"
 	"github.com/rcrowley/go-metrics"
 )
 
@@ -73,11 +77,211 @@ func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
 	return true, nil
 }
 
-// StartRPC starts the HTTP RPC API server.
-func (api *PrivateAdminAPI) StartRPC(host *string, port *int, cors *string, apis *string) (bool, error) {
+// AddTrustedPeer allows a remote node to always connect, even if the peer slots
+// are full. The node is remembered across restarts.
+func (api *PrivateAdminAPI) AddTrustedPeer(url string) (bool, error) {
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		return false, fmt.Errorf("invalid enode: %v", err)
+	}
+	if err := api.node.SetTrustedPeer(node, true); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveTrustedPeer removes a remote node from the trusted peer set, but it
+// does not disconnect it automatically.
+func (api *PrivateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		return false, fmt.Errorf("invalid enode: %v", err)
+	}
+	if err := api.node.SetTrustedPeer(node, false); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PeerEvents creates an RPC subscription which receives peer events from the
+// node's p2p.Server, i.e. peers being added, dropped or failing to handle a
+// message.
+func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	// Create the subscription
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan *p2p.PeerEvent)
+		sub := server.SubscribeEvents(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case event := <-events:
+				notifier.Notify(rpcSub.ID, event)
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// NetRestrictions lists the IP networks the node is restricted to communicate
+// with and the ones it refuses to communicate with. A null whitelist means
+// communication isn't restricted to specific networks.
+type NetRestrictions struct {
+	Whitelist *string `json:"whitelist"` // Comma-separated CIDR masks of allowed networks
+	Denylist  string  `json:"denylist"`  // Comma-separated CIDR masks of denied networks
+}
+
+// NetRestrictions retrieves the IP networks peer discovery, dialing and inbound
+// connections are currently restricted to or denied with.
+func (api *PrivateAdminAPI) NetRestrictions() (*NetRestrictions, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	whitelist, denylist := server.NetRestrictions()
+
+	restrictions := new(NetRestrictions)
+	if whitelist != nil {
+		list := whitelist.String()
+		restrictions.Whitelist = &list
+	}
+	if denylist != nil {
+		restrictions.Denylist = denylist.String()
+	}
+	return restrictions, nil
+}
+
+// SetNetRestrictions replaces the IP networks the node may communicate with,
+// given as comma-separated CIDR masks, and disconnects peers outside of them.
+// Lists which are not specified are left unchanged, while an empty whitelist
+// lifts the restriction to specific networks.
+func (api *PrivateAdminAPI) SetNetRestrictions(whitelist *string, denylist *string) (*NetRestrictions, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	allowed, denied := server.NetRestrictions()
+	if whitelist != nil {
+		allowed = nil
+		if strings.TrimSpace(*whitelist) != "" {
+			list, err := netutil.ParseNetlist(*whitelist)
+			if err != nil {
+				return nil, fmt.Errorf("invalid whitelist: %v", err)
+			}
+			allowed = list
+		}
+	}
+	if denylist != nil {
+		list, err := netutil.ParseNetlist(*denylist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid denylist: %v", err)
+		}
+		denied = list
+	}
+	server.SetNetRestrictions(allowed, denied)
+	return api.NetRestrictions()
+}
+
+// SetMaxPeers changes the maximum number of peers of the node, rebalancing the
+// peer slots of the protocols and disconnecting the youngest peers above the new
+// limits. It returns the resulting slot usage.
+func (api *PrivateAdminAPI) SetMaxPeers(n int) (*p2p.SlotInfo, error) {
+	if err := api.node.SetMaxPeers(n); err != nil {
+		return nil, err
+	}
+	return api.node.PeerSlotInfo()
+}
+
+// ReservePeerSlots sets aside up to n peer slots for the given protocol, e.g.
+// for the light clients of a "les" server, replacing its previous reservation.
+// Peers above the new limits are disconnected. It returns the resulting slot
+// usage.
+func (api *PrivateAdminAPI) ReservePeerSlots(protocol string, n int) (*p2p.SlotInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid slot count %d", n)
+	}
+	if _, err := server.ReservePeerSlots(protocol, n); err != nil {
+		return nil, err
+	}
+	return server.SlotInfo(), nil
+}
+
+// ReloadConfig applies the settings of the node's reload file, the same way as
+// when the node receives a SIGHUP.
+func (api *PrivateAdminAPI) ReloadConfig() (bool, error) {
+	if err := api.node.Reload(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NodeKeyInfo is the public part of the node key, identifying the node on the
+// network.
+type NodeKeyInfo struct {
+	ID        string        `json:"id"`        // Node ID derived from the public key
+	Enode     string        `json:"enode"`     // Enode URL to reach the node by
+	PublicKey hexutil.Bytes `json:"publicKey"` // Uncompressed secp256k1 public key
+}
+
+// nodeKeyInfo assembles the public key information of a p2p server.
+func nodeKeyInfo(server *p2p.Server) *NodeKeyInfo {
+	info := server.NodeInfo()
+	return &NodeKeyInfo{
+		ID:        info.ID,
+		Enode:     info.Enode,
+		PublicKey: crypto.FromECDSAPub(&server.PrivateKey.PublicKey),
+	}
+}
+
+// NodeKey exports the public key of the node along with its enode URL.
+func (api *PrivateAdminAPI) NodeKey() (*NodeKeyInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return nodeKeyInfo(server), nil
+}
+
+// RotateNodeKey replaces the node key with a newly generated one, reconnecting
+// all peers under the new identity, and returns the new public key information.
+func (api *PrivateAdminAPI) RotateNodeKey() (*NodeKeyInfo, error) {
+	if _, err := api.node.RotateNodeKey(); err != nil {
+		return nil, err
+	}
+	return api.NodeKey()
+}
+
+// StartRPC starts the HTTP RPC API server. Any parameter left unspecified
+// falls back to the value the endpoint was last started with (or configured).
+func (api *PrivateAdminAPI) StartRPC(host *string, port *int, cors *string, apis *string, vhosts *string) (bool, error) {
 	api.node.lock.Lock()
 	defer api.node.lock.Unlock()
 
+	if api.node.server == nil {
+		return false, ErrNodeStopped
+	}
 	if api.node.httpHandler != nil {
 		return false, fmt.Errorf("HTTP RPC already running on %s", api.node.httpEndpoint)
 	}
@@ -90,21 +294,24 @@ func (api *PrivateAdminAPI) StartRPC(host *string, port *int, cors *string, apis
 		host = &h
 	}
 	if port == nil {
-		port = &api.node.config.HTTPPort
+		p := DefaultHTTPPort
+		if api.node.config.HTTPPort != 0 {
+			p = api.node.config.HTTPPort
+		}
+		port = &p
 	}
 	if cors == nil {
-		cors = &api.node.config.HTTPCors
+		cors = &api.node.httpCors
 	}
-
 	modules := api.node.httpWhitelist
 	if apis != nil {
-		modules = nil
-		for _, m := range strings.Split(*apis, ",") {
-			modules = append(modules, strings.TrimSpace(m))
-		}
+		modules = splitModules(*apis)
 	}
-
-	if err := api.node.startHTTP(fmt.Sprintf("%s:%d", *host, *port), api.node.rpcAPIs, modules, *cors); err != nil {
+	allowedVhosts := api.node.httpVhosts
+	if vhosts != nil {
+		allowedVhosts = splitModules(*vhosts)
+	}
+	if err := api.node.startHTTP(fmt.Sprintf("%s:%d", *host, *port), api.node.rpcAPIs, modules, *cors, allowedVhosts); err != nil {
 		return false, err
 	}
 	return true, nil
@@ -122,11 +329,15 @@ func (api *PrivateAdminAPI) StopRPC() (bool, error) {
 	return true, nil
 }
 
-// StartWS starts the websocket RPC API server.
+// StartWS starts the websocket RPC API server. Any parameter left unspecified
+// falls back to the value the endpoint was last started with (or configured).
 func (api *PrivateAdminAPI) StartWS(host *string, port *int, allowedOrigins *string, apis *string) (bool, error) {
 	api.node.lock.Lock()
 	defer api.node.lock.Unlock()
 
+	if api.node.server == nil {
+		return false, ErrNodeStopped
+	}
 	if api.node.wsHandler != nil {
 		return false, fmt.Errorf("WebSocket RPC already running on %s", api.node.wsEndpoint)
 	}
@@ -139,27 +350,26 @@ func (api *PrivateAdminAPI) StartWS(host *string, port *int, allowedOrigins *str
 		host = &h
 	}
 	if port == nil {
-		port = &api.node.config.WSPort
+		p := DefaultWSPort
+		if api.node.config.WSPort != 0 {
+			p = api.node.config.WSPort
+		}
+		port = &p
 	}
 	if allowedOrigins == nil {
-		allowedOrigins = &api.node.config.WSOrigins
+		allowedOrigins = &api.node.wsOrigins
 	}
-
-	modules := api.node.config.WSModules
+	modules := api.node.wsWhitelist
 	if apis != nil {
-		modules = nil
-		for _, m := range strings.Split(*apis, ",") {
-			modules = append(modules, strings.TrimSpace(m))
-		}
+		modules = splitModules(*apis)
 	}
-
 	if err := api.node.startWS(fmt.Sprintf("%s:%d", *host, *port), api.node.rpcAPIs, modules, *allowedOrigins); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-// StopRPC terminates an already running websocket RPC API endpoint.
+// StopWS terminates an already running websocket RPC API endpoint.
 func (api *PrivateAdminAPI) StopWS() (bool, error) {
 	api.node.lock.Lock()
 	defer api.node.lock.Unlock()
@@ -171,6 +381,58 @@ func (api *PrivateAdminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// AuditLog retrieves the newest records of the privileged RPC call audit log,
+// oldest first. If limit is unspecified or non-positive, all records are returned.
+func (api *PrivateAdminAPI) AuditLog(limit *int) ([]*AuditRecord, error) {
+	auditlog, err := api.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	if limit != nil {
+		n = *limit
+	}
+	records, err := auditlog.Records(n)
+	if records == nil {
+		records = []*AuditRecord{}
+	}
+	return records, err
+}
+
+// VerifyAuditLog checks the hash chain of the privileged RPC call audit log,
+// returning the number of intact records or the first detected tampering.
+func (api *PrivateAdminAPI) VerifyAuditLog() (int, error) {
+	auditlog, err := api.auditLog()
+	if err != nil {
+		return 0, err
+	}
+	return auditlog.Verify()
+}
+
+// auditLog retrieves the audit log of the node, or an error if it's unavailable.
+func (api *PrivateAdminAPI) auditLog() (*AuditLog, error) {
+	if api.node.Server() == nil {
+		return nil, ErrNodeStopped
+	}
+	auditlog := api.node.AuditLog()
+	if auditlog == nil {
+		return nil, errors.New("RPC call auditing disabled")
+	}
+	return auditlog, nil
+}
+
+// splitModules converts a comma separated API module list into its individual
+// entries, dropping any empty ones.
+func splitModules(apis string) []string {
+	var modules []string
+	for _, m := range strings.Split(apis, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			modules = append(modules, m)
+		}
+	}
+	return modules
+}
+
 // PublicAdminAPI is the collection of administrative API methods exposed over
 // both secure and unsecure RPC channels.
 type PublicAdminAPI struct {
@@ -203,6 +465,12 @@ func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
 	return server.NodeInfo(), nil
 }
 
+// PeerSlots retrieves the usage of the inbound and outbound connection slots,
+// and of the peer slots shared among the protocols.
+func (api *PublicAdminAPI) PeerSlots() (*p2p.SlotInfo, error) {
+	return api.node.PeerSlotInfo()
+}
+
 // Datadir retrieves the current data directory the node is using.
 func (api *PublicAdminAPI) Datadir() string {
 	return api.node.DataDir()
@@ -220,6 +488,12 @@ func NewPublicDebugAPI(node *Node) *PublicDebugAPI {
 	return &PublicDebugAPI{node: node}
 }
 
+// RpcStats retrieves the call counts, error rates and latency histograms of the
+// RPC methods served by the node, keyed by the name they were called by.
+func (api *PublicDebugAPI) RpcStats() map[string]*rpc.MethodStats {
+	return api.node.RPCStats()
+}
+
 // Metrics retrieves all the known system metric collected by the node.
 func (api *PublicDebugAPI) Metrics(raw bool) (map[string]interface{}, error) {
 	// Create a rate formatter
@@ -234,7 +508,8 @@ func (api *PublicDebugAPI) Metrics(raw bool) (map[string]interface{}, error) {
 	format := func(total float64, rate float64) string {
 		return fmt.Sprintf("%s (%s/s)", round(total, 0), round(rate, 2))
 	}
-	// Iterate over all the metrics, and just dump for now
+	// Iterate over all the metrics, and just dump for now. The default registry
+	// holds both the global metrics and the ones prefixed by the node.
 	counters := make(map[string]interface{})
 	metrics.DefaultRegistry.Each(func(name string, metric interface{}) {
 		// Create or retrieve the counter hierarchy for this metric
@@ -333,3 +608,33 @@ func (s *PublicWeb3API) ClientVersion() string {
 func (s *PublicWeb3API) Sha3(input hexutil.Bytes) hexutil.Bytes {
 	return crypto.Keccak256(input)
 }
+
+// PublicHealthAPI offers methods to query the operational health of the node.
+type PublicHealthAPI struct {
+	node *Node // Node interfaced by this API
+}
+
+// NewPublicHealthAPI creates a new API definition for the health methods of the
+// node itself.
+func NewPublicHealthAPI(node *Node) *PublicHealthAPI {
+	return &PublicHealthAPI{node: node}
+}
+
+// DiskUsage retrieves the last measured storage space usage of the data directory.
+func (api *PublicHealthAPI) DiskUsage() (*DiskUsage, error) {
+	if api.node.Server() == nil {
+		return nil, ErrNodeStopped
+	}
+	monitor := api.node.DiskMonitor()
+	if monitor == nil {
+		return nil, errors.New("ephemeral node, no data directory to monitor")
+	}
+	usage := monitor.Usage()
+	return &usage, nil
+}
+
+// LoadStatus retrieves the load conditions the RPC methods are shed upon, and the
+// method classes currently being shed.
+func (api *PublicHealthAPI) LoadStatus() *LoadStatus {
+	return api.node.LoadShedder().Status()
+}
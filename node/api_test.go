@@ -0,0 +1,346 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/p2p/discover"
+	"github.com/expanse-org/go-expanse/p2p/netutil"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// Tests that the HTTP and websocket RPC endpoints can be started and stopped at
+// runtime, and that restarts remember the previously used configuration.
+func TestAdminRPCLifeCycle(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	api := NewPrivateAdminAPI(stack)
+
+	host, port, cors, modules, vhosts := "127.0.0.1", 0, "*", "admin, web3,", "localhost, example.org"
+	if _, err := api.StartRPC(&host, &port, &cors, &modules, &vhosts); err != ErrNodeStopped {
+		t.Fatalf("start on stopped node: error mismatch: have %v, want %v", err, ErrNodeStopped)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	// Start the HTTP endpoint and ensure it can't be started twice
+	if ok, err := api.StartRPC(&host, &port, &cors, &modules, &vhosts); !ok || err != nil {
+		t.Fatalf("failed to start HTTP RPC: %v", err)
+	}
+	if _, err := api.StartRPC(&host, &port, &cors, &modules, &vhosts); err == nil {
+		t.Fatalf("duplicate HTTP RPC start succeeded")
+	}
+	if ok, err := api.StopRPC(); !ok || err != nil {
+		t.Fatalf("failed to stop HTTP RPC: %v", err)
+	}
+	if _, err := api.StopRPC(); err == nil {
+		t.Fatalf("duplicate HTTP RPC stop succeeded")
+	}
+	// Restart with defaults, the previous configuration should be retained
+	if ok, err := api.StartRPC(&host, &port, nil, nil, nil); !ok || err != nil {
+		t.Fatalf("failed to restart HTTP RPC: %v", err)
+	}
+	if want := []string{"admin", "web3"}; !reflect.DeepEqual(stack.httpWhitelist, want) {
+		t.Errorf("HTTP whitelist mismatch: have %v, want %v", stack.httpWhitelist, want)
+	}
+	if stack.httpCors != cors {
+		t.Errorf("HTTP cors mismatch: have %q, want %q", stack.httpCors, cors)
+	}
+	if want := []string{"localhost", "example.org"}; !reflect.DeepEqual(stack.httpVhosts, want) {
+		t.Errorf("HTTP vhosts mismatch: have %v, want %v", stack.httpVhosts, want)
+	}
+	// Run the same checks for the websocket endpoint
+	if ok, err := api.StartWS(&host, &port, &cors, &modules); !ok || err != nil {
+		t.Fatalf("failed to start WS RPC: %v", err)
+	}
+	if _, err := api.StartWS(&host, &port, &cors, &modules); err == nil {
+		t.Fatalf("duplicate WS RPC start succeeded")
+	}
+	if ok, err := api.StopWS(); !ok || err != nil {
+		t.Fatalf("failed to stop WS RPC: %v", err)
+	}
+	if ok, err := api.StartWS(&host, &port, nil, nil); !ok || err != nil {
+		t.Fatalf("failed to restart WS RPC: %v", err)
+	}
+	if want := []string{"admin", "web3"}; !reflect.DeepEqual(stack.wsWhitelist, want) {
+		t.Errorf("WS whitelist mismatch: have %v, want %v", stack.wsWhitelist, want)
+	}
+}
+
+// Tests that the node key can be exported and rotated at runtime, with the new
+// key persisted and the p2p server running under the new identity.
+func TestAdminNodeKeyRotation(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	stack, err := New(&Config{Name: "test node", DataDir: datadir, ListenAddr: "127.0.0.1:0", NoDiscovery: true})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	api := NewPrivateAdminAPI(stack)
+	if _, err := api.RotateNodeKey(); err != ErrNodeStopped {
+		t.Fatalf("rotation on stopped node: error mismatch: have %v, want %v", err, ErrNodeStopped)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	old, err := api.NodeKey()
+	if err != nil {
+		t.Fatalf("failed to export node key: %v", err)
+	}
+	rotated, err := api.RotateNodeKey()
+	if err != nil {
+		t.Fatalf("failed to rotate node key: %v", err)
+	}
+	if rotated.ID == old.ID || bytes.Equal(rotated.PublicKey, old.PublicKey) {
+		t.Fatalf("node identity unchanged after rotation: %s", rotated.ID)
+	}
+	if id := stack.Server().Self().ID.String(); id != rotated.ID {
+		t.Errorf("p2p server identity mismatch: have %s, want %s", id, rotated.ID)
+	}
+	if !strings.HasPrefix(rotated.Enode, "enode://"+rotated.ID) {
+		t.Errorf("enode mismatch: have %s, want ID %s", rotated.Enode, rotated.ID)
+	}
+	key, err := crypto.LoadECDSA(stack.config.resolvePath(datadirPrivateKey))
+	if err != nil {
+		t.Fatalf("failed to load persisted node key: %v", err)
+	}
+	if pub := crypto.FromECDSAPub(&key.PublicKey); !bytes.Equal(pub, rotated.PublicKey) {
+		t.Errorf("persisted key mismatch: have %x, want %x", pub, rotated.PublicKey)
+	}
+}
+
+// Tests that explicitly configured node keys are not rotated.
+func TestAdminNodeKeyRotationConfigured(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	if _, err := NewPrivateAdminAPI(stack).RotateNodeKey(); err == nil {
+		t.Fatalf("configured node key rotated")
+	}
+	if id := stack.Server().Self().ID; id != discover.PubkeyID(&testNodeKey.PublicKey) {
+		t.Errorf("node identity changed: have %x, want %x", id, discover.PubkeyID(&testNodeKey.PublicKey))
+	}
+}
+
+// Tests that the network restrictions can be inspected and replaced through the
+// admin API, and that replaced lists survive restarts of the p2p server.
+func TestAdminNetRestrictions(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	whitelist, _ := netutil.ParseNetlist("10.0.0.0/8")
+	stack, err := New(&Config{Name: "test node", DataDir: datadir, ListenAddr: "127.0.0.1:0", NoDiscovery: true, NetRestrict: whitelist})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	api := NewPrivateAdminAPI(stack)
+	if _, err := api.NetRestrictions(); err != ErrNodeStopped {
+		t.Fatalf("restrictions of stopped node: error mismatch: have %v, want %v", err, ErrNodeStopped)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	check := func(have *NetRestrictions, whitelist *string, denylist string) {
+		if (have.Whitelist == nil) != (whitelist == nil) || (whitelist != nil && *have.Whitelist != *whitelist) {
+			t.Errorf("whitelist mismatch: have %v, want %v", have.Whitelist, whitelist)
+		}
+		if have.Denylist != denylist {
+			t.Errorf("denylist mismatch: have %q, want %q", have.Denylist, denylist)
+		}
+	}
+	restrictions, err := api.NetRestrictions()
+	if err != nil {
+		t.Fatalf("failed to retrieve restrictions: %v", err)
+	}
+	configured := "10.0.0.0/8"
+	check(restrictions, &configured, "")
+
+	// Lift the whitelist and deny a network instead
+	lift, deny := "", "1.2.3.0/24, 4.5.0.0/16"
+	if restrictions, err = api.SetNetRestrictions(&lift, &deny); err != nil {
+		t.Fatalf("failed to set restrictions: %v", err)
+	}
+	check(restrictions, nil, "1.2.3.0/24,4.5.0.0/16")
+
+	// Invalid lists should be rejected without changing anything
+	invalid := "1.2.3.4/44"
+	if _, err := api.SetNetRestrictions(nil, &invalid); err == nil {
+		t.Errorf("invalid denylist accepted")
+	}
+	if _, err := api.RotateNodeKey(); err != nil {
+		t.Fatalf("failed to restart p2p server: %v", err)
+	}
+	if restrictions, err = api.SetNetRestrictions(nil, nil); err != nil {
+		t.Fatalf("failed to retrieve restrictions: %v", err)
+	}
+	check(restrictions, nil, "1.2.3.0/24,4.5.0.0/16")
+}
+
+// Tests that trusted peers can be added and removed at runtime through the admin
+// API, and that the changes are persisted into the data directory.
+func TestAdminTrustedPeers(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	stack, err := New(&Config{Name: "test node", DataDir: datadir, ListenAddr: "127.0.0.1:0", NoDiscovery: true})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	api := NewPrivateAdminAPI(stack)
+
+	first := "enode://a979fb575495b8d6db44f750317d0f4622bf4c2aa3365d6af7c284339968eef29b69ad0dce72a4d8db5ebb4968de0e3bec910127f134779fbcb0cb6d3331163c@52.16.188.185:42786"
+	second := "enode://3f1d12044546b76342d59d4a05532c14b85aa669704bfe1f864fe079415aa2c02d743e03218e57a33fb94523adb54032871a6c51b2cc5514cb7c7e35b3ed0a99@13.93.211.84:42786"
+	if _, err := api.AddTrustedPeer(first); err != ErrNodeStopped {
+		t.Fatalf("trusting on stopped node: error mismatch: have %v, want %v", err, ErrNodeStopped)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	if _, err := api.AddTrustedPeer("enode://invalid"); err == nil {
+		t.Errorf("invalid enode accepted")
+	}
+	for _, url := range []string{first, second} {
+		if ok, err := api.AddTrustedPeer(url); !ok || err != nil {
+			t.Fatalf("failed to add trusted peer: %v", err)
+		}
+	}
+	if ok, err := api.RemoveTrustedPeer(first); !ok || err != nil {
+		t.Fatalf("failed to remove trusted peer: %v", err)
+	}
+	check := func(nodes []*discover.Node) {
+		if len(nodes) != 1 || nodes[0].String() != second {
+			t.Errorf("trusted nodes mismatch: have %v, want [%s]", nodes, second)
+		}
+	}
+	check(stack.config.TrusterNodes())
+
+	// Restart the node and ensure the trusted peers are loaded back
+	if err := stack.Restart(); err != nil {
+		t.Fatalf("failed to restart node: %v", err)
+	}
+	check(stack.Server().TrustedNodes)
+}
+
+// Tests that the peer limit and the slot reservations can be changed at runtime.
+func TestAdminPeerLimits(t *testing.T) {
+	stack, err := New(&Config{Name: "test node", PrivateKey: testNodeKey, ListenAddr: "127.0.0.1:0", NoDiscovery: true, MaxPeers: 10})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	api := NewPrivateAdminAPI(stack)
+	if _, err := api.SetMaxPeers(5); err != ErrNodeStopped {
+		t.Fatalf("limit on stopped node: error mismatch: have %v, want %v", err, ErrNodeStopped)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	info, err := api.ReservePeerSlots("les", 4)
+	if err != nil {
+		t.Fatalf("failed to reserve slots: %v", err)
+	}
+	if limit := info.Protocols["les"].Limit; limit != 4 {
+		t.Errorf("reserved slots mismatch: have %d, want %d", limit, 4)
+	}
+	// Lowering the limit should shrink the reservation, raising it regrant it
+	if info, err = api.SetMaxPeers(6); err != nil {
+		t.Fatalf("failed to lower peer limit: %v", err)
+	}
+	if info.MaxPeers != 6 || info.Protocols["les"].Limit != 3 {
+		t.Errorf("lowered limits mismatch: have %d peers, %d les slots, want 6 and 3", info.MaxPeers, info.Protocols["les"].Limit)
+	}
+	if info, err = api.SetMaxPeers(20); err != nil {
+		t.Fatalf("failed to raise peer limit: %v", err)
+	}
+	if info.MaxPeers != 20 || info.Protocols["les"].Limit != 4 {
+		t.Errorf("raised limits mismatch: have %d peers, %d les slots, want 20 and 4", info.MaxPeers, info.Protocols["les"].Limit)
+	}
+	if _, err := api.SetMaxPeers(0); err == nil {
+		t.Errorf("zero peer limit accepted")
+	}
+	if stack.config.MaxPeers != 20 {
+		t.Errorf("configured limit mismatch: have %d, want %d", stack.config.MaxPeers, 20)
+	}
+}
+
+// Tests that the metrics of a node with a metrics prefix are reported along with
+// the global ones outside of it.
+func TestDebugMetricsPrefixed(t *testing.T) {
+	config := testNodeConfig()
+	config.MetricsPrefix = "prefixed/"
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	gometrics.NewRegisteredMeter("test/meter", stack.Metrics())
+	gometrics.NewRegisteredMeter("global/test/meter", nil)
+	defer gometrics.DefaultRegistry.Unregister("prefixed/test/meter")
+	defer gometrics.DefaultRegistry.Unregister("global/test/meter")
+
+	counters, err := NewPublicDebugAPI(stack).Metrics(true)
+	if err != nil {
+		t.Fatalf("failed to retrieve metrics: %v", err)
+	}
+	for _, path := range [][]string{{"prefixed", "test", "meter"}, {"global", "test", "meter"}} {
+		root := counters
+		for _, part := range path[:len(path)-1] {
+			child, ok := root[part].(map[string]interface{})
+			if !ok {
+				t.Fatalf("metric %s missing", strings.Join(path, "/"))
+			}
+			root = child
+		}
+		if _, ok := root[path[len(path)-1]]; !ok {
+			t.Errorf("metric %s missing", strings.Join(path, "/"))
+		}
+	}
+}
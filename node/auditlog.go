@@ -0,0 +1,368 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/crypto"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+const (
+	maxAuditResult   = 1024            // Maximum encoded size of a result recorded verbatim
+	maxAuditLineSize = 4 * 1024 * 1024 // Maximum size of a record line accepted when reading the log
+	auditRedacted    = "REDACTED"      // Placeholder of the secrets in audited parameters
+)
+
+// DefaultAuditedMethods are the privileged RPC methods recorded into the audit
+// log, a trailing '*' matching any suffix.
+var DefaultAuditedMethods = []string{"admin_*", "miner_*", "personal_*", "debug_set*"}
+
+// AuditedSecrets lists the positions of the parameters carrying secrets (keys,
+// passphrases) of the audited methods, which are redacted from the audit log as
+// a whole, whatever their type. Audited methods taking secrets must be listed.
+var AuditedSecrets = map[string][]int{
+	"personal_openWallet":             {1},    // url, passphrase
+	"personal_newAccount":             {0},    // password
+	"personal_importRawKey":           {0, 1}, // privkey, password
+	"personal_unlockAccount":          {1},    // addr, password, duration
+	"personal_sendTransaction":        {1},    // args, passwd
+	"personal_signAndSendTransaction": {1},    // args, passwd
+	"personal_sign":                   {2},    // data, addr, passwd
+}
+
+// AuditEntry is a single privileged RPC call recorded into the audit log.
+type AuditEntry struct {
+	Time      time.Time         `json:"time"`             // Wall clock time the call finished at
+	Transport string            `json:"transport"`        // Endpoint the call was served through (inproc, ipc, http, ws)
+	Method    string            `json:"method"`           // Canonical name of the called method
+	Params    []json.RawMessage `json:"params"`           // Parameters of the call, secrets redacted
+	Result    json.RawMessage   `json:"result,omitempty"` // Result of the call, summarized if too large
+	Error     string            `json:"error,omitempty"`  // Error returned by the call, if any
+	Prev      common.Hash       `json:"prev"`             // Hash of the preceding record in the log
+}
+
+// AuditRecord is a line of the audit log, holding an encoded entry along with its
+// hash. As every entry contains the hash of its predecessor, the records form a
+// chain in which any modification or removal is detectable.
+type AuditRecord struct {
+	Hash  common.Hash     `json:"hash"`  // Keccak256 hash of the encoded entry
+	Entry json.RawMessage `json:"entry"` // JSON encoded AuditEntry
+}
+
+// Decode parses the entry of the record, verifying it against the record hash.
+func (r *AuditRecord) Decode() (*AuditEntry, error) {
+	if hash := crypto.Keccak256Hash(r.Entry); hash != r.Hash {
+		return nil, fmt.Errorf("hash mismatch: have %x, want %x", hash, r.Hash)
+	}
+	entry := new(AuditEntry)
+	if err := json.Unmarshal(r.Entry, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// AuditLog is an append-only, hash chained log file of the privileged RPC calls
+// served by the node. Once it grows above its size limit, the file is rotated to
+// path.1, shifting the older rotations up by one, and a new file continuing the
+// chain is started.
+type AuditLog struct {
+	path     string // Path of the live log file
+	maxSize  uint64 // Size in bytes above which the log is rotated
+	maxFiles int    // Number of rotated files to keep (0 = all)
+
+	file *os.File    // Live log file opened for appending
+	size uint64      // Current size of the live log file
+	head common.Hash // Hash of the last record, chained into the next one
+	lock sync.Mutex
+}
+
+// OpenAuditLog opens the audit log at path for appending, creating it if needed,
+// and resumes its hash chain from the last record logged.
+func OpenAuditLog(path string, maxSize uint64, maxFiles int) (*AuditLog, error) {
+	if maxSize == 0 {
+		maxSize = DefaultAuditLogMaxSize
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	l := &AuditLog{path: path, maxSize: maxSize, maxFiles: maxFiles}
+
+	// Resume the chain from the live file, or the newest rotation if it's empty
+	for _, file := range []string{path, l.rotated(1)} {
+		records, err := readAuditRecords(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 {
+			l.head = records[len(records)-1].Hash
+			break
+		}
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	l.file, l.size = file, uint64(stat.Size())
+	return l, nil
+}
+
+// rotated returns the path of the n-th newest rotated log file.
+func (l *AuditLog) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// readAuditRecords parses all the records of a log file, returning none if the
+// file doesn't exist.
+func readAuditRecords(path string) ([]*AuditRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []*AuditRecord
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxAuditLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		record := new(AuditRecord)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, fmt.Errorf("%s:%d: corrupt audit record: %v", path, line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Append chains the entry to the last record of the log and writes it out, rotating
+// the log file beforehand if it would grow too large.
+func (l *AuditLog) Append(entry *AuditEntry) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		return errors.New("audit log closed")
+	}
+	entry.Prev = l.head
+	blob, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	record := &AuditRecord{Hash: crypto.Keccak256Hash(blob), Entry: blob}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if l.size > 0 && l.size+uint64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := l.file.Write(line); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.size += uint64(len(line))
+	l.head = record.Hash
+	return nil
+}
+
+// rotate moves the live log file to the first rotation slot, shifting the older
+// ones and dropping those beyond the retention limit, and starts a new file.
+func (l *AuditLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+
+	// Count the existing rotations and drop those falling out of retention
+	count := 0
+	for common.FileExist(l.rotated(count + 1)) {
+		count++
+	}
+	for ; l.maxFiles > 0 && count >= l.maxFiles; count-- {
+		if err := os.Remove(l.rotated(count)); err != nil {
+			return err
+		}
+	}
+	for i := count; i > 0; i-- {
+		if err := os.Rename(l.rotated(i), l.rotated(i+1)); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(l.path, l.rotated(1)); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	l.file, l.size = file, 0
+	return nil
+}
+
+// files returns the paths of the existing log files, oldest first.
+func (l *AuditLog) files() []string {
+	var files []string
+	for i := 1; common.FileExist(l.rotated(i)); i++ {
+		files = append([]string{l.rotated(i)}, files...)
+	}
+	return append(files, l.path)
+}
+
+// Records retrieves the newest records of the log, oldest first. A non-positive
+// limit retrieves all of them.
+func (l *AuditLog) Records(limit int) ([]*AuditRecord, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	files := l.files()
+
+	var records []*AuditRecord
+	for i := len(files) - 1; i >= 0 && (limit <= 0 || len(records) < limit); i-- {
+		batch, err := readAuditRecords(files[i])
+		if err != nil {
+			return nil, err
+		}
+		records = append(batch, records...)
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return records, nil
+}
+
+// Verify checks the hash chain of the log across all retained files, returning the
+// number of records verified or an error describing the first broken link. The
+// oldest retained record is trusted to link to a rotated out predecessor.
+func (l *AuditLog) Verify() (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var (
+		count int
+		prev  *common.Hash
+	)
+	for _, file := range l.files() {
+		records, err := readAuditRecords(file)
+		if err != nil {
+			return count, err
+		}
+		for i, record := range records {
+			entry, err := record.Decode()
+			if err != nil {
+				return count, fmt.Errorf("%s:%d: %v", file, i+1, err)
+			}
+			if prev != nil && entry.Prev != *prev {
+				return count, fmt.Errorf("%s:%d: broken chain: have parent %x, want %x", file, i+1, entry.Prev, *prev)
+			}
+			prev = &record.Hash
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Close flushes and closes the live log file.
+func (l *AuditLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// rpcAuditor implements rpc.CallAuditor, recording the privileged calls served
+// through a single RPC endpoint into the audit log.
+type rpcAuditor struct {
+	log       *AuditLog
+	transport string
+}
+
+// Audited implements rpc.CallAuditor, selecting the privileged methods.
+func (a *rpcAuditor) Audited(method string) bool {
+	return matchMethod(DefaultAuditedMethods, method)
+}
+
+// Audit implements rpc.CallAuditor, appending the call to the audit log. Failures
+// are only logged, as the call was already executed.
+func (a *rpcAuditor) Audit(method string, args []interface{}, result interface{}, err error) {
+	entry := &AuditEntry{
+		Time:      time.Now().UTC(),
+		Transport: a.transport,
+		Method:    method,
+		Params:    make([]json.RawMessage, len(args)),
+	}
+	secrets := make(map[int]bool)
+	for _, pos := range AuditedSecrets[method] {
+		secrets[pos] = true
+	}
+	for i, arg := range args {
+		if secrets[i] {
+			entry.Params[i] = auditValue(auditRedacted)
+			continue
+		}
+		entry.Params[i] = auditValue(arg)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else if result != nil {
+		entry.Result = auditValue(result)
+		if len(entry.Result) > maxAuditResult {
+			entry.Result = auditValue(fmt.Sprintf("%d bytes omitted", len(entry.Result)))
+		}
+	}
+	if err := a.log.Append(entry); err != nil {
+		log.Error("Failed to audit RPC call", "method", method, "err", err)
+	}
+}
+
+// auditValue encodes a parameter or result for the audit log, falling back to its
+// textual representation if it can't be JSON encoded.
+func auditValue(v interface{}) json.RawMessage {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		blob, _ = json.Marshal(fmt.Sprintf("%v", v))
+	}
+	return blob
+}
@@ -0,0 +1,345 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/internal/ethapi"
+)
+
+// Tests that the audit log chains its records across reopens and rotations, and
+// that it retains the configured number of rotated files.
+func TestAuditLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit", "rpc.log")
+	auditlog, err := OpenAuditLog(path, 512, 2)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	auditor := &rpcAuditor{log: auditlog, transport: "ipc"}
+	for i := 0; i < 5; i++ {
+		auditor.Audit("admin_addPeer", []interface{}{"enode://peer"}, true, nil)
+	}
+	// Reopen the log and ensure the chain is resumed
+	auditlog.Close()
+	if auditlog, err = OpenAuditLog(path, 512, 2); err != nil {
+		t.Fatalf("failed to reopen audit log: %v", err)
+	}
+	defer auditlog.Close()
+
+	auditor = &rpcAuditor{log: auditlog, transport: "http"}
+	for i := 0; i < 10; i++ {
+		auditor.Audit("miner_stop", nil, true, nil)
+	}
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Fatalf("log not rotated: %v", err)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("retention limit exceeded: %v", err)
+	}
+	count, err := auditlog.Verify()
+	if err != nil {
+		t.Fatalf("failed to verify audit log: %v", err)
+	}
+	all, err := auditlog.Records(0)
+	if err != nil {
+		t.Fatalf("failed to retrieve records: %v", err)
+	}
+	if len(all) != count || count == 0 || count >= 15 {
+		t.Fatalf("record count mismatch: verified %d, retrieved %d", count, len(all))
+	}
+	// Ensure the newest records are returned, oldest first
+	records, err := auditlog.Records(3)
+	if err != nil {
+		t.Fatalf("failed to retrieve records: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("limited record count mismatch: have %d, want 3", len(records))
+	}
+	for i, record := range records {
+		if record.Hash != all[len(all)-3+i].Hash {
+			t.Errorf("record %d: hash mismatch: have %x, want %x", i, record.Hash, all[len(all)-3+i].Hash)
+		}
+		entry, err := record.Decode()
+		if err != nil {
+			t.Fatalf("record %d: failed to decode: %v", i, err)
+		}
+		if entry.Method != "miner_stop" || entry.Transport != "http" {
+			t.Errorf("record %d: entry mismatch: %+v", i, entry)
+		}
+		if i > 0 && entry.Prev != records[i-1].Hash {
+			t.Errorf("record %d: parent mismatch: have %x, want %x", i, entry.Prev, records[i-1].Hash)
+		}
+	}
+}
+
+// Tests that modifying or removing audit records is detected by verification.
+func TestAuditLogTampering(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rpc.log")
+	auditlog, err := OpenAuditLog(path, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer auditlog.Close()
+
+	auditor := &rpcAuditor{log: auditlog, transport: "ipc"}
+	auditor.Audit("admin_addPeer", []interface{}{"enode://a"}, true, nil)
+	auditor.Audit("admin_addPeer", []interface{}{"enode://b"}, true, nil)
+	auditor.Audit("admin_addPeer", []interface{}{"enode://c"}, true, nil)
+
+	if count, err := auditlog.Verify(); err != nil || count != 3 {
+		t.Fatalf("intact log verification failed: count %d, error %v", count, err)
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	lines := strings.SplitAfter(string(blob), "\n")
+
+	// Modify the parameters of a record
+	if err := ioutil.WriteFile(path, []byte(strings.Replace(string(blob), "enode://b", "enode://x", 1)), 0600); err != nil {
+		t.Fatalf("failed to tamper with audit log: %v", err)
+	}
+	if _, err := auditlog.Verify(); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Errorf("modified record not detected: %v", err)
+	}
+	// Remove a record from the middle
+	if err := ioutil.WriteFile(path, []byte(lines[0]+lines[2]), 0600); err != nil {
+		t.Fatalf("failed to tamper with audit log: %v", err)
+	}
+	if _, err := auditlog.Verify(); err == nil || !strings.Contains(err.Error(), "broken chain") {
+		t.Errorf("removed record not detected: %v", err)
+	}
+}
+
+// Tests that the auditor redacts the secrets of personal methods and summarizes
+// large results.
+func TestAuditorRedaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	auditlog, err := OpenAuditLog(filepath.Join(dir, "rpc.log"), 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer auditlog.Close()
+
+	auditor := &rpcAuditor{log: auditlog, transport: "inproc"}
+	if !auditor.Audited("personal_unlockAccount") || !auditor.Audited("debug_setHead") || auditor.Audited("debug_traceTransaction") || auditor.Audited("eth_blockNumber") {
+		t.Fatalf("privileged method selection mismatch")
+	}
+	duration := uint64(300)
+	auditor.Audit("personal_unlockAccount", []interface{}{[20]byte{1}, "secret", &duration}, nil, errors.New("could not decrypt key"))
+	auditor.Audit("admin_peers", nil, strings.Repeat("x", 2*maxAuditResult), nil)
+
+	records, err := auditlog.Records(0)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("failed to retrieve records: %v (%d records)", err, len(records))
+	}
+	if strings.Contains(string(records[0].Entry), "secret") {
+		t.Errorf("passphrase leaked into audit log: %s", records[0].Entry)
+	}
+	entry, _ := records[0].Decode()
+	if len(entry.Params) != 3 || string(entry.Params[1]) != `"REDACTED"` || string(entry.Params[2]) != "300" {
+		t.Errorf("parameter mismatch: %s", entry.Params)
+	}
+	if entry.Error != "could not decrypt key" || entry.Result != nil {
+		t.Errorf("outcome mismatch: result %s, error %q", entry.Result, entry.Error)
+	}
+	entry, _ = records[1].Decode()
+	var summary string
+	if err := json.Unmarshal(entry.Result, &summary); err != nil || summary != "2050 bytes omitted" {
+		t.Errorf("result summary mismatch: %s", entry.Result)
+	}
+}
+
+// Tests that the secret parameters of every audited method taking any are kept out
+// of the audit log, whereas their other parameters are recorded.
+func TestAuditorSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	auditlog, err := OpenAuditLog(filepath.Join(dir, "rpc.log"), 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer auditlog.Close()
+
+	var (
+		auditor    = &rpcAuditor{log: auditlog, transport: "ipc"}
+		addr       = common.ChecksumAddress(common.HexToAddress("0x1234567890123456789012345678901234567890"))
+		passphrase = "secret passphrase"
+		duration   = uint64(300)
+		txargs     = ethapi.SendTxArgs{From: addr, To: &addr}
+	)
+	tests := []struct {
+		method string
+		args   []interface{}
+		public string // Non-secret parameter expected in the log
+	}{
+		{"personal_openWallet", []interface{}{"ledger://public-url", &passphrase}, "ledger://public-url"},
+		{"personal_newAccount", []interface{}{passphrase}, ""},
+		{"personal_importRawKey", []interface{}{"secret raw key", passphrase}, ""},
+		{"personal_unlockAccount", []interface{}{addr, passphrase, &duration}, "0x1234567890123456789012345678901234567890"},
+		{"personal_sendTransaction", []interface{}{txargs, passphrase}, "0x1234567890123456789012345678901234567890"},
+		{"personal_signAndSendTransaction", []interface{}{txargs, passphrase}, "0x1234567890123456789012345678901234567890"},
+		{"personal_sign", []interface{}{hexutil.Bytes("public data"), addr, passphrase}, "0x1234567890123456789012345678901234567890"},
+	}
+	for _, tt := range tests {
+		if len(AuditedSecrets[tt.method]) == 0 {
+			t.Errorf("%s: no secrets listed", tt.method)
+		}
+		auditor.Audit(tt.method, tt.args, nil, nil)
+	}
+	auditor.Audit("admin_addPeer", []interface{}{"enode://public"}, true, nil)
+
+	records, err := auditlog.Records(0)
+	if err != nil || len(records) != len(tests)+1 {
+		t.Fatalf("failed to retrieve records: %v (%d records)", err, len(records))
+	}
+	for i, tt := range tests {
+		entry := string(records[i].Entry)
+		if strings.Contains(entry, "secret") {
+			t.Errorf("%s: secret leaked into audit log: %s", tt.method, entry)
+		}
+		if !strings.Contains(entry, `"REDACTED"`) {
+			t.Errorf("%s: redaction placeholder missing: %s", tt.method, entry)
+		}
+		if !strings.Contains(strings.ToLower(entry), tt.public) {
+			t.Errorf("%s: public parameter %q missing: %s", tt.method, tt.public, entry)
+		}
+	}
+	if entry := string(records[len(tests)].Entry); !strings.Contains(entry, "enode://public") {
+		t.Errorf("unlisted method redacted: %s", entry)
+	}
+}
+
+// Tests that every personal method is either known to carry no secrets or has
+// its secret parameters listed, so new ones can't leak into the audit log.
+func TestAuditedSecretsComplete(t *testing.T) {
+	public := map[string]bool{
+		"personal_listAccounts":  true,
+		"personal_listWallets":   true,
+		"personal_deriveAccount": true,
+		"personal_lockAccount":   true,
+		"personal_ecRecover":     true,
+	}
+	typ := reflect.TypeOf(new(ethapi.PrivateAccountAPI))
+	for i := 0; i < typ.NumMethod(); i++ {
+		name := []rune(typ.Method(i).Name)
+		name[0] = unicode.ToLower(name[0])
+		method := "personal_" + string(name)
+
+		if !public[method] && len(AuditedSecrets[method]) == 0 {
+			t.Errorf("%s: neither public nor secrets listed", method)
+		}
+		params := typ.Method(i).Type.NumIn() - 1 // receiver
+		if params > 0 && typ.Method(i).Type.In(1) == reflect.TypeOf((*context.Context)(nil)).Elem() {
+			params--
+		}
+		for _, pos := range AuditedSecrets[method] {
+			if pos >= params {
+				t.Errorf("%s: secret position %d beyond %d parameters", method, pos, params)
+			}
+		}
+	}
+}
+
+// Tests that a node with auditing enabled records the privileged calls served
+// through its endpoints, and serves them back via the admin API.
+func TestNodeAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testNodeConfig()
+	config.DataDir = dir
+	config.AuditLog = "audit.log"
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	client, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach to node: %v", err)
+	}
+	defer client.Close()
+
+	var info NodeKeyInfo
+	if err := client.Call(&info, "admin_nodeKey"); err != nil {
+		t.Fatalf("failed to retrieve node key: %v", err)
+	}
+	var version string
+	if err := client.Call(&version, "web3_clientVersion"); err != nil {
+		t.Fatalf("failed to retrieve client version: %v", err)
+	}
+	var records []*AuditRecord
+	if err := client.Call(&records, "admin_auditLog", nil); err != nil {
+		t.Fatalf("failed to retrieve audit log: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("audit record count mismatch: have %d, want 1", len(records))
+	}
+	entry, err := records[0].Decode()
+	if err != nil {
+		t.Fatalf("failed to decode audit record: %v", err)
+	}
+	if entry.Method != "admin_nodeKey" || entry.Transport != "inproc" {
+		t.Errorf("audit entry mismatch: %+v", entry)
+	}
+	var count int
+	if err := client.Call(&count, "admin_verifyAuditLog"); err != nil || count != 2 {
+		t.Errorf("verification mismatch: count %d, error %v", count, err)
+	}
+}
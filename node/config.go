@@ -18,6 +18,7 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -25,11 +26,13 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/expanse-org/go-expanse/accounts"
 	"github.com/expanse-org/go-expanse/accounts/keystore"
 	"github.com/expanse-org/go-expanse/accounts/usbwallet"
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
 	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/p2p/discover"
@@ -109,6 +112,10 @@ type Config struct {
 	// The whitelist only applies when non-nil.
 	NetRestrict *netutil.Netlist
 
+	// Deny communication with black listed IP networks, even if they are
+	// white listed by NetRestrict.
+	NetDeny *netutil.Netlist
+
 	// BootstrapNodes used to establish connectivity with the rest of the network.
 	BootstrapNodes []*discover.Node
 
@@ -130,6 +137,11 @@ type Config struct {
 	// If NoDial is true, the node will not dial any peers.
 	NoDial bool
 
+	// BootnodeMode runs only the peer discovery protocols, turning the node into
+	// a bootstrap node of the network. No services running protocols may be
+	// registered in this mode.
+	BootnodeMode bool
+
 	// MaxPeers is the maximum number of peers that can be connected. If this is
 	// set to zero, then only the configured static and trusted peers can connect.
 	MaxPeers int
@@ -153,6 +165,12 @@ type Config struct {
 	// useless for custom HTTP clients.
 	HTTPCors string
 
+	// HTTPVirtualHosts is the list of virtual hostnames which are allowed on incoming
+	// requests. Requests addressed by IP are always accepted, a "*" entry accepts any
+	// hostname. This protects the endpoint against DNS rebinding attacks; if the list
+	// is nil, only DefaultHTTPVirtualHosts are accepted.
+	HTTPVirtualHosts []string
+
 	// HTTPModules is a list of API modules to expose via the HTTP RPC interface.
 	// If the module list is empty, all RPC API endpoints designated public will be
 	// exposed.
@@ -176,6 +194,77 @@ type Config struct {
 	// If the module list is empty, all RPC API endpoints designated public will be
 	// exposed.
 	WSModules []string
+
+	// RPCSlowThreshold is the execution time above which RPC calls served through
+	// any endpoint are logged as slow. Zero disables logging slow calls.
+	RPCSlowThreshold time.Duration
+
+	// RPCMaxSubscriptions is the maximum number of concurrent subscriptions of a
+	// single IPC or websocket connection. Zero means unlimited.
+	RPCMaxSubscriptions int
+
+	// RPCNotificationBuffer is the maximum number of notifications queued for a
+	// single connection not reading them fast enough. Zero defaults to a preset
+	// value.
+	RPCNotificationBuffer int
+
+	// RPCDropNotifications makes connections overflowing their notification buffer
+	// lose their oldest notifications instead of being disconnected.
+	RPCDropNotifications bool
+
+	// RPCLoadShedding is the policy rejecting expensive RPC calls with a retriable
+	// error while the node is syncing or under memory or disk pressure. If nil, no
+	// calls are rejected.
+	RPCLoadShedding *LoadSheddingConfig
+
+	// AuditLog is the file to record the privileged RPC calls (admin, miner,
+	// personal and debug setters) served through any endpoint into, as a hash
+	// chained append-only log. Relative paths are resolved within the instance
+	// directory. If empty, privileged calls are not audited.
+	AuditLog string
+
+	// AuditLogMaxSize is the size in bytes above which the audit log is rotated.
+	// Zero defaults to DefaultAuditLogMaxSize.
+	AuditLogMaxSize uint64
+
+	// AuditLogMaxFiles is the number of rotated audit log files to retain. Zero
+	// retains all of them.
+	AuditLogMaxFiles int
+
+	// Systemd integrates the node with the systemd service manager. The IPC, HTTP
+	// and WebSocket endpoints take over the listener sockets passed by socket
+	// activation, matched by their FileDescriptorName= ("ipc", "http" or "ws") or
+	// by their address, and the service manager is notified once the node is
+	// ready and when it is stopping (Type=notify units).
+	Systemd bool
+
+	// ReloadFile is the JSON file holding the settings which are applied without a
+	// restart when the node receives a SIGHUP (see ReloadConfig). Empty disables
+	// configuration reloads.
+	ReloadFile string
+
+	// ServiceStopTimeout is the time the node waits for each service and each of
+	// their background processes to stop before giving up on it, reporting the
+	// timeout as its stop error. Zero waits indefinitely.
+	ServiceStopTimeout time.Duration
+
+	// DiskWarnThreshold is the free space (in bytes) on the data directory's file
+	// system below which the user is warned. Zero defaults to a preset value.
+	DiskWarnThreshold uint64
+
+	// DiskCriticalThreshold is the free space (in bytes) on the data directory's
+	// file system below which disk hungry subsystems (fast sync state writes and
+	// DAG generation) are paused. Zero defaults to a preset value.
+	DiskCriticalThreshold uint64
+
+	// MetricsPrefix is prepended to the name of every metric reported by the node
+	// and its services, allowing multiple nodes to run within the same process
+	// (e.g. tests, simulators) without their metrics colliding.
+	MetricsPrefix string
+
+	// Clock is the monotonic time source handed to all registered services. If
+	// it's nil, the system clock is used; tests may inject a simulated one.
+	Clock mclock.Clock
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -369,6 +458,37 @@ func (c *Config) TrusterNodes() []*discover.Node {
 	return c.parsePersistentNodes(c.resolvePath(datadirTrustedNodes))
 }
 
+// updateTrustedNodes adds the given node to, or removes it from, the trusted node
+// list within the data directory.
+func (c *Config) updateTrustedNodes(node *discover.Node, trusted bool) error {
+	nodes := updateNodeList(c.TrusterNodes(), node, trusted)
+
+	urls := make([]string, len(nodes))
+	for i, n := range nodes {
+		urls[i] = n.String()
+	}
+	blob, err := json.MarshalIndent(urls, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.resolvePath(datadirTrustedNodes), blob, 0644)
+}
+
+// updateNodeList returns a copy of the node list with the given node added or
+// removed. Nodes are identified by their IDs.
+func updateNodeList(nodes []*discover.Node, node *discover.Node, add bool) []*discover.Node {
+	list := make([]*discover.Node, 0, len(nodes)+1)
+	for _, n := range nodes {
+		if n.ID != node.ID {
+			list = append(list, n)
+		}
+	}
+	if add {
+		list = append(list, node)
+	}
+	return list
+}
+
 // parsePersistentNodes parses a list of discovery node URLs loaded from a .json
 // file from within the data directory.
 func (c *Config) parsePersistentNodes(path string) []*discover.Node {
@@ -445,5 +565,10 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 	} else {
 		backends = append(backends, ledgerhub)
 	}
+	if trezorhub, err := usbwallet.NewTrezorHub(); err != nil {
+		log.Warn(fmt.Sprintf("Failed to start Trezor hub, disabling: %v", err))
+	} else {
+		backends = append(backends, trezorhub)
+	}
 	return accounts.NewManager(backends...), ephemeral, nil
 }
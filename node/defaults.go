@@ -29,8 +29,17 @@ const (
 	DefaultHTTPPort  = 9656        // Default TCP port for the HTTP RPC server
 	DefaultWSHost    = "localhost" // Default host interface for the websocket RPC server
 	DefaultWSPort    = 8546        // Default TCP port for the websocket RPC server
+
+	DefaultDiskWarnThreshold     = 4 * 1024 * 1024 * 1024 // Default free space (bytes) below which to warn
+	DefaultDiskCriticalThreshold = 1024 * 1024 * 1024     // Default free space (bytes) below which to pause disk writers
+
+	DefaultAuditLogMaxSize = 16 * 1024 * 1024 // Default size (bytes) above which to rotate the audit log
 )
 
+// DefaultHTTPVirtualHosts is the list of hostnames the HTTP RPC server accepts
+// requests for if none are configured explicitly.
+var DefaultHTTPVirtualHosts = []string{"localhost"}
+
 // DefaultDataDir is the default data directory to use for the databases and other
 // persistence requirements.
 func DefaultDataDir() string {
@@ -0,0 +1,152 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+// diskCheckInterval is the time interval between two consecutive free space
+// checks of the data directory.
+const diskCheckInterval = 30 * time.Second
+
+// DiskUsage is a snapshot of the storage space available to the data directory.
+type DiskUsage struct {
+	Path     string `json:"path"`     // Directory whose hosting file system is monitored
+	Total    uint64 `json:"total"`    // Total size of the file system in bytes
+	Free     uint64 `json:"free"`     // Bytes available to the node on the file system
+	Warning  bool   `json:"warning"`  // Whether free space dropped below the warning threshold
+	Critical bool   `json:"critical"` // Whether free space dropped below the critical threshold
+}
+
+// DiskMonitor periodically checks the free space available to the data directory
+// and reports whether it's running low. Disk hungry subsystems (fast sync state
+// writes, ethash DAG generation) are expected to consult Critical and postpone
+// their work while it reports true, avoiding database corruption due to running
+// out of space in the middle of a commit.
+type DiskMonitor struct {
+	path     string       // Directory to monitor the hosting file system of
+	warn     uint64       // Free space (bytes) below which to warn the user
+	critical uint64       // Free space (bytes) below which to pause disk writers
+	clock    mclock.Clock // Time source to schedule the checks with
+
+	usage DiskUsage    // Last measured disk usage
+	lock  sync.RWMutex // Lock protecting the last measured usage
+
+	quit chan chan struct{} // Termination channel to stop the monitor
+}
+
+// NewDiskMonitor creates a disk space monitor for the file system hosting path,
+// using the given warning and critical free space thresholds (in bytes).
+func NewDiskMonitor(path string, warn, critical uint64, clock mclock.Clock) *DiskMonitor {
+	if clock == nil {
+		clock = mclock.System{}
+	}
+	return &DiskMonitor{
+		path:     path,
+		warn:     warn,
+		critical: critical,
+		clock:    clock,
+		usage:    DiskUsage{Path: path},
+	}
+}
+
+// Start runs an initial disk space check and spins up the background monitor.
+func (m *DiskMonitor) Start() {
+	m.check()
+
+	m.quit = make(chan chan struct{})
+	go m.loop()
+}
+
+// Stop terminates the background monitor, blocking until it exits.
+func (m *DiskMonitor) Stop() {
+	if m.quit == nil {
+		return
+	}
+	done := make(chan struct{})
+	m.quit <- done
+	<-done
+	m.quit = nil
+}
+
+// Usage retrieves the last measured disk usage of the data directory.
+func (m *DiskMonitor) Usage() DiskUsage {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.usage
+}
+
+// Critical reports whether the free disk space dropped below the critical level,
+// signalling that disk writers should pause. A nil monitor is never critical.
+func (m *DiskMonitor) Critical() bool {
+	if m == nil {
+		return false
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.usage.Critical
+}
+
+// loop periodically re-measures the free disk space until terminated.
+func (m *DiskMonitor) loop() {
+	for {
+		select {
+		case <-m.clock.After(diskCheckInterval):
+			m.check()
+		case done := <-m.quit:
+			close(done)
+			return
+		}
+	}
+}
+
+// check measures the free disk space, updating the cached usage and notifying
+// the user whenever a threshold is crossed.
+func (m *DiskMonitor) check() {
+	free, total, err := diskSpace(m.path)
+	if err != nil {
+		log.Debug("Failed to measure free disk space", "path", m.path, "err", err)
+		return
+	}
+	m.lock.Lock()
+	prev := m.usage
+	m.usage = DiskUsage{
+		Path:     m.path,
+		Total:    total,
+		Free:     free,
+		Warning:  free < m.warn,
+		Critical: free < m.critical,
+	}
+	m.lock.Unlock()
+
+	switch {
+	case m.usage.Critical && !prev.Critical:
+		log.Error("Disk space critically low, pausing disk writers", "path", m.path, "free", common.StorageSize(free))
+	case m.usage.Warning && !m.usage.Critical && !prev.Warning:
+		log.Warn("Disk space running low", "path", m.path, "free", common.StorageSize(free))
+	case prev.Critical && !m.usage.Critical:
+		log.Info("Disk space recovered, resuming disk writers", "path", m.path, "free", common.StorageSize(free))
+	}
+}
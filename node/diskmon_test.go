@@ -0,0 +1,74 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common/mclock"
+)
+
+// Tests that the disk monitor classifies the free space according to the set
+// thresholds and refreshes it periodically.
+func TestDiskMonitorThresholds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, _, err := diskSpace(dir); err != nil {
+		t.Skipf("disk space measurement unsupported: %v", err)
+	}
+	// Ensure a plentiful disk doesn't trigger any thresholds
+	monitor := NewDiskMonitor(dir, 0, 0, nil)
+	monitor.check()
+
+	if usage := monitor.Usage(); usage.Warning || usage.Critical || usage.Free == 0 || usage.Total < usage.Free {
+		t.Errorf("unexpected usage for unlimited disk: %+v", usage)
+	}
+	if monitor.Critical() {
+		t.Errorf("unlimited disk reported critical")
+	}
+	// Ensure impossible thresholds are triggered after the next periodic check
+	clock := new(mclock.Simulated)
+	monitor = NewDiskMonitor(dir, math.MaxUint64, 0, clock)
+	monitor.Start()
+	defer monitor.Stop()
+
+	if usage := monitor.Usage(); !usage.Warning || usage.Critical {
+		t.Errorf("warning level mismatch: %+v", usage)
+	}
+	monitor.lock.Lock()
+	monitor.critical = math.MaxUint64
+	monitor.lock.Unlock()
+
+	clock.WaitForTimers(1)
+	clock.Run(diskCheckInterval)
+	clock.WaitForTimers(1)
+
+	if !monitor.Critical() {
+		t.Errorf("critical level not reported after refresh")
+	}
+	// A nil monitor should never be critical
+	if (*DiskMonitor)(nil).Critical() {
+		t.Errorf("nil monitor reported critical")
+	}
+}
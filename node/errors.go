@@ -32,6 +32,29 @@ func (e *DuplicateServiceError) Error() string {
 	return fmt.Sprintf("duplicate service: %v", e.Kind)
 }
 
+// MissingDependencyError is returned during Node startup if a service declares
+// a dependency on a service type that was not registered.
+type MissingDependencyError struct {
+	Kind       reflect.Type
+	Dependency reflect.Type
+}
+
+// Error generates a textual representation of the missing dependency error.
+func (e *MissingDependencyError) Error() string {
+	return fmt.Sprintf("service %v: missing dependency %v", e.Kind, e.Dependency)
+}
+
+// DependencyCycleError is returned during Node startup if the declared service
+// dependencies form a cycle, making it impossible to determine a start order.
+type DependencyCycleError struct {
+	Kind reflect.Type
+}
+
+// Error generates a textual representation of the dependency cycle error.
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("service dependency cycle: %v", e.Kind)
+}
+
 // StopError is returned if a Node fails to stop either any of its registered
 // services or itself.
 type StopError struct {
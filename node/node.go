@@ -17,6 +17,8 @@
 package node
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"net"
@@ -25,15 +27,21 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/expanse-org/go-expanse/accounts"
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/ethdb"
 	"github.com/expanse-org/go-expanse/event"
 	"github.com/expanse-org/go-expanse/internal/debug"
 	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/metrics"
 	"github.com/expanse-org/go-expanse/p2p"
+	"github.com/expanse-org/go-expanse/p2p/discover"
 	"github.com/expanse-org/go-expanse/rpc"
+	gometrics "github.com/rcrowley/go-metrics"
 	"github.com/syndtr/goleveldb/leveldb/storage"
 )
 
@@ -51,6 +59,8 @@ type Node struct {
 	eventmux *event.TypeMux // Event multiplexer used between the services of a stack
 	config   *Config
 	accman   *accounts.Manager
+	clock    mclock.Clock       // Monotonic time source shared by the services
+	metrics  gometrics.Registry // Metrics registry scoped to this node instance
 
 	ephemeralKeystore string          // if non-empty, the key directory that will be removed by Stop
 	instanceDirLock   storage.Storage // prevents concurrent use of instance directory
@@ -58,11 +68,22 @@ type Node struct {
 	serverConfig p2p.Config
 	server       *p2p.Server // Currently running P2P networking layer
 
+	diskmon  *DiskMonitor // Free space monitor of the data directory (nil = ephemeral)
+	auditlog *AuditLog    // Log of the privileged RPC calls served (nil = not audited)
+
 	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
 	services     map[reflect.Type]Service // Currently running services
+	serviceOrder []reflect.Type           // Start order of the running services (dependencies first)
+	lifecycles   []ownedLifecycle         // Started lifecycles of the running services (in start order)
+	cancel       context.CancelFunc       // Cancels the context the lifecycles were started with
+
+	sockets []*activatedSocket // Listener sockets passed by systemd socket activation
 
-	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
-	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	rpcAPIs       []rpc.API      // List of APIs currently provided by the node
+	rpcRequests   *uint64        // Number of RPC requests served through all endpoints (atomic)
+	rpcStats      *rpc.CallStats // Execution statistics of the RPC methods served through all endpoints
+	rpcShedder    *LoadShedder   // Load shedding policy of the RPC methods served through all endpoints
+	inprocHandler *rpc.Server    // In-process RPC request handler to process the API requests
 
 	ipcEndpoint string       // IPC endpoint to listen at (empty = IPC disabled)
 	ipcListener net.Listener // IPC RPC listener socket to serve API requests
@@ -70,12 +91,16 @@ type Node struct {
 
 	httpEndpoint  string       // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
 	httpWhitelist []string     // HTTP RPC modules to allow through this endpoint
+	httpCors      string       // HTTP RPC Cross-Origin Resource Sharing header last used
+	httpVhosts    []string     // HTTP RPC virtual hostnames accepted by the endpoint last used
 	httpListener  net.Listener // HTTP RPC listener socket to server API requests
 	httpHandler   *rpc.Server  // HTTP RPC request handler to process the API requests
 
-	wsEndpoint string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
-	wsListener net.Listener // Websocket RPC listener socket to server API requests
-	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
+	wsEndpoint  string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
+	wsWhitelist []string     // Websocket RPC modules to allow through this endpoint
+	wsOrigins   string       // Websocket RPC origins last accepted
+	wsListener  net.Listener // Websocket RPC listener socket to server API requests
+	wsHandler   *rpc.Server  // Websocket RPC request handler to process the API requests
 
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
@@ -94,6 +119,13 @@ func New(conf *Config) (*Node, error) {
 		}
 		conf.DataDir = absdatadir
 	}
+	if conf.ReloadFile != "" {
+		absreload, err := filepath.Abs(conf.ReloadFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.ReloadFile = absreload
+	}
 	// Ensure that the instance name doesn't cause weird conflicts with
 	// other files in the data directory.
 	if strings.ContainsAny(conf.Name, `/\`) {
@@ -111,16 +143,40 @@ func New(conf *Config) (*Node, error) {
 	if err != nil {
 		return nil, err
 	}
+	clock := conf.Clock
+	if clock == nil {
+		clock = mclock.System{}
+	}
+	registry := metrics.NewRegistry(conf.MetricsPrefix)
+
+	var sockets []*activatedSocket
+	if conf.Systemd {
+		if sockets, err = activatedSockets(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Note: any interaction with Config that would create/touch files
 	// in the data directory or instance directory is delayed until Start.
 	return &Node{
 		accman:            am,
+		clock:             clock,
+		metrics:           registry,
+		rpcRequests:       new(uint64),
+		rpcStats:          rpc.NewCallStats(registry, conf.RPCSlowThreshold),
+		rpcShedder:        NewLoadShedder(conf.RPCLoadShedding, clock),
 		ephemeralKeystore: ephemeralKeystore,
+		sockets:           sockets,
 		config:            conf,
 		serviceFuncs:      []ServiceConstructor{},
 		ipcEndpoint:       conf.IPCEndpoint(),
 		httpEndpoint:      conf.HTTPEndpoint(),
+		httpWhitelist:     conf.HTTPModules,
+		httpCors:          conf.HTTPCors,
+		httpVhosts:        conf.HTTPVirtualHosts,
 		wsEndpoint:        conf.WSEndpoint(),
+		wsWhitelist:       conf.WSModules,
+		wsOrigins:         conf.WSOrigins,
 		eventmux:          new(event.TypeMux),
 	}, nil
 }
@@ -166,17 +222,42 @@ func (n *Node) Start() error {
 		NodeDatabase:     n.config.NodeDB(),
 		ListenAddr:       n.config.ListenAddr,
 		NetRestrict:      n.config.NetRestrict,
+		NetDeny:          n.config.NetDeny,
 		NAT:              n.config.NAT,
 		Dialer:           n.config.Dialer,
 		NoDial:           n.config.NoDial,
+		BootnodeMode:     n.config.BootnodeMode,
 		MaxPeers:         n.config.MaxPeers,
 		MaxPendingPeers:  n.config.MaxPendingPeers,
+		Slots:            p2p.NewPeerSlots(n.config.MaxPeers),
+		MetricsRegistry:  n.metrics,
 	}
 	running := &p2p.Server{Config: n.serverConfig}
 	log.Info(fmt.Sprint("instance:", n.serverConfig.Name))
 
+	// Measure the free space of the data directory, if any, so services are made
+	// aware of low disk conditions already during their startup
+	var diskmon *DiskMonitor
+	if n.config.DataDir != "" {
+		warn, critical := n.config.DiskWarnThreshold, n.config.DiskCriticalThreshold
+		if warn == 0 {
+			warn = DefaultDiskWarnThreshold
+		}
+		if critical == 0 {
+			critical = DefaultDiskCriticalThreshold
+		}
+		diskmon = NewDiskMonitor(n.config.DataDir, warn, critical, n.clock)
+		diskmon.check()
+	}
+	n.rpcShedder.reset(diskmon)
+
 	// Otherwise copy and specialize the P2P configuration
-	services := make(map[reflect.Type]Service)
+	var (
+		services    = make(map[reflect.Type]Service)
+		processes   = make(map[reflect.Type][]Lifecycle)
+		constructed []reflect.Type
+		deps        = make(map[reflect.Type][]reflect.Type)
+	)
 	for _, constructor := range n.serviceFuncs {
 		// Create a new context for the particular service
 		ctx := &ServiceContext{
@@ -184,6 +265,12 @@ func (n *Node) Start() error {
 			services:       make(map[reflect.Type]Service),
 			EventMux:       n.eventmux,
 			AccountManager: n.accman,
+			Clock:          n.clock,
+			Metrics:        n.metrics,
+			DiskMonitor:    diskmon,
+			RPCRequests:    n.RPCRequests,
+			LoadShedder:    n.rpcShedder,
+			PeerSlots:      n.serverConfig.Slots,
 		}
 		for kind, s := range services { // copy needed for threaded access
 			ctx.services[kind] = s
@@ -198,10 +285,21 @@ func (n *Node) Start() error {
 			return &DuplicateServiceError{Kind: kind}
 		}
 		services[kind] = service
+		processes[kind] = ctx.lifecycles
+		constructed = append(constructed, kind)
+
+		deps[kind] = ctx.lookups
+		if dependent, ok := service.(DependentService); ok {
+			deps[kind] = append(deps[kind], dependent.Dependencies()...)
+		}
+	}
+	order, err := sortServices(constructed, services, deps)
+	if err != nil {
+		return err
 	}
 	// Gather the protocols and start the freshly assembled P2P server
-	for _, service := range services {
-		running.Protocols = append(running.Protocols, service.Protocols()...)
+	for _, kind := range order {
+		running.Protocols = append(running.Protocols, services[kind].Protocols()...)
 	}
 	if err := running.Start(); err != nil {
 		if errno, ok := err.(syscall.Errno); ok && datadirInUseErrnos[uint(errno)] {
@@ -209,34 +307,65 @@ func (n *Node) Start() error {
 		}
 		return err
 	}
-	// Start each of the services
-	started := []reflect.Type{}
-	for kind, service := range services {
-		// Start the next service, stopping all previous upon failure
-		if err := service.Start(running); err != nil {
-			for _, kind := range started {
-				services[kind].Stop()
+	// Start each of the services followed by their background processes,
+	// dependencies always before their dependents
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var lifecycles []ownedLifecycle
+	for _, kind := range order {
+		for _, lifecycle := range append([]Lifecycle{&serviceLifecycle{services[kind], running}}, processes[kind]...) {
+			// Start the next lifecycle, stopping all previous upon failure
+			if err := lifecycle.Start(ctx); err != nil {
+				cancel()
+				n.stopLifecycles(lifecycles)
+				running.Stop()
+
+				return err
 			}
+			lifecycles = append(lifecycles, ownedLifecycle{kind, lifecycle})
+		}
+	}
+	// Lastly open the audit log and start the configured RPC interfaces
+	if n.config.AuditLog != "" {
+		path := n.config.resolvePath(n.config.AuditLog)
+		auditlog, err := OpenAuditLog(path, n.config.AuditLogMaxSize, n.config.AuditLogMaxFiles)
+		if err != nil {
+			cancel()
+			n.stopLifecycles(lifecycles)
 			running.Stop()
-
 			return err
 		}
-		// Mark the service started for potential cleanup
-		started = append(started, kind)
+		log.Info("Auditing privileged RPC calls", "path", path)
+		n.auditlog = auditlog
 	}
-	// Lastly start the configured RPC interfaces
 	if err := n.startRPC(services); err != nil {
-		for _, service := range services {
-			service.Stop()
-		}
+		cancel()
+		n.stopLifecycles(lifecycles)
 		running.Stop()
+		if n.auditlog != nil {
+			n.auditlog.Close()
+			n.auditlog = nil
+		}
 		return err
 	}
 	// Finish initializing the startup
+	if diskmon != nil {
+		diskmon.Start()
+	}
+	n.diskmon = diskmon
 	n.services = services
+	n.serviceOrder = order
+	n.lifecycles = lifecycles
+	n.cancel = cancel
 	n.server = running
 	n.stop = make(chan struct{})
 
+	// Chain loaded and protocols running, let the service manager know
+	if n.config.Systemd {
+		if err := sdNotify("READY=1"); err != nil {
+			log.Warn("Failed to notify systemd", "err", err)
+		}
+	}
 	return nil
 }
 
@@ -260,6 +389,72 @@ func (n *Node) openDataDir() error {
 	return nil
 }
 
+// sortServices orders the constructed services so that every service comes after
+// all the services it depends on, keeping the construction order otherwise.
+func sortServices(constructed []reflect.Type, services map[reflect.Type]Service, deps map[reflect.Type][]reflect.Type) ([]reflect.Type, error) {
+	var (
+		order   = make([]reflect.Type, 0, len(services))
+		visited = make(map[reflect.Type]bool)
+		pending = make(map[reflect.Type]bool)
+		visit   func(kind reflect.Type) error
+	)
+	visit = func(kind reflect.Type) error {
+		if visited[kind] {
+			return nil
+		}
+		if pending[kind] {
+			return &DependencyCycleError{Kind: kind}
+		}
+		pending[kind] = true
+		for _, dep := range deps[kind] {
+			if _, ok := services[dep]; !ok {
+				return &MissingDependencyError{Kind: kind, Dependency: dep}
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		delete(pending, kind)
+		visited[kind] = true
+		order = append(order, kind)
+		return nil
+	}
+	for _, kind := range constructed {
+		if err := visit(kind); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// ownedLifecycle is a started lifecycle along with the type of the service it
+// belongs to.
+type ownedLifecycle struct {
+	kind reflect.Type
+	Lifecycle
+}
+
+// stopLifecycles terminates the given lifecycles in the reverse of their start
+// order, so that no service is torn down while a dependent one is still running.
+// Each lifecycle is given the configured stop timeout. The first error of every
+// service is collected and returned per service type.
+func (n *Node) stopLifecycles(lifecycles []ownedLifecycle) map[reflect.Type]error {
+	failures := make(map[reflect.Type]error)
+	for i := len(lifecycles) - 1; i >= 0; i-- {
+		ctx, cancel := context.Background(), func() {}
+		if n.config.ServiceStopTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, n.config.ServiceStopTimeout)
+		}
+		err := lifecycles[i].Stop(ctx)
+		cancel()
+
+		if _, failed := failures[lifecycles[i].kind]; err != nil && !failed {
+			failures[lifecycles[i].kind] = err
+		}
+	}
+	return failures
+}
+
 // startRPC is a helper method to start all the various RPC endpoint during node
 // startup. It's not meant to be called at any time afterwards as it makes certain
 // assumptions about the state of the node.
@@ -277,7 +472,7 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 		n.stopInProc()
 		return err
 	}
-	if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, n.config.HTTPCors); err != nil {
+	if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, n.config.HTTPCors, n.config.HTTPVirtualHosts); err != nil {
 		n.stopIPC()
 		n.stopInProc()
 		return err
@@ -297,8 +492,13 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 func (n *Node) startInProc(apis []rpc.API) error {
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.CountRequests(n.rpcRequests)
+	handler.CollectStats(n.rpcStats)
+	handler.ShedLoad(n.rpcShedder)
+	n.auditCalls(handler, "inproc")
+	handler.LimitSubscriptions(n.subscriptionLimits())
 	for _, api := range apis {
-		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+		if err := handler.RegisterVersionedName(api.Namespace, api.Version, api.Service); err != nil {
 			return err
 		}
 		log.Debug(fmt.Sprintf("InProc registered %T under '%s'", api.Service, api.Namespace))
@@ -323,8 +523,13 @@ func (n *Node) startIPC(apis []rpc.API) error {
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.CountRequests(n.rpcRequests)
+	handler.CollectStats(n.rpcStats)
+	handler.ShedLoad(n.rpcShedder)
+	n.auditCalls(handler, "ipc")
+	handler.LimitSubscriptions(n.subscriptionLimits())
 	for _, api := range apis {
-		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+		if err := handler.RegisterVersionedName(api.Namespace, api.Version, api.Service); err != nil {
 			return err
 		}
 		log.Debug(fmt.Sprintf("IPC registered %T under '%s'", api.Service, api.Namespace))
@@ -334,7 +539,7 @@ func (n *Node) startIPC(apis []rpc.API) error {
 		listener net.Listener
 		err      error
 	)
-	if listener, err = rpc.CreateIPCListener(n.ipcEndpoint); err != nil {
+	if listener, err = n.listen("ipc", n.ipcEndpoint); err != nil {
 		return err
 	}
 	go func() {
@@ -379,7 +584,7 @@ func (n *Node) stopIPC() {
 }
 
 // startHTTP initializes and starts the HTTP RPC endpoint.
-func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors string) error {
+func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors string, vhosts []string) error {
 	// Short circuit if the HTTP endpoint isn't being exposed
 	if endpoint == "" {
 		return nil
@@ -388,12 +593,21 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
 		whitelist[module] = true
+
+		// Aliases only serve the aliased namespace, so that needs registering
+		if name, ok := rpc.DefaultAliases[module]; ok {
+			whitelist[name] = true
+		}
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.CountRequests(n.rpcRequests)
+	handler.CollectStats(n.rpcStats)
+	handler.ShedLoad(n.rpcShedder)
+	n.auditCalls(handler, "http")
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
-			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+			if err := handler.RegisterVersionedName(api.Namespace, api.Version, api.Service); err != nil {
 				return err
 			}
 			log.Debug(fmt.Sprintf("HTTP registered %T under '%s'", api.Service, api.Namespace))
@@ -404,14 +618,20 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 		listener net.Listener
 		err      error
 	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	if listener, err = n.listen("http", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, handler).Serve(listener)
+	if vhosts == nil {
+		vhosts = DefaultHTTPVirtualHosts
+	}
+	go rpc.NewHTTPServer(cors, vhosts, handler).Serve(listener)
 	log.Info(fmt.Sprintf("HTTP endpoint opened: http://%s", endpoint))
 
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
+	n.httpWhitelist = modules
+	n.httpCors = cors
+	n.httpVhosts = vhosts
 	n.httpListener = listener
 	n.httpHandler = handler
 
@@ -442,12 +662,22 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
 		whitelist[module] = true
+
+		// Aliases only serve the aliased namespace, so that needs registering
+		if name, ok := rpc.DefaultAliases[module]; ok {
+			whitelist[name] = true
+		}
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.CountRequests(n.rpcRequests)
+	handler.CollectStats(n.rpcStats)
+	handler.ShedLoad(n.rpcShedder)
+	n.auditCalls(handler, "ws")
+	handler.LimitSubscriptions(n.subscriptionLimits())
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
-			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+			if err := handler.RegisterVersionedName(api.Namespace, api.Version, api.Service); err != nil {
 				return err
 			}
 			log.Debug(fmt.Sprintf("WebSocket registered %T under '%s'", api.Service, api.Namespace))
@@ -458,7 +688,7 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 		listener net.Listener
 		err      error
 	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	if listener, err = n.listen("ws", endpoint); err != nil {
 		return err
 	}
 	go rpc.NewWSServer(wsOrigins, handler).Serve(listener)
@@ -466,6 +696,8 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 
 	// All listeners booted successfully
 	n.wsEndpoint = endpoint
+	n.wsWhitelist = modules
+	n.wsOrigins = wsOrigins
 	n.wsListener = listener
 	n.wsHandler = handler
 
@@ -497,6 +729,11 @@ func (n *Node) Stop() error {
 		return ErrNodeStopped
 	}
 
+	if n.config.Systemd {
+		if err := sdNotify("STOPPING=1"); err != nil {
+			log.Warn("Failed to notify systemd", "err", err)
+		}
+	}
 	// Terminate the API, services and the p2p server.
 	n.stopWS()
 	n.stopHTTP()
@@ -505,13 +742,24 @@ func (n *Node) Stop() error {
 	failure := &StopError{
 		Services: make(map[reflect.Type]error),
 	}
-	for kind, service := range n.services {
-		if err := service.Stop(); err != nil {
-			failure.Services[kind] = err
-		}
+	n.cancel()
+	for kind, err := range n.stopLifecycles(n.lifecycles) {
+		failure.Services[kind] = err
 	}
 	n.server.Stop()
 	n.services = nil
+	n.serviceOrder = nil
+	n.lifecycles = nil
+	n.cancel = nil
+
+	if n.diskmon != nil {
+		n.diskmon.Stop()
+		n.diskmon = nil
+	}
+	if n.auditlog != nil {
+		n.auditlog.Close()
+		n.auditlog = nil
+	}
 	n.server = nil
 
 	// Release instance directory lock.
@@ -563,6 +811,98 @@ func (n *Node) Restart() error {
 	return nil
 }
 
+// RotateNodeKey replaces the node key with a freshly generated one, persisting it
+// in the data directory, and restarts the p2p server with it. All peers are
+// disconnected and redialed, handshaking under the new identity. Keys configured
+// explicitly can't be rotated, as the configuration would override the new key
+// on the next startup.
+func (n *Node) RotateNodeKey() (*ecdsa.PrivateKey, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server == nil {
+		return nil, ErrNodeStopped
+	}
+	if n.config.PrivateKey != nil {
+		return nil, errors.New("node key configured explicitly")
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if n.config.DataDir != "" {
+		if err := crypto.SaveECDSA(n.config.resolvePath(datadirPrivateKey), key); err != nil {
+			return nil, err
+		}
+	}
+	old := n.server.Self().ID
+
+	n.server.Stop()
+	n.server.PrivateKey = key
+	n.serverConfig.PrivateKey = key
+	if err := n.server.Start(); err != nil {
+		return nil, err
+	}
+	log.Info("Rotated node key", "old", old, "new", n.server.Self().ID)
+	return key, nil
+}
+
+// SetTrustedPeer adds the given node to, or removes it from, the trusted peers
+// of the running p2p server. Trusted peers are allowed to connect even if all
+// peer slots are taken. Unless the node is ephemeral, the change is persisted
+// into the trusted node list of the data directory to survive restarts.
+func (n *Node) SetTrustedPeer(node *discover.Node, trusted bool) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server == nil {
+		return ErrNodeStopped
+	}
+	if n.config.DataDir != "" {
+		if err := n.config.updateTrustedNodes(node, trusted); err != nil {
+			return err
+		}
+	}
+	// Keep the change across restarts of the p2p server too
+	n.server.TrustedNodes = updateNodeList(n.server.TrustedNodes, node, trusted)
+	n.serverConfig.TrustedNodes = n.server.TrustedNodes
+
+	if trusted {
+		n.server.AddTrustedPeer(node)
+	} else {
+		n.server.RemoveTrustedPeer(node)
+	}
+	return nil
+}
+
+// SetMaxPeers changes the peer limit of the running p2p server, rebalancing the
+// peer slots and shedding the peers above the new limits. The new limit is kept
+// across restarts of the p2p server, but isn't persisted.
+func (n *Node) SetMaxPeers(max int) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server == nil {
+		return ErrNodeStopped
+	}
+	if err := n.server.SetMaxPeers(max); err != nil {
+		return err
+	}
+	n.config.MaxPeers = max
+	n.serverConfig.MaxPeers = max
+	return nil
+}
+
+// PeerSlotInfo retrieves the usage of the connection and protocol peer slots of
+// the running p2p server.
+func (n *Node) PeerSlotInfo() (*p2p.SlotInfo, error) {
+	server := n.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.SlotInfo(), nil
+}
+
 // Attach creates an RPC client attached to an in-process API handler.
 func (n *Node) Attach() (*rpc.Client, error) {
 	n.lock.RLock()
@@ -633,6 +973,70 @@ func (n *Node) WSEndpoint() string {
 	return n.wsEndpoint
 }
 
+// Clock retrieves the monotonic time source shared by the protocol stack.
+func (n *Node) Clock() mclock.Clock {
+	return n.clock
+}
+
+// Metrics retrieves the metrics registry scoped to this node instance.
+func (n *Node) Metrics() gometrics.Registry {
+	return n.metrics
+}
+
+// RPCRequests retrieves the number of RPC requests served by the node through all
+// of its endpoints since it was created.
+func (n *Node) RPCRequests() uint64 {
+	return atomic.LoadUint64(n.rpcRequests)
+}
+
+// subscriptionLimits returns the resource limits of the subscriptions of every
+// RPC connection served by the node.
+func (n *Node) subscriptionLimits() rpc.SubscriptionLimits {
+	return rpc.SubscriptionLimits{
+		MaxSubscriptions: n.config.RPCMaxSubscriptions,
+		BufferSize:       n.config.RPCNotificationBuffer,
+		DropOldest:       n.config.RPCDropNotifications,
+	}
+}
+
+// RPCStats retrieves the execution statistics of the RPC methods called through
+// all of the node's endpoints since it was created.
+func (n *Node) RPCStats() map[string]*rpc.MethodStats {
+	return n.rpcStats.Stats()
+}
+
+// LoadShedder retrieves the load shedding policy applied to the RPC methods served
+// through all of the node's endpoints.
+func (n *Node) LoadShedder() *LoadShedder {
+	return n.rpcShedder
+}
+
+// auditCalls makes the RPC handler of the given endpoint record the privileged
+// calls it serves into the audit log, if auditing is enabled.
+func (n *Node) auditCalls(handler *rpc.Server, transport string) {
+	if n.auditlog != nil {
+		handler.AuditCalls(&rpcAuditor{log: n.auditlog, transport: transport})
+	}
+}
+
+// AuditLog retrieves the log of the privileged RPC calls served by the node. It is
+// nil if the node is not running or auditing is disabled.
+func (n *Node) AuditLog() *AuditLog {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.auditlog
+}
+
+// DiskMonitor retrieves the free space monitor of the data directory. It is nil
+// if the node is not running or is ephemeral.
+func (n *Node) DiskMonitor() *DiskMonitor {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.diskmon
+}
+
 // EventMux retrieves the event multiplexer used by all the network services in
 // the current protocol stack.
 func (n *Node) EventMux() *event.TypeMux {
@@ -680,6 +1084,11 @@ func (n *Node) apis() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicWeb3API(n),
 			Public:    true,
+		}, {
+			Namespace: "health",
+			Version:   "1.0",
+			Service:   NewPublicHealthAPI(n),
+			Public:    true,
 		},
 	}
 }
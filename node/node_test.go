@@ -17,6 +17,7 @@
 package node
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"os"
@@ -185,6 +186,206 @@ func TestServiceLifeCycle(t *testing.T) {
 	}
 }
 
+// Tests that services are started after all their dependencies (both retrieved
+// during construction and explicitly declared) and stopped in reverse order.
+func TestServiceDependencyOrder(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	var started, stopped []string
+	instrument := func(id string) InstrumentedService {
+		return InstrumentedService{
+			startHook: func(*p2p.Server) { started = append(started, id) },
+			stopHook:  func() { stopped = append(stopped, id) },
+		}
+	}
+	// Register a service explicitly depending on a yet unconstructed one
+	dependent := func(*ServiceContext) (Service, error) {
+		service := &DependentInstrumentedServiceA{}
+		service.InstrumentedService = instrument("dependent")
+		service.deps = []reflect.Type{reflect.TypeOf(&InstrumentedServiceB{})}
+		return service, nil
+	}
+	// Register a base service and one retrieving it during construction
+	base := func(*ServiceContext) (Service, error) {
+		return &InstrumentedServiceA{instrument("base")}, nil
+	}
+	lookup := func(ctx *ServiceContext) (Service, error) {
+		var service *InstrumentedServiceA
+		if err := ctx.Service(&service); err != nil {
+			return nil, err
+		}
+		return &InstrumentedServiceB{instrument("lookup")}, nil
+	}
+	for i, constructor := range []ServiceConstructor{dependent, base, lookup} {
+		if err := stack.Register(constructor); err != nil {
+			t.Fatalf("service %d: registration failed: %v", i, err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if want := []string{"base", "lookup", "dependent"}; !reflect.DeepEqual(started, want) {
+		t.Errorf("start order mismatch: have %v, want %v", started, want)
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	if want := []string{"dependent", "lookup", "base"}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stop order mismatch: have %v, want %v", stopped, want)
+	}
+}
+
+// Tests that the background processes registered by a service are started right
+// after it and stopped right before it, and that their start context is cancelled
+// once the node shuts down.
+func TestServiceLifecycles(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	var (
+		started, stopped []string
+		startctx         context.Context
+	)
+	process := func(id string) *InstrumentedLifecycle {
+		return &InstrumentedLifecycle{
+			startHook: func(ctx context.Context) { started, startctx = append(started, id), ctx },
+			stopHook:  func(context.Context) { stopped = append(stopped, id) },
+		}
+	}
+	base := func(ctx *ServiceContext) (Service, error) {
+		ctx.RegisterLifecycle(process("base/1"))
+		ctx.RegisterLifecycle(process("base/2"))
+		return &InstrumentedServiceA{InstrumentedService{
+			startHook: func(*p2p.Server) { started = append(started, "base") },
+			stopHook:  func() { stopped = append(stopped, "base") },
+		}}, nil
+	}
+	dependent := func(ctx *ServiceContext) (Service, error) {
+		var service *InstrumentedServiceA
+		if err := ctx.Service(&service); err != nil {
+			return nil, err
+		}
+		ctx.RegisterLifecycle(process("dependent/1"))
+		return &InstrumentedServiceB{InstrumentedService{
+			startHook: func(*p2p.Server) { started = append(started, "dependent") },
+			stopHook:  func() { stopped = append(stopped, "dependent") },
+		}}, nil
+	}
+	for i, constructor := range []ServiceConstructor{base, dependent} {
+		if err := stack.Register(constructor); err != nil {
+			t.Fatalf("service %d: registration failed: %v", i, err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if want := []string{"base", "base/1", "base/2", "dependent", "dependent/1"}; !reflect.DeepEqual(started, want) {
+		t.Errorf("start order mismatch: have %v, want %v", started, want)
+	}
+	if startctx.Err() != nil {
+		t.Errorf("start context cancelled while running: %v", startctx.Err())
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	if want := []string{"dependent/1", "dependent", "base/2", "base/1", "base"}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stop order mismatch: have %v, want %v", stopped, want)
+	}
+	if startctx.Err() != context.Canceled {
+		t.Errorf("start context error mismatch: have %v, want %v", startctx.Err(), context.Canceled)
+	}
+}
+
+// Tests that services and background processes failing to stop within the stop
+// timeout are given up on, reporting the timeout as their stop error.
+func TestServiceStopTimeout(t *testing.T) {
+	config := testNodeConfig()
+	config.ServiceStopTimeout = 50 * time.Millisecond
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	release := make(chan struct{})
+	defer close(release)
+
+	// Register a service hanging on stop and one with a hanging background process
+	hanging := func(*ServiceContext) (Service, error) {
+		return &InstrumentedServiceA{InstrumentedService{stopHook: func() { <-release }}}, nil
+	}
+	process := func(ctx *ServiceContext) (Service, error) {
+		ctx.RegisterLifecycle(HangingLifecycle{})
+		return new(InstrumentedServiceB), nil
+	}
+	for i, constructor := range []ServiceConstructor{hanging, process} {
+		if err := stack.Register(constructor); err != nil {
+			t.Fatalf("service %d: registration failed: %v", i, err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	err = stack.Stop()
+	failure, ok := err.(*StopError)
+	if !ok {
+		t.Fatalf("termination failure mismatch: have %v, want StopError", err)
+	}
+	for _, kind := range []reflect.Type{reflect.TypeOf(&InstrumentedServiceA{}), reflect.TypeOf(&InstrumentedServiceB{})} {
+		if failure.Services[kind] != context.DeadlineExceeded {
+			t.Errorf("%v: stop error mismatch: have %v, want %v", kind, failure.Services[kind], context.DeadlineExceeded)
+		}
+	}
+}
+
+// Tests that unsatisfiable service dependencies abort the node startup.
+func TestServiceDependencyFailures(t *testing.T) {
+	kindA := reflect.TypeOf(&DependentInstrumentedServiceA{})
+	kindB := reflect.TypeOf(&DependentInstrumentedServiceB{})
+
+	makeA := func(deps ...reflect.Type) ServiceConstructor {
+		return func(*ServiceContext) (Service, error) {
+			service := &DependentInstrumentedServiceA{}
+			service.deps = deps
+			return service, nil
+		}
+	}
+	makeB := func(deps ...reflect.Type) ServiceConstructor {
+		return func(*ServiceContext) (Service, error) {
+			service := &DependentInstrumentedServiceB{}
+			service.deps = deps
+			return service, nil
+		}
+	}
+	// Dependency on a service that was never registered
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	stack.Register(makeA(kindB))
+	if err := stack.Start(); err == nil {
+		stack.Stop()
+		t.Fatalf("missing dependency accepted")
+	} else if _, ok := err.(*MissingDependencyError); !ok {
+		t.Fatalf("missing dependency error mismatch: have %v, want %v", err, MissingDependencyError{})
+	}
+	// Services depending on each other
+	stack, err = New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	stack.Register(makeA(kindB))
+	stack.Register(makeB(kindA))
+	if err := stack.Start(); err == nil {
+		stack.Stop()
+		t.Fatalf("dependency cycle accepted")
+	} else if _, ok := err.(*DependencyCycleError); !ok {
+		t.Fatalf("dependency cycle error mismatch: have %v, want %v", err, DependencyCycleError{})
+	}
+}
+
 // Tests that services are restarted cleanly as new instances.
 func TestServiceRestarts(t *testing.T) {
 	stack, err := New(testNodeConfig())
@@ -17,13 +17,20 @@
 package node
 
 import (
+	"context"
+	"io"
+	"os"
 	"reflect"
+	"syscall"
 
 	"github.com/expanse-org/go-expanse/accounts"
+	"github.com/expanse-org/go-expanse/common/mclock"
 	"github.com/expanse-org/go-expanse/ethdb"
 	"github.com/expanse-org/go-expanse/event"
 	"github.com/expanse-org/go-expanse/p2p"
 	"github.com/expanse-org/go-expanse/rpc"
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/syndtr/goleveldb/leveldb/storage"
 )
 
 // ServiceContext is a collection of service independent options inherited from
@@ -32,8 +39,16 @@ import (
 type ServiceContext struct {
 	config         *Config
 	services       map[reflect.Type]Service // Index of the already constructed services
+	lookups        []reflect.Type           // Services retrieved by the constructor (implicit dependencies)
+	lifecycles     []Lifecycle              // Background processes registered by the constructor
 	EventMux       *event.TypeMux           // Event multiplexer used for decoupled notifications
 	AccountManager *accounts.Manager        // Account manager created by the node.
+	Clock          mclock.Clock             // Monotonic time source shared by the node's services
+	Metrics        gometrics.Registry       // Metrics registry scoped to the node instance
+	DiskMonitor    *DiskMonitor             // Free space monitor of the data directory (nil if ephemeral)
+	RPCRequests    func() uint64            // Number of RPC requests served by the node so far
+	LoadShedder    *LoadShedder             // RPC load shedding policy, fed the sync status of the services
+	PeerSlots      *p2p.PeerSlots           // Peer slots shared by the protocols of the services
 }
 
 // OpenDatabase opens an existing database with the given name (or creates one
@@ -53,16 +68,53 @@ func (ctx *ServiceContext) ResolvePath(path string) string {
 	return ctx.config.resolvePath(path)
 }
 
+// LockPath acquires an exclusive lock on a directory within the node's instance
+// directory, creating it if needed. It is meant for services maintaining flat
+// files that must not be shared with a concurrently running process. The lock is
+// held until the returned handle is closed. Ephemeral nodes get a no-op handle.
+func (ctx *ServiceContext) LockPath(name string) (io.Closer, error) {
+	if ctx.config.DataDir == "" {
+		return nopCloser{}, nil
+	}
+	path := ctx.config.resolvePath(name)
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	lock, err := storage.OpenFile(path, false)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && datadirInUseErrnos[uint(errno)] {
+			return nil, ErrDatadirUsed
+		}
+		return nil, err
+	}
+	return lock, nil
+}
+
+// nopCloser is a lock handle for resources that don't need releasing.
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
 // Service retrieves a currently running service registered of a specific type.
+// Any service successfully retrieved is recorded as a dependency of the one being
+// constructed, guaranteeing that it is started before and stopped after it.
 func (ctx *ServiceContext) Service(service interface{}) error {
 	element := reflect.ValueOf(service).Elem()
 	if running, ok := ctx.services[element.Type()]; ok {
 		element.Set(reflect.ValueOf(running))
+		ctx.lookups = append(ctx.lookups, element.Type())
 		return nil
 	}
 	return ErrServiceUnknown
 }
 
+// RegisterLifecycle registers a background process of the service being constructed
+// with the node, which starts it right after the service and stops it right before,
+// sparing the service from starting and stopping its processes by hand.
+func (ctx *ServiceContext) RegisterLifecycle(lifecycle Lifecycle) {
+	ctx.lifecycles = append(ctx.lifecycles, lifecycle)
+}
+
 // ServiceConstructor is the function signature of the constructors needed to be
 // registered for service instantiation.
 type ServiceConstructor func(ctx *ServiceContext) (Service, error)
@@ -92,3 +144,107 @@ type Service interface {
 	// are all terminated.
 	Stop() error
 }
+
+// Lifecycle is the context-aware start and stop of a process managed by the node:
+// a service, adapted from its Start and Stop methods, or one of the background
+// processes it registered via ServiceContext.RegisterLifecycle.
+//
+// The context passed to Start is cancelled when the node shuts down, the one passed
+// to Stop expires after Config.ServiceStopTimeout. Both methods should return the
+// error of their context once it's done.
+type Lifecycle interface {
+	// Start spawns the goroutines of the process.
+	Start(ctx context.Context) error
+
+	// Stop terminates the goroutines of the process, blocking until they are all
+	// terminated.
+	Stop(ctx context.Context) error
+}
+
+// serviceLifecycle adapts a Service to the Lifecycle interface, starting it on the
+// P2P server of the node.
+type serviceLifecycle struct {
+	service Service
+	server  *p2p.Server
+}
+
+// Start implements Lifecycle, starting the service.
+func (s *serviceLifecycle) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.service.Start(s.server)
+}
+
+// Stop implements Lifecycle, stopping the service and waiting for it until the
+// context expires.
+func (s *serviceLifecycle) Stop(ctx context.Context) error {
+	return waitContext(ctx, s.service.Stop)
+}
+
+// NewLifecycle adapts the plain start and stop functions of a background process
+// to the Lifecycle interface, for registration via ServiceContext.RegisterLifecycle.
+func NewLifecycle(start, stop func()) Lifecycle {
+	return &funcLifecycle{start: start, stop: stop}
+}
+
+// funcLifecycle is a Lifecycle made of plain start and stop functions.
+type funcLifecycle struct {
+	start, stop func()
+}
+
+// Start implements Lifecycle, running the start function.
+func (l *funcLifecycle) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.start()
+	return nil
+}
+
+// Stop implements Lifecycle, running the stop function and waiting for it until
+// the context expires.
+func (l *funcLifecycle) Stop(ctx context.Context) error {
+	return waitContext(ctx, func() error { l.stop(); return nil })
+}
+
+// waitContext runs fn and returns its error, or the error of the context if that
+// expires first, leaving fn to finish in the background.
+func waitContext(ctx context.Context, fn func() error) error {
+	errc := make(chan error, 1)
+	go func() { errc <- fn() }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DependentService is an optional interface a Service may implement to declare
+// the other services (by their concrete type) it requires to be running before
+// it can be started itself. Services retrieved via ServiceContext.Service during
+// construction are tracked automatically and need not be declared.
+type DependentService interface {
+	Service
+
+	// Dependencies retrieves the types of the services this one relies on.
+	Dependencies() []reflect.Type
+}
+
+// Reloader is an optional interface a Service may implement to pick up the parts
+// of a reloaded configuration concerning it without restarting the node.
+type Reloader interface {
+	// Reload applies the settings of the configuration that are set and relevant
+	// to the service, leaving everything else unchanged.
+	Reload(config *ReloadConfig) error
+}
+
+// StatusReporter is an optional interface a Service may implement to contribute
+// to the status dumps of the node.
+type StatusReporter interface {
+	// Status summarises the current state of the service as alternating keys and
+	// values, suitable for a log entry.
+	Status() []interface{}
+}
@@ -22,6 +22,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/expanse-org/go-expanse/common/mclock"
+	gometrics "github.com/rcrowley/go-metrics"
 )
 
 // Tests that databases are correctly created persistent or ephemeral based on
@@ -61,6 +64,99 @@ func TestContextDatabases(t *testing.T) {
 	}
 }
 
+// Tests that paths can be exclusively locked within the instance directory.
+func TestContextLockPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := &ServiceContext{config: &Config{Name: "unit-test", DataDir: dir}}
+	lock, err := ctx.LockPath("resource")
+	if err != nil {
+		t.Fatalf("failed to lock resource: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unit-test", "resource")); err != nil {
+		t.Fatalf("locked resource doesn't exist: %v", err)
+	}
+	if _, err := ctx.LockPath("resource"); err != ErrDatadirUsed {
+		t.Fatalf("double lock error mismatch: have %v, want %v", err, ErrDatadirUsed)
+	}
+	lock.Close()
+
+	if lock, err = ctx.LockPath("resource"); err != nil {
+		t.Fatalf("failed to relock released resource: %v", err)
+	}
+	lock.Close()
+
+	// Ephemeral nodes should hand out dummy locks
+	ctx = &ServiceContext{config: &Config{DataDir: ""}}
+	if lock, err = ctx.LockPath("resource"); err != nil {
+		t.Fatalf("failed to lock ephemeral resource: %v", err)
+	}
+	lock.Close()
+}
+
+// Tests that the node's shared resource handles are passed to all services.
+func TestContextSharedResources(t *testing.T) {
+	clock := new(mclock.Simulated)
+
+	config := testNodeConfig()
+	config.Clock = clock
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	var contexts []*ServiceContext
+	constructor := func(ctx *ServiceContext) (Service, error) {
+		contexts = append(contexts, ctx)
+		return new(NoopService), nil
+	}
+	if err := stack.Register(constructor); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	if len(contexts) != 1 {
+		t.Fatalf("service construction count mismatch: have %d, want 1", len(contexts))
+	}
+	if contexts[0].Clock != clock {
+		t.Errorf("service clock mismatch: have %v, want %v", contexts[0].Clock, clock)
+	}
+	if contexts[0].Metrics == nil || contexts[0].Metrics != stack.Metrics() {
+		t.Errorf("service metrics registry mismatch: have %v, want %v", contexts[0].Metrics, stack.Metrics())
+	}
+}
+
+// Tests that nodes with distinct metrics prefixes don't share their metrics.
+func TestContextMetricsIsolation(t *testing.T) {
+	configA, configB := testNodeConfig(), testNodeConfig()
+	configA.MetricsPrefix, configB.MetricsPrefix = "nodeA/", "nodeB/"
+
+	stackA, err := New(configA)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack A: %v", err)
+	}
+	stackB, err := New(configB)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack B: %v", err)
+	}
+	counterA := gometrics.GetOrRegisterCounter("test/counter", stackA.Metrics())
+	counterB := gometrics.GetOrRegisterCounter("test/counter", stackB.Metrics())
+	if counterA == counterB {
+		t.Fatalf("metrics shared between nodes")
+	}
+	if gometrics.DefaultRegistry.Get("nodeA/test/counter") != counterA {
+		t.Fatalf("prefixed metric not found in the default registry")
+	}
+	gometrics.DefaultRegistry.Unregister("nodeA/test/counter")
+	gometrics.DefaultRegistry.Unregister("nodeB/test/counter")
+}
+
 // Tests that already constructed services can be retrieves by later ones.
 func TestContextServices(t *testing.T) {
 	stack, err := New(testNodeConfig())
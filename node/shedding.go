@@ -0,0 +1,275 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+const (
+	memCheckInterval = time.Second // Minimum time between two heap size measurements
+
+	syncRetryAfter     = 30 * time.Second // Retry hint for calls shed while syncing
+	pressureRetryAfter = 10 * time.Second // Retry hint for calls shed under resource pressure
+)
+
+// LoadSheddingClass is a class of expensive RPC methods rejected under the same
+// conditions.
+type LoadSheddingClass struct {
+	Methods  []string // Method names in the class, a trailing '*' matching any suffix
+	MinRange uint64   // Blocks a call needs to span to be shed, if it has a range (0 = any)
+	Syncing  bool     // Whether to shed the calls while the node is syncing
+	Pressure bool     // Whether to shed the calls under memory or disk pressure
+}
+
+// matches returns whether the canonical method name belongs to the class.
+func (c *LoadSheddingClass) matches(method string) bool {
+	return matchMethod(c.Methods, method)
+}
+
+// matchMethod returns whether the canonical method name matches any of the given
+// patterns, a trailing '*' in a pattern matching any suffix.
+func matchMethod(patterns []string, method string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(method, pattern[:len(pattern)-1]) {
+				return true
+			}
+		} else if method == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSheddingConfig is the policy rejecting expensive RPC calls with a retriable
+// error while the node is catching up with the network or short on resources.
+type LoadSheddingConfig struct {
+	Classes     map[string]LoadSheddingClass // Method classes to shed, keyed by name
+	MemoryLimit uint64                       // Heap size in bytes above which memory is under pressure (0 = unlimited)
+}
+
+// DefaultLoadSheddingClasses are the preset method classes known to be expensive
+// enough to be worth shedding.
+var DefaultLoadSheddingClasses = map[string]LoadSheddingClass{
+	"logs":  {Methods: []string{"eth_getLogs"}, MinRange: 1000, Syncing: true, Pressure: true},
+	"trace": {Methods: []string{"debug_trace*"}, Syncing: true, Pressure: true},
+}
+
+// SyncStatusFunc reports whether a service is still catching up with the network
+// and the number of its current head block.
+type SyncStatusFunc func() (syncing bool, head uint64)
+
+// LoadStatus is a snapshot of the conditions the load shedding policy acts upon.
+type LoadStatus struct {
+	Syncing        bool     `json:"syncing"`        // Whether any service is catching up with the network
+	Memory         uint64   `json:"memory"`         // Last measured heap size in bytes
+	MemoryPressure bool     `json:"memoryPressure"` // Whether the heap size exceeds the configured limit
+	DiskPressure   bool     `json:"diskPressure"`   // Whether the data directory is critically low on space
+	Shedding       []string `json:"shedding"`       // Method classes currently being shed
+	Shed           uint64   `json:"shed"`           // Number of calls shed since the node was created
+}
+
+// LoadShedder implements rpc.LoadShedder, rejecting the calls of the configured
+// method classes while the node is syncing or under memory or disk pressure, so
+// that cheap queries stay responsive during catch-up. Services report their sync
+// progress via RegisterSyncStatus.
+type LoadShedder struct {
+	config LoadSheddingConfig
+	clock  mclock.Clock
+	memory func() uint64 // Heap size measurement, replaceable for testing
+
+	syncers []SyncStatusFunc // Sync progress reporters of the running services
+	disk    *DiskMonitor     // Free space monitor of the data directory (nil = ephemeral)
+
+	heap        uint64         // Last measured heap size
+	heapChecked mclock.AbsTime // Time of the last heap size measurement
+	heapValid   bool           // Whether the heap size was measured at all
+
+	shed uint64 // Number of calls shed, accessed atomically
+	lock sync.Mutex
+}
+
+// NewLoadShedder creates a load shedder enforcing the given policy. A nil config
+// creates one that never sheds, but still tracks the load conditions.
+func NewLoadShedder(config *LoadSheddingConfig, clock mclock.Clock) *LoadShedder {
+	if clock == nil {
+		clock = mclock.System{}
+	}
+	s := &LoadShedder{clock: clock, memory: heapSize}
+	if config != nil {
+		s.config = *config
+	}
+	return s
+}
+
+// heapSize measures the number of bytes allocated on the heap.
+func heapSize() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// RegisterSyncStatus adds a sync progress reporter to consult when deciding if
+// the node is syncing. Services call it from their constructor.
+func (s *LoadShedder) RegisterSyncStatus(status SyncStatusFunc) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.syncers = append(s.syncers, status)
+}
+
+// reset drops the sync reporters of the previous services and switches to a new
+// disk monitor, preparing the shedder for a fresh set of services.
+func (s *LoadShedder) reset(disk *DiskMonitor) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.syncers, s.disk = nil, disk
+}
+
+// syncing returns whether any service is catching up with the network, and the
+// highest head block reported, or math.MaxUint64 if there are no reporters.
+func (s *LoadShedder) syncing() (bool, uint64) {
+	s.lock.Lock()
+	syncers := s.syncers
+	s.lock.Unlock()
+
+	if len(syncers) == 0 {
+		return false, math.MaxUint64
+	}
+	var (
+		syncing bool
+		head    uint64
+	)
+	for _, status := range syncers {
+		busy, number := status()
+		syncing = syncing || busy
+		if number > head {
+			head = number
+		}
+	}
+	return syncing, head
+}
+
+// pressure returns the last measured heap size and whether memory or the disk is
+// under pressure, measuring the heap at most once per memCheckInterval.
+func (s *LoadShedder) pressure() (heap uint64, memory bool, disk bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if now := s.clock.Now(); !s.heapValid || time.Duration(now-s.heapChecked) >= memCheckInterval {
+		s.heap, s.heapChecked, s.heapValid = s.memory(), now, true
+	}
+	memory = s.config.MemoryLimit > 0 && s.heap > s.config.MemoryLimit
+	disk = s.disk != nil && s.disk.Critical()
+	return s.heap, memory, disk
+}
+
+// Shed implements rpc.LoadShedder, returning an *rpc.OverloadedError if the call
+// belongs to a method class shed under the current load conditions.
+func (s *LoadShedder) Shed(method string, args []interface{}) error {
+	for _, class := range s.config.Classes {
+		if !class.matches(method) {
+			continue
+		}
+		syncing, head := s.syncing()
+		if class.MinRange > 0 {
+			if span, ok := blockSpan(args, head); ok && span < class.MinRange {
+				continue
+			}
+		}
+		if err := s.reject(&class, syncing); err != nil {
+			atomic.AddUint64(&s.shed, 1)
+			return err
+		}
+	}
+	return nil
+}
+
+// reject returns the error to shed a call of the given class with, or nil if the
+// class is not shed under the current load conditions.
+func (s *LoadShedder) reject(class *LoadSheddingClass, syncing bool) error {
+	if class.Syncing && syncing {
+		return &rpc.OverloadedError{Reason: "syncing", RetryAfter: syncRetryAfter}
+	}
+	if class.Pressure {
+		_, memory, disk := s.pressure()
+		if memory {
+			return &rpc.OverloadedError{Reason: "memory pressure", RetryAfter: pressureRetryAfter}
+		}
+		if disk {
+			return &rpc.OverloadedError{Reason: "disk pressure", RetryAfter: pressureRetryAfter}
+		}
+	}
+	return nil
+}
+
+// Status retrieves the current load conditions and the method classes shed due
+// to them.
+func (s *LoadShedder) Status() *LoadStatus {
+	syncing, _ := s.syncing()
+	heap, memory, disk := s.pressure()
+
+	status := &LoadStatus{
+		Syncing:        syncing,
+		Memory:         heap,
+		MemoryPressure: memory,
+		DiskPressure:   disk,
+		Shedding:       []string{},
+		Shed:           atomic.LoadUint64(&s.shed),
+	}
+	for name, class := range s.config.Classes {
+		if (class.Syncing && syncing) || (class.Pressure && (memory || disk)) {
+			status.Shedding = append(status.Shedding, name)
+		}
+	}
+	sort.Strings(status.Shedding)
+	return status
+}
+
+// blockSpan returns the number of blocks spanned by the first block range among
+// the call arguments, resolving the latest and pending blocks to head.
+func blockSpan(args []interface{}, head uint64) (uint64, bool) {
+	for _, arg := range args {
+		ranger, ok := arg.(rpc.BlockRanger)
+		if !ok {
+			continue
+		}
+		from, to := ranger.BlockRange()
+		resolve := func(number rpc.BlockNumber) uint64 {
+			if number < 0 {
+				return head
+			}
+			return uint64(number)
+		}
+		if first, last := resolve(from), resolve(to); last > first {
+			return last - first, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
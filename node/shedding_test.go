@@ -0,0 +1,120 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+// testRange is a call argument spanning a range of blocks.
+type testRange struct{ from, to rpc.BlockNumber }
+
+func (r testRange) BlockRange() (rpc.BlockNumber, rpc.BlockNumber) { return r.from, r.to }
+
+// shedTest is a call to check the load shedding decision of.
+type shedTest struct {
+	method string
+	args   []interface{}
+	shed   bool
+}
+
+// Tests that the load shedder rejects the calls of the configured method classes
+// while the node is syncing or under memory pressure, but lets cheap calls pass.
+func TestLoadShedder(t *testing.T) {
+	clock := new(mclock.Simulated)
+	shedder := NewLoadShedder(&LoadSheddingConfig{
+		Classes: map[string]LoadSheddingClass{
+			"logs":  {Methods: []string{"eth_getLogs"}, MinRange: 100, Syncing: true},
+			"trace": {Methods: []string{"debug_trace*"}, Pressure: true},
+		},
+		MemoryLimit: 1000,
+	}, clock)
+
+	heap := uint64(500)
+	shedder.memory = func() uint64 { return heap }
+
+	syncing, head := false, uint64(1000)
+	shedder.RegisterSyncStatus(func() (bool, uint64) { return syncing, head })
+
+	tests := []shedTest{
+		{"eth_blockNumber", nil, false},
+		{"eth_getLogs", []interface{}{testRange{0, 1000}}, false},
+		{"debug_traceTransaction", nil, false},
+	}
+	check := func(stage string, shedding []string) {
+		for i, tt := range tests {
+			err := shedder.Shed(tt.method, tt.args)
+			if (err != nil) != tt.shed {
+				t.Errorf("%s: test %d (%s): shed mismatch: have %v, want %v", stage, i, tt.method, err, tt.shed)
+			}
+			if _, ok := err.(*rpc.OverloadedError); err != nil && !ok {
+				t.Errorf("%s: test %d (%s): error type mismatch: have %T", stage, i, tt.method, err)
+			}
+		}
+		if have := shedder.Status().Shedding; !reflect.DeepEqual(have, shedding) {
+			t.Errorf("%s: shedding classes mismatch: have %v, want %v", stage, have, shedding)
+		}
+	}
+	check("idle", []string{})
+
+	// Start syncing and ensure only large log queries are shed
+	syncing = true
+	tests = []shedTest{
+		{"eth_blockNumber", nil, false},
+		{"eth_getLogs", []interface{}{testRange{0, 1000}}, true},
+		{"eth_getLogs", []interface{}{testRange{0, rpc.LatestBlockNumber}}, true},
+		{"eth_getLogs", []interface{}{testRange{950, rpc.LatestBlockNumber}}, false},
+		{"eth_getLogs", []interface{}{testRange{rpc.LatestBlockNumber, rpc.LatestBlockNumber}}, false},
+		{"eth_getLogs", nil, true},
+		{"debug_traceTransaction", nil, false},
+	}
+	check("syncing", []string{"logs"})
+
+	// Finish syncing and grow the heap, ensuring only traces are shed once the
+	// memory is measured again
+	syncing, heap = false, 2000
+	tests = []shedTest{
+		{"eth_getLogs", []interface{}{testRange{0, 1000}}, false},
+		{"debug_traceTransaction", nil, false},
+	}
+	check("stale heap", []string{})
+
+	clock.Run(memCheckInterval)
+	tests[1].shed = true
+	check("memory pressure", []string{"trace"})
+
+	if have := shedder.Status().Shed; have != 4 {
+		t.Errorf("shed call count mismatch: have %d, want %d", have, 4)
+	}
+}
+
+// Tests that a load shedder without a policy never sheds any calls.
+func TestLoadShedderDisabled(t *testing.T) {
+	shedder := NewLoadShedder(nil, nil)
+	shedder.RegisterSyncStatus(func() (bool, uint64) { return true, 0 })
+
+	if err := shedder.Shed("debug_traceTransaction", nil); err != nil {
+		t.Errorf("disabled shedder rejected call: %v", err)
+	}
+	if status := shedder.Status(); !status.Syncing || len(status.Shedding) != 0 {
+		t.Errorf("status mismatch: %+v", status)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"reflect"
+	"runtime/pprof"
+	"strings"
+
+	"github.com/expanse-org/go-expanse/internal/debug"
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/p2p/netutil"
+)
+
+// errNoReloadFile is returned when a configuration reload is requested without a
+// reload file being configured.
+var errNoReloadFile = errors.New("no reload file configured")
+
+// ReloadConfig is the set of settings which can be changed while the node is
+// running, read from the configured reload file upon SIGHUP. Only the fields
+// present in the file are applied, the rest of the configuration is unchanged.
+type ReloadConfig struct {
+	Verbosity   *int     `json:"verbosity"`   // Log verbosity (0-9)
+	Vmodule     *string  `json:"vmodule"`     // Per-module log verbosity pattern
+	NetRestrict *string  `json:"netrestrict"` // Comma-separated CIDR masks of allowed networks (empty = unrestricted)
+	NetDeny     *string  `json:"netdeny"`     // Comma-separated CIDR masks of denied networks
+	GasPrice    *big.Int `json:"gasprice"`    // Minimal gas price of mined and pooled transactions
+}
+
+// Reload reads the reload file and applies its settings to the logger, the p2p
+// server and the services supporting configuration reloads.
+func (n *Node) Reload() error {
+	if n.config.ReloadFile == "" {
+		return errNoReloadFile
+	}
+	blob, err := ioutil.ReadFile(n.config.ReloadFile)
+	if err != nil {
+		return err
+	}
+	config := new(ReloadConfig)
+	if err := json.Unmarshal(blob, config); err != nil {
+		return fmt.Errorf("invalid reload file %s: %v", n.config.ReloadFile, err)
+	}
+	return n.reload(config)
+}
+
+// reload applies a configuration to the running node. The network restrictions
+// are validated before anything is changed, so a malformed list doesn't leave the
+// node half reconfigured.
+func (n *Node) reload(config *ReloadConfig) error {
+	var whitelist, denylist *netutil.Netlist
+	if config.NetRestrict != nil && strings.TrimSpace(*config.NetRestrict) != "" {
+		list, err := netutil.ParseNetlist(*config.NetRestrict)
+		if err != nil {
+			return fmt.Errorf("invalid netrestrict: %v", err)
+		}
+		whitelist = list
+	}
+	if config.NetDeny != nil {
+		list, err := netutil.ParseNetlist(*config.NetDeny)
+		if err != nil {
+			return fmt.Errorf("invalid netdeny: %v", err)
+		}
+		denylist = list
+	}
+	n.lock.RLock()
+	server, services, order := n.server, n.services, n.serviceOrder
+	n.lock.RUnlock()
+
+	if server == nil {
+		return ErrNodeStopped
+	}
+	// Update the logger and the networking layer
+	if config.Verbosity != nil {
+		debug.Handler.Verbosity(*config.Verbosity)
+	}
+	if config.Vmodule != nil {
+		if err := debug.Handler.Vmodule(*config.Vmodule); err != nil {
+			return fmt.Errorf("invalid vmodule: %v", err)
+		}
+	}
+	if config.NetRestrict != nil || config.NetDeny != nil {
+		allowed, denied := server.NetRestrictions()
+		if config.NetRestrict != nil {
+			allowed = whitelist
+		}
+		if config.NetDeny != nil {
+			denied = denylist
+		}
+		server.SetNetRestrictions(allowed, denied)
+	}
+	// Pass the configuration on to the services, dependencies first
+	for _, kind := range order {
+		if reloader, ok := services[kind].(Reloader); ok {
+			if err := reloader.Reload(config); err != nil {
+				return fmt.Errorf("%v: %v", kind, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DumpStatus logs the status of the node and of the services reporting one, such
+// as the progress of chain synchronisation, then writes the stack traces of all
+// goroutines to w.
+func (n *Node) DumpStatus(w io.Writer) error {
+	n.lock.RLock()
+	server, services, order := n.server, n.services, n.serviceOrder
+	n.lock.RUnlock()
+
+	if server == nil {
+		log.Info("Node status", "running", false)
+	} else {
+		log.Info("Node status", "running", true, "peers", server.PeerCount(), "rpcreqs", n.RPCRequests())
+		for _, kind := range order {
+			if reporter, ok := services[kind].(StatusReporter); ok {
+				log.Info("Service status", append([]interface{}{"service", serviceName(kind)}, reporter.Status()...)...)
+			}
+		}
+	}
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// serviceName returns the type name of a service, without the pointer marker.
+func serviceName(kind reflect.Type) string {
+	if kind.Kind() == reflect.Ptr {
+		kind = kind.Elem()
+	}
+	return kind.String()
+}
@@ -0,0 +1,192 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !windows
+
+package node
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// ReloadableService is a test service recording the configurations it reloads.
+type ReloadableService struct {
+	NoopService
+	reloads chan *ReloadConfig
+}
+
+func (s *ReloadableService) Reload(config *ReloadConfig) error {
+	s.reloads <- config
+	return nil
+}
+
+func (s *ReloadableService) Status() []interface{} { return []interface{}{"reloads", len(s.reloads)} }
+
+// Tests that the reload file is applied to the network restrictions and passed
+// on to the services, and that invalid files don't change anything.
+func TestNodeReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testNodeConfig()
+	config.ReloadFile = filepath.Join(dir, "reload.json")
+	config.ListenAddr = "127.0.0.1:0"
+	config.NoDiscovery = true
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	service := &ReloadableService{reloads: make(chan *ReloadConfig, 1)}
+	if err := stack.Register(func(*ServiceContext) (Service, error) { return service, nil }); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Reload(); err != ErrNodeStopped && !os.IsNotExist(err) {
+		t.Fatalf("reload of stopped node: unexpected error %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	// Apply a valid configuration and check the changes
+	if err := ioutil.WriteFile(config.ReloadFile, []byte(`{"netrestrict": "10.0.0.0/8", "netdeny": "1.2.3.0/24", "gasprice": 50000000000}`), 0600); err != nil {
+		t.Fatalf("failed to write reload file: %v", err)
+	}
+	if err := stack.Reload(); err != nil {
+		t.Fatalf("failed to reload configuration: %v", err)
+	}
+	whitelist, denylist := stack.Server().NetRestrictions()
+	if whitelist == nil || whitelist.String() != "10.0.0.0/8" {
+		t.Errorf("whitelist mismatch: have %v, want %v", whitelist, "10.0.0.0/8")
+	}
+	if denylist == nil || denylist.String() != "1.2.3.0/24" {
+		t.Errorf("denylist mismatch: have %v, want %v", denylist, "1.2.3.0/24")
+	}
+	select {
+	case reload := <-service.reloads:
+		if reload.GasPrice == nil || reload.GasPrice.Cmp(big.NewInt(50000000000)) != 0 {
+			t.Errorf("gas price mismatch: have %v, want %v", reload.GasPrice, 50000000000)
+		}
+	default:
+		t.Fatalf("service not reloaded")
+	}
+	// Invalid lists must be rejected without touching the node or the services
+	if err := ioutil.WriteFile(config.ReloadFile, []byte(`{"netrestrict": "", "netdeny": "1.2.3.4/44"}`), 0600); err != nil {
+		t.Fatalf("failed to write reload file: %v", err)
+	}
+	if err := stack.Reload(); err == nil {
+		t.Fatalf("invalid denylist accepted")
+	}
+	if whitelist, _ := stack.Server().NetRestrictions(); whitelist == nil {
+		t.Errorf("whitelist lifted by invalid configuration")
+	}
+	if len(service.reloads) > 0 {
+		t.Errorf("service reloaded with invalid configuration")
+	}
+	// Omitted settings must be left unchanged, empty whitelists lift the restriction
+	if err := ioutil.WriteFile(config.ReloadFile, []byte(`{"netrestrict": ""}`), 0600); err != nil {
+		t.Fatalf("failed to write reload file: %v", err)
+	}
+	if err := stack.Reload(); err != nil {
+		t.Fatalf("failed to reload configuration: %v", err)
+	}
+	whitelist, denylist = stack.Server().NetRestrictions()
+	if whitelist != nil {
+		t.Errorf("whitelist not lifted: %v", whitelist)
+	}
+	if denylist == nil || denylist.String() != "1.2.3.0/24" {
+		t.Errorf("denylist mismatch: have %v, want %v", denylist, "1.2.3.0/24")
+	}
+	if reload := <-service.reloads; reload.GasPrice != nil {
+		t.Errorf("omitted gas price reloaded: %v", reload.GasPrice)
+	}
+}
+
+// Tests that SIGHUP reloads the configuration of a node handling signals, and
+// that no reloads happen after signal handling is stopped.
+func TestNodeSignalReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testNodeConfig()
+	config.ReloadFile = filepath.Join(dir, "reload.json")
+	if err := ioutil.WriteFile(config.ReloadFile, []byte(`{"gasprice": 1}`), 0600); err != nil {
+		t.Fatalf("failed to write reload file: %v", err)
+	}
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	service := &ReloadableService{reloads: make(chan *ReloadConfig, 1)}
+	if err := stack.Register(func(*ServiceContext) (Service, error) { return service, nil }); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	stop := stack.HandleSignals()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to send SIGHUP: %v", err)
+	}
+	select {
+	case <-service.reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("configuration not reloaded")
+	}
+	stop()
+	stop() // stopping twice must be a noop
+}
+
+// Tests that status dumps contain the goroutine stacks.
+func TestNodeDumpStatus(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	service := &ReloadableService{reloads: make(chan *ReloadConfig, 1)}
+	if err := stack.Register(func(*ServiceContext) (Service, error) { return service, nil }); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	buf := new(bytes.Buffer)
+	if err := stack.DumpStatus(buf); err != nil {
+		t.Fatalf("failed to dump status: %v", err)
+	}
+	if !strings.Contains(buf.String(), "TestNodeDumpStatus") {
+		t.Errorf("stack of the dumping goroutine missing from the dump")
+	}
+}
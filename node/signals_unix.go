@@ -0,0 +1,71 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !windows
+
+package node
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/expanse-org/go-expanse/log"
+)
+
+// HandleSignals starts handling the runtime control signals of the node until the
+// returned function is called. SIGUSR1 dumps the node status and the goroutine
+// stacks to stderr, while SIGHUP reloads the configuration from the reload file.
+func (n *Node) HandleSignals() (stop func()) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGUSR1, syscall.SIGHUP)
+
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigc:
+				n.handleSignal(sig)
+			case <-quit:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigc)
+			close(quit)
+		})
+	}
+}
+
+// handleSignal executes the action bound to a received control signal.
+func (n *Node) handleSignal(sig os.Signal) {
+	switch sig {
+	case syscall.SIGUSR1:
+		log.Info("Got SIGUSR1, dumping status")
+		if err := n.DumpStatus(os.Stderr); err != nil {
+			log.Warn("Failed to dump goroutine stacks", "err", err)
+		}
+	case syscall.SIGHUP:
+		log.Info("Got SIGHUP, reloading configuration", "file", n.config.ReloadFile)
+		if err := n.Reload(); err != nil {
+			log.Warn("Failed to reload configuration", "err", err)
+		}
+	}
+}
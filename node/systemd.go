@@ -0,0 +1,113 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/rpc"
+)
+
+// sdListenFdsStart is the first file descriptor passed by socket activation.
+const sdListenFdsStart = 3
+
+// activatedSocket is a listener socket passed by systemd socket activation. The
+// file is kept open for the lifetime of the process, every endpoint (re)start
+// creates a new listener on a duplicate of it.
+type activatedSocket struct {
+	name string   // Name assigned with FileDescriptorName=
+	addr string   // Address the socket is bound to
+	file *os.File // Socket passed by systemd
+}
+
+// activatedSockets retrieves the listener sockets passed to the process by
+// systemd socket activation. The activation environment is cleared, so that the
+// sockets are neither picked up twice nor announced to child processes.
+func activatedSockets() ([]*activatedSocket, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	sockets := make([]*activatedSocket, 0, count)
+	for i := 0; i < count; i++ {
+		fd := sdListenFdsStart + i
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+
+		listener, err := net.FileListener(file)
+		if err != nil {
+			file.Close()
+			for _, socket := range sockets {
+				socket.file.Close()
+			}
+			return nil, fmt.Errorf("invalid activated socket %d: %v", fd, err)
+		}
+		socket := &activatedSocket{addr: listener.Addr().String(), file: file}
+		if i < len(names) {
+			socket.name = names[i]
+		}
+		listener.Close()
+		sockets = append(sockets, socket)
+	}
+	return sockets, nil
+}
+
+// listen opens the listener of an RPC endpoint, taking over the activated socket
+// matching the endpoint's name or address if there is one. Unix sockets are used
+// for the IPC endpoint, TCP ones otherwise.
+func (n *Node) listen(name string, endpoint string) (net.Listener, error) {
+	for _, socket := range n.sockets {
+		if socket.name == name || socket.addr == endpoint {
+			log.Info("Using activated socket", "endpoint", name, "addr", socket.addr)
+			return net.FileListener(socket.file)
+		}
+	}
+	if name == "ipc" {
+		return rpc.CreateIPCListener(endpoint)
+	}
+	return net.Listen("tcp", endpoint)
+}
+
+// sdNotify sends a state update to the service manager, if the process runs as
+// a Type=notify systemd unit. Otherwise it is a noop.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Tests that state notifications are sent to the socket of the service manager.
+func TestSdNotify(t *testing.T) {
+	defer os.Setenv("NOTIFY_SOCKET", os.Getenv("NOTIFY_SOCKET"))
+
+	// Without a notification socket, notifying must be a noop
+	os.Unsetenv("NOTIFY_SOCKET")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("failed to skip notification: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets not supported: %v", err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", path)
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	if state := string(buf[:n]); state != "READY=1" {
+		t.Errorf("state mismatch: have %q, want %q", state, "READY=1")
+	}
+}
+
+// Tests that activation is ignored if the sockets were passed to another process.
+func TestActivatedSocketsOtherProcess(t *testing.T) {
+	defer os.Setenv("LISTEN_PID", os.Getenv("LISTEN_PID"))
+	defer os.Setenv("LISTEN_FDS", os.Getenv("LISTEN_FDS"))
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	os.Setenv("LISTEN_FDS", "1")
+
+	sockets, err := activatedSockets()
+	if err != nil || sockets != nil {
+		t.Fatalf("sockets of other process taken over: %v, %v", sockets, err)
+	}
+	if os.Getenv("LISTEN_FDS") != "1" {
+		t.Errorf("activation environment of other process cleared")
+	}
+}
+
+// Tests that the RPC endpoints serve on the activated socket matching their
+// name, also after restarts.
+func TestActivatedSocketEndpoint(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to open listener: %v", err)
+	}
+	file, err := listener.(*net.TCPListener).File()
+	listener.Close()
+	if err != nil {
+		t.Fatalf("failed to retrieve socket: %v", err)
+	}
+	addr := listener.Addr().String()
+
+	config := testNodeConfig()
+	config.HTTPHost, config.HTTPPort = "127.0.0.1", 0
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	stack.sockets = []*activatedSocket{{name: "http", addr: addr, file: file}}
+	defer file.Close()
+
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	// Use fresh connections, the ones of the previous run are closed by restarts
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	for i := 0; i < 2; i++ {
+		res, err := client.Post("http://"+addr, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules"}`))
+		if err != nil {
+			t.Fatalf("iter %d: failed to call activated endpoint: %v", i, err)
+		}
+		blob, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if !strings.Contains(string(blob), `"result"`) {
+			t.Errorf("iter %d: invalid response: %s", i, blob)
+		}
+		if err := stack.Restart(); err != nil {
+			t.Fatalf("iter %d: failed to restart node: %v", i, err)
+		}
+	}
+}
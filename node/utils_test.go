@@ -20,6 +20,7 @@
 package node
 
 import (
+	"context"
 	"reflect"
 
 	"github.com/expanse-org/go-expanse/p2p"
@@ -123,6 +124,21 @@ func InstrumentedServiceMakerC(base ServiceConstructor) ServiceConstructor {
 	return InstrumentingWrapperMaker(base, reflect.TypeOf(InstrumentedServiceC{}))
 }
 
+// DependentInstrumentedService is an InstrumentedService additionally declaring
+// a set of explicit service dependencies.
+type DependentInstrumentedService struct {
+	InstrumentedService
+	deps []reflect.Type
+}
+
+func (s *DependentInstrumentedService) Dependencies() []reflect.Type {
+	return s.deps
+}
+
+// Set of dependent services wrapping the base DependentInstrumentedService.
+type DependentInstrumentedServiceA struct{ DependentInstrumentedService }
+type DependentInstrumentedServiceB struct{ DependentInstrumentedService }
+
 // OneMethodApi is a single-method API handler to be returned by test services.
 type OneMethodApi struct {
 	fun func()
@@ -133,3 +149,38 @@ func (api *OneMethodApi) TheOneMethod() {
 		api.fun()
 	}
 }
+
+// InstrumentedLifecycle is an implementation of Lifecycle for which both methods
+// can be instrumented both return value as well as event hook wise.
+type InstrumentedLifecycle struct {
+	start error
+	stop  error
+
+	startHook func(context.Context)
+	stopHook  func(context.Context)
+}
+
+func (l *InstrumentedLifecycle) Start(ctx context.Context) error {
+	if l.startHook != nil {
+		l.startHook(ctx)
+	}
+	return l.start
+}
+
+func (l *InstrumentedLifecycle) Stop(ctx context.Context) error {
+	if l.stopHook != nil {
+		l.stopHook(ctx)
+	}
+	return l.stop
+}
+
+// HangingLifecycle is an implementation of Lifecycle which doesn't stop until its
+// context expires.
+type HangingLifecycle struct{}
+
+func (HangingLifecycle) Start(context.Context) error { return nil }
+
+func (HangingLifecycle) Stop(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -34,6 +34,10 @@ const (
 	// redialing a certain node.
 	dialHistoryExpiration = 30 * time.Second
 
+	// Peers which turned out to be useless, e.g. by running on
+	// another network, aren't redialed for a much longer time.
+	uselessPeerExpiration = 10 * time.Minute
+
 	// Discovery lookups are throttled and can only run
 	// once every few seconds.
 	lookupInterval = 4 * time.Second
@@ -49,7 +53,7 @@ const (
 type dialstate struct {
 	maxDynDials int
 	ntab        discoverTable
-	netrestrict *netutil.Netlist
+	netrestrict *netutil.NetFilter
 
 	lookupRunning bool
 	dialing       map[discover.NodeID]connFlag
@@ -102,7 +106,53 @@ type waitExpireTask struct {
 	time.Duration
 }
 
-func newDialState(static []*discover.Node, ntab discoverTable, maxdyn int, netrestrict *netutil.Netlist) *dialstate {
+// taskQueue holds the tasks which can't run yet. Static dials, dynamic dials
+// and other tasks are queued separately and started in turns, so that a burst
+// of one kind, e.g. many static nodes added at once, doesn't hold up the others.
+type taskQueue struct {
+	queues [3][]task
+	next   int // index of the queue to take the next task from
+}
+
+// taskClass returns the index of the queue holding tasks like t.
+func taskClass(t task) int {
+	if t, ok := t.(*dialTask); ok {
+		if t.flags&staticDialedConn != 0 {
+			return 0
+		}
+		return 1
+	}
+	return 2
+}
+
+// push appends tasks to the queues of their kind.
+func (q *taskQueue) push(ts ...task) {
+	for _, t := range ts {
+		class := taskClass(t)
+		q.queues[class] = append(q.queues[class], t)
+	}
+}
+
+// pop removes and returns the next task, taking the queues in turns. It returns
+// nil if all queues are empty.
+func (q *taskQueue) pop() task {
+	for i := 0; i < len(q.queues); i++ {
+		class := (q.next + i) % len(q.queues)
+		if queue := q.queues[class]; len(queue) > 0 {
+			q.queues[class] = queue[1:]
+			q.next = (class + 1) % len(q.queues)
+			return queue[0]
+		}
+	}
+	return nil
+}
+
+// len returns the number of queued tasks.
+func (q *taskQueue) len() int {
+	return len(q.queues[0]) + len(q.queues[1]) + len(q.queues[2])
+}
+
+func newDialState(static []*discover.Node, ntab discoverTable, maxdyn int, netrestrict *netutil.NetFilter) *dialstate {
 	s := &dialstate{
 		maxDynDials: maxdyn,
 		ntab:        ntab,
@@ -157,11 +207,13 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	// Expire the dial history on every invocation.
 	s.hist.expire(now)
 
-	// Create dials for static nodes if they are not connected.
+	// Create dials for static nodes if they are not connected. Static nodes
+	// outside of the network restrictions are kept, as the restrictions may
+	// be changed at runtime.
 	for id, t := range s.static {
 		err := s.checkDial(t.dest, peers)
 		switch err {
-		case errNotWhitelisted, errSelf:
+		case errSelf:
 			log.Warn("Removing static dial candidate", "id", t.dest.ID, "addr", &net.TCPAddr{IP: t.dest.IP, Port: int(t.dest.TCP)}, "err", err)
 			delete(s.static, t.dest.ID)
 		case nil:
@@ -212,7 +264,6 @@ var (
 	errAlreadyDialing   = errors.New("already dialing")
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
-	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
 )
 
 func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer) error {
@@ -224,12 +275,10 @@ func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer)
 		return errAlreadyConnected
 	case s.ntab != nil && n.ID == s.ntab.Self().ID:
 		return errSelf
-	case s.netrestrict != nil && !s.netrestrict.Contains(n.IP):
-		return errNotWhitelisted
 	case s.hist.contains(n.ID):
 		return errRecentlyDialed
 	}
-	return nil
+	return s.netrestrict.Check(n.IP)
 }
 
 func (s *dialstate) taskDone(t task, now time.Time) {
@@ -243,6 +292,23 @@ func (s *dialstate) taskDone(t task, now time.Time) {
 	}
 }
 
+// setMaxDynDials changes the number of peers dialed from the discovery table.
+// Dynamically dialed peers above the new quota are not dropped here, the server
+// sheds them.
+func (s *dialstate) setMaxDynDials(n int) {
+	s.maxDynDials = n
+	s.randomNodes = make([]*discover.Node, n/2)
+}
+
+// markUseless keeps the node from being redialed for a while after it turned
+// out to share nothing useful with us. Static nodes are dialed regardless.
+func (s *dialstate) markUseless(id discover.NodeID, now time.Time) {
+	if _, ok := s.static[id]; ok {
+		return
+	}
+	s.hist.add(id, now.Add(uselessPeerExpiration))
+}
+
 func (t *dialTask) Do(srv *Server) {
 	if t.dest.Incomplete() {
 		if !t.resolve(srv) {
@@ -300,7 +366,7 @@ func (t *dialTask) dial(srv *Server, dest *discover.Node) bool {
 		log.Trace("Dial error", "task", t, "err", err)
 		return false
 	}
-	mfd := newMeteredConn(fd, false)
+	mfd := newMeteredConn(fd, false, srv.meters)
 	srv.setupConn(mfd, t.flags, dest)
 	return true
 }
@@ -332,7 +332,7 @@ func TestDialStateNetRestrict(t *testing.T) {
 	restrict.Add("127.0.2.0/24")
 
 	runDialTest(t, dialtest{
-		init: newDialState(nil, table, 10, restrict),
+		init: newDialState(nil, table, 10, netutil.NewNetFilter(restrict, nil)),
 		rounds: []round{
 			{
 				new: []task{
@@ -344,6 +344,62 @@ func TestDialStateNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that candidates that match the netrestrict denylist are not dialed.
+func TestDialStateNetDeny(t *testing.T) {
+	// This table always returns the same random nodes
+	// in the order given below.
+	table := fakeTable{
+		{ID: uintID(1), IP: net.ParseIP("127.0.0.1")},
+		{ID: uintID(2), IP: net.ParseIP("127.0.0.2")},
+		{ID: uintID(3), IP: net.ParseIP("127.0.0.3")},
+		{ID: uintID(4), IP: net.ParseIP("127.0.0.4")},
+		{ID: uintID(5), IP: net.ParseIP("127.0.2.5")},
+		{ID: uintID(6), IP: net.ParseIP("127.0.2.6")},
+	}
+	deny := new(netutil.Netlist)
+	deny.Add("127.0.0.0/30")
+
+	runDialTest(t, dialtest{
+		init: newDialState(nil, table, 10, netutil.NewNetFilter(nil, deny)),
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: table[3]},
+					&dialTask{flags: dynDialedConn, dest: table[4]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
+// This test checks that peers found useless aren't redialed for a while,
+// unless they are static nodes.
+func TestDialStateUselessPeer(t *testing.T) {
+	table := fakeTable{
+		{ID: uintID(2), IP: net.ParseIP("127.0.0.2")},
+		{ID: uintID(3), IP: net.ParseIP("127.0.0.3")},
+	}
+	static := []*discover.Node{{ID: uintID(1), IP: net.ParseIP("127.0.0.1")}}
+
+	state := newDialState(static, table, 10, nil)
+	state.markUseless(uintID(1), time.Time{})
+	state.markUseless(uintID(2), time.Time{})
+
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: staticDialedConn, dest: static[0]},
+					&dialTask{flags: dynDialedConn, dest: table[1]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
 // This test checks that static dials are launched.
 func TestDialStateStaticDial(t *testing.T) {
 	wantStatic := []*discover.Node{
@@ -562,3 +618,29 @@ func (t *resolveMock) Close()                                   {}
 func (t *resolveMock) Bootstrap([]*discover.Node)               {}
 func (t *resolveMock) Lookup(discover.NodeID) []*discover.Node  { return nil }
 func (t *resolveMock) ReadRandomNodes(buf []*discover.Node) int { return 0 }
+
+// This test checks that queued tasks of different kinds are started in turns.
+func TestTaskQueueFairness(t *testing.T) {
+	var (
+		static1  = &dialTask{flags: staticDialedConn, dest: &discover.Node{ID: uintID(1)}}
+		static2  = &dialTask{flags: staticDialedConn, dest: &discover.Node{ID: uintID(2)}}
+		static3  = &dialTask{flags: staticDialedConn, dest: &discover.Node{ID: uintID(3)}}
+		dynamic1 = &dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(4)}}
+		dynamic2 = &dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(5)}}
+		lookup   = &discoverTask{}
+	)
+	q := new(taskQueue)
+	q.push(static1, static2, static3, dynamic1, dynamic2, lookup)
+	if q.len() != 6 {
+		t.Fatalf("queue length mismatch: have %d, want %d", q.len(), 6)
+	}
+	want := []task{static1, dynamic1, lookup, static2, dynamic2, static3}
+	for i, w := range want {
+		if have := q.pop(); have != w {
+			t.Errorf("task %d mismatch: have %v, want %v", i, have, w)
+		}
+	}
+	if have := q.pop(); have != nil {
+		t.Errorf("task popped from empty queue: %v", have)
+	}
+}
@@ -123,6 +123,17 @@ func (tab *Table) Self() *Node {
 	return tab.self
 }
 
+// Len returns the number of nodes currently in the table.
+func (tab *Table) Len() (n int) {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	for _, b := range tab.buckets {
+		n += len(b.entries)
+	}
+	return n
+}
+
 // ReadRandomNodes fills the given slice with random nodes from the
 // table. It will not write the same node more than once. The nodes in
 // the slice are copies and can be modified by the caller.
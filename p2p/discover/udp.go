@@ -133,8 +133,8 @@ func (t *udp) nodeFromRPC(sender *net.UDPAddr, rn rpcNode) (*Node, error) {
 	if err := netutil.CheckRelayIP(sender.IP, rn.IP); err != nil {
 		return nil, err
 	}
-	if t.netrestrict != nil && !t.netrestrict.Contains(rn.IP) {
-		return nil, errors.New("not contained in netrestrict whitelist")
+	if err := t.netrestrict.Check(rn.IP); err != nil {
+		return nil, err
 	}
 	n := NewNode(rn.ID, rn.IP, rn.UDP, rn.TCP)
 	err := n.validateComplete()
@@ -160,7 +160,7 @@ type conn interface {
 // udp implements the RPC protocol.
 type udp struct {
 	conn        conn
-	netrestrict *netutil.Netlist
+	netrestrict *netutil.NetFilter
 	priv        *ecdsa.PrivateKey
 	ourEndpoint rpcEndpoint
 
@@ -211,7 +211,7 @@ type reply struct {
 }
 
 // ListenUDP returns a new table that listens for UDP packets on laddr.
-func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, nodeDBPath string, netrestrict *netutil.Netlist) (*Table, error) {
+func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, nodeDBPath string, netrestrict *netutil.NetFilter) (*Table, error) {
 	addr, err := net.ResolveUDPAddr("udp", laddr)
 	if err != nil {
 		return nil, err
@@ -228,7 +228,7 @@ func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, nodeDBP
 	return tab, nil
 }
 
-func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface, nodeDBPath string, netrestrict *netutil.Netlist) (*Table, *udp, error) {
+func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface, nodeDBPath string, netrestrict *netutil.NetFilter) (*Table, *udp, error) {
 	udp := &udp{
 		conn:        c,
 		priv:        priv,
@@ -465,6 +465,7 @@ func (t *udp) send(toaddr *net.UDPAddr, ptype byte, req packet) error {
 		return err
 	}
 	_, err = t.conn.WriteToUDP(packet, toaddr)
+	egressPacketMeter.Mark(1)
 	log.Trace(">> "+req.name(), "addr", toaddr, "err", err)
 	return err
 }
@@ -514,8 +515,15 @@ func (t *udp) readLoop() {
 }
 
 func (t *udp) handlePacket(from *net.UDPAddr, buf []byte) error {
+	ingressPacketMeter.Mark(1)
+	if err := t.netrestrict.Check(from.IP); err != nil {
+		filteredPacketMeter.Mark(1)
+		log.Trace("Ignoring discv4 packet", "addr", from, "err", err)
+		return err
+	}
 	packet, fromID, hash, err := decodePacket(buf)
 	if err != nil {
+		badPacketMeter.Mark(1)
 		log.Debug("Bad discv4 packet", "addr", from, "err", err)
 		return err
 	}
@@ -19,46 +19,150 @@
 package p2p
 
 import (
+	"fmt"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/expanse-org/go-expanse/metrics"
+	gometrics "github.com/rcrowley/go-metrics"
 )
 
-var (
-	ingressConnectMeter = metrics.NewMeter("p2p/InboundConnects")
-	ingressTrafficMeter = metrics.NewMeter("p2p/InboundTraffic")
-	egressConnectMeter  = metrics.NewMeter("p2p/OutboundConnects")
-	egressTrafficMeter  = metrics.NewMeter("p2p/OutboundTraffic")
-)
+// discoveryMeterInterval is the interval at which the discovery table size is
+// reported in bootnode mode.
+const discoveryMeterInterval = 10 * time.Second
+
+// serverMeters is the set of networking meters reported by a single server,
+// registered within the metrics registry configured for it.
+type serverMeters struct {
+	ingressConnect gometrics.Meter
+	ingressTraffic gometrics.Meter
+	egressConnect  gometrics.Meter
+	egressTraffic  gometrics.Meter
+
+	handshakeErrors gometrics.Meter    // Connections failing the RLPx handshakes
+	discoveryNodes  gometrics.Gauge    // Nodes in the discovery table, reported in bootnode mode
+	registry        gometrics.Registry // Registry to lazily create the per-protocol and per-reason meters in
+}
+
+// newServerMeters creates the networking meters within the given registry, or
+// the default one if nil.
+func newServerMeters(registry gometrics.Registry) *serverMeters {
+	return &serverMeters{
+		ingressConnect: metrics.NewRegisteredMeter("p2p/InboundConnects", registry),
+		ingressTraffic: metrics.NewRegisteredMeter("p2p/InboundTraffic", registry),
+		egressConnect:  metrics.NewRegisteredMeter("p2p/OutboundConnects", registry),
+		egressTraffic:  metrics.NewRegisteredMeter("p2p/OutboundTraffic", registry),
+
+		handshakeErrors: metrics.NewRegisteredMeter("p2p/HandshakeErrors", registry),
+		discoveryNodes:  metrics.NewRegisteredGauge("p2p/DiscoveryNodes", registry),
+		registry:        registry,
+	}
+}
+
+// markHandshakeError bumps the meter of connections failing the encryption or
+// protocol handshake.
+func (m *serverMeters) markHandshakeError() {
+	if m != nil {
+		m.handshakeErrors.Mark(1)
+	}
+}
+
+// markNegotiated bumps the meters of the subprotocols negotiated with a peer, or
+// the meter of peers sharing no protocols with us if there are none.
+func (m *serverMeters) markNegotiated(running map[string]*protoRW) {
+	if m == nil {
+		return
+	}
+	if len(running) == 0 {
+		metrics.NewRegisteredMeter("p2p/Negotiated/None", m.registry).Mark(1)
+		return
+	}
+	for _, proto := range running {
+		name := fmt.Sprintf("p2p/Negotiated/%s/%d", proto.Name, proto.Version)
+		metrics.NewRegisteredMeter(name, m.registry).Mark(1)
+	}
+}
+
+// meterDiscovery periodically reports the number of nodes in the discovery
+// table, the main health indicator of a server running in bootnode mode.
+func (srv *Server) meterDiscovery() {
+	defer srv.loopWG.Done()
+
+	ticker := time.NewTicker(discoveryMeterInterval)
+	defer ticker.Stop()
+
+	for {
+		if tab, ok := srv.ntab.(interface {
+			Len() int
+		}); ok {
+			srv.meters.discoveryNodes.Update(int64(tab.Len()))
+		}
+		select {
+		case <-ticker.C:
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
+// markDisconnect bumps the meter of the reason a peer got disconnected for,
+// split by which side requested the disconnect.
+func (m *serverMeters) markDisconnect(reason DiscReason, remote bool) {
+	if m == nil {
+		return
+	}
+	side := "Local"
+	if remote {
+		side = "Remote"
+	}
+	metrics.NewRegisteredMeter("p2p/Disconnects/"+side+"/"+reasonMeterName(reason), m.registry).Mark(1)
+}
+
+// reasonMeterName converts a disconnect reason into a camel cased meter name,
+// e.g. "too many peers" into "TooManyPeers". Reasons unknown to us are metered
+// together, as remote peers may send arbitrary ones.
+func reasonMeterName(reason DiscReason) string {
+	if int(reason) >= len(discReasonToString) || discReasonToString[reason] == "" {
+		return "Unknown"
+	}
+	words := strings.Fields(discReasonToString[reason])
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, "")
+}
 
 // meteredConn is a wrapper around a network TCP connection that meters both the
 // inbound and outbound network traffic.
 type meteredConn struct {
 	*net.TCPConn // Network connection to wrap with metering
+
+	meters *serverMeters // Meters to report the traffic into
 }
 
 // newMeteredConn creates a new metered connection, also bumping the ingress or
 // egress connection meter. If the metrics system is disabled, this function
 // returns the original object.
-func newMeteredConn(conn net.Conn, ingress bool) net.Conn {
+func newMeteredConn(conn net.Conn, ingress bool, meters *serverMeters) net.Conn {
 	// Short circuit if metrics are disabled
-	if !metrics.Enabled {
+	if !metrics.Enabled || meters == nil {
 		return conn
 	}
 	// Otherwise bump the connection counters and wrap the connection
 	if ingress {
-		ingressConnectMeter.Mark(1)
+		meters.ingressConnect.Mark(1)
 	} else {
-		egressConnectMeter.Mark(1)
+		meters.egressConnect.Mark(1)
 	}
-	return &meteredConn{conn.(*net.TCPConn)}
+	return &meteredConn{TCPConn: conn.(*net.TCPConn), meters: meters}
 }
 
 // Read delegates a network read to the underlying connection, bumping the ingress
 // traffic meter along the way.
 func (c *meteredConn) Read(b []byte) (n int, err error) {
 	n, err = c.TCPConn.Read(b)
-	ingressTrafficMeter.Mark(int64(n))
+	c.meters.ingressTraffic.Mark(int64(n))
 	return
 }
 
@@ -66,6 +170,6 @@ func (c *meteredConn) Read(b []byte) (n int, err error) {
 // egress traffic meter along the way.
 func (c *meteredConn) Write(b []byte) (n int, err error) {
 	n, err = c.TCPConn.Write(b)
-	egressTrafficMeter.Mark(int64(n))
+	c.meters.egressTraffic.Mark(int64(n))
 	return
 }
@@ -0,0 +1,91 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package netutil
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+var (
+	errNotWhitelisted = errors.New("not contained in netrestrict whitelist")
+	errDenylisted     = errors.New("contained in netrestrict denylist")
+)
+
+// NetFilter restricts communication to IP addresses contained in a whitelist
+// of networks and not contained in a denylist. Both lists may be replaced while
+// the filter is in use, which allows changing the restrictions of a running
+// node. A nil NetFilter allows all addresses.
+type NetFilter struct {
+	lock      sync.RWMutex
+	whitelist *Netlist // Only applies when non-nil
+	denylist  *Netlist
+}
+
+// NewNetFilter creates a filter from the given whitelist and denylist, either
+// of which may be nil.
+func NewNetFilter(whitelist, denylist *Netlist) *NetFilter {
+	return &NetFilter{whitelist: whitelist, denylist: denylist}
+}
+
+// Check returns an error if communication with the given IP is not allowed.
+func (f *NetFilter) Check(ip net.IP) error {
+	if f == nil {
+		return nil
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if f.whitelist != nil && !f.whitelist.Contains(ip) {
+		return errNotWhitelisted
+	}
+	if f.denylist.Contains(ip) {
+		return errDenylisted
+	}
+	return nil
+}
+
+// Allowed reports whether communication with the given IP is allowed.
+func (f *NetFilter) Allowed(ip net.IP) bool {
+	return f.Check(ip) == nil
+}
+
+// Lists returns the current whitelist and denylist of the filter.
+func (f *NetFilter) Lists() (whitelist, denylist *Netlist) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.whitelist, f.denylist
+}
+
+// SetWhitelist replaces the whitelist of the filter. A nil list lifts the
+// restriction to whitelisted networks.
+func (f *NetFilter) SetWhitelist(l *Netlist) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.whitelist = l
+}
+
+// SetDenylist replaces the denylist of the filter.
+func (f *NetFilter) SetDenylist(l *Netlist) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.denylist = l
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package netutil
+
+import (
+	"testing"
+)
+
+func TestNilNetFilterAllowed(t *testing.T) {
+	var filter *NetFilter
+	checkContains(t, filter.Allowed, []string{"1.2.3.4", "::1"}, nil)
+}
+
+func TestNetFilter(t *testing.T) {
+	whitelist, _ := ParseNetlist("10.0.0.0/8, 192.168.0.0/16")
+	denylist, _ := ParseNetlist("10.1.0.0/16")
+	filter := NewNetFilter(whitelist, denylist)
+
+	checkContains(t, filter.Allowed,
+		[]string{"10.0.0.1", "10.2.3.4", "192.168.1.1"},
+		[]string{"10.1.0.1", "10.1.255.255", "172.16.0.1", "1.2.3.4"},
+	)
+	// Lifting the whitelist should only leave the denylist in effect
+	filter.SetWhitelist(nil)
+	checkContains(t, filter.Allowed,
+		[]string{"10.0.0.1", "172.16.0.1", "1.2.3.4"},
+		[]string{"10.1.0.1"},
+	)
+	// Replacing the denylist should allow the previously denied networks
+	replacement, _ := ParseNetlist("1.2.3.0/24")
+	filter.SetDenylist(replacement)
+	checkContains(t, filter.Allowed,
+		[]string{"10.1.0.1", "1.2.4.1"},
+		[]string{"1.2.3.4"},
+	)
+	if whitelist, denylist := filter.Lists(); whitelist != nil || denylist.String() != "1.2.3.0/24" {
+		t.Errorf("lists mismatch: got %v and %v, want <nil> and 1.2.3.0/24", whitelist, denylist)
+	}
+}
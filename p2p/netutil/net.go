@@ -107,6 +107,16 @@ func (l *Netlist) Contains(ip net.IP) bool {
 	return false
 }
 
+// String returns the comma-separated CIDR masks of the list, in the format
+// accepted by ParseNetlist.
+func (l Netlist) String() string {
+	masks := make([]string, len(l))
+	for i, n := range l {
+		masks[i] = n.String()
+	}
+	return strings.Join(masks, ",")
+}
+
 // IsLAN reports whether an IP is a local network address.
 func IsLAN(ip net.IP) bool {
 	if ip.IsLoopback() {
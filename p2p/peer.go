@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/event"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/p2p/discover"
 	"github.com/expanse-org/go-expanse/rlp"
@@ -71,6 +72,9 @@ type Peer struct {
 	protoErr chan error
 	closed   chan struct{}
 	disc     chan DiscReason
+
+	// events receives message errors, if set
+	events *event.Feed
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -98,6 +102,11 @@ func (p *Peer) Caps() []Cap {
 	return p.rw.caps
 }
 
+// Inbound returns whether the connection to the peer was initiated remotely.
+func (p *Peer) Inbound() bool {
+	return p.rw.is(inboundConn)
+}
+
 // RemoteAddr returns the remote address of the network connection.
 func (p *Peer) RemoteAddr() net.Addr {
 	return p.rw.fd.RemoteAddr()
@@ -140,12 +149,11 @@ func (p *Peer) Log() log.Logger {
 	return p.log
 }
 
-func (p *Peer) run() (remoteRequested bool, err error) {
+func (p *Peer) run() (remoteRequested bool, reason DiscReason, err error) {
 	var (
 		writeStart = make(chan struct{}, 1)
 		writeErr   = make(chan error, 1)
 		readErr    = make(chan error, 1)
-		reason     DiscReason // sent to the peer
 	)
 	p.wg.Add(2)
 	go p.readLoop(readErr)
@@ -176,9 +184,13 @@ loop:
 			}
 			break loop
 		case err = <-p.protoErr:
+			if _, ok := err.(DiscReason); !ok && err != errProtocolReturned && p.events != nil {
+				p.events.Send(p.newEvent(PeerEventTypeMsgError, err))
+			}
 			reason = discReasonForError(err)
 			break loop
 		case err = <-p.disc:
+			reason = discReasonForError(err)
 			break loop
 		}
 	}
@@ -186,7 +198,7 @@ loop:
 	close(p.closed)
 	p.rw.close(reason)
 	p.wg.Wait()
-	return remoteRequested, err
+	return remoteRequested, reason, err
 }
 
 func (p *Peer) pingLoop() {
@@ -406,3 +418,52 @@ func (p *Peer) Info() *PeerInfo {
 	}
 	return info
 }
+
+// PeerEventType is the type of peer events emitted by a p2p.Server
+type PeerEventType string
+
+const (
+	// PeerEventTypeAdd is the type of event emitted when a peer is added
+	// to a p2p.Server
+	PeerEventTypeAdd PeerEventType = "add"
+
+	// PeerEventTypeDrop is the type of event emitted when a peer is
+	// dropped from a p2p.Server
+	PeerEventTypeDrop PeerEventType = "drop"
+
+	// PeerEventTypeMsgError is the type of event emitted when a protocol
+	// of a peer fails handling a message, right before the peer is dropped
+	PeerEventTypeMsgError PeerEventType = "msgerror"
+)
+
+// PeerEvent is an event emitted when peers are either added or dropped from
+// a p2p.Server or when one of their protocols fails.
+type PeerEvent struct {
+	Type          PeerEventType `json:"type"`
+	ID            string        `json:"id"`              // Unique node identifier of the peer
+	Enode         string        `json:"enode"`           // Enode URL of the peer as seen from the local node
+	RemoteAddress string        `json:"remoteAddress"`   // Remote endpoint of the TCP data connection
+	Protocols     []string      `json:"protocols"`       // Sub-protocols advertised by the peer
+	Error         string        `json:"error,omitempty"` // Disconnect reason or message error
+}
+
+// newEvent assembles a peer event of the given type, with err describing the
+// cause of drops and message errors.
+func (p *Peer) newEvent(typ PeerEventType, err error) *PeerEvent {
+	ev := &PeerEvent{
+		Type:          typ,
+		ID:            p.ID().String(),
+		RemoteAddress: p.RemoteAddr().String(),
+		Protocols:     make([]string, 0, len(p.Caps())),
+	}
+	for _, cap := range p.Caps() {
+		ev.Protocols = append(ev.Protocols, cap.String())
+	}
+	if addr, ok := p.RemoteAddr().(*net.TCPAddr); ok {
+		ev.Enode = discover.NewNode(p.ID(), addr.IP, uint16(addr.Port), uint16(addr.Port)).String()
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	return ev
+}
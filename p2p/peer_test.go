@@ -24,6 +24,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/expanse-org/go-expanse/event"
 )
 
 var discard = Protocol{
@@ -55,7 +57,7 @@ func testPeer(protos []Protocol) (func(), *conn, *Peer, <-chan error) {
 	peer := newPeer(c1, protos)
 	errc := make(chan error, 1)
 	go func() {
-		_, err := peer.run()
+		_, _, err := peer.run()
 		errc <- err
 	}()
 
@@ -120,6 +122,50 @@ func TestPeerProtoEncodeMsg(t *testing.T) {
 	}
 }
 
+func TestPeerMsgErrorEvent(t *testing.T) {
+	proto := Protocol{
+		Name:   "a",
+		Length: 1,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			if _, err := rw.ReadMsg(); err != nil {
+				return err
+			}
+			return errors.New("invalid message")
+		},
+	}
+	fd1, fd2 := net.Pipe()
+	c1 := &conn{fd: fd1, transport: newTestTransport(randomID(), fd1), caps: []Cap{proto.cap()}}
+	c2 := &conn{fd: fd2, transport: newTestTransport(randomID(), fd2), caps: []Cap{proto.cap()}}
+	defer c2.close(errors.New("test done"))
+
+	var feed event.Feed
+	events := make(chan *PeerEvent, 1)
+	sub := feed.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	peer := newPeer(c1, []Protocol{proto})
+	peer.events = &feed
+	go peer.run()
+
+	if err := SendItems(c2, baseProtocolLength); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Type != PeerEventTypeMsgError {
+			t.Errorf("event type mismatch: got %q, want %q", ev.Type, PeerEventTypeMsgError)
+		}
+		if ev.Error != "invalid message" {
+			t.Errorf("event error mismatch: got %q, want %q", ev.Error, "invalid message")
+		}
+		if !reflect.DeepEqual(ev.Protocols, []string{"a/0"}) {
+			t.Errorf("event protocols mismatch: got %v, want %v", ev.Protocols, []string{"a/0"})
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message error event")
+	}
+}
+
 func TestPeerPing(t *testing.T) {
 	closer, rw, _, _ := testPeer(nil)
 	defer closer()
@@ -309,3 +355,23 @@ func TestMatchProtocols(t *testing.T) {
 		}
 	}
 }
+
+func TestDiscReasonMeterName(t *testing.T) {
+	tests := []struct {
+		reason DiscReason
+		want   string
+	}{
+		{DiscRequested, "DisconnectRequested"},
+		{DiscTooManyPeers, "TooManyPeers"},
+		{DiscUselessPeer, "UselessPeer"},
+		{DiscSubprotocolError, "SubprotocolError"},
+		{DiscReason(0x0d), "Unknown"},
+		{DiscReason(0x11), "Unknown"},
+		{DiscReason(0xff), "Unknown"},
+	}
+	for _, test := range tests {
+		if name := reasonMeterName(test.reason); name != test.want {
+			t.Errorf("reason %d: meter name mismatch: got %q, want %q", test.reason, name, test.want)
+		}
+	}
+}
@@ -22,16 +22,20 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/expanse-org/go-expanse/common"
 	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/event"
 	"github.com/expanse-org/go-expanse/log"
 	"github.com/expanse-org/go-expanse/p2p/discover"
 	"github.com/expanse-org/go-expanse/p2p/discv5"
 	"github.com/expanse-org/go-expanse/p2p/nat"
 	"github.com/expanse-org/go-expanse/p2p/netutil"
+	gometrics "github.com/rcrowley/go-metrics"
 )
 
 const (
@@ -45,6 +49,9 @@ const (
 	// Maximum number of concurrently dialing outbound connections.
 	maxActiveDialTasks = 16
 
+	// Default ratio of peer slots to dynamically dialed connections.
+	defaultDialRatio = 2
+
 	// Maximum time allowed for reading a complete message.
 	// This is effectively the amount of time a connection can be idle.
 	frameReadTimeout = 30 * time.Second
@@ -53,7 +60,11 @@ const (
 	frameWriteTimeout = 20 * time.Second
 )
 
-var errServerStopped = errors.New("server stopped")
+var (
+	errServerStopped       = errors.New("server stopped")
+	errBootnodeNoDiscovery = errors.New("bootnode mode requires peer discovery")
+	errBootnodeProtocols   = errors.New("bootnode mode doesn't run protocols")
+)
 
 // Config holds Server options.
 type Config struct {
@@ -61,7 +72,8 @@ type Config struct {
 	PrivateKey *ecdsa.PrivateKey
 
 	// MaxPeers is the maximum number of peers that can be
-	// connected. It must be greater than zero.
+	// connected. It must be greater than zero. It may be
+	// changed on a running server through SetMaxPeers.
 	MaxPeers int
 
 	// MaxPendingPeers is the maximum number of peers that can be pending in the
@@ -69,6 +81,15 @@ type Config struct {
 	// Zero defaults to preset values.
 	MaxPendingPeers int
 
+	// DialRatio controls the share of the peer slots used for connections
+	// dialed from the discovery table, the rest is left to inbound ones.
+	// A ratio of 3 dials a third of MaxPeers. Zero defaults to 2.
+	DialRatio int
+
+	// Slots, if set to a non-nil value, shares the peer slots among the
+	// protocols running on the server. Otherwise a new one is created.
+	Slots *PeerSlots
+
 	// Discovery specifies whether the peer discovery mechanism should be started
 	// or not. Disabling is usually useful for protocol debugging (manual topology).
 	Discovery bool
@@ -106,6 +127,10 @@ type Config struct {
 	// IP networks contained in the list are considered.
 	NetRestrict *netutil.Netlist
 
+	// NetDeny lists IP networks communication is never established with, even
+	// if they are contained in NetRestrict.
+	NetDeny *netutil.Netlist
+
 	// NodeDatabase is the path to the database containing the previously seen
 	// live nodes in the network.
 	NodeDatabase string
@@ -134,6 +159,15 @@ type Config struct {
 
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool
+
+	// BootnodeMode runs only the peer discovery protocols, serving as a bootstrap
+	// node of the network. The server neither listens for nor dials any peer
+	// connections, so no Protocols may be set.
+	BootnodeMode bool
+
+	// MetricsRegistry, if set to a non-nil value, is the registry the networking
+	// metrics are reported into. Otherwise the default registry is used.
+	MetricsRegistry gometrics.Registry
 }
 
 // Server manages all peer connections.
@@ -146,8 +180,14 @@ type Server struct {
 	newTransport func(net.Conn) transport
 	newPeerHook  func(*Peer)
 
-	lock    sync.Mutex // protects running
-	running bool
+	lock      sync.Mutex   // protects running
+	limitLock sync.RWMutex // protects MaxPeers, which SetMaxPeers changes at runtime
+	running   bool
+	meters    *serverMeters
+	slots     *PeerSlots
+
+	netfilter *netutil.NetFilter // NetRestrict and NetDeny, updatable at runtime
+	peerFeed  event.Feed
 
 	ntab         discoverTable
 	listener     net.Listener
@@ -159,9 +199,15 @@ type Server struct {
 	peerOp     chan peerOpFunc
 	peerOpDone chan struct{}
 
+	runningDials int32 // Number of dial tasks in progress (atomic)
+	queuedDials  int32 // Number of dial tasks waiting for a free slot (atomic)
+
 	quit          chan struct{}
 	addstatic     chan *discover.Node
 	removestatic  chan *discover.Node
+	addtrusted    chan *discover.Node
+	removetrusted chan *discover.Node
+	rebalance     chan struct{}
 	posthandshake chan *conn
 	addpeer       chan *conn
 	delpeer       chan peerDrop
@@ -173,10 +219,11 @@ type peerOpFunc func(map[discover.NodeID]*Peer)
 type peerDrop struct {
 	*Peer
 	err       error
-	requested bool // true if signaled by the peer
+	reason    DiscReason // reason the peer was disconnected for
+	requested bool       // true if signaled by the peer
 }
 
-type connFlag int
+type connFlag int32
 
 const (
 	dynDialedConn connFlag = 1 << iota
@@ -190,7 +237,7 @@ const (
 type conn struct {
 	fd net.Conn
 	transport
-	flags connFlag
+	flags connFlag        // Accessed atomically, the trusted flag may change while connected
 	cont  chan error      // The run loop uses cont to signal errors to setupConn.
 	id    discover.NodeID // valid after the encryption handshake
 	caps  []Cap           // valid after the protocol handshake
@@ -241,7 +288,24 @@ func (f connFlag) String() string {
 }
 
 func (c *conn) is(f connFlag) bool {
-	return c.flags&f != 0
+	flags := connFlag(atomic.LoadInt32((*int32)(&c.flags)))
+	return flags&f != 0
+}
+
+// set sets or clears the given flags of the connection.
+func (c *conn) set(f connFlag, val bool) {
+	for {
+		oldFlags := connFlag(atomic.LoadInt32((*int32)(&c.flags)))
+		flags := oldFlags
+		if val {
+			flags |= f
+		} else {
+			flags &= ^f
+		}
+		if atomic.CompareAndSwapInt32((*int32)(&c.flags), int32(oldFlags), int32(flags)) {
+			return
+		}
+	}
 }
 
 // Peers returns all connected peers.
@@ -262,6 +326,49 @@ func (srv *Server) Peers() []*Peer {
 	return ps
 }
 
+// NetRestrictions returns the IP networks communication is currently restricted
+// to and the ones it is denied with. A nil whitelist means no restriction.
+func (srv *Server) NetRestrictions() (whitelist, denylist *netutil.Netlist) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if srv.netfilter == nil {
+		return srv.NetRestrict, srv.NetDeny
+	}
+	return srv.netfilter.Lists()
+}
+
+// SetNetRestrictions replaces the IP networks communication is restricted to and
+// the ones it is denied with, disconnecting peers which are no longer allowed.
+// The lists apply to discovery, dialing and inbound connections alike.
+func (srv *Server) SetNetRestrictions(whitelist, denylist *netutil.Netlist) {
+	srv.lock.Lock()
+	if srv.netfilter == nil {
+		srv.netfilter = netutil.NewNetFilter(whitelist, denylist)
+	} else {
+		srv.netfilter.SetWhitelist(whitelist)
+		srv.netfilter.SetDenylist(denylist)
+	}
+	running, filter := srv.running, srv.netfilter
+	srv.lock.Unlock()
+
+	if !running {
+		return
+	}
+	for _, p := range srv.Peers() {
+		if tcp, ok := p.RemoteAddr().(*net.TCPAddr); ok && !filter.Allowed(tcp.IP) {
+			p.log.Debug("Dropping peer outside of network restrictions", "addr", tcp)
+			p.Disconnect(DiscRequested)
+		}
+	}
+}
+
+// SubscribeEvents subscribes the given channel to peer events, which are sent
+// when peers are added, dropped or fail handling a message.
+func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
+	return srv.peerFeed.Subscribe(ch)
+}
+
 // PeerCount returns the number of connected peers.
 func (srv *Server) PeerCount() int {
 	var count int
@@ -273,6 +380,59 @@ func (srv *Server) PeerCount() int {
 	return count
 }
 
+// PeerCounts is a breakdown of a number of peers by connection direction.
+type PeerCounts struct {
+	Total    int `json:"total"`
+	Inbound  int `json:"inbound"`  // Peers which connected to us
+	Outbound int `json:"outbound"` // Peers we dialed
+}
+
+// add counts a peer of the given direction.
+func (c *PeerCounts) add(inbound bool) {
+	c.Total++
+	if inbound {
+		c.Inbound++
+	} else {
+		c.Outbound++
+	}
+}
+
+// ConnectivityInfo is a summary of the connectivity of the server, meant for
+// diagnosing peering problems.
+type ConnectivityInfo struct {
+	Peers       PeerCounts            `json:"peers"`       // All connected peers
+	Protocols   map[string]PeerCounts `json:"protocols"`   // Peers running each sub-protocol
+	Dialing     int                   `json:"dialing"`     // Dial tasks in progress
+	QueuedDials int                   `json:"queuedDials"` // Dial tasks waiting for a free slot
+	TableSize   int                   `json:"tableSize"`   // Nodes in the discovery table
+}
+
+// ConnectivityInfo gathers the peer counts by protocol and direction, the dial
+// activity and the size of the discovery table.
+func (srv *Server) ConnectivityInfo() *ConnectivityInfo {
+	info := &ConnectivityInfo{
+		Protocols:   make(map[string]PeerCounts),
+		Dialing:     int(atomic.LoadInt32(&srv.runningDials)),
+		QueuedDials: int(atomic.LoadInt32(&srv.queuedDials)),
+	}
+	for _, p := range srv.Peers() {
+		inbound := p.Inbound()
+		info.Peers.add(inbound)
+		for name := range p.running {
+			counts := info.Protocols[name]
+			counts.add(inbound)
+			info.Protocols[name] = counts
+		}
+	}
+	srv.lock.Lock()
+	if tab, ok := srv.ntab.(*discover.Table); ok {
+		info.TableSize = tab.Len()
+	}
+	srv.lock.Unlock()
+
+	return info
+}
+
 // AddPeer connects to the given node and maintains the connection until the
 // server is shut down. If the connection fails for any reason, the server will
 // attempt to reconnect the peer.
@@ -291,6 +451,23 @@ func (srv *Server) RemovePeer(node *discover.Node) {
 	}
 }
 
+// AddTrustedPeer adds the given node to a reserved whitelist which allows the
+// node to always connect, even if the slots are full.
+func (srv *Server) AddTrustedPeer(node *discover.Node) {
+	select {
+	case srv.addtrusted <- node:
+	case <-srv.quit:
+	}
+}
+
+// RemoveTrustedPeer removes the given node from the trusted peer set.
+func (srv *Server) RemoveTrustedPeer(node *discover.Node) {
+	select {
+	case srv.removetrusted <- node:
+	case <-srv.quit:
+	}
+}
+
 // Self returns the local node's endpoint information.
 func (srv *Server) Self() *discover.Node {
 	srv.lock.Lock()
@@ -340,7 +517,7 @@ func (srv *Server) Stop() {
 }
 
 // Start starts running the server.
-// Servers can not be re-used after stopping.
+// A stopped server may be started again, e.g. with a new private key.
 func (srv *Server) Start() (err error) {
 	srv.lock.Lock()
 	defer srv.lock.Unlock()
@@ -354,24 +531,47 @@ func (srv *Server) Start() (err error) {
 	if srv.PrivateKey == nil {
 		return fmt.Errorf("Server.PrivateKey must be set to a non-nil key")
 	}
+	if srv.BootnodeMode {
+		if !srv.Discovery && !srv.DiscoveryV5 {
+			return errBootnodeNoDiscovery
+		}
+		if len(srv.Protocols) > 0 {
+			return errBootnodeProtocols
+		}
+	}
 	if srv.newTransport == nil {
 		srv.newTransport = newRLPX
 	}
 	if srv.Dialer == nil {
 		srv.Dialer = &net.Dialer{Timeout: defaultDialTimeout}
 	}
+	if srv.meters == nil {
+		srv.meters = newServerMeters(srv.MetricsRegistry) // keep reporting into the same meters across restarts
+	}
+	if srv.netfilter == nil {
+		srv.netfilter = netutil.NewNetFilter(srv.NetRestrict, srv.NetDeny) // keep runtime changes across restarts
+	}
+	if srv.slots == nil {
+		srv.slots = srv.Slots
+		if srv.slots == nil {
+			srv.slots = NewPeerSlots(srv.peerLimit())
+		}
+	}
 	srv.quit = make(chan struct{})
 	srv.addpeer = make(chan *conn)
 	srv.delpeer = make(chan peerDrop)
 	srv.posthandshake = make(chan *conn)
 	srv.addstatic = make(chan *discover.Node)
 	srv.removestatic = make(chan *discover.Node)
+	srv.addtrusted = make(chan *discover.Node)
+	srv.removetrusted = make(chan *discover.Node)
+	srv.rebalance = make(chan struct{})
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
 	// node table
 	if srv.Discovery {
-		ntab, err := discover.ListenUDP(srv.PrivateKey, srv.ListenAddr, srv.NAT, srv.NodeDatabase, srv.NetRestrict)
+		ntab, err := discover.ListenUDP(srv.PrivateKey, srv.ListenAddr, srv.NAT, srv.NodeDatabase, srv.netfilter)
 		if err != nil {
 			return err
 		}
@@ -382,7 +582,7 @@ func (srv *Server) Start() (err error) {
 	}
 
 	if srv.DiscoveryV5 {
-		ntab, err := discv5.ListenUDP(srv.PrivateKey, srv.DiscoveryV5Addr, srv.NAT, "", srv.NetRestrict) //srv.NodeDatabase)
+		ntab, err := discv5.ListenUDP(srv.PrivateKey, srv.DiscoveryV5Addr, srv.NAT, "", srv.netfilter) //srv.NodeDatabase)
 		if err != nil {
 			return err
 		}
@@ -392,11 +592,10 @@ func (srv *Server) Start() (err error) {
 		srv.DiscV5 = ntab
 	}
 
-	dynPeers := (srv.MaxPeers + 1) / 2
-	if !srv.Discovery {
-		dynPeers = 0
+	var dialer dialer = newDialState(srv.StaticNodes, srv.ntab, srv.maxDialedConns(), srv.netfilter)
+	if srv.BootnodeMode {
+		dialer = nullDialer{}
 	}
-	dialer := newDialState(srv.StaticNodes, srv.ntab, dynPeers, srv.NetRestrict)
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
@@ -404,13 +603,19 @@ func (srv *Server) Start() (err error) {
 		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, p.cap())
 	}
 	// listen/dial
-	if srv.ListenAddr != "" {
-		if err := srv.startListening(); err != nil {
-			return err
+	if srv.BootnodeMode {
+		srv.loopWG.Add(1)
+		go srv.meterDiscovery()
+		log.Info("Running in bootnode mode, serving peer discovery only")
+	} else {
+		if srv.ListenAddr != "" {
+			if err := srv.startListening(); err != nil {
+				return err
+			}
+		}
+		if srv.NoDial && srv.ListenAddr == "" {
+			log.Warn("P2P server will be useless, neither dialing nor listening")
 		}
-	}
-	if srv.NoDial && srv.ListenAddr == "" {
-		log.Warn("P2P server will be useless, neither dialing nor listening")
 	}
 
 	srv.loopWG.Add(1)
@@ -446,8 +651,20 @@ type dialer interface {
 	taskDone(task, time.Time)
 	addStatic(*discover.Node)
 	removeStatic(*discover.Node)
+	markUseless(discover.NodeID, time.Time)
+	setMaxDynDials(int)
 }
 
+// nullDialer is the dialer of servers in bootnode mode, never dialing any peers.
+type nullDialer struct{}
+
+func (nullDialer) newTasks(int, map[discover.NodeID]*Peer, time.Time) []task { return nil }
+func (nullDialer) taskDone(task, time.Time)                                  {}
+func (nullDialer) addStatic(*discover.Node)                                  {}
+func (nullDialer) removeStatic(*discover.Node)                               {}
+func (nullDialer) markUseless(discover.NodeID, time.Time)                    {}
+func (nullDialer) setMaxDynDials(int)                                        {}
+
 func (srv *Server) run(dialstate dialer) {
 	defer srv.loopWG.Done()
 	var (
@@ -455,11 +672,10 @@ func (srv *Server) run(dialstate dialer) {
 		trusted      = make(map[discover.NodeID]bool, len(srv.TrustedNodes))
 		taskdone     = make(chan task, maxActiveDialTasks)
 		runningTasks []task
-		queuedTasks  []task // tasks that can't run yet
+		queuedTasks  = new(taskQueue) // tasks that can't run yet
 	)
 	// Put trusted nodes into a map to speed up checks.
-	// Trusted peers are loaded on startup and cannot be
-	// modified while the server is running.
+	// Trusted peers are loaded on startup or added via AddTrustedPeer RPC.
 	for _, n := range srv.TrustedNodes {
 		trusted[n.ID] = true
 	}
@@ -473,25 +689,25 @@ func (srv *Server) run(dialstate dialer) {
 			}
 		}
 	}
-	// starts until max number of active tasks is satisfied
-	startTasks := func(ts []task) (rest []task) {
-		i := 0
-		for ; len(runningTasks) < maxActiveDialTasks && i < len(ts); i++ {
-			t := ts[i]
+	// starts queued tasks until max number of active tasks is satisfied
+	startTasks := func() {
+		for len(runningTasks) < maxActiveDialTasks && queuedTasks.len() > 0 {
+			t := queuedTasks.pop()
 			log.Trace("New dial task", "task", t)
 			go func() { t.Do(srv); taskdone <- t }()
 			runningTasks = append(runningTasks, t)
 		}
-		return ts[i:]
 	}
 	scheduleTasks := func() {
 		// Start from queue first.
-		queuedTasks = append(queuedTasks[:0], startTasks(queuedTasks)...)
+		startTasks()
 		// Query dialer for new tasks and start as many as possible now.
 		if len(runningTasks) < maxActiveDialTasks {
-			nt := dialstate.newTasks(len(runningTasks)+len(queuedTasks), peers, time.Now())
-			queuedTasks = append(queuedTasks, startTasks(nt)...)
+			queuedTasks.push(dialstate.newTasks(len(runningTasks)+queuedTasks.len(), peers, time.Now())...)
+			startTasks()
 		}
+		atomic.StoreInt32(&srv.runningDials, int32(len(runningTasks)))
+		atomic.StoreInt32(&srv.queuedDials, int32(queuedTasks.len()))
 	}
 
 running:
@@ -517,6 +733,33 @@ running:
 			if p, ok := peers[n.ID]; ok {
 				p.Disconnect(DiscRequested)
 			}
+		case n := <-srv.addtrusted:
+			// This channel is used by AddTrustedPeer to add an enode
+			// to the trusted node set.
+			log.Debug("Adding trusted node", "node", n)
+			trusted[n.ID] = true
+			// Mark any already-connected peer as trusted
+			if p, ok := peers[n.ID]; ok {
+				p.rw.set(trustedConn, true)
+			}
+		case n := <-srv.removetrusted:
+			// This channel is used by RemoveTrustedPeer to remove an enode
+			// from the trusted node set.
+			log.Debug("Removing trusted node", "node", n)
+			delete(trusted, n.ID)
+			// Unmark any already-connected peer as trusted
+			if p, ok := peers[n.ID]; ok {
+				p.rw.set(trustedConn, false)
+			}
+		case <-srv.rebalance:
+			// This channel is used by SetMaxPeers and ReservePeerSlots after
+			// changing the limits. Adjust the dial quota and shed the peers
+			// above the new limits.
+			dialstate.setMaxDynDials(srv.maxDialedConns())
+			for _, p := range srv.excessPeers(peers) {
+				p.log.Debug("Shedding peer above limits")
+				p.Disconnect(DiscTooManyPeers)
+			}
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
@@ -533,7 +776,7 @@ running:
 			// the remote identity is known (but hasn't been verified yet).
 			if trusted[c.id] {
 				// Ensure that the trusted flag is set before checking against MaxPeers.
-				c.flags |= trustedConn
+				c.set(trustedConn, true)
 			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
 			c.cont <- srv.encHandshakeChecks(peers, c)
@@ -541,12 +784,19 @@ running:
 			// At this point the connection is past the protocol handshake.
 			// Its capabilities are known and the remote identity is verified.
 			err := srv.protoHandshakeChecks(peers, c)
+			if err == DiscUselessPeer {
+				srv.meters.markNegotiated(nil)
+				dialstate.markUseless(c.id, time.Now())
+			}
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
+				p.events = &srv.peerFeed
+				srv.meters.markNegotiated(p.running)
 				name := truncateName(c.name)
 				log.Debug("Adding p2p peer", "id", c.id, "name", name, "addr", c.fd.RemoteAddr(), "peers", len(peers)+1)
 				peers[c.id] = p
+				srv.peerFeed.Send(p.newEvent(PeerEventTypeAdd, nil))
 				go srv.runPeer(p)
 			}
 			// The dialer logic relies on the assumption that
@@ -558,6 +808,13 @@ running:
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			pd.log.Debug("Removing p2p peer", "duration", d, "peers", len(peers)-1, "req", pd.requested, "err", pd.err)
 			delete(peers, pd.ID())
+			srv.meters.markDisconnect(pd.reason, pd.requested)
+			if pd.reason == DiscUselessPeer {
+				// Don't redial peers which turned out to be of no use to us,
+				// e.g. ones running on another network, for a while.
+				dialstate.markUseless(pd.ID(), time.Now())
+			}
+			srv.peerFeed.Send(pd.newEvent(PeerEventTypeDrop, pd.err))
 		}
 	}
 
@@ -596,7 +853,9 @@ func (srv *Server) protoHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn
 
 func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn) error {
 	switch {
-	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
+	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.peerLimit():
+		return DiscTooManyPeers
+	case !c.is(trustedConn) && c.is(inboundConn) && countInbound(peers) >= srv.maxInboundConns():
 		return DiscTooManyPeers
 	case peers[c.id] != nil:
 		return DiscAlreadyConnected
@@ -607,6 +866,151 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn)
 	}
 }
 
+// maxDialedConns returns the number of peers the server dials from the
+// discovery table.
+func (srv *Server) maxDialedConns() int {
+	if !srv.Discovery {
+		return 0
+	}
+	r := srv.DialRatio
+	if r == 0 {
+		r = defaultDialRatio
+	}
+	return (srv.peerLimit() + r - 1) / r
+}
+
+// maxInboundConns returns the number of inbound peers the server accepts,
+// which is the share of the peer slots not used for dialing.
+func (srv *Server) maxInboundConns() int {
+	return srv.peerLimit() - srv.maxDialedConns()
+}
+
+// peerLimit returns the current maximum number of peers.
+func (srv *Server) peerLimit() int {
+	srv.limitLock.RLock()
+	defer srv.limitLock.RUnlock()
+
+	return srv.MaxPeers
+}
+
+// SetMaxPeers changes the maximum number of peers of the server. The dial and
+// inbound quotas and the peer slots shared among the protocols are rebalanced
+// to the new limit, and if the server is running, the peers above the new limits
+// are disconnected, youngest first. Static and trusted peers are never shed.
+func (srv *Server) SetMaxPeers(n int) error {
+	if n <= 0 {
+		return errors.New("peer limit must be greater than zero")
+	}
+	srv.limitLock.Lock()
+	srv.MaxPeers = n
+	srv.limitLock.Unlock()
+
+	if slots := srv.PeerSlots(); slots != nil {
+		slots.SetTotal(n)
+	}
+	srv.shedExcessPeers()
+	return nil
+}
+
+// ReservePeerSlots sets aside up to n peer slots for the given protocol, as
+// PeerSlots.Reserve does, and disconnects the peers above the new limits of
+// the protocols. It returns the number of slots actually reserved.
+func (srv *Server) ReservePeerSlots(protocol string, n int) (int, error) {
+	slots := srv.PeerSlots()
+	if slots == nil {
+		return 0, errServerStopped
+	}
+	reserved := slots.Reserve(protocol, n)
+	srv.shedExcessPeers()
+	return reserved, nil
+}
+
+// shedExcessPeers makes a running server rebalance its peers after the limits
+// changed.
+func (srv *Server) shedExcessPeers() {
+	srv.lock.Lock()
+	running := srv.running
+	srv.lock.Unlock()
+
+	if !running {
+		return
+	}
+	select {
+	case srv.rebalance <- struct{}{}:
+	case <-srv.quit:
+	}
+}
+
+// excessPeers returns the peers above the limits of the server, first the ones
+// above the slots of their protocols, then the ones above the inbound quota and
+// at last the ones above the total limit. In each case the youngest peers are
+// picked first. Static and trusted peers are exempt.
+func (srv *Server) excessPeers(peers map[discover.NodeID]*Peer) []*Peer {
+	var dynamic []*Peer
+	for _, p := range peers {
+		if !p.rw.is(trustedConn | staticDialedConn) {
+			dynamic = append(dynamic, p)
+		}
+	}
+	sort.Slice(dynamic, func(i, j int) bool { return dynamic[i].created > dynamic[j].created })
+
+	drop := make(map[discover.NodeID]bool)
+	if slots := srv.PeerSlots(); slots != nil {
+		for _, ids := range slots.Excess() {
+			for _, id := range ids {
+				if _, ok := peers[id]; ok {
+					drop[id] = true
+				}
+			}
+		}
+	}
+	inbound := -srv.maxInboundConns()
+	for _, p := range dynamic {
+		if p.rw.is(inboundConn) && !drop[p.ID()] {
+			inbound++
+		}
+	}
+	for _, p := range dynamic {
+		if inbound > 0 && p.rw.is(inboundConn) && !drop[p.ID()] {
+			drop[p.ID()] = true
+			inbound--
+		}
+	}
+	total := len(peers) - len(drop) - srv.peerLimit()
+	for _, p := range dynamic {
+		if total > 0 && !drop[p.ID()] {
+			drop[p.ID()] = true
+			total--
+		}
+	}
+	var excess []*Peer
+	for _, p := range dynamic {
+		if drop[p.ID()] {
+			excess = append(excess, p)
+		}
+	}
+	return excess
+}
+
+// countInbound returns the number of inbound peers subject to the inbound limit.
+func countInbound(peers map[discover.NodeID]*Peer) int {
+	var n int
+	for _, p := range peers {
+		if p.rw.is(inboundConn) && !p.rw.is(trustedConn) {
+			n++
+		}
+	}
+	return n
+}
+
+// PeerSlots returns the slot manager sharing the peers among the protocols.
+func (srv *Server) PeerSlots() *PeerSlots {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	return srv.slots
+}
+
 type tempError interface {
 	Temporary() bool
 }
@@ -649,17 +1053,17 @@ func (srv *Server) listenLoop() {
 			break
 		}
 
-		// Reject connections that do not match NetRestrict.
-		if srv.NetRestrict != nil {
-			if tcp, ok := fd.RemoteAddr().(*net.TCPAddr); ok && !srv.NetRestrict.Contains(tcp.IP) {
-				log.Debug("Rejected conn (not whitelisted in NetRestrict)", "addr", fd.RemoteAddr())
+		// Reject connections that do not match NetRestrict or NetDeny.
+		if tcp, ok := fd.RemoteAddr().(*net.TCPAddr); ok {
+			if err := srv.netfilter.Check(tcp.IP); err != nil {
+				log.Debug("Rejected conn", "addr", fd.RemoteAddr(), "err", err)
 				fd.Close()
 				slots <- struct{}{}
 				continue
 			}
 		}
 
-		fd = newMeteredConn(fd, true)
+		fd = newMeteredConn(fd, true, srv.meters)
 		log.Trace("Accepted connection", "addr", fd.RemoteAddr())
 
 		// Spawn the handler. It will give the slot back when the connection
@@ -688,6 +1092,7 @@ func (srv *Server) setupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 	var err error
 	if c.id, err = c.doEncHandshake(srv.PrivateKey, dialDest); err != nil {
 		log.Trace("Failed RLPx handshake", "addr", c.fd.RemoteAddr(), "conn", c.flags, "err", err)
+		srv.meters.markHandshakeError()
 		c.close(err)
 		return
 	}
@@ -707,6 +1112,7 @@ func (srv *Server) setupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 	phs, err := c.doProtoHandshake(srv.ourHandshake)
 	if err != nil {
 		clog.Trace("Failed proto handshake", "err", err)
+		srv.meters.markHandshakeError()
 		c.close(err)
 		return
 	}
@@ -755,10 +1161,10 @@ func (srv *Server) runPeer(p *Peer) {
 	if srv.newPeerHook != nil {
 		srv.newPeerHook(p)
 	}
-	remoteRequested, err := p.run()
+	remoteRequested, reason, err := p.run()
 	// Note: run waits for existing peers to be sent on srv.delpeer
 	// before returning, so this send should not select on srv.quit.
-	srv.delpeer <- peerDrop{p, err, remoteRequested}
+	srv.delpeer <- peerDrop{p, err, reason, remoteRequested}
 }
 
 // NodeInfo represents a short summary of the information known about the host.
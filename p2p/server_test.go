@@ -19,6 +19,7 @@ package p2p
 import (
 	"crypto/ecdsa"
 	"errors"
+	"io"
 	"math/rand"
 	"net"
 	"reflect"
@@ -28,6 +29,7 @@ import (
 	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/crypto/sha3"
 	"github.com/expanse-org/go-expanse/p2p/discover"
+	"github.com/expanse-org/go-expanse/p2p/netutil"
 )
 
 func init() {
@@ -178,6 +180,156 @@ func TestServerDial(t *testing.T) {
 	}
 }
 
+func TestServerPeerEvents(t *testing.T) {
+	remid := randomID()
+	srv := startTestServer(t, remid, nil)
+	defer srv.Stop()
+
+	events := make(chan *PeerEvent, 2)
+	sub := srv.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	// dial the test server and wait for the peer to be added
+	conn, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Type != PeerEventTypeAdd {
+			t.Errorf("event type mismatch: got %q, want %q", ev.Type, PeerEventTypeAdd)
+		}
+		if ev.ID != remid.String() {
+			t.Errorf("event peer id mismatch: got %s, want %s", ev.ID, remid)
+		}
+		if ev.RemoteAddress != conn.LocalAddr().String() {
+			t.Errorf("event remote address mismatch: got %s, want %s", ev.RemoteAddress, conn.LocalAddr())
+		}
+		if node, err := discover.ParseNode(ev.Enode); err != nil || node.ID != remid {
+			t.Errorf("invalid event enode %q: %v", ev.Enode, err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("no add event within one second")
+	}
+	// drop the connection and wait for the peer to be removed
+	conn.Close()
+	select {
+	case ev := <-events:
+		if ev.Type != PeerEventTypeDrop {
+			t.Errorf("event type mismatch: got %q, want %q", ev.Type, PeerEventTypeDrop)
+		}
+		if ev.ID != remid.String() {
+			t.Errorf("event peer id mismatch: got %s, want %s", ev.ID, remid)
+		}
+		if ev.Error == "" {
+			t.Error("drop event without disconnect reason")
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("no drop event within one second")
+	}
+}
+
+func TestServerSetNetRestrictions(t *testing.T) {
+	remid := randomID()
+	srv := startTestServer(t, remid, nil)
+	defer srv.Stop()
+
+	events := make(chan *PeerEvent, 2)
+	sub := srv.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	// connect a peer while there are no restrictions
+	conn, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case ev := <-events:
+		if ev.Type != PeerEventTypeAdd {
+			t.Fatalf("event type mismatch: got %q, want %q", ev.Type, PeerEventTypeAdd)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("peer not added within one second")
+	}
+	// deny the loopback network and check that the peer is dropped
+	deny, _ := netutil.ParseNetlist("127.0.0.0/8")
+	srv.SetNetRestrictions(nil, deny)
+
+	select {
+	case ev := <-events:
+		if ev.Type != PeerEventTypeDrop {
+			t.Fatalf("event type mismatch: got %q, want %q", ev.Type, PeerEventTypeDrop)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("denied peer not dropped within one second")
+	}
+	if whitelist, denylist := srv.NetRestrictions(); whitelist != nil || denylist != deny {
+		t.Errorf("restrictions mismatch: got %v and %v, want <nil> and %v", whitelist, denylist, deny)
+	}
+	// new connections from the denied network should be rejected
+	conn, err = net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("denied connection not closed: %v", err)
+	}
+	if count := srv.PeerCount(); count != 0 {
+		t.Errorf("peer count mismatch: got %d, want 0", count)
+	}
+}
+
+// Tests that a server in bootnode mode serves peer discovery, but neither runs
+// protocols nor accepts peer connections.
+func TestServerBootnodeMode(t *testing.T) {
+	config := Config{
+		PrivateKey:   newkey(),
+		MaxPeers:     10,
+		ListenAddr:   "127.0.0.1:0",
+		BootnodeMode: true,
+	}
+	if err := (&Server{Config: config}).Start(); err != errBootnodeNoDiscovery {
+		t.Fatalf("start without discovery error mismatch: got %v, want %v", err, errBootnodeNoDiscovery)
+	}
+	config.Discovery = true
+	config.Protocols = []Protocol{discard}
+	if err := (&Server{Config: config}).Start(); err != errBootnodeProtocols {
+		t.Fatalf("start with protocols error mismatch: got %v, want %v", err, errBootnodeProtocols)
+	}
+	config.Protocols = nil
+	srv := &Server{Config: config}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start bootnode: %v", err)
+	}
+	defer srv.Stop()
+
+	if srv.listener != nil {
+		t.Fatal("bootnode listening for peer connections")
+	}
+	// Bootstrap a discovery node off the bootnode and check it gets known
+	tab, err := discover.ListenUDP(newkey(), "127.0.0.1:0", nil, "", nil)
+	if err != nil {
+		t.Fatalf("could not start discovery: %v", err)
+	}
+	defer tab.Close()
+
+	if err := tab.SetFallbackNodes([]*discover.Node{srv.Self()}); err != nil {
+		t.Fatalf("could not set bootnode: %v", err)
+	}
+	tab.Lookup(randomID())
+	if n := srv.ntab.(*discover.Table).Len(); n == 0 {
+		t.Error("bootnode didn't learn about the discovering node")
+	}
+	if n := srv.PeerCount(); n != 0 {
+		t.Errorf("peer count mismatch: got %d, want 0", n)
+	}
+}
+
 // This test checks that tasks generated by dialstate are
 // actually executed and taskdone is called for them.
 func TestServerTaskScheduling(t *testing.T) {
@@ -302,6 +454,10 @@ func (tg taskgen) addStatic(*discover.Node) {
 }
 func (tg taskgen) removeStatic(*discover.Node) {
 }
+func (tg taskgen) markUseless(discover.NodeID, time.Time) {
+}
+func (tg taskgen) setMaxDynDials(int) {
+}
 
 type testTask struct {
 	index  int
@@ -312,6 +468,82 @@ func (t *testTask) Do(srv *Server) {
 	t.called = true
 }
 
+type blockingTask struct {
+	release chan struct{}
+}
+
+func (t *blockingTask) Do(srv *Server) {
+	<-t.release
+}
+
+// This test checks that the connectivity info breaks down the peers by protocol
+// and direction, and reports the dial activity.
+func TestServerConnectivityInfo(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var tasks []task
+	for i := 0; i < maxActiveDialTasks+2; i++ {
+		tasks = append(tasks, &blockingTask{release})
+	}
+	srv := &Server{
+		quit:       make(chan struct{}),
+		ntab:       fakeTable{},
+		running:    true,
+		peerOp:     make(chan peerOpFunc),
+		peerOpDone: make(chan struct{}),
+	}
+	defer srv.Stop()
+	srv.loopWG.Add(1)
+	go srv.run(taskgen{
+		newFunc: func(running int, peers map[discover.NodeID]*Peer) []task {
+			ts := tasks
+			tasks = nil
+			return ts
+		},
+		doneFunc: func(task) {},
+	})
+	// Inject a few peers running various protocols
+	protocols := []Protocol{{Name: "exp", Version: 63, Length: 17}, {Name: "les", Version: 1, Length: 15}}
+	makePeer := func(flags connFlag, caps ...Cap) *Peer {
+		fd, _ := net.Pipe()
+		return newPeer(&conn{fd: fd, flags: flags, id: randomID(), caps: caps}, protocols)
+	}
+	injected := []*Peer{
+		makePeer(inboundConn, Cap{"exp", 63}),
+		makePeer(inboundConn, Cap{"exp", 63}, Cap{"les", 1}),
+		makePeer(dynDialedConn, Cap{"exp", 63}),
+		makePeer(staticDialedConn, Cap{"shh", 5}),
+	}
+	srv.peerOp <- func(peers map[discover.NodeID]*Peer) {
+		for _, p := range injected {
+			peers[p.ID()] = p
+		}
+	}
+	<-srv.peerOpDone
+
+	info := srv.ConnectivityInfo()
+	want := &ConnectivityInfo{
+		Peers: PeerCounts{Total: 4, Inbound: 2, Outbound: 2},
+		Protocols: map[string]PeerCounts{
+			"exp": {Total: 3, Inbound: 2, Outbound: 1},
+			"les": {Total: 1, Inbound: 1, Outbound: 0},
+		},
+		Dialing:     maxActiveDialTasks,
+		QueuedDials: 2,
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("connectivity info mismatch:\nhave %+v\nwant %+v", info, want)
+	}
+	// Drop the injected peers, they can't be disconnected
+	srv.peerOp <- func(peers map[discover.NodeID]*Peer) {
+		for _, p := range injected {
+			delete(peers, p.ID())
+		}
+	}
+	<-srv.peerOpDone
+}
+
 // This test checks that connections are disconnected
 // just after the encryption handshake when the server is
 // at capacity. Trusted connections should still be accepted.
@@ -357,6 +589,23 @@ func TestServerAtCap(t *testing.T) {
 		t.Error("Server did not set trusted flag")
 	}
 
+	// Remove from trusted set and try again
+	srv.RemoveTrustedPeer(&discover.Node{ID: trustedID})
+	c = newconn(trustedID)
+	if err := srv.checkpoint(c, srv.posthandshake); err != DiscTooManyPeers {
+		t.Error("wrong error for insert:", err)
+	}
+
+	// Add anotherID to trusted set and try again
+	anotherID := randomID()
+	srv.AddTrustedPeer(&discover.Node{ID: anotherID})
+	c = newconn(anotherID)
+	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
+		t.Error("unexpected error for trusted conn @posthandshake:", err)
+	}
+	if !c.is(trustedConn) {
+		t.Error("Server did not set trusted flag")
+	}
 }
 
 func TestServerSetupConn(t *testing.T) {
@@ -0,0 +1,258 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/expanse-org/go-expanse/p2p/discover"
+)
+
+// SlotUsage is the number of connection slots in use out of a limit.
+type SlotUsage struct {
+	Used  int `json:"used"`
+	Limit int `json:"limit"`
+}
+
+// PeerSlots shares the peer slots of a server among the protocols running on
+// it. Protocols may reserve a quota of the slots for their peers, e.g. a LES
+// server for its light clients, while protocols without a reservation share
+// the slots which aren't reserved. Reservations are capped at half of the slots
+// in total, so that the sharing protocols always keep at least the other half.
+//
+// Static and trusted peers are always granted a slot, as they are exempt from
+// the peer limit of the server too, but they count towards the usage.
+//
+// The total number of slots may be changed at runtime, in which case the
+// reservations are granted anew, in the order they were made, up to the amount
+// originally requested.
+type PeerSlots struct {
+	total     int
+	requested map[string]int // Reservations as requested, to regrant on resize
+	order     []string       // Protocols in the order of their reservations
+	reserved  map[string]int
+	peers     map[string]map[discover.NodeID]slotHolder
+	seq       uint64 // Counter ordering the slot holders by age
+	lock      sync.Mutex
+}
+
+// slotHolder is a peer holding a slot of a protocol.
+type slotHolder struct {
+	seq    uint64 // Acquisition sequence number, higher is younger
+	exempt bool   // Whether the peer is static or trusted
+}
+
+// NewPeerSlots creates a slot manager for the given total number of peers.
+func NewPeerSlots(total int) *PeerSlots {
+	return &PeerSlots{
+		total:     total,
+		requested: make(map[string]int),
+		reserved:  make(map[string]int),
+		peers:     make(map[string]map[discover.NodeID]slotHolder),
+	}
+}
+
+// Reserve sets aside up to n slots for the peers of the given protocol, replacing
+// any previous reservation, and returns the number of slots actually reserved.
+func (s *PeerSlots) Reserve(protocol string, n int) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.requested[protocol]; !ok {
+		s.order = append(s.order, protocol)
+	}
+	s.requested[protocol] = n
+
+	// Replace the reservation, granting whatever is still available
+	delete(s.reserved, protocol)
+	if avail := s.total - s.total/2 - s.reservedSlots(); n > avail {
+		n = avail
+	}
+	if n > 0 {
+		s.reserved[protocol] = n
+	}
+	return n
+}
+
+// Total returns the total number of slots.
+func (s *PeerSlots) Total() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.total
+}
+
+// SetTotal changes the total number of slots, granting the reservations anew
+// against the new total. Peers already holding slots keep them, Excess reports
+// the ones above the new limits.
+func (s *PeerSlots) SetTotal(total int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.total = total
+	s.reserved = make(map[string]int)
+	for _, protocol := range s.order {
+		n := s.requested[protocol]
+		if avail := s.total - s.total/2 - s.reservedSlots(); n > avail {
+			n = avail
+		}
+		if n > 0 {
+			s.reserved[protocol] = n
+		}
+	}
+}
+
+// reservedSlots returns the number of slots reserved by all protocols.
+func (s *PeerSlots) reservedSlots() int {
+	var sum int
+	for _, n := range s.reserved {
+		sum += n
+	}
+	return sum
+}
+
+// Limit returns the number of peers the given protocol may have, which is either
+// its reservation or the number of slots not reserved by others.
+func (s *PeerSlots) Limit(protocol string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.limit(protocol)
+}
+
+func (s *PeerSlots) limit(protocol string) int {
+	if n, ok := s.reserved[protocol]; ok {
+		return n
+	}
+	return s.total - s.reservedSlots()
+}
+
+// Acquire claims a slot of the given protocol for the peer, failing with
+// DiscTooManyPeers if the protocol has run out of slots.
+func (s *PeerSlots) Acquire(protocol string, p *Peer) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	peers := s.peers[protocol]
+	if peers == nil {
+		peers = make(map[discover.NodeID]slotHolder)
+		s.peers[protocol] = peers
+	}
+	if _, ok := peers[p.ID()]; ok {
+		return DiscAlreadyConnected
+	}
+	exempt := p.rw.is(trustedConn | staticDialedConn)
+	if !exempt && len(peers) >= s.limit(protocol) {
+		return DiscTooManyPeers
+	}
+	s.seq++
+	peers[p.ID()] = slotHolder{seq: s.seq, exempt: exempt}
+	return nil
+}
+
+// Release returns the slot of the given protocol held by the peer.
+func (s *PeerSlots) Release(protocol string, p *Peer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.peers[protocol], p.ID())
+}
+
+// Excess returns the peers holding slots above the limit of their protocol,
+// youngest first, e.g. after the slots were resized. Static and trusted peers
+// are never reported, but they do count towards the usage.
+func (s *PeerSlots) Excess() map[string][]discover.NodeID {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	excess := make(map[string][]discover.NodeID)
+	for protocol, peers := range s.peers {
+		over := len(peers) - s.limit(protocol)
+		if over <= 0 {
+			continue
+		}
+		var (
+			ids  []discover.NodeID
+			seqs = make(map[discover.NodeID]uint64)
+		)
+		for id, holder := range peers {
+			if !holder.exempt {
+				ids = append(ids, id)
+				seqs[id] = holder.seq
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return seqs[ids[i]] > seqs[ids[j]] })
+		if len(ids) > over {
+			ids = ids[:over]
+		}
+		if len(ids) > 0 {
+			excess[protocol] = ids
+		}
+	}
+	return excess
+}
+
+// Usage returns the slot usage of all protocols which have peers or reserved
+// slots.
+func (s *PeerSlots) Usage() map[string]SlotUsage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	usage := make(map[string]SlotUsage)
+	for protocol := range s.reserved {
+		usage[protocol] = SlotUsage{Limit: s.limit(protocol)}
+	}
+	for protocol, peers := range s.peers {
+		usage[protocol] = SlotUsage{Used: len(peers), Limit: s.limit(protocol)}
+	}
+	return usage
+}
+
+// SlotInfo reports the usage of the connection slots of a server.
+type SlotInfo struct {
+	MaxPeers  int                  `json:"maxPeers"`
+	Inbound   SlotUsage            `json:"inbound"`   // Peers which connected to us
+	Outbound  SlotUsage            `json:"outbound"`  // Peers dialed from the discovery table
+	Exempt    int                  `json:"exempt"`    // Static and trusted peers, not subject to the limits
+	Protocols map[string]SlotUsage `json:"protocols"` // Slots of the protocols sharing the peers
+}
+
+// SlotInfo gathers the usage of the inbound and outbound connection slots and
+// of the slots shared among the protocols.
+func (srv *Server) SlotInfo() *SlotInfo {
+	info := &SlotInfo{
+		MaxPeers:  srv.peerLimit(),
+		Inbound:   SlotUsage{Limit: srv.maxInboundConns()},
+		Outbound:  SlotUsage{Limit: srv.maxDialedConns()},
+		Protocols: make(map[string]SlotUsage),
+	}
+	for _, p := range srv.Peers() {
+		switch {
+		case p.rw.is(trustedConn | staticDialedConn):
+			info.Exempt++
+		case p.rw.is(inboundConn):
+			info.Inbound.Used++
+		default:
+			info.Outbound.Used++
+		}
+	}
+	if slots := srv.PeerSlots(); slots != nil {
+		info.Protocols = slots.Usage()
+	}
+	return info
+}
@@ -0,0 +1,218 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common/mclock"
+	"github.com/expanse-org/go-expanse/p2p/discover"
+)
+
+// Tests that reservations are capped at half of the slots and that protocols
+// without a reservation share the remaining ones.
+func TestPeerSlotsReserve(t *testing.T) {
+	slots := NewPeerSlots(25)
+
+	if n := slots.Reserve("les", 20); n != 13 {
+		t.Errorf("reserved slots mismatch: have %d, want %d", n, 13)
+	}
+	if n := slots.Reserve("bzz", 5); n != 0 {
+		t.Errorf("reserved slots beyond the cap: have %d, want %d", n, 0)
+	}
+	if limit := slots.Limit("exp"); limit != 12 {
+		t.Errorf("shared limit mismatch: have %d, want %d", limit, 12)
+	}
+	// Reserving again should replace the previous reservation
+	if n := slots.Reserve("les", 5); n != 5 {
+		t.Errorf("re-reserved slots mismatch: have %d, want %d", n, 5)
+	}
+	if limit := slots.Limit("exp"); limit != 20 {
+		t.Errorf("shared limit mismatch: have %d, want %d", limit, 20)
+	}
+}
+
+// Tests that slots are granted up to the limit of a protocol, except for static
+// and trusted peers, and are given back on release.
+func TestPeerSlotsAcquire(t *testing.T) {
+	slots := NewPeerSlots(4)
+	slots.Reserve("les", 1)
+
+	makePeer := func(flags connFlag) *Peer {
+		fd, _ := net.Pipe()
+		return newPeer(&conn{fd: fd, flags: flags, id: randomID()}, nil)
+	}
+	peers := []*Peer{makePeer(inboundConn), makePeer(dynDialedConn), makePeer(inboundConn), makePeer(inboundConn)}
+	for i, p := range peers[:3] {
+		if err := slots.Acquire("exp", p); err != nil {
+			t.Fatalf("peer %d: failed to acquire slot: %v", i, err)
+		}
+	}
+	if err := slots.Acquire("exp", peers[0]); err != DiscAlreadyConnected {
+		t.Errorf("duplicate slot error mismatch: have %v, want %v", err, DiscAlreadyConnected)
+	}
+	if err := slots.Acquire("exp", peers[3]); err != DiscTooManyPeers {
+		t.Errorf("exhausted slot error mismatch: have %v, want %v", err, DiscTooManyPeers)
+	}
+	// Static and trusted peers should be granted a slot regardless
+	static, trusted := makePeer(staticDialedConn), makePeer(inboundConn|trustedConn)
+	if err := slots.Acquire("exp", static); err != nil {
+		t.Errorf("failed to acquire slot for static peer: %v", err)
+	}
+	if err := slots.Acquire("exp", trusted); err != nil {
+		t.Errorf("failed to acquire slot for trusted peer: %v", err)
+	}
+	// The reserved slots should be separate from the shared ones
+	if err := slots.Acquire("les", peers[3]); err != nil {
+		t.Errorf("failed to acquire reserved slot: %v", err)
+	}
+	want := map[string]SlotUsage{
+		"exp": {Used: 5, Limit: 3},
+		"les": {Used: 1, Limit: 1},
+	}
+	if usage := slots.Usage(); !reflect.DeepEqual(usage, want) {
+		t.Errorf("usage mismatch:\nhave %+v\nwant %+v", usage, want)
+	}
+	// Released slots should be available again
+	slots.Release("exp", static)
+	slots.Release("exp", trusted)
+	slots.Release("exp", peers[0])
+	if err := slots.Acquire("exp", peers[0]); err != nil {
+		t.Errorf("failed to acquire released slot: %v", err)
+	}
+}
+
+// Tests that resizing the slots grants the reservations anew, up to the amount
+// originally requested.
+func TestPeerSlotsResize(t *testing.T) {
+	slots := NewPeerSlots(25)
+	slots.Reserve("les", 20)
+
+	slots.SetTotal(10)
+	if les, exp := slots.Limit("les"), slots.Limit("exp"); les != 5 || exp != 5 {
+		t.Errorf("shrunk limits mismatch: have les %d, exp %d, want 5 and 5", les, exp)
+	}
+	slots.SetTotal(50)
+	if les, exp := slots.Limit("les"), slots.Limit("exp"); les != 20 || exp != 30 {
+		t.Errorf("grown limits mismatch: have les %d, exp %d, want 20 and 30", les, exp)
+	}
+}
+
+// Tests that the youngest peers above the limit of their protocol are reported
+// as excess, never including static and trusted ones.
+func TestPeerSlotsExcess(t *testing.T) {
+	slots := NewPeerSlots(4)
+
+	var peers []*Peer
+	for _, flags := range []connFlag{inboundConn, dynDialedConn, inboundConn, staticDialedConn} {
+		fd, _ := net.Pipe()
+		p := newPeer(&conn{fd: fd, flags: flags, id: randomID()}, nil)
+		if err := slots.Acquire("exp", p); err != nil {
+			t.Fatalf("failed to acquire slot: %v", err)
+		}
+		peers = append(peers, p)
+	}
+	if excess := slots.Excess(); len(excess) != 0 {
+		t.Fatalf("excess peers within limits: %v", excess)
+	}
+	slots.SetTotal(2)
+	want := map[string][]discover.NodeID{"exp": {peers[2].ID(), peers[1].ID()}}
+	if excess := slots.Excess(); !reflect.DeepEqual(excess, want) {
+		t.Errorf("excess mismatch:\nhave %v\nwant %v", excess, want)
+	}
+}
+
+// Tests that lowering the peer limit sheds the youngest peers above the inbound
+// quota and the total limit, keeping static and trusted peers.
+func TestServerExcessPeers(t *testing.T) {
+	srv := &Server{
+		Config: Config{MaxPeers: 6, DialRatio: 3, Discovery: true},
+	}
+	var (
+		peers = make(map[discover.NodeID]*Peer)
+		order []*Peer
+	)
+	for i, flags := range []connFlag{inboundConn | trustedConn, inboundConn, inboundConn, inboundConn, inboundConn, dynDialedConn} {
+		fd, _ := net.Pipe()
+		p := newPeer(&conn{fd: fd, flags: flags, id: randomID()}, nil)
+		p.created = mclock.AbsTime(i)
+		peers[p.ID()] = p
+		order = append(order, p)
+	}
+	if excess := srv.excessPeers(peers); len(excess) != 0 {
+		t.Fatalf("excess peers within limits: %v", excess)
+	}
+	// With 3 peers, 1 is dialed and 2 are inbound: the two youngest inbound
+	// peers exceed the inbound quota, the dialed one the total limit.
+	if err := srv.SetMaxPeers(3); err != nil {
+		t.Fatalf("failed to set peer limit: %v", err)
+	}
+	want := []*Peer{order[5], order[4], order[3]}
+	if excess := srv.excessPeers(peers); !reflect.DeepEqual(excess, want) {
+		t.Errorf("excess mismatch:\nhave %v\nwant %v", excess, want)
+	}
+	if err := srv.SetMaxPeers(0); err == nil {
+		t.Errorf("zero peer limit accepted")
+	}
+}
+
+// Tests that inbound connections are limited to the slots not used for dialing,
+// while dialed and trusted connections are not affected.
+func TestServerInboundLimit(t *testing.T) {
+	srv := &Server{
+		Config: Config{MaxPeers: 6, DialRatio: 3, Discovery: true},
+	}
+	if dialed, inbound := srv.maxDialedConns(), srv.maxInboundConns(); dialed != 2 || inbound != 4 {
+		t.Fatalf("quota mismatch: have %d dialed and %d inbound, want 2 and 4", dialed, inbound)
+	}
+	peers := make(map[discover.NodeID]*Peer)
+	for i := 0; i < 4; i++ {
+		flags := inboundConn
+		if i == 0 {
+			flags |= trustedConn
+		}
+		fd, _ := net.Pipe()
+		p := newPeer(&conn{fd: fd, flags: flags, id: randomID()}, nil)
+		peers[p.ID()] = p
+	}
+	tests := []struct {
+		flags connFlag
+		want  error
+	}{
+		{inboundConn, nil},
+		{dynDialedConn, nil},
+		{inboundConn | trustedConn, nil},
+	}
+	for i, tt := range tests {
+		if err := srv.encHandshakeChecks(peers, &conn{flags: tt.flags, id: randomID()}); err != tt.want {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.want)
+		}
+	}
+	// One more inbound peer should exhaust the inbound slots
+	fd, _ := net.Pipe()
+	p := newPeer(&conn{fd: fd, flags: inboundConn, id: randomID()}, nil)
+	peers[p.ID()] = p
+
+	if err := srv.encHandshakeChecks(peers, &conn{flags: inboundConn, id: randomID()}); err != DiscTooManyPeers {
+		t.Errorf("inbound error mismatch: have %v, want %v", err, DiscTooManyPeers)
+	}
+	if err := srv.encHandshakeChecks(peers, &conn{flags: dynDialedConn, id: randomID()}); err != nil {
+		t.Errorf("dialed error mismatch: have %v, want <nil>", err)
+	}
+}
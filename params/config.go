@@ -57,8 +57,8 @@ var (
 	// means that all fields must be set at all times. This forces
 	// anyone adding flags to the config to also have to set these
 	// fields.
-	AllProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0)}
-	TestChainConfig    = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0)}
+	AllProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil}
+	TestChainConfig    = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil}
 )
 
 // ChainConfig is the core config which determines the blockchain settings.
@@ -79,11 +79,63 @@ type ChainConfig struct {
 
 	EIP155Block *big.Int `json:"eip155Block"` // EIP155 HF block
 	EIP158Block *big.Int `json:"eip158Block"` // EIP158 HF block
+
+	// EIP1014 implements the CREATE2 opcode (https://github.com/ethereum/EIPs/issues/1014)
+	EIP1014Block *big.Int `json:"eip1014Block,omitempty"` // EIP1014 HF block (nil = no fork)
+
+	// EIP211 implements the RETURNDATASIZE and RETURNDATACOPY opcodes (https://github.com/ethereum/EIPs/pull/211)
+	EIP211Block *big.Int `json:"eip211Block,omitempty"` // EIP211 HF block (nil = no fork)
+	// EIP145 implements the SHL, SHR and SAR opcodes (https://github.com/ethereum/EIPs/pull/145)
+	EIP145Block *big.Int `json:"eip145Block,omitempty"` // EIP145 HF block (nil = no fork)
+	// EIP1052 implements the EXTCODEHASH opcode (https://github.com/ethereum/EIPs/pull/1052)
+	EIP1052Block *big.Int `json:"eip1052Block,omitempty"` // EIP1052 HF block (nil = no fork)
+	// EIP140 implements the REVERT opcode (https://github.com/ethereum/EIPs/pull/206)
+	EIP140Block *big.Int `json:"eip140Block,omitempty"` // EIP140 HF block (nil = no fork)
+	// EIP214 implements the STATICCALL opcode (https://github.com/ethereum/EIPs/pull/214)
+	EIP214Block *big.Int `json:"eip214Block,omitempty"` // EIP214 HF block (nil = no fork)
+
+	Freeze  *FreezeConfig  `json:"freeze,omitempty"`  // Address freeze enforcement of private networks (nil = disabled)
+	GasFree *GasFreeConfig `json:"gasFree,omitempty"` // Gas free transaction lane of private networks (nil = disabled)
+
+	BlockInterval *BlockIntervalConfig `json:"blockInterval,omitempty"` // Block interval bounds of test networks (nil = unbounded)
+	Limits        *LimitsConfig        `json:"limits,omitempty"`        // Size limits of private networks (nil = protocol defaults)
+}
+
+// LimitsConfig overrides the size limits of the protocol, letting private networks
+// raise or lower them. Fields left zero keep the protocol default. Apart from the
+// transaction size, which only affects pool admission, the limits are consensus
+// rules and must not be changed once a network is running.
+type LimitsConfig struct {
+	MaxTxSize       uint64 `json:"maxTxSize,omitempty"`       // Maximum encoded size of transactions admitted to the pool
+	MaxCodeSize     uint64 `json:"maxCodeSize,omitempty"`     // Maximum size of the code of created contracts
+	MaxUncleDepth   uint64 `json:"maxUncleDepth,omitempty"`   // Number of most recent ancestors an uncle may branch off from
+	CallCreateDepth uint64 `json:"callCreateDepth,omitempty"` // Maximum depth of the call/create stack of the EVM
+	StackLimit      uint64 `json:"stackLimit,omitempty"`      // Maximum number of items on the EVM stack
+}
+
+// BlockIntervalConfig bounds the number of seconds between blocks, letting test
+// networks running without real difficulty simulate the timing of the main
+// network.
+type BlockIntervalConfig struct {
+	Min uint64 `json:"min"` // Minimum seconds between a block and its parent
+	Max uint64 `json:"max"` // Maximum seconds between a block and its parent (0 = unbounded)
+}
+
+// FreezeConfig enables the enforcement of an address freeze list on private
+// networks: from the activation block on, transactions from or to frozen
+// addresses are neither accepted by the transaction pool nor valid in blocks.
+// The list is updated on chain by transactions of the governance account sent
+// to the registry account, whose storage holds the changes to the list.
+type FreezeConfig struct {
+	Block      *big.Int         `json:"block"`            // Activation block
+	Governance common.Address   `json:"governance"`       // Sender of the freeze list updates
+	Registry   common.Address   `json:"registry"`         // Recipient of the freeze list updates, storing the list
+	Frozen     []common.Address `json:"frozen,omitempty"` // Addresses frozen from the activation block
 }
 
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v EIP1014: %v EIP211: %v EIP145: %v EIP1052: %v EIP140: %v EIP214: %v Freeze: %v}",
 		c.ChainId,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -91,6 +143,13 @@ func (c *ChainConfig) String() string {
 		c.EIP150Block,
 		c.EIP155Block,
 		c.EIP158Block,
+		c.EIP1014Block,
+		c.EIP211Block,
+		c.EIP145Block,
+		c.EIP1052Block,
+		c.EIP140Block,
+		c.EIP214Block,
+		c.freezeBlock(),
 	)
 }
 
@@ -104,6 +163,32 @@ func (c *ChainConfig) IsDAOFork(num *big.Int) bool {
 	return isForked(c.DAOForkBlock, num)
 }
 
+// GasFreeConfig lets whitelisted senders of private networks transact without
+// paying for gas: their transactions bypass the gas price floors of transaction
+// pools and miners, and are included ahead of paying ones.
+type GasFreeConfig struct {
+	Senders []common.Address `json:"senders"` // Accounts allowed to transact gas free
+}
+
+// IsGasFreeSender returns whether the transactions of addr bypass the gas price
+// floors of the network.
+func (c *ChainConfig) IsGasFreeSender(addr common.Address) bool {
+	if c.GasFree == nil {
+		return false
+	}
+	for _, sender := range c.GasFree.Senders {
+		if sender == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFreeze returns whether the address freeze list is enforced at num.
+func (c *ChainConfig) IsFreeze(num *big.Int) bool {
+	return c.Freeze != nil && isForked(c.Freeze.Block, num)
+}
+
 func (c *ChainConfig) IsEIP150(num *big.Int) bool {
 	return isForked(c.EIP150Block, num)
 }
@@ -116,6 +201,79 @@ func (c *ChainConfig) IsEIP158(num *big.Int) bool {
 	return isForked(c.EIP158Block, num)
 }
 
+// IsEIP1014 returns whether the CREATE2 opcode is available at num.
+func (c *ChainConfig) IsEIP1014(num *big.Int) bool {
+	return isForked(c.EIP1014Block, num)
+}
+
+// IsEIP211 returns whether the RETURNDATASIZE and RETURNDATACOPY opcodes are
+// available at num.
+func (c *ChainConfig) IsEIP211(num *big.Int) bool {
+	return isForked(c.EIP211Block, num)
+}
+
+// IsEIP145 returns whether the bitwise shifting opcodes are available at num.
+func (c *ChainConfig) IsEIP145(num *big.Int) bool {
+	return isForked(c.EIP145Block, num)
+}
+
+// IsEIP1052 returns whether the EXTCODEHASH opcode is available at num.
+func (c *ChainConfig) IsEIP1052(num *big.Int) bool {
+	return isForked(c.EIP1052Block, num)
+}
+
+// IsEIP140 returns whether the REVERT opcode is available at num.
+func (c *ChainConfig) IsEIP140(num *big.Int) bool {
+	return isForked(c.EIP140Block, num)
+}
+
+// IsEIP214 returns whether the STATICCALL opcode is available at num.
+func (c *ChainConfig) IsEIP214(num *big.Int) bool {
+	return isForked(c.EIP214Block, num)
+}
+
+// MaxTxSize returns the maximum encoded size of transactions admitted to the
+// transaction pool.
+func (c *ChainConfig) MaxTxSize() uint64 {
+	if c.Limits != nil && c.Limits.MaxTxSize != 0 {
+		return c.Limits.MaxTxSize
+	}
+	return MaxTxSize
+}
+
+// MaxCodeSize returns the maximum size of the code of created contracts.
+func (c *ChainConfig) MaxCodeSize() uint64 {
+	if c.Limits != nil && c.Limits.MaxCodeSize != 0 {
+		return c.Limits.MaxCodeSize
+	}
+	return MaxCodeSize
+}
+
+// MaxUncleDepth returns the number of most recent ancestors of a block its uncles
+// may branch off from.
+func (c *ChainConfig) MaxUncleDepth() uint64 {
+	if c.Limits != nil && c.Limits.MaxUncleDepth != 0 {
+		return c.Limits.MaxUncleDepth
+	}
+	return MaxUncleDepth
+}
+
+// CallCreateDepth returns the maximum depth of the call/create stack of the EVM.
+func (c *ChainConfig) CallCreateDepth() uint64 {
+	if c.Limits != nil && c.Limits.CallCreateDepth != 0 {
+		return c.Limits.CallCreateDepth
+	}
+	return CallCreateDepth
+}
+
+// StackLimit returns the maximum number of items on the EVM stack.
+func (c *ChainConfig) StackLimit() uint64 {
+	if c.Limits != nil && c.Limits.StackLimit != 0 {
+		return c.Limits.StackLimit
+	}
+	return StackLimit
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -173,9 +331,39 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if c.IsEIP158(head) && !configNumEqual(c.ChainId, newcfg.ChainId) {
 		return newCompatError("EIP158 chain ID", c.EIP158Block, newcfg.EIP158Block)
 	}
+	if isForkIncompatible(c.EIP1014Block, newcfg.EIP1014Block, head) {
+		return newCompatError("EIP1014 fork block", c.EIP1014Block, newcfg.EIP1014Block)
+	}
+	if isForkIncompatible(c.EIP211Block, newcfg.EIP211Block, head) {
+		return newCompatError("EIP211 fork block", c.EIP211Block, newcfg.EIP211Block)
+	}
+	if isForkIncompatible(c.EIP145Block, newcfg.EIP145Block, head) {
+		return newCompatError("EIP145 fork block", c.EIP145Block, newcfg.EIP145Block)
+	}
+	if isForkIncompatible(c.EIP1052Block, newcfg.EIP1052Block, head) {
+		return newCompatError("EIP1052 fork block", c.EIP1052Block, newcfg.EIP1052Block)
+	}
+	if isForkIncompatible(c.EIP140Block, newcfg.EIP140Block, head) {
+		return newCompatError("EIP140 fork block", c.EIP140Block, newcfg.EIP140Block)
+	}
+	if isForkIncompatible(c.EIP214Block, newcfg.EIP214Block, head) {
+		return newCompatError("EIP214 fork block", c.EIP214Block, newcfg.EIP214Block)
+	}
+	if isForkIncompatible(c.freezeBlock(), newcfg.freezeBlock(), head) {
+		return newCompatError("address freeze block", c.freezeBlock(), newcfg.freezeBlock())
+	}
 	return nil
 }
 
+// freezeBlock returns the activation block of the address freeze list, nil if it
+// isn't enforced.
+func (c *ChainConfig) freezeBlock() *big.Int {
+	if c.Freeze == nil {
+		return nil
+	}
+	return c.Freeze.Block
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2, head *big.Int) bool {
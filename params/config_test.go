@@ -70,6 +70,28 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored: &ChainConfig{EIP1014Block: big.NewInt(10)},
+			new:    &ChainConfig{EIP1014Block: nil},
+			head:   12,
+			wantErr: &ConfigCompatError{
+				What:         "EIP1014 fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    nil,
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{EIP145Block: big.NewInt(30)},
+			new:    &ChainConfig{EIP145Block: big.NewInt(20)},
+			head:   25,
+			wantErr: &ConfigCompatError{
+				What:         "EIP145 fork block",
+				StoredConfig: big.NewInt(30),
+				NewConfig:    big.NewInt(20),
+				RewindTo:     19,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -79,3 +101,38 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestLimits(t *testing.T) {
+	config := &ChainConfig{}
+	if size := config.MaxTxSize(); size != MaxTxSize {
+		t.Errorf("default tx size mismatch: have %d, want %d", size, MaxTxSize)
+	}
+	if size := config.MaxCodeSize(); size != MaxCodeSize {
+		t.Errorf("default code size mismatch: have %d, want %d", size, MaxCodeSize)
+	}
+	if depth := config.MaxUncleDepth(); depth != MaxUncleDepth {
+		t.Errorf("default uncle depth mismatch: have %d, want %d", depth, MaxUncleDepth)
+	}
+	if depth := config.CallCreateDepth(); depth != CallCreateDepth {
+		t.Errorf("default call depth mismatch: have %d, want %d", depth, CallCreateDepth)
+	}
+	if limit := config.StackLimit(); limit != StackLimit {
+		t.Errorf("default stack limit mismatch: have %d, want %d", limit, StackLimit)
+	}
+	config.Limits = &LimitsConfig{MaxCodeSize: 2 * MaxCodeSize, MaxUncleDepth: 3, CallCreateDepth: 64, StackLimit: 2048}
+	if size := config.MaxTxSize(); size != MaxTxSize {
+		t.Errorf("unset tx size mismatch: have %d, want %d", size, MaxTxSize)
+	}
+	if size := config.MaxCodeSize(); size != 2*MaxCodeSize {
+		t.Errorf("raised code size mismatch: have %d, want %d", size, 2*MaxCodeSize)
+	}
+	if depth := config.MaxUncleDepth(); depth != 3 {
+		t.Errorf("lowered uncle depth mismatch: have %d, want 3", depth)
+	}
+	if depth := config.CallCreateDepth(); depth != 64 {
+		t.Errorf("lowered call depth mismatch: have %d, want 64", depth)
+	}
+	if limit := config.StackLimit(); limit != 2048 {
+		t.Errorf("raised stack limit mismatch: have %d, want 2048", limit)
+	}
+}
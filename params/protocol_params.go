@@ -56,11 +56,14 @@ const (
 	TierStepGas      uint64 = 0     // Once per operation, for a selection of them.
 	LogTopicGas      uint64 = 375   // Multiplied by the * of the LOG*, per LOG transaction. e.g. LOG0 incurs 0 * c_txLogTopicGas, LOG4 incurs 4 * c_txLogTopicGas.
 	CreateGas        uint64 = 32000 // Once per CREATE operation & contract-creation transaction.
+	ExtcodeHashGas   uint64 = 400   // Once per EXTCODEHASH operation.
 	SuicideRefundGas uint64 = 24000 // Refunded following a suicide operation.
 	MemoryGas        uint64 = 3     // Times the address of the (highest referenced byte in memory + 1). NOTE: referencing happens on read, write and in instructions such as RETURN and CALL.
 	TxDataNonZeroGas uint64 = 68    // Per byte of data attached to a transaction that is not equal to zero. NOTE: Not payable on data of calls between transactions.
 
-	MaxCodeSize = 24576
+	MaxCodeSize   = 24576     // Maximum size of the code of created contracts
+	MaxTxSize     = 32 * 1024 // Maximum encoded size of transactions admitted to the pool
+	MaxUncleDepth = 7         // Number of most recent ancestors an uncle may branch off from
 )
 
 var (
@@ -20,7 +20,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"math"
 	"math/big"
 	"math/rand"
 	"os"
@@ -32,6 +31,8 @@ import (
 	"unsafe"
 
 	mmap "github.com/edsrzf/mmap-go"
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
 	"github.com/expanse-org/go-expanse/log"
 	metrics "github.com/rcrowley/go-metrics"
 )
@@ -157,7 +158,7 @@ type cache struct {
 }
 
 // generate ensures that the cache content is generated before use.
-func (c *cache) generate(dir string, limit int, test bool) {
+func (c *cache) generate(dir string, test bool) {
 	c.once.Do(func() {
 		// If we have a testing cache, generate and return
 		if test {
@@ -199,12 +200,6 @@ func (c *cache) generate(dir string, limit int, test bool) {
 			c.cache = make([]uint32, size/4)
 			generateCache(c.cache, c.epoch, seed)
 		}
-		// Iterate over all previous instances and delete old ones
-		for ep := int(c.epoch) - limit; ep >= 0; ep-- {
-			seed := seedHash(uint64(ep)*epochLength + 1)
-			path := filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s", algorithmRevision, seed[:8], endian))
-			os.Remove(path)
-		}
 	})
 }
 
@@ -234,7 +229,7 @@ type dataset struct {
 }
 
 // generate ensures that the dataset content is generated before use.
-func (d *dataset) generate(dir string, limit int, test bool) {
+func (d *dataset) generate(dir string, test bool) {
 	d.once.Do(func() {
 		// If we have a testing dataset, generate and return
 		if test {
@@ -257,6 +252,8 @@ func (d *dataset) generate(dir string, limit int, test bool) {
 
 			d.dataset = make([]uint32, dsize/4)
 			generateDataset(d.dataset, d.epoch, cache)
+
+			return
 		}
 		// Disk storage is needed, this will get fancy
 		var endian string
@@ -286,12 +283,6 @@ func (d *dataset) generate(dir string, limit int, test bool) {
 			d.dataset = make([]uint32, dsize/2)
 			generateDataset(d.dataset, d.epoch, cache)
 		}
-		// Iterate over all previous instances and delete old ones
-		for ep := int(d.epoch) - limit; ep >= 0; ep-- {
-			seed := seedHash(uint64(ep)*epochLength + 1)
-			path := filepath.Join(dir, fmt.Sprintf("full-R%d-%x%s", algorithmRevision, seed[:8], endian))
-			os.Remove(path)
-		}
 	})
 }
 
@@ -310,14 +301,14 @@ func (d *dataset) release() {
 // MakeCache generates a new ethash cache and optionally stores it to disk.
 func MakeCache(block uint64, dir string) {
 	c := cache{epoch: block/epochLength + 1}
-	c.generate(dir, math.MaxInt32, false)
+	c.generate(dir, false)
 	c.release()
 }
 
 // MakeDataset generates a new ethash dataset and optionally stores it to disk.
 func MakeDataset(block uint64, dir string) {
 	d := dataset{epoch: block/epochLength + 1}
-	d.generate(dir, math.MaxInt32, false)
+	d.generate(dir, false)
 	d.release()
 }
 
@@ -329,6 +320,8 @@ type Ethash struct {
 	dagdir       string // Data directory to store full mining datasets
 	dagsinmem    int    // Number of mining datasets to keep in memory
 	dagsondisk   int    // Number of mining datasets to keep on disk
+	cachequota   uint64 // Disk space (bytes) the verification caches may use (0 = unlimited)
+	dagquota     uint64 // Disk space (bytes) the mining datasets may use (0 = unlimited)
 
 	caches   map[uint64]*cache   // In memory caches to avoid regenerating too often
 	fcache   *cache              // Pre-generated cache for the estimated future epoch
@@ -338,6 +331,8 @@ type Ethash struct {
 
 	hashrate metrics.Meter // Meter tracking the average hashrate
 
+	storageLow func() bool // Reports whether disk space is critically low (nil = never)
+
 	tester bool // Flag whether to use a smaller test dataset
 }
 
@@ -349,9 +344,11 @@ func NewFullEthash(cachedir string, cachesinmem, cachesondisk int, dagdir string
 	}
 	if cachedir != "" && cachesondisk > 0 {
 		log.Info("Disk storage enabled for ethash caches", "dir", cachedir, "count", cachesondisk)
+		reportStorage(cachedir, "cache")
 	}
 	if dagdir != "" && dagsondisk > 0 {
 		log.Info("Disk storage enabled for ethash DAGs", "dir", dagdir, "count", dagsondisk)
+		reportStorage(dagdir, "full")
 	}
 	return &Ethash{
 		cachedir:     cachedir,
@@ -384,6 +381,27 @@ func NewSharedEthash() PoW {
 	return sharedEthash
 }
 
+// SetStorageGuard sets a callback reporting whether the disk space available to
+// the DAG directory is critically low. While it does, mining datasets are only
+// generated in memory and future datasets are not pre-generated at all.
+func (ethash *Ethash) SetStorageGuard(low func() bool) {
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	ethash.storageLow = low
+}
+
+// SetStorageQuota limits the disk space (in bytes) the verification caches and the
+// mining datasets may use, zero meaning unlimited. When exceeded, the files of the
+// oldest epochs are removed, even if still within the retention window. Files of
+// the current and future epochs are always kept.
+func (ethash *Ethash) SetStorageQuota(caches, datasets uint64) {
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	ethash.cachequota, ethash.dagquota = caches, datasets
+}
+
 // Verify implements PoW, checking whether the given block satisfies the PoW
 // difficulty requirements.
 func (ethash *Ethash) Verify(block Block) error {
@@ -461,18 +479,25 @@ func (ethash *Ethash) cache(block uint64) []uint32 {
 		}
 	}
 	current.used = time.Now()
+	quota := ethash.cachequota
 	ethash.lock.Unlock()
 
 	// Wait for generation finish, bump the timestamp and finalize the cache
-	current.generate(ethash.cachedir, ethash.cachesondisk, ethash.tester)
+	current.generate(ethash.cachedir, ethash.tester)
 
 	current.lock.Lock()
 	current.used = time.Now()
 	current.lock.Unlock()
 
-	// If we exhausted the future cache, now's a good time to regenerate it
+	// If we exhausted the future cache, now's a good time to regenerate it and to
+	// drop the caches of the epochs the chain moved past
 	if future != nil {
-		go future.generate(ethash.cachedir, ethash.cachesondisk, ethash.tester)
+		go func() {
+			future.generate(ethash.cachedir, ethash.tester)
+			if ethash.cachedir != "" && !ethash.tester {
+				pruneStorage(ethash.cachedir, "cache", epoch, ethash.cachesondisk, quota)
+			}
+		}()
 	}
 	return current.cache
 }
@@ -560,18 +585,38 @@ func (ethash *Ethash) dataset(block uint64) []uint32 {
 		}
 	}
 	current.used = time.Now()
+
+	// If disk space is running out, don't write any new DAGs to disk, but free up
+	// whatever the chain doesn't need any more
+	dagdir, lowDisk, quota := ethash.dagdir, ethash.storageLow != nil && ethash.storageLow(), ethash.dagquota
+	if lowDisk {
+		log.Warn("Disk space critically low, generating ethash dataset in memory", "epoch", epoch)
+		if dagdir != "" && future != nil {
+			go pruneStorage(dagdir, "full", epoch, ethash.dagsondisk, quota)
+		}
+		dagdir = ""
+		if future != nil {
+			ethash.fdataset, future = nil, nil
+		}
+	}
 	ethash.lock.Unlock()
 
 	// Wait for generation finish, bump the timestamp and finalize the cache
-	current.generate(ethash.dagdir, ethash.dagsondisk, ethash.tester)
+	current.generate(dagdir, ethash.tester)
 
 	current.lock.Lock()
 	current.used = time.Now()
 	current.lock.Unlock()
 
-	// If we exhausted the future dataset, now's a good time to regenerate it
+	// If we exhausted the future dataset, now's a good time to regenerate it and
+	// to drop the datasets of the epochs the chain moved past
 	if future != nil {
-		go future.generate(ethash.dagdir, ethash.dagsondisk, ethash.tester)
+		go func() {
+			future.generate(dagdir, ethash.tester)
+			if dagdir != "" && !ethash.tester {
+				pruneStorage(dagdir, "full", epoch, ethash.dagsondisk, quota)
+			}
+		}()
 	}
 	return current.dataset
 }
@@ -582,6 +627,12 @@ func (ethash *Ethash) Hashrate() float64 {
 	return ethash.hashrate.Rate1()
 }
 
+// Author implements PoW, returning the coinbase of the header as ethash blocks
+// aren't signed by their miners.
+func (ethash *Ethash) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
+
 // EthashSeedHash is the seed to use for generating a vrification cache and the
 // mining dataset.
 func EthashSeedHash(block uint64) []byte {
@@ -0,0 +1,181 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pow
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/crypto/sha3"
+	"github.com/expanse-org/go-expanse/log"
+)
+
+// storedFileRE matches the names of the ethash caches and datasets stored on disk,
+// capturing the kind, the algorithm revision, the seed prefix and the byte order.
+var storedFileRE = regexp.MustCompile(`^(cache|full)-R(\d+)-([0-9a-f]{16})(\.be)?$`)
+
+var (
+	seedEpochs     map[string]uint64 // Epochs indexed by the hex seed prefix used in file names
+	seedEpochsOnce sync.Once         // Ensures the seed index is built only once
+)
+
+// seedEpoch resolves the epoch of an ethash file from the seed prefix in its name.
+func seedEpoch(prefix string) (uint64, bool) {
+	seedEpochsOnce.Do(func() {
+		seedEpochs = make(map[string]uint64)
+
+		seed := make([]byte, 32)
+		keccak256 := makeHasher(sha3.NewKeccak256())
+		for epoch := 0; epoch < len(cacheSizes); epoch++ {
+			seedEpochs[hex.EncodeToString(seed[:8])] = uint64(epoch)
+			keccak256(seed, seed)
+		}
+	})
+	epoch, ok := seedEpochs[prefix]
+	return epoch, ok
+}
+
+// storedFile is an ethash verification cache or mining dataset found on disk.
+type storedFile struct {
+	path  string // Location of the file
+	epoch uint64 // Epoch the file was generated for (meaningless if stale)
+	size  uint64 // Size of the file in bytes
+	stale bool   // Whether the file is unusable (other algorithm revision or byte order)
+}
+
+// scanStorage lists the ethash files of a kind ("cache" or "full") stored in dir,
+// ordered by epoch with the stale ones first. Temporary files of generations in
+// progress and unrelated files are ignored.
+func scanStorage(dir, kind string) ([]storedFile, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []storedFile
+	for _, info := range infos {
+		match := storedFileRE.FindStringSubmatch(info.Name())
+		if match == nil || match[1] != kind || info.IsDir() {
+			continue
+		}
+		file := storedFile{
+			path: filepath.Join(dir, info.Name()),
+			size: uint64(info.Size()),
+		}
+		revision, _ := strconv.Atoi(match[2])
+		epoch, known := seedEpoch(match[3])
+
+		file.epoch = epoch
+		file.stale = !known || revision != algorithmRevision || (match[4] == "") != isLittleEndian()
+
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].stale != files[j].stale {
+			return files[i].stale
+		}
+		return files[i].epoch < files[j].epoch
+	})
+	return files, nil
+}
+
+// pruneStorage removes the ethash files of a kind stored in dir which are stale,
+// or belong to epochs out of the retention window of the current epoch (keeping
+// the last keep epochs). If the remaining files still exceed the quota (in bytes,
+// 0 = unlimited), older epochs are removed until they fit. Files of the current
+// and future epochs are never removed.
+func pruneStorage(dir, kind string, current uint64, keep int, quota uint64) {
+	files, err := scanStorage(dir, kind)
+	if err != nil {
+		log.Debug("Failed to scan ethash storage", "dir", dir, "err", err)
+		return
+	}
+	if keep < 1 {
+		keep = 1
+	}
+	var (
+		retained []storedFile
+		size     uint64
+	)
+	for _, file := range files {
+		if file.stale || file.epoch+uint64(keep) <= current {
+			removeStoredFile(file, "retention")
+			continue
+		}
+		retained = append(retained, file)
+		size += file.size
+	}
+	if quota == 0 || size <= quota {
+		return
+	}
+	for _, file := range retained {
+		if size <= quota || file.epoch >= current {
+			break
+		}
+		removeStoredFile(file, "quota")
+		size -= file.size
+	}
+	if size > quota {
+		log.Warn("Ethash storage above quota", "dir", dir, "kind", kind, "size", common.StorageSize(size), "quota", common.StorageSize(quota))
+	}
+}
+
+// removeStoredFile deletes an ethash file from disk, logging the reason.
+func removeStoredFile(file storedFile, reason string) {
+	if err := os.Remove(file.path); err != nil {
+		log.Debug("Failed to remove ethash file", "path", file.path, "err", err)
+		return
+	}
+	if file.stale {
+		log.Info("Removed stale ethash file", "path", file.path, "size", common.StorageSize(file.size))
+	} else {
+		log.Info("Removed old ethash file", "path", file.path, "epoch", file.epoch, "size", common.StorageSize(file.size), "reason", reason)
+	}
+}
+
+// reportStorage logs the disk space used by the ethash files of a kind stored in
+// dir, warning about stale ones which will be removed on first use.
+func reportStorage(dir, kind string) {
+	files, err := scanStorage(dir, kind)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Failed to scan ethash storage", "dir", dir, "err", err)
+		}
+		return
+	}
+	var (
+		usable, stale         int
+		usableSize, staleSize uint64
+	)
+	for _, file := range files {
+		if file.stale {
+			stale, staleSize = stale+1, staleSize+file.size
+		} else {
+			usable, usableSize = usable+1, usableSize+file.size
+		}
+	}
+	log.Info("Ethash storage usage", "dir", dir, "kind", kind, "files", usable, "size", common.StorageSize(usableSize))
+	if stale > 0 {
+		log.Warn("Stale ethash files on disk", "dir", dir, "kind", kind, "files", stale, "size", common.StorageSize(staleSize))
+	}
+}
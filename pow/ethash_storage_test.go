@@ -0,0 +1,114 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pow
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// storedFileName returns the name an ethash file of the given kind and epoch is
+// stored under, in the local or in the opposite byte order.
+func storedFileName(kind string, revision int, epoch uint64, native bool) string {
+	var endian string
+	if isLittleEndian() != native {
+		endian = ".be"
+	}
+	seed := seedHash(epoch*epochLength + 1)
+	return fmt.Sprintf("%s-R%d-%x%s", kind, revision, seed[:8], endian)
+}
+
+// Tests that ethash files out of the retention window, of other algorithm
+// revisions or byte orders, and above the quota are pruned, while the files of
+// the current and future epochs are kept.
+func TestStoragePruning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-storage-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	create := func(name string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), make([]byte, 100), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+	for epoch := uint64(0); epoch < 5; epoch++ {
+		create(storedFileName("cache", algorithmRevision, epoch, true))
+		create(storedFileName("full", algorithmRevision, epoch, true))
+	}
+	create(storedFileName("cache", algorithmRevision-1, 3, true))
+	create(storedFileName("cache", algorithmRevision, 3, false))
+	create(storedFileName("cache", algorithmRevision, 0, true) + ".1234") // generation in progress
+
+	check := func(kind string, epochs ...uint64) {
+		files, err := scanStorage(dir, kind)
+		if err != nil {
+			t.Fatalf("failed to scan storage: %v", err)
+		}
+		var have []uint64
+		for _, file := range files {
+			if file.stale {
+				t.Errorf("%s: stale file %s retained", kind, file.path)
+			}
+			have = append(have, file.epoch)
+		}
+		if fmt.Sprint(have) != fmt.Sprint(epochs) {
+			t.Errorf("%s: retained epochs mismatch: have %v, want %v", kind, have, epochs)
+		}
+	}
+	// Both stale caches should be reported before pruning
+	files, err := scanStorage(dir, "cache")
+	if err != nil {
+		t.Fatalf("failed to scan storage: %v", err)
+	}
+	if len(files) != 7 || !files[0].stale || !files[1].stale || files[2].stale {
+		t.Fatalf("scanned files mismatch: %+v", files)
+	}
+	// Prune the epochs older than current-1, leaving datasets alone
+	pruneStorage(dir, "cache", 3, 2, 0)
+	check("cache", 2, 3, 4)
+	check("full", 0, 1, 2, 3, 4)
+
+	if _, err := os.Stat(filepath.Join(dir, storedFileName("cache", algorithmRevision, 0, true)+".1234")); err != nil {
+		t.Errorf("temporary file removed: %v", err)
+	}
+	// Enforce a quota, which may eat into the retention window, but never into the
+	// current and future epochs
+	pruneStorage(dir, "full", 3, 3, 250)
+	check("full", 3, 4)
+
+	pruneStorage(dir, "cache", 3, 2, 50)
+	check("cache", 3, 4)
+}
+
+// Tests that the epochs of ethash files are resolved from their seed prefixes.
+func TestStorageSeedEpochs(t *testing.T) {
+	epochs := []uint64{0, 1, 2, 100, uint64(len(cacheSizes) - 1)}
+	for _, epoch := range epochs {
+		seed := seedHash(epoch*epochLength + 1)
+		if have, ok := seedEpoch(fmt.Sprintf("%x", seed[:8])); !ok || have != epoch {
+			t.Errorf("epoch %d: resolved epoch mismatch: have %d/%v", epoch, have, ok)
+		}
+	}
+	if _, ok := seedEpoch("0123456789abcdef"); ok {
+		t.Errorf("unknown seed resolved")
+	}
+}
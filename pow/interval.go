@@ -0,0 +1,113 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pow
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+)
+
+var (
+	errBlockTooFast = errors.New("block mined too soon after its parent")
+	errBlockTooSlow = errors.New("block mined too long after its parent")
+)
+
+// HeaderReader retrieves the headers of the chain, used by engines needing the
+// parent of the blocks they verify.
+type HeaderReader interface {
+	GetHeader(hash common.Hash, number uint64) *types.Header
+}
+
+// IntervalPoW wraps a proof of work engine, enforcing bounds on the number of
+// seconds between a block and its parent. It lets test networks running without
+// real difficulty simulate the timing of the main network: blocks are only
+// sealed once their timestamp is reached, and miners stamp them within the
+// bounds via Timestamp.
+type IntervalPoW struct {
+	PoW
+	min, max uint64       // Bounds of the block interval in seconds (max 0 = unbounded)
+	chain    atomic.Value // HeaderReader to look up the parents of verified blocks
+}
+
+// NewIntervalPoW wraps the engine, enforcing the given bounds of the block
+// interval in seconds. A maximum of zero leaves the interval unbounded.
+func NewIntervalPoW(engine PoW, min, max uint64) *IntervalPoW {
+	return &IntervalPoW{PoW: engine, min: min, max: max}
+}
+
+// SetChain sets the chain the parents of verified blocks are looked up in. Until
+// set, only the wrapped engine verifies blocks.
+func (pow *IntervalPoW) SetChain(chain HeaderReader) {
+	pow.chain.Store(chain)
+}
+
+// Timestamp returns the timestamp a block with the given parent mined at the
+// given time must carry to honour the interval bounds.
+func (pow *IntervalPoW) Timestamp(parent *types.Header, now uint64) uint64 {
+	ptime := parent.Time.Uint64()
+	if now < ptime+pow.min {
+		return ptime + pow.min
+	}
+	if pow.max > 0 && now > ptime+pow.max {
+		return ptime + pow.max
+	}
+	return now
+}
+
+// Verify implements PoW, checking the interval of the block to its parent on
+// top of the proof of work.
+func (pow *IntervalPoW) Verify(block Block) error {
+	if err := pow.PoW.Verify(block); err != nil {
+		return err
+	}
+	b, ok := block.(*types.Block)
+	chain, _ := pow.chain.Load().(HeaderReader)
+	if !ok || chain == nil || b.NumberU64() == 0 {
+		return nil
+	}
+	parent := chain.GetHeader(b.ParentHash(), b.NumberU64()-1)
+	if parent == nil {
+		return nil // unknown parents are reported by the block validation
+	}
+	ptime, btime := parent.Time.Uint64(), b.Time().Uint64()
+	if btime < ptime+pow.min {
+		return errBlockTooFast
+	}
+	if pow.max > 0 && btime > ptime+pow.max {
+		return errBlockTooSlow
+	}
+	return nil
+}
+
+// Search implements PoW, delaying sealing until the timestamp of the block is
+// reached, so it isn't published ahead of its time.
+func (pow *IntervalPoW) Search(block Block, stop <-chan struct{}) (uint64, []byte) {
+	if b, ok := block.(*types.Block); ok {
+		if wait := time.Unix(b.Time().Int64(), 0).Sub(time.Now()); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-stop:
+				return 0, nil
+			}
+		}
+	}
+	return pow.PoW.Search(block, stop)
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pow
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/core/types"
+)
+
+// testHeaderReader is a HeaderReader serving a fixed set of headers.
+type testHeaderReader map[common.Hash]*types.Header
+
+func (r testHeaderReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return r[hash]
+}
+
+// Tests that block timestamps are clamped into the interval bounds.
+func TestIntervalTimestamp(t *testing.T) {
+	parent := &types.Header{Time: big.NewInt(100)}
+
+	tests := []struct {
+		min, max uint64
+		now      uint64
+		want     uint64
+	}{
+		{10, 20, 105, 110}, // too soon, pushed to the minimum
+		{10, 20, 115, 115}, // within bounds, left alone
+		{10, 20, 130, 120}, // too late, pulled back to the maximum
+		{10, 0, 1000, 1000},
+	}
+	for i, tt := range tests {
+		engine := NewIntervalPoW(FakePow{}, tt.min, tt.max)
+		if have := engine.Timestamp(parent, tt.now); have != tt.want {
+			t.Errorf("test %d: timestamp mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that blocks violating the interval bounds are rejected once the chain
+// is known.
+func TestIntervalVerify(t *testing.T) {
+	parent := &types.Header{Number: big.NewInt(1), Time: big.NewInt(100)}
+	child := func(time int64) *types.Block {
+		return types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), ParentHash: parent.Hash(), Time: big.NewInt(time)})
+	}
+	engine := NewIntervalPoW(FakePow{}, 10, 20)
+
+	// Without a chain, only the wrapped engine verifies
+	if err := engine.Verify(child(101)); err != nil {
+		t.Fatalf("verification without chain failed: %v", err)
+	}
+	engine.SetChain(testHeaderReader{parent.Hash(): parent})
+
+	tests := []struct {
+		time int64
+		err  error
+	}{
+		{105, errBlockTooFast},
+		{110, nil},
+		{120, nil},
+		{121, errBlockTooSlow},
+	}
+	for i, tt := range tests {
+		if err := engine.Verify(child(tt.time)); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
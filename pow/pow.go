@@ -40,6 +40,10 @@ type PoW interface {
 	Verify(block Block) error
 	Search(block Block, stop <-chan struct{}) (uint64, []byte)
 	Hashrate() float64
+
+	// Author retrieves the address of the account that minted the given block,
+	// which may differ from the coinbase for engines signing their blocks.
+	Author(header *types.Header) (common.Address, error)
 }
 
 // FakePow is a non-validating proof of work implementation.
@@ -56,3 +60,8 @@ func (pow FakePow) Search(block Block, stop <-chan struct{}) (uint64, []byte) {
 
 // Hashrate implements PoW, returning 0.
 func (pow FakePow) Hashrate() float64 { return 0 }
+
+// Author implements PoW, returning the coinbase of the header.
+func (pow FakePow) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
@@ -76,6 +76,16 @@ An example server which uses the JSON codec:
 	go server.ServeCodec(codec)
  }
 
+Several versions of a service can be offered side by side by registering them with
+RegisterVersionedName. A specific version is called as <namespace>@<version>_<method>,
+e.g. calculator@2.0_add, while calculator_add is served by the version registered first.
+The rpc_modules method reports the version behind each plain namespace and
+rpc_moduleVersions lists all registered versions.
+
+A namespace can be served under another name too with RegisterAlias, which resolves
+methods and subscriptions at call time so that later registrations are picked up. All
+servers alias exp to eth, including exp_subscribe and the exp_subscription notifications.
+
 The package also supports the publish subscribe pattern through the use of subscriptions.
 A method that is considered eligible for notifications must satisfy the following criteria:
  - object must be exported
@@ -89,6 +99,10 @@ An example method:
  	...
  }
 
+Subscriptions are created through the <namespace>_subscribe method of the namespace the
+service is registered in, e.g. eth_subscribe or admin_subscribe, and notifications are
+delivered as <namespace>_subscription messages.
+
 Subscriptions are deleted when:
  - the user sends an unsubscribe request
  - the connection which was used to create the subscription is closed. This can be initiated
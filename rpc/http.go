@@ -137,11 +137,13 @@ func (t *httpReadWriteNopCloser) Close() error {
 	return nil
 }
 
-// NewHTTPServer creates a new HTTP RPC server around an API provider.
+// NewHTTPServer creates a new HTTP RPC server around an API provider. Requests
+// are only served if their Host header matches one of the given virtual hosts.
 //
 // Deprecated: Server implements http.Handler
-func NewHTTPServer(corsString string, srv *Server) *http.Server {
-	return &http.Server{Handler: newCorsHandler(srv, corsString)}
+func NewHTTPServer(corsString string, vhosts []string, srv *Server) *http.Server {
+	handler := newCorsHandler(srv, corsString)
+	return &http.Server{Handler: newVHostHandler(vhosts, handler)}
 }
 
 // ServeHTTP serves JSON-RPC requests over HTTP.
@@ -175,3 +177,52 @@ func newCorsHandler(srv *Server, corsString string) http.Handler {
 	})
 	return c.Handler(srv)
 }
+
+// virtualHostHandler is a handler which validates the Host-header of incoming
+// requests. Using virtual hosts protects the HTTP endpoint against DNS rebinding
+// attacks, where a malicious site resolves its own domain to a local address
+// and issues requests to it from the victim's browser.
+type virtualHostHandler struct {
+	vhosts map[string]struct{}
+	next   http.Handler
+}
+
+// newVHostHandler wraps next into a handler that only admits requests whose
+// Host header is an IP address or one of the whitelisted vhosts. A "*" entry
+// disables the check altogether.
+func newVHostHandler(vhosts []string, next http.Handler) http.Handler {
+	vhostMap := make(map[string]struct{})
+	for _, allowedHost := range vhosts {
+		vhostMap[strings.ToLower(strings.TrimSpace(allowedHost))] = struct{}{}
+	}
+	return &virtualHostHandler{vhostMap, next}
+}
+
+// ServeHTTP serves JSON-RPC requests over HTTP, implements http.Handler
+func (h *virtualHostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// If r.Host is not set, we can continue serving since a browser would set the Host header
+	if r.Host == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		// Either invalid (too many colons) or no port specified
+		host = r.Host
+	}
+	if ipAddr := net.ParseIP(host); ipAddr != nil {
+		// It's an IP address, we can serve that
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	// Not an IP address, but a hostname. Need to validate
+	if _, exist := h.vhosts["*"]; exist {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if _, exist := h.vhosts[strings.ToLower(host)]; exist {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, "invalid host specified", http.StatusForbidden)
+}
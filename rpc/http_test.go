@@ -0,0 +1,57 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Tests that the virtual host handler only admits requests addressed to an IP
+// or one of the whitelisted hostnames.
+func TestVirtualHostHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		vhosts []string
+		host   string
+		code   int
+	}{
+		{[]string{"localhost"}, "", http.StatusOK},
+		{[]string{"localhost"}, "localhost", http.StatusOK},
+		{[]string{"localhost"}, "LocalHost:9656", http.StatusOK},
+		{[]string{"localhost"}, "127.0.0.1:9656", http.StatusOK},
+		{[]string{"localhost"}, "[::1]:9656", http.StatusOK},
+		{[]string{"localhost"}, "evil.com", http.StatusForbidden},
+		{[]string{"localhost"}, "evil.com:9656", http.StatusForbidden},
+		{[]string{"localhost", " example.org"}, "example.org", http.StatusOK},
+		{[]string{"*"}, "evil.com", http.StatusOK},
+		{nil, "localhost", http.StatusForbidden},
+		{nil, "10.0.0.1", http.StatusOK},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Host = tt.host
+
+		rec := httptest.NewRecorder()
+		newVHostHandler(tt.vhosts, ok).ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("test %d (vhosts %v, host %q): status mismatch: have %d, want %d", i, tt.vhosts, tt.host, rec.Code, tt.code)
+		}
+	}
+}
@@ -30,13 +30,29 @@ import (
 )
 
 const (
-	jsonrpcVersion         = "2.0"
-	serviceMethodSeparator = "_"
-	subscribeMethod        = "eth_subscribe"
-	unsubscribeMethod      = "eth_unsubscribe"
-	notificationMethod     = "eth_subscription"
+	jsonrpcVersion           = "2.0"
+	serviceMethodSeparator   = "_"
+	serviceVersionSeparator  = "@"
+	subscribeMethodSuffix    = "_subscribe"
+	unsubscribeMethodSuffix  = "_unsubscribe"
+	notificationMethodSuffix = "_subscription"
+	pubsubNamespace          = "eth"
+	unsubscribeMethod        = pubsubNamespace + unsubscribeMethodSuffix
 )
 
+// pubsubMethod checks whether method is the given (un)subscribe method of some
+// namespace, returning the namespace used.
+func pubsubMethod(method string, suffix string) (string, bool) {
+	if !strings.HasSuffix(method, suffix) {
+		return "", false
+	}
+	namespace := strings.TrimSuffix(method, suffix)
+	if namespace == "" || strings.Contains(namespace, serviceMethodSeparator) {
+		return "", false
+	}
+	return namespace, true
+}
+
 type jsonRequest struct {
 	Method  string          `json:"method"`
 	Version string          `json:"jsonrpc"`
@@ -96,6 +112,10 @@ func (err *jsonError) ErrorCode() int {
 	return err.Code
 }
 
+func (err *jsonError) ErrorData() interface{} {
+	return err.Data
+}
+
 // NewJSONCodec creates a new RPC server codec with support for JSON-RPC 2.0
 func NewJSONCodec(rwc io.ReadWriteCloser) ServerCodec {
 	d := json.NewDecoder(rwc)
@@ -164,7 +184,7 @@ func parseRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
 	}
 
 	// subscribe are special, they will always use `subscribeMethod` as first param in the payload
-	if in.Method == subscribeMethod {
+	if namespace, ok := pubsubMethod(in.Method, subscribeMethodSuffix); ok {
 		reqs := []rpcRequest{{id: &in.Id, isPubSub: true}}
 		if len(in.Payload) > 0 {
 			// first param must be subscription name
@@ -174,15 +194,15 @@ func parseRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
 				return nil, false, &invalidRequestError{"Unable to parse subscription request"}
 			}
 
-			// all subscriptions are made on the eth service
-			reqs[0].service, reqs[0].method = "eth", subscribeMethod[0]
+			// subscriptions are made on the service of the namespace used
+			reqs[0].service, reqs[0].method = namespace, subscribeMethod[0]
 			reqs[0].params = in.Payload
 			return reqs, false, nil
 		}
 		return nil, false, &invalidRequestError{"Unable to parse subscription request"}
 	}
 
-	if in.Method == unsubscribeMethod {
+	if _, ok := pubsubMethod(in.Method, unsubscribeMethodSuffix); ok {
 		return []rpcRequest{{id: &in.Id, isPubSub: true,
 			method: unsubscribeMethod, params: in.Payload}}, false, nil
 	}
@@ -217,7 +237,7 @@ func parseBatchRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error)
 		id := &in[i].Id
 
 		// subscribe are special, they will always use `subscribeMethod` as first param in the payload
-		if r.Method == subscribeMethod {
+		if namespace, ok := pubsubMethod(r.Method, subscribeMethodSuffix); ok {
 			requests[i] = rpcRequest{id: id, isPubSub: true}
 			if len(r.Payload) > 0 {
 				// first param must be subscription name
@@ -227,8 +247,8 @@ func parseBatchRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error)
 					return nil, false, &invalidRequestError{"Unable to parse subscription request"}
 				}
 
-				// all subscriptions are made on the eth service
-				requests[i].service, requests[i].method = "eth", subscribeMethod[0]
+				// subscriptions are made on the service of the namespace used
+				requests[i].service, requests[i].method = namespace, subscribeMethod[0]
 				requests[i].params = r.Payload
 				continue
 			}
@@ -236,7 +256,7 @@ func parseBatchRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error)
 			return nil, true, &invalidRequestError{"Unable to parse (un)subscribe request arguments"}
 		}
 
-		if r.Method == unsubscribeMethod {
+		if _, ok := pubsubMethod(r.Method, unsubscribeMethodSuffix); ok {
 			requests[i] = rpcRequest{id: id, isPubSub: true, method: unsubscribeMethod, params: r.Payload}
 			continue
 		}
@@ -324,14 +344,16 @@ func (c *jsonCodec) CreateErrorResponseWithInfo(id interface{}, err Error, info
 		Error: jsonError{Code: err.ErrorCode(), Message: err.Error(), Data: info}}
 }
 
-// CreateNotification will create a JSON-RPC notification with the given subscription id and event as params.
-func (c *jsonCodec) CreateNotification(subid string, event interface{}) interface{} {
+// CreateNotification will create a JSON-RPC notification with the given subscription id and event as params,
+// sent as a method of the namespace the subscription was created in.
+func (c *jsonCodec) CreateNotification(subid string, namespace string, event interface{}) interface{} {
+	method := namespace + notificationMethodSuffix
 	if isHexNum(reflect.TypeOf(event)) {
-		return &jsonNotification{Version: jsonrpcVersion, Method: notificationMethod,
+		return &jsonNotification{Version: jsonrpcVersion, Method: method,
 			Params: jsonSubscription{Subscription: subid, Result: fmt.Sprintf(`%#x`, event)}}
 	}
 
-	return &jsonNotification{Version: jsonrpcVersion, Method: notificationMethod,
+	return &jsonNotification{Version: jsonrpcVersion, Method: method,
 		Params: jsonSubscription{Subscription: subid, Result: event}}
 }
 
@@ -21,19 +21,23 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/expanse-org/go-expanse/log"
 	"gopkg.in/fatih/set.v0"
 )
 
 const (
-	notificationBufferSize = 10000 // max buffered notifications before codec is closed
+	notificationBufferSize = 10000 // default max queued notifications per connection
 
-	MetadataApi     = "rpc"
-	DefaultIPCApis  = "admin,debug,eth,exp,miner,net,personal,shh,txpool,web3"
-	DefaultHTTPApis = "eth,exp,net,web3"
+	MetadataApi       = "rpc"
+	DefaultApiVersion = "1.0" // version of services registered without an explicit one
+	DefaultIPCApis    = "admin,debug,eth,exp,health,miner,net,personal,shh,txpool,web3"
+	DefaultHTTPApis   = "eth,exp,net,web3"
 )
 
 // CodecOption specifies which type of messages this codec supports
@@ -51,6 +55,7 @@ const (
 func NewServer() *Server {
 	server := &Server{
 		services:      make(serviceRegistry),
+		aliases:       make(map[string]string),
 		subscriptions: make(subscriptionRegistry),
 		codecs:        set.New(),
 		run:           1,
@@ -61,70 +66,188 @@ func NewServer() *Server {
 	rpcService := &RPCService{server}
 	server.RegisterName(MetadataApi, rpcService)
 
+	for alias, name := range DefaultAliases {
+		server.RegisterAlias(alias, name)
+	}
 	return server
 }
 
+// DefaultAliases maps the namespaces every server serves as an alias to the
+// namespace they alias. Expanse serves the whole eth namespace as exp too.
+var DefaultAliases = map[string]string{"exp": "eth"}
+
 // RPCService gives meta information about the server.
 // e.g. gives information about the loaded modules.
 type RPCService struct {
 	server *Server
 }
 
-// Modules returns the list of RPC services with their version number
+// Modules returns the list of RPC services with the version number served under
+// their plain namespace.
 func (s *RPCService) Modules() map[string]string {
 	modules := make(map[string]string)
-	for name := range s.server.services {
-		modules[name] = "1.0"
+	for name, svc := range s.server.services {
+		if !strings.Contains(name, serviceVersionSeparator) {
+			modules[name] = svc.version
+		}
+	}
+	for alias, name := range s.server.aliases {
+		if _, present := modules[alias]; !present {
+			if svc, ok := s.server.services[name]; ok {
+				modules[alias] = svc.version
+			}
+		}
+	}
+	return modules
+}
+
+// ModuleVersions returns the list of RPC services with all the version numbers
+// registered for them, each of which can be called explicitly by addressing the
+// methods as <namespace>@<version>_<method>.
+func (s *RPCService) ModuleVersions() map[string][]string {
+	modules := make(map[string][]string)
+	for name, svc := range s.server.services {
+		if idx := strings.Index(name, serviceVersionSeparator); idx >= 0 {
+			modules[name[:idx]] = append(modules[name[:idx]], svc.version)
+		}
+	}
+	for alias, name := range s.server.aliases {
+		if _, present := modules[alias]; !present && len(modules[name]) > 0 {
+			modules[alias] = append([]string{}, modules[name]...)
+		}
+	}
+	for _, versions := range modules {
+		sort.Strings(versions)
 	}
 	return modules
 }
 
-// RegisterName will create a service for the given rcvr type under the given name. When no methods on the given rcvr
-// match the criteria to be either a RPC method or a subscription an error is returned. Otherwise a new service is
-// created and added to the service collection this server instance serves.
+// RegisterName will create a service for the given rcvr type under the given name
+// with the default API version. See RegisterVersionedName for details.
 func (s *Server) RegisterName(name string, rcvr interface{}) error {
+	return s.RegisterVersionedName(name, DefaultApiVersion, rcvr)
+}
+
+// RegisterVersionedName will create a service for the given rcvr type under the given name and version. When no
+// methods on the given rcvr match the criteria to be either a RPC method or a subscription an error is returned.
+// Otherwise the methods are added to the service collection this server instance serves.
+//
+// Multiple versions of the same namespace may be registered side by side. Each is reachable by calling its methods
+// as <namespace>@<version>_<method>, whereas the plain <namespace>_<method> form dispatches to the version that
+// was registered first under the namespace. Registering the same namespace and version again merges the methods.
+func (s *Server) RegisterVersionedName(name string, version string, rcvr interface{}) error {
 	if s.services == nil {
 		s.services = make(serviceRegistry)
 	}
-
-	svc := new(service)
-	svc.typ = reflect.TypeOf(rcvr)
+	typ := reflect.TypeOf(rcvr)
 	rcvrVal := reflect.ValueOf(rcvr)
 
 	if name == "" {
-		return fmt.Errorf("no service name for type %s", svc.typ.String())
+		return fmt.Errorf("no service name for type %s", typ.String())
+	}
+	if strings.Contains(name, serviceVersionSeparator) || strings.Contains(version, serviceVersionSeparator) {
+		return fmt.Errorf("service name %q or version %q contains %q", name, version, serviceVersionSeparator)
 	}
 	if !isExported(reflect.Indirect(rcvrVal).Type().Name()) {
 		return fmt.Errorf("%s is not exported", reflect.Indirect(rcvrVal).Type().Name())
 	}
+	if version == "" {
+		version = DefaultApiVersion
+	}
+	methods, subscriptions := suitableCallbacks(rcvrVal, typ)
+	if len(methods) == 0 && len(subscriptions) == 0 {
+		return fmt.Errorf("Service %T doesn't have any suitable methods/subscriptions to expose", rcvr)
+	}
+	// Expose the methods under the explicit version, and under the plain name too
+	// unless another version already occupies it
+	s.mergeService(name+serviceVersionSeparator+version, version, typ, methods, subscriptions)
+	if regsvc, present := s.services[name]; !present || regsvc.version == version {
+		s.mergeService(name, version, typ, methods, subscriptions)
+	}
+	return nil
+}
 
-	// already a previous service register under given sname, merge methods/subscriptions
-	if regsvc, present := s.services[name]; present {
-		methods, subscriptions := suitableCallbacks(rcvrVal, svc.typ)
-		if len(methods) == 0 && len(subscriptions) == 0 {
-			return fmt.Errorf("Service %T doesn't have any suitable methods/subscriptions to expose", rcvr)
-		}
-
-		for _, m := range methods {
-			regsvc.callbacks[formatName(m.method.Name)] = m
-		}
-		for _, s := range subscriptions {
-			regsvc.subscriptions[formatName(s.method.Name)] = s
+// mergeService adds the given methods and subscriptions to the service registered
+// under name, creating it if it doesn't exist yet.
+func (s *Server) mergeService(name string, version string, typ reflect.Type, methods callbacks, subs subscriptions) {
+	svc, present := s.services[name]
+	if !present {
+		svc = &service{
+			name:          name,
+			version:       version,
+			typ:           typ,
+			callbacks:     make(callbacks),
+			subscriptions: make(subscriptions),
 		}
+		s.services[name] = svc
+	}
+	for mname, m := range methods {
+		svc.callbacks[mname] = m
+	}
+	for sname, sub := range subs {
+		svc.subscriptions[sname] = sub
+	}
+}
 
-		return nil
+// RegisterAlias makes the server serve all methods and subscriptions of the name
+// namespace under alias too, including the ones registered after the alias, for
+// all registered versions. Methods registered under the alias itself take
+// precedence over the aliased ones.
+func (s *Server) RegisterAlias(alias string, name string) error {
+	if alias == "" || name == "" {
+		return fmt.Errorf("empty alias %q or aliased name %q", alias, name)
+	}
+	if alias == name {
+		return fmt.Errorf("namespace %q aliased to itself", alias)
+	}
+	if strings.Contains(alias, serviceVersionSeparator) || strings.Contains(name, serviceVersionSeparator) {
+		return fmt.Errorf("alias %q or aliased name %q contains %q", alias, name, serviceVersionSeparator)
+	}
+	if s.aliases == nil {
+		s.aliases = make(map[string]string)
 	}
+	s.aliases[alias] = name
+	return nil
+}
 
-	svc.name = name
-	svc.callbacks, svc.subscriptions = suitableCallbacks(rcvrVal, svc.typ)
+// callback looks up the method or subscription callback serving the given (possibly
+// versioned) namespace, falling back to the aliased namespace if there's an alias.
+func (s *Server) callback(namespace string, method string, subscription bool) (*callback, bool) {
+	names := []string{namespace}
 
-	if len(svc.callbacks) == 0 && len(svc.subscriptions) == 0 {
-		return fmt.Errorf("Service %T doesn't have any suitable methods/subscriptions to expose", rcvr)
+	base, version := namespace, ""
+	if idx := strings.Index(namespace, serviceVersionSeparator); idx >= 0 {
+		base, version = namespace[:idx], namespace[idx:]
 	}
+	if name, ok := s.aliases[base]; ok {
+		names = append(names, name+version)
+	}
+	for _, name := range names {
+		svc, ok := s.services[name]
+		if !ok {
+			continue
+		}
+		callb, ok := svc.callbacks[method]
+		if subscription {
+			callb, ok = svc.subscriptions[method]
+		}
+		if ok {
+			return callb, true
+		}
+	}
+	return nil, false
+}
 
-	s.services[svc.name] = svc
-
-	return nil
+// canonicalName resolves the versioned or aliased namespace a method was called
+// through to the namespace it is served under.
+func (s *Server) canonicalName(namespace string, method string) string {
+	if idx := strings.Index(namespace, serviceVersionSeparator); idx >= 0 {
+		namespace = namespace[:idx]
+	}
+	if name, ok := s.aliases[namespace]; ok {
+		namespace = name
+	}
+	return namespace + serviceMethodSeparator + method
 }
 
 // hasOption returns true if option is included in options, otherwise false
@@ -167,7 +290,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 	// to send notification to clients. It is thight to the codec/connection. If the
 	// connection is closed the notifier will stop and cancels all active subscriptions.
 	if options&OptionSubscriptions == OptionSubscriptions {
-		ctx = context.WithValue(ctx, notifierKey{}, newNotifier(codec))
+		ctx = context.WithValue(ctx, notifierKey{}, newNotifier(codec, s.subLimits))
 	}
 	s.codecsMu.Lock()
 	if atomic.LoadInt32(&s.run) != 1 { // server stopped
@@ -261,6 +384,13 @@ func (s *Server) Stop() {
 
 // createSubscription will call the subscription callback and returns the subscription id or error.
 func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *serverRequest) (ID, error) {
+	// refuse subscriptions beyond the limit of the connection
+	if max := s.subLimits.MaxSubscriptions; max > 0 {
+		if notifier, ok := NotifierFromContext(ctx); ok && notifier.subscriptions() >= max {
+			subscriptionRejectMeter.Mark(1)
+			return "", ErrTooManySubscriptions
+		}
+	}
 	// subscription have as first argument the context following optional arguments
 	args := []reflect.Value{req.callb.rcvr, reflect.ValueOf(ctx)}
 	args = append(args, req.args...)
@@ -273,8 +403,49 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 	return reply[0].Interface().(*Subscription).ID, nil
 }
 
+// CountRequests makes the server tally the requests it handles into the given
+// counter, which is updated atomically and may be shared between servers. It must
+// be called before the server starts serving requests.
+func (s *Server) CountRequests(counter *uint64) {
+	s.requests = counter
+}
+
+// LimitSubscriptions bounds the number of subscriptions and queued notifications
+// of every connection served. It must be called before the server starts serving
+// requests.
+func (s *Server) LimitSubscriptions(limits SubscriptionLimits) {
+	s.subLimits = limits
+}
+
+// CollectStats makes the server record the execution statistics of the methods
+// it serves into the given collection, which may be shared between servers. It
+// must be called before the server starts serving requests.
+func (s *Server) CollectStats(stats *CallStats) {
+	s.stats = stats
+}
+
+// CallAuditor records the execution of method calls for later inspection. Method
+// names are reported in their canonical form, without version or alias.
+type CallAuditor interface {
+	// Audited returns whether calls of the given method need to be recorded.
+	Audited(method string) bool
+
+	// Audit records an executed call of method with the given arguments, along
+	// with the result and error it returned.
+	Audit(method string, args []interface{}, result interface{}, err error)
+}
+
+// AuditCalls makes the server report the calls it executes to the given auditor.
+// It must be called before the server starts serving requests.
+func (s *Server) AuditCalls(auditor CallAuditor) {
+	s.auditor = auditor
+}
+
 // handle executes a request and returns the response from the callback.
 func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+	if s.requests != nil {
+		atomic.AddUint64(s.requests, 1)
+	}
 	if req.err != nil {
 		return codec.CreateErrorResponse(&req.id, req.err), nil
 	}
@@ -305,7 +476,7 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		// active the subscription after the sub id was successfully sent to the client
 		activateSub := func() {
 			notifier, _ := NotifierFromContext(ctx)
-			notifier.activate(subid)
+			notifier.activate(subid, req.svcname)
 		}
 
 		return codec.CreateResponse(req.id, subid), activateSub
@@ -327,18 +498,63 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		arguments = append(arguments, req.args...)
 	}
 
+	name := s.canonicalName(req.svcname, formatName(req.callb.method.Name))
+	audited := s.auditor != nil && s.auditor.Audited(name)
+
+	var args []interface{}
+	if s.shedder != nil || audited {
+		args = make([]interface{}, len(req.args))
+		for i, arg := range req.args {
+			args[i] = arg.Interface()
+		}
+	}
+	if s.shedder != nil {
+		if err := s.shedder.Shed(name, args); err != nil {
+			var rpcErr Error = &callbackError{err.Error()}
+			if ce, ok := err.(Error); ok {
+				rpcErr = ce
+			}
+			if de, ok := err.(DataError); ok {
+				return codec.CreateErrorResponseWithInfo(&req.id, rpcErr, de.ErrorData()), nil
+			}
+			return codec.CreateErrorResponse(&req.id, rpcErr), nil
+		}
+	}
 	// execute RPC method and return result
+	start := time.Now()
 	reply := req.callb.method.Func.Call(arguments)
+	failed := req.callb.errPos >= 0 && !reply[req.callb.errPos].IsNil()
+	if s.stats != nil {
+		s.stats.record(req.svcname+serviceMethodSeparator+formatName(req.callb.method.Name), time.Since(start), failed)
+	}
+	if audited {
+		var (
+			result interface{}
+			err    error
+		)
+		if failed {
+			err = reply[req.callb.errPos].Interface().(error)
+		} else if len(reply) > 0 && req.callb.errPos != 0 {
+			result = reply[0].Interface()
+		}
+		s.auditor.Audit(name, args, result, err)
+	}
 	if len(reply) == 0 {
 		return codec.CreateResponse(req.id, nil), nil
 	}
 
-	if req.callb.errPos >= 0 { // test if method returned an error
-		if !reply[req.callb.errPos].IsNil() {
-			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+	if failed { // method returned an error
+		e := reply[req.callb.errPos].Interface().(error)
+
+		// Callbacks may pick their own error code and attach error data
+		var err Error = &callbackError{e.Error()}
+		if ce, ok := e.(Error); ok {
+			err = ce
+		}
+		if de, ok := e.(DataError); ok {
+			return codec.CreateErrorResponseWithInfo(&req.id, err, de.ErrorData()), nil
 		}
+		return codec.CreateErrorResponse(&req.id, err), nil
 	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
@@ -404,9 +620,6 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 	// verify requests
 	for i, r := range reqs {
-		var ok bool
-		var svc *service
-
 		if r.err != nil {
 			requests[i] = &serverRequest{id: r.id, err: r.err}
 			continue
@@ -423,14 +636,9 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			continue
 		}
 
-		if svc, ok = s.services[r.service]; !ok { // rpc method isn't available
-			requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
-			continue
-		}
-
 		if r.isPubSub { // eth_subscribe, r.method contains the subscription method name
-			if callb, ok := svc.subscriptions[r.method]; ok {
-				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+			if callb, ok := s.callback(r.service, r.method, true); ok {
+				requests[i] = &serverRequest{id: r.id, svcname: r.service, callb: callb}
 				if r.params != nil && len(callb.argTypes) > 0 {
 					argTypes := []reflect.Type{reflect.TypeOf("")}
 					argTypes = append(argTypes, callb.argTypes...)
@@ -441,13 +649,13 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 					}
 				}
 			} else {
-				requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{subscribeMethod, r.method}}
+				requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service + subscribeMethodSuffix, r.method}}
 			}
 			continue
 		}
 
-		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+		if callb, ok := s.callback(r.service, r.method, false); ok { // lookup RPC method
+			requests[i] = &serverRequest{id: r.id, svcname: r.service, callb: callb}
 			if r.params != nil && len(callb.argTypes) > 0 {
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
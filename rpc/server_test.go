@@ -83,8 +83,8 @@ func TestServerRegisterName(t *testing.T) {
 		t.Fatalf("%v", err)
 	}
 
-	if len(server.services) != 2 {
-		t.Fatalf("Expected 2 service entries, got %d", len(server.services))
+	if len(server.services) != 4 {
+		t.Fatalf("Expected 4 service entries, got %d", len(server.services))
 	}
 
 	svc, ok := server.services["calc"]
@@ -101,6 +101,130 @@ func TestServerRegisterName(t *testing.T) {
 	}
 }
 
+type VersionedService struct {
+	Gen int
+}
+
+func (s *VersionedService) Generation() int {
+	return s.Gen
+}
+
+// Tests that multiple versions of a namespace can be served side by side, and
+// that the metadata service reports them.
+func TestServerRegisterVersionedName(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterVersionedName("calc", "1.0", &VersionedService{1}); err != nil {
+		t.Fatalf("failed to register version 1.0: %v", err)
+	}
+	if err := server.RegisterVersionedName("calc", "2.0", &VersionedService{2}); err != nil {
+		t.Fatalf("failed to register version 2.0: %v", err)
+	}
+	if err := server.RegisterVersionedName("calc@3.0", "3.0", &VersionedService{3}); err == nil {
+		t.Fatalf("registered service with version separator in its name")
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	for method, want := range map[string]int{
+		"calc_generation":     1,
+		"calc@1.0_generation": 1,
+		"calc@2.0_generation": 2,
+	} {
+		var have int
+		if err := client.Call(&have, method); err != nil {
+			t.Fatalf("%s: call failed: %v", method, err)
+		}
+		if have != want {
+			t.Errorf("%s: generation mismatch: have %d, want %d", method, have, want)
+		}
+	}
+	if err := client.Call(nil, "calc@3.0_generation"); err == nil {
+		t.Errorf("call to unregistered version succeeded")
+	}
+	modules, err := client.SupportedModules()
+	if err != nil {
+		t.Fatalf("failed to retrieve modules: %v", err)
+	}
+	if want := map[string]string{"rpc": "1.0", "calc": "1.0"}; !reflect.DeepEqual(modules, want) {
+		t.Errorf("modules mismatch: have %v, want %v", modules, want)
+	}
+	versions, err := client.SupportedModuleVersions()
+	if err != nil {
+		t.Fatalf("failed to retrieve module versions: %v", err)
+	}
+	if want := map[string][]string{"rpc": {"1.0"}, "calc": {"1.0", "2.0"}}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("module versions mismatch: have %v, want %v", versions, want)
+	}
+}
+
+// Tests that aliased namespaces serve every method and subscription of the
+// namespace they alias, including ones registered after the alias.
+func TestServerAliases(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("eth", new(Service)); err != nil {
+		t.Fatalf("failed to register eth: %v", err)
+	}
+	if err := server.RegisterVersionedName("eth", "2.0", &VersionedService{2}); err != nil {
+		t.Fatalf("failed to register eth 2.0: %v", err)
+	}
+	if err := server.RegisterName("exp", &VersionedService{1}); err != nil {
+		t.Fatalf("failed to register exp: %v", err)
+	}
+	if err := server.RegisterAlias("eth", "eth"); err == nil {
+		t.Fatalf("namespace aliased to itself")
+	}
+	// Every eth method and subscription must also be served as exp
+	for name, want := range server.services["eth"].callbacks {
+		if have, ok := server.callback("exp", name, false); !ok || have != want {
+			t.Errorf("method %s: alias mismatch: have %v, want %v", name, have, want)
+		}
+	}
+	for name, want := range server.services["eth"].subscriptions {
+		if have, ok := server.callback("exp", name, true); !ok || have != want {
+			t.Errorf("subscription %s: alias mismatch: have %v, want %v", name, have, want)
+		}
+	}
+	// Methods added later must be picked up, the alias' own taking precedence
+	if err := server.RegisterName("eth", &VersionedService{3}); err != nil {
+		t.Fatalf("failed to extend eth: %v", err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	for method, want := range map[string]int{
+		"eth_generation":     3,
+		"exp_generation":     1,
+		"exp@2.0_generation": 2,
+	} {
+		var have int
+		if err := client.Call(&have, method); err != nil {
+			t.Fatalf("%s: call failed: %v", method, err)
+		}
+		if have != want {
+			t.Errorf("%s: generation mismatch: have %d, want %d", method, have, want)
+		}
+	}
+	var echo Result
+	if err := client.Call(&echo, "exp_echo", "hello", 1, &Args{"world"}); err != nil {
+		t.Fatalf("aliased call failed: %v", err)
+	}
+	if echo.String != "hello" || echo.Int != 1 || echo.Args.S != "world" {
+		t.Errorf("aliased call result mismatch: %+v", echo)
+	}
+	// Aliases without own services should be reported with the versions of their target
+	server = NewServer()
+	if err := server.RegisterVersionedName("eth", "2.0", &VersionedService{2}); err != nil {
+		t.Fatalf("failed to register eth 2.0: %v", err)
+	}
+	meta := &RPCService{server}
+	if version := meta.Modules()["exp"]; version != "2.0" {
+		t.Errorf("exp module version mismatch: have %q, want %q", version, "2.0")
+	}
+	if versions, want := meta.ModuleVersions()["exp"], []string{"2.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("exp module versions mismatch: have %v, want %v", versions, want)
+	}
+}
+
 func testServerMethodExecution(t *testing.T, method string) {
 	server := NewServer()
 	service := new(Service)
@@ -160,3 +284,143 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+type DataErrorService struct{}
+
+type testDataError struct{}
+
+func (testDataError) Error() string          { return "custom error" }
+func (testDataError) ErrorCode() int         { return 3 }
+func (testDataError) ErrorData() interface{} { return "custom data" }
+
+func (s *DataErrorService) Fail() error {
+	return testDataError{}
+}
+
+// Tests that callback errors can set their own error code and attach data to
+// the error response.
+func TestServerErrorData(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(DataErrorService)); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	err := client.Call(nil, "test_fail")
+	if err == nil {
+		t.Fatal("call succeeded")
+	}
+	if have := err.Error(); have != "custom error" {
+		t.Errorf("message mismatch: have %q, want %q", have, "custom error")
+	}
+	if have := err.(Error).ErrorCode(); have != 3 {
+		t.Errorf("code mismatch: have %d, want %d", have, 3)
+	}
+	if have := err.(DataError).ErrorData(); have != "custom data" {
+		t.Errorf("data mismatch: have %v, want %v", have, "custom data")
+	}
+}
+
+type ShedService struct{}
+
+func (s *ShedService) Cheap() int          { return 1 }
+func (s *ShedService) Expensive(n int) int { return n }
+
+// testShedder rejects the calls of the expensive method, recording the name and
+// arguments of all the calls consulted about.
+type testShedder struct {
+	calls []string
+	args  [][]interface{}
+}
+
+func (s *testShedder) Shed(method string, args []interface{}) error {
+	s.calls = append(s.calls, method)
+	s.args = append(s.args, args)
+	if method == "test_expensive" {
+		return &OverloadedError{Reason: "testing", RetryAfter: 3 * time.Second}
+	}
+	return nil
+}
+
+// Tests that the load shedder is consulted with canonical method names before
+// executing calls, and that shed calls are rejected with a retriable error.
+func TestServerShedLoad(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(ShedService)); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := server.RegisterAlias("alias", "test"); err != nil {
+		t.Fatalf("failed to register alias: %v", err)
+	}
+	shedder := new(testShedder)
+	server.ShedLoad(shedder)
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	var result int
+	if err := client.Call(&result, "alias_cheap"); err != nil || result != 1 {
+		t.Fatalf("cheap call failed: result %d, error %v", result, err)
+	}
+	err := client.Call(&result, "test@1.0_expensive", 7)
+	if err == nil {
+		t.Fatal("expensive call succeeded")
+	}
+	if have := err.(Error).ErrorCode(); have != -32005 {
+		t.Errorf("code mismatch: have %d, want %d", have, -32005)
+	}
+	data, ok := err.(DataError).ErrorData().(map[string]interface{})
+	if !ok || data["retriable"] != true || data["retryAfter"] != float64(3) {
+		t.Errorf("data mismatch: have %v", err.(DataError).ErrorData())
+	}
+	if want := []string{"test_cheap", "test_expensive"}; !reflect.DeepEqual(shedder.calls, want) {
+		t.Errorf("consulted calls mismatch: have %v, want %v", shedder.calls, want)
+	}
+	if want := []interface{}{7}; !reflect.DeepEqual(shedder.args[1], want) {
+		t.Errorf("consulted arguments mismatch: have %v, want %v", shedder.args[1], want)
+	}
+}
+
+// testAuditor records the calls of the expensive method.
+type testAuditor struct {
+	calls   []string
+	args    [][]interface{}
+	results []interface{}
+}
+
+func (a *testAuditor) Audited(method string) bool { return method == "test_expensive" }
+
+func (a *testAuditor) Audit(method string, args []interface{}, result interface{}, err error) {
+	a.calls = append(a.calls, method)
+	a.args = append(a.args, args)
+	a.results = append(a.results, result)
+}
+
+// Tests that the auditor is only notified of the calls it selects, along with
+// their arguments and results.
+func TestServerAuditCalls(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(ShedService)); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	auditor := new(testAuditor)
+	server.AuditCalls(auditor)
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	var result int
+	if err := client.Call(&result, "test_cheap"); err != nil {
+		t.Fatalf("cheap call failed: %v", err)
+	}
+	if err := client.Call(&result, "test_expensive", 7); err != nil {
+		t.Fatalf("expensive call failed: %v", err)
+	}
+	if want := []string{"test_expensive"}; !reflect.DeepEqual(auditor.calls, want) {
+		t.Fatalf("audited calls mismatch: have %v, want %v", auditor.calls, want)
+	}
+	if !reflect.DeepEqual(auditor.args[0], []interface{}{7}) || auditor.results[0] != 7 {
+		t.Errorf("audited call mismatch: args %v, result %v", auditor.args[0], auditor.results[0])
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoadShedder decides whether a method call should be rejected instead of being
+// executed, keeping the server responsive while the backing node is busy. Method
+// names are reported in their canonical form, without version or alias.
+type LoadShedder interface {
+	// Shed returns a non-nil error if the call of method with the given arguments
+	// must be rejected. The error is sent to the caller as is, so it should usually
+	// be an *OverloadedError.
+	Shed(method string, args []interface{}) error
+}
+
+// BlockRanger may be implemented by method arguments spanning a range of blocks,
+// allowing load shedders to tell cheap and expensive calls apart.
+type BlockRanger interface {
+	BlockRange() (from, to BlockNumber)
+}
+
+// OverloadedError is returned for calls rejected while the server is shedding
+// load. Such calls did not execute and are safe to retry later.
+type OverloadedError struct {
+	Reason     string        // Condition the server is shedding load due to
+	RetryAfter time.Duration // Suggested time to wait before retrying the call
+}
+
+func (e *OverloadedError) ErrorCode() int { return -32005 }
+
+func (e *OverloadedError) Error() string {
+	return fmt.Sprintf("server overloaded (%s), retry later", e.Reason)
+}
+
+// ErrorData implements DataError, flagging the error as retriable for clients.
+func (e *OverloadedError) ErrorData() interface{} {
+	return map[string]interface{}{
+		"retriable":  true,
+		"retryAfter": int(e.RetryAfter / time.Second),
+	}
+}
+
+// ShedLoad makes the server consult the given load shedder before executing a
+// method call. It must be called before the server starts serving requests.
+func (s *Server) ShedLoad(shedder LoadShedder) {
+	s.shedder = shedder
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/expanse-org/go-expanse/log"
+	"github.com/expanse-org/go-expanse/metrics"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// latencyBuckets are the upper bounds of the buckets of the call latency
+// histograms, calls taking longer than the last one falling into an extra bucket.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// MethodStats is the execution statistics of a single RPC method.
+type MethodStats struct {
+	Calls     uint64            `json:"calls"`     // Number of calls executed
+	Errors    uint64            `json:"errors"`    // Number of calls returning an error
+	ErrorRate float64           `json:"errorRate"` // Fraction of the calls returning an error
+	Mean      string            `json:"mean"`      // Average execution time of the calls
+	Max       string            `json:"max"`       // Longest execution time of a call
+	Latency   map[string]uint64 `json:"latency"`   // Number of calls by execution time, keyed by bucket upper bound
+}
+
+// methodStats tracks the execution of a single RPC method.
+type methodStats struct {
+	calls   uint64
+	errors  uint64
+	total   time.Duration
+	max     time.Duration
+	buckets []uint64
+
+	timer gometrics.Timer // Call latencies reported to the metrics system
+	meter gometrics.Meter // Call failures reported to the metrics system
+}
+
+// CallStats collects the execution statistics of the RPC methods served by one
+// or more servers, keyed by the namespace and name the methods were called by.
+// Calls running longer than the slow threshold are logged.
+type CallStats struct {
+	registry gometrics.Registry // Registry the statistics are also reported into
+	slow     time.Duration      // Execution time above which calls are logged (0 = never)
+
+	methods map[string]*methodStats
+	lock    sync.Mutex
+}
+
+// NewCallStats creates an empty collection of RPC call statistics, reporting
+// them into the given metrics registry as well (nil = default registry).
+func NewCallStats(registry gometrics.Registry, slow time.Duration) *CallStats {
+	return &CallStats{
+		registry: registry,
+		slow:     slow,
+		methods:  make(map[string]*methodStats),
+	}
+}
+
+// record accounts for a single execution of the given method.
+func (s *CallStats) record(method string, elapsed time.Duration, failed bool) {
+	if s.slow > 0 && elapsed > s.slow {
+		log.Warn("Slow RPC call", "method", method, "elapsed", elapsed, "failed", failed)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := s.methods[method]
+	if stats == nil {
+		stats = &methodStats{
+			buckets: make([]uint64, len(latencyBuckets)+1),
+			timer:   metrics.NewRegisteredTimer("rpc/duration/"+method, s.registry),
+			meter:   metrics.NewRegisteredMeter("rpc/failure/"+method, s.registry),
+		}
+		s.methods[method] = stats
+	}
+	stats.calls++
+	stats.total += elapsed
+	if elapsed > stats.max {
+		stats.max = elapsed
+	}
+	bucket := 0
+	for bucket < len(latencyBuckets) && elapsed > latencyBuckets[bucket] {
+		bucket++
+	}
+	stats.buckets[bucket]++
+	stats.timer.Update(elapsed)
+
+	if failed {
+		stats.errors++
+		stats.meter.Mark(1)
+	}
+}
+
+// Stats returns the execution statistics of every method called so far.
+func (s *CallStats) Stats() map[string]*MethodStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	all := make(map[string]*MethodStats, len(s.methods))
+	for method, stats := range s.methods {
+		latency := make(map[string]uint64, len(stats.buckets))
+		for i, count := range stats.buckets {
+			if i < len(latencyBuckets) {
+				latency[latencyBuckets[i].String()] = count
+			} else {
+				latency["+Inf"] = count
+			}
+		}
+		all[method] = &MethodStats{
+			Calls:     stats.calls,
+			Errors:    stats.errors,
+			ErrorRate: float64(stats.errors) / float64(stats.calls),
+			Mean:      (stats.total / time.Duration(stats.calls)).String(),
+			Max:       stats.max.String(),
+			Latency:   latency,
+		}
+	}
+	return all
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type StatsTestService struct{}
+
+func (s *StatsTestService) Check(n int) (int, error) {
+	if n < 0 {
+		return 0, errors.New("negative")
+	}
+	return n, nil
+}
+
+func (s *StatsTestService) Sleep(duration time.Duration) {
+	time.Sleep(duration)
+}
+
+// Tests that the servers sharing a statistics collection record the calls, the
+// failures and the latencies of the methods they serve.
+func TestCallStats(t *testing.T) {
+	stats := NewCallStats(nil, 0)
+
+	for i := 0; i < 2; i++ {
+		server := newTestServer("test", new(StatsTestService))
+		server.CollectStats(stats)
+		client := DialInProc(server)
+
+		var result int
+		if err := client.Call(&result, "test_check", 1); err != nil {
+			t.Fatalf("server %d: call failed: %v", i, err)
+		}
+		if err := client.Call(&result, "test_check", -1); err == nil {
+			t.Fatalf("server %d: failing call succeeded", i)
+		}
+		if err := client.Call(nil, "test_sleep", 20*time.Millisecond); err != nil {
+			t.Fatalf("server %d: call failed: %v", i, err)
+		}
+		// Calls rejected before execution aren't recorded
+		if err := client.Call(nil, "test_sleep"); err == nil {
+			t.Fatalf("server %d: call without arguments succeeded", i)
+		}
+		client.Close()
+		server.Stop()
+	}
+	all := stats.Stats()
+	if len(all) != 2 {
+		t.Fatalf("method count mismatch: have %d, want 2", len(all))
+	}
+	check := all["test_check"]
+	if check == nil || check.Calls != 4 || check.Errors != 2 || check.ErrorRate != 0.5 {
+		t.Errorf("check stats mismatch: have %+v, want 4 calls, 2 errors", check)
+	}
+	sleep := all["test_sleep"]
+	if sleep == nil || sleep.Calls != 2 || sleep.Errors != 0 {
+		t.Fatalf("sleep stats mismatch: have %+v, want 2 calls, no errors", sleep)
+	}
+	if sleep.Latency["100ms"] != 2 {
+		t.Errorf("sleep latency mismatch: have %v, want 2 calls within 10ms-100ms", sleep.Latency)
+	}
+	if max, _ := time.ParseDuration(sleep.Max); max < 20*time.Millisecond {
+		t.Errorf("sleep max latency too low: %v", sleep.Max)
+	}
+}
@@ -27,16 +27,38 @@ var (
 	ErrNotificationsUnsupported = errors.New("notifications not supported")
 	// ErrNotificationNotFound is returned when the notification for the given id is not found
 	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrTooManySubscriptions is returned when a connection exceeds its subscription limit
+	ErrTooManySubscriptions = errors.New("too many subscriptions")
+	// ErrNotificationBufferFull is returned when the client doesn't keep up with its notifications
+	ErrNotificationBufferFull = errors.New("notification buffer full")
 )
 
+// SubscriptionLimits bounds the resources the subscriptions of a single connection
+// may use, protecting the server from clients subscribing to everything or not
+// reading their notifications.
+type SubscriptionLimits struct {
+	MaxSubscriptions int  // Maximum number of concurrent subscriptions (0 = unlimited)
+	BufferSize       int  // Maximum number of notifications queued for sending (0 = default)
+	DropOldest       bool // Whether to drop the oldest queued notification on overflow instead of disconnecting
+}
+
+// bufferSize returns the notification buffer size, falling back to the default.
+func (l SubscriptionLimits) bufferSize() int {
+	if l.BufferSize > 0 {
+		return l.BufferSize
+	}
+	return notificationBufferSize
+}
+
 // ID defines a pseudo random number that is used to identify RPC subscriptions.
 type ID string
 
 // a Subscription is created by a notifier and tight to that notifier. The client can use
 // this subscription to wait for an unsubscribe request for the client, see Err().
 type Subscription struct {
-	ID  ID
-	err chan error // closed on unsubscribe
+	ID        ID
+	namespace string     // namespace the client subscribed through, set on activation
+	err       chan error // closed on unsubscribe
 }
 
 // Err returns a channel that is closed when the client send an unsubscribe request.
@@ -48,23 +70,34 @@ func (s *Subscription) Err() <-chan error {
 type notifierKey struct{}
 
 // Notifier is tight to a RPC connection that supports subscriptions.
-// Server callbacks use the notifier to send notifications.
+// Server callbacks use the notifier to send notifications, which are queued and
+// written to the connection in the background so a slow client doesn't block the
+// callbacks.
 type Notifier struct {
 	codec    ServerCodec
+	limits   SubscriptionLimits
 	subMu    sync.RWMutex // guards active and inactive maps
 	stopped  bool
 	active   map[ID]*Subscription
 	inactive map[ID]*Subscription
+
+	queueMu sync.Mutex
+	queue   []interface{} // notifications waiting to be written
+	wake    chan struct{} // signals the sender about queued notifications
 }
 
 // newNotifier creates a new notifier that can be used to send subscription
 // notifications to the client.
-func newNotifier(codec ServerCodec) *Notifier {
-	return &Notifier{
+func newNotifier(codec ServerCodec, limits SubscriptionLimits) *Notifier {
+	n := &Notifier{
 		codec:    codec,
+		limits:   limits,
 		active:   make(map[ID]*Subscription),
 		inactive: make(map[ID]*Subscription),
+		wake:     make(chan struct{}, 1),
 	}
+	go n.loop()
+	return n
 }
 
 // NotifierFromContext returns the Notifier value stored in ctx, if any.
@@ -78,30 +111,83 @@ func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
 // are dropped until the subscription is marked as active. This is done
 // by the RPC server after the subscription ID is send to the client.
 func (n *Notifier) CreateSubscription() *Subscription {
-	s := &Subscription{NewID(), make(chan error)}
+	s := &Subscription{ID: NewID(), namespace: pubsubNamespace, err: make(chan error)}
 	n.subMu.Lock()
 	n.inactive[s.ID] = s
 	n.subMu.Unlock()
 	return s
 }
 
-// Notify sends a notification to the client with the given data as payload.
-// If an error occurs the RPC connection is closed and the error is returned.
+// Notify queues a notification to the client with the given data as payload. If
+// the queue of the connection is full, either the oldest notification is dropped
+// or the RPC connection is closed and an error returned, depending on the limits.
 func (n *Notifier) Notify(id ID, data interface{}) error {
 	n.subMu.RLock()
-	defer n.subMu.RUnlock()
+	sub, active := n.active[id]
+	n.subMu.RUnlock()
+
+	if !active {
+		return nil
+	}
+	notification := n.codec.CreateNotification(string(id), sub.namespace, data)
+
+	n.queueMu.Lock()
+	defer n.queueMu.Unlock()
 
-	_, active := n.active[id]
-	if active {
-		notification := n.codec.CreateNotification(string(id), data)
-		if err := n.codec.Write(notification); err != nil {
+	if len(n.queue) >= n.limits.bufferSize() {
+		if !n.limits.DropOldest {
+			notificationDisconnectMeter.Mark(1)
 			n.codec.Close()
-			return err
+			return ErrNotificationBufferFull
 		}
+		notificationDropMeter.Mark(1)
+		n.queue[0] = nil
+		n.queue = n.queue[1:]
+	}
+	n.queue = append(n.queue, notification)
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
 	}
 	return nil
 }
 
+// loop writes the queued notifications to the connection until it's closed.
+func (n *Notifier) loop() {
+	for {
+		select {
+		case <-n.wake:
+		case <-n.codec.Closed():
+			return
+		}
+		for {
+			n.queueMu.Lock()
+			if len(n.queue) == 0 {
+				n.queueMu.Unlock()
+				break
+			}
+			notification := n.queue[0]
+			n.queue[0] = nil
+			n.queue = n.queue[1:]
+			n.queueMu.Unlock()
+
+			if err := n.codec.Write(notification); err != nil {
+				n.codec.Close()
+				return
+			}
+		}
+	}
+}
+
+// subscriptions returns the number of subscriptions of the connection, whether
+// already activated or not.
+func (n *Notifier) subscriptions() int {
+	n.subMu.RLock()
+	defer n.subMu.RUnlock()
+	return len(n.active) + len(n.inactive)
+}
+
 // Closed returns a channel that is closed when the RPC connection is closed.
 func (n *Notifier) Closed() <-chan interface{} {
 	return n.codec.Closed()
@@ -123,11 +209,13 @@ func (n *Notifier) unsubscribe(id ID) error {
 // activate enables a subscription. Until a subscription is enabled all
 // notifications are dropped. This method is called by the RPC server after
 // the subscription ID was sent to client. This prevents notifications being
-// send to the client before the subscription ID is send to the client.
-func (n *Notifier) activate(id ID) {
+// send to the client before the subscription ID is send to the client. Notifications
+// are sent as methods of the given namespace.
+func (n *Notifier) activate(id ID, namespace string) {
 	n.subMu.Lock()
 	defer n.subMu.Unlock()
 	if sub, found := n.inactive[id]; found {
+		sub.namespace = namespace
 		n.active[id] = sub
 		delete(n.inactive, id)
 	}
@@ -19,6 +19,7 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"sync"
 	"testing"
@@ -162,3 +163,232 @@ func TestNotifications(t *testing.T) {
 		t.Error("unsubscribe callback not called after closing connection")
 	}
 }
+
+type AliasNotificationService struct {
+	stop chan struct{}
+}
+
+// Ticks keeps notifying until unsubscribed, as notifications sent before the
+// subscription is activated are dropped.
+func (s *AliasNotificationService) Ticks(ctx context.Context) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	subscription := notifier.CreateSubscription()
+
+	go func() {
+		for i := 0; ; i++ {
+			if err := notifier.Notify(subscription.ID, i); err != nil {
+				return
+			}
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-subscription.Err():
+				return
+			case <-notifier.Closed():
+				return
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return subscription, nil
+}
+
+// Tests that subscriptions can be made through an alias of the eth namespace,
+// and that notifications are delivered under the aliased namespace.
+func TestAliasNotifications(t *testing.T) {
+	service := &AliasNotificationService{stop: make(chan struct{})}
+	defer close(service.stop)
+
+	server := NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatalf("unable to register test service %v", err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+
+	out := json.NewEncoder(clientConn)
+	in := json.NewDecoder(clientConn)
+
+	type message struct {
+		Id     int              `json:"id"`
+		Method string           `json:"method"`
+		Result json.RawMessage  `json:"result"`
+		Params jsonSubscription `json:"params"`
+	}
+	for i, namespace := range []string{"exp", "eth"} {
+		request := map[string]interface{}{
+			"id":      i + 1,
+			"method":  namespace + "_subscribe",
+			"version": "2.0",
+			"params":  []interface{}{"ticks"},
+		}
+		if err := out.Encode(request); err != nil {
+			t.Fatal(err)
+		}
+		// Skip any leftovers of earlier subscriptions, then check the method name
+		var subid string
+		for {
+			var msg message
+			if err := in.Decode(&msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Id == i+1 {
+				if err := json.Unmarshal(msg.Result, &subid); err != nil {
+					t.Fatalf("%s: invalid subscription id: %v", namespace, err)
+				}
+				continue
+			}
+			if subid == "" || msg.Params.Subscription != subid {
+				continue
+			}
+			if want := namespace + "_subscription"; msg.Method != want {
+				t.Errorf("%s: notification method mismatch: have %s, want %s", namespace, msg.Method, want)
+			}
+			break
+		}
+		request = map[string]interface{}{
+			"id":      100 + i,
+			"method":  namespace + "_unsubscribe",
+			"version": "2.0",
+			"params":  []interface{}{subid},
+		}
+		if err := out.Encode(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type LimitTestService struct{}
+
+func (s *LimitTestService) Idle(ctx context.Context) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	return notifier.CreateSubscription(), nil
+}
+
+// Tests that connections can't create more subscriptions than their limit.
+func TestSubscriptionLimit(t *testing.T) {
+	server := NewServer()
+	server.LimitSubscriptions(SubscriptionLimits{MaxSubscriptions: 2})
+	if err := server.RegisterName("eth", new(LimitTestService)); err != nil {
+		t.Fatalf("unable to register test service %v", err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+
+	out := json.NewEncoder(clientConn)
+	in := json.NewDecoder(clientConn)
+	for i := 0; i < 3; i++ {
+		request := map[string]interface{}{
+			"id":      i,
+			"method":  "eth_subscribe",
+			"version": "2.0",
+			"params":  []interface{}{"idle"},
+		}
+		if err := out.Encode(request); err != nil {
+			t.Fatal(err)
+		}
+		var response jsonErrResponse
+		if err := in.Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if i < 2 && response.Error.Message != "" {
+			t.Errorf("subscription %d: unexpected error: %s", i, response.Error.Message)
+		}
+		if i == 2 && response.Error.Message != ErrTooManySubscriptions.Error() {
+			t.Errorf("subscription %d: error mismatch: have %q, want %q", i, response.Error.Message, ErrTooManySubscriptions)
+		}
+	}
+}
+
+// blockingCodec is a server codec which blocks writes until they are released,
+// recording the written messages.
+type blockingCodec struct {
+	ServerCodec
+
+	writing chan interface{} // receives the messages as their writes start
+	release chan struct{}    // releases a single blocked write
+	closed  chan interface{}
+	once    sync.Once
+}
+
+func newBlockingCodec() *blockingCodec {
+	return &blockingCodec{
+		writing: make(chan interface{}, 100),
+		release: make(chan struct{}),
+		closed:  make(chan interface{}),
+	}
+}
+
+func (c *blockingCodec) CreateNotification(id, namespace string, data interface{}) interface{} {
+	return data
+}
+
+func (c *blockingCodec) Write(msg interface{}) error {
+	c.writing <- msg
+	select {
+	case <-c.release:
+		return nil
+	case <-c.closed:
+		return errors.New("closed")
+	}
+}
+
+func (c *blockingCodec) Close()                     { c.once.Do(func() { close(c.closed) }) }
+func (c *blockingCodec) Closed() <-chan interface{} { return c.closed }
+
+// Tests that notifications queue up behind a slow client up to the buffer limit,
+// beyond which the oldest ones are dropped or the connection is closed.
+func TestNotificationBufferLimit(t *testing.T) {
+	for _, dropOldest := range []bool{true, false} {
+		codec := newBlockingCodec()
+		notifier := newNotifier(codec, SubscriptionLimits{BufferSize: 2, DropOldest: dropOldest})
+		sub := notifier.CreateSubscription()
+		notifier.activate(sub.ID, "eth")
+
+		// Block the sender on the first notification, then overflow the queue
+		if err := notifier.Notify(sub.ID, 0); err != nil {
+			t.Fatalf("drop %v: notification failed: %v", dropOldest, err)
+		}
+		if msg := <-codec.writing; msg != 0 {
+			t.Fatalf("drop %v: first write mismatch: have %v, want 0", dropOldest, msg)
+		}
+		for i := 1; i <= 2; i++ {
+			if err := notifier.Notify(sub.ID, i); err != nil {
+				t.Fatalf("drop %v: notification %d failed: %v", dropOldest, i, err)
+			}
+		}
+		err := notifier.Notify(sub.ID, 3)
+		if !dropOldest {
+			if err != ErrNotificationBufferFull {
+				t.Errorf("overflow error mismatch: have %v, want %v", err, ErrNotificationBufferFull)
+			}
+			select {
+			case <-codec.Closed():
+			case <-time.After(time.Second):
+				t.Errorf("connection not closed on overflow")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("overflowing notification failed: %v", err)
+		}
+		// Release the writes and check the oldest queued notification was dropped
+		for _, want := range []int{2, 3} {
+			codec.release <- struct{}{}
+			if msg := <-codec.writing; msg != want {
+				t.Errorf("write mismatch: have %v, want %d", msg, want)
+			}
+		}
+		codec.Close()
+	}
+}
@@ -17,6 +17,8 @@
 package rpc
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -24,6 +26,8 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -48,6 +52,7 @@ type callback struct {
 // service represents a registered object
 type service struct {
 	name          string        // name for service
+	version       string        // api version of the service
 	rcvr          reflect.Value // receiver of methods for the service
 	typ           reflect.Type  // receiver type
 	callbacks     callbacks     // registered handlers
@@ -73,12 +78,20 @@ type subscriptionRegistry map[string]*callback // collection of subscription cal
 // Server represents a RPC server
 type Server struct {
 	services       serviceRegistry
-	muSubcriptions sync.Mutex // protects subscriptions
+	aliases        map[string]string // namespaces served as aliases of others
+	muSubcriptions sync.Mutex        // protects subscriptions
 	subscriptions  subscriptionRegistry
 
 	run      int32
 	codecsMu sync.Mutex
 	codecs   *set.Set
+
+	requests *uint64    // Counter of handled requests, accessed atomically (nil = not counted)
+	stats    *CallStats // Execution statistics of the called methods (nil = not collected)
+
+	subLimits SubscriptionLimits // Resource limits of the subscriptions of each connection
+	shedder   LoadShedder        // Policy rejecting calls while the node is busy (nil = never)
+	auditor   CallAuditor        // Recorder of the privileged calls executed (nil = not audited)
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -97,6 +110,13 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError may be implemented by errors returned from callbacks to have
+// additional data included in the data field of the error response.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
@@ -112,7 +132,7 @@ type ServerCodec interface {
 	// Assemble error response with extra information about the error through info
 	CreateErrorResponseWithInfo(id interface{}, err Error, info interface{}) interface{}
 	// Create notification response
-	CreateNotification(string, interface{}) interface{}
+	CreateNotification(string, string, interface{}) interface{}
 	// Write msg to client.
 	Write(interface{}) error
 	// Close underlying data stream
@@ -122,8 +142,6 @@ type ServerCodec interface {
 }
 
 var (
-	pendingBlockNumber  = big.NewInt(-2)
-	latestBlockNumber   = big.NewInt(-1)
 	earliestBlockNumber = big.NewInt(0)
 	maxBlockNumber      = big.NewInt(math.MaxInt64)
 )
@@ -131,59 +149,115 @@ var (
 type BlockNumber int64
 
 const (
-	PendingBlockNumber = BlockNumber(-2)
-	LatestBlockNumber  = BlockNumber(-1)
+	SafeBlockNumber      = BlockNumber(-4)
+	FinalizedBlockNumber = BlockNumber(-3)
+	PendingBlockNumber   = BlockNumber(-2)
+	LatestBlockNumber    = BlockNumber(-1)
 )
 
-// UnmarshalJSON parses the given JSON fragment into a BlockNumber. It supports:
-// - "latest", "earliest" or "pending" as string arguments
-// - the block number
-// Returned errors:
-// - an invalid block number error when the given argument isn't a known strings
-// - an out of range error when the given block number is either too little or too large
+// blockNumberTags maps the supported block tags to their block numbers.
+var blockNumberTags = map[string]BlockNumber{
+	"earliest":  BlockNumber(earliestBlockNumber.Int64()),
+	"latest":    LatestBlockNumber,
+	"pending":   PendingBlockNumber,
+	"safe":      SafeBlockNumber,
+	"finalized": FinalizedBlockNumber,
+}
+
+// UnmarshalJSON parses the given JSON fragment into a BlockNumber. It supports
+// the "earliest", "latest", "pending", "safe" and "finalized" tags and the block
+// number as a hex encoded quantity. A hexutil syntax error is returned for any
+// other argument, e.g. decimal, empty or zero padded numbers, and an out of
+// range error for too large block numbers.
 func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 	input := strings.TrimSpace(string(data))
-
 	if len(input) >= 2 && input[0] == '"' && input[len(input)-1] == '"' {
 		input = input[1 : len(input)-1]
 	}
-
-	if len(input) == 0 {
-		*bn = BlockNumber(latestBlockNumber.Int64())
+	if tag, ok := blockNumberTags[input]; ok {
+		*bn = tag
 		return nil
 	}
+	number, err := hexutil.DecodeUint64(input)
+	if err != nil {
+		return fmt.Errorf("invalid blocknumber %s: %v", data, err)
+	}
+	if number > uint64(maxBlockNumber.Int64()) {
+		return fmt.Errorf("blocknumber not in range [%d, %d]", earliestBlockNumber, maxBlockNumber)
+	}
+	*bn = BlockNumber(number)
+	return nil
+}
 
-	in := new(big.Int)
-	_, ok := in.SetString(input, 0)
+func (bn BlockNumber) Int64() int64 {
+	return (int64)(bn)
+}
 
-	if !ok { // test if user supplied string tag
-		strBlockNumber := input
-		if strBlockNumber == "latest" {
-			*bn = BlockNumber(latestBlockNumber.Int64())
-			return nil
-		}
+// BlockNumberOrHash identifies a block either by number, including the block
+// tags, or by hash. Hash based queries may additionally require the block to be
+// part of the canonical chain.
+type BlockNumberOrHash struct {
+	BlockNumber      *BlockNumber `json:"blockNumber,omitempty"`
+	BlockHash        *common.Hash `json:"blockHash,omitempty"`
+	RequireCanonical bool         `json:"requireCanonical,omitempty"`
+}
 
-		if strBlockNumber == "earliest" {
-			*bn = BlockNumber(earliestBlockNumber.Int64())
-			return nil
-		}
+// BlockNumberOrHashWithNumber creates a block identifier of the given number.
+func BlockNumberOrHashWithNumber(number BlockNumber) BlockNumberOrHash {
+	return BlockNumberOrHash{BlockNumber: &number}
+}
 
-		if strBlockNumber == "pending" {
-			*bn = BlockNumber(pendingBlockNumber.Int64())
-			return nil
-		}
+// BlockNumberOrHashWithHash creates a block identifier of the given hash.
+func BlockNumberOrHashWithHash(hash common.Hash, canonical bool) BlockNumberOrHash {
+	return BlockNumberOrHash{BlockHash: &hash, RequireCanonical: canonical}
+}
 
-		return fmt.Errorf(`invalid blocknumber %s`, data)
-	}
+// UnmarshalJSON parses the given JSON fragment into a BlockNumberOrHash. Besides
+// the block numbers and tags supported by BlockNumber, it accepts a block hash or
+// an object with either a "blockNumber" or a "blockHash" and "requireCanonical".
+func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
+	type plain BlockNumberOrHash
 
-	if in.Cmp(earliestBlockNumber) >= 0 && in.Cmp(maxBlockNumber) <= 0 {
-		*bn = BlockNumber(in.Int64())
+	var obj plain
+	if err := json.Unmarshal(data, &obj); err == nil {
+		if (obj.BlockNumber == nil) == (obj.BlockHash == nil) {
+			return errors.New("exactly one of blockNumber and blockHash must be specified")
+		}
+		if obj.BlockNumber != nil && obj.RequireCanonical {
+			return errors.New("requireCanonical only applies to blockHash")
+		}
+		*bnh = BlockNumberOrHash(obj)
+		return nil
+	}
+	var input string
+	if err := json.Unmarshal(data, &input); err == nil && len(input) == 2+2*common.HashLength {
+		var hash common.Hash
+		if err := hash.UnmarshalText([]byte(input)); err != nil {
+			return err
+		}
+		*bnh = BlockNumberOrHashWithHash(hash, false)
 		return nil
 	}
+	var number BlockNumber
+	if err := number.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*bnh = BlockNumberOrHashWithNumber(number)
+	return nil
+}
 
-	return fmt.Errorf("blocknumber not in range [%d, %d]", earliestBlockNumber, maxBlockNumber)
+// Number returns the block number, if the block is identified by it.
+func (bnh BlockNumberOrHash) Number() (BlockNumber, bool) {
+	if bnh.BlockNumber != nil {
+		return *bnh.BlockNumber, true
+	}
+	return BlockNumber(0), false
 }
 
-func (bn BlockNumber) Int64() int64 {
-	return (int64)(bn)
+// Hash returns the block hash, if the block is identified by it.
+func (bnh BlockNumberOrHash) Hash() (common.Hash, bool) {
+	if bnh.BlockHash != nil {
+		return *bnh.BlockHash, true
+	}
+	return common.Hash{}, false
 }
@@ -0,0 +1,108 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/common"
+)
+
+func TestBlockNumberJSONUnmarshal(t *testing.T) {
+	tests := []struct {
+		input    string
+		mustFail bool
+		expected BlockNumber
+	}{
+		{`"0x"`, true, BlockNumber(0)},
+		{`"0x0"`, false, BlockNumber(0)},
+		{`"0X1"`, false, BlockNumber(1)},
+		{`"0x00"`, true, BlockNumber(0)},
+		{`"0x01"`, true, BlockNumber(0)},
+		{`"0x1ecfa4"`, false, BlockNumber(0x1ecfa4)},
+		{`"0xg"`, true, BlockNumber(0)},
+		{`"0x7fffffffffffffff"`, false, BlockNumber(0x7fffffffffffffff)},
+		{`"0x8000000000000000"`, true, BlockNumber(0)},
+		{`"ff"`, true, BlockNumber(0)},
+		{`"100"`, true, BlockNumber(0)},
+		{`100`, true, BlockNumber(0)},
+		{`"0100"`, true, BlockNumber(0)},
+		{`""`, true, BlockNumber(0)},
+		{`"pending"`, false, PendingBlockNumber},
+		{`"latest"`, false, LatestBlockNumber},
+		{`"earliest"`, false, BlockNumber(0)},
+		{`"safe"`, false, SafeBlockNumber},
+		{`"finalized"`, false, FinalizedBlockNumber},
+		{`"Latest"`, true, BlockNumber(0)},
+	}
+	for i, test := range tests {
+		var num BlockNumber
+		err := json.Unmarshal([]byte(test.input), &num)
+		if test.mustFail && err == nil {
+			t.Errorf("test %d: %s: expected error", i, test.input)
+			continue
+		}
+		if !test.mustFail && err != nil {
+			t.Errorf("test %d: %s: unexpected error: %v", i, test.input, err)
+			continue
+		}
+		if num != test.expected {
+			t.Errorf("test %d: %s: number mismatch: have %d, want %d", i, test.input, num, test.expected)
+		}
+	}
+}
+
+func TestBlockNumberOrHashUnmarshalJSON(t *testing.T) {
+	hash := common.HexToHash("0x656c34545f90a730a19008c0e7a7cd4fb3895064b48d6d69761bd5abad681056")
+
+	tests := []struct {
+		input    string
+		mustFail bool
+		expected BlockNumberOrHash
+	}{
+		{`"0x1ecfa4"`, false, BlockNumberOrHashWithNumber(0x1ecfa4)},
+		{`"latest"`, false, BlockNumberOrHashWithNumber(LatestBlockNumber)},
+		{`"finalized"`, false, BlockNumberOrHashWithNumber(FinalizedBlockNumber)},
+		{`"0x` + common.Bytes2Hex(hash[:]) + `"`, false, BlockNumberOrHashWithHash(hash, false)},
+		{`{"blockNumber":"pending"}`, false, BlockNumberOrHashWithNumber(PendingBlockNumber)},
+		{`{"blockHash":"0x` + common.Bytes2Hex(hash[:]) + `"}`, false, BlockNumberOrHashWithHash(hash, false)},
+		{`{"blockHash":"0x` + common.Bytes2Hex(hash[:]) + `","requireCanonical":true}`, false, BlockNumberOrHashWithHash(hash, true)},
+		{`{"blockNumber":"0x1","requireCanonical":true}`, true, BlockNumberOrHash{}},
+		{`{"blockNumber":"0x1","blockHash":"0x` + common.Bytes2Hex(hash[:]) + `"}`, true, BlockNumberOrHash{}},
+		{`{}`, true, BlockNumberOrHash{}},
+		{`{"blockNumber":"1"}`, true, BlockNumberOrHash{}},
+		{`"0x` + common.Bytes2Hex(hash[:31]) + `zz"`, true, BlockNumberOrHash{}},
+		{`"100"`, true, BlockNumberOrHash{}},
+	}
+	for i, test := range tests {
+		var bnh BlockNumberOrHash
+		err := json.Unmarshal([]byte(test.input), &bnh)
+		if test.mustFail && err == nil {
+			t.Errorf("test %d: %s: expected error", i, test.input)
+			continue
+		}
+		if !test.mustFail && err != nil {
+			t.Errorf("test %d: %s: unexpected error: %v", i, test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(bnh, test.expected) {
+			t.Errorf("test %d: %s: mismatch: have %+v, want %+v", i, test.input, bnh, test.expected)
+		}
+	}
+}
@@ -34,6 +34,7 @@ var (
 	transactionTestDir = filepath.Join(baseDir, "TransactionTests")
 	vmTestDir          = filepath.Join(baseDir, "VMTests")
 	rlpTestDir         = filepath.Join(baseDir, "RLPTests")
+	rewardTestDir      = filepath.Join(baseDir, "RewardTests")
 
 	BlockSkipTests = []string{
 		// These tests are not valid, as they are out of scope for RLP and
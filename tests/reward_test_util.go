@@ -0,0 +1,154 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/math"
+	"github.com/expanse-org/go-expanse/core"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/pow"
+)
+
+// RewardTest checks the rewards credited to the author of a block and to the
+// authors of the uncles it includes. Starting from the pre-state, the rewards of
+// the block described by the environment are credited, after which the state
+// must match the post-state and its root. The format is documented in the
+// README of the RewardTests directory.
+type RewardTest struct {
+	Env           VmEnv              `json:"env"`
+	Uncles        []RewardUncle      `json:"uncles"`
+	Pre           map[string]Account `json:"pre"`
+	Post          map[string]Account `json:"post"`
+	PostStateRoot string             `json:"postStateRoot"`
+}
+
+// RewardUncle is an uncle included by the block of a reward test.
+type RewardUncle struct {
+	Coinbase string `json:"coinbase"`
+	Number   string `json:"number"`
+}
+
+// RunRewardTest runs all the reward tests of a file.
+func RunRewardTest(p string) error {
+	tests := make(map[string]RewardTest)
+	if err := readJsonFile(p, &tests); err != nil {
+		return err
+	}
+	for name, test := range tests {
+		if err := runRewardTest(test); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func runRewardTest(test RewardTest) error {
+	db, _ := ethdb.NewMemDatabase()
+	statedb := makePreState(db, test.Pre)
+
+	if err := applyRewards(statedb, test.Env, test.Uncles); err != nil {
+		return err
+	}
+	for addr, account := range test.Post {
+		address := common.HexToAddress(addr)
+		if !statedb.Exist(address) {
+			return fmt.Errorf("did not find expected post-state account: %s", addr)
+		}
+		if balance := statedb.GetBalance(address); balance.Cmp(math.MustParseBig256(account.Balance)) != 0 {
+			return fmt.Errorf("(%x) balance failed. Expected: %v have: %v", address[:4], math.MustParseBig256(account.Balance), balance)
+		}
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		return err
+	}
+	if common.HexToHash(test.PostStateRoot) != root {
+		return fmt.Errorf("post state root error. Expected: %s have: %x", test.PostStateRoot, root)
+	}
+	return nil
+}
+
+// applyRewards credits the rewards of a block with the given environment and
+// uncles the same way block processing does after executing the transactions.
+func applyRewards(statedb *state.StateDB, env VmEnv, uncles []RewardUncle) error {
+	header := &types.Header{
+		Number:   math.MustParseBig256(env.CurrentNumber),
+		Coinbase: common.HexToAddress(env.CurrentCoinbase),
+	}
+	headers := make([]*types.Header, len(uncles))
+	for i, uncle := range uncles {
+		headers[i] = &types.Header{
+			Number:   math.MustParseBig256(uncle.Number),
+			Coinbase: common.HexToAddress(uncle.Coinbase),
+		}
+	}
+	return core.AccumulateRewards(core.NewPowEngine(pow.FakePow{}), statedb, header, headers)
+}
+
+// FillRewardTest credits the rewards of a reward test on top of its pre-state
+// and fills in the expected post-state and post-state root. Any previously
+// filled results are overwritten.
+func FillRewardTest(test *RewardTest) error {
+	db, _ := ethdb.NewMemDatabase()
+	statedb := makePreState(db, test.Pre)
+
+	if err := applyRewards(statedb, test.Env, test.Uncles); err != nil {
+		return err
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		return err
+	}
+	post, err := dumpPostState(statedb)
+	if err != nil {
+		return err
+	}
+	test.Post = post
+	test.PostStateRoot = common.Bytes2Hex(root[:])
+
+	return nil
+}
+
+// FillRewardTests fills the given reward tests and encodes them into JSON.
+func FillRewardTests(tests map[string]RewardTest) ([]byte, error) {
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filled := make(map[string]RewardTest, len(tests))
+	for _, name := range names {
+		test := tests[name]
+		if err := FillRewardTest(&test); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		filled[name] = test
+	}
+	blob, err := json.MarshalIndent(filled, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(blob, '\n'), nil
+}
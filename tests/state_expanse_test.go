@@ -0,0 +1,183 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/expanse-org/go-expanse/params"
+)
+
+var fillExpanseTests = flag.Bool("fill", false, "regenerate the Expanse state and reward test vectors instead of verifying them")
+
+// expanseForkBlock is the block at which the fork gated opcodes are activated
+// by the chain rules of the Expanse state test vectors.
+const expanseForkBlock = 10
+
+var expanseTestConfig = &params.ChainConfig{
+	HomesteadBlock: new(big.Int),
+	EIP150Block:    new(big.Int),
+	EIP155Block:    new(big.Int),
+	EIP158Block:    new(big.Int),
+	EIP1014Block:   big.NewInt(expanseForkBlock),
+	EIP211Block:    big.NewInt(expanseForkBlock),
+	EIP145Block:    big.NewInt(expanseForkBlock),
+	EIP1052Block:   big.NewInt(expanseForkBlock),
+	EIP140Block:    big.NewInt(expanseForkBlock),
+	EIP214Block:    big.NewInt(expanseForkBlock),
+}
+
+const (
+	expanseTestSender   = "a94f5374fce5edbc8e2a8697c15331677e6ebf0b"
+	expanseTestContract = "095e7baea6a6c7c4c2dfeb977efac326af552d87"
+	expanseTestCoinbase = "2adc25665018aa1fe0e6bc666dac8fc2697ff9ba"
+	expanseTestUncle1   = "3535353535353535353535353535353535353535"
+	expanseTestUncle2   = "4545454545454545454545454545454545454545"
+)
+
+// expanseStateTest creates an unfilled state test calling a contract with the
+// given code in the given block.
+func expanseStateTest(number uint64, code string) VmTest {
+	return VmTest{
+		Env: VmEnv{
+			CurrentCoinbase:   expanseTestCoinbase,
+			CurrentDifficulty: "0x020000",
+			CurrentGasLimit:   "0x7fffffffffffffff",
+			CurrentNumber:     fmt.Sprintf("%#x", number),
+			CurrentTimestamp:  "0x03e8",
+			PreviousHash:      "5e20a0453cecd065ea59c37ac63e079ee08998b6045136a8ce6635c7912ec0b6",
+		},
+		Pre: map[string]Account{
+			expanseTestContract: {Balance: "0x0de0b6b3a7640000", Code: code, Nonce: "0x00", Storage: map[string]string{}},
+			expanseTestSender:   {Balance: "0x0de0b6b3a7640000", Code: "0x", Nonce: "0x00", Storage: map[string]string{}},
+		},
+		Transaction: map[string]string{
+			"data":      "",
+			"gasLimit":  "0x061a80",
+			"gasPrice":  "0x01",
+			"nonce":     "0x00",
+			"secretKey": "45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8",
+			"to":        expanseTestContract,
+			"value":     "0x0186a0",
+		},
+	}
+}
+
+// expanseForkGateTests runs every fork gated opcode right before and at its
+// activation block. The opcodes are invoked with zeroed arguments and followed
+// by a storage write, which only survives if the opcode is available.
+func expanseForkGateTests() map[string]VmTest {
+	opcodes := []struct {
+		name string
+		code string
+	}{
+		{"create2", "6000600060006000f5"},
+		{"returndatasize", "3d"},
+		{"returndatacopy", "6000600060003e"},
+		{"shl", "600060001b"},
+		{"shr", "600060001c"},
+		{"sar", "600060001d"},
+		{"extcodehash", "60003f"},
+		{"revert", "60006000fd"},
+		{"staticcall", "600060006000600060006000fa"},
+	}
+	tests := make(map[string]VmTest)
+	for _, op := range opcodes {
+		code := "0x" + op.code + "6001600055"
+		tests[op.name+"BeforeFork"] = expanseStateTest(expanseForkBlock-1, code)
+		tests[op.name+"AtFork"] = expanseStateTest(expanseForkBlock, code)
+	}
+	return tests
+}
+
+// expanseRewardTests credits the block and uncle rewards, covering the uncle
+// inclusion distances.
+func expanseRewardTests() map[string]RewardTest {
+	withUncles := func(number uint64, uncles ...RewardUncle) RewardTest {
+		return RewardTest{
+			Env: expanseStateTest(number, "0x").Env,
+			Pre: map[string]Account{
+				expanseTestCoinbase: {Balance: "0x0de0b6b3a7640000", Code: "0x", Nonce: "0x00", Storage: map[string]string{}},
+			},
+			Uncles: append([]RewardUncle{}, uncles...),
+		}
+	}
+	return map[string]RewardTest{
+		"noUncles":          withUncles(1),
+		"oneUncle":          withUncles(1, RewardUncle{expanseTestUncle1, "0x00"}),
+		"twoUncles":         withUncles(7, RewardUncle{expanseTestUncle1, "0x06"}, RewardUncle{expanseTestUncle2, "0x01"}),
+		"uncleSameCoinbase": withUncles(2, RewardUncle{expanseTestCoinbase, "0x01"}),
+		"unclesSameAuthor":  withUncles(3, RewardUncle{expanseTestUncle1, "0x02"}, RewardUncle{expanseTestUncle1, "0x01"}),
+	}
+}
+
+// TestExpanseStateTests verifies the Expanse specific state test vectors against
+// the scenarios they were generated from and runs them. After changing the
+// scenarios or the consensus rules, regenerate the vectors with:
+//
+//	go test ./tests -run TestExpanse -fill
+func TestExpanseStateTests(t *testing.T) {
+	fn := filepath.Join(stateTestDir, "stExpanseForkGates.json")
+
+	filled, err := FillStateTests(expanseTestConfig, expanseForkGateTests())
+	if err != nil {
+		t.Fatalf("failed to fill: %v", err)
+	}
+	checkExpanseVectors(t, fn, filled)
+	if err := RunStateTest(expanseTestConfig, fn, StateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestExpanseRewardTests verifies the Expanse reward test vectors against the
+// scenarios they were generated from and runs them.
+func TestExpanseRewardTests(t *testing.T) {
+	fn := filepath.Join(rewardTestDir, "expanseRewards.json")
+
+	filled, err := FillRewardTests(expanseRewardTests())
+	if err != nil {
+		t.Fatalf("failed to fill: %v", err)
+	}
+	checkExpanseVectors(t, fn, filled)
+	if err := RunRewardTest(fn); err != nil {
+		t.Error(err)
+	}
+}
+
+// checkExpanseVectors compares a vector file with its freshly filled contents,
+// or overwrites it if the vectors are being regenerated.
+func checkExpanseVectors(t *testing.T, fn string, filled []byte) {
+	if *fillExpanseTests {
+		if err := ioutil.WriteFile(fn, filled, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", fn, err)
+		}
+		return
+	}
+	have, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", fn, err)
+	}
+	if !bytes.Equal(have, filled) {
+		t.Errorf("%s: vectors out of date, regenerate them with -fill", fn)
+	}
+}
@@ -54,6 +54,25 @@ func TestStateExample(t *testing.T) {
 	}
 }
 
+func TestStateForkOpcodes(t *testing.T) {
+	chainConfig := &params.ChainConfig{
+		HomesteadBlock: new(big.Int),
+		EIP150Block:    new(big.Int),
+		EIP155Block:    new(big.Int),
+		EIP158Block:    new(big.Int),
+		EIP211Block:    new(big.Int),
+		EIP145Block:    new(big.Int),
+		EIP1052Block:   new(big.Int),
+		EIP140Block:    new(big.Int),
+		EIP214Block:    new(big.Int),
+	}
+
+	fn := filepath.Join(stateTestDir, "stForkOpcodesTest.json")
+	if err := RunStateTest(chainConfig, fn, StateSkipTests); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestStatePreCompiledContracts(t *testing.T) {
 	chainConfig := &params.ChainConfig{
 		HomesteadBlock: big.NewInt(1150000),
@@ -0,0 +1,137 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/common/hexutil"
+	"github.com/expanse-org/go-expanse/common/math"
+	"github.com/expanse-org/go-expanse/core/state"
+	"github.com/expanse-org/go-expanse/core/types"
+	"github.com/expanse-org/go-expanse/ethdb"
+	"github.com/expanse-org/go-expanse/params"
+	"github.com/expanse-org/go-expanse/rlp"
+)
+
+// FillStateTest executes the transaction of a state test on top of its pre-state
+// with the given chain rules and fills in the expected results: the return data,
+// the logs, the full post-state and the post-state root. Any previously filled
+// results are overwritten.
+func FillStateTest(chainConfig *params.ChainConfig, test *VmTest) error {
+	db, _ := ethdb.NewMemDatabase()
+	statedb := makePreState(db, test.Pre)
+
+	ret, logs, _, _ := RunState(chainConfig, statedb, stateTestEnv(test.Env), test.Transaction)
+	root, err := statedb.Commit(false)
+	if err != nil {
+		return err
+	}
+	post, err := dumpPostState(statedb)
+	if err != nil {
+		return err
+	}
+	test.Out = hexutil.Encode(ret)
+	test.Logs = make([]Log, len(logs))
+	for i, log := range logs {
+		test.Logs[i] = fillLog(log)
+	}
+	test.Post = post
+	test.PostStateRoot = common.Bytes2Hex(root[:])
+
+	return nil
+}
+
+// FillStateTests fills the given state tests and encodes them into JSON in the
+// format of the state test suite.
+func FillStateTests(chainConfig *params.ChainConfig, tests map[string]VmTest) ([]byte, error) {
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filled := make(map[string]VmTest, len(tests))
+	for _, name := range names {
+		test := tests[name]
+		if err := FillStateTest(chainConfig, &test); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		filled[name] = test
+	}
+	blob, err := json.MarshalIndent(filled, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(blob, '\n'), nil
+}
+
+// dumpPostState converts every account of the state into its state test form.
+func dumpPostState(statedb *state.StateDB) (map[string]Account, error) {
+	post := make(map[string]Account)
+	for addr, dumped := range statedb.RawDump().Accounts {
+		balance, ok := new(big.Int).SetString(dumped.Balance, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid balance %q of %s", dumped.Balance, addr)
+		}
+		account := Account{
+			Balance: quantityHex(balance),
+			Code:    "0x" + dumped.Code,
+			Nonce:   quantityHex(new(big.Int).SetUint64(dumped.Nonce)),
+			Storage: make(map[string]string),
+		}
+		for key, enc := range dumped.Storage {
+			var value []byte
+			if err := rlp.DecodeBytes(common.Hex2Bytes(enc), &value); err != nil {
+				return nil, fmt.Errorf("invalid storage value of %s: %v", addr, err)
+			}
+			slot := new(big.Int).SetBytes(common.Hex2Bytes(key))
+			account.Storage[quantityHex(slot)] = quantityHex(new(big.Int).SetBytes(value))
+		}
+		post[addr] = account
+	}
+	return post, nil
+}
+
+// fillLog converts a log into its state test form.
+func fillLog(log *types.Log) Log {
+	topics := make([]string, len(log.Topics))
+	for i, topic := range log.Topics {
+		topics[i] = common.Bytes2Hex(topic[:])
+	}
+	bloom := math.PaddedBigBytes(types.LogsBloom([]*types.Log{log}), 256)
+	return Log{
+		AddressF: common.Bytes2Hex(log.Address[:]),
+		DataF:    hexutil.Encode(log.Data),
+		TopicsF:  topics,
+		BloomF:   common.Bytes2Hex(bloom),
+	}
+}
+
+// quantityHex formats a number the way the state test suite does: 0x prefixed,
+// with an even number of hex digits.
+func quantityHex(n *big.Int) string {
+	s := n.Text(16)
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return "0x" + s
+}
@@ -130,19 +130,6 @@ func runStateTest(chainConfig *params.ChainConfig, test VmTest) error {
 	db, _ := ethdb.NewMemDatabase()
 	statedb := makePreState(db, test.Pre)
 
-	// XXX Yeah, yeah...
-	env := make(map[string]string)
-	env["currentCoinbase"] = test.Env.CurrentCoinbase
-	env["currentDifficulty"] = test.Env.CurrentDifficulty
-	env["currentGasLimit"] = test.Env.CurrentGasLimit
-	env["currentNumber"] = test.Env.CurrentNumber
-	env["previousHash"] = test.Env.PreviousHash
-	if n, ok := test.Env.CurrentTimestamp.(float64); ok {
-		env["currentTimestamp"] = strconv.Itoa(int(n))
-	} else {
-		env["currentTimestamp"] = test.Env.CurrentTimestamp.(string)
-	}
-
 	var (
 		ret []byte
 		// gas  *big.Int
@@ -150,7 +137,7 @@ func runStateTest(chainConfig *params.ChainConfig, test VmTest) error {
 		logs []*types.Log
 	)
 
-	ret, logs, _, _ = RunState(chainConfig, statedb, env, test.Transaction)
+	ret, logs, _, _ = RunState(chainConfig, statedb, stateTestEnv(test.Env), test.Transaction)
 
 	// Compare expected and actual return
 	var rexp []byte
@@ -204,6 +191,24 @@ func runStateTest(chainConfig *params.ChainConfig, test VmTest) error {
 	return nil
 }
 
+// stateTestEnv flattens the block environment of a state test into the form
+// accepted by RunState.
+func stateTestEnv(vmenv VmEnv) map[string]string {
+	// XXX Yeah, yeah...
+	env := make(map[string]string)
+	env["currentCoinbase"] = vmenv.CurrentCoinbase
+	env["currentDifficulty"] = vmenv.CurrentDifficulty
+	env["currentGasLimit"] = vmenv.CurrentGasLimit
+	env["currentNumber"] = vmenv.CurrentNumber
+	env["previousHash"] = vmenv.PreviousHash
+	if n, ok := vmenv.CurrentTimestamp.(float64); ok {
+		env["currentTimestamp"] = strconv.Itoa(int(n))
+	} else {
+		env["currentTimestamp"] = vmenv.CurrentTimestamp.(string)
+	}
+	return env
+}
+
 func RunState(chainConfig *params.ChainConfig, statedb *state.StateDB, env, tx map[string]string) ([]byte, []*types.Log, *big.Int, error) {
 	environment, msg := NewEVMEnvironment(false, chainConfig, statedb, env, tx)
 	gaspool := new(core.GasPool).AddGas(math.MustParseBig256(env["currentGasLimit"]))
@@ -214,7 +219,7 @@ func RunState(chainConfig *params.ChainConfig, statedb *state.StateDB, env, tx m
 	snapshot := statedb.Snapshot()
 
 	ret, gasUsed, err := core.ApplyMessage(environment, msg, gaspool)
-	if core.IsNonceErr(err) || core.IsInvalidTxErr(err) || core.IsGasLimitErr(err) {
+	if core.IsNonceErr(err) || core.IsInvalidTxErr(err) || core.IsGasLimitErr(err) || core.IsIntrinsicGasErr(err) || core.IsInsufficientFundsErr(err) {
 		statedb.RevertToSnapshot(snapshot)
 	}
 	statedb.Commit(chainConfig.IsEIP158(environment.Context.BlockNumber))
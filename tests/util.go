@@ -82,10 +82,10 @@ func checkLogs(tlog []Log, logs []*types.Log) error {
 }
 
 type Account struct {
-	Balance string
-	Code    string
-	Nonce   string
-	Storage map[string]string
+	Balance string            `json:"balance"`
+	Code    string            `json:"code"`
+	Nonce   string            `json:"nonce"`
+	Storage map[string]string `json:"storage"`
 }
 
 type Log struct {
@@ -128,26 +128,26 @@ func insertAccount(state *state.StateDB, saddr string, account Account) {
 }
 
 type VmEnv struct {
-	CurrentCoinbase   string
-	CurrentDifficulty string
-	CurrentGasLimit   string
-	CurrentNumber     string
-	CurrentTimestamp  interface{}
-	PreviousHash      string
+	CurrentCoinbase   string      `json:"currentCoinbase"`
+	CurrentDifficulty string      `json:"currentDifficulty"`
+	CurrentGasLimit   string      `json:"currentGasLimit"`
+	CurrentNumber     string      `json:"currentNumber"`
+	CurrentTimestamp  interface{} `json:"currentTimestamp"`
+	PreviousHash      string      `json:"previousHash"`
 }
 
 type VmTest struct {
-	Callcreates interface{}
+	Callcreates interface{} `json:"callcreates,omitempty"`
 	//Env         map[string]string
-	Env           VmEnv
-	Exec          map[string]string
-	Transaction   map[string]string
-	Logs          []Log
-	Gas           string
-	Out           string
-	Post          map[string]Account
-	Pre           map[string]Account
-	PostStateRoot string
+	Env           VmEnv              `json:"env"`
+	Exec          map[string]string  `json:"exec,omitempty"`
+	Transaction   map[string]string  `json:"transaction,omitempty"`
+	Logs          []Log              `json:"logs"`
+	Gas           string             `json:"gas,omitempty"`
+	Out           string             `json:"out"`
+	Post          map[string]Account `json:"post"`
+	Pre           map[string]Account `json:"pre"`
+	PostStateRoot string             `json:"postStateRoot,omitempty"`
 }
 
 func NewEVMEnvironment(vmTest bool, chainConfig *params.ChainConfig, statedb *state.StateDB, envValues map[string]string, tx map[string]string) (*vm.EVM, core.Message) {
@@ -30,6 +30,7 @@ type hasher struct {
 	tmp                  *bytes.Buffer
 	sha                  hash.Hash
 	cachegen, cachelimit uint16
+	parallel             bool // Whether to hash the children of full nodes concurrently
 }
 
 // hashers live in a global pool.
@@ -41,10 +42,23 @@ var hasherPool = sync.Pool{
 
 func newHasher(cachegen, cachelimit uint16) *hasher {
 	h := hasherPool.Get().(*hasher)
-	h.cachegen, h.cachelimit = cachegen, cachelimit
+	h.cachegen, h.cachelimit, h.parallel = cachegen, cachelimit, false
 	return h
 }
 
+// lockedWriter serializes the database writes of concurrently running hashers.
+type lockedWriter struct {
+	db   DatabaseWriter
+	lock sync.Mutex
+}
+
+func (w *lockedWriter) Put(key, value []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.db.Put(key, value)
+}
+
 func returnHasherToPool(h *hasher) {
 	hasherPool.Put(h)
 }
@@ -123,14 +137,20 @@ func (h *hasher) hashChildren(original node, db DatabaseWriter) (node, node, err
 		// Hash the full node's children, caching the newly hashed subtrees
 		collapsed, cached := n.copy(), n.copy()
 
-		for i := 0; i < 16; i++ {
-			if n.Children[i] != nil {
-				collapsed.Children[i], cached.Children[i], err = h.hash(n.Children[i], db, false)
-				if err != nil {
-					return original, original, err
+		if h.parallel {
+			if err := h.hashChildrenParallel(n, collapsed, cached, db); err != nil {
+				return original, original, err
+			}
+		} else {
+			for i := 0; i < 16; i++ {
+				if n.Children[i] != nil {
+					collapsed.Children[i], cached.Children[i], err = h.hash(n.Children[i], db, false)
+					if err != nil {
+						return original, original, err
+					}
+				} else {
+					collapsed.Children[i] = valueNode(nil) // Ensure that nil children are encoded as empty strings.
 				}
-			} else {
-				collapsed.Children[i] = valueNode(nil) // Ensure that nil children are encoded as empty strings.
 			}
 		}
 		cached.Children[16] = n.Children[16]
@@ -145,6 +165,42 @@ func (h *hasher) hashChildren(original node, db DatabaseWriter) (node, node, err
 	}
 }
 
+// hashChildrenParallel hashes the 16 children of a full node concurrently, each
+// subtree with its own sequential hasher, filling in the collapsed and cached
+// replacement nodes.
+func (h *hasher) hashChildrenParallel(n, collapsed, cached *fullNode, db DatabaseWriter) error {
+	if db != nil {
+		db = &lockedWriter{db: db}
+	}
+	var (
+		wg   sync.WaitGroup
+		errs [16]error
+	)
+	for i := 0; i < 16; i++ {
+		if n.Children[i] == nil {
+			collapsed.Children[i] = valueNode(nil) // Ensure that nil children are encoded as empty strings.
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			hasher := newHasher(h.cachegen, h.cachelimit)
+			defer returnHasherToPool(hasher)
+
+			collapsed.Children[i], cached.Children[i], errs[i] = hasher.hash(n.Children[i], db, false)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (h *hasher) store(n node, db DatabaseWriter, force bool) (node, error) {
 	// Don't store hashes or empty nodes.
 	if _, isHash := n.(hashNode); n == nil || isHash {
@@ -27,6 +27,7 @@ type Iterator struct {
 
 	Key   []byte // Current data key on which the iterator is positioned on
 	Value []byte // Current data value on which the iterator is positioned on
+	Err   error  // Failure set if the iteration stopped on a missing trie node
 }
 
 // NewIterator creates a new key-value iterator.
@@ -54,6 +55,7 @@ func (it *Iterator) Next() bool {
 	}
 	it.Key = nil
 	it.Value = nil
+	it.Err = it.nodeIt.Error()
 	return false
 }
 
@@ -32,6 +32,10 @@ var (
 	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
 	// This is the known hash of an empty state trie entry.
 	emptyState common.Hash
+
+	// Number of updates since the last hashing above which the root's subtrees
+	// are hashed concurrently.
+	parallelHashThreshold = 100
 )
 
 var (
@@ -91,6 +95,10 @@ type Trie struct {
 	// new nodes are tagged with the current generation and unloaded
 	// when their generation is older than than cachegen-cachelimit.
 	cachegen, cachelimit uint16
+
+	// Number of updates since the trie was last hashed, used to decide whether
+	// hashing is worth spreading across multiple threads.
+	unhashed int
 }
 
 // SetCacheLimit sets the number of 'cache generations' to keep.
@@ -211,6 +219,7 @@ func (t *Trie) Update(key, value []byte) {
 //
 // If a node was not found in the database, a MissingNodeError is returned.
 func (t *Trie) TryUpdate(key, value []byte) error {
+	t.unhashed++
 	k := compactHexDecode(key)
 	if len(value) != 0 {
 		_, n, err := t.insert(t.root, nil, k, valueNode(value))
@@ -307,6 +316,7 @@ func (t *Trie) Delete(key []byte) {
 // TryDelete removes any existing value for key from the trie.
 // If a node was not found in the database, a MissingNodeError is returned.
 func (t *Trie) TryDelete(key []byte) error {
+	t.unhashed++
 	k := compactHexDecode(key)
 	_, n, err := t.delete(t.root, nil, k)
 	if err != nil {
@@ -506,5 +516,10 @@ func (t *Trie) hashRoot(db DatabaseWriter) (node, node, error) {
 	}
 	h := newHasher(t.cachegen, t.cachelimit)
 	defer returnHasherToPool(h)
+
+	// Hash the subtrees of the root concurrently if enough of them changed
+	h.parallel = t.unhashed >= parallelHashThreshold
+	t.unhashed = 0
+
 	return h.hash(t.root, db, true)
 }
@@ -29,6 +29,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/expanse-org/go-expanse/common"
+	"github.com/expanse-org/go-expanse/crypto"
 	"github.com/expanse-org/go-expanse/ethdb"
 )
 
@@ -309,6 +310,45 @@ func TestLargeValue(t *testing.T) {
 	trie.Hash()
 }
 
+// Tests that hashing and committing the subtrees of the root concurrently yields
+// the same root and database contents as doing it sequentially.
+func TestParallelHash(t *testing.T) {
+	defer func(threshold int) { parallelHashThreshold = threshold }(parallelHashThreshold)
+
+	commit := func(parallel bool) (common.Hash, *ethdb.MemDatabase) {
+		if parallel {
+			parallelHashThreshold = 0
+		} else {
+			parallelHashThreshold = 1 << 30
+		}
+		db, _ := ethdb.NewMemDatabase()
+		trie, _ := New(common.Hash{}, db)
+		for i := 0; i < 1000; i++ {
+			key := crypto.Keccak256([]byte{byte(i), byte(i >> 8)})
+			trie.Update(key, key[:i%32+1])
+		}
+		root, err := trie.Commit()
+		if err != nil {
+			t.Fatalf("parallel %v: commit failed: %v", parallel, err)
+		}
+		return root, db
+	}
+	seqRoot, seqDb := commit(false)
+	parRoot, parDb := commit(true)
+
+	if seqRoot != parRoot {
+		t.Fatalf("root mismatch: sequential %x, parallel %x", seqRoot, parRoot)
+	}
+	if seqKeys, parKeys := len(seqDb.Keys()), len(parDb.Keys()); seqKeys != parKeys {
+		t.Fatalf("node count mismatch: sequential %d, parallel %d", seqKeys, parKeys)
+	}
+	for _, key := range seqDb.Keys() {
+		if _, err := parDb.Get(key); err != nil {
+			t.Errorf("node %x missing from parallel commit", key)
+		}
+	}
+}
+
 type countingDB struct {
 	Database
 	gets map[string]int
@@ -503,6 +543,9 @@ func BenchmarkUpdateLE(b *testing.B) { benchUpdate(b, binary.LittleEndian) }
 func BenchmarkHashBE(b *testing.B)   { benchHash(b, binary.BigEndian) }
 func BenchmarkHashLE(b *testing.B)   { benchHash(b, binary.LittleEndian) }
 
+func BenchmarkHashFreshSequential(b *testing.B) { benchHashFresh(b, false) }
+func BenchmarkHashFreshParallel(b *testing.B)   { benchHashFresh(b, true) }
+
 const benchElemCount = 20000
 
 func benchGet(b *testing.B, commit bool) {
@@ -558,6 +601,33 @@ func benchHash(b *testing.B, e binary.ByteOrder) {
 	}
 }
 
+// benchHashFresh measures hashing a trie whose nodes were all modified since
+// the last hashing, the way the state trie is hashed after block processing.
+func benchHashFresh(b *testing.B, parallel bool) {
+	defer func(threshold int) { parallelHashThreshold = threshold }(parallelHashThreshold)
+	if parallel {
+		parallelHashThreshold = 0
+	} else {
+		parallelHashThreshold = benchElemCount + 1
+	}
+	keys := make([][]byte, benchElemCount)
+	for i := range keys {
+		keys[i] = make([]byte, 32)
+		rand.Read(keys[i])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		trie := newEmpty()
+		for _, k := range keys {
+			trie.Update(k, k)
+		}
+		b.StartTimer()
+		trie.Hash()
+	}
+}
+
 func tempDB() (string, Database) {
 	dir, err := ioutil.TempDir("", "trie-bench")
 	if err != nil {